@@ -11,6 +11,8 @@ import (
 	"github.com/ujwegh/gophermart/internal/app/router"
 	"github.com/ujwegh/gophermart/internal/app/service"
 	"github.com/ujwegh/gophermart/internal/app/service/clients"
+	"github.com/ujwegh/gophermart/migrations"
+	"go.uber.org/zap"
 	"log"
 	"net/http"
 	"os"
@@ -45,34 +47,118 @@ func main() {
 
 	c := config.ParseFlags()
 	logger.InitLogger(c.LogLevel)
+	if err := c.Validate(); err != nil {
+		logger.Log.Fatal("invalid config", zap.Error(err))
+	}
+	if c.InsecureTokenSecret() {
+		logger.Log.Warn("tokens are signed with the default secret, set TOKEN_SECRET_KEY")
+	}
+	if c.Features[config.FeatureDevEndpoints] && !c.FeatureEnabled(config.FeatureDevEndpoints) {
+		logger.Log.Warn("dev mode is ignored outside the dev environment", zap.String("environment", string(c.Environment)))
+	}
+
+	if c.MigrateOnly {
+		if err := repository.RunMigrations(c); err != nil {
+			logger.Log.Fatal("unable to apply migrations", zap.Error(err))
+		}
+		logger.Log.Info("migrations applied")
+		return
+	}
 
 	ts := service.NewTokenService(c)
 	s := repository.NewDBStorage(c)
 	ur := repository.NewUserRepository(s.DBConn)
-	or := repository.NewOrderRepository(s.DBConn)
+	or := repository.NewOrderRepository(s.DBConn).WithReadDB(s.ReadDBConn)
 	wr := repository.NewWalletRepository(s.DBConn)
-	wlr := repository.NewWithdrawalsRepository(s.DBConn)
+	wlr := repository.NewWithdrawalsRepository(s.DBConn).WithReadDB(s.ReadDBConn)
+	ohr := repository.NewOrderHistoryRepository(s.DBConn)
+	sr := repository.NewStatsRepository(s.DBConn).WithReadDB(s.ReadDBConn)
+	dmr := repository.NewDeviceMarkerRepository(s.DBConn)
 
 	processOrderChannel := make(chan repository.Order, 100)
 
 	ws := service.NewWalletService(wr)
-	ors := service.NewOrderService(or, ws, processOrderChannel)
-	oc := service.NewOrderCache(10*time.Second, 5*time.Minute, processOrderChannel)
+	ors := service.NewOrderService(or, ohr, ws, processOrderChannel).
+		WithStuckAfter(time.Duration(c.OrderRetryCooldownSec) * time.Second)
+	oc := service.NewOrderCache(10*time.Second, 5*time.Minute, processOrderChannel).WithMaxSize(c.OrderCacheMaxSize)
 	ac := clients.NewAccrualClient(c)
-	wls := service.NewWithdrawalService(wlr, ws)
-	us := service.NewUserService(ur, ws)
-
-	uh := handlers.NewUserHandler(us, ts, c.TokenLifetimeSec)
-	oh := handlers.NewOrdersHandler(c.ContextTimeoutSec, ors)
-	bh := handlers.NewBalanceHandler(c.ContextTimeoutSec, ws, wls)
+	logAccrualVersion(ac, c.AccrualSystemRequestTimeoutSec)
+	wls := service.NewWithdrawalService(wlr, or, ws)
+	us := service.NewUserService(ur, ws).
+		WithLoginLockout(c.LoginMaxFailures, time.Duration(c.LoginLockoutSec)*time.Second)
+	rs := service.NewReconcileService(us, wr, or, wlr)
+	ls := service.NewLedgerService(ors, wls)
+	ss := service.NewStatsService(sr)
+	dms := service.NewDeviceMarkerService(dmr)
+
+	statusMapping, err := service.NewAccrualStatusMapping(c.AccrualStatusMap)
+	if err != nil {
+		logger.Log.Fatal("invalid accrual status mapping", zap.Error(err))
+	}
+	op := service.NewOrderProcessor(or, ohr, oc, ws, ac, processOrderChannel, c.AccrualLookupConcurrency, c.OrderMaxAttempts,
+		time.Duration(c.AccrualNotRegisteredRetrySec)*time.Second).
+		WithStatusMapping(statusMapping).
+		WithCommitConcurrency(c.CommitConcurrency()).
+		WithPollFloor(time.Duration(c.AccrualPollFloorSec)*time.Second).
+		WithWriteTimeout(time.Duration(c.OrderWriteTimeoutSec)*time.Second).
+		WithMaxLookupFailures(c.MaxAccrualAttempts).
+		WithStartupLease(repository.NewLeaseRepository(s.DBConn), time.Duration(c.StartupLeaseSec)*time.Second)
+	op.ProcessUnfinishedOrders()
+
+	authCookieName := ""
+	if c.FeatureEnabled(config.FeatureCookieAuth) {
+		authCookieName = c.AuthCookieName
+	}
+	uh := handlers.NewUserHandler(us, ts, c.TokenLifetimeSec).
+		WithTokenCookie(authCookieName, time.Duration(c.TokenLifetimeSec)*time.Second, !c.Environment.IsDev()).
+		WithTokenLifetime(time.Duration(c.TokenLifetimeSec) * time.Second)
+	pg := handlers.NewPagination(c.DefaultPageSize, c.MaxPageSize)
+	oh := handlers.NewOrdersHandler(c.TimeoutSec(c.OrdersTimeoutSec), pg, c.OrderRetryCooldownSec, ors, dms)
+	bh := handlers.NewBalanceHandler(c.TimeoutSec(c.BalanceTimeoutSec), pg, ws, wls, ors).
+		WithPendingAccruals(c.FeatureEnabled(config.FeaturePendingBalance))
+	lh := handlers.NewLedgerHandler(c.TimeoutSec(c.BalanceTimeoutSec), pg, ls)
+	sh := handlers.NewStatsHandler(c.TimeoutSec(c.BalanceTimeoutSec), ss)
+	dvh := handlers.NewDeviceHandler(c.TimeoutSec(c.OrdersTimeoutSec), dms)
+	ah := handlers.NewAdminHandler(c.TimeoutSec(c.AdminTimeoutSec), pg, rs, ors, us, wls)
+	mh := handlers.NewMetricsHandler(op, op)
+	latestMigration, err := repository.LatestMigrationVersion(migrations.FS)
+	if err != nil {
+		logger.Log.Fatal("unable to read embedded migrations", zap.Error(err))
+	}
+	mgh := handlers.NewMigrationsHandler(s, latestMigration)
+	mth := handlers.NewMetaHandler()
+	hh := handlers.NewHealthHandler(op, c.ProcessorStaleSec)
+	dh := handlers.NewDevHandler()
+
+	am := middlware.NewAuthMiddleware(ts, us, c.ContextTimeoutSec, c.AdminLogins).WithTokenCookie(authCookieName)
+	al, err := middlware.NewAccessLogger(c.AccessLogLevel, c.AccessLogBodies)
+	if err != nil {
+		logger.Log.Fatal("invalid access log config", zap.Error(err))
+	}
+	clientIPs, err := middlware.NewClientIPResolver(c.TrustedProxies)
+	if err != nil {
+		logger.Log.Fatal("invalid trusted proxies", zap.Error(err))
+	}
+	al.WithClientIPResolver(clientIPs)
+	rl := middlware.NewIPRateLimiter(c.ClientRateLimitPerSec, clientIPs)
 
-	am := middlware.NewAuthMiddleware(ts, us, c.ContextTimeoutSec)
+	sg := middlware.NewShutdownGuard()
 
-	r := router.NewAppRouter(c.ServerAddr, uh, oh, bh, am)
+	r := router.NewAppRouter(c.ServerAddr, c.GzipMinSizeBytes, c.FeatureEnabled, uh, oh, bh, lh, sh, dvh, ah, mh, mgh, mth, hh, dh, am, al, rl, sg)
 
-	op := service.NewOrderProcessor(or, oc, ws, ac, processOrderChannel)
 	go op.ProcessOrders(serverCtx)
 
+	if c.OrderRetentionDays > 0 {
+		cleaner := service.NewOrderCleaner(or, time.Duration(c.OrderRetentionDays)*24*time.Hour,
+			time.Duration(c.OrderCleanupIntervalSec)*time.Second)
+		go cleaner.Run(serverCtx)
+	}
+
+	if c.WalletReconcileIntervalSec > 0 {
+		reconciler := service.NewWalletReconciler(wr, time.Duration(c.WalletReconcileIntervalSec)*time.Second)
+		go reconciler.Run(serverCtx)
+	}
+
 	server := &http.Server{Addr: c.ServerAddr, Handler: r}
 
 	serverErrors := make(chan error, 1)
@@ -88,6 +174,11 @@ func main() {
 	case sig := <-shutdown:
 		log.Printf("Start shutdown %v", sig)
 
+		// new requests get 503 from now on, the listener stays open for the drain period
+		// so load balancers see the instance going away instead of refused connections
+		sg.Reject()
+		time.Sleep(time.Duration(c.ShutdownDrainSec) * time.Second)
+
 		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 		defer cancel()
 
@@ -95,6 +186,8 @@ func main() {
 		if err != nil {
 			log.Fatalf("graceful shutdown did not complete in 30s: %v", err)
 		}
+		op.ReleaseStartupLease(ctx)
+		oc.Close() // evictions must stop sending before the channel is closed
 		close(processOrderChannel)
 
 	case err := <-serverErrors:
@@ -104,3 +197,15 @@ func main() {
 	serverStopCtx()
 	log.Println("finished shutting down server")
 }
+
+func logAccrualVersion(ac clients.AccrualClient, timeoutSec int) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeoutSec)*time.Second)
+	defer cancel()
+
+	version, err := ac.Version(ctx)
+	if err != nil {
+		logger.Log.Warn("unable to detect accrual service version", zap.Error(err))
+		return
+	}
+	logger.Log.Info("detected accrual service version", zap.String("version", version))
+}
@@ -23,26 +23,26 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
-        "/api/user/balance": {
+        "/admin/metrics": {
             "get": {
                 "security": [
                     {
                         "ApiKeyAuth": []
                     }
                 ],
-                "description": "The handler returns the current amount of loyalty points and the total amount of points",
+                "description": "The handler returns how many orders were sent back to the cache to be polled again and how many\nwere marked INVALID after reaching the max attempts or lookup failures since start, and how many orders wait in the cache now.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "balance"
+                    "admin"
                 ],
-                "summary": "Getting the user's current balance",
+                "summary": "Order processor retry budget metrics",
                 "responses": {
                     "200": {
-                        "description": "Current and withdrawn loyalty points",
+                        "description": "Order processor counters",
                         "schema": {
-                            "$ref": "#/definitions/handlers.BalanceDto"
+                            "$ref": "#/definitions/handlers.ProcessorMetricsDTO"
                         }
                     },
                     "401": {
@@ -51,6 +51,12 @@ const docTemplate = `{
                             "$ref": "#/definitions/handlers.ErrorResponse"
                         }
                     },
+                    "403": {
+                        "description": "Forbidden - The user is not an admin",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
@@ -60,41 +66,107 @@ const docTemplate = `{
                 }
             }
         },
-        "/api/user/balance/withdraw": {
-            "post": {
+        "/admin/migrations": {
+            "get": {
                 "security": [
                     {
                         "ApiKeyAuth": []
                     }
                 ],
-                "description": "The handler allows an authorized user to debit points from their account for a hypothetical new order.",
-                "consumes": [
+                "description": "The handler returns the version of the latest goose migration applied to the database.",
+                "produces": [
                     "application/json"
                 ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Database migration status",
+                "responses": {
+                    "200": {
+                        "description": "Applied migration version",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.MigrationStatusDTO"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized - The user is not authorized",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden - The user is not an admin",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/orders": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "The handler returns orders of all users uploaded in the [from, to) range, oldest first,\ntogether with the login of the owning user.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "balance"
+                    "admin"
                 ],
-                "summary": "Request for debiting funds",
+                "summary": "Listing orders of all users by upload time",
                 "parameters": [
                     {
-                        "description": "Withdrawal Request",
-                        "name": "withdrawal",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/handlers.WithdrawRequestDTO"
-                        }
+                        "type": "string",
+                        "description": "Range start, RFC 3339",
+                        "name": "from",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Range end (exclusive), RFC 3339",
+                        "name": "to",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Page size, clamped to the configured maximum",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Number of orders to skip",
+                        "name": "offset",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Successful processing of the request"
+                        "description": "List of orders with owner logins",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/handlers.AdminOrderDTO"
+                            }
+                        }
+                    },
+                    "204": {
+                        "description": "No orders in the range"
                     },
                     "400": {
-                        "description": "Bad Request - Unable to read body or parse body",
+                        "description": "Bad Request - Invalid time range or pagination parameters",
                         "schema": {
                             "$ref": "#/definitions/handlers.ErrorResponse"
                         }
@@ -105,14 +177,77 @@ const docTemplate = `{
                             "$ref": "#/definitions/handlers.ErrorResponse"
                         }
                     },
-                    "402": {
-                        "description": "Payment Required - Insufficient funds in the account",
+                    "403": {
+                        "description": "Forbidden - The user is not an admin",
                         "schema": {
                             "$ref": "#/definitions/handlers.ErrorResponse"
                         }
                     },
-                    "422": {
-                        "description": "Unprocessable Entity - Incorrect order number format",
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/orders/dead-letter": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "The handler returns the INVALID orders of all users, the longest failed first, with the owner login,\nthe attempts and failed lookups made and why the order was marked INVALID.\nOrders marked INVALID before failure reasons were recorded have no reason.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Listing orders that failed processing",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Page size, clamped to the configured maximum",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Number of orders to skip",
+                        "name": "offset",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "List of failed orders",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/handlers.DeadLetterOrderDTO"
+                            }
+                        }
+                    },
+                    "204": {
+                        "description": "No failed orders"
+                    },
+                    "400": {
+                        "description": "Bad Request - Invalid pagination parameters",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized - The user is not authorized",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden - The user is not an admin",
                         "schema": {
                             "$ref": "#/definitions/handlers.ErrorResponse"
                         }
@@ -126,9 +261,14 @@ const docTemplate = `{
                 }
             }
         },
-        "/api/user/login": {
+        "/admin/orders/dead-letter/requeue": {
             "post": {
-                "description": "Authenticates a user using a login/password pair and returns a bearer token if successful.",
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "The handler resets every INVALID order of the batch back to NEW with fresh attempt counters, regardless of its owner,\nand sends it to processing again. Orders in other statuses are skipped, unknown numbers are reported as missing.\nA batch holds at most 100 order numbers.",
                 "consumes": [
                     "application/json"
                 ],
@@ -136,41 +276,47 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "users"
+                    "admin"
                 ],
-                "summary": "User login",
+                "summary": "Requeueing orders that failed processing",
                 "parameters": [
                     {
-                        "description": "User Login Credentials",
-                        "name": "user",
+                        "description": "Order numbers to requeue",
+                        "name": "request",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/handlers.UserLoginDto"
+                            "$ref": "#/definitions/handlers.RetryOrdersRequestDTO"
                         }
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Bearer \u003ctoken\u003e",
+                        "description": "Order numbers by outcome",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/handlers.RetryOrdersResponseDTO"
                         }
                     },
                     "400": {
-                        "description": "Bad Request - Unable to read body or parse body or login and password are required",
+                        "description": "Bad Request - Invalid body, empty or too large batch",
                         "schema": {
                             "$ref": "#/definitions/handlers.ErrorResponse"
                         }
                     },
                     "401": {
-                        "description": "Unauthorized - Invalid login credentials",
+                        "description": "Unauthorized - The user is not authorized",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden - The user is not an admin",
                         "schema": {
                             "$ref": "#/definitions/handlers.ErrorResponse"
                         }
                     },
                     "500": {
-                        "description": "Internal Server Error - Unable to generate token",
+                        "description": "Internal Server Error",
                         "schema": {
                             "$ref": "#/definitions/handlers.ErrorResponse"
                         }
@@ -178,33 +324,47 @@ const docTemplate = `{
                 }
             }
         },
-        "/api/user/orders": {
-            "get": {
+        "/admin/orders/retry": {
+            "post": {
                 "security": [
                     {
                         "ApiKeyAuth": []
                     }
                 ],
-                "description": "The handler returns a list of order numbers sorted by loading time from oldest to newest for an authorized user.\nThe response includes the order number, status, accrual (if available), and the upload timestamp.",
+                "description": "The handler resets every INVALID or stuck PROCESSING order of the batch back to NEW, regardless of its owner,\nand sends it to processing again. Orders in other statuses are skipped, unknown numbers are reported as missing.\nA batch holds at most 100 order numbers.",
+                "consumes": [
+                    "application/json"
+                ],
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "orders"
+                    "admin"
+                ],
+                "summary": "Retrying a batch of orders",
+                "parameters": [
+                    {
+                        "description": "Order numbers to retry",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.RetryOrdersRequestDTO"
+                        }
+                    }
                 ],
-                "summary": "Getting a list of downloaded order numbers",
                 "responses": {
                     "200": {
-                        "description": "List of orders with details",
+                        "description": "Order numbers by outcome",
                         "schema": {
-                            "type": "array",
-                            "items": {
-                                "$ref": "#/definitions/handlers.OrderDTO"
-                            }
+                            "$ref": "#/definitions/handlers.RetryOrdersResponseDTO"
                         }
                     },
-                    "204": {
-                        "description": "No orders to display"
+                    "400": {
+                        "description": "Bad Request - Invalid body, empty or too large batch",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
                     },
                     "401": {
                         "description": "Unauthorized - The user is not authorized",
@@ -212,6 +372,12 @@ const docTemplate = `{
                             "$ref": "#/definitions/handlers.ErrorResponse"
                         }
                     },
+                    "403": {
+                        "description": "Forbidden - The user is not an admin",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
@@ -219,62 +385,77 @@ const docTemplate = `{
                         }
                     }
                 }
-            },
+            }
+        },
+        "/admin/orders/{number}/accrual": {
             "post": {
                 "security": [
                     {
                         "ApiKeyAuth": []
                     }
                 ],
-                "description": "The handler is only available to authenticated users and is used to upload a new order number.",
+                "description": "The handler replaces the accrual of a PROCESSED order, e.g. when the accrual system reported a wrong amount,\nand credits the owner's wallet with the difference, or takes it back when the accrual goes down.\nThe correction is recorded. Corrections that would leave the owner with a negative balance are refused.\nAn accrual of 0 clears the accrual of the order.",
                 "consumes": [
-                    "text/plain"
+                    "application/json"
                 ],
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "order"
+                    "admin"
                 ],
-                "summary": "Loading order number",
+                "summary": "Correcting the accrual of an order",
                 "parameters": [
                     {
-                        "description": "Order Number",
-                        "name": "order",
+                        "type": "string",
+                        "description": "Order number",
+                        "name": "number",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Corrected accrual",
+                        "name": "request",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/handlers.AccrualCorrectionRequestDTO"
                         }
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "The order number has already been uploaded by this user"
-                    },
-                    "202": {
-                        "description": "The new order number has been accepted for processing"
+                        "description": "The recorded correction",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.AccrualCorrectionDTO"
+                        }
                     },
                     "400": {
-                        "description": "Bad Request - Unable to read body or incorrect request format",
+                        "description": "Bad Request - Invalid body or accrual",
                         "schema": {
                             "$ref": "#/definitions/handlers.ErrorResponse"
                         }
                     },
                     "401": {
-                        "description": "Unauthorized - The user is not authenticated",
+                        "description": "Unauthorized - The user is not authorized",
                         "schema": {
                             "$ref": "#/definitions/handlers.ErrorResponse"
                         }
                     },
-                    "409": {
-                        "description": "Conflict - The order number has already been uploaded by another user",
+                    "403": {
+                        "description": "Forbidden - The user is not an admin",
                         "schema": {
                             "$ref": "#/definitions/handlers.ErrorResponse"
                         }
                     },
-                    "422": {
-                        "description": "Unprocessable Entity - Incorrect order number format",
+                    "404": {
+                        "description": "Not Found - The order does not exist",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict - The order is not PROCESSED or the balance would become negative",
                         "schema": {
                             "$ref": "#/definitions/handlers.ErrorResponse"
                         }
@@ -288,39 +469,36 @@ const docTemplate = `{
                 }
             }
         },
-        "/api/user/register": {
-            "post": {
-                "description": "Registration is carried out using a login/password pair. Each login must be unique.",
-                "consumes": [
-                    "application/json"
+        "/admin/queue": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
                 ],
+                "description": "The handler returns how many orders wait in the processing channel for an accrual lookup, the size of\nthe channel buffer, and how many orders wait in the cache to be polled again.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "user"
-                ],
-                "summary": "User registration",
-                "parameters": [
-                    {
-                        "description": "User Registration Information",
-                        "name": "user",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/handlers.UserRegisterDto"
-                        }
-                    }
+                    "admin"
                 ],
+                "summary": "Accrual processing queue depth",
                 "responses": {
                     "200": {
-                        "description": "Bearer \u003ctoken\u003e",
+                        "description": "Orders waiting for processing",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/handlers.QueueDepthDTO"
                         }
                     },
-                    "400": {
-                        "description": "Bad Request",
+                    "401": {
+                        "description": "Unauthorized - The user is not authorized",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden - The user is not an admin",
                         "schema": {
                             "$ref": "#/definitions/handlers.ErrorResponse"
                         }
@@ -334,40 +512,49 @@ const docTemplate = `{
                 }
             }
         },
-        "/api/user/withdrawals": {
+        "/admin/reconcile/{login}": {
             "get": {
                 "security": [
                     {
                         "ApiKeyAuth": []
                     }
                 ],
-                "description": "The handler returns information about the withdrawal of funds,",
+                "description": "The handler recomputes the user's balance from processed order accruals and withdrawals\nand compares it with the wallet credits and debits, reporting any discrepancy.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "withdrawals"
+                    "admin"
+                ],
+                "summary": "Balance consistency self-check",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "User login",
+                        "name": "login",
+                        "in": "path",
+                        "required": true
+                    }
                 ],
-                "summary": "Receiving information about the withdrawal of funds",
                 "responses": {
                     "200": {
-                        "description": "List of withdrawals with details",
+                        "description": "Expected and actual wallet totals",
                         "schema": {
-                            "type": "array",
-                            "items": {
-                                "$ref": "#/definitions/handlers.WithdrawalDTO"
-                            }
+                            "$ref": "#/definitions/handlers.ReconciliationDTO"
                         }
                     },
-                    "204": {
-                        "description": "No withdrawals to display"
-                    },
                     "401": {
                         "description": "Unauthorized - The user is not authorized",
                         "schema": {
                             "$ref": "#/definitions/handlers.ErrorResponse"
                         }
                     },
+                    "403": {
+                        "description": "Forbidden - The user is not an admin",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
@@ -376,17 +563,1424 @@ const docTemplate = `{
                     }
                 }
             }
-        }
-    },
-    "definitions": {
-        "handlers.BalanceDto": {
-            "type": "object",
-            "properties": {
-                "current": {
+        },
+        "/admin/withdrawals/reconcile": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "The handler finds the wallets debited by more than the sum of their unreversed withdrawals,\ni.e. points taken without a withdrawal record. They are only reported unless apply=true is passed,\nthen the excess is refunded to each of them. A refunded amount of 0 with apply=true means the refund\nfailed or the wallet no longer had an excess; the next call picks up what is left.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Refunding debits without a withdrawal",
+                "parameters": [
+                    {
+                        "type": "boolean",
+                        "description": "Refund the unmatched debits instead of only reporting them",
+                        "name": "apply",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Unmatched debits by login and the refunded amounts",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/handlers.UnmatchedDebitDTO"
+                            }
+                        }
+                    },
+                    "204": {
+                        "description": "Every debit has a matching withdrawal"
+                    },
+                    "400": {
+                        "description": "Bad Request - Invalid apply parameter",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized - The user is not authorized",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden - The user is not an admin",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/withdrawals/{login}/{order}/reverse": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "The handler refunds the user's withdrawal for the order back to their wallet and records the reversal.\nReversing an already reversed withdrawal succeeds without refunding it again.",
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Reversing a user's withdrawal",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "User login",
+                        "name": "login",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Order number of the withdrawal",
+                        "name": "order",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "The withdrawal is reversed"
+                    },
+                    "401": {
+                        "description": "Unauthorized - The user is not authorized",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden - The user is not an admin",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found - The user has no withdrawal for the order",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/dev/order-number": {
+            "get": {
+                "description": "Development helper returning a random order number that passes the Luhn check.\nOnly mounted when the server runs in dev mode.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "dev"
+                ],
+                "summary": "Generate a valid order number",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Digits the number should start with",
+                        "name": "prefix",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Number length, 16 by default",
+                        "name": "length",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Generated order number",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.OrderNumberDTO"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request - Invalid prefix or length",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/meta/statuses": {
+            "get": {
+                "description": "The handler returns every status an order can have, in the order an order goes through them,\nand every status a withdrawal can have, each with a description.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "meta"
+                ],
+                "summary": "Listing the order and withdrawal statuses",
+                "responses": {
+                    "200": {
+                        "description": "The order and withdrawal statuses",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.StatusesDTO"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/user/balance": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "The handler returns the current amount of loyalty points and the total amount of points\nwithdrawn during the entire registration period for an authorized user.\nWith include_pending=true it also returns the accruals of orders that are still PROCESSING,\nwhich are credited once the orders are PROCESSED, unless the pending-balance feature is off.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "balance"
+                ],
+                "summary": "Getting the user's current balance",
+                "parameters": [
+                    {
+                        "type": "boolean",
+                        "description": "Also return the pending accruals",
+                        "name": "include_pending",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Current and withdrawn loyalty points",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.BalanceDto"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request - Invalid include_pending parameter",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized - The user is not authorized",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/user/balance/withdraw": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "The handler allows an authorized user to debit points from their account for a hypothetical new order.\nThe sum must be positive and have at most two decimal places.\nSend an Idempotency-Key header to make retries safe: repeating the withdrawal with the same key,\norder and sum succeeds without debiting again, GET /api/user/withdrawals/by-key/{key} returns its outcome.\nThe response confirms the withdrawal and reports the balance left after it. Earlier versions answered\nwith an empty body, clients that only check the status code keep working.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "balance"
+                ],
+                "summary": "Request for debiting funds",
+                "parameters": [
+                    {
+                        "description": "Withdrawal Request",
+                        "name": "withdrawal",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.WithdrawRequestDTO"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Client chosen key of at most 128 characters that makes retries of the withdrawal safe",
+                        "name": "Idempotency-Key",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "The withdrawal and the current balance, a retry with the same Idempotency-Key returns the original withdrawal",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.WithdrawResponseDTO"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request - Unable to read body or parse body, invalid sum or Idempotency-Key",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized - The user is not authorized",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "402": {
+                        "description": "Payment Required - Insufficient funds in the account",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict - A withdrawal for the order already exists or the order was uploaded by another user",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "415": {
+                        "description": "Unsupported Media Type - The body is not JSON",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity - Incorrect order number format, or the Idempotency-Key was used for another withdrawal",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/user/devices/{device}/marker": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "The handler returns the upload time of the newest order the authorized user has seen on the device.\nGET /api/user/orders?new_only=true\u0026device=\u003cdevice\u003e lists the orders uploaded after it.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "orders"
+                ],
+                "summary": "Receiving the last seen marker of a device",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Client chosen device identifier, at most 128 characters",
+                        "name": "device",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "The marker of the device",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.DeviceMarkerDTO"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request - Invalid device identifier",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized - The user is not authorized",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found - The device has no marker yet",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "The handler stores the upload time of the newest order the authorized user has seen on the device,\nusually the uploaded_at of the first order in the last list the device fetched.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "orders"
+                ],
+                "summary": "Setting the last seen marker of a device",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Client chosen device identifier, at most 128 characters",
+                        "name": "device",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Upload time of the newest order seen",
+                        "name": "marker",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.DeviceMarkerRequestDTO"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "The stored marker",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.DeviceMarkerDTO"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request - Invalid device identifier, unable to parse body or last_seen_at is required",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized - The user is not authorized",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "415": {
+                        "description": "Unsupported Media Type - The body is not JSON",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/user/ledger": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "The handler returns accruals of processed orders and withdrawals of an authorized user\nmerged into a single list sorted from oldest to newest. The type field tells the entries apart.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "balance"
+                ],
+                "summary": "Receiving the user's accruals and withdrawals in one list",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Page size, clamped to the configured maximum",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Number of entries to skip",
+                        "name": "offset",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "List of accrual and withdrawal entries",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/handlers.LedgerEntryDTO"
+                            }
+                        }
+                    },
+                    "204": {
+                        "description": "No entries to display"
+                    },
+                    "400": {
+                        "description": "Bad Request - Invalid pagination parameters",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized - The user is not authorized",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/user/login": {
+            "post": {
+                "description": "Authenticates a user using a login/password pair and returns a bearer token if successful.\nWith AUTH_COOKIE_NAME set the token is also set in an HttpOnly cookie of that name.\nThe body is the plain \"Bearer \u003ctoken\u003e\" unless the request sends Accept: application/json,\nthen it is a JSON object with the token and its expiry. The Authorization header is set either way.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "text/plain",
+                    "application/json"
+                ],
+                "tags": [
+                    "users"
+                ],
+                "summary": "User login",
+                "parameters": [
+                    {
+                        "description": "User Login Credentials",
+                        "name": "user",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.UserLoginDto"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Tenant of the user, at most 64 characters, the default tenant when absent",
+                        "name": "X-Tenant-ID",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Bearer \u003ctoken\u003e as plain text, or the token and its expiry with Accept: application/json",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.TokenDto"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request - Invalid tenant identifier, unable to read body or parse body or login and password are required",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized - Invalid login credentials",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "415": {
+                        "description": "Unsupported Media Type - The body is not JSON",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests - The login is locked after repeated failures, see Retry-After",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error - Unable to generate token",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/user/orders": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "The handler returns a list of order numbers sorted by loading time from oldest to newest for an authorized user.\nThe response includes the order number, status, accrual (if available), and the upload timestamp.\nWith envelope=true the list is wrapped together with the page and the total number of orders,\nand an empty list is returned with 200 instead of 204.\nWith the cursor param the orders are paged by an opaque cursor instead of an offset, newest first,\nso orders uploaded meanwhile don't shift the pages. An empty cursor starts from the newest order.\nThe response is always wrapped in the envelope, whose next_cursor is set while more orders follow.\nWith new_only=true only the orders uploaded after the marker of the device are listed,\nall orders while the device has no marker. See PUT /api/user/devices/{device}/marker.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "orders"
+                ],
+                "summary": "Getting a list of downloaded order numbers",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Page size, clamped to the configured maximum, all orders are listed without it unless paging by cursor",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Number of orders to skip, not allowed together with cursor",
+                        "name": "offset",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Wrap the list in an object with pagination metadata",
+                        "name": "envelope",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "next_cursor of the previous page, empty for the first page",
+                        "name": "cursor",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "List only the orders uploaded after the marker of the device, not allowed together with cursor",
+                        "name": "new_only",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Device identifier, required with new_only",
+                        "name": "device",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "List of orders with details",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/handlers.OrderDTO"
+                            }
+                        }
+                    },
+                    "204": {
+                        "description": "No orders to display"
+                    },
+                    "400": {
+                        "description": "Bad Request - Invalid pagination, envelope, cursor or device parameters, or an unknown parameter",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized - The user is not authorized",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "The handler is only available to authenticated users and is used to upload a new order number.\nThe order number is a sequence of digits of arbitrary length and can be validated using the Luhn algorithm.\nSend Accept: application/json to receive the created order in the 202 body, without it the body stays empty.",
+                "consumes": [
+                    "text/plain",
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "order"
+                ],
+                "summary": "Loading order number",
+                "parameters": [
+                    {
+                        "description": "Order Number as plain text, or a JSON object with an order field",
+                        "name": "order",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "The order number has already been uploaded by this user"
+                    },
+                    "202": {
+                        "description": "The new order number has been accepted for processing, the body is only sent with Accept: application/json",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.OrderDTO"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request - Unable to read body, incorrect request format or empty order number",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized - The user is not authenticated",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict - The order number has already been uploaded by another user",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity - Incorrect order number format",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/user/orders/{number}/history": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "The handler returns the status transitions of the user's order sorted from oldest to newest.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "orders"
+                ],
+                "summary": "Getting the status history of an order",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Order Number",
+                        "name": "number",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Ordered status transitions",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/handlers.OrderStatusChangeDTO"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized - The user is not authorized",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found - The order does not exist or belongs to another user",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/user/orders/{number}/retry": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "The handler resets the user's INVALID or stuck PROCESSING order to NEW and sends it to the accrual\nsystem again. Retries of the same order are limited to one per cooldown period.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "orders"
+                ],
+                "summary": "Re-triggering accrual lookup for an order",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Order Number",
+                        "name": "number",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "202": {
+                        "description": "The order has been sent to processing again"
+                    },
+                    "401": {
+                        "description": "Unauthorized - The user is not authorized",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found - The order does not exist or belongs to another user",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict - The order is not in a retryable state",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests - The order was retried recently",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/user/profile": {
+            "patch": {
+                "description": "Changes the login of the authenticated user. The new login must be unique.\nA token for the new login is returned, the presented token of the old login is revoked.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "user"
+                ],
+                "summary": "Update user profile",
+                "parameters": [
+                    {
+                        "description": "New profile data",
+                        "name": "profile",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.UserProfileDto"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Bearer \u003ctoken\u003e",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request - Unable to read body or parse body or login is required",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict - Login already taken",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error - Unable to update login, generate or revoke token",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/user/register": {
+            "post": {
+                "description": "Registration is carried out using a login/password pair. Each login must be unique.\nAfter successful registration, automatic user authentication should occur.\nA registration retried with the same Idempotency-Key, login and password within 10 minutes\ngets a token of the registered user instead of a conflict.\nWith AUTH_COOKIE_NAME set the token is also set in an HttpOnly cookie of that name.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "user"
+                ],
+                "summary": "User registration",
+                "parameters": [
+                    {
+                        "description": "User Registration Information",
+                        "name": "user",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.UserRegisterDto"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Client chosen key of at most 128 characters that makes retries of the registration safe",
+                        "name": "Idempotency-Key",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Tenant to register in, at most 64 characters, the default tenant when absent",
+                        "name": "X-Tenant-ID",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Bearer \u003ctoken\u003e",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request - Invalid tenant identifier or Idempotency-Key, unable to parse body or login and password are required",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "415": {
+                        "description": "Unsupported Media Type - The body is not JSON",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/user/stats": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "The handler returns the number of orders of an authorized user, in total and by status,\nthe accruals of processed orders, the withdrawn points and the current balance in one response.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "balance"
+                ],
+                "summary": "Receiving aggregate statistics of the user",
+                "responses": {
+                    "200": {
+                        "description": "Aggregate statistics",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.UserStatsDTO"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized - The user is not authorized",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/user/token/renew": {
+            "post": {
+                "description": "Issues a fresh token for a still valid one, e.g. shortly before it expires, without asking for the password.\nWith revoke_old=true the presented token stops working. Expired tokens can't be renewed, log in again instead.",
+                "produces": [
+                    "text/plain"
+                ],
+                "tags": [
+                    "user"
+                ],
+                "summary": "Renew the auth token",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Bearer \u003ctoken\u003e",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Revoke the presented token",
+                        "name": "revoke_old",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Bearer \u003ctoken\u003e",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request - Invalid revoke_old parameter",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized - Missing, invalid, expired or revoked token",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error - Unable to generate or revoke token",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/user/wallet": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "The handler returns the raw credited and debited totals of the authorized user's wallet\nalong with the current and withdrawn balance derived from them.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "balance"
+                ],
+                "summary": "Getting the user's wallet totals",
+                "responses": {
+                    "200": {
+                        "description": "Credits, debits, current and withdrawn loyalty points",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.WalletDTO"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized - The user is not authorized",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/user/withdrawals": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "The handler returns information about the withdrawal of funds,\nsorted by the time of withdrawal from oldest to newest for an authorized user.\nPass sort=desc to get the newest withdrawals first. Reversed withdrawals are left out, as they are\nfrom the withdrawn balance.\nThe X-Total-Count header holds the number of withdrawals of the user across all pages.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "withdrawals"
+                ],
+                "summary": "Receiving information about the withdrawal of funds",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Page size, clamped to the configured maximum, all withdrawals are listed without it",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Number of withdrawals to skip",
+                        "name": "offset",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "asc",
+                            "desc"
+                        ],
+                        "type": "string",
+                        "description": "Order by withdrawal time, asc (default) or desc",
+                        "name": "sort",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "List of withdrawals with details",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/handlers.WithdrawalDTO"
+                            }
+                        },
+                        "headers": {
+                            "X-Total-Count": {
+                                "type": "int",
+                                "description": "Number of withdrawals across all pages"
+                            }
+                        }
+                    },
+                    "204": {
+                        "description": "No withdrawals to display"
+                    },
+                    "400": {
+                        "description": "Bad Request - Invalid pagination or sort parameters, or an unknown parameter",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized - The user is not authorized",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/user/withdrawals/by-key/{key}": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "The handler returns the withdrawal the authorized user made with the Idempotency-Key,\nso a client unsure whether its request went through can check instead of retrying.\nThe status is REVERSED once the withdrawal has been refunded.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "withdrawals"
+                ],
+                "summary": "Receiving the outcome of a withdrawal by its idempotency key",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Idempotency-Key the withdrawal was requested with",
+                        "name": "key",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "The withdrawal made with the key",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.WithdrawalOutcomeDTO"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request - Invalid key",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized - The user is not authorized",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found - No withdrawal was made with the key",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/healthz/processor": {
+            "get": {
+                "description": "The handler reports the order processor healthy while its loop keeps beating. It answers 503 once\nthe last heartbeat is older than PROCESSOR_STALE_SEC, e.g. when the processor died or is stuck,\nand before the processor has started.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "health"
+                ],
+                "summary": "Order processor liveness check",
+                "responses": {
+                    "200": {
+                        "description": "The processor is running",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ProcessorHealthDTO"
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable - The processor heartbeat is stale",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ProcessorHealthDTO"
+                        }
+                    }
+                }
+            }
+        },
+        "/readyz": {
+            "get": {
+                "description": "The handler reports the instance ready once the database schema is at the newest migration\nshipped with the binary. It answers 503 while the schema is behind, e.g. when the server runs\nwith SKIP_MIGRATIONS and they have not been applied yet, or the database can't be reached.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "health"
+                ],
+                "summary": "Readiness check",
+                "responses": {
+                    "200": {
+                        "description": "The schema is up to date",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ReadinessDTO"
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable - The schema is behind the migrations",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ReadinessDTO"
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "handlers.AccrualCorrectionDTO": {
+            "type": "object",
+            "properties": {
+                "accrual": {
+                    "type": "number"
+                },
+                "corrected_at": {
+                    "type": "string"
+                },
+                "delta": {
+                    "type": "number"
+                },
+                "order": {
+                    "type": "string"
+                },
+                "previous_accrual": {
+                    "type": "number"
+                }
+            }
+        },
+        "handlers.AccrualCorrectionRequestDTO": {
+            "type": "object",
+            "properties": {
+                "accrual": {
+                    "type": "number"
+                }
+            }
+        },
+        "handlers.AdminOrderDTO": {
+            "type": "object",
+            "properties": {
+                "accrual": {
+                    "type": "number"
+                },
+                "attempts": {
+                    "type": "integer"
+                },
+                "login": {
+                    "type": "string"
+                },
+                "number": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "uploaded_at": {
+                    "type": "string"
+                }
+            }
+        },
+        "handlers.BalanceDto": {
+            "type": "object",
+            "properties": {
+                "current": {
+                    "type": "number"
+                },
+                "pending": {
+                    "description": "Pending is only reported with include_pending=true",
+                    "type": "number"
+                },
+                "withdrawn": {
                     "type": "number"
+                }
+            }
+        },
+        "handlers.DeadLetterOrderDTO": {
+            "type": "object",
+            "properties": {
+                "attempts": {
+                    "type": "integer"
+                },
+                "failed_at": {
+                    "type": "string"
+                },
+                "failure_reason": {
+                    "type": "string"
+                },
+                "login": {
+                    "type": "string"
                 },
-                "withdrawn": {
-                    "type": "number"
+                "lookup_failures": {
+                    "type": "integer"
+                },
+                "number": {
+                    "type": "string"
+                },
+                "uploaded_at": {
+                    "type": "string"
+                }
+            }
+        },
+        "handlers.DeviceMarkerDTO": {
+            "type": "object",
+            "properties": {
+                "device": {
+                    "type": "string"
+                },
+                "last_seen_at": {
+                    "type": "string"
+                },
+                "updated_at": {
+                    "type": "string"
+                }
+            }
+        },
+        "handlers.DeviceMarkerRequestDTO": {
+            "type": "object",
+            "properties": {
+                "last_seen_at": {
+                    "type": "string"
                 }
             }
         },
@@ -401,6 +1995,31 @@ const docTemplate = `{
                 }
             }
         },
+        "handlers.LedgerEntryDTO": {
+            "type": "object",
+            "properties": {
+                "order": {
+                    "type": "string"
+                },
+                "processed_at": {
+                    "type": "string"
+                },
+                "sum": {
+                    "type": "number"
+                },
+                "type": {
+                    "type": "string"
+                }
+            }
+        },
+        "handlers.MigrationStatusDTO": {
+            "type": "object",
+            "properties": {
+                "version": {
+                    "type": "integer"
+                }
+            }
+        },
         "handlers.OrderDTO": {
             "type": "object",
             "properties": {
@@ -418,6 +2037,210 @@ const docTemplate = `{
                 }
             }
         },
+        "handlers.OrderNumberDTO": {
+            "type": "object",
+            "properties": {
+                "number": {
+                    "type": "string"
+                }
+            }
+        },
+        "handlers.OrderStatusChangeDTO": {
+            "type": "object",
+            "properties": {
+                "accrual": {
+                    "type": "number"
+                },
+                "changed_at": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string"
+                }
+            }
+        },
+        "handlers.ProcessorHealthDTO": {
+            "type": "object",
+            "properties": {
+                "healthy": {
+                    "type": "boolean"
+                },
+                "last_heartbeat": {
+                    "type": "string"
+                },
+                "stale_after_sec": {
+                    "type": "integer"
+                }
+            }
+        },
+        "handlers.ProcessorMetricsDTO": {
+            "type": "object",
+            "properties": {
+                "cache_size": {
+                    "type": "integer"
+                },
+                "orders_exhausted": {
+                    "type": "integer"
+                },
+                "orders_recached": {
+                    "type": "integer"
+                }
+            }
+        },
+        "handlers.QueueDepthDTO": {
+            "type": "object",
+            "properties": {
+                "cached": {
+                    "type": "integer"
+                },
+                "capacity": {
+                    "type": "integer"
+                },
+                "queued": {
+                    "type": "integer"
+                }
+            }
+        },
+        "handlers.ReadinessDTO": {
+            "type": "object",
+            "properties": {
+                "latest": {
+                    "type": "integer"
+                },
+                "ready": {
+                    "type": "boolean"
+                },
+                "version": {
+                    "type": "integer"
+                }
+            }
+        },
+        "handlers.ReconciliationDTO": {
+            "type": "object",
+            "properties": {
+                "actual_balance": {
+                    "type": "number"
+                },
+                "actual_credits": {
+                    "type": "number"
+                },
+                "actual_debits": {
+                    "type": "number"
+                },
+                "consistent": {
+                    "type": "boolean"
+                },
+                "discrepancy": {
+                    "type": "number"
+                },
+                "expected_balance": {
+                    "type": "number"
+                },
+                "expected_credits": {
+                    "type": "number"
+                },
+                "expected_debits": {
+                    "type": "number"
+                },
+                "login": {
+                    "type": "string"
+                }
+            }
+        },
+        "handlers.RetryOrdersRequestDTO": {
+            "type": "object",
+            "properties": {
+                "orders": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "handlers.RetryOrdersResponseDTO": {
+            "type": "object",
+            "properties": {
+                "missing": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "retried": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "skipped": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "handlers.StatusDTO": {
+            "type": "object",
+            "properties": {
+                "description": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string"
+                }
+            }
+        },
+        "handlers.StatusesDTO": {
+            "type": "object",
+            "properties": {
+                "orders": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/handlers.StatusDTO"
+                    }
+                },
+                "withdrawals": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/handlers.StatusDTO"
+                    }
+                }
+            }
+        },
+        "handlers.TokenDto": {
+            "type": "object",
+            "properties": {
+                "expires_at": {
+                    "description": "ExpiresAt is left out when the token lifetime isn't configured",
+                    "type": "string"
+                },
+                "token": {
+                    "type": "string"
+                }
+            }
+        },
+        "handlers.UnmatchedDebitDTO": {
+            "type": "object",
+            "properties": {
+                "debits": {
+                    "type": "number"
+                },
+                "login": {
+                    "type": "string"
+                },
+                "refunded": {
+                    "type": "number"
+                },
+                "unmatched": {
+                    "type": "number"
+                },
+                "withdrawn": {
+                    "type": "number"
+                }
+            }
+        },
         "handlers.UserLoginDto": {
             "type": "object",
             "properties": {
@@ -429,6 +2252,14 @@ const docTemplate = `{
                 }
             }
         },
+        "handlers.UserProfileDto": {
+            "type": "object",
+            "properties": {
+                "login": {
+                    "type": "string"
+                }
+            }
+        },
         "handlers.UserRegisterDto": {
             "type": "object",
             "properties": {
@@ -440,6 +2271,46 @@ const docTemplate = `{
                 }
             }
         },
+        "handlers.UserStatsDTO": {
+            "type": "object",
+            "properties": {
+                "accrued": {
+                    "type": "number"
+                },
+                "balance": {
+                    "type": "number"
+                },
+                "orders_by_status": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "integer"
+                    }
+                },
+                "total_orders": {
+                    "type": "integer"
+                },
+                "withdrawn": {
+                    "type": "number"
+                }
+            }
+        },
+        "handlers.WalletDTO": {
+            "type": "object",
+            "properties": {
+                "credits": {
+                    "type": "number"
+                },
+                "current": {
+                    "type": "number"
+                },
+                "debits": {
+                    "type": "number"
+                },
+                "withdrawn": {
+                    "type": "number"
+                }
+            }
+        },
         "handlers.WithdrawRequestDTO": {
             "type": "object",
             "properties": {
@@ -451,6 +2322,26 @@ const docTemplate = `{
                 }
             }
         },
+        "handlers.WithdrawResponseDTO": {
+            "type": "object",
+            "properties": {
+                "current": {
+                    "type": "number"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "order": {
+                    "type": "string"
+                },
+                "processed_at": {
+                    "type": "string"
+                },
+                "sum": {
+                    "type": "number"
+                }
+            }
+        },
         "handlers.WithdrawalDTO": {
             "type": "object",
             "properties": {
@@ -464,6 +2355,33 @@ const docTemplate = `{
                     "type": "number"
                 }
             }
+        },
+        "handlers.WithdrawalOutcomeDTO": {
+            "type": "object",
+            "properties": {
+                "key": {
+                    "type": "string"
+                },
+                "order": {
+                    "type": "string"
+                },
+                "processed_at": {
+                    "type": "string"
+                },
+                "reversed_at": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string",
+                    "enum": [
+                        "WITHDRAWN",
+                        "REVERSED"
+                    ]
+                },
+                "sum": {
+                    "type": "number"
+                }
+            }
         }
     },
     "securityDefinitions": {
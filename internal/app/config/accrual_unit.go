@@ -0,0 +1,14 @@
+package config
+
+// AccrualUnit is the unit the accrual service reports accruals in. Some versions emit integer
+// cents instead of points, the client converts those so points are stored either way.
+type AccrualUnit string
+
+const (
+	AccrualUnitPoints AccrualUnit = "points"
+	AccrualUnitCents  AccrualUnit = "cents"
+)
+
+func (u AccrualUnit) IsValid() bool {
+	return u == AccrualUnitPoints || u == AccrualUnitCents
+}
@@ -0,0 +1,253 @@
+package config
+
+import (
+	"flag"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestParse_EndpointTimeouts(t *testing.T) {
+	tests := []struct {
+		name        string
+		args        []string
+		env         map[string]string
+		wantOrders  int
+		wantBalance int
+		wantAdmin   int
+	}{
+		{
+			name:        "Global Timeout By Default",
+			wantOrders:  20,
+			wantBalance: 20,
+			wantAdmin:   20,
+		},
+		{
+			name:        "Overrides From Flags",
+			args:        []string{"-orders-timeout", "45", "-admin-timeout", "90"},
+			wantOrders:  45,
+			wantBalance: 20,
+			wantAdmin:   90,
+		},
+		{
+			name:        "Environment Wins Over Flags",
+			args:        []string{"-balance-timeout", "5"},
+			env:         map[string]string{"BALANCE_TIMEOUT_SEC": "3"},
+			wantOrders:  20,
+			wantBalance: 3,
+			wantAdmin:   20,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+			c := parse(flag.NewFlagSet("test", flag.ContinueOnError), tt.args)
+
+			assert.Equal(t, tt.wantOrders, c.TimeoutSec(c.OrdersTimeoutSec))
+			assert.Equal(t, tt.wantBalance, c.TimeoutSec(c.BalanceTimeoutSec))
+			assert.Equal(t, tt.wantAdmin, c.TimeoutSec(c.AdminTimeoutSec))
+		})
+	}
+}
+
+func TestParse_Migrations(t *testing.T) {
+	tests := []struct {
+		name               string
+		args               []string
+		env                map[string]string
+		wantMigrateOnly    bool
+		wantSkipMigrations bool
+	}{
+		{
+			name: "Migrate On Start By Default",
+		},
+		{
+			name:            "Migrate Only From Flag",
+			args:            []string{"-migrate-only"},
+			wantMigrateOnly: true,
+		},
+		{
+			name:               "Skip Migrations From Environment",
+			env:                map[string]string{"SKIP_MIGRATIONS": "true"},
+			wantSkipMigrations: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+			c := parse(flag.NewFlagSet("test", flag.ContinueOnError), tt.args)
+
+			assert.Equal(t, tt.wantMigrateOnly, c.MigrateOnly)
+			assert.Equal(t, tt.wantSkipMigrations, c.SkipMigrations)
+		})
+	}
+}
+
+func TestParse_AccrualStatusMap(t *testing.T) {
+	c := parse(flag.NewFlagSet("test", flag.ContinueOnError), nil)
+	assert.Empty(t, c.AccrualStatusMap)
+
+	c = parse(flag.NewFlagSet("test", flag.ContinueOnError), []string{"-accrual-status-map", "DONE=PROCESSED, QUEUED = NEW"})
+	assert.Equal(t, map[string]string{"DONE": "PROCESSED", "QUEUED": "NEW"}, c.AccrualStatusMap)
+
+	t.Setenv("ACCRUAL_STATUS_MAP", "FAILED=INVALID,BROKEN")
+	c = parse(flag.NewFlagSet("test", flag.ContinueOnError), nil)
+	assert.Equal(t, map[string]string{"FAILED": "INVALID", "BROKEN": ""}, c.AccrualStatusMap)
+}
+
+func TestParse_TrustedProxies(t *testing.T) {
+	c := parse(flag.NewFlagSet("test", flag.ContinueOnError), nil)
+	assert.Empty(t, c.TrustedProxies)
+
+	c = parse(flag.NewFlagSet("test", flag.ContinueOnError), []string{"-trusted-proxies", "10.0.0.0/8, 192.168.1.1"})
+	assert.Equal(t, []string{"10.0.0.0/8", "192.168.1.1"}, c.TrustedProxies)
+
+	t.Setenv("TRUSTED_PROXIES", "172.16.0.0/12")
+	c = parse(flag.NewFlagSet("test", flag.ContinueOnError), []string{"-trusted-proxies", "10.0.0.0/8"})
+	assert.Equal(t, []string{"172.16.0.0/12"}, c.TrustedProxies)
+}
+
+func TestParse_ClientRateLimit(t *testing.T) {
+	c := parse(flag.NewFlagSet("test", flag.ContinueOnError), nil)
+	assert.Equal(t, 0, c.ClientRateLimitPerSec)
+
+	c = parse(flag.NewFlagSet("test", flag.ContinueOnError), []string{"-client-rate-limit", "20"})
+	assert.Equal(t, 20, c.ClientRateLimitPerSec)
+
+	t.Setenv("CLIENT_RATE_LIMIT_PER_SEC", "5")
+	c = parse(flag.NewFlagSet("test", flag.ContinueOnError), []string{"-client-rate-limit", "20"})
+	assert.Equal(t, 5, c.ClientRateLimitPerSec)
+}
+
+func TestParse_Environment(t *testing.T) {
+	tests := []struct {
+		name        string
+		args        []string
+		env         map[string]string
+		wantEnv     Environment
+		wantErr     error
+		wantInvalid bool
+		wantDev     bool
+	}{
+		{
+			name:    "Prod By Default",
+			args:    []string{"-dev"},
+			wantEnv: EnvProd,
+			wantErr: ErrInsecureTokenSecret,
+		},
+		{
+			name:    "Dev On Request",
+			args:    []string{"-env", "dev", "-dev"},
+			wantEnv: EnvDev,
+			wantDev: true,
+		},
+		{
+			name:    "Prod Requires A Token Secret",
+			args:    []string{"-env", "prod"},
+			wantEnv: EnvProd,
+			wantErr: ErrInsecureTokenSecret,
+		},
+		{
+			name:    "Prod With A Token Secret",
+			args:    []string{"-env", "prod", "-dev"},
+			env:     map[string]string{"TOKEN_SECRET_KEY": "a-real-secret"},
+			wantEnv: EnvProd,
+		},
+		{
+			name:    "Environment Wins Over Flags",
+			args:    []string{"-env", "prod", "-token-secret", "a-real-secret"},
+			env:     map[string]string{"APP_ENV": "Staging"},
+			wantEnv: EnvStaging,
+		},
+		{
+			name:        "Unknown Environment",
+			args:        []string{"-env", "production"},
+			wantEnv:     Environment("production"),
+			wantInvalid: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+			c := parse(flag.NewFlagSet("test", flag.ContinueOnError), tt.args)
+
+			assert.Equal(t, tt.wantEnv, c.Environment)
+			assert.Equal(t, tt.wantDev, c.FeatureEnabled(FeatureDevEndpoints))
+			err := c.Validate()
+			switch {
+			case tt.wantErr != nil:
+				assert.ErrorIs(t, err, tt.wantErr)
+			case tt.wantInvalid:
+				assert.ErrorContains(t, err, "unknown environment")
+			default:
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestParse_AccrualUnit(t *testing.T) {
+	c := parse(flag.NewFlagSet("test", flag.ContinueOnError), nil)
+	assert.Equal(t, AccrualUnitPoints, c.AccrualUnit)
+
+	c = parse(flag.NewFlagSet("test", flag.ContinueOnError), []string{"-accrual-unit", "cents"})
+	assert.Equal(t, AccrualUnitCents, c.AccrualUnit)
+
+	t.Setenv("ACCRUAL_UNIT", "Points")
+	c = parse(flag.NewFlagSet("test", flag.ContinueOnError), []string{"-accrual-unit", "cents"})
+	assert.Equal(t, AccrualUnitPoints, c.AccrualUnit)
+
+	c = parse(flag.NewFlagSet("test", flag.ContinueOnError), []string{"-env", "dev"})
+	c.AccrualUnit = "kopecks"
+	assert.ErrorContains(t, c.Validate(), "unknown accrual unit")
+}
+
+func TestAppConfig_Validate_OrderCleanupInterval(t *testing.T) {
+	c := parse(flag.NewFlagSet("test", flag.ContinueOnError), []string{"-env", "dev"})
+	c.OrderCleanupIntervalSec = 0
+	assert.NoError(t, c.Validate(), "the interval doesn't matter without retention")
+
+	c.OrderRetentionDays = 30
+	assert.ErrorContains(t, c.Validate(), "order cleanup interval")
+
+	c.OrderCleanupIntervalSec = 60
+	assert.NoError(t, c.Validate())
+}
+
+func TestAppConfig_CommitConcurrency(t *testing.T) {
+	c := parse(flag.NewFlagSet("test", flag.ContinueOnError), nil)
+	assert.Equal(t, 9, c.CommitConcurrency(), "one connection of the default pool of 10 stays free")
+
+	t.Setenv("DB_MAX_OPEN_CONNS", "1")
+	c = parse(flag.NewFlagSet("test", flag.ContinueOnError), nil)
+	assert.Equal(t, 1, c.CommitConcurrency())
+}
+
+func TestParse_Features(t *testing.T) {
+	c := parse(flag.NewFlagSet("test", flag.ContinueOnError), nil)
+	assert.True(t, c.FeatureEnabled(FeatureCookieAuth))
+	assert.True(t, c.FeatureEnabled(FeaturePendingBalance))
+	assert.False(t, c.FeatureEnabled(FeatureDevEndpoints))
+
+	c = parse(flag.NewFlagSet("test", flag.ContinueOnError), []string{"-env", "dev", "-features", "dev-endpoints, cookie-auth"})
+	assert.True(t, c.FeatureEnabled(FeatureDevEndpoints))
+	assert.True(t, c.FeatureEnabled(FeatureCookieAuth))
+	assert.False(t, c.FeatureEnabled(FeaturePendingBalance), "listed features replace the defaults")
+
+	t.Setenv("FEATURES", "")
+	c = parse(flag.NewFlagSet("test", flag.ContinueOnError), []string{"-features", "pending-balance"})
+	assert.Empty(t, c.Features, "an empty FEATURES disables every feature")
+
+	t.Setenv("APP_ENV", "prod")
+	t.Setenv("FEATURES", FeatureDevEndpoints)
+	c = parse(flag.NewFlagSet("test", flag.ContinueOnError), nil)
+	assert.False(t, c.FeatureEnabled(FeatureDevEndpoints), "dev endpoints stay off outside dev")
+}
@@ -0,0 +1,52 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Environment is the kind of deployment the server runs in. Behaviour that is only safe on a
+// developer machine, like the dev endpoints or the default token secret, is tied to EnvDev.
+type Environment string
+
+const (
+	EnvDev     Environment = "dev"
+	EnvStaging Environment = "staging"
+	EnvProd    Environment = "prod"
+)
+
+// insecureTokenSecret is the default token secret, published in this repository.
+const insecureTokenSecret = "super-duper-secret"
+
+// ErrInsecureTokenSecret is returned by Validate outside dev when tokens would be signed with a known secret.
+var ErrInsecureTokenSecret = errors.New("token secret must be set outside the dev environment")
+
+func (e Environment) IsValid() bool {
+	return e == EnvDev || e == EnvStaging || e == EnvProd
+}
+
+func (e Environment) IsDev() bool {
+	return e == EnvDev
+}
+
+// Validate checks the settings that can't be judged one flag at a time.
+func (c AppConfig) Validate() error {
+	if !c.Environment.IsValid() {
+		return fmt.Errorf("unknown environment %q, expected %s, %s or %s", c.Environment, EnvDev, EnvStaging, EnvProd)
+	}
+	if !c.Environment.IsDev() && c.InsecureTokenSecret() {
+		return ErrInsecureTokenSecret
+	}
+	if c.OrderRetentionDays > 0 && c.OrderCleanupIntervalSec <= 0 {
+		return fmt.Errorf("order cleanup interval must be positive, got %d", c.OrderCleanupIntervalSec)
+	}
+	if !c.AccrualUnit.IsValid() {
+		return fmt.Errorf("unknown accrual unit %q, expected %s or %s", c.AccrualUnit, AccrualUnitPoints, AccrualUnitCents)
+	}
+	return nil
+}
+
+// InsecureTokenSecret reports whether tokens are signed with the default or an empty secret.
+func (c AppConfig) InsecureTokenSecret() bool {
+	return c.TokenSecretKey == "" || c.TokenSecretKey == insecureTokenSecret
+}
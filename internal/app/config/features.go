@@ -0,0 +1,31 @@
+package config
+
+// Names of the optional features listed in FEATURES.
+const (
+	// FeatureCookieAuth sets and accepts auth tokens in the AUTH_COOKIE_NAME cookie
+	FeatureCookieAuth = "cookie-auth"
+	// FeatureDevEndpoints mounts the development endpoints, in the dev environment only
+	FeatureDevEndpoints = "dev-endpoints"
+	// FeaturePendingBalance reports pending accruals on the balance endpoint with include_pending=true
+	FeaturePendingBalance = "pending-balance"
+)
+
+// defaultFeatures keep the features that used to be always on enabled when FEATURES is not set.
+const defaultFeatures = FeatureCookieAuth + "," + FeaturePendingBalance
+
+// FeatureEnabled reports whether the optional feature is switched on. The dev endpoints stay off
+// outside the dev environment whatever FEATURES says.
+func (c AppConfig) FeatureEnabled(name string) bool {
+	if name == FeatureDevEndpoints && !c.Environment.IsDev() {
+		return false
+	}
+	return c.Features[name]
+}
+
+func parseFeatures(value string) map[string]bool {
+	features := make(map[string]bool)
+	for _, name := range splitList(value) {
+		features[name] = true
+	}
+	return features
+}
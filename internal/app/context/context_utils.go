@@ -10,8 +10,14 @@ import (
 type key string
 
 const userUIDKey key = "userUID"
+const userLoginKey key = "userLogin"
+const tenantIDKey key = "tenantID"
+const allTenantsKey key = "allTenants"
 const errorKey key = "error"
 
+// StatusClientClosedRequest is the nginx status for a request the client gave up on before the response.
+const StatusClientClosedRequest = 499
+
 func WithUserUID(ctx context.Context, userUID *uuid.UUID) context.Context {
 	return context.WithValue(ctx, userUIDKey, userUID)
 }
@@ -25,6 +31,42 @@ func UserUID(ctx context.Context) *uuid.UUID {
 	return userUID
 }
 
+// WithUserLogin stores the login of the authenticated user, as found in the token.
+func WithUserLogin(ctx context.Context, login string) context.Context {
+	return context.WithValue(ctx, userLoginKey, login)
+}
+
+// UserLogin returns the login stored by WithUserLogin, or an empty string.
+func UserLogin(ctx context.Context) string {
+	login, _ := ctx.Value(userLoginKey).(string)
+	return login
+}
+
+// WithTenantID stores the tenant the request acts in, "" for the default tenant.
+func WithTenantID(ctx context.Context, tenantID string) context.Context {
+	return context.WithValue(ctx, tenantIDKey, tenantID)
+}
+
+// TenantID returns the tenant stored by WithTenantID. It reports false for contexts without one.
+func TenantID(ctx context.Context) (string, bool) {
+	tenantID, ok := ctx.Value(tenantIDKey).(string)
+	return tenantID, ok
+}
+
+// WithAllTenants marks ctx as working across tenants, like background jobs do. Tenant scoped queries
+// refuse contexts with neither a tenant nor this mark, so a forgotten tenant can't leak other tenants' data.
+func WithAllTenants(ctx context.Context) context.Context {
+	return context.WithValue(ctx, allTenantsKey, true)
+}
+
+// AllTenants reports whether ctx was marked by WithAllTenants.
+func AllTenants(ctx context.Context) bool {
+	all, _ := ctx.Value(allTenantsKey).(bool)
+	return all
+}
+
+// GetContextError returns the coded error for a finished ctx, nil while it is still running.
+// A cancellation means the client went away and answers 499, a timeout stays a server error.
 func GetContextError(ctx context.Context) error {
 	if err := ctx.Err(); err != nil {
 		var errMsg string
@@ -32,7 +74,7 @@ func GetContextError(ctx context.Context) error {
 
 		switch err {
 		case context.Canceled:
-			errMsg, errCode = "Request canceled", http.StatusInternalServerError
+			errMsg, errCode = "Client closed request", StatusClientClosedRequest
 		case context.DeadlineExceeded:
 			errMsg, errCode = "Timeout exceeded", http.StatusInternalServerError
 		default:
@@ -0,0 +1,64 @@
+package context
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appErrors "github.com/ujwegh/gophermart/internal/app/errors"
+	"net/http"
+	"testing"
+)
+
+func TestUserValues(t *testing.T) {
+	ctx := context.Background()
+	assert.Nil(t, UserUID(ctx))
+	assert.Empty(t, UserLogin(ctx))
+
+	userUID := uuid.New()
+	ctx = WithUserLogin(WithUserUID(ctx, &userUID), "alice")
+	assert.Equal(t, &userUID, UserUID(ctx))
+	assert.Equal(t, "alice", UserLogin(ctx))
+
+	// an unrelated value under the same key text is not picked up
+	ctx = context.WithValue(context.Background(), "userLogin", "mallory")
+	assert.Empty(t, UserLogin(ctx))
+}
+
+func TestGetContextError(t *testing.T) {
+	assert.NoError(t, GetContextError(context.Background()))
+
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	appErr := &appErrors.ResponseCodeError{}
+	require.ErrorAs(t, GetContextError(canceled), appErr)
+	assert.Equal(t, StatusClientClosedRequest, appErr.Code())
+	assert.Equal(t, "Client closed request", appErr.Msg())
+
+	expired, cancel := context.WithTimeout(context.Background(), 0)
+	defer cancel()
+	require.ErrorAs(t, GetContextError(expired), appErr)
+	assert.Equal(t, http.StatusInternalServerError, appErr.Code())
+	assert.Equal(t, "Timeout exceeded", appErr.Msg())
+}
+
+func TestTenantID(t *testing.T) {
+	_, ok := TenantID(context.Background())
+	assert.False(t, ok, "a context without a tenant")
+
+	tenantID, ok := TenantID(WithTenantID(context.Background(), ""))
+	assert.True(t, ok, "the default tenant is a tenant too")
+	assert.Empty(t, tenantID)
+
+	tenantID, ok = TenantID(WithTenantID(context.Background(), "acme"))
+	assert.True(t, ok)
+	assert.Equal(t, "acme", tenantID)
+}
+
+func TestAllTenants(t *testing.T) {
+	assert.False(t, AllTenants(context.Background()), "working across tenants has to be asked for")
+	assert.True(t, AllTenants(WithAllTenants(context.Background())))
+
+	_, ok := TenantID(WithAllTenants(context.Background()))
+	assert.False(t, ok)
+}
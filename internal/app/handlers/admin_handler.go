@@ -0,0 +1,613 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/go-chi/chi/v5"
+	appContext "github.com/ujwegh/gophermart/internal/app/context"
+	appErrors "github.com/ujwegh/gophermart/internal/app/errors"
+	"github.com/ujwegh/gophermart/internal/app/service"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type (
+	AdminHandler struct {
+		reconcileService  service.ReconcileService
+		orderService      service.OrderService
+		userService       service.UserService
+		withdrawalService service.WithdrawalService
+		contextTimeout    time.Duration
+		pagination        Pagination
+	}
+
+	//easyjson:json
+	ReconciliationDTO struct {
+		Login           string  `json:"login"`
+		ExpectedCredits float64 `json:"expected_credits"`
+		ActualCredits   float64 `json:"actual_credits"`
+		ExpectedDebits  float64 `json:"expected_debits"`
+		ActualDebits    float64 `json:"actual_debits"`
+		ExpectedBalance float64 `json:"expected_balance"`
+		ActualBalance   float64 `json:"actual_balance"`
+		Discrepancy     float64 `json:"discrepancy"`
+		Consistent      bool    `json:"consistent"`
+	}
+	//easyjson:json
+	UnmatchedDebitDTO struct {
+		Login     string  `json:"login"`
+		Debits    float64 `json:"debits"`
+		Withdrawn float64 `json:"withdrawn"`
+		Unmatched float64 `json:"unmatched"`
+		Refunded  float64 `json:"refunded"`
+	}
+	//easyjson:json
+	UnmatchedDebitDTOSlice []UnmatchedDebitDTO
+	//easyjson:json
+	AdminOrderDTO struct {
+		OrderID    string    `json:"number"`
+		Login      string    `json:"login"`
+		Status     string    `json:"status"`
+		Accrual    *float64  `json:"accrual,omitempty"`
+		Attempts   int       `json:"attempts"`
+		UploadedAt time.Time `json:"uploaded_at"`
+	}
+	//easyjson:json
+	AdminOrderDTOSlice []AdminOrderDTO
+	//easyjson:json
+	DeadLetterOrderDTO struct {
+		OrderID        string    `json:"number"`
+		Login          string    `json:"login"`
+		Attempts       int       `json:"attempts"`
+		LookupFailures int       `json:"lookup_failures"`
+		FailureReason  string    `json:"failure_reason,omitempty"`
+		FailedAt       time.Time `json:"failed_at"`
+		UploadedAt     time.Time `json:"uploaded_at"`
+	}
+	//easyjson:json
+	DeadLetterOrderDTOSlice []DeadLetterOrderDTO
+	//easyjson:json
+	RetryOrdersRequestDTO struct {
+		Orders []string `json:"orders"`
+	}
+	//easyjson:json
+	RetryOrdersResponseDTO struct {
+		Retried []string `json:"retried"`
+		Skipped []string `json:"skipped"`
+		Missing []string `json:"missing"`
+	}
+	//easyjson:json
+	AccrualCorrectionRequestDTO struct {
+		Accrual json.Number `json:"accrual" swaggertype:"number"`
+	}
+	//easyjson:json
+	AccrualCorrectionDTO struct {
+		OrderID         string    `json:"order"`
+		PreviousAccrual float64   `json:"previous_accrual"`
+		Accrual         float64   `json:"accrual"`
+		Delta           float64   `json:"delta"`
+		CorrectedAt     time.Time `json:"corrected_at"`
+	}
+)
+
+const (
+	errMsgInvalidTimeRange = "Invalid time range"
+	// maxRetryBatchSize bounds the IN list of a single batch retry query
+	maxRetryBatchSize = 100
+)
+
+func NewAdminHandler(contextTimeoutSec int, pagination Pagination,
+	reconcileService service.ReconcileService,
+	orderService service.OrderService,
+	userService service.UserService,
+	withdrawalService service.WithdrawalService) *AdminHandler {
+	return &AdminHandler{
+		reconcileService:  reconcileService,
+		orderService:      orderService,
+		userService:       userService,
+		withdrawalService: withdrawalService,
+		contextTimeout:    time.Duration(contextTimeoutSec) * time.Second,
+		pagination:        pagination,
+	}
+}
+
+// Reconcile godoc
+// @Summary Balance consistency self-check
+// @Description The handler recomputes the user's balance from processed order accruals and withdrawals
+// @Description and compares it with the wallet credits and debits, reporting any discrepancy.
+// @Tags admin
+// @Produce json
+// @Param login path string true "User login"
+// @Success 200 {object} ReconciliationDTO "Expected and actual wallet totals"
+// @Failure 401 {object} ErrorResponse "Unauthorized - The user is not authorized"
+// @Failure 403 {object} ErrorResponse "Forbidden - The user is not an admin"
+// @Failure 500 {object} ErrorResponse "Internal Server Error"
+// @Security ApiKeyAuth
+// @Router /admin/reconcile/{login} [get]
+func (ah *AdminHandler) Reconcile(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), ah.contextTimeout)
+	defer cancel()
+
+	login := chi.URLParam(r, "login")
+	reconciliation, err := ah.reconcileService.Reconcile(ctx, login)
+	if err != nil {
+		PrepareError(w, r, err)
+		return
+	}
+	response := ReconciliationDTO{
+		Login:           reconciliation.Login,
+		ExpectedCredits: reconciliation.ExpectedCredits,
+		ActualCredits:   reconciliation.ActualCredits,
+		ExpectedDebits:  reconciliation.ExpectedDebits,
+		ActualDebits:    reconciliation.ActualDebits,
+		ExpectedBalance: reconciliation.ExpectedBalance,
+		ActualBalance:   reconciliation.ActualBalance,
+		Discrepancy:     reconciliation.Discrepancy,
+		Consistent:      reconciliation.Consistent,
+	}
+	rawBytes, err := response.MarshalJSON()
+	if err != nil {
+		PrepareError(w, r, fmt.Errorf("marshal response: %w", err))
+		return
+	}
+
+	err = appContext.GetContextError(ctx)
+	if err != nil {
+		PrepareError(w, r, err)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(rawBytes)
+}
+
+// ReconcileDebits godoc
+// @Summary Refunding debits without a withdrawal
+// @Description The handler finds the wallets debited by more than the sum of their unreversed withdrawals,
+// @Description i.e. points taken without a withdrawal record. They are only reported unless apply=true is passed,
+// @Description then the excess is refunded to each of them. A refunded amount of 0 with apply=true means the refund
+// @Description failed or the wallet no longer had an excess; the next call picks up what is left.
+// @Tags admin
+// @Produce json
+// @Param apply query bool false "Refund the unmatched debits instead of only reporting them"
+// @Success 200 {array} UnmatchedDebitDTO "Unmatched debits by login and the refunded amounts"
+// @Success 204 "Every debit has a matching withdrawal"
+// @Failure 400 {object} ErrorResponse "Bad Request - Invalid apply parameter"
+// @Failure 401 {object} ErrorResponse "Unauthorized - The user is not authorized"
+// @Failure 403 {object} ErrorResponse "Forbidden - The user is not an admin"
+// @Failure 500 {object} ErrorResponse "Internal Server Error"
+// @Security ApiKeyAuth
+// @Router /admin/withdrawals/reconcile [post]
+func (ah *AdminHandler) ReconcileDebits(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), ah.contextTimeout)
+	defer cancel()
+
+	if err := checkQueryParams(r, "apply"); err != nil {
+		PrepareError(w, r, err)
+		return
+	}
+	// refunds move money, so a call without an explicit apply=true is a dry run
+	apply := false
+	if raw := r.URL.Query().Get("apply"); raw != "" {
+		var err error
+		apply, err = strconv.ParseBool(raw)
+		if err != nil {
+			PrepareError(w, r, appErrors.NewWithCode(err, "Invalid apply parameter", http.StatusBadRequest))
+			return
+		}
+	}
+
+	err := appContext.GetContextError(ctx)
+	if err != nil {
+		PrepareError(w, r, err)
+		return
+	}
+	reconciliations, err := ah.reconcileService.ReconcileDebits(ctx, apply)
+	if err != nil {
+		PrepareError(w, r, err)
+		return
+	}
+	if len(reconciliations) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	response := make(UnmatchedDebitDTOSlice, 0, len(reconciliations))
+	for _, reconciliation := range reconciliations {
+		response = append(response, UnmatchedDebitDTO{
+			Login:     reconciliation.Login,
+			Debits:    reconciliation.Debits,
+			Withdrawn: reconciliation.Withdrawn,
+			Unmatched: reconciliation.Unmatched(),
+			Refunded:  reconciliation.Refunded,
+		})
+	}
+	rawBytes, err := response.MarshalJSON()
+	if err != nil {
+		PrepareError(w, r, fmt.Errorf("marshal response: %w", err))
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(rawBytes)
+}
+
+// ListOrders godoc
+// @Summary Listing orders of all users by upload time
+// @Description The handler returns orders of all users uploaded in the [from, to) range, oldest first,
+// @Description together with the login of the owning user.
+// @Tags admin
+// @Produce json
+// @Param from query string true "Range start, RFC 3339"
+// @Param to query string true "Range end (exclusive), RFC 3339"
+// @Param limit query int false "Page size, clamped to the configured maximum"
+// @Param offset query int false "Number of orders to skip"
+// @Success 200 {array} AdminOrderDTO "List of orders with owner logins"
+// @Success 204 "No orders in the range"
+// @Failure 400 {object} ErrorResponse "Bad Request - Invalid time range or pagination parameters"
+// @Failure 401 {object} ErrorResponse "Unauthorized - The user is not authorized"
+// @Failure 403 {object} ErrorResponse "Forbidden - The user is not an admin"
+// @Failure 500 {object} ErrorResponse "Internal Server Error"
+// @Security ApiKeyAuth
+// @Router /admin/orders [get]
+func (ah *AdminHandler) ListOrders(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), ah.contextTimeout)
+	defer cancel()
+
+	from, to, err := parseTimeRange(r)
+	if err != nil {
+		PrepareError(w, r, err)
+		return
+	}
+	page, err := ah.pagination.ParsePage(r)
+	if err != nil {
+		PrepareError(w, r, err)
+		return
+	}
+
+	orders, err := ah.orderService.GetOrdersInRange(ctx, from, to, page.Limit, page.Offset)
+	if err != nil {
+		PrepareError(w, r, err)
+		return
+	}
+	if len(*orders) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	response := make(AdminOrderDTOSlice, 0, len(*orders))
+	for _, order := range *orders {
+		response = append(response, AdminOrderDTO{
+			OrderID:    order.ID,
+			Login:      order.Login,
+			Status:     order.Status.String(),
+			Accrual:    order.Accrual,
+			Attempts:   order.Attempts,
+			UploadedAt: order.CreatedAt,
+		})
+	}
+	rawBytes, err := response.MarshalJSON()
+	if err != nil {
+		PrepareError(w, r, fmt.Errorf("marshal response: %w", err))
+		return
+	}
+
+	err = appContext.GetContextError(ctx)
+	if err != nil {
+		PrepareError(w, r, err)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(rawBytes)
+}
+
+// ReverseWithdrawal godoc
+// @Summary Reversing a user's withdrawal
+// @Description The handler refunds the user's withdrawal for the order back to their wallet and records the reversal.
+// @Description Reversing an already reversed withdrawal succeeds without refunding it again.
+// @Tags admin
+// @Param login path string true "User login"
+// @Param order path string true "Order number of the withdrawal"
+// @Success 200 "The withdrawal is reversed"
+// @Failure 401 {object} ErrorResponse "Unauthorized - The user is not authorized"
+// @Failure 403 {object} ErrorResponse "Forbidden - The user is not an admin"
+// @Failure 404 {object} ErrorResponse "Not Found - The user has no withdrawal for the order"
+// @Failure 500 {object} ErrorResponse "Internal Server Error"
+// @Security ApiKeyAuth
+// @Router /admin/withdrawals/{login}/{order}/reverse [post]
+func (ah *AdminHandler) ReverseWithdrawal(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), ah.contextTimeout)
+	defer cancel()
+
+	user, err := ah.userService.GetByUserLogin(ctx, chi.URLParam(r, "login"))
+	if err != nil {
+		PrepareError(w, r, err)
+		return
+	}
+	err = appContext.GetContextError(ctx)
+	if err != nil {
+		PrepareError(w, r, err)
+		return
+	}
+	err = ah.withdrawalService.ReverseWithdrawal(ctx, &user.UUID, chi.URLParam(r, "order"))
+	if err != nil {
+		PrepareError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// RetryOrders godoc
+// @Summary Retrying a batch of orders
+// @Description The handler resets every INVALID or stuck PROCESSING order of the batch back to NEW, regardless of its owner,
+// @Description and sends it to processing again. Orders in other statuses are skipped, unknown numbers are reported as missing.
+// @Description A batch holds at most 100 order numbers.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param request body RetryOrdersRequestDTO true "Order numbers to retry"
+// @Success 200 {object} RetryOrdersResponseDTO "Order numbers by outcome"
+// @Failure 400 {object} ErrorResponse "Bad Request - Invalid body, empty or too large batch"
+// @Failure 401 {object} ErrorResponse "Unauthorized - The user is not authorized"
+// @Failure 403 {object} ErrorResponse "Forbidden - The user is not an admin"
+// @Failure 500 {object} ErrorResponse "Internal Server Error"
+// @Security ApiKeyAuth
+// @Router /admin/orders/retry [post]
+func (ah *AdminHandler) RetryOrders(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), ah.contextTimeout)
+	defer cancel()
+
+	orderIDs, err := parseRetryBatch(r)
+	if err != nil {
+		PrepareError(w, r, err)
+		return
+	}
+
+	err = appContext.GetContextError(ctx)
+	if err != nil {
+		PrepareError(w, r, err)
+		return
+	}
+	result, err := ah.orderService.RetryOrders(ctx, orderIDs)
+	if err != nil {
+		PrepareError(w, r, err)
+		return
+	}
+	writeRetryResult(w, r, result)
+}
+
+// ListDeadLetterOrders godoc
+// @Summary Listing orders that failed processing
+// @Description The handler returns the INVALID orders of all users, the longest failed first, with the owner login,
+// @Description the attempts and failed lookups made and why the order was marked INVALID.
+// @Description Orders marked INVALID before failure reasons were recorded have no reason.
+// @Tags admin
+// @Produce json
+// @Param limit query int false "Page size, clamped to the configured maximum"
+// @Param offset query int false "Number of orders to skip"
+// @Success 200 {array} DeadLetterOrderDTO "List of failed orders"
+// @Success 204 "No failed orders"
+// @Failure 400 {object} ErrorResponse "Bad Request - Invalid pagination parameters"
+// @Failure 401 {object} ErrorResponse "Unauthorized - The user is not authorized"
+// @Failure 403 {object} ErrorResponse "Forbidden - The user is not an admin"
+// @Failure 500 {object} ErrorResponse "Internal Server Error"
+// @Security ApiKeyAuth
+// @Router /admin/orders/dead-letter [get]
+func (ah *AdminHandler) ListDeadLetterOrders(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), ah.contextTimeout)
+	defer cancel()
+
+	page, err := ah.pagination.ParsePage(r)
+	if err != nil {
+		PrepareError(w, r, err)
+		return
+	}
+
+	orders, err := ah.orderService.GetDeadLetterOrders(ctx, page.Limit, page.Offset)
+	if err != nil {
+		PrepareError(w, r, err)
+		return
+	}
+	if len(*orders) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	response := make(DeadLetterOrderDTOSlice, 0, len(*orders))
+	for _, order := range *orders {
+		dto := DeadLetterOrderDTO{
+			OrderID:        order.ID,
+			Login:          order.Login,
+			Attempts:       order.Attempts,
+			LookupFailures: order.LookupFailures,
+			FailedAt:       order.UpdatedAt,
+			UploadedAt:     order.CreatedAt,
+		}
+		if order.FailureReason != nil {
+			dto.FailureReason = *order.FailureReason
+		}
+		response = append(response, dto)
+	}
+	rawBytes, err := response.MarshalJSON()
+	if err != nil {
+		PrepareError(w, r, fmt.Errorf("marshal response: %w", err))
+		return
+	}
+
+	err = appContext.GetContextError(ctx)
+	if err != nil {
+		PrepareError(w, r, err)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(rawBytes)
+}
+
+// RequeueDeadLetterOrders godoc
+// @Summary Requeueing orders that failed processing
+// @Description The handler resets every INVALID order of the batch back to NEW with fresh attempt counters, regardless of its owner,
+// @Description and sends it to processing again. Orders in other statuses are skipped, unknown numbers are reported as missing.
+// @Description A batch holds at most 100 order numbers.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param request body RetryOrdersRequestDTO true "Order numbers to requeue"
+// @Success 200 {object} RetryOrdersResponseDTO "Order numbers by outcome"
+// @Failure 400 {object} ErrorResponse "Bad Request - Invalid body, empty or too large batch"
+// @Failure 401 {object} ErrorResponse "Unauthorized - The user is not authorized"
+// @Failure 403 {object} ErrorResponse "Forbidden - The user is not an admin"
+// @Failure 500 {object} ErrorResponse "Internal Server Error"
+// @Security ApiKeyAuth
+// @Router /admin/orders/dead-letter/requeue [post]
+func (ah *AdminHandler) RequeueDeadLetterOrders(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), ah.contextTimeout)
+	defer cancel()
+
+	orderIDs, err := parseRetryBatch(r)
+	if err != nil {
+		PrepareError(w, r, err)
+		return
+	}
+
+	err = appContext.GetContextError(ctx)
+	if err != nil {
+		PrepareError(w, r, err)
+		return
+	}
+	result, err := ah.orderService.RequeueDeadLetterOrders(ctx, orderIDs)
+	if err != nil {
+		PrepareError(w, r, err)
+		return
+	}
+	writeRetryResult(w, r, result)
+}
+
+// parseRetryBatch reads the order numbers of a batch retry from the JSON body.
+func parseRetryBatch(r *http.Request) ([]string, error) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, appErrors.NewWithCode(err, errMsgEnableReadBody, http.StatusBadRequest)
+	}
+	request := RetryOrdersRequestDTO{}
+	err = request.UnmarshalJSON(body)
+	if err != nil {
+		return nil, appErrors.NewWithCode(err, "Unable to parse body", http.StatusBadRequest)
+	}
+	if len(request.Orders) == 0 || len(request.Orders) > maxRetryBatchSize {
+		msg := fmt.Sprintf("Batch must hold from 1 to %d orders", maxRetryBatchSize)
+		return nil, appErrors.NewWithCode(errors.New(msg), msg, http.StatusBadRequest)
+	}
+	return request.Orders, nil
+}
+
+func writeRetryResult(w http.ResponseWriter, r *http.Request, result *service.BatchRetryResult) {
+	response := RetryOrdersResponseDTO{
+		Retried: result.Retried,
+		Skipped: result.Skipped,
+		Missing: result.Missing,
+	}
+	rawBytes, err := response.MarshalJSON()
+	if err != nil {
+		PrepareError(w, r, fmt.Errorf("marshal response: %w", err))
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(rawBytes)
+}
+
+// CorrectAccrual godoc
+// @Summary Correcting the accrual of an order
+// @Description The handler replaces the accrual of a PROCESSED order, e.g. when the accrual system reported a wrong amount,
+// @Description and credits the owner's wallet with the difference, or takes it back when the accrual goes down.
+// @Description The correction is recorded. Corrections that would leave the owner with a negative balance are refused.
+// @Description An accrual of 0 clears the accrual of the order.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param number path string true "Order number"
+// @Param request body AccrualCorrectionRequestDTO true "Corrected accrual"
+// @Success 200 {object} AccrualCorrectionDTO "The recorded correction"
+// @Failure 400 {object} ErrorResponse "Bad Request - Invalid body or accrual"
+// @Failure 401 {object} ErrorResponse "Unauthorized - The user is not authorized"
+// @Failure 403 {object} ErrorResponse "Forbidden - The user is not an admin"
+// @Failure 404 {object} ErrorResponse "Not Found - The order does not exist"
+// @Failure 409 {object} ErrorResponse "Conflict - The order is not PROCESSED or the balance would become negative"
+// @Failure 500 {object} ErrorResponse "Internal Server Error"
+// @Security ApiKeyAuth
+// @Router /admin/orders/{number}/accrual [post]
+func (ah *AdminHandler) CorrectAccrual(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), ah.contextTimeout)
+	defer cancel()
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		PrepareError(w, r, appErrors.NewWithCode(err, errMsgEnableReadBody, http.StatusBadRequest))
+		return
+	}
+	request := AccrualCorrectionRequestDTO{}
+	err = request.UnmarshalJSON(body)
+	if err != nil {
+		PrepareError(w, r, appErrors.NewWithCode(err, "Unable to parse body", http.StatusBadRequest))
+		return
+	}
+	cents, err := parseNonNegativeCents(request.Accrual)
+	if err != nil {
+		PrepareError(w, r, appErrors.NewWithCode(err, "Invalid accrual", http.StatusBadRequest))
+		return
+	}
+
+	err = appContext.GetContextError(ctx)
+	if err != nil {
+		PrepareError(w, r, err)
+		return
+	}
+	correction, err := ah.orderService.CorrectAccrual(ctx, chi.URLParam(r, "number"), cents)
+	if err != nil {
+		PrepareError(w, r, err)
+		return
+	}
+	response := AccrualCorrectionDTO{
+		OrderID:         correction.OrderID,
+		PreviousAccrual: correction.PreviousAccrual.Float64(),
+		Accrual:         correction.Accrual.Float64(),
+		Delta:           correction.Delta.Float64(),
+		CorrectedAt:     correction.CreatedAt,
+	}
+	rawBytes, err := response.MarshalJSON()
+	if err != nil {
+		PrepareError(w, r, fmt.Errorf("marshal response: %w", err))
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(rawBytes)
+}
+
+// parseTimeRange reads the required from and to query params in RFC 3339.
+func parseTimeRange(r *http.Request) (time.Time, time.Time, error) {
+	query := r.URL.Query()
+	from, err := time.Parse(time.RFC3339, query.Get("from"))
+	if err != nil {
+		return time.Time{}, time.Time{}, appErrors.NewWithCode(err, errMsgInvalidTimeRange, http.StatusBadRequest)
+	}
+	to, err := time.Parse(time.RFC3339, query.Get("to"))
+	if err != nil {
+		return time.Time{}, time.Time{}, appErrors.NewWithCode(err, errMsgInvalidTimeRange, http.StatusBadRequest)
+	}
+	if !from.Before(to) {
+		msg := "from must be before to"
+		return time.Time{}, time.Time{}, appErrors.NewWithCode(errors.New(msg), errMsgInvalidTimeRange, http.StatusBadRequest)
+	}
+	return from, to, nil
+}
@@ -0,0 +1,1092 @@
+// Code generated by easyjson for marshaling/unmarshaling. DO NOT EDIT.
+
+package handlers
+
+import (
+	json "encoding/json"
+	easyjson "github.com/mailru/easyjson"
+	jlexer "github.com/mailru/easyjson/jlexer"
+	jwriter "github.com/mailru/easyjson/jwriter"
+)
+
+// suppress unused package warning
+var (
+	_ *json.RawMessage
+	_ *jlexer.Lexer
+	_ *jwriter.Writer
+	_ easyjson.Marshaler
+)
+
+func easyjsonCe850e56DecodeGithubComUjweghGophermartInternalAppHandlers(in *jlexer.Lexer, out *UnmatchedDebitDTOSlice) {
+	isTopLevel := in.IsStart()
+	if in.IsNull() {
+		in.Skip()
+		*out = nil
+	} else {
+		in.Delim('[')
+		if *out == nil {
+			if !in.IsDelim(']') {
+				*out = make(UnmatchedDebitDTOSlice, 0, 1)
+			} else {
+				*out = UnmatchedDebitDTOSlice{}
+			}
+		} else {
+			*out = (*out)[:0]
+		}
+		for !in.IsDelim(']') {
+			var v1 UnmatchedDebitDTO
+			(v1).UnmarshalEasyJSON(in)
+			*out = append(*out, v1)
+			in.WantComma()
+		}
+		in.Delim(']')
+	}
+	if isTopLevel {
+		in.Consumed()
+	}
+}
+func easyjsonCe850e56EncodeGithubComUjweghGophermartInternalAppHandlers(out *jwriter.Writer, in UnmatchedDebitDTOSlice) {
+	if in == nil && (out.Flags&jwriter.NilSliceAsEmpty) == 0 {
+		out.RawString("null")
+	} else {
+		out.RawByte('[')
+		for v2, v3 := range in {
+			if v2 > 0 {
+				out.RawByte(',')
+			}
+			(v3).MarshalEasyJSON(out)
+		}
+		out.RawByte(']')
+	}
+}
+
+// MarshalJSON supports json.Marshaler interface
+func (v UnmatchedDebitDTOSlice) MarshalJSON() ([]byte, error) {
+	w := jwriter.Writer{}
+	easyjsonCe850e56EncodeGithubComUjweghGophermartInternalAppHandlers(&w, v)
+	return w.Buffer.BuildBytes(), w.Error
+}
+
+// MarshalEasyJSON supports easyjson.Marshaler interface
+func (v UnmatchedDebitDTOSlice) MarshalEasyJSON(w *jwriter.Writer) {
+	easyjsonCe850e56EncodeGithubComUjweghGophermartInternalAppHandlers(w, v)
+}
+
+// UnmarshalJSON supports json.Unmarshaler interface
+func (v *UnmatchedDebitDTOSlice) UnmarshalJSON(data []byte) error {
+	r := jlexer.Lexer{Data: data}
+	easyjsonCe850e56DecodeGithubComUjweghGophermartInternalAppHandlers(&r, v)
+	return r.Error()
+}
+
+// UnmarshalEasyJSON supports easyjson.Unmarshaler interface
+func (v *UnmatchedDebitDTOSlice) UnmarshalEasyJSON(l *jlexer.Lexer) {
+	easyjsonCe850e56DecodeGithubComUjweghGophermartInternalAppHandlers(l, v)
+}
+func easyjsonCe850e56DecodeGithubComUjweghGophermartInternalAppHandlers1(in *jlexer.Lexer, out *UnmatchedDebitDTO) {
+	isTopLevel := in.IsStart()
+	if in.IsNull() {
+		if isTopLevel {
+			in.Consumed()
+		}
+		in.Skip()
+		return
+	}
+	in.Delim('{')
+	for !in.IsDelim('}') {
+		key := in.UnsafeFieldName(false)
+		in.WantColon()
+		if in.IsNull() {
+			in.Skip()
+			in.WantComma()
+			continue
+		}
+		switch key {
+		case "login":
+			out.Login = string(in.String())
+		case "debits":
+			out.Debits = float64(in.Float64())
+		case "withdrawn":
+			out.Withdrawn = float64(in.Float64())
+		case "unmatched":
+			out.Unmatched = float64(in.Float64())
+		case "refunded":
+			out.Refunded = float64(in.Float64())
+		default:
+			in.SkipRecursive()
+		}
+		in.WantComma()
+	}
+	in.Delim('}')
+	if isTopLevel {
+		in.Consumed()
+	}
+}
+func easyjsonCe850e56EncodeGithubComUjweghGophermartInternalAppHandlers1(out *jwriter.Writer, in UnmatchedDebitDTO) {
+	out.RawByte('{')
+	first := true
+	_ = first
+	{
+		const prefix string = ",\"login\":"
+		out.RawString(prefix[1:])
+		out.String(string(in.Login))
+	}
+	{
+		const prefix string = ",\"debits\":"
+		out.RawString(prefix)
+		out.Float64(float64(in.Debits))
+	}
+	{
+		const prefix string = ",\"withdrawn\":"
+		out.RawString(prefix)
+		out.Float64(float64(in.Withdrawn))
+	}
+	{
+		const prefix string = ",\"unmatched\":"
+		out.RawString(prefix)
+		out.Float64(float64(in.Unmatched))
+	}
+	{
+		const prefix string = ",\"refunded\":"
+		out.RawString(prefix)
+		out.Float64(float64(in.Refunded))
+	}
+	out.RawByte('}')
+}
+
+// MarshalJSON supports json.Marshaler interface
+func (v UnmatchedDebitDTO) MarshalJSON() ([]byte, error) {
+	w := jwriter.Writer{}
+	easyjsonCe850e56EncodeGithubComUjweghGophermartInternalAppHandlers1(&w, v)
+	return w.Buffer.BuildBytes(), w.Error
+}
+
+// MarshalEasyJSON supports easyjson.Marshaler interface
+func (v UnmatchedDebitDTO) MarshalEasyJSON(w *jwriter.Writer) {
+	easyjsonCe850e56EncodeGithubComUjweghGophermartInternalAppHandlers1(w, v)
+}
+
+// UnmarshalJSON supports json.Unmarshaler interface
+func (v *UnmatchedDebitDTO) UnmarshalJSON(data []byte) error {
+	r := jlexer.Lexer{Data: data}
+	easyjsonCe850e56DecodeGithubComUjweghGophermartInternalAppHandlers1(&r, v)
+	return r.Error()
+}
+
+// UnmarshalEasyJSON supports easyjson.Unmarshaler interface
+func (v *UnmatchedDebitDTO) UnmarshalEasyJSON(l *jlexer.Lexer) {
+	easyjsonCe850e56DecodeGithubComUjweghGophermartInternalAppHandlers1(l, v)
+}
+func easyjsonCe850e56DecodeGithubComUjweghGophermartInternalAppHandlers2(in *jlexer.Lexer, out *RetryOrdersResponseDTO) {
+	isTopLevel := in.IsStart()
+	if in.IsNull() {
+		if isTopLevel {
+			in.Consumed()
+		}
+		in.Skip()
+		return
+	}
+	in.Delim('{')
+	for !in.IsDelim('}') {
+		key := in.UnsafeFieldName(false)
+		in.WantColon()
+		if in.IsNull() {
+			in.Skip()
+			in.WantComma()
+			continue
+		}
+		switch key {
+		case "retried":
+			if in.IsNull() {
+				in.Skip()
+				out.Retried = nil
+			} else {
+				in.Delim('[')
+				if out.Retried == nil {
+					if !in.IsDelim(']') {
+						out.Retried = make([]string, 0, 4)
+					} else {
+						out.Retried = []string{}
+					}
+				} else {
+					out.Retried = (out.Retried)[:0]
+				}
+				for !in.IsDelim(']') {
+					var v4 string
+					v4 = string(in.String())
+					out.Retried = append(out.Retried, v4)
+					in.WantComma()
+				}
+				in.Delim(']')
+			}
+		case "skipped":
+			if in.IsNull() {
+				in.Skip()
+				out.Skipped = nil
+			} else {
+				in.Delim('[')
+				if out.Skipped == nil {
+					if !in.IsDelim(']') {
+						out.Skipped = make([]string, 0, 4)
+					} else {
+						out.Skipped = []string{}
+					}
+				} else {
+					out.Skipped = (out.Skipped)[:0]
+				}
+				for !in.IsDelim(']') {
+					var v5 string
+					v5 = string(in.String())
+					out.Skipped = append(out.Skipped, v5)
+					in.WantComma()
+				}
+				in.Delim(']')
+			}
+		case "missing":
+			if in.IsNull() {
+				in.Skip()
+				out.Missing = nil
+			} else {
+				in.Delim('[')
+				if out.Missing == nil {
+					if !in.IsDelim(']') {
+						out.Missing = make([]string, 0, 4)
+					} else {
+						out.Missing = []string{}
+					}
+				} else {
+					out.Missing = (out.Missing)[:0]
+				}
+				for !in.IsDelim(']') {
+					var v6 string
+					v6 = string(in.String())
+					out.Missing = append(out.Missing, v6)
+					in.WantComma()
+				}
+				in.Delim(']')
+			}
+		default:
+			in.SkipRecursive()
+		}
+		in.WantComma()
+	}
+	in.Delim('}')
+	if isTopLevel {
+		in.Consumed()
+	}
+}
+func easyjsonCe850e56EncodeGithubComUjweghGophermartInternalAppHandlers2(out *jwriter.Writer, in RetryOrdersResponseDTO) {
+	out.RawByte('{')
+	first := true
+	_ = first
+	{
+		const prefix string = ",\"retried\":"
+		out.RawString(prefix[1:])
+		if in.Retried == nil && (out.Flags&jwriter.NilSliceAsEmpty) == 0 {
+			out.RawString("null")
+		} else {
+			out.RawByte('[')
+			for v7, v8 := range in.Retried {
+				if v7 > 0 {
+					out.RawByte(',')
+				}
+				out.String(string(v8))
+			}
+			out.RawByte(']')
+		}
+	}
+	{
+		const prefix string = ",\"skipped\":"
+		out.RawString(prefix)
+		if in.Skipped == nil && (out.Flags&jwriter.NilSliceAsEmpty) == 0 {
+			out.RawString("null")
+		} else {
+			out.RawByte('[')
+			for v9, v10 := range in.Skipped {
+				if v9 > 0 {
+					out.RawByte(',')
+				}
+				out.String(string(v10))
+			}
+			out.RawByte(']')
+		}
+	}
+	{
+		const prefix string = ",\"missing\":"
+		out.RawString(prefix)
+		if in.Missing == nil && (out.Flags&jwriter.NilSliceAsEmpty) == 0 {
+			out.RawString("null")
+		} else {
+			out.RawByte('[')
+			for v11, v12 := range in.Missing {
+				if v11 > 0 {
+					out.RawByte(',')
+				}
+				out.String(string(v12))
+			}
+			out.RawByte(']')
+		}
+	}
+	out.RawByte('}')
+}
+
+// MarshalJSON supports json.Marshaler interface
+func (v RetryOrdersResponseDTO) MarshalJSON() ([]byte, error) {
+	w := jwriter.Writer{}
+	easyjsonCe850e56EncodeGithubComUjweghGophermartInternalAppHandlers2(&w, v)
+	return w.Buffer.BuildBytes(), w.Error
+}
+
+// MarshalEasyJSON supports easyjson.Marshaler interface
+func (v RetryOrdersResponseDTO) MarshalEasyJSON(w *jwriter.Writer) {
+	easyjsonCe850e56EncodeGithubComUjweghGophermartInternalAppHandlers2(w, v)
+}
+
+// UnmarshalJSON supports json.Unmarshaler interface
+func (v *RetryOrdersResponseDTO) UnmarshalJSON(data []byte) error {
+	r := jlexer.Lexer{Data: data}
+	easyjsonCe850e56DecodeGithubComUjweghGophermartInternalAppHandlers2(&r, v)
+	return r.Error()
+}
+
+// UnmarshalEasyJSON supports easyjson.Unmarshaler interface
+func (v *RetryOrdersResponseDTO) UnmarshalEasyJSON(l *jlexer.Lexer) {
+	easyjsonCe850e56DecodeGithubComUjweghGophermartInternalAppHandlers2(l, v)
+}
+func easyjsonCe850e56DecodeGithubComUjweghGophermartInternalAppHandlers3(in *jlexer.Lexer, out *RetryOrdersRequestDTO) {
+	isTopLevel := in.IsStart()
+	if in.IsNull() {
+		if isTopLevel {
+			in.Consumed()
+		}
+		in.Skip()
+		return
+	}
+	in.Delim('{')
+	for !in.IsDelim('}') {
+		key := in.UnsafeFieldName(false)
+		in.WantColon()
+		if in.IsNull() {
+			in.Skip()
+			in.WantComma()
+			continue
+		}
+		switch key {
+		case "orders":
+			if in.IsNull() {
+				in.Skip()
+				out.Orders = nil
+			} else {
+				in.Delim('[')
+				if out.Orders == nil {
+					if !in.IsDelim(']') {
+						out.Orders = make([]string, 0, 4)
+					} else {
+						out.Orders = []string{}
+					}
+				} else {
+					out.Orders = (out.Orders)[:0]
+				}
+				for !in.IsDelim(']') {
+					var v13 string
+					v13 = string(in.String())
+					out.Orders = append(out.Orders, v13)
+					in.WantComma()
+				}
+				in.Delim(']')
+			}
+		default:
+			in.SkipRecursive()
+		}
+		in.WantComma()
+	}
+	in.Delim('}')
+	if isTopLevel {
+		in.Consumed()
+	}
+}
+func easyjsonCe850e56EncodeGithubComUjweghGophermartInternalAppHandlers3(out *jwriter.Writer, in RetryOrdersRequestDTO) {
+	out.RawByte('{')
+	first := true
+	_ = first
+	{
+		const prefix string = ",\"orders\":"
+		out.RawString(prefix[1:])
+		if in.Orders == nil && (out.Flags&jwriter.NilSliceAsEmpty) == 0 {
+			out.RawString("null")
+		} else {
+			out.RawByte('[')
+			for v14, v15 := range in.Orders {
+				if v14 > 0 {
+					out.RawByte(',')
+				}
+				out.String(string(v15))
+			}
+			out.RawByte(']')
+		}
+	}
+	out.RawByte('}')
+}
+
+// MarshalJSON supports json.Marshaler interface
+func (v RetryOrdersRequestDTO) MarshalJSON() ([]byte, error) {
+	w := jwriter.Writer{}
+	easyjsonCe850e56EncodeGithubComUjweghGophermartInternalAppHandlers3(&w, v)
+	return w.Buffer.BuildBytes(), w.Error
+}
+
+// MarshalEasyJSON supports easyjson.Marshaler interface
+func (v RetryOrdersRequestDTO) MarshalEasyJSON(w *jwriter.Writer) {
+	easyjsonCe850e56EncodeGithubComUjweghGophermartInternalAppHandlers3(w, v)
+}
+
+// UnmarshalJSON supports json.Unmarshaler interface
+func (v *RetryOrdersRequestDTO) UnmarshalJSON(data []byte) error {
+	r := jlexer.Lexer{Data: data}
+	easyjsonCe850e56DecodeGithubComUjweghGophermartInternalAppHandlers3(&r, v)
+	return r.Error()
+}
+
+// UnmarshalEasyJSON supports easyjson.Unmarshaler interface
+func (v *RetryOrdersRequestDTO) UnmarshalEasyJSON(l *jlexer.Lexer) {
+	easyjsonCe850e56DecodeGithubComUjweghGophermartInternalAppHandlers3(l, v)
+}
+func easyjsonCe850e56DecodeGithubComUjweghGophermartInternalAppHandlers4(in *jlexer.Lexer, out *ReconciliationDTO) {
+	isTopLevel := in.IsStart()
+	if in.IsNull() {
+		if isTopLevel {
+			in.Consumed()
+		}
+		in.Skip()
+		return
+	}
+	in.Delim('{')
+	for !in.IsDelim('}') {
+		key := in.UnsafeFieldName(false)
+		in.WantColon()
+		if in.IsNull() {
+			in.Skip()
+			in.WantComma()
+			continue
+		}
+		switch key {
+		case "login":
+			out.Login = string(in.String())
+		case "expected_credits":
+			out.ExpectedCredits = float64(in.Float64())
+		case "actual_credits":
+			out.ActualCredits = float64(in.Float64())
+		case "expected_debits":
+			out.ExpectedDebits = float64(in.Float64())
+		case "actual_debits":
+			out.ActualDebits = float64(in.Float64())
+		case "expected_balance":
+			out.ExpectedBalance = float64(in.Float64())
+		case "actual_balance":
+			out.ActualBalance = float64(in.Float64())
+		case "discrepancy":
+			out.Discrepancy = float64(in.Float64())
+		case "consistent":
+			out.Consistent = bool(in.Bool())
+		default:
+			in.SkipRecursive()
+		}
+		in.WantComma()
+	}
+	in.Delim('}')
+	if isTopLevel {
+		in.Consumed()
+	}
+}
+func easyjsonCe850e56EncodeGithubComUjweghGophermartInternalAppHandlers4(out *jwriter.Writer, in ReconciliationDTO) {
+	out.RawByte('{')
+	first := true
+	_ = first
+	{
+		const prefix string = ",\"login\":"
+		out.RawString(prefix[1:])
+		out.String(string(in.Login))
+	}
+	{
+		const prefix string = ",\"expected_credits\":"
+		out.RawString(prefix)
+		out.Float64(float64(in.ExpectedCredits))
+	}
+	{
+		const prefix string = ",\"actual_credits\":"
+		out.RawString(prefix)
+		out.Float64(float64(in.ActualCredits))
+	}
+	{
+		const prefix string = ",\"expected_debits\":"
+		out.RawString(prefix)
+		out.Float64(float64(in.ExpectedDebits))
+	}
+	{
+		const prefix string = ",\"actual_debits\":"
+		out.RawString(prefix)
+		out.Float64(float64(in.ActualDebits))
+	}
+	{
+		const prefix string = ",\"expected_balance\":"
+		out.RawString(prefix)
+		out.Float64(float64(in.ExpectedBalance))
+	}
+	{
+		const prefix string = ",\"actual_balance\":"
+		out.RawString(prefix)
+		out.Float64(float64(in.ActualBalance))
+	}
+	{
+		const prefix string = ",\"discrepancy\":"
+		out.RawString(prefix)
+		out.Float64(float64(in.Discrepancy))
+	}
+	{
+		const prefix string = ",\"consistent\":"
+		out.RawString(prefix)
+		out.Bool(bool(in.Consistent))
+	}
+	out.RawByte('}')
+}
+
+// MarshalJSON supports json.Marshaler interface
+func (v ReconciliationDTO) MarshalJSON() ([]byte, error) {
+	w := jwriter.Writer{}
+	easyjsonCe850e56EncodeGithubComUjweghGophermartInternalAppHandlers4(&w, v)
+	return w.Buffer.BuildBytes(), w.Error
+}
+
+// MarshalEasyJSON supports easyjson.Marshaler interface
+func (v ReconciliationDTO) MarshalEasyJSON(w *jwriter.Writer) {
+	easyjsonCe850e56EncodeGithubComUjweghGophermartInternalAppHandlers4(w, v)
+}
+
+// UnmarshalJSON supports json.Unmarshaler interface
+func (v *ReconciliationDTO) UnmarshalJSON(data []byte) error {
+	r := jlexer.Lexer{Data: data}
+	easyjsonCe850e56DecodeGithubComUjweghGophermartInternalAppHandlers4(&r, v)
+	return r.Error()
+}
+
+// UnmarshalEasyJSON supports easyjson.Unmarshaler interface
+func (v *ReconciliationDTO) UnmarshalEasyJSON(l *jlexer.Lexer) {
+	easyjsonCe850e56DecodeGithubComUjweghGophermartInternalAppHandlers4(l, v)
+}
+func easyjsonCe850e56DecodeGithubComUjweghGophermartInternalAppHandlers5(in *jlexer.Lexer, out *DeadLetterOrderDTOSlice) {
+	isTopLevel := in.IsStart()
+	if in.IsNull() {
+		in.Skip()
+		*out = nil
+	} else {
+		in.Delim('[')
+		if *out == nil {
+			if !in.IsDelim(']') {
+				*out = make(DeadLetterOrderDTOSlice, 0, 0)
+			} else {
+				*out = DeadLetterOrderDTOSlice{}
+			}
+		} else {
+			*out = (*out)[:0]
+		}
+		for !in.IsDelim(']') {
+			var v16 DeadLetterOrderDTO
+			(v16).UnmarshalEasyJSON(in)
+			*out = append(*out, v16)
+			in.WantComma()
+		}
+		in.Delim(']')
+	}
+	if isTopLevel {
+		in.Consumed()
+	}
+}
+func easyjsonCe850e56EncodeGithubComUjweghGophermartInternalAppHandlers5(out *jwriter.Writer, in DeadLetterOrderDTOSlice) {
+	if in == nil && (out.Flags&jwriter.NilSliceAsEmpty) == 0 {
+		out.RawString("null")
+	} else {
+		out.RawByte('[')
+		for v17, v18 := range in {
+			if v17 > 0 {
+				out.RawByte(',')
+			}
+			(v18).MarshalEasyJSON(out)
+		}
+		out.RawByte(']')
+	}
+}
+
+// MarshalJSON supports json.Marshaler interface
+func (v DeadLetterOrderDTOSlice) MarshalJSON() ([]byte, error) {
+	w := jwriter.Writer{}
+	easyjsonCe850e56EncodeGithubComUjweghGophermartInternalAppHandlers5(&w, v)
+	return w.Buffer.BuildBytes(), w.Error
+}
+
+// MarshalEasyJSON supports easyjson.Marshaler interface
+func (v DeadLetterOrderDTOSlice) MarshalEasyJSON(w *jwriter.Writer) {
+	easyjsonCe850e56EncodeGithubComUjweghGophermartInternalAppHandlers5(w, v)
+}
+
+// UnmarshalJSON supports json.Unmarshaler interface
+func (v *DeadLetterOrderDTOSlice) UnmarshalJSON(data []byte) error {
+	r := jlexer.Lexer{Data: data}
+	easyjsonCe850e56DecodeGithubComUjweghGophermartInternalAppHandlers5(&r, v)
+	return r.Error()
+}
+
+// UnmarshalEasyJSON supports easyjson.Unmarshaler interface
+func (v *DeadLetterOrderDTOSlice) UnmarshalEasyJSON(l *jlexer.Lexer) {
+	easyjsonCe850e56DecodeGithubComUjweghGophermartInternalAppHandlers5(l, v)
+}
+func easyjsonCe850e56DecodeGithubComUjweghGophermartInternalAppHandlers6(in *jlexer.Lexer, out *DeadLetterOrderDTO) {
+	isTopLevel := in.IsStart()
+	if in.IsNull() {
+		if isTopLevel {
+			in.Consumed()
+		}
+		in.Skip()
+		return
+	}
+	in.Delim('{')
+	for !in.IsDelim('}') {
+		key := in.UnsafeFieldName(false)
+		in.WantColon()
+		if in.IsNull() {
+			in.Skip()
+			in.WantComma()
+			continue
+		}
+		switch key {
+		case "number":
+			out.OrderID = string(in.String())
+		case "login":
+			out.Login = string(in.String())
+		case "attempts":
+			out.Attempts = int(in.Int())
+		case "lookup_failures":
+			out.LookupFailures = int(in.Int())
+		case "failure_reason":
+			out.FailureReason = string(in.String())
+		case "failed_at":
+			if data := in.Raw(); in.Ok() {
+				in.AddError((out.FailedAt).UnmarshalJSON(data))
+			}
+		case "uploaded_at":
+			if data := in.Raw(); in.Ok() {
+				in.AddError((out.UploadedAt).UnmarshalJSON(data))
+			}
+		default:
+			in.SkipRecursive()
+		}
+		in.WantComma()
+	}
+	in.Delim('}')
+	if isTopLevel {
+		in.Consumed()
+	}
+}
+func easyjsonCe850e56EncodeGithubComUjweghGophermartInternalAppHandlers6(out *jwriter.Writer, in DeadLetterOrderDTO) {
+	out.RawByte('{')
+	first := true
+	_ = first
+	{
+		const prefix string = ",\"number\":"
+		out.RawString(prefix[1:])
+		out.String(string(in.OrderID))
+	}
+	{
+		const prefix string = ",\"login\":"
+		out.RawString(prefix)
+		out.String(string(in.Login))
+	}
+	{
+		const prefix string = ",\"attempts\":"
+		out.RawString(prefix)
+		out.Int(int(in.Attempts))
+	}
+	{
+		const prefix string = ",\"lookup_failures\":"
+		out.RawString(prefix)
+		out.Int(int(in.LookupFailures))
+	}
+	if in.FailureReason != "" {
+		const prefix string = ",\"failure_reason\":"
+		out.RawString(prefix)
+		out.String(string(in.FailureReason))
+	}
+	{
+		const prefix string = ",\"failed_at\":"
+		out.RawString(prefix)
+		out.Raw((in.FailedAt).MarshalJSON())
+	}
+	{
+		const prefix string = ",\"uploaded_at\":"
+		out.RawString(prefix)
+		out.Raw((in.UploadedAt).MarshalJSON())
+	}
+	out.RawByte('}')
+}
+
+// MarshalJSON supports json.Marshaler interface
+func (v DeadLetterOrderDTO) MarshalJSON() ([]byte, error) {
+	w := jwriter.Writer{}
+	easyjsonCe850e56EncodeGithubComUjweghGophermartInternalAppHandlers6(&w, v)
+	return w.Buffer.BuildBytes(), w.Error
+}
+
+// MarshalEasyJSON supports easyjson.Marshaler interface
+func (v DeadLetterOrderDTO) MarshalEasyJSON(w *jwriter.Writer) {
+	easyjsonCe850e56EncodeGithubComUjweghGophermartInternalAppHandlers6(w, v)
+}
+
+// UnmarshalJSON supports json.Unmarshaler interface
+func (v *DeadLetterOrderDTO) UnmarshalJSON(data []byte) error {
+	r := jlexer.Lexer{Data: data}
+	easyjsonCe850e56DecodeGithubComUjweghGophermartInternalAppHandlers6(&r, v)
+	return r.Error()
+}
+
+// UnmarshalEasyJSON supports easyjson.Unmarshaler interface
+func (v *DeadLetterOrderDTO) UnmarshalEasyJSON(l *jlexer.Lexer) {
+	easyjsonCe850e56DecodeGithubComUjweghGophermartInternalAppHandlers6(l, v)
+}
+func easyjsonCe850e56DecodeGithubComUjweghGophermartInternalAppHandlers7(in *jlexer.Lexer, out *AdminOrderDTOSlice) {
+	isTopLevel := in.IsStart()
+	if in.IsNull() {
+		in.Skip()
+		*out = nil
+	} else {
+		in.Delim('[')
+		if *out == nil {
+			if !in.IsDelim(']') {
+				*out = make(AdminOrderDTOSlice, 0, 0)
+			} else {
+				*out = AdminOrderDTOSlice{}
+			}
+		} else {
+			*out = (*out)[:0]
+		}
+		for !in.IsDelim(']') {
+			var v19 AdminOrderDTO
+			(v19).UnmarshalEasyJSON(in)
+			*out = append(*out, v19)
+			in.WantComma()
+		}
+		in.Delim(']')
+	}
+	if isTopLevel {
+		in.Consumed()
+	}
+}
+func easyjsonCe850e56EncodeGithubComUjweghGophermartInternalAppHandlers7(out *jwriter.Writer, in AdminOrderDTOSlice) {
+	if in == nil && (out.Flags&jwriter.NilSliceAsEmpty) == 0 {
+		out.RawString("null")
+	} else {
+		out.RawByte('[')
+		for v20, v21 := range in {
+			if v20 > 0 {
+				out.RawByte(',')
+			}
+			(v21).MarshalEasyJSON(out)
+		}
+		out.RawByte(']')
+	}
+}
+
+// MarshalJSON supports json.Marshaler interface
+func (v AdminOrderDTOSlice) MarshalJSON() ([]byte, error) {
+	w := jwriter.Writer{}
+	easyjsonCe850e56EncodeGithubComUjweghGophermartInternalAppHandlers7(&w, v)
+	return w.Buffer.BuildBytes(), w.Error
+}
+
+// MarshalEasyJSON supports easyjson.Marshaler interface
+func (v AdminOrderDTOSlice) MarshalEasyJSON(w *jwriter.Writer) {
+	easyjsonCe850e56EncodeGithubComUjweghGophermartInternalAppHandlers7(w, v)
+}
+
+// UnmarshalJSON supports json.Unmarshaler interface
+func (v *AdminOrderDTOSlice) UnmarshalJSON(data []byte) error {
+	r := jlexer.Lexer{Data: data}
+	easyjsonCe850e56DecodeGithubComUjweghGophermartInternalAppHandlers7(&r, v)
+	return r.Error()
+}
+
+// UnmarshalEasyJSON supports easyjson.Unmarshaler interface
+func (v *AdminOrderDTOSlice) UnmarshalEasyJSON(l *jlexer.Lexer) {
+	easyjsonCe850e56DecodeGithubComUjweghGophermartInternalAppHandlers7(l, v)
+}
+func easyjsonCe850e56DecodeGithubComUjweghGophermartInternalAppHandlers8(in *jlexer.Lexer, out *AdminOrderDTO) {
+	isTopLevel := in.IsStart()
+	if in.IsNull() {
+		if isTopLevel {
+			in.Consumed()
+		}
+		in.Skip()
+		return
+	}
+	in.Delim('{')
+	for !in.IsDelim('}') {
+		key := in.UnsafeFieldName(false)
+		in.WantColon()
+		if in.IsNull() {
+			in.Skip()
+			in.WantComma()
+			continue
+		}
+		switch key {
+		case "number":
+			out.OrderID = string(in.String())
+		case "login":
+			out.Login = string(in.String())
+		case "status":
+			out.Status = string(in.String())
+		case "accrual":
+			if in.IsNull() {
+				in.Skip()
+				out.Accrual = nil
+			} else {
+				if out.Accrual == nil {
+					out.Accrual = new(float64)
+				}
+				*out.Accrual = float64(in.Float64())
+			}
+		case "attempts":
+			out.Attempts = int(in.Int())
+		case "uploaded_at":
+			if data := in.Raw(); in.Ok() {
+				in.AddError((out.UploadedAt).UnmarshalJSON(data))
+			}
+		default:
+			in.SkipRecursive()
+		}
+		in.WantComma()
+	}
+	in.Delim('}')
+	if isTopLevel {
+		in.Consumed()
+	}
+}
+func easyjsonCe850e56EncodeGithubComUjweghGophermartInternalAppHandlers8(out *jwriter.Writer, in AdminOrderDTO) {
+	out.RawByte('{')
+	first := true
+	_ = first
+	{
+		const prefix string = ",\"number\":"
+		out.RawString(prefix[1:])
+		out.String(string(in.OrderID))
+	}
+	{
+		const prefix string = ",\"login\":"
+		out.RawString(prefix)
+		out.String(string(in.Login))
+	}
+	{
+		const prefix string = ",\"status\":"
+		out.RawString(prefix)
+		out.String(string(in.Status))
+	}
+	if in.Accrual != nil {
+		const prefix string = ",\"accrual\":"
+		out.RawString(prefix)
+		out.Float64(float64(*in.Accrual))
+	}
+	{
+		const prefix string = ",\"attempts\":"
+		out.RawString(prefix)
+		out.Int(int(in.Attempts))
+	}
+	{
+		const prefix string = ",\"uploaded_at\":"
+		out.RawString(prefix)
+		out.Raw((in.UploadedAt).MarshalJSON())
+	}
+	out.RawByte('}')
+}
+
+// MarshalJSON supports json.Marshaler interface
+func (v AdminOrderDTO) MarshalJSON() ([]byte, error) {
+	w := jwriter.Writer{}
+	easyjsonCe850e56EncodeGithubComUjweghGophermartInternalAppHandlers8(&w, v)
+	return w.Buffer.BuildBytes(), w.Error
+}
+
+// MarshalEasyJSON supports easyjson.Marshaler interface
+func (v AdminOrderDTO) MarshalEasyJSON(w *jwriter.Writer) {
+	easyjsonCe850e56EncodeGithubComUjweghGophermartInternalAppHandlers8(w, v)
+}
+
+// UnmarshalJSON supports json.Unmarshaler interface
+func (v *AdminOrderDTO) UnmarshalJSON(data []byte) error {
+	r := jlexer.Lexer{Data: data}
+	easyjsonCe850e56DecodeGithubComUjweghGophermartInternalAppHandlers8(&r, v)
+	return r.Error()
+}
+
+// UnmarshalEasyJSON supports easyjson.Unmarshaler interface
+func (v *AdminOrderDTO) UnmarshalEasyJSON(l *jlexer.Lexer) {
+	easyjsonCe850e56DecodeGithubComUjweghGophermartInternalAppHandlers8(l, v)
+}
+func easyjsonCe850e56DecodeGithubComUjweghGophermartInternalAppHandlers9(in *jlexer.Lexer, out *AccrualCorrectionRequestDTO) {
+	isTopLevel := in.IsStart()
+	if in.IsNull() {
+		if isTopLevel {
+			in.Consumed()
+		}
+		in.Skip()
+		return
+	}
+	in.Delim('{')
+	for !in.IsDelim('}') {
+		key := in.UnsafeFieldName(false)
+		in.WantColon()
+		if in.IsNull() {
+			in.Skip()
+			in.WantComma()
+			continue
+		}
+		switch key {
+		case "accrual":
+			out.Accrual = in.JsonNumber()
+		default:
+			in.SkipRecursive()
+		}
+		in.WantComma()
+	}
+	in.Delim('}')
+	if isTopLevel {
+		in.Consumed()
+	}
+}
+func easyjsonCe850e56EncodeGithubComUjweghGophermartInternalAppHandlers9(out *jwriter.Writer, in AccrualCorrectionRequestDTO) {
+	out.RawByte('{')
+	first := true
+	_ = first
+	{
+		const prefix string = ",\"accrual\":"
+		out.RawString(prefix[1:])
+		out.String(string(in.Accrual))
+	}
+	out.RawByte('}')
+}
+
+// MarshalJSON supports json.Marshaler interface
+func (v AccrualCorrectionRequestDTO) MarshalJSON() ([]byte, error) {
+	w := jwriter.Writer{}
+	easyjsonCe850e56EncodeGithubComUjweghGophermartInternalAppHandlers9(&w, v)
+	return w.Buffer.BuildBytes(), w.Error
+}
+
+// MarshalEasyJSON supports easyjson.Marshaler interface
+func (v AccrualCorrectionRequestDTO) MarshalEasyJSON(w *jwriter.Writer) {
+	easyjsonCe850e56EncodeGithubComUjweghGophermartInternalAppHandlers9(w, v)
+}
+
+// UnmarshalJSON supports json.Unmarshaler interface
+func (v *AccrualCorrectionRequestDTO) UnmarshalJSON(data []byte) error {
+	r := jlexer.Lexer{Data: data}
+	easyjsonCe850e56DecodeGithubComUjweghGophermartInternalAppHandlers9(&r, v)
+	return r.Error()
+}
+
+// UnmarshalEasyJSON supports easyjson.Unmarshaler interface
+func (v *AccrualCorrectionRequestDTO) UnmarshalEasyJSON(l *jlexer.Lexer) {
+	easyjsonCe850e56DecodeGithubComUjweghGophermartInternalAppHandlers9(l, v)
+}
+func easyjsonCe850e56DecodeGithubComUjweghGophermartInternalAppHandlers10(in *jlexer.Lexer, out *AccrualCorrectionDTO) {
+	isTopLevel := in.IsStart()
+	if in.IsNull() {
+		if isTopLevel {
+			in.Consumed()
+		}
+		in.Skip()
+		return
+	}
+	in.Delim('{')
+	for !in.IsDelim('}') {
+		key := in.UnsafeFieldName(false)
+		in.WantColon()
+		if in.IsNull() {
+			in.Skip()
+			in.WantComma()
+			continue
+		}
+		switch key {
+		case "order":
+			out.OrderID = string(in.String())
+		case "previous_accrual":
+			out.PreviousAccrual = float64(in.Float64())
+		case "accrual":
+			out.Accrual = float64(in.Float64())
+		case "delta":
+			out.Delta = float64(in.Float64())
+		case "corrected_at":
+			if data := in.Raw(); in.Ok() {
+				in.AddError((out.CorrectedAt).UnmarshalJSON(data))
+			}
+		default:
+			in.SkipRecursive()
+		}
+		in.WantComma()
+	}
+	in.Delim('}')
+	if isTopLevel {
+		in.Consumed()
+	}
+}
+func easyjsonCe850e56EncodeGithubComUjweghGophermartInternalAppHandlers10(out *jwriter.Writer, in AccrualCorrectionDTO) {
+	out.RawByte('{')
+	first := true
+	_ = first
+	{
+		const prefix string = ",\"order\":"
+		out.RawString(prefix[1:])
+		out.String(string(in.OrderID))
+	}
+	{
+		const prefix string = ",\"previous_accrual\":"
+		out.RawString(prefix)
+		out.Float64(float64(in.PreviousAccrual))
+	}
+	{
+		const prefix string = ",\"accrual\":"
+		out.RawString(prefix)
+		out.Float64(float64(in.Accrual))
+	}
+	{
+		const prefix string = ",\"delta\":"
+		out.RawString(prefix)
+		out.Float64(float64(in.Delta))
+	}
+	{
+		const prefix string = ",\"corrected_at\":"
+		out.RawString(prefix)
+		out.Raw((in.CorrectedAt).MarshalJSON())
+	}
+	out.RawByte('}')
+}
+
+// MarshalJSON supports json.Marshaler interface
+func (v AccrualCorrectionDTO) MarshalJSON() ([]byte, error) {
+	w := jwriter.Writer{}
+	easyjsonCe850e56EncodeGithubComUjweghGophermartInternalAppHandlers10(&w, v)
+	return w.Buffer.BuildBytes(), w.Error
+}
+
+// MarshalEasyJSON supports easyjson.Marshaler interface
+func (v AccrualCorrectionDTO) MarshalEasyJSON(w *jwriter.Writer) {
+	easyjsonCe850e56EncodeGithubComUjweghGophermartInternalAppHandlers10(w, v)
+}
+
+// UnmarshalJSON supports json.Unmarshaler interface
+func (v *AccrualCorrectionDTO) UnmarshalJSON(data []byte) error {
+	r := jlexer.Lexer{Data: data}
+	easyjsonCe850e56DecodeGithubComUjweghGophermartInternalAppHandlers10(&r, v)
+	return r.Error()
+}
+
+// UnmarshalEasyJSON supports easyjson.Unmarshaler interface
+func (v *AccrualCorrectionDTO) UnmarshalEasyJSON(l *jlexer.Lexer) {
+	easyjsonCe850e56DecodeGithubComUjweghGophermartInternalAppHandlers10(l, v)
+}
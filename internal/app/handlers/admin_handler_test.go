@@ -0,0 +1,605 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	appErrors "github.com/ujwegh/gophermart/internal/app/errors"
+	"github.com/ujwegh/gophermart/internal/app/repository"
+	"github.com/ujwegh/gophermart/internal/app/service"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type MockReconcileService struct {
+	mock.Mock
+}
+
+func (m *MockReconcileService) Reconcile(ctx context.Context, login string) (*service.Reconciliation, error) {
+	args := m.Called(ctx, login)
+	return args.Get(0).(*service.Reconciliation), args.Error(1)
+}
+
+func (m *MockReconcileService) ReconcileDebits(ctx context.Context, refund bool) ([]service.DebitReconciliation, error) {
+	args := m.Called(ctx, refund)
+	return args.Get(0).([]service.DebitReconciliation), args.Error(1)
+}
+
+func TestAdminHandler_Reconcile(t *testing.T) {
+	tests := []struct {
+		name             string
+		reconciliation   *service.Reconciliation
+		err              error
+		wantStatusCode   int
+		wantResponseBody string
+	}{
+		{
+			name: "Consistent Wallet",
+			reconciliation: &service.Reconciliation{
+				Login: "alice", ExpectedCredits: 500.5, ActualCredits: 500.5, ExpectedDebits: 100, ActualDebits: 100,
+				ExpectedBalance: 400.5, ActualBalance: 400.5, Consistent: true,
+			},
+			wantStatusCode: http.StatusOK,
+			wantResponseBody: `{"login":"alice","expected_credits":500.5,"actual_credits":500.5,"expected_debits":100,
+				"actual_debits":100,"expected_balance":400.5,"actual_balance":400.5,"discrepancy":0,"consistent":true}`,
+		},
+		{
+			name: "Discrepancy Reported",
+			reconciliation: &service.Reconciliation{
+				Login: "alice", ExpectedCredits: 500.5, ActualCredits: 520.5, ExpectedDebits: 100, ActualDebits: 100,
+				ExpectedBalance: 400.5, ActualBalance: 420.5, Discrepancy: 20,
+			},
+			wantStatusCode: http.StatusOK,
+			wantResponseBody: `{"login":"alice","expected_credits":500.5,"actual_credits":520.5,"expected_debits":100,
+				"actual_debits":100,"expected_balance":400.5,"actual_balance":420.5,"discrepancy":20,"consistent":false}`,
+		},
+		{
+			name:             "Unknown User",
+			err:              appErrors.NewWithCode(errors.New("user not found"), "User not found", http.StatusNotFound),
+			wantStatusCode:   http.StatusNotFound,
+			wantResponseBody: `{"code":404,"message":"User not found"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/admin/reconcile/alice", nil)
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("login", "alice")
+			req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+			w := httptest.NewRecorder()
+
+			rs := &MockReconcileService{}
+			rs.On("Reconcile", mock.Anything, "alice").Return(tt.reconciliation, tt.err)
+			ah := &AdminHandler{reconcileService: rs, contextTimeout: 5 * time.Second}
+			ah.Reconcile(w, req)
+
+			assert.Equal(t, tt.wantStatusCode, w.Code)
+			assert.JSONEq(t, tt.wantResponseBody, w.Body.String())
+			rs.AssertExpectations(t)
+		})
+	}
+}
+
+func TestAdminHandler_ReconcileDebits(t *testing.T) {
+	unmatched := repository.UnmatchedDebit{UserUUID: uuid.New(), Login: "alice", Debits: 125.5, Withdrawn: 100}
+	tests := []struct {
+		name             string
+		query            string
+		wantRefund       bool
+		reconciliations  []service.DebitReconciliation
+		wantStatusCode   int
+		wantResponseBody string
+	}{
+		{
+			name:             "Debits Refunded",
+			query:            "?apply=true",
+			wantRefund:       true,
+			reconciliations:  []service.DebitReconciliation{{UnmatchedDebit: unmatched, Refunded: 25.5}},
+			wantStatusCode:   http.StatusOK,
+			wantResponseBody: `[{"login":"alice","debits":125.5,"withdrawn":100,"unmatched":25.5,"refunded":25.5}]`,
+		},
+		{
+			name:             "Dry Run By Default",
+			wantRefund:       false,
+			reconciliations:  []service.DebitReconciliation{{UnmatchedDebit: unmatched}},
+			wantStatusCode:   http.StatusOK,
+			wantResponseBody: `[{"login":"alice","debits":125.5,"withdrawn":100,"unmatched":25.5,"refunded":0}]`,
+		},
+		{
+			name:            "Nothing Unmatched",
+			query:           "?apply=true",
+			wantRefund:      true,
+			reconciliations: []service.DebitReconciliation{},
+			wantStatusCode:  http.StatusNoContent,
+		},
+		{
+			name:             "Explicit Dry Run",
+			query:            "?apply=false",
+			wantRefund:       false,
+			reconciliations:  []service.DebitReconciliation{{UnmatchedDebit: unmatched}},
+			wantStatusCode:   http.StatusOK,
+			wantResponseBody: `[{"login":"alice","debits":125.5,"withdrawn":100,"unmatched":25.5,"refunded":0}]`,
+		},
+		{
+			name:             "Invalid Apply",
+			query:            "?apply=maybe",
+			wantStatusCode:   http.StatusBadRequest,
+			wantResponseBody: `{"code":400,"message":"Invalid apply parameter"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/admin/withdrawals/reconcile"+tt.query, nil)
+			w := httptest.NewRecorder()
+
+			rs := &MockReconcileService{}
+			if tt.reconciliations != nil {
+				rs.On("ReconcileDebits", mock.Anything, tt.wantRefund).Return(tt.reconciliations, nil)
+			}
+			ah := &AdminHandler{reconcileService: rs, contextTimeout: 5 * time.Second}
+			ah.ReconcileDebits(w, req)
+
+			assert.Equal(t, tt.wantStatusCode, w.Code)
+			if tt.wantResponseBody != "" {
+				assert.JSONEq(t, tt.wantResponseBody, w.Body.String())
+			} else {
+				assert.Empty(t, w.Body.String())
+			}
+			rs.AssertExpectations(t)
+		})
+	}
+}
+
+func TestAdminHandler_ListOrders(t *testing.T) {
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+	accrual := 42.5
+	tests := []struct {
+		name             string
+		query            string
+		mockOrderService func() *MockOrderService
+		wantStatusCode   int
+		wantResponseBody string
+	}{
+		{
+			name:  "Orders With Owner Logins",
+			query: "?from=2024-01-01T00:00:00Z&to=2024-02-01T00:00:00Z",
+			mockOrderService: func() *MockOrderService {
+				m := &MockOrderService{}
+				orders := &[]repository.UserOrder{
+					{Order: repository.Order{ID: "order1", Status: repository.NEW, CreatedAt: from}, Login: "alice"},
+					{Order: repository.Order{ID: "order2", Status: repository.PROCESSED, Accrual: &accrual, Attempts: 3, CreatedAt: from.Add(time.Hour)}, Login: "bob"},
+				}
+				m.On("GetOrdersInRange", mock.Anything, from, to, 100, 0).Return(orders, nil)
+				return m
+			},
+			wantStatusCode: http.StatusOK,
+			wantResponseBody: `[{"number":"order1","login":"alice","status":"NEW","attempts":0,"uploaded_at":"2024-01-01T00:00:00Z"},` +
+				`{"number":"order2","login":"bob","status":"PROCESSED","accrual":42.5,"attempts":3,"uploaded_at":"2024-01-01T01:00:00Z"}]`,
+		},
+		{
+			name:  "No Orders In Range",
+			query: "?from=2024-01-01T00:00:00Z&to=2024-02-01T00:00:00Z&limit=5&offset=10",
+			mockOrderService: func() *MockOrderService {
+				m := &MockOrderService{}
+				m.On("GetOrdersInRange", mock.Anything, from, to, 5, 10).Return(&[]repository.UserOrder{}, nil)
+				return m
+			},
+			wantStatusCode: http.StatusNoContent,
+		},
+		{
+			name:             "Missing Range",
+			query:            "?from=2024-01-01T00:00:00Z",
+			mockOrderService: func() *MockOrderService { return &MockOrderService{} },
+			wantStatusCode:   http.StatusBadRequest,
+			wantResponseBody: `{"code":400,"message":"Invalid time range"}`,
+		},
+		{
+			name:             "Inverted Range",
+			query:            "?from=2024-02-01T00:00:00Z&to=2024-01-01T00:00:00Z",
+			mockOrderService: func() *MockOrderService { return &MockOrderService{} },
+			wantStatusCode:   http.StatusBadRequest,
+			wantResponseBody: `{"code":400,"message":"Invalid time range"}`,
+		},
+		{
+			name:  "Error In Order Retrieval",
+			query: "?from=2024-01-01T00:00:00Z&to=2024-02-01T00:00:00Z",
+			mockOrderService: func() *MockOrderService {
+				m := &MockOrderService{}
+				m.On("GetOrdersInRange", mock.Anything, from, to, 100, 0).Return((*[]repository.UserOrder)(nil), errors.New("db down"))
+				return m
+			},
+			wantStatusCode:   http.StatusInternalServerError,
+			wantResponseBody: `{"code":500,"message":"Internal Server Error"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/admin/orders"+tt.query, nil)
+			w := httptest.NewRecorder()
+
+			ah := &AdminHandler{
+				orderService:   tt.mockOrderService(),
+				contextTimeout: 5 * time.Second,
+				pagination:     NewPagination(100, 1000),
+			}
+			ah.ListOrders(w, req)
+
+			assert.Equal(t, tt.wantStatusCode, w.Code)
+			if tt.wantResponseBody != "" {
+				assert.JSONEq(t, tt.wantResponseBody, w.Body.String())
+			} else {
+				assert.Empty(t, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestAdminHandler_RetryOrders(t *testing.T) {
+	tests := []struct {
+		name             string
+		body             string
+		mockOrderService func() *MockOrderService
+		wantStatusCode   int
+		wantResponseBody string
+	}{
+		{
+			name: "Orders By Outcome",
+			body: `{"orders":["354188083613","12345678903","79927398713"]}`,
+			mockOrderService: func() *MockOrderService {
+				m := &MockOrderService{}
+				m.On("RetryOrders", mock.Anything, []string{"354188083613", "12345678903", "79927398713"}).
+					Return(&service.BatchRetryResult{
+						Retried: []string{"354188083613"},
+						Skipped: []string{"12345678903"},
+						Missing: []string{"79927398713"},
+					}, nil)
+				return m
+			},
+			wantStatusCode:   http.StatusOK,
+			wantResponseBody: `{"retried":["354188083613"],"skipped":["12345678903"],"missing":["79927398713"]}`,
+		},
+		{
+			name:             "Invalid Body",
+			body:             `["354188083613"`,
+			mockOrderService: func() *MockOrderService { return &MockOrderService{} },
+			wantStatusCode:   http.StatusBadRequest,
+			wantResponseBody: `{"code":400,"message":"Unable to parse body"}`,
+		},
+		{
+			name:             "Empty Batch",
+			body:             `{"orders":[]}`,
+			mockOrderService: func() *MockOrderService { return &MockOrderService{} },
+			wantStatusCode:   http.StatusBadRequest,
+			wantResponseBody: `{"code":400,"message":"Batch must hold from 1 to 100 orders"}`,
+		},
+		{
+			name:             "Batch Too Large",
+			body:             `{"orders":[` + strings.Repeat(`"354188083613",`, 100) + `"354188083613"]}`,
+			mockOrderService: func() *MockOrderService { return &MockOrderService{} },
+			wantStatusCode:   http.StatusBadRequest,
+			wantResponseBody: `{"code":400,"message":"Batch must hold from 1 to 100 orders"}`,
+		},
+		{
+			name: "Error In Retry",
+			body: `{"orders":["354188083613"]}`,
+			mockOrderService: func() *MockOrderService {
+				m := &MockOrderService{}
+				m.On("RetryOrders", mock.Anything, []string{"354188083613"}).
+					Return((*service.BatchRetryResult)(nil), errors.New("db down"))
+				return m
+			},
+			wantStatusCode:   http.StatusInternalServerError,
+			wantResponseBody: `{"code":500,"message":"Internal Server Error"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/admin/orders/retry", strings.NewReader(tt.body))
+			w := httptest.NewRecorder()
+
+			orderService := tt.mockOrderService()
+			ah := &AdminHandler{
+				orderService:   orderService,
+				contextTimeout: 5 * time.Second,
+			}
+			ah.RetryOrders(w, req)
+
+			assert.Equal(t, tt.wantStatusCode, w.Code)
+			assert.JSONEq(t, tt.wantResponseBody, w.Body.String())
+			if tt.wantStatusCode == http.StatusBadRequest {
+				orderService.AssertNotCalled(t, "RetryOrders", mock.Anything, mock.Anything)
+			}
+		})
+	}
+}
+
+func TestAdminHandler_ListDeadLetterOrders(t *testing.T) {
+	uploaded := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	failed := uploaded.Add(time.Hour)
+	reason := "accrual lookups kept failing after 20 failures: accrual system unavailable"
+	tests := []struct {
+		name             string
+		query            string
+		mockOrderService func() *MockOrderService
+		wantStatusCode   int
+		wantResponseBody string
+	}{
+		{
+			name: "Failed Orders With Reasons",
+			mockOrderService: func() *MockOrderService {
+				m := &MockOrderService{}
+				orders := &[]repository.UserOrder{
+					{Order: repository.Order{ID: "order1", Status: repository.INVALID, LookupFailures: 20, FailureReason: &reason,
+						CreatedAt: uploaded, UpdatedAt: failed}, Login: "alice"},
+					{Order: repository.Order{ID: "order2", Status: repository.INVALID, Attempts: 3, CreatedAt: uploaded, UpdatedAt: failed}, Login: "bob"},
+				}
+				m.On("GetDeadLetterOrders", mock.Anything, 100, 0).Return(orders, nil)
+				return m
+			},
+			wantStatusCode: http.StatusOK,
+			wantResponseBody: `[{"number":"order1","login":"alice","attempts":0,"lookup_failures":20,"failure_reason":"` + reason + `",` +
+				`"failed_at":"2024-01-01T01:00:00Z","uploaded_at":"2024-01-01T00:00:00Z"},` +
+				`{"number":"order2","login":"bob","attempts":3,"lookup_failures":0,"failed_at":"2024-01-01T01:00:00Z","uploaded_at":"2024-01-01T00:00:00Z"}]`,
+		},
+		{
+			name:  "No Failed Orders",
+			query: "?limit=5&offset=10",
+			mockOrderService: func() *MockOrderService {
+				m := &MockOrderService{}
+				m.On("GetDeadLetterOrders", mock.Anything, 5, 10).Return(&[]repository.UserOrder{}, nil)
+				return m
+			},
+			wantStatusCode: http.StatusNoContent,
+		},
+		{
+			name: "Error In Order Retrieval",
+			mockOrderService: func() *MockOrderService {
+				m := &MockOrderService{}
+				m.On("GetDeadLetterOrders", mock.Anything, 100, 0).Return((*[]repository.UserOrder)(nil), errors.New("db down"))
+				return m
+			},
+			wantStatusCode:   http.StatusInternalServerError,
+			wantResponseBody: `{"code":500,"message":"Internal Server Error"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/admin/orders/dead-letter"+tt.query, nil)
+			w := httptest.NewRecorder()
+
+			ah := &AdminHandler{
+				orderService:   tt.mockOrderService(),
+				contextTimeout: 5 * time.Second,
+				pagination:     NewPagination(100, 1000),
+			}
+			ah.ListDeadLetterOrders(w, req)
+
+			assert.Equal(t, tt.wantStatusCode, w.Code)
+			if tt.wantResponseBody != "" {
+				assert.JSONEq(t, tt.wantResponseBody, w.Body.String())
+			} else {
+				assert.Empty(t, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestAdminHandler_RequeueDeadLetterOrders(t *testing.T) {
+	tests := []struct {
+		name             string
+		body             string
+		mockOrderService func() *MockOrderService
+		wantStatusCode   int
+		wantResponseBody string
+	}{
+		{
+			name: "Orders By Outcome",
+			body: `{"orders":["354188083613","12345678903","79927398713"]}`,
+			mockOrderService: func() *MockOrderService {
+				m := &MockOrderService{}
+				m.On("RequeueDeadLetterOrders", mock.Anything, []string{"354188083613", "12345678903", "79927398713"}).
+					Return(&service.BatchRetryResult{
+						Retried: []string{"354188083613"},
+						Skipped: []string{"12345678903"},
+						Missing: []string{"79927398713"},
+					}, nil)
+				return m
+			},
+			wantStatusCode:   http.StatusOK,
+			wantResponseBody: `{"retried":["354188083613"],"skipped":["12345678903"],"missing":["79927398713"]}`,
+		},
+		{
+			name:             "Empty Batch",
+			body:             `{"orders":[]}`,
+			mockOrderService: func() *MockOrderService { return &MockOrderService{} },
+			wantStatusCode:   http.StatusBadRequest,
+			wantResponseBody: `{"code":400,"message":"Batch must hold from 1 to 100 orders"}`,
+		},
+		{
+			name: "Error In Requeue",
+			body: `{"orders":["354188083613"]}`,
+			mockOrderService: func() *MockOrderService {
+				m := &MockOrderService{}
+				m.On("RequeueDeadLetterOrders", mock.Anything, []string{"354188083613"}).
+					Return((*service.BatchRetryResult)(nil), errors.New("db down"))
+				return m
+			},
+			wantStatusCode:   http.StatusInternalServerError,
+			wantResponseBody: `{"code":500,"message":"Internal Server Error"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/admin/orders/dead-letter/requeue", strings.NewReader(tt.body))
+			w := httptest.NewRecorder()
+
+			orderService := tt.mockOrderService()
+			ah := &AdminHandler{
+				orderService:   orderService,
+				contextTimeout: 5 * time.Second,
+			}
+			ah.RequeueDeadLetterOrders(w, req)
+
+			assert.Equal(t, tt.wantStatusCode, w.Code)
+			assert.JSONEq(t, tt.wantResponseBody, w.Body.String())
+			if tt.wantStatusCode == http.StatusBadRequest {
+				orderService.AssertNotCalled(t, "RequeueDeadLetterOrders", mock.Anything, mock.Anything)
+			}
+		})
+	}
+}
+
+func TestAdminHandler_ReverseWithdrawal(t *testing.T) {
+	user := &repository.User{UUID: uuid.New(), Login: "alice"}
+	tests := []struct {
+		name                  string
+		mockWithdrawalService func() *MockWithdrawalService
+		wantStatusCode        int
+		wantResponseBody      string
+	}{
+		{
+			name: "Withdrawal Reversed",
+			mockWithdrawalService: func() *MockWithdrawalService {
+				m := &MockWithdrawalService{}
+				m.On("ReverseWithdrawal", mock.Anything, &user.UUID, "354188083613").Return(nil)
+				return m
+			},
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			name: "Withdrawal Not Found",
+			mockWithdrawalService: func() *MockWithdrawalService {
+				m := &MockWithdrawalService{}
+				err := appErrors.NewWithCode(errors.New("withdrawal not found"), "Withdrawal not found", http.StatusNotFound)
+				m.On("ReverseWithdrawal", mock.Anything, &user.UUID, "354188083613").Return(err)
+				return m
+			},
+			wantStatusCode:   http.StatusNotFound,
+			wantResponseBody: `{"code":404,"message":"Withdrawal not found"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/admin/withdrawals/alice/354188083613/reverse", nil)
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("login", user.Login)
+			rctx.URLParams.Add("order", "354188083613")
+			req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+			w := httptest.NewRecorder()
+
+			us := &MockUserService{}
+			us.On("GetByUserLogin", mock.Anything, user.Login).Return(user, nil)
+			ws := tt.mockWithdrawalService()
+			ah := &AdminHandler{
+				userService:       us,
+				withdrawalService: ws,
+				contextTimeout:    5 * time.Second,
+			}
+			ah.ReverseWithdrawal(w, req)
+
+			assert.Equal(t, tt.wantStatusCode, w.Code)
+			if tt.wantResponseBody != "" {
+				assert.JSONEq(t, tt.wantResponseBody, w.Body.String())
+			} else {
+				assert.Empty(t, w.Body.String())
+			}
+			ws.AssertExpectations(t)
+		})
+	}
+}
+
+func TestAdminHandler_CorrectAccrual(t *testing.T) {
+	correctedAt := time.Date(2021, 1, 2, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name             string
+		body             string
+		mockOrderService func() *MockOrderService
+		wantStatusCode   int
+		wantResponseBody string
+	}{
+		{
+			name: "Accrual Raised",
+			body: `{"accrual":120.5}`,
+			mockOrderService: func() *MockOrderService {
+				m := &MockOrderService{}
+				m.On("CorrectAccrual", mock.Anything, "354188083613", repository.Cents(120_50)).Return(&repository.AccrualCorrection{
+					OrderID: "354188083613", PreviousAccrual: 100_00, Accrual: 120_50, Delta: 20_50, CreatedAt: correctedAt,
+				}, nil)
+				return m
+			},
+			wantStatusCode: http.StatusOK,
+			wantResponseBody: `{"order":"354188083613","previous_accrual":100,"accrual":120.5,"delta":20.5,
+				"corrected_at":"2021-01-02T00:00:00Z"}`,
+		},
+		{
+			name: "Accrual Lowered",
+			body: `{"accrual":0}`,
+			mockOrderService: func() *MockOrderService {
+				m := &MockOrderService{}
+				m.On("CorrectAccrual", mock.Anything, "354188083613", repository.Cents(0)).Return(&repository.AccrualCorrection{
+					OrderID: "354188083613", PreviousAccrual: 100_00, Accrual: 0, Delta: -100_00, CreatedAt: correctedAt,
+				}, nil)
+				return m
+			},
+			wantStatusCode: http.StatusOK,
+			wantResponseBody: `{"order":"354188083613","previous_accrual":100,"accrual":0,"delta":-100,
+				"corrected_at":"2021-01-02T00:00:00Z"}`,
+		},
+		{
+			name:             "Negative Accrual",
+			body:             `{"accrual":-1}`,
+			mockOrderService: func() *MockOrderService { return &MockOrderService{} },
+			wantStatusCode:   http.StatusBadRequest,
+			wantResponseBody: `{"code":400,"message":"Invalid accrual"}`,
+		},
+		{
+			name: "Balance Would Become Negative",
+			body: `{"accrual":0}`,
+			mockOrderService: func() *MockOrderService {
+				m := &MockOrderService{}
+				err := appErrors.NewWithCode(errors.New("negative balance"), "Correction exceeds the current balance", http.StatusConflict)
+				m.On("CorrectAccrual", mock.Anything, "354188083613", repository.Cents(0)).Return((*repository.AccrualCorrection)(nil), err)
+				return m
+			},
+			wantStatusCode:   http.StatusConflict,
+			wantResponseBody: `{"code":409,"message":"Correction exceeds the current balance"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/admin/orders/354188083613/accrual", strings.NewReader(tt.body))
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("number", "354188083613")
+			req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+			w := httptest.NewRecorder()
+
+			orderService := tt.mockOrderService()
+			ah := &AdminHandler{
+				orderService:   orderService,
+				contextTimeout: 5 * time.Second,
+			}
+			ah.CorrectAccrual(w, req)
+
+			assert.Equal(t, tt.wantStatusCode, w.Code)
+			assert.JSONEq(t, tt.wantResponseBody, w.Body.String())
+			if tt.wantStatusCode == http.StatusBadRequest {
+				orderService.AssertNotCalled(t, "CorrectAccrual", mock.Anything, mock.Anything, mock.Anything)
+			}
+		})
+	}
+}
@@ -2,33 +2,61 @@ package handlers
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/ShiraazMoollatjie/goluhn"
+	"github.com/go-chi/chi/v5"
 	appContext "github.com/ujwegh/gophermart/internal/app/context"
 	appErrors "github.com/ujwegh/gophermart/internal/app/errors"
 	"github.com/ujwegh/gophermart/internal/app/repository"
 	"github.com/ujwegh/gophermart/internal/app/service"
 	"io"
+	"math/big"
 	"net/http"
+	"strconv"
 	"time"
 )
 
+const errMsgInvalidSum = "Invalid withdrawal sum"
+
+// maxIdempotencyKeyLength matches the withdrawals.idempotency_key and users.registration_key columns.
+const maxIdempotencyKeyLength = 128
+
+const (
+	withdrawalStatusWithdrawn = "WITHDRAWN"
+	withdrawalStatusReversed  = "REVERSED"
+)
+
 type (
 	BalanceHandler struct {
 		walletService     service.WalletService
 		withdrawalService service.WithdrawalService
+		orderService      service.OrderService
 		contextTimeout    time.Duration
+		pagination        Pagination
+		// pendingAccruals makes include_pending report the pending accruals, they are left out otherwise
+		pendingAccruals bool
 	}
 
 	//easyjson:json
 	BalanceDto struct {
 		CurrentBalance   float64 `json:"current"`
 		WithdrawnBalance float64 `json:"withdrawn"`
+		// Pending is only reported with include_pending=true
+		Pending *float64 `json:"pending,omitempty"`
+	}
+	//easyjson:json
+	WalletDTO struct {
+		Credits          float64 `json:"credits"`
+		Debits           float64 `json:"debits"`
+		CurrentBalance   float64 `json:"current"`
+		WithdrawnBalance float64 `json:"withdrawn"`
 	}
 	//easyjson:json
 	WithdrawRequestDTO struct {
-		Order string  `json:"order"`
-		Sum   float64 `json:"sum"`
+		Order string      `json:"order"`
+		Sum   json.Number `json:"sum" swaggertype:"number"`
 	}
 	//easyjson:json
 	WithdrawalDTO struct {
@@ -38,50 +66,139 @@ type (
 	}
 	//easyjson:json
 	WithdrawalDtoSlice []WithdrawalDTO
+	//easyjson:json
+	WithdrawResponseDTO struct {
+		ID             int64     `json:"id"`
+		OrderID        string    `json:"order"`
+		Sum            float64   `json:"sum"`
+		ProcessedAt    time.Time `json:"processed_at"`
+		CurrentBalance float64   `json:"current"`
+	}
+	//easyjson:json
+	WithdrawalOutcomeDTO struct {
+		Key         string     `json:"key"`
+		OrderID     string     `json:"order"`
+		Sum         float64    `json:"sum"`
+		Status      string     `json:"status" enums:"WITHDRAWN,REVERSED"`
+		ProcessedAt time.Time  `json:"processed_at"`
+		ReversedAt  *time.Time `json:"reversed_at,omitempty"`
+	}
 )
 
-func NewBalanceHandler(contextTimeoutSec int, walletService service.WalletService, withdrawalService service.WithdrawalService) *BalanceHandler {
+func NewBalanceHandler(contextTimeoutSec int, pagination Pagination, walletService service.WalletService,
+	withdrawalService service.WithdrawalService, orderService service.OrderService) *BalanceHandler {
 	return &BalanceHandler{
 		walletService:     walletService,
 		withdrawalService: withdrawalService,
+		orderService:      orderService,
 		contextTimeout:    time.Duration(contextTimeoutSec) * time.Second,
+		pagination:        pagination,
+		pendingAccruals:   true,
 	}
 }
 
+// WithPendingAccruals switches the pending accruals of include_pending on or off.
+func (bh *BalanceHandler) WithPendingAccruals(enabled bool) *BalanceHandler {
+	bh.pendingAccruals = enabled
+	return bh
+}
+
 // GetBalance godoc
 // @Summary Getting the user's current balance
 // @Description The handler returns the current amount of loyalty points and the total amount of points
-// withdrawn during the entire registration period for an authorized user.
+// @Description withdrawn during the entire registration period for an authorized user.
+// @Description With include_pending=true it also returns the accruals of orders that are still PROCESSING,
+// @Description which are credited once the orders are PROCESSED, unless the pending-balance feature is off.
 // @Tags balance
 // @Produce json
+// @Param include_pending query bool false "Also return the pending accruals"
 // @Success 200 {object} BalanceDto "Current and withdrawn loyalty points"
+// @Failure 400 {object} ErrorResponse "Bad Request - Invalid include_pending parameter"
 // @Failure 401 {object} ErrorResponse "Unauthorized - The user is not authorized"
 // @Failure 500 {object} ErrorResponse "Internal Server Error"
 // @Security ApiKeyAuth
 // @Router /api/user/balance [get]
 func (bh *BalanceHandler) GetBalance(w http.ResponseWriter, r *http.Request) {
-	ctx, cancel := context.WithTimeout(context.Background(), bh.contextTimeout)
+	ctx, cancel := context.WithTimeout(r.Context(), bh.contextTimeout)
 	defer cancel()
 	userUID := appContext.UserUID(r.Context())
 
+	includePending, err := parseIncludePending(r)
+	if err != nil {
+		PrepareError(w, r, err)
+		return
+	}
+
 	balance, err := bh.walletService.GetBalance(ctx, userUID)
 	if err != nil {
-		PrepareError(w, err)
+		PrepareError(w, r, err)
 		return
 	}
 	balanceDto := BalanceDto{
 		CurrentBalance:   balance.CurrentBalance,
 		WithdrawnBalance: balance.WithdrawnBalance,
 	}
+	if includePending && bh.pendingAccruals {
+		pending, err := bh.orderService.GetPendingAccruals(ctx, userUID)
+		if err != nil {
+			PrepareError(w, r, err)
+			return
+		}
+		balanceDto.Pending = &pending
+	}
 	json, err := balanceDto.MarshalJSON()
 	if err != nil {
-		PrepareError(w, fmt.Errorf("unable to marshal json: %w", err))
+		PrepareError(w, r, fmt.Errorf("unable to marshal json: %w", err))
 		return
 	}
 
 	err = appContext.GetContextError(ctx)
 	if err != nil {
-		PrepareError(w, err)
+		PrepareError(w, r, err)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(json)
+}
+
+// GetWallet godoc
+// @Summary Getting the user's wallet totals
+// @Description The handler returns the raw credited and debited totals of the authorized user's wallet
+// @Description along with the current and withdrawn balance derived from them.
+// @Tags balance
+// @Produce json
+// @Success 200 {object} WalletDTO "Credits, debits, current and withdrawn loyalty points"
+// @Failure 401 {object} ErrorResponse "Unauthorized - The user is not authorized"
+// @Failure 500 {object} ErrorResponse "Internal Server Error"
+// @Security ApiKeyAuth
+// @Router /api/user/wallet [get]
+func (bh *BalanceHandler) GetWallet(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), bh.contextTimeout)
+	defer cancel()
+	userUID := appContext.UserUID(r.Context())
+
+	wallet, err := bh.walletService.GetWallet(ctx, userUID)
+	if err != nil {
+		PrepareError(w, r, err)
+		return
+	}
+	walletDto := WalletDTO{
+		Credits:          wallet.Credits,
+		Debits:           wallet.Debits,
+		CurrentBalance:   wallet.Credits - wallet.Debits,
+		WithdrawnBalance: wallet.Debits,
+	}
+	json, err := walletDto.MarshalJSON()
+	if err != nil {
+		PrepareError(w, r, fmt.Errorf("unable to marshal json: %w", err))
+		return
+	}
+
+	err = appContext.GetContextError(ctx)
+	if err != nil {
+		PrepareError(w, r, err)
 		return
 	}
 
@@ -93,81 +210,230 @@ func (bh *BalanceHandler) GetBalance(w http.ResponseWriter, r *http.Request) {
 // Withdraw godoc
 // @Summary Request for debiting funds
 // @Description The handler allows an authorized user to debit points from their account for a hypothetical new order.
+// @Description The sum must be positive and have at most two decimal places.
+// @Description Send an Idempotency-Key header to make retries safe: repeating the withdrawal with the same key,
+// @Description order and sum succeeds without debiting again, GET /api/user/withdrawals/by-key/{key} returns its outcome.
+// @Description The response confirms the withdrawal and reports the balance left after it. Earlier versions answered
+// @Description with an empty body, clients that only check the status code keep working.
 // @Tags balance
 // @Accept json
 // @Produce json
 // @Param withdrawal body WithdrawRequestDTO true "Withdrawal Request"
-// @Success 200 "Successful processing of the request"
-// @Failure 400 {object} ErrorResponse "Bad Request - Unable to read body or parse body"
+// @Param Idempotency-Key header string false "Client chosen key of at most 128 characters that makes retries of the withdrawal safe"
+// @Success 200 {object} WithdrawResponseDTO "The withdrawal and the current balance, a retry with the same Idempotency-Key returns the original withdrawal"
+// @Failure 400 {object} ErrorResponse "Bad Request - Unable to read body or parse body, invalid sum or Idempotency-Key"
 // @Failure 401 {object} ErrorResponse "Unauthorized - The user is not authorized"
 // @Failure 402 {object} ErrorResponse "Payment Required - Insufficient funds in the account"
-// @Failure 422 {object} ErrorResponse "Unprocessable Entity - Incorrect order number format"
+// @Failure 409 {object} ErrorResponse "Conflict - A withdrawal for the order already exists or the order was uploaded by another user"
+// @Failure 415 {object} ErrorResponse "Unsupported Media Type - The body is not JSON"
+// @Failure 422 {object} ErrorResponse "Unprocessable Entity - Incorrect order number format, or the Idempotency-Key was used for another withdrawal"
 // @Failure 500 {object} ErrorResponse "Internal Server Error"
 // @Security ApiKeyAuth
 // @Router /api/user/balance/withdraw [post]
 func (bh *BalanceHandler) Withdraw(w http.ResponseWriter, r *http.Request) {
-	ctx, cancel := context.WithTimeout(context.Background(), bh.contextTimeout)
+	ctx, cancel := context.WithTimeout(r.Context(), bh.contextTimeout)
 	defer cancel()
 	userUID := appContext.UserUID(r.Context())
 
+	if err := requireJSON(r); err != nil {
+		PrepareError(w, r, err)
+		return
+	}
+
+	idempotencyKey, err := validateIdempotencyKey(r.Header.Get("Idempotency-Key"))
+	if err != nil {
+		PrepareError(w, r, err)
+		return
+	}
+
 	body, err := io.ReadAll(r.Body)
 	if err != nil {
 		err = appErrors.NewWithCode(err, errMsgEnableReadBody, http.StatusBadRequest)
-		PrepareError(w, err)
+		PrepareError(w, r, err)
 		return
 	}
 
 	request := WithdrawRequestDTO{}
-	err = request.UnmarshalJSON(body)
+	err = decodeStrict(body, (*strictWithdrawRequestDTO)(&request))
+	if err != nil {
+		PrepareError(w, r, err)
+		return
+	}
+
+	cents, err := parseCents(request.Sum)
 	if err != nil {
-		err = appErrors.NewWithCode(err, "Unable to parse body", http.StatusBadRequest)
-		PrepareError(w, err)
+		err = appErrors.NewWithCode(err, errMsgInvalidSum, http.StatusBadRequest)
+		PrepareError(w, r, err)
 		return
 	}
 
 	err = goluhn.Validate(request.Order)
 	if err != nil {
 		err = appErrors.NewWithCode(err, "Invalid order ID", http.StatusUnprocessableEntity)
-		PrepareError(w, err)
+		PrepareError(w, r, err)
+		return
+	}
+	// check the deadline before writing anything: once the withdrawal is committed, report success
+	err = appContext.GetContextError(ctx)
+	if err != nil {
+		PrepareError(w, r, err)
+		return
+	}
+	var receipt *service.WithdrawalReceipt
+	if idempotencyKey != "" {
+		receipt, err = bh.withdrawalService.CreateWithdrawalWithKey(ctx, userUID, idempotencyKey, request.Order, cents)
+	} else {
+		receipt, err = bh.withdrawalService.CreateWithdrawal(ctx, userUID, request.Order, cents)
+	}
+	if err != nil {
+		PrepareError(w, r, err)
 		return
 	}
-	err = bh.withdrawalService.CreateWithdrawal(ctx, userUID, request.Order, request.Sum)
+
+	response := WithdrawResponseDTO{
+		ID:             receipt.Withdrawal.ID,
+		OrderID:        receipt.Withdrawal.OrderID,
+		Sum:            receipt.Withdrawal.Amount.Float64(),
+		ProcessedAt:    receipt.Withdrawal.CreatedAt,
+		CurrentBalance: receipt.Current,
+	}
+	rawBytes, err := response.MarshalJSON()
 	if err != nil {
-		PrepareError(w, err)
+		PrepareError(w, r, fmt.Errorf("unable to marshal response: %w", err))
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(rawBytes)
+}
+
+// GetWithdrawalByKey godoc
+// @Summary Receiving the outcome of a withdrawal by its idempotency key
+// @Description The handler returns the withdrawal the authorized user made with the Idempotency-Key,
+// @Description so a client unsure whether its request went through can check instead of retrying.
+// @Description The status is REVERSED once the withdrawal has been refunded.
+// @Tags withdrawals
+// @Produce json
+// @Param key path string true "Idempotency-Key the withdrawal was requested with"
+// @Success 200 {object} WithdrawalOutcomeDTO "The withdrawal made with the key"
+// @Failure 400 {object} ErrorResponse "Bad Request - Invalid key"
+// @Failure 401 {object} ErrorResponse "Unauthorized - The user is not authorized"
+// @Failure 404 {object} ErrorResponse "Not Found - No withdrawal was made with the key"
+// @Failure 500 {object} ErrorResponse "Internal Server Error"
+// @Security ApiKeyAuth
+// @Router /api/user/withdrawals/by-key/{key} [get]
+func (bh *BalanceHandler) GetWithdrawalByKey(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), bh.contextTimeout)
+	defer cancel()
+	userUID := appContext.UserUID(r.Context())
+
+	// chi has decoded the param already
+	key := chi.URLParam(r, "key")
+	var err error
+	if key == "" {
+		err = errors.New("empty idempotency key")
+	} else {
+		key, err = validateIdempotencyKey(key)
+	}
+	if err != nil {
+		PrepareError(w, r, appErrors.NewWithCode(err, "Invalid idempotency key", http.StatusBadRequest))
+		return
+	}
+
+	outcome, err := bh.withdrawalService.GetWithdrawalOutcome(ctx, userUID, key)
+	if err != nil {
+		PrepareError(w, r, err)
+		return
+	}
+	response := WithdrawalOutcomeDTO{
+		Key:         key,
+		OrderID:     outcome.OrderID,
+		Sum:         outcome.Amount.Float64(),
+		Status:      withdrawalStatusWithdrawn,
+		ProcessedAt: outcome.CreatedAt,
+		ReversedAt:  outcome.ReversedAt,
+	}
+	if outcome.ReversedAt != nil {
+		response.Status = withdrawalStatusReversed
+	}
+	rawBytes, err := response.MarshalJSON()
+	if err != nil {
+		PrepareError(w, r, fmt.Errorf("unable to marshal response: %w", err))
 		return
 	}
 
 	err = appContext.GetContextError(ctx)
 	if err != nil {
-		PrepareError(w, err)
+		PrepareError(w, r, err)
 		return
 	}
 
+	w.Header().Add("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
+	w.Write(rawBytes)
+}
+
+// validateIdempotencyKey rejects keys that don't fit the idempotency key columns. An empty key means none was sent.
+func validateIdempotencyKey(key string) (string, error) {
+	if len(key) > maxIdempotencyKeyLength {
+		msg := fmt.Sprintf("Idempotency-Key must be at most %d characters", maxIdempotencyKeyLength)
+		return "", appErrors.NewWithCode(errors.New(msg), msg, http.StatusBadRequest)
+	}
+	return key, nil
 }
 
 // GetWithdrawals godoc
 // @Summary Receiving information about the withdrawal of funds
 // @Description The handler returns information about the withdrawal of funds,
-// sorted by the time of withdrawal from oldest to newest for an authorized user.
+// @Description sorted by the time of withdrawal from oldest to newest for an authorized user.
+// @Description Pass sort=desc to get the newest withdrawals first. Reversed withdrawals are left out, as they are
+// @Description from the withdrawn balance.
+// @Description The X-Total-Count header holds the number of withdrawals of the user across all pages.
 // @Tags withdrawals
 // @Produce json
+// @Param limit query int false "Page size, clamped to the configured maximum, all withdrawals are listed without it"
+// @Param offset query int false "Number of withdrawals to skip"
+// @Param sort query string false "Order by withdrawal time, asc (default) or desc" Enums(asc, desc)
 // @Success 200 {array} WithdrawalDTO "List of withdrawals with details"
+// @Header 200,204 {int} X-Total-Count "Number of withdrawals across all pages"
 // @Success 204 "No withdrawals to display"
+// @Failure 400 {object} ErrorResponse "Bad Request - Invalid pagination or sort parameters, or an unknown parameter"
 // @Failure 401 {object} ErrorResponse "Unauthorized - The user is not authorized"
 // @Failure 500 {object} ErrorResponse "Internal Server Error"
 // @Security ApiKeyAuth
 // @Router /api/user/withdrawals [get]
 func (bh *BalanceHandler) GetWithdrawals(w http.ResponseWriter, r *http.Request) {
-	ctx, cancel := context.WithTimeout(context.Background(), bh.contextTimeout)
+	ctx, cancel := context.WithTimeout(r.Context(), bh.contextTimeout)
 	defer cancel()
+	if err := checkQueryParams(r, "limit", "offset", "sort"); err != nil {
+		PrepareError(w, r, err)
+		return
+	}
 	userUID := appContext.UserUID(r.Context())
+	page, err := bh.pagination.ParseOptionalPage(r)
+	if err != nil {
+		PrepareError(w, r, err)
+		return
+	}
+
+	direction, err := parseSortDirection(r)
+	if err != nil {
+		PrepareError(w, r, err)
+		return
+	}
 
-	withdrawals, err := bh.withdrawalService.GetWithdrawals(ctx, userUID)
+	withdrawals, err := bh.withdrawalService.GetWithdrawalsSorted(ctx, userUID, page.RowLimit(), page.Offset, direction)
+	if err != nil {
+		PrepareError(w, r, err)
+		return
+	}
+	total, err := bh.withdrawalService.CountWithdrawals(ctx, userUID)
 	if err != nil {
-		PrepareError(w, err)
+		PrepareError(w, r, err)
 		return
 	}
+	w.Header().Set("X-Total-Count", strconv.Itoa(total))
 	if len(*withdrawals) == 0 {
 		w.WriteHeader(http.StatusNoContent)
 		fmt.Fprintf(w, "%s", "[]")
@@ -176,13 +442,13 @@ func (bh *BalanceHandler) GetWithdrawals(w http.ResponseWriter, r *http.Request)
 	response := bh.mapWithdrawalsToWithdrawalDtoSlice(withdrawals)
 	rawBytes, err := response.MarshalJSON()
 	if err != nil {
-		PrepareError(w, fmt.Errorf("unable to marshal response: %w", err))
+		PrepareError(w, r, fmt.Errorf("unable to marshal response: %w", err))
 		return
 	}
 
 	err = appContext.GetContextError(ctx)
 	if err != nil {
-		PrepareError(w, err)
+		PrepareError(w, r, err)
 		return
 	}
 
@@ -197,10 +463,66 @@ func (bh *BalanceHandler) mapWithdrawalsToWithdrawalDtoSlice(slice *[]repository
 	for _, item := range *slice {
 		responseItem := WithdrawalDTO{
 			OrderID:     item.OrderID,
-			Sum:         item.Amount,
+			Sum:         item.Amount.Float64(),
 			ProcessedAt: item.CreatedAt,
 		}
 		responseSlice = append(responseSlice, responseItem)
 	}
 	return responseSlice
 }
+
+// parseCents converts a decimal amount to integer cents without going through float64.
+// Amounts with more than two significant decimal places and non-positive amounts are rejected.
+func parseCents(amount json.Number) (repository.Cents, error) {
+	cents, err := parseNonNegativeCents(amount)
+	if err != nil {
+		return 0, err
+	}
+	if cents == 0 {
+		return 0, errors.New("amount must be positive")
+	}
+	return cents, nil
+}
+
+// parseNonNegativeCents is parseCents for amounts that may be zero.
+func parseNonNegativeCents(amount json.Number) (repository.Cents, error) {
+	value, ok := new(big.Rat).SetString(amount.String())
+	if !ok {
+		return 0, fmt.Errorf("parse amount %q", amount)
+	}
+	if value.Sign() < 0 {
+		return 0, errors.New("amount must not be negative")
+	}
+	cents := new(big.Rat).Mul(value, big.NewRat(100, 1))
+	if !cents.IsInt() {
+		return 0, fmt.Errorf("amount %s has more than two decimal places", amount)
+	}
+	if !cents.Num().IsInt64() {
+		return 0, fmt.Errorf("amount %s is too large", amount)
+	}
+	return repository.Cents(cents.Num().Int64()), nil
+}
+
+func parseIncludePending(r *http.Request) (bool, error) {
+	raw := r.URL.Query().Get("include_pending")
+	if raw == "" {
+		return false, nil
+	}
+	includePending, err := strconv.ParseBool(raw)
+	if err != nil {
+		return false, appErrors.NewWithCode(err, "Invalid include_pending parameter", http.StatusBadRequest)
+	}
+	return includePending, nil
+}
+
+// parseSortDirection reads the sort query parameter, ascending when it is absent.
+func parseSortDirection(r *http.Request) (repository.SortDirection, error) {
+	switch direction := repository.SortDirection(r.URL.Query().Get("sort")); direction {
+	case "":
+		return repository.SortAsc, nil
+	case repository.SortAsc, repository.SortDesc:
+		return direction, nil
+	}
+	msg := "Invalid sort parameter, use asc or desc"
+	return "", appErrors.NewWithCode(errors.New(msg), msg, http.StatusBadRequest)
+}
@@ -7,6 +7,7 @@ import (
 	easyjson "github.com/mailru/easyjson"
 	jlexer "github.com/mailru/easyjson/jlexer"
 	jwriter "github.com/mailru/easyjson/jwriter"
+	time "time"
 )
 
 // suppress unused package warning
@@ -17,7 +18,120 @@ var (
 	_ easyjson.Marshaler
 )
 
-func easyjsonE77ba387DecodeGithubComUjweghGophermartInternalAppHandlers(in *jlexer.Lexer, out *WithdrawalDtoSlice) {
+func easyjsonE77ba387DecodeGithubComUjweghGophermartInternalAppHandlers(in *jlexer.Lexer, out *WithdrawalOutcomeDTO) {
+	isTopLevel := in.IsStart()
+	if in.IsNull() {
+		if isTopLevel {
+			in.Consumed()
+		}
+		in.Skip()
+		return
+	}
+	in.Delim('{')
+	for !in.IsDelim('}') {
+		key := in.UnsafeFieldName(false)
+		in.WantColon()
+		if in.IsNull() {
+			in.Skip()
+			in.WantComma()
+			continue
+		}
+		switch key {
+		case "key":
+			out.Key = string(in.String())
+		case "order":
+			out.OrderID = string(in.String())
+		case "sum":
+			out.Sum = float64(in.Float64())
+		case "status":
+			out.Status = string(in.String())
+		case "processed_at":
+			if data := in.Raw(); in.Ok() {
+				in.AddError((out.ProcessedAt).UnmarshalJSON(data))
+			}
+		case "reversed_at":
+			if in.IsNull() {
+				in.Skip()
+				out.ReversedAt = nil
+			} else {
+				if out.ReversedAt == nil {
+					out.ReversedAt = new(time.Time)
+				}
+				if data := in.Raw(); in.Ok() {
+					in.AddError((*out.ReversedAt).UnmarshalJSON(data))
+				}
+			}
+		default:
+			in.SkipRecursive()
+		}
+		in.WantComma()
+	}
+	in.Delim('}')
+	if isTopLevel {
+		in.Consumed()
+	}
+}
+func easyjsonE77ba387EncodeGithubComUjweghGophermartInternalAppHandlers(out *jwriter.Writer, in WithdrawalOutcomeDTO) {
+	out.RawByte('{')
+	first := true
+	_ = first
+	{
+		const prefix string = ",\"key\":"
+		out.RawString(prefix[1:])
+		out.String(string(in.Key))
+	}
+	{
+		const prefix string = ",\"order\":"
+		out.RawString(prefix)
+		out.String(string(in.OrderID))
+	}
+	{
+		const prefix string = ",\"sum\":"
+		out.RawString(prefix)
+		out.Float64(float64(in.Sum))
+	}
+	{
+		const prefix string = ",\"status\":"
+		out.RawString(prefix)
+		out.String(string(in.Status))
+	}
+	{
+		const prefix string = ",\"processed_at\":"
+		out.RawString(prefix)
+		out.Raw((in.ProcessedAt).MarshalJSON())
+	}
+	if in.ReversedAt != nil {
+		const prefix string = ",\"reversed_at\":"
+		out.RawString(prefix)
+		out.Raw((*in.ReversedAt).MarshalJSON())
+	}
+	out.RawByte('}')
+}
+
+// MarshalJSON supports json.Marshaler interface
+func (v WithdrawalOutcomeDTO) MarshalJSON() ([]byte, error) {
+	w := jwriter.Writer{}
+	easyjsonE77ba387EncodeGithubComUjweghGophermartInternalAppHandlers(&w, v)
+	return w.Buffer.BuildBytes(), w.Error
+}
+
+// MarshalEasyJSON supports easyjson.Marshaler interface
+func (v WithdrawalOutcomeDTO) MarshalEasyJSON(w *jwriter.Writer) {
+	easyjsonE77ba387EncodeGithubComUjweghGophermartInternalAppHandlers(w, v)
+}
+
+// UnmarshalJSON supports json.Unmarshaler interface
+func (v *WithdrawalOutcomeDTO) UnmarshalJSON(data []byte) error {
+	r := jlexer.Lexer{Data: data}
+	easyjsonE77ba387DecodeGithubComUjweghGophermartInternalAppHandlers(&r, v)
+	return r.Error()
+}
+
+// UnmarshalEasyJSON supports easyjson.Unmarshaler interface
+func (v *WithdrawalOutcomeDTO) UnmarshalEasyJSON(l *jlexer.Lexer) {
+	easyjsonE77ba387DecodeGithubComUjweghGophermartInternalAppHandlers(l, v)
+}
+func easyjsonE77ba387DecodeGithubComUjweghGophermartInternalAppHandlers1(in *jlexer.Lexer, out *WithdrawalDtoSlice) {
 	isTopLevel := in.IsStart()
 	if in.IsNull() {
 		in.Skip()
@@ -45,7 +159,7 @@ func easyjsonE77ba387DecodeGithubComUjweghGophermartInternalAppHandlers(in *jlex
 		in.Consumed()
 	}
 }
-func easyjsonE77ba387EncodeGithubComUjweghGophermartInternalAppHandlers(out *jwriter.Writer, in WithdrawalDtoSlice) {
+func easyjsonE77ba387EncodeGithubComUjweghGophermartInternalAppHandlers1(out *jwriter.Writer, in WithdrawalDtoSlice) {
 	if in == nil && (out.Flags&jwriter.NilSliceAsEmpty) == 0 {
 		out.RawString("null")
 	} else {
@@ -63,27 +177,27 @@ func easyjsonE77ba387EncodeGithubComUjweghGophermartInternalAppHandlers(out *jwr
 // MarshalJSON supports json.Marshaler interface
 func (v WithdrawalDtoSlice) MarshalJSON() ([]byte, error) {
 	w := jwriter.Writer{}
-	easyjsonE77ba387EncodeGithubComUjweghGophermartInternalAppHandlers(&w, v)
+	easyjsonE77ba387EncodeGithubComUjweghGophermartInternalAppHandlers1(&w, v)
 	return w.Buffer.BuildBytes(), w.Error
 }
 
 // MarshalEasyJSON supports easyjson.Marshaler interface
 func (v WithdrawalDtoSlice) MarshalEasyJSON(w *jwriter.Writer) {
-	easyjsonE77ba387EncodeGithubComUjweghGophermartInternalAppHandlers(w, v)
+	easyjsonE77ba387EncodeGithubComUjweghGophermartInternalAppHandlers1(w, v)
 }
 
 // UnmarshalJSON supports json.Unmarshaler interface
 func (v *WithdrawalDtoSlice) UnmarshalJSON(data []byte) error {
 	r := jlexer.Lexer{Data: data}
-	easyjsonE77ba387DecodeGithubComUjweghGophermartInternalAppHandlers(&r, v)
+	easyjsonE77ba387DecodeGithubComUjweghGophermartInternalAppHandlers1(&r, v)
 	return r.Error()
 }
 
 // UnmarshalEasyJSON supports easyjson.Unmarshaler interface
 func (v *WithdrawalDtoSlice) UnmarshalEasyJSON(l *jlexer.Lexer) {
-	easyjsonE77ba387DecodeGithubComUjweghGophermartInternalAppHandlers(l, v)
+	easyjsonE77ba387DecodeGithubComUjweghGophermartInternalAppHandlers1(l, v)
 }
-func easyjsonE77ba387DecodeGithubComUjweghGophermartInternalAppHandlers1(in *jlexer.Lexer, out *WithdrawalDTO) {
+func easyjsonE77ba387DecodeGithubComUjweghGophermartInternalAppHandlers2(in *jlexer.Lexer, out *WithdrawalDTO) {
 	isTopLevel := in.IsStart()
 	if in.IsNull() {
 		if isTopLevel {
@@ -120,7 +234,7 @@ func easyjsonE77ba387DecodeGithubComUjweghGophermartInternalAppHandlers1(in *jle
 		in.Consumed()
 	}
 }
-func easyjsonE77ba387EncodeGithubComUjweghGophermartInternalAppHandlers1(out *jwriter.Writer, in WithdrawalDTO) {
+func easyjsonE77ba387EncodeGithubComUjweghGophermartInternalAppHandlers2(out *jwriter.Writer, in WithdrawalDTO) {
 	out.RawByte('{')
 	first := true
 	_ = first
@@ -145,27 +259,27 @@ func easyjsonE77ba387EncodeGithubComUjweghGophermartInternalAppHandlers1(out *jw
 // MarshalJSON supports json.Marshaler interface
 func (v WithdrawalDTO) MarshalJSON() ([]byte, error) {
 	w := jwriter.Writer{}
-	easyjsonE77ba387EncodeGithubComUjweghGophermartInternalAppHandlers1(&w, v)
+	easyjsonE77ba387EncodeGithubComUjweghGophermartInternalAppHandlers2(&w, v)
 	return w.Buffer.BuildBytes(), w.Error
 }
 
 // MarshalEasyJSON supports easyjson.Marshaler interface
 func (v WithdrawalDTO) MarshalEasyJSON(w *jwriter.Writer) {
-	easyjsonE77ba387EncodeGithubComUjweghGophermartInternalAppHandlers1(w, v)
+	easyjsonE77ba387EncodeGithubComUjweghGophermartInternalAppHandlers2(w, v)
 }
 
 // UnmarshalJSON supports json.Unmarshaler interface
 func (v *WithdrawalDTO) UnmarshalJSON(data []byte) error {
 	r := jlexer.Lexer{Data: data}
-	easyjsonE77ba387DecodeGithubComUjweghGophermartInternalAppHandlers1(&r, v)
+	easyjsonE77ba387DecodeGithubComUjweghGophermartInternalAppHandlers2(&r, v)
 	return r.Error()
 }
 
 // UnmarshalEasyJSON supports easyjson.Unmarshaler interface
 func (v *WithdrawalDTO) UnmarshalEasyJSON(l *jlexer.Lexer) {
-	easyjsonE77ba387DecodeGithubComUjweghGophermartInternalAppHandlers1(l, v)
+	easyjsonE77ba387DecodeGithubComUjweghGophermartInternalAppHandlers2(l, v)
 }
-func easyjsonE77ba387DecodeGithubComUjweghGophermartInternalAppHandlers2(in *jlexer.Lexer, out *WithdrawRequestDTO) {
+func easyjsonE77ba387DecodeGithubComUjweghGophermartInternalAppHandlers3(in *jlexer.Lexer, out *WithdrawResponseDTO) {
 	isTopLevel := in.IsStart()
 	if in.IsNull() {
 		if isTopLevel {
@@ -184,10 +298,18 @@ func easyjsonE77ba387DecodeGithubComUjweghGophermartInternalAppHandlers2(in *jle
 			continue
 		}
 		switch key {
+		case "id":
+			out.ID = int64(in.Int64())
 		case "order":
-			out.Order = string(in.String())
+			out.OrderID = string(in.String())
 		case "sum":
 			out.Sum = float64(in.Float64())
+		case "processed_at":
+			if data := in.Raw(); in.Ok() {
+				in.AddError((out.ProcessedAt).UnmarshalJSON(data))
+			}
+		case "current":
+			out.CurrentBalance = float64(in.Float64())
 		default:
 			in.SkipRecursive()
 		}
@@ -198,7 +320,95 @@ func easyjsonE77ba387DecodeGithubComUjweghGophermartInternalAppHandlers2(in *jle
 		in.Consumed()
 	}
 }
-func easyjsonE77ba387EncodeGithubComUjweghGophermartInternalAppHandlers2(out *jwriter.Writer, in WithdrawRequestDTO) {
+func easyjsonE77ba387EncodeGithubComUjweghGophermartInternalAppHandlers3(out *jwriter.Writer, in WithdrawResponseDTO) {
+	out.RawByte('{')
+	first := true
+	_ = first
+	{
+		const prefix string = ",\"id\":"
+		out.RawString(prefix[1:])
+		out.Int64(int64(in.ID))
+	}
+	{
+		const prefix string = ",\"order\":"
+		out.RawString(prefix)
+		out.String(string(in.OrderID))
+	}
+	{
+		const prefix string = ",\"sum\":"
+		out.RawString(prefix)
+		out.Float64(float64(in.Sum))
+	}
+	{
+		const prefix string = ",\"processed_at\":"
+		out.RawString(prefix)
+		out.Raw((in.ProcessedAt).MarshalJSON())
+	}
+	{
+		const prefix string = ",\"current\":"
+		out.RawString(prefix)
+		out.Float64(float64(in.CurrentBalance))
+	}
+	out.RawByte('}')
+}
+
+// MarshalJSON supports json.Marshaler interface
+func (v WithdrawResponseDTO) MarshalJSON() ([]byte, error) {
+	w := jwriter.Writer{}
+	easyjsonE77ba387EncodeGithubComUjweghGophermartInternalAppHandlers3(&w, v)
+	return w.Buffer.BuildBytes(), w.Error
+}
+
+// MarshalEasyJSON supports easyjson.Marshaler interface
+func (v WithdrawResponseDTO) MarshalEasyJSON(w *jwriter.Writer) {
+	easyjsonE77ba387EncodeGithubComUjweghGophermartInternalAppHandlers3(w, v)
+}
+
+// UnmarshalJSON supports json.Unmarshaler interface
+func (v *WithdrawResponseDTO) UnmarshalJSON(data []byte) error {
+	r := jlexer.Lexer{Data: data}
+	easyjsonE77ba387DecodeGithubComUjweghGophermartInternalAppHandlers3(&r, v)
+	return r.Error()
+}
+
+// UnmarshalEasyJSON supports easyjson.Unmarshaler interface
+func (v *WithdrawResponseDTO) UnmarshalEasyJSON(l *jlexer.Lexer) {
+	easyjsonE77ba387DecodeGithubComUjweghGophermartInternalAppHandlers3(l, v)
+}
+func easyjsonE77ba387DecodeGithubComUjweghGophermartInternalAppHandlers4(in *jlexer.Lexer, out *WithdrawRequestDTO) {
+	isTopLevel := in.IsStart()
+	if in.IsNull() {
+		if isTopLevel {
+			in.Consumed()
+		}
+		in.Skip()
+		return
+	}
+	in.Delim('{')
+	for !in.IsDelim('}') {
+		key := in.UnsafeFieldName(false)
+		in.WantColon()
+		if in.IsNull() {
+			in.Skip()
+			in.WantComma()
+			continue
+		}
+		switch key {
+		case "order":
+			out.Order = string(in.String())
+		case "sum":
+			out.Sum = in.JsonNumber()
+		default:
+			in.SkipRecursive()
+		}
+		in.WantComma()
+	}
+	in.Delim('}')
+	if isTopLevel {
+		in.Consumed()
+	}
+}
+func easyjsonE77ba387EncodeGithubComUjweghGophermartInternalAppHandlers4(out *jwriter.Writer, in WithdrawRequestDTO) {
 	out.RawByte('{')
 	first := true
 	_ = first
@@ -210,7 +420,7 @@ func easyjsonE77ba387EncodeGithubComUjweghGophermartInternalAppHandlers2(out *jw
 	{
 		const prefix string = ",\"sum\":"
 		out.RawString(prefix)
-		out.Float64(float64(in.Sum))
+		out.String(string(in.Sum))
 	}
 	out.RawByte('}')
 }
@@ -218,27 +428,27 @@ func easyjsonE77ba387EncodeGithubComUjweghGophermartInternalAppHandlers2(out *jw
 // MarshalJSON supports json.Marshaler interface
 func (v WithdrawRequestDTO) MarshalJSON() ([]byte, error) {
 	w := jwriter.Writer{}
-	easyjsonE77ba387EncodeGithubComUjweghGophermartInternalAppHandlers2(&w, v)
+	easyjsonE77ba387EncodeGithubComUjweghGophermartInternalAppHandlers4(&w, v)
 	return w.Buffer.BuildBytes(), w.Error
 }
 
 // MarshalEasyJSON supports easyjson.Marshaler interface
 func (v WithdrawRequestDTO) MarshalEasyJSON(w *jwriter.Writer) {
-	easyjsonE77ba387EncodeGithubComUjweghGophermartInternalAppHandlers2(w, v)
+	easyjsonE77ba387EncodeGithubComUjweghGophermartInternalAppHandlers4(w, v)
 }
 
 // UnmarshalJSON supports json.Unmarshaler interface
 func (v *WithdrawRequestDTO) UnmarshalJSON(data []byte) error {
 	r := jlexer.Lexer{Data: data}
-	easyjsonE77ba387DecodeGithubComUjweghGophermartInternalAppHandlers2(&r, v)
+	easyjsonE77ba387DecodeGithubComUjweghGophermartInternalAppHandlers4(&r, v)
 	return r.Error()
 }
 
 // UnmarshalEasyJSON supports easyjson.Unmarshaler interface
 func (v *WithdrawRequestDTO) UnmarshalEasyJSON(l *jlexer.Lexer) {
-	easyjsonE77ba387DecodeGithubComUjweghGophermartInternalAppHandlers2(l, v)
+	easyjsonE77ba387DecodeGithubComUjweghGophermartInternalAppHandlers4(l, v)
 }
-func easyjsonE77ba387DecodeGithubComUjweghGophermartInternalAppHandlers3(in *jlexer.Lexer, out *BalanceDto) {
+func easyjsonE77ba387DecodeGithubComUjweghGophermartInternalAppHandlers5(in *jlexer.Lexer, out *WalletDTO) {
 	isTopLevel := in.IsStart()
 	if in.IsNull() {
 		if isTopLevel {
@@ -257,6 +467,10 @@ func easyjsonE77ba387DecodeGithubComUjweghGophermartInternalAppHandlers3(in *jle
 			continue
 		}
 		switch key {
+		case "credits":
+			out.Credits = float64(in.Float64())
+		case "debits":
+			out.Debits = float64(in.Float64())
 		case "current":
 			out.CurrentBalance = float64(in.Float64())
 		case "withdrawn":
@@ -271,7 +485,100 @@ func easyjsonE77ba387DecodeGithubComUjweghGophermartInternalAppHandlers3(in *jle
 		in.Consumed()
 	}
 }
-func easyjsonE77ba387EncodeGithubComUjweghGophermartInternalAppHandlers3(out *jwriter.Writer, in BalanceDto) {
+func easyjsonE77ba387EncodeGithubComUjweghGophermartInternalAppHandlers5(out *jwriter.Writer, in WalletDTO) {
+	out.RawByte('{')
+	first := true
+	_ = first
+	{
+		const prefix string = ",\"credits\":"
+		out.RawString(prefix[1:])
+		out.Float64(float64(in.Credits))
+	}
+	{
+		const prefix string = ",\"debits\":"
+		out.RawString(prefix)
+		out.Float64(float64(in.Debits))
+	}
+	{
+		const prefix string = ",\"current\":"
+		out.RawString(prefix)
+		out.Float64(float64(in.CurrentBalance))
+	}
+	{
+		const prefix string = ",\"withdrawn\":"
+		out.RawString(prefix)
+		out.Float64(float64(in.WithdrawnBalance))
+	}
+	out.RawByte('}')
+}
+
+// MarshalJSON supports json.Marshaler interface
+func (v WalletDTO) MarshalJSON() ([]byte, error) {
+	w := jwriter.Writer{}
+	easyjsonE77ba387EncodeGithubComUjweghGophermartInternalAppHandlers5(&w, v)
+	return w.Buffer.BuildBytes(), w.Error
+}
+
+// MarshalEasyJSON supports easyjson.Marshaler interface
+func (v WalletDTO) MarshalEasyJSON(w *jwriter.Writer) {
+	easyjsonE77ba387EncodeGithubComUjweghGophermartInternalAppHandlers5(w, v)
+}
+
+// UnmarshalJSON supports json.Unmarshaler interface
+func (v *WalletDTO) UnmarshalJSON(data []byte) error {
+	r := jlexer.Lexer{Data: data}
+	easyjsonE77ba387DecodeGithubComUjweghGophermartInternalAppHandlers5(&r, v)
+	return r.Error()
+}
+
+// UnmarshalEasyJSON supports easyjson.Unmarshaler interface
+func (v *WalletDTO) UnmarshalEasyJSON(l *jlexer.Lexer) {
+	easyjsonE77ba387DecodeGithubComUjweghGophermartInternalAppHandlers5(l, v)
+}
+func easyjsonE77ba387DecodeGithubComUjweghGophermartInternalAppHandlers6(in *jlexer.Lexer, out *BalanceDto) {
+	isTopLevel := in.IsStart()
+	if in.IsNull() {
+		if isTopLevel {
+			in.Consumed()
+		}
+		in.Skip()
+		return
+	}
+	in.Delim('{')
+	for !in.IsDelim('}') {
+		key := in.UnsafeFieldName(false)
+		in.WantColon()
+		if in.IsNull() {
+			in.Skip()
+			in.WantComma()
+			continue
+		}
+		switch key {
+		case "current":
+			out.CurrentBalance = float64(in.Float64())
+		case "withdrawn":
+			out.WithdrawnBalance = float64(in.Float64())
+		case "pending":
+			if in.IsNull() {
+				in.Skip()
+				out.Pending = nil
+			} else {
+				if out.Pending == nil {
+					out.Pending = new(float64)
+				}
+				*out.Pending = float64(in.Float64())
+			}
+		default:
+			in.SkipRecursive()
+		}
+		in.WantComma()
+	}
+	in.Delim('}')
+	if isTopLevel {
+		in.Consumed()
+	}
+}
+func easyjsonE77ba387EncodeGithubComUjweghGophermartInternalAppHandlers6(out *jwriter.Writer, in BalanceDto) {
 	out.RawByte('{')
 	first := true
 	_ = first
@@ -285,29 +592,34 @@ func easyjsonE77ba387EncodeGithubComUjweghGophermartInternalAppHandlers3(out *jw
 		out.RawString(prefix)
 		out.Float64(float64(in.WithdrawnBalance))
 	}
+	if in.Pending != nil {
+		const prefix string = ",\"pending\":"
+		out.RawString(prefix)
+		out.Float64(float64(*in.Pending))
+	}
 	out.RawByte('}')
 }
 
 // MarshalJSON supports json.Marshaler interface
 func (v BalanceDto) MarshalJSON() ([]byte, error) {
 	w := jwriter.Writer{}
-	easyjsonE77ba387EncodeGithubComUjweghGophermartInternalAppHandlers3(&w, v)
+	easyjsonE77ba387EncodeGithubComUjweghGophermartInternalAppHandlers6(&w, v)
 	return w.Buffer.BuildBytes(), w.Error
 }
 
 // MarshalEasyJSON supports easyjson.Marshaler interface
 func (v BalanceDto) MarshalEasyJSON(w *jwriter.Writer) {
-	easyjsonE77ba387EncodeGithubComUjweghGophermartInternalAppHandlers3(w, v)
+	easyjsonE77ba387EncodeGithubComUjweghGophermartInternalAppHandlers6(w, v)
 }
 
 // UnmarshalJSON supports json.Unmarshaler interface
 func (v *BalanceDto) UnmarshalJSON(data []byte) error {
 	r := jlexer.Lexer{Data: data}
-	easyjsonE77ba387DecodeGithubComUjweghGophermartInternalAppHandlers3(&r, v)
+	easyjsonE77ba387DecodeGithubComUjweghGophermartInternalAppHandlers6(&r, v)
 	return r.Error()
 }
 
 // UnmarshalEasyJSON supports easyjson.Unmarshaler interface
 func (v *BalanceDto) UnmarshalEasyJSON(l *jlexer.Lexer) {
-	easyjsonE77ba387DecodeGithubComUjweghGophermartInternalAppHandlers3(l, v)
+	easyjsonE77ba387DecodeGithubComUjweghGophermartInternalAppHandlers6(l, v)
 }
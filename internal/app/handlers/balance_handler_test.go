@@ -2,16 +2,22 @@ package handlers
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/ujwegh/gophermart/internal/app/config"
 	appContext "github.com/ujwegh/gophermart/internal/app/context"
+	appErrors "github.com/ujwegh/gophermart/internal/app/errors"
 	"github.com/ujwegh/gophermart/internal/app/repository"
 	"github.com/ujwegh/gophermart/internal/app/service"
+	"math"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"strings"
 	"testing"
 	"time"
@@ -30,17 +36,37 @@ func (m *MockWalletService) CreateWallet(ctx context.Context, tx *sqlx.Tx, userU
 	return args.Error(1)
 }
 
+func (m *MockWalletService) EnsureWallet(ctx context.Context, tx *sqlx.Tx, userUID *uuid.UUID) error {
+	args := m.Called(ctx, tx, userUID)
+	return args.Error(0)
+}
+
 func (m *MockWalletService) GetWallet(ctx context.Context, userUID *uuid.UUID) (*repository.Wallet, error) {
 	args := m.Called(ctx, userUID)
 	return args.Get(0).(*repository.Wallet), args.Error(1)
 }
 
-func (m *MockWalletService) Credit(ctx context.Context, tx *sqlx.Tx, userUID *uuid.UUID, amount float64) (*repository.Wallet, error) {
+func (m *MockWalletService) GetWalletForUpdate(ctx context.Context, tx *sqlx.Tx, userUID *uuid.UUID) (*repository.Wallet, error) {
+	args := m.Called(ctx, tx, userUID)
+	return args.Get(0).(*repository.Wallet), args.Error(1)
+}
+
+func (m *MockWalletService) Credit(ctx context.Context, tx *sqlx.Tx, userUID *uuid.UUID, amount repository.Cents) (*repository.Wallet, error) {
 	args := m.Called(ctx, tx, userUID, amount)
 	return args.Get(0).(*repository.Wallet), args.Error(1)
 }
 
-func (m *MockWalletService) Debit(ctx context.Context, tx *sqlx.Tx, userUID *uuid.UUID, amount float64) (*repository.Wallet, error) {
+func (m *MockWalletService) CreditOnce(ctx context.Context, tx *sqlx.Tx, userUID *uuid.UUID, amount repository.Cents, key string) (*repository.Wallet, bool, error) {
+	args := m.Called(ctx, tx, userUID, amount, key)
+	return args.Get(0).(*repository.Wallet), args.Bool(1), args.Error(2)
+}
+
+func (m *MockWalletService) Debit(ctx context.Context, tx *sqlx.Tx, userUID *uuid.UUID, amount repository.Cents) (*repository.Wallet, error) {
+	args := m.Called(ctx, tx, userUID, amount)
+	return args.Get(0).(*repository.Wallet), args.Error(1)
+}
+
+func (m *MockWalletService) Refund(ctx context.Context, tx *sqlx.Tx, userUID *uuid.UUID, amount repository.Cents) (*repository.Wallet, error) {
 	args := m.Called(ctx, tx, userUID, amount)
 	return args.Get(0).(*repository.Wallet), args.Error(1)
 }
@@ -50,13 +76,38 @@ func (m *MockWalletService) GetBalance(ctx context.Context, userUID *uuid.UUID)
 	return args.Get(0).(*service.UserBalance), args.Error(1)
 }
 
-func (m *MockWithdrawalService) CreateWithdrawal(ctx context.Context, userUID *uuid.UUID, order string, sum float64) error {
+func (m *MockWithdrawalService) CreateWithdrawal(ctx context.Context, userUID *uuid.UUID, order string, sum repository.Cents) (*service.WithdrawalReceipt, error) {
 	args := m.Called(ctx, userUID, order, sum)
+	return args.Get(0).(*service.WithdrawalReceipt), args.Error(1)
+}
+
+func (m *MockWithdrawalService) CreateWithdrawalWithKey(ctx context.Context, userUID *uuid.UUID, key string, order string, sum repository.Cents) (*service.WithdrawalReceipt, error) {
+	args := m.Called(ctx, userUID, key, order, sum)
+	return args.Get(0).(*service.WithdrawalReceipt), args.Error(1)
+}
+
+func (m *MockWithdrawalService) GetWithdrawalOutcome(ctx context.Context, userUID *uuid.UUID, key string) (*repository.WithdrawalOutcome, error) {
+	args := m.Called(ctx, userUID, key)
+	return args.Get(0).(*repository.WithdrawalOutcome), args.Error(1)
+}
+
+func (m *MockWithdrawalService) ReverseWithdrawal(ctx context.Context, userUID *uuid.UUID, orderID string) error {
+	args := m.Called(ctx, userUID, orderID)
 	return args.Error(0)
 }
 
-func (m *MockWithdrawalService) GetWithdrawals(ctx context.Context, userUID *uuid.UUID) (*[]repository.Withdrawal, error) {
+func (m *MockWithdrawalService) GetWithdrawalsSorted(ctx context.Context, userUID *uuid.UUID, limit int, offset int, direction repository.SortDirection) (*[]repository.Withdrawal, error) {
+	args := m.Called(ctx, userUID, limit, offset, direction)
+	return args.Get(0).(*[]repository.Withdrawal), args.Error(1)
+}
+
+func (m *MockWithdrawalService) CountWithdrawals(ctx context.Context, userUID *uuid.UUID) (int, error) {
 	args := m.Called(ctx, userUID)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockWithdrawalService) GetWithdrawals(ctx context.Context, userUID *uuid.UUID, limit int, offset int) (*[]repository.Withdrawal, error) {
+	args := m.Called(ctx, userUID, limit, offset)
 	return args.Get(0).(*[]repository.Withdrawal), args.Error(1)
 }
 
@@ -144,10 +195,128 @@ func TestBalanceHandler_GetBalance(t *testing.T) {
 	}
 }
 
+func TestBalanceHandler_GetBalance_IncludePending(t *testing.T) {
+	userUID := uuid.New()
+	tests := []struct {
+		name             string
+		query            string
+		featureOff       bool
+		wantStatusCode   int
+		wantResponseBody string
+	}{
+		{
+			name:             "Pending Accruals Included",
+			query:            "?include_pending=true",
+			wantStatusCode:   http.StatusOK,
+			wantResponseBody: `{"current":100,"withdrawn":50,"pending":42.5}`,
+		},
+		{
+			name:             "Pending Balance Feature Off",
+			query:            "?include_pending=true",
+			featureOff:       true,
+			wantStatusCode:   http.StatusOK,
+			wantResponseBody: `{"current":100,"withdrawn":50}`,
+		},
+		{
+			name:             "Pending Accruals Omitted",
+			query:            "?include_pending=false",
+			wantStatusCode:   http.StatusOK,
+			wantResponseBody: `{"current":100,"withdrawn":50}`,
+		},
+		{
+			name:             "Invalid Include Pending",
+			query:            "?include_pending=maybe",
+			wantStatusCode:   http.StatusBadRequest,
+			wantResponseBody: `{"code":400,"message":"Invalid include_pending parameter"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/api/user/balance"+tt.query, nil)
+			req = req.WithContext(appContext.WithUserUID(req.Context(), &userUID))
+			w := httptest.NewRecorder()
+
+			ws := &MockWalletService{}
+			ws.On("GetBalance", mock.Anything, &userUID).
+				Return(&service.UserBalance{CurrentBalance: 100.0, WithdrawnBalance: 50.0}, nil)
+			os := &MockOrderService{}
+			os.On("GetPendingAccruals", mock.Anything, &userUID).Return(42.5, nil)
+			bh := &BalanceHandler{
+				walletService:   ws,
+				orderService:    os,
+				contextTimeout:  5 * time.Second,
+				pendingAccruals: !tt.featureOff,
+			}
+			bh.GetBalance(w, req)
+
+			assert.Equal(t, tt.wantStatusCode, w.Code)
+			assert.JSONEq(t, tt.wantResponseBody, w.Body.String())
+		})
+	}
+}
+
+func TestBalanceHandler_GetWallet(t *testing.T) {
+	userUID := uuid.New()
+	tests := []struct {
+		name              string
+		mockWalletService func() *MockWalletService
+		contextTimeout    time.Duration
+		wantErr           bool
+		wantStatusCode    int
+		wantResponseBody  string
+	}{
+		{
+			name: "Successful Wallet Retrieval",
+			mockWalletService: func() *MockWalletService {
+				m := &MockWalletService{}
+				wallet := &repository.Wallet{UserUUID: userUID, Credits: 250.5, Debits: 100.25}
+				m.On("GetWallet", mock.Anything, &userUID).Return(wallet, nil)
+				return m
+			},
+			contextTimeout:   5 * time.Second,
+			wantStatusCode:   http.StatusOK,
+			wantResponseBody: `{"credits":250.5,"debits":100.25,"current":150.25,"withdrawn":100.25}`,
+		},
+		{
+			name: "Error in Fetching Wallet",
+			mockWalletService: func() *MockWalletService {
+				m := &MockWalletService{}
+				err := errors.New("internal server error")
+				m.On("GetWallet", mock.Anything, &userUID).Return((*repository.Wallet)(nil), err)
+				return m
+			},
+			contextTimeout:   5 * time.Second,
+			wantErr:          true,
+			wantStatusCode:   http.StatusInternalServerError,
+			wantResponseBody: "{\"code\":500,\"message\":\"Internal Server Error\"}\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest("GET", "/api/user/wallet", nil)
+			assert.NoError(t, err)
+			req = req.WithContext(appContext.WithUserUID(req.Context(), &userUID))
+			w := httptest.NewRecorder()
+
+			bh := &BalanceHandler{
+				walletService:  tt.mockWalletService(),
+				contextTimeout: tt.contextTimeout,
+			}
+			bh.GetWallet(w, req)
+
+			assert.Equal(t, tt.wantStatusCode, w.Code)
+			assert.JSONEq(t, tt.wantResponseBody, w.Body.String())
+		})
+	}
+}
+
 func TestBalanceHandler_GetWithdrawals(t *testing.T) {
 	userUID := uuid.New()
 	tests := []struct {
 		name                  string
+		query                 string
 		mockWithdrawalService func() *MockWithdrawalService
 		contextTimeout        time.Duration
 		userUID               *uuid.UUID
@@ -160,10 +329,10 @@ func TestBalanceHandler_GetWithdrawals(t *testing.T) {
 			mockWithdrawalService: func() *MockWithdrawalService {
 				m := &MockWithdrawalService{}
 				withdrawals := &[]repository.Withdrawal{
-					{OrderID: "order1", Amount: 100.0, CreatedAt: time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)},
-					{OrderID: "order2", Amount: 200.0, CreatedAt: time.Date(2021, 1, 2, 0, 0, 0, 0, time.UTC)},
+					{OrderID: "order1", Amount: 100_00, CreatedAt: time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)},
+					{OrderID: "order2", Amount: 200_00, CreatedAt: time.Date(2021, 1, 2, 0, 0, 0, 0, time.UTC)},
 				}
-				m.On("GetWithdrawals", mock.Anything, mock.Anything).Return(withdrawals, nil)
+				m.On("GetWithdrawalsSorted", mock.Anything, mock.Anything, mock.Anything, mock.Anything, repository.SortAsc).Return(withdrawals, nil)
 				return m
 			},
 			contextTimeout: 5 * time.Second,
@@ -179,7 +348,7 @@ func TestBalanceHandler_GetWithdrawals(t *testing.T) {
 			name: "No Withdrawals Found",
 			mockWithdrawalService: func() *MockWithdrawalService {
 				m := &MockWithdrawalService{}
-				m.On("GetWithdrawals", mock.Anything, mock.Anything).Return(&[]repository.Withdrawal{}, nil)
+				m.On("GetWithdrawalsSorted", mock.Anything, mock.Anything, mock.Anything, mock.Anything, repository.SortAsc).Return(&[]repository.Withdrawal{}, nil)
 				return m
 			},
 			contextTimeout:   5 * time.Second,
@@ -193,7 +362,7 @@ func TestBalanceHandler_GetWithdrawals(t *testing.T) {
 			mockWithdrawalService: func() *MockWithdrawalService {
 				m := &MockWithdrawalService{}
 				err := errors.New("internal server error")
-				m.On("GetWithdrawals", mock.Anything, mock.Anything).Return((*[]repository.Withdrawal)(nil), err)
+				m.On("GetWithdrawalsSorted", mock.Anything, mock.Anything, mock.Anything, mock.Anything, repository.SortAsc).Return((*[]repository.Withdrawal)(nil), err)
 				return m
 			},
 			contextTimeout:   5 * time.Second,
@@ -207,9 +376,9 @@ func TestBalanceHandler_GetWithdrawals(t *testing.T) {
 			mockWithdrawalService: func() *MockWithdrawalService {
 				m := &MockWithdrawalService{}
 				withdrawals := &[]repository.Withdrawal{
-					{OrderID: "order1", Amount: 100.0, CreatedAt: time.Now()},
+					{OrderID: "order1", Amount: 100_00, CreatedAt: time.Now()},
 				}
-				m.On("GetWithdrawals", mock.Anything, mock.Anything).Return(withdrawals, nil)
+				m.On("GetWithdrawalsSorted", mock.Anything, mock.Anything, mock.Anything, mock.Anything, repository.SortAsc).Return(withdrawals, nil)
 				return m
 			},
 			contextTimeout:   0, // 0 seconds timeout to trigger the timeout error
@@ -218,13 +387,68 @@ func TestBalanceHandler_GetWithdrawals(t *testing.T) {
 			wantStatusCode:   http.StatusInternalServerError,
 			wantResponseBody: "{\"code\":500,\"message\":\"Timeout exceeded\"}\n",
 		},
-		// Add more test cases as needed
+		{
+			name:  "Explicit Ascending Order",
+			query: "?sort=asc",
+			mockWithdrawalService: func() *MockWithdrawalService {
+				m := &MockWithdrawalService{}
+				withdrawals := &[]repository.Withdrawal{
+					{OrderID: "order1", Amount: 100_00, CreatedAt: time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)},
+				}
+				m.On("GetWithdrawalsSorted", mock.Anything, mock.Anything, mock.Anything, mock.Anything, repository.SortAsc).Return(withdrawals, nil)
+				return m
+			},
+			contextTimeout:   5 * time.Second,
+			userUID:          &userUID,
+			wantStatusCode:   http.StatusOK,
+			wantResponseBody: `[{"order":"order1","sum":100,"processed_at":"2021-01-01T00:00:00Z"}]`,
+		},
+		{
+			name:  "Descending Order",
+			query: "?sort=desc",
+			mockWithdrawalService: func() *MockWithdrawalService {
+				m := &MockWithdrawalService{}
+				withdrawals := &[]repository.Withdrawal{
+					{OrderID: "order2", Amount: 200_00, CreatedAt: time.Date(2021, 1, 2, 0, 0, 0, 0, time.UTC)},
+					{OrderID: "order1", Amount: 100_00, CreatedAt: time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)},
+				}
+				m.On("GetWithdrawalsSorted", mock.Anything, mock.Anything, mock.Anything, mock.Anything, repository.SortDesc).Return(withdrawals, nil)
+				return m
+			},
+			contextTimeout: 5 * time.Second,
+			userUID:        &userUID,
+			wantStatusCode: http.StatusOK,
+			wantResponseBody: `[
+									{"order":"order2","sum":200,"processed_at":"2021-01-02T00:00:00Z"},
+									{"order":"order1","sum":100,"processed_at":"2021-01-01T00:00:00Z"}
+								]`,
+		},
+		{
+			name:                  "Invalid Sort",
+			query:                 "?sort=newest",
+			mockWithdrawalService: func() *MockWithdrawalService { return &MockWithdrawalService{} },
+			contextTimeout:        5 * time.Second,
+			userUID:               &userUID,
+			wantErr:               true,
+			wantStatusCode:        http.StatusBadRequest,
+			wantResponseBody:      `{"code":400,"message":"Invalid sort parameter, use asc or desc"}`,
+		},
+		{
+			name:                  "Misspelled Sort Parameter",
+			query:                 "?srot=desc",
+			mockWithdrawalService: func() *MockWithdrawalService { return &MockWithdrawalService{} },
+			contextTimeout:        5 * time.Second,
+			userUID:               &userUID,
+			wantErr:               true,
+			wantStatusCode:        http.StatusBadRequest,
+			wantResponseBody:      `{"code":400,"message":"Unknown query parameter \"srot\""}`,
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			// Prepare the request and response recorder
-			req, err := http.NewRequest("GET", "/api/withdrawals", nil)
+			req, err := http.NewRequest("GET", "/api/withdrawals"+tt.query, nil)
 			assert.NoError(t, err)
 
 			// Add user UID to the request context
@@ -234,8 +458,10 @@ func TestBalanceHandler_GetWithdrawals(t *testing.T) {
 			w := httptest.NewRecorder()
 
 			// Create BalanceHandler with mocked service
+			withdrawalService := tt.mockWithdrawalService()
+			withdrawalService.On("CountWithdrawals", mock.Anything, tt.userUID).Return(2, nil).Maybe()
 			bh := &BalanceHandler{
-				withdrawalService: tt.mockWithdrawalService(),
+				withdrawalService: withdrawalService,
 				contextTimeout:    tt.contextTimeout,
 			}
 
@@ -245,15 +471,136 @@ func TestBalanceHandler_GetWithdrawals(t *testing.T) {
 			// Validate the results
 			assert.Equal(t, tt.wantStatusCode, w.Code)
 			assert.JSONEq(t, tt.wantResponseBody, w.Body.String())
+			withdrawalService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestBalanceHandler_GetWithdrawals_TotalCount(t *testing.T) {
+	userUID := uuid.New()
+	tests := []struct {
+		name           string
+		query          string
+		withdrawals    []repository.Withdrawal
+		total          int
+		countErr       error
+		wantLimit      int
+		wantOffset     int
+		wantStatusCode int
+		wantTotal      string
+	}{
+		{
+			name:           "Page Of Many",
+			query:          "?limit=1&offset=1",
+			withdrawals:    []repository.Withdrawal{{OrderID: "order2", Amount: 200_00}},
+			total:          3,
+			wantLimit:      1,
+			wantOffset:     1,
+			wantStatusCode: http.StatusOK,
+			wantTotal:      "3",
+		},
+		{
+			name:           "Offset Past The End",
+			query:          "?offset=10",
+			withdrawals:    []repository.Withdrawal{},
+			total:          3,
+			wantLimit:      math.MaxInt32,
+			wantOffset:     10,
+			wantStatusCode: http.StatusNoContent,
+			wantTotal:      "3",
+		},
+		{
+			name:           "Count Failure",
+			withdrawals:    []repository.Withdrawal{{OrderID: "order1", Amount: 100_00}},
+			countErr:       errors.New("connection reset"),
+			wantLimit:      math.MaxInt32,
+			wantStatusCode: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &MockWithdrawalService{}
+			m.On("GetWithdrawalsSorted", mock.Anything, &userUID, tt.wantLimit, tt.wantOffset, repository.SortAsc).Return(&tt.withdrawals, nil)
+			m.On("CountWithdrawals", mock.Anything, &userUID).Return(tt.total, tt.countErr)
+			bh := NewBalanceHandler(5, NewPagination(10, 100), nil, m, nil)
+
+			req := httptest.NewRequest("GET", "/api/user/withdrawals"+tt.query, nil)
+			req = req.WithContext(appContext.WithUserUID(req.Context(), &userUID))
+			w := httptest.NewRecorder()
+			bh.GetWithdrawals(w, req)
+
+			assert.Equal(t, tt.wantStatusCode, w.Code)
+			assert.Equal(t, tt.wantTotal, w.Header().Get("X-Total-Count"))
+			m.AssertExpectations(t)
+		})
+	}
+}
+
+func TestBalanceHandler_GetWithdrawals_ContextErrors(t *testing.T) {
+	userUID := uuid.New()
+	tests := []struct {
+		name             string
+		contextTimeout   time.Duration
+		clientGone       bool
+		wantStatusCode   int
+		wantResponseBody string
+	}{
+		{
+			name:             "Client Closed Request",
+			contextTimeout:   5 * time.Second,
+			clientGone:       true,
+			wantStatusCode:   appContext.StatusClientClosedRequest,
+			wantResponseBody: `{"code":499,"message":"Client closed request"}`,
+		},
+		{
+			name:             "Deadline Exceeded",
+			contextTimeout:   0,
+			wantStatusCode:   http.StatusInternalServerError,
+			wantResponseBody: `{"code":500,"message":"Timeout exceeded"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withdrawals := &[]repository.Withdrawal{{OrderID: "order1", Amount: 100_00}}
+			m := &MockWithdrawalService{}
+			m.On("GetWithdrawalsSorted", mock.Anything, &userUID, mock.Anything, mock.Anything, repository.SortAsc).Return(withdrawals, nil)
+			m.On("CountWithdrawals", mock.Anything, &userUID).Return(1, nil)
+			bh := &BalanceHandler{withdrawalService: m, pagination: NewPagination(10, 100), contextTimeout: tt.contextTimeout}
+
+			reqCtx, cancelReq := context.WithCancel(appContext.WithUserUID(context.Background(), &userUID))
+			defer cancelReq()
+			if tt.clientGone {
+				cancelReq()
+			}
+			req := httptest.NewRequest("GET", "/api/user/withdrawals", nil).WithContext(reqCtx)
+			w := httptest.NewRecorder()
+			bh.GetWithdrawals(w, req)
+
+			assert.Equal(t, tt.wantStatusCode, w.Code)
+			assert.JSONEq(t, tt.wantResponseBody, w.Body.String())
 		})
 	}
 }
 
 func TestBalanceHandler_Withdraw(t *testing.T) {
 	userUID := uuid.New()
+	receipt := func(sum repository.Cents) *service.WithdrawalReceipt {
+		return &service.WithdrawalReceipt{
+			Withdrawal: repository.Withdrawal{
+				ID: 7, UserUUID: userUID, OrderID: "354188083613", Amount: sum,
+				CreatedAt: time.Date(2021, 1, 2, 0, 0, 0, 0, time.UTC),
+			},
+			Current: 400.5,
+		}
+	}
 	tests := []struct {
 		name                  string
 		requestBody           string
+		contentType           string
+		noContentType         bool
+		idempotencyKey        string
 		mockWithdrawalService func() *MockWithdrawalService
 		contextTimeout        time.Duration
 		userUID               *uuid.UUID
@@ -266,13 +613,14 @@ func TestBalanceHandler_Withdraw(t *testing.T) {
 			requestBody: `{"order":"354188083613","sum":100.0}`,
 			mockWithdrawalService: func() *MockWithdrawalService {
 				m := &MockWithdrawalService{}
-				m.On("CreateWithdrawal", mock.Anything, mock.Anything, "354188083613", 100.0).Return(nil)
+				m.On("CreateWithdrawal", mock.Anything, mock.Anything, "354188083613", repository.Cents(100_00)).Return(receipt(100_00), nil)
 				return m
 			},
-			contextTimeout: 5 * time.Second,
-			userUID:        &userUID,
-			wantErr:        false,
-			wantStatusCode: http.StatusOK,
+			contextTimeout:   5 * time.Second,
+			userUID:          &userUID,
+			wantErr:          false,
+			wantStatusCode:   http.StatusOK,
+			wantResponseBody: `{"id":7,"order":"354188083613","sum":100,"processed_at":"2021-01-02T00:00:00Z","current":400.5}`,
 		},
 		{
 			name:        "Invalid Order ID",
@@ -289,7 +637,74 @@ func TestBalanceHandler_Withdraw(t *testing.T) {
 		},
 		{
 			name:        "Invalid Request Body",
-			requestBody: `{"order":354188083613,"sum":"100.0"}`, // Malformed JSON
+			requestBody: `{"order":354188083613,"sum":"100.0"}`, // Order of the wrong type
+			mockWithdrawalService: func() *MockWithdrawalService {
+				m := &MockWithdrawalService{}
+				return m
+			},
+			contextTimeout:   5 * time.Second,
+			userUID:          &userUID,
+			wantErr:          true,
+			wantStatusCode:   http.StatusBadRequest,
+			wantResponseBody: `{"code":400,"message":"Invalid type of field \"order\": got number"}`,
+		},
+		{
+			name:                  "Malformed JSON",
+			requestBody:           `{"order":"354188083613","sum":`,
+			mockWithdrawalService: func() *MockWithdrawalService { return &MockWithdrawalService{} },
+			contextTimeout:        5 * time.Second,
+			userUID:               &userUID,
+			wantErr:               true,
+			wantStatusCode:        http.StatusBadRequest,
+			wantResponseBody:      `{"code":400,"message":"Unable to parse body"}`,
+		},
+		{
+			name:                  "Unknown Field",
+			requestBody:           `{"order":"354188083613","sum":100,"currency":"points"}`,
+			mockWithdrawalService: func() *MockWithdrawalService { return &MockWithdrawalService{} },
+			contextTimeout:        5 * time.Second,
+			userUID:               &userUID,
+			wantErr:               true,
+			wantStatusCode:        http.StatusBadRequest,
+			wantResponseBody:      `{"code":400,"message":"Unknown field \"currency\""}`,
+		},
+		{
+			name:                  "Sum Of The Wrong Type",
+			requestBody:           `{"order":"354188083613","sum":true}`,
+			mockWithdrawalService: func() *MockWithdrawalService { return &MockWithdrawalService{} },
+			contextTimeout:        5 * time.Second,
+			userUID:               &userUID,
+			wantErr:               true,
+			wantStatusCode:        http.StatusBadRequest,
+			wantResponseBody:      `{"code":400,"message":"Invalid type of a field: got bool"}`,
+		},
+		{
+			name:                  "Trailing Data",
+			requestBody:           `{"order":"354188083613","sum":100}{}`,
+			mockWithdrawalService: func() *MockWithdrawalService { return &MockWithdrawalService{} },
+			contextTimeout:        5 * time.Second,
+			userUID:               &userUID,
+			wantErr:               true,
+			wantStatusCode:        http.StatusBadRequest,
+			wantResponseBody:      `{"code":400,"message":"Unable to parse body"}`,
+		},
+		{
+			name:        "Sum With Trailing Zero",
+			requestBody: `{"order":"354188083613","sum":100.10}`,
+			mockWithdrawalService: func() *MockWithdrawalService {
+				m := &MockWithdrawalService{}
+				m.On("CreateWithdrawal", mock.Anything, mock.Anything, "354188083613", repository.Cents(100_10)).Return(receipt(100_10), nil)
+				return m
+			},
+			contextTimeout:   5 * time.Second,
+			userUID:          &userUID,
+			wantErr:          false,
+			wantStatusCode:   http.StatusOK,
+			wantResponseBody: `{"id":7,"order":"354188083613","sum":100.1,"processed_at":"2021-01-02T00:00:00Z","current":400.5}`,
+		},
+		{
+			name:        "Sum With Too Many Decimal Places",
+			requestBody: `{"order":"354188083613","sum":100.999}`,
 			mockWithdrawalService: func() *MockWithdrawalService {
 				m := &MockWithdrawalService{}
 				return m
@@ -298,7 +713,20 @@ func TestBalanceHandler_Withdraw(t *testing.T) {
 			userUID:          &userUID,
 			wantErr:          true,
 			wantStatusCode:   http.StatusBadRequest,
-			wantResponseBody: "{\"code\":400, \"message\":\"Unable to parse body\"}",
+			wantResponseBody: "{\"code\":400, \"message\":\"Invalid withdrawal sum\"}",
+		},
+		{
+			name:        "Negative Sum",
+			requestBody: `{"order":"354188083613","sum":-10}`,
+			mockWithdrawalService: func() *MockWithdrawalService {
+				m := &MockWithdrawalService{}
+				return m
+			},
+			contextTimeout:   5 * time.Second,
+			userUID:          &userUID,
+			wantErr:          true,
+			wantStatusCode:   http.StatusBadRequest,
+			wantResponseBody: "{\"code\":400, \"message\":\"Invalid withdrawal sum\"}",
 		},
 		{
 			name:        "Error in Withdrawal Service",
@@ -306,7 +734,7 @@ func TestBalanceHandler_Withdraw(t *testing.T) {
 			mockWithdrawalService: func() *MockWithdrawalService {
 				m := &MockWithdrawalService{}
 				err := errors.New("internal server error")
-				m.On("CreateWithdrawal", mock.Anything, mock.Anything, "354188083613", 100.0).Return(err)
+				m.On("CreateWithdrawal", mock.Anything, mock.Anything, "354188083613", repository.Cents(100_00)).Return((*service.WithdrawalReceipt)(nil), err)
 				return m
 			},
 			contextTimeout: 5 * time.Second,
@@ -319,7 +747,7 @@ func TestBalanceHandler_Withdraw(t *testing.T) {
 			requestBody: `{"order":"354188083613","sum":100.0}`,
 			mockWithdrawalService: func() *MockWithdrawalService {
 				m := &MockWithdrawalService{}
-				m.On("CreateWithdrawal", mock.Anything, mock.Anything, "354188083613", 100.0).Return(nil)
+				m.On("CreateWithdrawal", mock.Anything, mock.Anything, "354188083613", repository.Cents(100_00)).Return(receipt(100_00), nil)
 				return m
 			},
 			contextTimeout: 0, // 0 seconds timeout to trigger the timeout error
@@ -329,6 +757,69 @@ func TestBalanceHandler_Withdraw(t *testing.T) {
 			// If your implementation returns a specific error message for timeout, include it here
 			wantResponseBody: "{\"code\":500,\"message\":\"Timeout exceeded\"}\n",
 		},
+		{
+			name:           "Withdrawal With Idempotency Key",
+			requestBody:    `{"order":"354188083613","sum":100.0}`,
+			idempotencyKey: "9f1c2e4a",
+			mockWithdrawalService: func() *MockWithdrawalService {
+				m := &MockWithdrawalService{}
+				m.On("CreateWithdrawalWithKey", mock.Anything, mock.Anything, "9f1c2e4a", "354188083613", repository.Cents(100_00)).Return(receipt(100_00), nil)
+				return m
+			},
+			contextTimeout:   5 * time.Second,
+			userUID:          &userUID,
+			wantErr:          false,
+			wantStatusCode:   http.StatusOK,
+			wantResponseBody: `{"id":7,"order":"354188083613","sum":100,"processed_at":"2021-01-02T00:00:00Z","current":400.5}`,
+		},
+		{
+			name:                  "Too Long Idempotency Key",
+			requestBody:           `{"order":"354188083613","sum":100.0}`,
+			idempotencyKey:        strings.Repeat("k", 129),
+			mockWithdrawalService: func() *MockWithdrawalService { return &MockWithdrawalService{} },
+			contextTimeout:        5 * time.Second,
+			userUID:               &userUID,
+			wantErr:               true,
+			wantStatusCode:        http.StatusBadRequest,
+			wantResponseBody:      `{"code":400,"message":"Idempotency-Key must be at most 128 characters"}`,
+		},
+		{
+			name:                  "Not A JSON Content Type",
+			requestBody:           `{"order":"354188083613","sum":100.0}`,
+			contentType:           "application/x-www-form-urlencoded",
+			mockWithdrawalService: func() *MockWithdrawalService { return &MockWithdrawalService{} },
+			contextTimeout:        5 * time.Second,
+			userUID:               &userUID,
+			wantErr:               true,
+			wantStatusCode:        http.StatusUnsupportedMediaType,
+			wantResponseBody:      `{"code":415,"message":"Content-Type must be application/json"}`,
+		},
+		{
+			name:                  "Missing Content Type",
+			requestBody:           `{"order":"354188083613","sum":100.0}`,
+			noContentType:         true,
+			mockWithdrawalService: func() *MockWithdrawalService { return &MockWithdrawalService{} },
+			contextTimeout:        5 * time.Second,
+			userUID:               &userUID,
+			wantErr:               true,
+			wantStatusCode:        http.StatusUnsupportedMediaType,
+			wantResponseBody:      `{"code":415,"message":"Content-Type must be application/json"}`,
+		},
+		{
+			name:        "JSON Content Type With Charset",
+			requestBody: `{"order":"354188083613","sum":100.0}`,
+			contentType: "application/json; charset=utf-8",
+			mockWithdrawalService: func() *MockWithdrawalService {
+				m := &MockWithdrawalService{}
+				m.On("CreateWithdrawal", mock.Anything, mock.Anything, "354188083613", repository.Cents(100_00)).Return(receipt(100_00), nil)
+				return m
+			},
+			contextTimeout:   5 * time.Second,
+			userUID:          &userUID,
+			wantErr:          false,
+			wantStatusCode:   http.StatusOK,
+			wantResponseBody: `{"id":7,"order":"354188083613","sum":100,"processed_at":"2021-01-02T00:00:00Z","current":400.5}`,
+		},
 	}
 
 	for _, tt := range tests {
@@ -337,6 +828,15 @@ func TestBalanceHandler_Withdraw(t *testing.T) {
 			body := strings.NewReader(tt.requestBody)
 			req, err := http.NewRequest("POST", "/api/withdraw", body)
 			assert.NoError(t, err)
+			if !tt.noContentType {
+				req.Header.Set("Content-Type", "application/json")
+			}
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+			if tt.idempotencyKey != "" {
+				req.Header.Set("Idempotency-Key", tt.idempotencyKey)
+			}
 
 			// Add user UID to the request context
 			ctx := appContext.WithUserUID(req.Context(), tt.userUID)
@@ -363,12 +863,153 @@ func TestBalanceHandler_Withdraw(t *testing.T) {
 					assert.JSONEq(t, "{\"code\":500,\"message\":\"Internal Server Error\"}\n", w.Body.String())
 				}
 			} else {
-				if tt.wantResponseBody != "" {
-					assert.Equal(t, tt.wantResponseBody, w.Body.String())
-				} else {
-					assert.Empty(t, w.Body.String())
-				}
+				assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+				assert.JSONEq(t, tt.wantResponseBody, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestBalanceHandler_GetWithdrawalByKey(t *testing.T) {
+	userUID := uuid.New()
+	processedAt := time.Date(2021, 1, 2, 0, 0, 0, 0, time.UTC)
+	reversedAt := time.Date(2021, 1, 3, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name                  string
+		key                   string
+		mockWithdrawalService func() *MockWithdrawalService
+		wantStatusCode        int
+		wantResponseBody      string
+	}{
+		{
+			name: "Known Key",
+			key:  "9f1c2e4a",
+			mockWithdrawalService: func() *MockWithdrawalService {
+				m := &MockWithdrawalService{}
+				m.On("GetWithdrawalOutcome", mock.Anything, &userUID, "9f1c2e4a").Return(&repository.WithdrawalOutcome{
+					Withdrawal: repository.Withdrawal{UserUUID: userUID, OrderID: "354188083613", Amount: 100_00, CreatedAt: processedAt},
+				}, nil)
+				return m
+			},
+			wantStatusCode:   http.StatusOK,
+			wantResponseBody: `{"key":"9f1c2e4a","order":"354188083613","sum":100,"status":"WITHDRAWN","processed_at":"2021-01-02T00:00:00Z"}`,
+		},
+		{
+			name: "Reversed Withdrawal",
+			key:  "9f1c2e4a",
+			mockWithdrawalService: func() *MockWithdrawalService {
+				m := &MockWithdrawalService{}
+				m.On("GetWithdrawalOutcome", mock.Anything, &userUID, "9f1c2e4a").Return(&repository.WithdrawalOutcome{
+					Withdrawal: repository.Withdrawal{UserUUID: userUID, OrderID: "354188083613", Amount: 100_00, CreatedAt: processedAt},
+					ReversedAt: &reversedAt,
+				}, nil)
+				return m
+			},
+			wantStatusCode: http.StatusOK,
+			wantResponseBody: `{"key":"9f1c2e4a","order":"354188083613","sum":100,"status":"REVERSED",
+				"processed_at":"2021-01-02T00:00:00Z","reversed_at":"2021-01-03T00:00:00Z"}`,
+		},
+		{
+			name: "Unknown Key",
+			key:  "unknown",
+			mockWithdrawalService: func() *MockWithdrawalService {
+				m := &MockWithdrawalService{}
+				err := appErrors.NewWithCode(repository.ErrWithdrawalNotFound, "Withdrawal not found", http.StatusNotFound)
+				m.On("GetWithdrawalOutcome", mock.Anything, &userUID, "unknown").Return((*repository.WithdrawalOutcome)(nil), err)
+				return m
+			},
+			wantStatusCode:   http.StatusNotFound,
+			wantResponseBody: `{"code":404,"message":"Withdrawal not found"}`,
+		},
+		{
+			name: "Key With A Percent Sign",
+			key:  "100%",
+			mockWithdrawalService: func() *MockWithdrawalService {
+				m := &MockWithdrawalService{}
+				m.On("GetWithdrawalOutcome", mock.Anything, &userUID, "100%").Return(&repository.WithdrawalOutcome{
+					Withdrawal: repository.Withdrawal{UserUUID: userUID, OrderID: "354188083613", Amount: 100_00, CreatedAt: processedAt},
+				}, nil)
+				return m
+			},
+			wantStatusCode:   http.StatusOK,
+			wantResponseBody: `{"key":"100%","order":"354188083613","sum":100,"status":"WITHDRAWN","processed_at":"2021-01-02T00:00:00Z"}`,
+		},
+		{
+			name:                  "Too Long Key",
+			key:                   strings.Repeat("k", 129),
+			mockWithdrawalService: func() *MockWithdrawalService { return &MockWithdrawalService{} },
+			wantStatusCode:        http.StatusBadRequest,
+			wantResponseBody:      `{"code":400,"message":"Invalid idempotency key"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/user/withdrawals/by-key/"+url.PathEscape(tt.key), nil)
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("key", tt.key)
+			req = req.WithContext(context.WithValue(appContext.WithUserUID(req.Context(), &userUID), chi.RouteCtxKey, rctx))
+			w := httptest.NewRecorder()
+			ws := tt.mockWithdrawalService()
+			bh := &BalanceHandler{
+				withdrawalService: ws,
+				contextTimeout:    5 * time.Second,
+			}
+
+			bh.GetWithdrawalByKey(w, req)
+
+			assert.Equal(t, tt.wantStatusCode, w.Code)
+			assert.JSONEq(t, tt.wantResponseBody, w.Body.String())
+			ws.AssertExpectations(t)
+		})
+	}
+}
+
+func TestParseCents(t *testing.T) {
+	tests := []struct {
+		amount  json.Number
+		want    repository.Cents
+		wantErr bool
+	}{
+		{amount: "100.10", want: 10010},
+		{amount: "100.1", want: 10010},
+		{amount: "100", want: 10000},
+		{amount: "0.01", want: 1},
+		{amount: "1e2", want: 10000},
+		{amount: "100.100", want: 10010},
+		{amount: "100.999", wantErr: true},
+		{amount: "0.001", wantErr: true},
+		{amount: "0", wantErr: true},
+		{amount: "-5", wantErr: true},
+		{amount: "abc", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.amount.String(), func(t *testing.T) {
+			got, err := parseCents(tt.amount)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.want, got)
 			}
 		})
 	}
 }
+
+func TestNewBalanceHandler_EndpointTimeout(t *testing.T) {
+	// a zero global budget would time every request out, the balance override must win
+	c := config.AppConfig{ContextTimeoutSec: 0, BalanceTimeoutSec: 5}
+	userUID := uuid.New()
+	ws := &MockWalletService{}
+	ws.On("GetBalance", mock.Anything, &userUID).Return(&service.UserBalance{CurrentBalance: 10}, nil)
+
+	bh := NewBalanceHandler(c.TimeoutSec(c.BalanceTimeoutSec), NewPagination(100, 1000), ws, &MockWithdrawalService{}, &MockOrderService{})
+	assert.Equal(t, 5*time.Second, bh.contextTimeout)
+
+	req := httptest.NewRequest("GET", "/api/user/balance", nil)
+	req = req.WithContext(appContext.WithUserUID(req.Context(), &userUID))
+	w := httptest.NewRecorder()
+	bh.GetBalance(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"current":10,"withdrawn":0}`, w.Body.String())
+}
@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	appErrors "github.com/ujwegh/gophermart/internal/app/errors"
+	"github.com/ujwegh/gophermart/internal/app/util"
+	"net/http"
+	"strconv"
+)
+
+const (
+	defaultOrderNumberLength = 16
+	maxOrderNumberLength     = 64
+)
+
+type (
+	DevHandler struct{}
+
+	//easyjson:json
+	OrderNumberDTO struct {
+		Number string `json:"number"`
+	}
+)
+
+func NewDevHandler() *DevHandler {
+	return &DevHandler{}
+}
+
+// GenerateOrderNumber godoc
+// @Summary Generate a valid order number
+// @Description Development helper returning a random order number that passes the Luhn check.
+// @Description Only mounted when the server runs in dev mode.
+// @Tags dev
+// @Produce json
+// @Param prefix query string false "Digits the number should start with"
+// @Param length query int false "Number length, 16 by default"
+// @Success 200 {object} OrderNumberDTO "Generated order number"
+// @Failure 400 {object} ErrorResponse "Bad Request - Invalid prefix or length"
+// @Failure 500 {object} ErrorResponse "Internal Server Error"
+// @Router /api/dev/order-number [get]
+func (dh *DevHandler) GenerateOrderNumber(w http.ResponseWriter, r *http.Request) {
+	prefix := r.URL.Query().Get("prefix")
+	length := defaultOrderNumberLength
+	if raw := r.URL.Query().Get("length"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil {
+			PrepareError(w, r, appErrors.NewWithCode(err, "Invalid order number length", http.StatusBadRequest))
+			return
+		}
+		length = parsed
+	}
+	if length <= len(prefix) || length > maxOrderNumberLength {
+		msg := "Invalid order number length"
+		PrepareError(w, r, appErrors.NewWithCode(errors.New(msg), msg, http.StatusBadRequest))
+		return
+	}
+	if !isDigits(prefix) {
+		msg := "Invalid order number prefix"
+		PrepareError(w, r, appErrors.NewWithCode(errors.New(msg), msg, http.StatusBadRequest))
+		return
+	}
+
+	response := OrderNumberDTO{Number: util.GenerateLuhn(prefix, length)}
+	rawBytes, err := response.MarshalJSON()
+	if err != nil {
+		PrepareError(w, r, fmt.Errorf("marshal response: %w", err))
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(rawBytes)
+}
+
+func isDigits(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"github.com/ShiraazMoollatjie/goluhn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDevHandler_GenerateOrderNumber(t *testing.T) {
+	tests := []struct {
+		name             string
+		query            string
+		wantStatusCode   int
+		wantPrefix       string
+		wantLength       int
+		wantResponseBody string
+	}{
+		{name: "Default Length", query: "", wantStatusCode: http.StatusOK, wantLength: 16},
+		{name: "Prefix And Length", query: "?prefix=12&length=10", wantStatusCode: http.StatusOK, wantPrefix: "12", wantLength: 10},
+		{
+			name:             "Non Numeric Prefix",
+			query:            "?prefix=ab",
+			wantStatusCode:   http.StatusBadRequest,
+			wantResponseBody: `{"code":400,"message":"Invalid order number prefix"}`,
+		},
+		{
+			name:             "Length Too Large",
+			query:            "?length=65",
+			wantStatusCode:   http.StatusBadRequest,
+			wantResponseBody: `{"code":400,"message":"Invalid order number length"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/api/dev/order-number"+tt.query, nil)
+			w := httptest.NewRecorder()
+
+			NewDevHandler().GenerateOrderNumber(w, req)
+
+			assert.Equal(t, tt.wantStatusCode, w.Code)
+			if tt.wantResponseBody != "" {
+				assert.JSONEq(t, tt.wantResponseBody, w.Body.String())
+				return
+			}
+			dto := OrderNumberDTO{}
+			require.NoError(t, dto.UnmarshalJSON(w.Body.Bytes()))
+			assert.Len(t, dto.Number, tt.wantLength)
+			assert.True(t, strings.HasPrefix(dto.Number, tt.wantPrefix))
+			assert.NoError(t, goluhn.Validate(dto.Number))
+		})
+	}
+}
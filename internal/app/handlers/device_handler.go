@@ -0,0 +1,170 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/go-chi/chi/v5"
+	appContext "github.com/ujwegh/gophermart/internal/app/context"
+	appErrors "github.com/ujwegh/gophermart/internal/app/errors"
+	"github.com/ujwegh/gophermart/internal/app/repository"
+	"github.com/ujwegh/gophermart/internal/app/service"
+	"io"
+	"net/http"
+	"time"
+)
+
+// maxDeviceIDLength matches the device_id column.
+const maxDeviceIDLength = 128
+
+type (
+	DeviceHandler struct {
+		deviceMarkerService service.DeviceMarkerService
+		contextTimeout      time.Duration
+	}
+
+	//easyjson:json
+	DeviceMarkerDTO struct {
+		DeviceID   string    `json:"device"`
+		LastSeenAt time.Time `json:"last_seen_at"`
+		UpdatedAt  time.Time `json:"updated_at"`
+	}
+	//easyjson:json
+	DeviceMarkerRequestDTO struct {
+		LastSeenAt *time.Time `json:"last_seen_at"`
+	}
+)
+
+func NewDeviceHandler(contextTimeoutSec int, deviceMarkerService service.DeviceMarkerService) *DeviceHandler {
+	return &DeviceHandler{
+		deviceMarkerService: deviceMarkerService,
+		contextTimeout:      time.Duration(contextTimeoutSec) * time.Second,
+	}
+}
+
+// GetMarker godoc
+// @Summary Receiving the last seen marker of a device
+// @Description The handler returns the upload time of the newest order the authorized user has seen on the device.
+// @Description GET /api/user/orders?new_only=true&device=<device> lists the orders uploaded after it.
+// @Tags orders
+// @Produce json
+// @Param device path string true "Client chosen device identifier, at most 128 characters"
+// @Success 200 {object} DeviceMarkerDTO "The marker of the device"
+// @Failure 400 {object} ErrorResponse "Bad Request - Invalid device identifier"
+// @Failure 401 {object} ErrorResponse "Unauthorized - The user is not authorized"
+// @Failure 404 {object} ErrorResponse "Not Found - The device has no marker yet"
+// @Failure 500 {object} ErrorResponse "Internal Server Error"
+// @Security ApiKeyAuth
+// @Router /api/user/devices/{device}/marker [get]
+func (dh *DeviceHandler) GetMarker(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), dh.contextTimeout)
+	defer cancel()
+	userUID := appContext.UserUID(r.Context())
+
+	deviceID, err := validateDeviceID(chi.URLParam(r, "device"))
+	if err != nil {
+		PrepareError(w, r, err)
+		return
+	}
+	marker, err := dh.deviceMarkerService.GetMarker(ctx, userUID, deviceID)
+	if err != nil {
+		PrepareError(w, r, err)
+		return
+	}
+	dh.writeMarker(ctx, w, r, marker)
+}
+
+// SetMarker godoc
+// @Summary Setting the last seen marker of a device
+// @Description The handler stores the upload time of the newest order the authorized user has seen on the device,
+// @Description usually the uploaded_at of the first order in the last list the device fetched.
+// @Tags orders
+// @Accept json
+// @Produce json
+// @Param device path string true "Client chosen device identifier, at most 128 characters"
+// @Param marker body DeviceMarkerRequestDTO true "Upload time of the newest order seen"
+// @Success 200 {object} DeviceMarkerDTO "The stored marker"
+// @Failure 400 {object} ErrorResponse "Bad Request - Invalid device identifier, unable to parse body or last_seen_at is required"
+// @Failure 401 {object} ErrorResponse "Unauthorized - The user is not authorized"
+// @Failure 415 {object} ErrorResponse "Unsupported Media Type - The body is not JSON"
+// @Failure 500 {object} ErrorResponse "Internal Server Error"
+// @Security ApiKeyAuth
+// @Router /api/user/devices/{device}/marker [put]
+func (dh *DeviceHandler) SetMarker(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), dh.contextTimeout)
+	defer cancel()
+
+	if err := requireJSON(r); err != nil {
+		PrepareError(w, r, err)
+		return
+	}
+
+	userUID := appContext.UserUID(r.Context())
+	deviceID, err := validateDeviceID(chi.URLParam(r, "device"))
+	if err != nil {
+		PrepareError(w, r, err)
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		err = appErrors.NewWithCode(err, errMsgEnableReadBody, http.StatusBadRequest)
+		PrepareError(w, r, err)
+		return
+	}
+	request := DeviceMarkerRequestDTO{}
+	err = decodeStrict(body, (*strictDeviceMarkerRequestDTO)(&request))
+	if err != nil {
+		PrepareError(w, r, err)
+		return
+	}
+	if request.LastSeenAt == nil {
+		msg := "last_seen_at is required"
+		PrepareError(w, r, appErrors.NewWithCode(errors.New(msg), msg, http.StatusBadRequest))
+		return
+	}
+
+	err = appContext.GetContextError(ctx)
+	if err != nil {
+		PrepareError(w, r, err)
+		return
+	}
+	marker, err := dh.deviceMarkerService.SetMarker(ctx, userUID, deviceID, *request.LastSeenAt)
+	if err != nil {
+		PrepareError(w, r, err)
+		return
+	}
+	dh.writeMarker(ctx, w, r, marker)
+}
+
+func (dh *DeviceHandler) writeMarker(ctx context.Context, w http.ResponseWriter, r *http.Request, marker *repository.DeviceMarker) {
+	response := DeviceMarkerDTO{
+		DeviceID:   marker.DeviceID,
+		LastSeenAt: marker.LastSeenAt,
+		UpdatedAt:  marker.UpdatedAt,
+	}
+	rawBytes, err := response.MarshalJSON()
+	if err != nil {
+		PrepareError(w, r, fmt.Errorf("unable to marshal response: %w", err))
+		return
+	}
+
+	err = appContext.GetContextError(ctx)
+	if err != nil {
+		PrepareError(w, r, err)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(rawBytes)
+}
+
+// validateDeviceID rejects empty and overlong device identifiers.
+func validateDeviceID(deviceID string) (string, error) {
+	if deviceID == "" || len(deviceID) > maxDeviceIDLength {
+		msg := fmt.Sprintf("Device identifier must be 1 to %d characters", maxDeviceIDLength)
+		return "", appErrors.NewWithCode(errors.New(msg), msg, http.StatusBadRequest)
+	}
+	return deviceID, nil
+}
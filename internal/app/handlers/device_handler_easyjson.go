@@ -0,0 +1,184 @@
+// Code generated by easyjson for marshaling/unmarshaling. DO NOT EDIT.
+
+package handlers
+
+import (
+	json "encoding/json"
+	easyjson "github.com/mailru/easyjson"
+	jlexer "github.com/mailru/easyjson/jlexer"
+	jwriter "github.com/mailru/easyjson/jwriter"
+	time "time"
+)
+
+// suppress unused package warning
+var (
+	_ *json.RawMessage
+	_ *jlexer.Lexer
+	_ *jwriter.Writer
+	_ easyjson.Marshaler
+)
+
+func easyjson26408acfDecodeGithubComUjweghGophermartInternalAppHandlers(in *jlexer.Lexer, out *DeviceMarkerRequestDTO) {
+	isTopLevel := in.IsStart()
+	if in.IsNull() {
+		if isTopLevel {
+			in.Consumed()
+		}
+		in.Skip()
+		return
+	}
+	in.Delim('{')
+	for !in.IsDelim('}') {
+		key := in.UnsafeFieldName(false)
+		in.WantColon()
+		if in.IsNull() {
+			in.Skip()
+			in.WantComma()
+			continue
+		}
+		switch key {
+		case "last_seen_at":
+			if in.IsNull() {
+				in.Skip()
+				out.LastSeenAt = nil
+			} else {
+				if out.LastSeenAt == nil {
+					out.LastSeenAt = new(time.Time)
+				}
+				if data := in.Raw(); in.Ok() {
+					in.AddError((*out.LastSeenAt).UnmarshalJSON(data))
+				}
+			}
+		default:
+			in.SkipRecursive()
+		}
+		in.WantComma()
+	}
+	in.Delim('}')
+	if isTopLevel {
+		in.Consumed()
+	}
+}
+func easyjson26408acfEncodeGithubComUjweghGophermartInternalAppHandlers(out *jwriter.Writer, in DeviceMarkerRequestDTO) {
+	out.RawByte('{')
+	first := true
+	_ = first
+	{
+		const prefix string = ",\"last_seen_at\":"
+		out.RawString(prefix[1:])
+		if in.LastSeenAt == nil {
+			out.RawString("null")
+		} else {
+			out.Raw((*in.LastSeenAt).MarshalJSON())
+		}
+	}
+	out.RawByte('}')
+}
+
+// MarshalJSON supports json.Marshaler interface
+func (v DeviceMarkerRequestDTO) MarshalJSON() ([]byte, error) {
+	w := jwriter.Writer{}
+	easyjson26408acfEncodeGithubComUjweghGophermartInternalAppHandlers(&w, v)
+	return w.Buffer.BuildBytes(), w.Error
+}
+
+// MarshalEasyJSON supports easyjson.Marshaler interface
+func (v DeviceMarkerRequestDTO) MarshalEasyJSON(w *jwriter.Writer) {
+	easyjson26408acfEncodeGithubComUjweghGophermartInternalAppHandlers(w, v)
+}
+
+// UnmarshalJSON supports json.Unmarshaler interface
+func (v *DeviceMarkerRequestDTO) UnmarshalJSON(data []byte) error {
+	r := jlexer.Lexer{Data: data}
+	easyjson26408acfDecodeGithubComUjweghGophermartInternalAppHandlers(&r, v)
+	return r.Error()
+}
+
+// UnmarshalEasyJSON supports easyjson.Unmarshaler interface
+func (v *DeviceMarkerRequestDTO) UnmarshalEasyJSON(l *jlexer.Lexer) {
+	easyjson26408acfDecodeGithubComUjweghGophermartInternalAppHandlers(l, v)
+}
+func easyjson26408acfDecodeGithubComUjweghGophermartInternalAppHandlers1(in *jlexer.Lexer, out *DeviceMarkerDTO) {
+	isTopLevel := in.IsStart()
+	if in.IsNull() {
+		if isTopLevel {
+			in.Consumed()
+		}
+		in.Skip()
+		return
+	}
+	in.Delim('{')
+	for !in.IsDelim('}') {
+		key := in.UnsafeFieldName(false)
+		in.WantColon()
+		if in.IsNull() {
+			in.Skip()
+			in.WantComma()
+			continue
+		}
+		switch key {
+		case "device":
+			out.DeviceID = string(in.String())
+		case "last_seen_at":
+			if data := in.Raw(); in.Ok() {
+				in.AddError((out.LastSeenAt).UnmarshalJSON(data))
+			}
+		case "updated_at":
+			if data := in.Raw(); in.Ok() {
+				in.AddError((out.UpdatedAt).UnmarshalJSON(data))
+			}
+		default:
+			in.SkipRecursive()
+		}
+		in.WantComma()
+	}
+	in.Delim('}')
+	if isTopLevel {
+		in.Consumed()
+	}
+}
+func easyjson26408acfEncodeGithubComUjweghGophermartInternalAppHandlers1(out *jwriter.Writer, in DeviceMarkerDTO) {
+	out.RawByte('{')
+	first := true
+	_ = first
+	{
+		const prefix string = ",\"device\":"
+		out.RawString(prefix[1:])
+		out.String(string(in.DeviceID))
+	}
+	{
+		const prefix string = ",\"last_seen_at\":"
+		out.RawString(prefix)
+		out.Raw((in.LastSeenAt).MarshalJSON())
+	}
+	{
+		const prefix string = ",\"updated_at\":"
+		out.RawString(prefix)
+		out.Raw((in.UpdatedAt).MarshalJSON())
+	}
+	out.RawByte('}')
+}
+
+// MarshalJSON supports json.Marshaler interface
+func (v DeviceMarkerDTO) MarshalJSON() ([]byte, error) {
+	w := jwriter.Writer{}
+	easyjson26408acfEncodeGithubComUjweghGophermartInternalAppHandlers1(&w, v)
+	return w.Buffer.BuildBytes(), w.Error
+}
+
+// MarshalEasyJSON supports easyjson.Marshaler interface
+func (v DeviceMarkerDTO) MarshalEasyJSON(w *jwriter.Writer) {
+	easyjson26408acfEncodeGithubComUjweghGophermartInternalAppHandlers1(w, v)
+}
+
+// UnmarshalJSON supports json.Unmarshaler interface
+func (v *DeviceMarkerDTO) UnmarshalJSON(data []byte) error {
+	r := jlexer.Lexer{Data: data}
+	easyjson26408acfDecodeGithubComUjweghGophermartInternalAppHandlers1(&r, v)
+	return r.Error()
+}
+
+// UnmarshalEasyJSON supports easyjson.Unmarshaler interface
+func (v *DeviceMarkerDTO) UnmarshalEasyJSON(l *jlexer.Lexer) {
+	easyjson26408acfDecodeGithubComUjweghGophermartInternalAppHandlers1(l, v)
+}
@@ -0,0 +1,188 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	appContext "github.com/ujwegh/gophermart/internal/app/context"
+	appErrors "github.com/ujwegh/gophermart/internal/app/errors"
+	"github.com/ujwegh/gophermart/internal/app/repository"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type MockDeviceMarkerService struct {
+	mock.Mock
+}
+
+func (m *MockDeviceMarkerService) GetMarker(ctx context.Context, userUID *uuid.UUID, deviceID string) (*repository.DeviceMarker, error) {
+	args := m.Called(ctx, userUID, deviceID)
+	return args.Get(0).(*repository.DeviceMarker), args.Error(1)
+}
+
+func (m *MockDeviceMarkerService) SetMarker(ctx context.Context, userUID *uuid.UUID, deviceID string, lastSeenAt time.Time) (*repository.DeviceMarker, error) {
+	args := m.Called(ctx, userUID, deviceID, lastSeenAt)
+	return args.Get(0).(*repository.DeviceMarker), args.Error(1)
+}
+
+func newDeviceRequest(method, device, body string, userUID *uuid.UUID) *http.Request {
+	req := httptest.NewRequest(method, "/api/user/devices/"+device+"/marker", strings.NewReader(body))
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("device", device)
+	ctx := context.WithValue(appContext.WithUserUID(req.Context(), userUID), chi.RouteCtxKey, rctx)
+	return req.WithContext(ctx)
+}
+
+func TestDeviceHandler_GetMarker(t *testing.T) {
+	userUID := uuid.New()
+	seen := time.Date(2021, 1, 2, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name           string
+		device         string
+		mockService    func() *MockDeviceMarkerService
+		wantStatusCode int
+		wantResponse   string
+	}{
+		{
+			name:   "Marker Found",
+			device: "phone",
+			mockService: func() *MockDeviceMarkerService {
+				m := &MockDeviceMarkerService{}
+				marker := &repository.DeviceMarker{UserUUID: userUID, DeviceID: "phone", LastSeenAt: seen, UpdatedAt: seen.Add(time.Hour)}
+				m.On("GetMarker", mock.Anything, &userUID, "phone").Return(marker, nil)
+				return m
+			},
+			wantStatusCode: http.StatusOK,
+			wantResponse:   `{"device":"phone","last_seen_at":"2021-01-02T00:00:00Z","updated_at":"2021-01-02T01:00:00Z"}`,
+		},
+		{
+			name:   "No Marker Yet",
+			device: "phone",
+			mockService: func() *MockDeviceMarkerService {
+				m := &MockDeviceMarkerService{}
+				err := appErrors.NewWithCode(repository.ErrDeviceMarkerNotFound, "Device marker not found", http.StatusNotFound)
+				m.On("GetMarker", mock.Anything, &userUID, "phone").Return((*repository.DeviceMarker)(nil), err)
+				return m
+			},
+			wantStatusCode: http.StatusNotFound,
+			wantResponse:   `{"code":404,"message":"Device marker not found"}`,
+		},
+		{
+			name:           "Device Identifier Too Long",
+			device:         strings.Repeat("x", maxDeviceIDLength+1),
+			mockService:    func() *MockDeviceMarkerService { return &MockDeviceMarkerService{} },
+			wantStatusCode: http.StatusBadRequest,
+			wantResponse:   `{"code":400,"message":"Device identifier must be 1 to 128 characters"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := tt.mockService()
+			dh := &DeviceHandler{deviceMarkerService: m, contextTimeout: 5 * time.Second}
+			w := httptest.NewRecorder()
+
+			dh.GetMarker(w, newDeviceRequest("GET", tt.device, "", &userUID))
+
+			assert.Equal(t, tt.wantStatusCode, w.Code)
+			assert.JSONEq(t, tt.wantResponse, w.Body.String())
+			m.AssertExpectations(t)
+		})
+	}
+}
+
+func TestDeviceHandler_SetMarker(t *testing.T) {
+	userUID := uuid.New()
+	seen := time.Date(2021, 1, 2, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name           string
+		body           string
+		contentType    string
+		noContentType  bool
+		mockService    func() *MockDeviceMarkerService
+		wantStatusCode int
+		wantResponse   string
+	}{
+		{
+			name: "Marker Stored",
+			body: `{"last_seen_at":"2021-01-02T00:00:00Z"}`,
+			mockService: func() *MockDeviceMarkerService {
+				m := &MockDeviceMarkerService{}
+				marker := &repository.DeviceMarker{UserUUID: userUID, DeviceID: "phone", LastSeenAt: seen, UpdatedAt: seen}
+				m.On("SetMarker", mock.Anything, &userUID, "phone", seen).Return(marker, nil)
+				return m
+			},
+			wantStatusCode: http.StatusOK,
+			wantResponse:   `{"device":"phone","last_seen_at":"2021-01-02T00:00:00Z","updated_at":"2021-01-02T00:00:00Z"}`,
+		},
+		{
+			name:           "Missing Last Seen",
+			body:           `{}`,
+			mockService:    func() *MockDeviceMarkerService { return &MockDeviceMarkerService{} },
+			wantStatusCode: http.StatusBadRequest,
+			wantResponse:   `{"code":400,"message":"last_seen_at is required"}`,
+		},
+		{
+			name:           "Unknown Field",
+			body:           `{"last_seen_at":"2021-01-02T00:00:00Z","order":"354188083613"}`,
+			mockService:    func() *MockDeviceMarkerService { return &MockDeviceMarkerService{} },
+			wantStatusCode: http.StatusBadRequest,
+			wantResponse:   `{"code":400,"message":"Unknown field \"order\""}`,
+		},
+		{
+			name:           "Not A JSON Content Type",
+			body:           `{"last_seen_at":"2021-01-02T00:00:00Z"}`,
+			contentType:    "text/plain",
+			mockService:    func() *MockDeviceMarkerService { return &MockDeviceMarkerService{} },
+			wantStatusCode: http.StatusUnsupportedMediaType,
+			wantResponse:   `{"code":415,"message":"Content-Type must be application/json"}`,
+		},
+		{
+			name:           "Missing Content Type",
+			body:           `{"last_seen_at":"2021-01-02T00:00:00Z"}`,
+			noContentType:  true,
+			mockService:    func() *MockDeviceMarkerService { return &MockDeviceMarkerService{} },
+			wantStatusCode: http.StatusUnsupportedMediaType,
+			wantResponse:   `{"code":415,"message":"Content-Type must be application/json"}`,
+		},
+		{
+			name: "Service Error",
+			body: `{"last_seen_at":"2021-01-02T00:00:00Z"}`,
+			mockService: func() *MockDeviceMarkerService {
+				m := &MockDeviceMarkerService{}
+				err := appErrors.New(errors.New("db down"), "set device marker")
+				m.On("SetMarker", mock.Anything, &userUID, "phone", seen).Return((*repository.DeviceMarker)(nil), err)
+				return m
+			},
+			wantStatusCode: http.StatusInternalServerError,
+			wantResponse:   `{"code":500,"message":"set device marker"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := tt.mockService()
+			dh := &DeviceHandler{deviceMarkerService: m, contextTimeout: 5 * time.Second}
+			req := newDeviceRequest("PUT", "phone", tt.body, &userUID)
+			if !tt.noContentType {
+				req.Header.Set("Content-Type", "application/json")
+			}
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+			w := httptest.NewRecorder()
+
+			dh.SetMarker(w, req)
+
+			assert.Equal(t, tt.wantStatusCode, w.Code)
+			assert.JSONEq(t, tt.wantResponse, w.Body.String())
+			m.AssertExpectations(t)
+		})
+	}
+}
@@ -1,11 +1,16 @@
 package handlers
 
 import (
+	"context"
 	"errors"
+	appContext "github.com/ujwegh/gophermart/internal/app/context"
 	appErrors "github.com/ujwegh/gophermart/internal/app/errors"
 	"github.com/ujwegh/gophermart/internal/app/logger"
 	"go.uber.org/zap"
+	"mime"
 	"net/http"
+	"strconv"
+	"strings"
 )
 
 //easyjson:json
@@ -14,15 +19,33 @@ type ErrorResponse struct {
 	Code    int    `json:"code"`
 }
 
-func PrepareError(w http.ResponseWriter, err error) {
+func PrepareError(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, context.Canceled) {
+		// the client went away, also when a query failed on it; the answer only reaches the access log
+		logger.Log.Debug("client closed request", zap.String("path", r.URL.Path), zap.Error(err))
+		WriteErrorResponse(w, r, "Client closed request", appContext.StatusClientClosedRequest)
+		return
+	}
 	var codeErr appErrors.ResponseCodeError
 	logger.Log.Error("internal error: ", zap.Error(err))
 	if errors.As(err, &codeErr) {
-		WriteJSONErrorResponse(w, codeErr.Msg(), codeErr.Code())
+		WriteErrorResponse(w, r, codeErr.Msg(), codeErr.Code())
 		return
 	}
 	// Default error handling
-	WriteJSONErrorResponse(w, "Internal Server Error", http.StatusInternalServerError)
+	WriteErrorResponse(w, r, "Internal Server Error", http.StatusInternalServerError)
+}
+
+// WriteErrorResponse writes the error as plain text when the client's Accept header prefers text/plain
+// over JSON, and as JSON otherwise.
+func WriteErrorResponse(w http.ResponseWriter, r *http.Request, message string, code int) {
+	if prefersPlainText(r.Header.Get("Accept")) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.WriteHeader(code)
+		w.Write([]byte(message + "\n"))
+		return
+	}
+	WriteJSONErrorResponse(w, message, code)
 }
 
 func WriteJSONErrorResponse(w http.ResponseWriter, message string, code int) {
@@ -40,3 +63,37 @@ func WriteJSONErrorResponse(w http.ResponseWriter, message string, code int) {
 	w.WriteHeader(code)
 	w.Write(json)
 }
+
+// prefersPlainText reports whether the Accept header rates text/plain above application/json.
+// Ties, wildcards and a missing header keep the JSON default.
+func prefersPlainText(accept string) bool {
+	var jsonQ, plainQ float64
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if raw, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(raw, 64); err != nil {
+				continue
+			}
+		}
+		switch mediaType {
+		case "application/json", "application/*":
+			jsonQ = maxQ(jsonQ, q)
+		case "text/plain", "text/*":
+			plainQ = maxQ(plainQ, q)
+		case "*/*":
+			jsonQ, plainQ = maxQ(jsonQ, q), maxQ(plainQ, q)
+		}
+	}
+	return plainQ > jsonQ
+}
+
+func maxQ(a, b float64) float64 {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	appContext "github.com/ujwegh/gophermart/internal/app/context"
+	appErrors "github.com/ujwegh/gophermart/internal/app/errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWriteErrorResponse_ContentNegotiation(t *testing.T) {
+	tests := []struct {
+		name            string
+		accept          string
+		wantContentType string
+		wantBody        string
+	}{
+		{
+			name:            "No Accept Header",
+			accept:          "",
+			wantContentType: "application/json",
+			wantBody:        `{"message":"Invalid order number prefix","code":400}`,
+		},
+		{
+			name:            "Accept JSON",
+			accept:          "application/json",
+			wantContentType: "application/json",
+			wantBody:        `{"message":"Invalid order number prefix","code":400}`,
+		},
+		{
+			name:            "Accept Plain Text",
+			accept:          "text/plain",
+			wantContentType: "text/plain; charset=utf-8",
+			wantBody:        "Invalid order number prefix\n",
+		},
+		{
+			name:            "Plain Text Preferred By Quality",
+			accept:          "application/json;q=0.5, text/plain",
+			wantContentType: "text/plain; charset=utf-8",
+			wantBody:        "Invalid order number prefix\n",
+		},
+		{
+			name:            "Wildcard Keeps JSON",
+			accept:          "*/*",
+			wantContentType: "application/json",
+			wantBody:        `{"message":"Invalid order number prefix","code":400}`,
+		},
+		{
+			name:            "Tie Keeps JSON",
+			accept:          "text/plain, */*",
+			wantContentType: "application/json",
+			wantBody:        `{"message":"Invalid order number prefix","code":400}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/api/dev/order-number?prefix=ab", nil)
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+			w := httptest.NewRecorder()
+
+			NewDevHandler().GenerateOrderNumber(w, req)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			assert.Equal(t, tt.wantContentType, w.Header().Get("Content-Type"))
+			if tt.wantContentType == "application/json" {
+				assert.JSONEq(t, tt.wantBody, w.Body.String())
+			} else {
+				assert.Equal(t, tt.wantBody, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestPrepareError_ClientClosedRequest(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		wantCode int
+	}{
+		{name: "Context Error", err: appContext.GetContextError(canceledContext()), wantCode: appContext.StatusClientClosedRequest},
+		{name: "Query Failed On Cancellation", err: fmt.Errorf("read orders: %w", context.Canceled), wantCode: appContext.StatusClientClosedRequest},
+		{name: "Coded Error Wrapping Cancellation", err: appErrors.NewWithCode(context.Canceled, "Order not found", http.StatusNotFound),
+			wantCode: appContext.StatusClientClosedRequest},
+		{name: "Deadline", err: fmt.Errorf("read orders: %w", context.DeadlineExceeded), wantCode: http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			PrepareError(w, httptest.NewRequest("GET", "/api/user/orders", nil), tt.err)
+			assert.Equal(t, tt.wantCode, w.Code)
+		})
+	}
+}
+
+func canceledContext() context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	return ctx
+}
@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"fmt"
+	"github.com/ujwegh/gophermart/internal/app/service"
+	"net/http"
+	"time"
+)
+
+type (
+	HealthHandler struct {
+		heartbeat service.HeartbeatProvider
+		// staleAfter is how old the last heartbeat may get before the processor counts as stalled
+		staleAfter time.Duration
+		now        func() time.Time
+	}
+
+	//easyjson:json
+	ProcessorHealthDTO struct {
+		Healthy       bool       `json:"healthy"`
+		LastHeartbeat *time.Time `json:"last_heartbeat,omitempty"`
+		StaleAfterSec int        `json:"stale_after_sec"`
+	}
+)
+
+func NewHealthHandler(heartbeat service.HeartbeatProvider, staleAfterSec int) *HealthHandler {
+	return &HealthHandler{
+		heartbeat:  heartbeat,
+		staleAfter: time.Duration(staleAfterSec) * time.Second,
+		now:        time.Now,
+	}
+}
+
+// ProcessorHealth godoc
+// @Summary Order processor liveness check
+// @Description The handler reports the order processor healthy while its loop keeps beating. It answers 503 once
+// @Description the last heartbeat is older than PROCESSOR_STALE_SEC, e.g. when the processor died or is stuck,
+// @Description and before the processor has started.
+// @Tags health
+// @Produce json
+// @Success 200 {object} ProcessorHealthDTO "The processor is running"
+// @Failure 503 {object} ProcessorHealthDTO "Service Unavailable - The processor heartbeat is stale"
+// @Router /healthz/processor [get]
+func (hh *HealthHandler) ProcessorHealth(w http.ResponseWriter, r *http.Request) {
+	response := ProcessorHealthDTO{StaleAfterSec: int(hh.staleAfter / time.Second)}
+	if last := hh.heartbeat.LastHeartbeat(); !last.IsZero() {
+		response.LastHeartbeat = &last
+		response.Healthy = hh.now().Sub(last) <= hh.staleAfter
+	}
+	rawBytes, err := response.MarshalJSON()
+	if err != nil {
+		PrepareError(w, r, fmt.Errorf("marshal response: %w", err))
+		return
+	}
+
+	code := http.StatusOK
+	if !response.Healthy {
+		code = http.StatusServiceUnavailable
+	}
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(code)
+	w.Write(rawBytes)
+}
@@ -0,0 +1,110 @@
+// Code generated by easyjson for marshaling/unmarshaling. DO NOT EDIT.
+
+package handlers
+
+import (
+	json "encoding/json"
+	easyjson "github.com/mailru/easyjson"
+	jlexer "github.com/mailru/easyjson/jlexer"
+	jwriter "github.com/mailru/easyjson/jwriter"
+	time "time"
+)
+
+// suppress unused package warning
+var (
+	_ *json.RawMessage
+	_ *jlexer.Lexer
+	_ *jwriter.Writer
+	_ easyjson.Marshaler
+)
+
+func easyjson6e73809bDecodeGithubComUjweghGophermartInternalAppHandlers(in *jlexer.Lexer, out *ProcessorHealthDTO) {
+	isTopLevel := in.IsStart()
+	if in.IsNull() {
+		if isTopLevel {
+			in.Consumed()
+		}
+		in.Skip()
+		return
+	}
+	in.Delim('{')
+	for !in.IsDelim('}') {
+		key := in.UnsafeFieldName(false)
+		in.WantColon()
+		if in.IsNull() {
+			in.Skip()
+			in.WantComma()
+			continue
+		}
+		switch key {
+		case "healthy":
+			out.Healthy = bool(in.Bool())
+		case "last_heartbeat":
+			if in.IsNull() {
+				in.Skip()
+				out.LastHeartbeat = nil
+			} else {
+				if out.LastHeartbeat == nil {
+					out.LastHeartbeat = new(time.Time)
+				}
+				if data := in.Raw(); in.Ok() {
+					in.AddError((*out.LastHeartbeat).UnmarshalJSON(data))
+				}
+			}
+		case "stale_after_sec":
+			out.StaleAfterSec = int(in.Int())
+		default:
+			in.SkipRecursive()
+		}
+		in.WantComma()
+	}
+	in.Delim('}')
+	if isTopLevel {
+		in.Consumed()
+	}
+}
+func easyjson6e73809bEncodeGithubComUjweghGophermartInternalAppHandlers(out *jwriter.Writer, in ProcessorHealthDTO) {
+	out.RawByte('{')
+	first := true
+	_ = first
+	{
+		const prefix string = ",\"healthy\":"
+		out.RawString(prefix[1:])
+		out.Bool(bool(in.Healthy))
+	}
+	if in.LastHeartbeat != nil {
+		const prefix string = ",\"last_heartbeat\":"
+		out.RawString(prefix)
+		out.Raw((*in.LastHeartbeat).MarshalJSON())
+	}
+	{
+		const prefix string = ",\"stale_after_sec\":"
+		out.RawString(prefix)
+		out.Int(int(in.StaleAfterSec))
+	}
+	out.RawByte('}')
+}
+
+// MarshalJSON supports json.Marshaler interface
+func (v ProcessorHealthDTO) MarshalJSON() ([]byte, error) {
+	w := jwriter.Writer{}
+	easyjson6e73809bEncodeGithubComUjweghGophermartInternalAppHandlers(&w, v)
+	return w.Buffer.BuildBytes(), w.Error
+}
+
+// MarshalEasyJSON supports easyjson.Marshaler interface
+func (v ProcessorHealthDTO) MarshalEasyJSON(w *jwriter.Writer) {
+	easyjson6e73809bEncodeGithubComUjweghGophermartInternalAppHandlers(w, v)
+}
+
+// UnmarshalJSON supports json.Unmarshaler interface
+func (v *ProcessorHealthDTO) UnmarshalJSON(data []byte) error {
+	r := jlexer.Lexer{Data: data}
+	easyjson6e73809bDecodeGithubComUjweghGophermartInternalAppHandlers(&r, v)
+	return r.Error()
+}
+
+// UnmarshalEasyJSON supports easyjson.Unmarshaler interface
+func (v *ProcessorHealthDTO) UnmarshalEasyJSON(l *jlexer.Lexer) {
+	easyjson6e73809bDecodeGithubComUjweghGophermartInternalAppHandlers(l, v)
+}
@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type stubHeartbeat time.Time
+
+func (s stubHeartbeat) LastHeartbeat() time.Time {
+	return time.Time(s)
+}
+
+func TestHealthHandler_ProcessorHealth(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name             string
+		heartbeat        time.Time
+		wantStatusCode   int
+		wantResponseBody string
+	}{
+		{
+			name:             "Recent Heartbeat",
+			heartbeat:        now.Add(-10 * time.Second),
+			wantStatusCode:   http.StatusOK,
+			wantResponseBody: `{"healthy":true,"last_heartbeat":"2024-01-01T11:59:50Z","stale_after_sec":60}`,
+		},
+		{
+			name:             "Stalled Processor",
+			heartbeat:        now.Add(-2 * time.Minute),
+			wantStatusCode:   http.StatusServiceUnavailable,
+			wantResponseBody: `{"healthy":false,"last_heartbeat":"2024-01-01T11:58:00Z","stale_after_sec":60}`,
+		},
+		{
+			name:             "Processor Not Started",
+			wantStatusCode:   http.StatusServiceUnavailable,
+			wantResponseBody: `{"healthy":false,"stale_after_sec":60}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hh := NewHealthHandler(stubHeartbeat(tt.heartbeat), 60)
+			hh.now = func() time.Time { return now }
+			req := httptest.NewRequest("GET", "/healthz/processor", nil)
+			rr := httptest.NewRecorder()
+
+			hh.ProcessorHealth(rr, req)
+
+			assert.Equal(t, tt.wantStatusCode, rr.Code)
+			assert.JSONEq(t, tt.wantResponseBody, rr.Body.String())
+		})
+	}
+}
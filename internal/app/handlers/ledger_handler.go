@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	appContext "github.com/ujwegh/gophermart/internal/app/context"
+	"github.com/ujwegh/gophermart/internal/app/service"
+	"net/http"
+	"time"
+)
+
+type (
+	LedgerHandler struct {
+		ledgerService  service.LedgerService
+		contextTimeout time.Duration
+		pagination     Pagination
+	}
+
+	//easyjson:json
+	LedgerEntryDTO struct {
+		Type        string    `json:"type"`
+		OrderID     string    `json:"order"`
+		Sum         float64   `json:"sum"`
+		ProcessedAt time.Time `json:"processed_at"`
+	}
+	//easyjson:json
+	LedgerEntryDTOSlice []LedgerEntryDTO
+)
+
+func NewLedgerHandler(contextTimeoutSec int, pagination Pagination, ledgerService service.LedgerService) *LedgerHandler {
+	return &LedgerHandler{
+		ledgerService:  ledgerService,
+		contextTimeout: time.Duration(contextTimeoutSec) * time.Second,
+		pagination:     pagination,
+	}
+}
+
+// GetLedger godoc
+// @Summary Receiving the user's accruals and withdrawals in one list
+// @Description The handler returns accruals of processed orders and withdrawals of an authorized user
+// @Description merged into a single list sorted from oldest to newest. The type field tells the entries apart.
+// @Tags balance
+// @Produce json
+// @Param limit query int false "Page size, clamped to the configured maximum"
+// @Param offset query int false "Number of entries to skip"
+// @Success 200 {array} LedgerEntryDTO "List of accrual and withdrawal entries"
+// @Success 204 "No entries to display"
+// @Failure 400 {object} ErrorResponse "Bad Request - Invalid pagination parameters"
+// @Failure 401 {object} ErrorResponse "Unauthorized - The user is not authorized"
+// @Failure 500 {object} ErrorResponse "Internal Server Error"
+// @Security ApiKeyAuth
+// @Router /api/user/ledger [get]
+func (lh *LedgerHandler) GetLedger(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), lh.contextTimeout)
+	defer cancel()
+	userUID := appContext.UserUID(r.Context())
+	page, err := lh.pagination.ParsePage(r)
+	if err != nil {
+		PrepareError(w, r, err)
+		return
+	}
+
+	entries, err := lh.ledgerService.GetLedger(ctx, userUID, page.Limit, page.Offset)
+	if err != nil {
+		PrepareError(w, r, err)
+		return
+	}
+	if len(*entries) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	response := make(LedgerEntryDTOSlice, 0, len(*entries))
+	for _, entry := range *entries {
+		response = append(response, LedgerEntryDTO{
+			Type:        string(entry.Type),
+			OrderID:     entry.OrderID,
+			Sum:         entry.Amount,
+			ProcessedAt: entry.CreatedAt,
+		})
+	}
+	rawBytes, err := response.MarshalJSON()
+	if err != nil {
+		PrepareError(w, r, fmt.Errorf("unable to marshal response: %w", err))
+		return
+	}
+
+	err = appContext.GetContextError(ctx)
+	if err != nil {
+		PrepareError(w, r, err)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(rawBytes)
+}
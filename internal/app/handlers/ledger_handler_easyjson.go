@@ -0,0 +1,174 @@
+// Code generated by easyjson for marshaling/unmarshaling. DO NOT EDIT.
+
+package handlers
+
+import (
+	json "encoding/json"
+	easyjson "github.com/mailru/easyjson"
+	jlexer "github.com/mailru/easyjson/jlexer"
+	jwriter "github.com/mailru/easyjson/jwriter"
+)
+
+// suppress unused package warning
+var (
+	_ *json.RawMessage
+	_ *jlexer.Lexer
+	_ *jwriter.Writer
+	_ easyjson.Marshaler
+)
+
+func easyjson30f2d73eDecodeGithubComUjweghGophermartInternalAppHandlers(in *jlexer.Lexer, out *LedgerEntryDTOSlice) {
+	isTopLevel := in.IsStart()
+	if in.IsNull() {
+		in.Skip()
+		*out = nil
+	} else {
+		in.Delim('[')
+		if *out == nil {
+			if !in.IsDelim(']') {
+				*out = make(LedgerEntryDTOSlice, 0, 1)
+			} else {
+				*out = LedgerEntryDTOSlice{}
+			}
+		} else {
+			*out = (*out)[:0]
+		}
+		for !in.IsDelim(']') {
+			var v1 LedgerEntryDTO
+			(v1).UnmarshalEasyJSON(in)
+			*out = append(*out, v1)
+			in.WantComma()
+		}
+		in.Delim(']')
+	}
+	if isTopLevel {
+		in.Consumed()
+	}
+}
+func easyjson30f2d73eEncodeGithubComUjweghGophermartInternalAppHandlers(out *jwriter.Writer, in LedgerEntryDTOSlice) {
+	if in == nil && (out.Flags&jwriter.NilSliceAsEmpty) == 0 {
+		out.RawString("null")
+	} else {
+		out.RawByte('[')
+		for v2, v3 := range in {
+			if v2 > 0 {
+				out.RawByte(',')
+			}
+			(v3).MarshalEasyJSON(out)
+		}
+		out.RawByte(']')
+	}
+}
+
+// MarshalJSON supports json.Marshaler interface
+func (v LedgerEntryDTOSlice) MarshalJSON() ([]byte, error) {
+	w := jwriter.Writer{}
+	easyjson30f2d73eEncodeGithubComUjweghGophermartInternalAppHandlers(&w, v)
+	return w.Buffer.BuildBytes(), w.Error
+}
+
+// MarshalEasyJSON supports easyjson.Marshaler interface
+func (v LedgerEntryDTOSlice) MarshalEasyJSON(w *jwriter.Writer) {
+	easyjson30f2d73eEncodeGithubComUjweghGophermartInternalAppHandlers(w, v)
+}
+
+// UnmarshalJSON supports json.Unmarshaler interface
+func (v *LedgerEntryDTOSlice) UnmarshalJSON(data []byte) error {
+	r := jlexer.Lexer{Data: data}
+	easyjson30f2d73eDecodeGithubComUjweghGophermartInternalAppHandlers(&r, v)
+	return r.Error()
+}
+
+// UnmarshalEasyJSON supports easyjson.Unmarshaler interface
+func (v *LedgerEntryDTOSlice) UnmarshalEasyJSON(l *jlexer.Lexer) {
+	easyjson30f2d73eDecodeGithubComUjweghGophermartInternalAppHandlers(l, v)
+}
+func easyjson30f2d73eDecodeGithubComUjweghGophermartInternalAppHandlers1(in *jlexer.Lexer, out *LedgerEntryDTO) {
+	isTopLevel := in.IsStart()
+	if in.IsNull() {
+		if isTopLevel {
+			in.Consumed()
+		}
+		in.Skip()
+		return
+	}
+	in.Delim('{')
+	for !in.IsDelim('}') {
+		key := in.UnsafeFieldName(false)
+		in.WantColon()
+		if in.IsNull() {
+			in.Skip()
+			in.WantComma()
+			continue
+		}
+		switch key {
+		case "type":
+			out.Type = string(in.String())
+		case "order":
+			out.OrderID = string(in.String())
+		case "sum":
+			out.Sum = float64(in.Float64())
+		case "processed_at":
+			if data := in.Raw(); in.Ok() {
+				in.AddError((out.ProcessedAt).UnmarshalJSON(data))
+			}
+		default:
+			in.SkipRecursive()
+		}
+		in.WantComma()
+	}
+	in.Delim('}')
+	if isTopLevel {
+		in.Consumed()
+	}
+}
+func easyjson30f2d73eEncodeGithubComUjweghGophermartInternalAppHandlers1(out *jwriter.Writer, in LedgerEntryDTO) {
+	out.RawByte('{')
+	first := true
+	_ = first
+	{
+		const prefix string = ",\"type\":"
+		out.RawString(prefix[1:])
+		out.String(string(in.Type))
+	}
+	{
+		const prefix string = ",\"order\":"
+		out.RawString(prefix)
+		out.String(string(in.OrderID))
+	}
+	{
+		const prefix string = ",\"sum\":"
+		out.RawString(prefix)
+		out.Float64(float64(in.Sum))
+	}
+	{
+		const prefix string = ",\"processed_at\":"
+		out.RawString(prefix)
+		out.Raw((in.ProcessedAt).MarshalJSON())
+	}
+	out.RawByte('}')
+}
+
+// MarshalJSON supports json.Marshaler interface
+func (v LedgerEntryDTO) MarshalJSON() ([]byte, error) {
+	w := jwriter.Writer{}
+	easyjson30f2d73eEncodeGithubComUjweghGophermartInternalAppHandlers1(&w, v)
+	return w.Buffer.BuildBytes(), w.Error
+}
+
+// MarshalEasyJSON supports easyjson.Marshaler interface
+func (v LedgerEntryDTO) MarshalEasyJSON(w *jwriter.Writer) {
+	easyjson30f2d73eEncodeGithubComUjweghGophermartInternalAppHandlers1(w, v)
+}
+
+// UnmarshalJSON supports json.Unmarshaler interface
+func (v *LedgerEntryDTO) UnmarshalJSON(data []byte) error {
+	r := jlexer.Lexer{Data: data}
+	easyjson30f2d73eDecodeGithubComUjweghGophermartInternalAppHandlers1(&r, v)
+	return r.Error()
+}
+
+// UnmarshalEasyJSON supports easyjson.Unmarshaler interface
+func (v *LedgerEntryDTO) UnmarshalEasyJSON(l *jlexer.Lexer) {
+	easyjson30f2d73eDecodeGithubComUjweghGophermartInternalAppHandlers1(l, v)
+}
@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"errors"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	appContext "github.com/ujwegh/gophermart/internal/app/context"
+	"github.com/ujwegh/gophermart/internal/app/repository"
+	"github.com/ujwegh/gophermart/internal/app/service"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestLedgerHandler_GetLedger(t *testing.T) {
+	userUID := uuid.New()
+	day := func(d int) time.Time { return time.Date(2024, 1, d, 0, 0, 0, 0, time.UTC) }
+	accrual1, accrual2 := 500.0, 80.0
+	orders := &[]repository.Order{
+		{ID: "order1", Status: repository.PROCESSED, Accrual: &accrual1, UpdatedAt: day(1)},
+		{ID: "order3", Status: repository.PROCESSED, Accrual: &accrual2, UpdatedAt: day(3)},
+	}
+	withdrawals := &[]repository.Withdrawal{
+		{OrderID: "order2", Amount: 100_00, CreatedAt: day(2)},
+		{OrderID: "order4", Amount: 50_50, CreatedAt: day(4)},
+	}
+	tests := []struct {
+		name                  string
+		query                 string
+		mockOrderService      func() *MockOrderService
+		mockWithdrawalService func() *MockWithdrawalService
+		wantStatusCode        int
+		wantResponseBody      string
+	}{
+		{
+			name:  "Entries Merged By Time",
+			query: "",
+			mockOrderService: func() *MockOrderService {
+				m := &MockOrderService{}
+				m.On("GetProcessedOrders", mock.Anything, &userUID, 100, 0).Return(orders, nil)
+				return m
+			},
+			mockWithdrawalService: func() *MockWithdrawalService {
+				m := &MockWithdrawalService{}
+				m.On("GetWithdrawals", mock.Anything, &userUID, 100, 0).Return(withdrawals, nil)
+				return m
+			},
+			wantStatusCode: http.StatusOK,
+			wantResponseBody: `[
+				{"type":"accrual","order":"order1","sum":500,"processed_at":"2024-01-01T00:00:00Z"},
+				{"type":"withdrawal","order":"order2","sum":100,"processed_at":"2024-01-02T00:00:00Z"},
+				{"type":"accrual","order":"order3","sum":80,"processed_at":"2024-01-03T00:00:00Z"},
+				{"type":"withdrawal","order":"order4","sum":50.5,"processed_at":"2024-01-04T00:00:00Z"}
+			]`,
+		},
+		{
+			name:  "Page Taken From Merged List",
+			query: "?limit=2&offset=1",
+			mockOrderService: func() *MockOrderService {
+				m := &MockOrderService{}
+				m.On("GetProcessedOrders", mock.Anything, &userUID, 3, 0).Return(orders, nil)
+				return m
+			},
+			mockWithdrawalService: func() *MockWithdrawalService {
+				m := &MockWithdrawalService{}
+				m.On("GetWithdrawals", mock.Anything, &userUID, 3, 0).Return(withdrawals, nil)
+				return m
+			},
+			wantStatusCode: http.StatusOK,
+			wantResponseBody: `[
+				{"type":"withdrawal","order":"order2","sum":100,"processed_at":"2024-01-02T00:00:00Z"},
+				{"type":"accrual","order":"order3","sum":80,"processed_at":"2024-01-03T00:00:00Z"}
+			]`,
+		},
+		{
+			name: "Empty Ledger",
+			mockOrderService: func() *MockOrderService {
+				m := &MockOrderService{}
+				m.On("GetProcessedOrders", mock.Anything, &userUID, 100, 0).Return(&[]repository.Order{}, nil)
+				return m
+			},
+			mockWithdrawalService: func() *MockWithdrawalService {
+				m := &MockWithdrawalService{}
+				m.On("GetWithdrawals", mock.Anything, &userUID, 100, 0).Return(&[]repository.Withdrawal{}, nil)
+				return m
+			},
+			wantStatusCode: http.StatusNoContent,
+		},
+		{
+			name: "Error In Withdrawal Retrieval",
+			mockOrderService: func() *MockOrderService {
+				m := &MockOrderService{}
+				m.On("GetProcessedOrders", mock.Anything, &userUID, 100, 0).Return(orders, nil)
+				return m
+			},
+			mockWithdrawalService: func() *MockWithdrawalService {
+				m := &MockWithdrawalService{}
+				m.On("GetWithdrawals", mock.Anything, &userUID, 100, 0).Return((*[]repository.Withdrawal)(nil), errors.New("db down"))
+				return m
+			},
+			wantStatusCode:   http.StatusInternalServerError,
+			wantResponseBody: `{"code":500,"message":"Internal Server Error"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/api/user/ledger"+tt.query, nil)
+			req = req.WithContext(appContext.WithUserUID(req.Context(), &userUID))
+			w := httptest.NewRecorder()
+
+			lh := NewLedgerHandler(5, NewPagination(100, 1000),
+				service.NewLedgerService(tt.mockOrderService(), tt.mockWithdrawalService()))
+			lh.GetLedger(w, req)
+
+			assert.Equal(t, tt.wantStatusCode, w.Code)
+			if tt.wantResponseBody != "" {
+				assert.JSONEq(t, tt.wantResponseBody, w.Body.String())
+			} else {
+				assert.Empty(t, w.Body.String())
+			}
+		})
+	}
+}
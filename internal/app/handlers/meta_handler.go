@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"fmt"
+	"github.com/ujwegh/gophermart/internal/app/repository"
+	"net/http"
+)
+
+type (
+	MetaHandler struct{}
+
+	//easyjson:json
+	StatusDTO struct {
+		Status      string `json:"status"`
+		Description string `json:"description"`
+	}
+
+	//easyjson:json
+	StatusesDTO struct {
+		Orders      []StatusDTO `json:"orders"`
+		Withdrawals []StatusDTO `json:"withdrawals"`
+	}
+)
+
+// withdrawalStatuses describes the statuses GET /api/user/withdrawals/by-key/{key} reports.
+var withdrawalStatuses = []StatusDTO{
+	{Status: withdrawalStatusWithdrawn, Description: "The sum is debited from the balance"},
+	{Status: withdrawalStatusReversed, Description: "The withdrawal has been refunded to the balance"},
+}
+
+func NewMetaHandler() *MetaHandler {
+	return &MetaHandler{}
+}
+
+// GetStatuses godoc
+// @Summary Listing the order and withdrawal statuses
+// @Description The handler returns every status an order can have, in the order an order goes through them,
+// @Description and every status a withdrawal can have, each with a description.
+// @Tags meta
+// @Produce json
+// @Success 200 {object} StatusesDTO "The order and withdrawal statuses"
+// @Failure 500 {object} ErrorResponse "Internal Server Error"
+// @Router /api/meta/statuses [get]
+func (mh *MetaHandler) GetStatuses(w http.ResponseWriter, r *http.Request) {
+	orderStatuses := repository.Statuses()
+	response := StatusesDTO{
+		Orders:      make([]StatusDTO, 0, len(orderStatuses)),
+		Withdrawals: withdrawalStatuses,
+	}
+	for _, status := range orderStatuses {
+		response.Orders = append(response.Orders, StatusDTO{Status: status.String(), Description: status.Description()})
+	}
+	rawBytes, err := response.MarshalJSON()
+	if err != nil {
+		PrepareError(w, r, fmt.Errorf("marshal response: %w", err))
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(rawBytes)
+}
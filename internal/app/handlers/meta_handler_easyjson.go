@@ -0,0 +1,229 @@
+// Code generated by easyjson for marshaling/unmarshaling. DO NOT EDIT.
+
+package handlers
+
+import (
+	json "encoding/json"
+	easyjson "github.com/mailru/easyjson"
+	jlexer "github.com/mailru/easyjson/jlexer"
+	jwriter "github.com/mailru/easyjson/jwriter"
+)
+
+// suppress unused package warning
+var (
+	_ *json.RawMessage
+	_ *jlexer.Lexer
+	_ *jwriter.Writer
+	_ easyjson.Marshaler
+)
+
+func easyjson766a5c2DecodeGithubComUjweghGophermartInternalAppHandlers(in *jlexer.Lexer, out *StatusesDTO) {
+	isTopLevel := in.IsStart()
+	if in.IsNull() {
+		if isTopLevel {
+			in.Consumed()
+		}
+		in.Skip()
+		return
+	}
+	in.Delim('{')
+	for !in.IsDelim('}') {
+		key := in.UnsafeFieldName(false)
+		in.WantColon()
+		if in.IsNull() {
+			in.Skip()
+			in.WantComma()
+			continue
+		}
+		switch key {
+		case "orders":
+			if in.IsNull() {
+				in.Skip()
+				out.Orders = nil
+			} else {
+				in.Delim('[')
+				if out.Orders == nil {
+					if !in.IsDelim(']') {
+						out.Orders = make([]StatusDTO, 0, 2)
+					} else {
+						out.Orders = []StatusDTO{}
+					}
+				} else {
+					out.Orders = (out.Orders)[:0]
+				}
+				for !in.IsDelim(']') {
+					var v1 StatusDTO
+					(v1).UnmarshalEasyJSON(in)
+					out.Orders = append(out.Orders, v1)
+					in.WantComma()
+				}
+				in.Delim(']')
+			}
+		case "withdrawals":
+			if in.IsNull() {
+				in.Skip()
+				out.Withdrawals = nil
+			} else {
+				in.Delim('[')
+				if out.Withdrawals == nil {
+					if !in.IsDelim(']') {
+						out.Withdrawals = make([]StatusDTO, 0, 2)
+					} else {
+						out.Withdrawals = []StatusDTO{}
+					}
+				} else {
+					out.Withdrawals = (out.Withdrawals)[:0]
+				}
+				for !in.IsDelim(']') {
+					var v2 StatusDTO
+					(v2).UnmarshalEasyJSON(in)
+					out.Withdrawals = append(out.Withdrawals, v2)
+					in.WantComma()
+				}
+				in.Delim(']')
+			}
+		default:
+			in.SkipRecursive()
+		}
+		in.WantComma()
+	}
+	in.Delim('}')
+	if isTopLevel {
+		in.Consumed()
+	}
+}
+func easyjson766a5c2EncodeGithubComUjweghGophermartInternalAppHandlers(out *jwriter.Writer, in StatusesDTO) {
+	out.RawByte('{')
+	first := true
+	_ = first
+	{
+		const prefix string = ",\"orders\":"
+		out.RawString(prefix[1:])
+		if in.Orders == nil && (out.Flags&jwriter.NilSliceAsEmpty) == 0 {
+			out.RawString("null")
+		} else {
+			out.RawByte('[')
+			for v3, v4 := range in.Orders {
+				if v3 > 0 {
+					out.RawByte(',')
+				}
+				(v4).MarshalEasyJSON(out)
+			}
+			out.RawByte(']')
+		}
+	}
+	{
+		const prefix string = ",\"withdrawals\":"
+		out.RawString(prefix)
+		if in.Withdrawals == nil && (out.Flags&jwriter.NilSliceAsEmpty) == 0 {
+			out.RawString("null")
+		} else {
+			out.RawByte('[')
+			for v5, v6 := range in.Withdrawals {
+				if v5 > 0 {
+					out.RawByte(',')
+				}
+				(v6).MarshalEasyJSON(out)
+			}
+			out.RawByte(']')
+		}
+	}
+	out.RawByte('}')
+}
+
+// MarshalJSON supports json.Marshaler interface
+func (v StatusesDTO) MarshalJSON() ([]byte, error) {
+	w := jwriter.Writer{}
+	easyjson766a5c2EncodeGithubComUjweghGophermartInternalAppHandlers(&w, v)
+	return w.Buffer.BuildBytes(), w.Error
+}
+
+// MarshalEasyJSON supports easyjson.Marshaler interface
+func (v StatusesDTO) MarshalEasyJSON(w *jwriter.Writer) {
+	easyjson766a5c2EncodeGithubComUjweghGophermartInternalAppHandlers(w, v)
+}
+
+// UnmarshalJSON supports json.Unmarshaler interface
+func (v *StatusesDTO) UnmarshalJSON(data []byte) error {
+	r := jlexer.Lexer{Data: data}
+	easyjson766a5c2DecodeGithubComUjweghGophermartInternalAppHandlers(&r, v)
+	return r.Error()
+}
+
+// UnmarshalEasyJSON supports easyjson.Unmarshaler interface
+func (v *StatusesDTO) UnmarshalEasyJSON(l *jlexer.Lexer) {
+	easyjson766a5c2DecodeGithubComUjweghGophermartInternalAppHandlers(l, v)
+}
+func easyjson766a5c2DecodeGithubComUjweghGophermartInternalAppHandlers1(in *jlexer.Lexer, out *StatusDTO) {
+	isTopLevel := in.IsStart()
+	if in.IsNull() {
+		if isTopLevel {
+			in.Consumed()
+		}
+		in.Skip()
+		return
+	}
+	in.Delim('{')
+	for !in.IsDelim('}') {
+		key := in.UnsafeFieldName(false)
+		in.WantColon()
+		if in.IsNull() {
+			in.Skip()
+			in.WantComma()
+			continue
+		}
+		switch key {
+		case "status":
+			out.Status = string(in.String())
+		case "description":
+			out.Description = string(in.String())
+		default:
+			in.SkipRecursive()
+		}
+		in.WantComma()
+	}
+	in.Delim('}')
+	if isTopLevel {
+		in.Consumed()
+	}
+}
+func easyjson766a5c2EncodeGithubComUjweghGophermartInternalAppHandlers1(out *jwriter.Writer, in StatusDTO) {
+	out.RawByte('{')
+	first := true
+	_ = first
+	{
+		const prefix string = ",\"status\":"
+		out.RawString(prefix[1:])
+		out.String(string(in.Status))
+	}
+	{
+		const prefix string = ",\"description\":"
+		out.RawString(prefix)
+		out.String(string(in.Description))
+	}
+	out.RawByte('}')
+}
+
+// MarshalJSON supports json.Marshaler interface
+func (v StatusDTO) MarshalJSON() ([]byte, error) {
+	w := jwriter.Writer{}
+	easyjson766a5c2EncodeGithubComUjweghGophermartInternalAppHandlers1(&w, v)
+	return w.Buffer.BuildBytes(), w.Error
+}
+
+// MarshalEasyJSON supports easyjson.Marshaler interface
+func (v StatusDTO) MarshalEasyJSON(w *jwriter.Writer) {
+	easyjson766a5c2EncodeGithubComUjweghGophermartInternalAppHandlers1(w, v)
+}
+
+// UnmarshalJSON supports json.Unmarshaler interface
+func (v *StatusDTO) UnmarshalJSON(data []byte) error {
+	r := jlexer.Lexer{Data: data}
+	easyjson766a5c2DecodeGithubComUjweghGophermartInternalAppHandlers1(&r, v)
+	return r.Error()
+}
+
+// UnmarshalEasyJSON supports easyjson.Unmarshaler interface
+func (v *StatusDTO) UnmarshalEasyJSON(l *jlexer.Lexer) {
+	easyjson766a5c2DecodeGithubComUjweghGophermartInternalAppHandlers1(l, v)
+}
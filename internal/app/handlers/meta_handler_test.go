@@ -0,0 +1,30 @@
+package handlers
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMetaHandler_GetStatuses(t *testing.T) {
+	req := httptest.NewRequest("GET", "/api/meta/statuses", nil)
+	rr := httptest.NewRecorder()
+
+	NewMetaHandler().GetStatuses(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "application/json", rr.Header().Get("Content-Type"))
+	assert.JSONEq(t, `{
+		"orders":[
+			{"status":"NEW","description":"The order is uploaded but not processed yet"},
+			{"status":"PROCESSING","description":"The accrual system is calculating the reward for the order"},
+			{"status":"INVALID","description":"The accrual system declined the order, no reward will be credited"},
+			{"status":"PROCESSED","description":"The reward for the order is calculated and credited"}
+		],
+		"withdrawals":[
+			{"status":"WITHDRAWN","description":"The sum is debited from the balance"},
+			{"status":"REVERSED","description":"The withdrawal has been refunded to the balance"}
+		]
+	}`, rr.Body.String())
+}
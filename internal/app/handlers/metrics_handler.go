@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"fmt"
+	"github.com/ujwegh/gophermart/internal/app/service"
+	"net/http"
+)
+
+type (
+	MetricsHandler struct {
+		processorMetrics service.ProcessorMetricsProvider
+		queue            service.QueueDepthProvider
+	}
+
+	//easyjson:json
+	ProcessorMetricsDTO struct {
+		OrdersRecached  int64 `json:"orders_recached"`
+		OrdersExhausted int64 `json:"orders_exhausted"`
+		CacheSize       int   `json:"cache_size"`
+	}
+
+	//easyjson:json
+	QueueDepthDTO struct {
+		Queued   int `json:"queued"`
+		Capacity int `json:"capacity"`
+		Cached   int `json:"cached"`
+	}
+)
+
+func NewMetricsHandler(processorMetrics service.ProcessorMetricsProvider, queue service.QueueDepthProvider) *MetricsHandler {
+	return &MetricsHandler{processorMetrics: processorMetrics, queue: queue}
+}
+
+// GetMetrics godoc
+// @Summary Order processor retry budget metrics
+// @Description The handler returns how many orders were sent back to the cache to be polled again and how many
+// @Description were marked INVALID after reaching the max attempts or lookup failures since start, and how many orders wait in the cache now.
+// @Tags admin
+// @Produce json
+// @Success 200 {object} ProcessorMetricsDTO "Order processor counters"
+// @Failure 401 {object} ErrorResponse "Unauthorized - The user is not authorized"
+// @Failure 403 {object} ErrorResponse "Forbidden - The user is not an admin"
+// @Failure 500 {object} ErrorResponse "Internal Server Error"
+// @Security ApiKeyAuth
+// @Router /admin/metrics [get]
+func (mh *MetricsHandler) GetMetrics(w http.ResponseWriter, r *http.Request) {
+	metrics := mh.processorMetrics.Metrics()
+	response := ProcessorMetricsDTO{
+		OrdersRecached:  metrics.Recached,
+		OrdersExhausted: metrics.Exhausted,
+		CacheSize:       metrics.CacheSize,
+	}
+	rawBytes, err := response.MarshalJSON()
+	if err != nil {
+		PrepareError(w, r, fmt.Errorf("marshal response: %w", err))
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(rawBytes)
+}
+
+// GetQueue godoc
+// @Summary Accrual processing queue depth
+// @Description The handler returns how many orders wait in the processing channel for an accrual lookup, the size of
+// @Description the channel buffer, and how many orders wait in the cache to be polled again.
+// @Tags admin
+// @Produce json
+// @Success 200 {object} QueueDepthDTO "Orders waiting for processing"
+// @Failure 401 {object} ErrorResponse "Unauthorized - The user is not authorized"
+// @Failure 403 {object} ErrorResponse "Forbidden - The user is not an admin"
+// @Failure 500 {object} ErrorResponse "Internal Server Error"
+// @Security ApiKeyAuth
+// @Router /admin/queue [get]
+func (mh *MetricsHandler) GetQueue(w http.ResponseWriter, r *http.Request) {
+	depth := mh.queue.QueueDepth()
+	response := QueueDepthDTO{
+		Queued:   depth.Queued,
+		Capacity: depth.Capacity,
+		Cached:   depth.Cached,
+	}
+	rawBytes, err := response.MarshalJSON()
+	if err != nil {
+		PrepareError(w, r, fmt.Errorf("marshal response: %w", err))
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(rawBytes)
+}
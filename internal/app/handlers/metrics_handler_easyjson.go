@@ -0,0 +1,179 @@
+// Code generated by easyjson for marshaling/unmarshaling. DO NOT EDIT.
+
+package handlers
+
+import (
+	json "encoding/json"
+	easyjson "github.com/mailru/easyjson"
+	jlexer "github.com/mailru/easyjson/jlexer"
+	jwriter "github.com/mailru/easyjson/jwriter"
+)
+
+// suppress unused package warning
+var (
+	_ *json.RawMessage
+	_ *jlexer.Lexer
+	_ *jwriter.Writer
+	_ easyjson.Marshaler
+)
+
+func easyjson8562e294DecodeGithubComUjweghGophermartInternalAppHandlers(in *jlexer.Lexer, out *QueueDepthDTO) {
+	isTopLevel := in.IsStart()
+	if in.IsNull() {
+		if isTopLevel {
+			in.Consumed()
+		}
+		in.Skip()
+		return
+	}
+	in.Delim('{')
+	for !in.IsDelim('}') {
+		key := in.UnsafeFieldName(false)
+		in.WantColon()
+		if in.IsNull() {
+			in.Skip()
+			in.WantComma()
+			continue
+		}
+		switch key {
+		case "queued":
+			out.Queued = int(in.Int())
+		case "capacity":
+			out.Capacity = int(in.Int())
+		case "cached":
+			out.Cached = int(in.Int())
+		default:
+			in.SkipRecursive()
+		}
+		in.WantComma()
+	}
+	in.Delim('}')
+	if isTopLevel {
+		in.Consumed()
+	}
+}
+func easyjson8562e294EncodeGithubComUjweghGophermartInternalAppHandlers(out *jwriter.Writer, in QueueDepthDTO) {
+	out.RawByte('{')
+	first := true
+	_ = first
+	{
+		const prefix string = ",\"queued\":"
+		out.RawString(prefix[1:])
+		out.Int(int(in.Queued))
+	}
+	{
+		const prefix string = ",\"capacity\":"
+		out.RawString(prefix)
+		out.Int(int(in.Capacity))
+	}
+	{
+		const prefix string = ",\"cached\":"
+		out.RawString(prefix)
+		out.Int(int(in.Cached))
+	}
+	out.RawByte('}')
+}
+
+// MarshalJSON supports json.Marshaler interface
+func (v QueueDepthDTO) MarshalJSON() ([]byte, error) {
+	w := jwriter.Writer{}
+	easyjson8562e294EncodeGithubComUjweghGophermartInternalAppHandlers(&w, v)
+	return w.Buffer.BuildBytes(), w.Error
+}
+
+// MarshalEasyJSON supports easyjson.Marshaler interface
+func (v QueueDepthDTO) MarshalEasyJSON(w *jwriter.Writer) {
+	easyjson8562e294EncodeGithubComUjweghGophermartInternalAppHandlers(w, v)
+}
+
+// UnmarshalJSON supports json.Unmarshaler interface
+func (v *QueueDepthDTO) UnmarshalJSON(data []byte) error {
+	r := jlexer.Lexer{Data: data}
+	easyjson8562e294DecodeGithubComUjweghGophermartInternalAppHandlers(&r, v)
+	return r.Error()
+}
+
+// UnmarshalEasyJSON supports easyjson.Unmarshaler interface
+func (v *QueueDepthDTO) UnmarshalEasyJSON(l *jlexer.Lexer) {
+	easyjson8562e294DecodeGithubComUjweghGophermartInternalAppHandlers(l, v)
+}
+func easyjson8562e294DecodeGithubComUjweghGophermartInternalAppHandlers1(in *jlexer.Lexer, out *ProcessorMetricsDTO) {
+	isTopLevel := in.IsStart()
+	if in.IsNull() {
+		if isTopLevel {
+			in.Consumed()
+		}
+		in.Skip()
+		return
+	}
+	in.Delim('{')
+	for !in.IsDelim('}') {
+		key := in.UnsafeFieldName(false)
+		in.WantColon()
+		if in.IsNull() {
+			in.Skip()
+			in.WantComma()
+			continue
+		}
+		switch key {
+		case "orders_recached":
+			out.OrdersRecached = int64(in.Int64())
+		case "orders_exhausted":
+			out.OrdersExhausted = int64(in.Int64())
+		case "cache_size":
+			out.CacheSize = int(in.Int())
+		default:
+			in.SkipRecursive()
+		}
+		in.WantComma()
+	}
+	in.Delim('}')
+	if isTopLevel {
+		in.Consumed()
+	}
+}
+func easyjson8562e294EncodeGithubComUjweghGophermartInternalAppHandlers1(out *jwriter.Writer, in ProcessorMetricsDTO) {
+	out.RawByte('{')
+	first := true
+	_ = first
+	{
+		const prefix string = ",\"orders_recached\":"
+		out.RawString(prefix[1:])
+		out.Int64(int64(in.OrdersRecached))
+	}
+	{
+		const prefix string = ",\"orders_exhausted\":"
+		out.RawString(prefix)
+		out.Int64(int64(in.OrdersExhausted))
+	}
+	{
+		const prefix string = ",\"cache_size\":"
+		out.RawString(prefix)
+		out.Int(int(in.CacheSize))
+	}
+	out.RawByte('}')
+}
+
+// MarshalJSON supports json.Marshaler interface
+func (v ProcessorMetricsDTO) MarshalJSON() ([]byte, error) {
+	w := jwriter.Writer{}
+	easyjson8562e294EncodeGithubComUjweghGophermartInternalAppHandlers1(&w, v)
+	return w.Buffer.BuildBytes(), w.Error
+}
+
+// MarshalEasyJSON supports easyjson.Marshaler interface
+func (v ProcessorMetricsDTO) MarshalEasyJSON(w *jwriter.Writer) {
+	easyjson8562e294EncodeGithubComUjweghGophermartInternalAppHandlers1(w, v)
+}
+
+// UnmarshalJSON supports json.Unmarshaler interface
+func (v *ProcessorMetricsDTO) UnmarshalJSON(data []byte) error {
+	r := jlexer.Lexer{Data: data}
+	easyjson8562e294DecodeGithubComUjweghGophermartInternalAppHandlers1(&r, v)
+	return r.Error()
+}
+
+// UnmarshalEasyJSON supports easyjson.Unmarshaler interface
+func (v *ProcessorMetricsDTO) UnmarshalEasyJSON(l *jlexer.Lexer) {
+	easyjson8562e294DecodeGithubComUjweghGophermartInternalAppHandlers1(l, v)
+}
@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/ujwegh/gophermart/internal/app/service"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type stubProcessorMetrics struct {
+	metrics service.ProcessorMetrics
+}
+
+func (s stubProcessorMetrics) Metrics() service.ProcessorMetrics {
+	return s.metrics
+}
+
+type stubQueueDepth struct {
+	depth service.QueueDepth
+}
+
+func (s stubQueueDepth) QueueDepth() service.QueueDepth {
+	return s.depth
+}
+
+func TestMetricsHandler_GetMetrics(t *testing.T) {
+	mh := NewMetricsHandler(stubProcessorMetrics{metrics: service.ProcessorMetrics{Recached: 7, Exhausted: 2, CacheSize: 3}}, stubQueueDepth{})
+	req := httptest.NewRequest("GET", "/admin/metrics", nil)
+	rr := httptest.NewRecorder()
+
+	mh.GetMetrics(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "application/json", rr.Header().Get("Content-Type"))
+	assert.JSONEq(t, `{"orders_recached":7,"orders_exhausted":2,"cache_size":3}`, rr.Body.String())
+}
+
+func TestMetricsHandler_GetQueue(t *testing.T) {
+	mh := NewMetricsHandler(stubProcessorMetrics{}, stubQueueDepth{depth: service.QueueDepth{Queued: 4, Capacity: 100, Cached: 9}})
+	req := httptest.NewRequest("GET", "/admin/queue", nil)
+	rr := httptest.NewRecorder()
+
+	mh.GetQueue(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "application/json", rr.Header().Get("Content-Type"))
+	assert.JSONEq(t, `{"queued":4,"capacity":100,"cached":9}`, rr.Body.String())
+}
@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"fmt"
+	appErrors "github.com/ujwegh/gophermart/internal/app/errors"
+	"github.com/ujwegh/gophermart/internal/app/repository"
+	"net/http"
+)
+
+type (
+	MigrationsHandler struct {
+		migrations repository.MigrationVersionProvider
+		// latest is the version of the newest migration shipped with the binary
+		latest int64
+	}
+
+	//easyjson:json
+	MigrationStatusDTO struct {
+		Version int64 `json:"version"`
+	}
+	//easyjson:json
+	ReadinessDTO struct {
+		Ready   bool  `json:"ready"`
+		Version int64 `json:"version"`
+		Latest  int64 `json:"latest"`
+	}
+)
+
+func NewMigrationsHandler(migrations repository.MigrationVersionProvider, latest int64) *MigrationsHandler {
+	return &MigrationsHandler{migrations: migrations, latest: latest}
+}
+
+// GetMigrationStatus godoc
+// @Summary Database migration status
+// @Description The handler returns the version of the latest goose migration applied to the database.
+// @Tags admin
+// @Produce json
+// @Success 200 {object} MigrationStatusDTO "Applied migration version"
+// @Failure 401 {object} ErrorResponse "Unauthorized - The user is not authorized"
+// @Failure 403 {object} ErrorResponse "Forbidden - The user is not an admin"
+// @Failure 500 {object} ErrorResponse "Internal Server Error"
+// @Security ApiKeyAuth
+// @Router /admin/migrations [get]
+func (mh *MigrationsHandler) GetMigrationStatus(w http.ResponseWriter, r *http.Request) {
+	version, err := mh.migrations.MigrationVersion()
+	if err != nil {
+		PrepareError(w, r, err)
+		return
+	}
+	response := MigrationStatusDTO{Version: version}
+	rawBytes, err := response.MarshalJSON()
+	if err != nil {
+		PrepareError(w, r, fmt.Errorf("marshal response: %w", err))
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(rawBytes)
+}
+
+// Ready godoc
+// @Summary Readiness check
+// @Description The handler reports the instance ready once the database schema is at the newest migration
+// @Description shipped with the binary. It answers 503 while the schema is behind, e.g. when the server runs
+// @Description with SKIP_MIGRATIONS and they have not been applied yet, or the database can't be reached.
+// @Tags health
+// @Produce json
+// @Success 200 {object} ReadinessDTO "The schema is up to date"
+// @Failure 503 {object} ReadinessDTO "Service Unavailable - The schema is behind the migrations"
+// @Router /readyz [get]
+func (mh *MigrationsHandler) Ready(w http.ResponseWriter, r *http.Request) {
+	version, err := mh.migrations.MigrationVersion()
+	if err != nil {
+		err = appErrors.NewWithCode(err, "Service Unavailable: unable to read migration version", http.StatusServiceUnavailable)
+		PrepareError(w, r, err)
+		return
+	}
+	response := ReadinessDTO{Ready: version >= mh.latest, Version: version, Latest: mh.latest}
+	rawBytes, err := response.MarshalJSON()
+	if err != nil {
+		PrepareError(w, r, fmt.Errorf("marshal response: %w", err))
+		return
+	}
+
+	code := http.StatusOK
+	if !response.Ready {
+		code = http.StatusServiceUnavailable
+	}
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(code)
+	w.Write(rawBytes)
+}
@@ -0,0 +1,165 @@
+// Code generated by easyjson for marshaling/unmarshaling. DO NOT EDIT.
+
+package handlers
+
+import (
+	json "encoding/json"
+	easyjson "github.com/mailru/easyjson"
+	jlexer "github.com/mailru/easyjson/jlexer"
+	jwriter "github.com/mailru/easyjson/jwriter"
+)
+
+// suppress unused package warning
+var (
+	_ *json.RawMessage
+	_ *jlexer.Lexer
+	_ *jwriter.Writer
+	_ easyjson.Marshaler
+)
+
+func easyjson84a4d4aeDecodeGithubComUjweghGophermartInternalAppHandlers(in *jlexer.Lexer, out *ReadinessDTO) {
+	isTopLevel := in.IsStart()
+	if in.IsNull() {
+		if isTopLevel {
+			in.Consumed()
+		}
+		in.Skip()
+		return
+	}
+	in.Delim('{')
+	for !in.IsDelim('}') {
+		key := in.UnsafeFieldName(false)
+		in.WantColon()
+		if in.IsNull() {
+			in.Skip()
+			in.WantComma()
+			continue
+		}
+		switch key {
+		case "ready":
+			out.Ready = bool(in.Bool())
+		case "version":
+			out.Version = int64(in.Int64())
+		case "latest":
+			out.Latest = int64(in.Int64())
+		default:
+			in.SkipRecursive()
+		}
+		in.WantComma()
+	}
+	in.Delim('}')
+	if isTopLevel {
+		in.Consumed()
+	}
+}
+func easyjson84a4d4aeEncodeGithubComUjweghGophermartInternalAppHandlers(out *jwriter.Writer, in ReadinessDTO) {
+	out.RawByte('{')
+	first := true
+	_ = first
+	{
+		const prefix string = ",\"ready\":"
+		out.RawString(prefix[1:])
+		out.Bool(bool(in.Ready))
+	}
+	{
+		const prefix string = ",\"version\":"
+		out.RawString(prefix)
+		out.Int64(int64(in.Version))
+	}
+	{
+		const prefix string = ",\"latest\":"
+		out.RawString(prefix)
+		out.Int64(int64(in.Latest))
+	}
+	out.RawByte('}')
+}
+
+// MarshalJSON supports json.Marshaler interface
+func (v ReadinessDTO) MarshalJSON() ([]byte, error) {
+	w := jwriter.Writer{}
+	easyjson84a4d4aeEncodeGithubComUjweghGophermartInternalAppHandlers(&w, v)
+	return w.Buffer.BuildBytes(), w.Error
+}
+
+// MarshalEasyJSON supports easyjson.Marshaler interface
+func (v ReadinessDTO) MarshalEasyJSON(w *jwriter.Writer) {
+	easyjson84a4d4aeEncodeGithubComUjweghGophermartInternalAppHandlers(w, v)
+}
+
+// UnmarshalJSON supports json.Unmarshaler interface
+func (v *ReadinessDTO) UnmarshalJSON(data []byte) error {
+	r := jlexer.Lexer{Data: data}
+	easyjson84a4d4aeDecodeGithubComUjweghGophermartInternalAppHandlers(&r, v)
+	return r.Error()
+}
+
+// UnmarshalEasyJSON supports easyjson.Unmarshaler interface
+func (v *ReadinessDTO) UnmarshalEasyJSON(l *jlexer.Lexer) {
+	easyjson84a4d4aeDecodeGithubComUjweghGophermartInternalAppHandlers(l, v)
+}
+func easyjson84a4d4aeDecodeGithubComUjweghGophermartInternalAppHandlers1(in *jlexer.Lexer, out *MigrationStatusDTO) {
+	isTopLevel := in.IsStart()
+	if in.IsNull() {
+		if isTopLevel {
+			in.Consumed()
+		}
+		in.Skip()
+		return
+	}
+	in.Delim('{')
+	for !in.IsDelim('}') {
+		key := in.UnsafeFieldName(false)
+		in.WantColon()
+		if in.IsNull() {
+			in.Skip()
+			in.WantComma()
+			continue
+		}
+		switch key {
+		case "version":
+			out.Version = int64(in.Int64())
+		default:
+			in.SkipRecursive()
+		}
+		in.WantComma()
+	}
+	in.Delim('}')
+	if isTopLevel {
+		in.Consumed()
+	}
+}
+func easyjson84a4d4aeEncodeGithubComUjweghGophermartInternalAppHandlers1(out *jwriter.Writer, in MigrationStatusDTO) {
+	out.RawByte('{')
+	first := true
+	_ = first
+	{
+		const prefix string = ",\"version\":"
+		out.RawString(prefix[1:])
+		out.Int64(int64(in.Version))
+	}
+	out.RawByte('}')
+}
+
+// MarshalJSON supports json.Marshaler interface
+func (v MigrationStatusDTO) MarshalJSON() ([]byte, error) {
+	w := jwriter.Writer{}
+	easyjson84a4d4aeEncodeGithubComUjweghGophermartInternalAppHandlers1(&w, v)
+	return w.Buffer.BuildBytes(), w.Error
+}
+
+// MarshalEasyJSON supports easyjson.Marshaler interface
+func (v MigrationStatusDTO) MarshalEasyJSON(w *jwriter.Writer) {
+	easyjson84a4d4aeEncodeGithubComUjweghGophermartInternalAppHandlers1(w, v)
+}
+
+// UnmarshalJSON supports json.Unmarshaler interface
+func (v *MigrationStatusDTO) UnmarshalJSON(data []byte) error {
+	r := jlexer.Lexer{Data: data}
+	easyjson84a4d4aeDecodeGithubComUjweghGophermartInternalAppHandlers1(&r, v)
+	return r.Error()
+}
+
+// UnmarshalEasyJSON supports easyjson.Unmarshaler interface
+func (v *MigrationStatusDTO) UnmarshalEasyJSON(l *jlexer.Lexer) {
+	easyjson84a4d4aeDecodeGithubComUjweghGophermartInternalAppHandlers1(l, v)
+}
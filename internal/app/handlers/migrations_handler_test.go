@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type stubMigrationVersion struct {
+	version int64
+	err     error
+}
+
+func (s stubMigrationVersion) MigrationVersion() (int64, error) {
+	return s.version, s.err
+}
+
+func TestMigrationsHandler_GetMigrationStatus(t *testing.T) {
+	tests := []struct {
+		name             string
+		migrations       stubMigrationVersion
+		wantStatusCode   int
+		wantResponseBody string
+	}{
+		{
+			name:             "Applied Version",
+			migrations:       stubMigrationVersion{version: 6},
+			wantStatusCode:   http.StatusOK,
+			wantResponseBody: `{"version":6}`,
+		},
+		{
+			name:             "Version Lookup Failure",
+			migrations:       stubMigrationVersion{err: errors.New("relation goose_db_version does not exist")},
+			wantStatusCode:   http.StatusInternalServerError,
+			wantResponseBody: `{"code":500,"message":"Internal Server Error"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mh := NewMigrationsHandler(tt.migrations, 6)
+			req := httptest.NewRequest("GET", "/admin/migrations", nil)
+			rr := httptest.NewRecorder()
+
+			mh.GetMigrationStatus(rr, req)
+
+			assert.Equal(t, tt.wantStatusCode, rr.Code)
+			assert.JSONEq(t, tt.wantResponseBody, rr.Body.String())
+		})
+	}
+}
+
+func TestMigrationsHandler_Ready(t *testing.T) {
+	tests := []struct {
+		name             string
+		migrations       stubMigrationVersion
+		wantStatusCode   int
+		wantResponseBody string
+	}{
+		{
+			name:             "Up To Date",
+			migrations:       stubMigrationVersion{version: 6},
+			wantStatusCode:   http.StatusOK,
+			wantResponseBody: `{"ready":true,"version":6,"latest":6}`,
+		},
+		{
+			name:             "Schema Behind",
+			migrations:       stubMigrationVersion{version: 5},
+			wantStatusCode:   http.StatusServiceUnavailable,
+			wantResponseBody: `{"ready":false,"version":5,"latest":6}`,
+		},
+		{
+			name:             "Version Lookup Failure",
+			migrations:       stubMigrationVersion{err: errors.New("connection refused")},
+			wantStatusCode:   http.StatusServiceUnavailable,
+			wantResponseBody: `{"code":503,"message":"Service Unavailable: unable to read migration version"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mh := NewMigrationsHandler(tt.migrations, 6)
+			req := httptest.NewRequest("GET", "/readyz", nil)
+			rr := httptest.NewRecorder()
+
+			mh.Ready(rr, req)
+
+			assert.Equal(t, tt.wantStatusCode, rr.Code)
+			assert.JSONEq(t, tt.wantResponseBody, rr.Body.String())
+		})
+	}
+}
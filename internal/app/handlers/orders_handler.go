@@ -5,20 +5,29 @@ import (
 	"errors"
 	"fmt"
 	"github.com/ShiraazMoollatjie/goluhn"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/patrickmn/go-cache"
 	appContext "github.com/ujwegh/gophermart/internal/app/context"
 	appErrors "github.com/ujwegh/gophermart/internal/app/errors"
 	"github.com/ujwegh/gophermart/internal/app/repository"
 	"github.com/ujwegh/gophermart/internal/app/service"
+	"github.com/ujwegh/gophermart/internal/app/util"
 	"io"
+	"mime"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 )
 
 type (
 	OrdersHandler struct {
-		orderService   service.OrderService
-		contextTimeout time.Duration
+		orderService        service.OrderService
+		deviceMarkerService service.DeviceMarkerService
+		contextTimeout      time.Duration
+		pagination          Pagination
+		retryLimiter        *cache.Cache
 	}
 
 	//easyjson:json
@@ -30,28 +39,61 @@ type (
 	}
 	//easyjson:json
 	OrderDTOSlice []OrderDTO
+	//easyjson:json
+	OrdersEnvelopeDTO struct {
+		Data  OrderDTOSlice `json:"data"`
+		Page  PageDTO       `json:"page"`
+		Total int           `json:"total"`
+		// NextCursor is only set in cursor mode while more orders follow
+		NextCursor string `json:"next_cursor,omitempty"`
+	}
+	PageDTO struct {
+		Limit  int    `json:"limit"`
+		Offset int    `json:"offset"`
+		Cursor string `json:"cursor,omitempty"`
+	}
+	//easyjson:json
+	CreateOrderRequestDTO struct {
+		Order string `json:"order"`
+	}
+	//easyjson:json
+	OrderStatusChangeDTO struct {
+		Status    string    `json:"status"`
+		Accrual   *float64  `json:"accrual,omitempty"`
+		ChangedAt time.Time `json:"changed_at"`
+	}
+	//easyjson:json
+	OrderStatusChangeDTOSlice []OrderStatusChangeDTO
 )
 
-func NewOrdersHandler(contextTimeoutSec int, orderService service.OrderService) *OrdersHandler {
+func NewOrdersHandler(contextTimeoutSec int, pagination Pagination, retryCooldownSec int,
+	orderService service.OrderService, deviceMarkerService service.DeviceMarkerService) *OrdersHandler {
 	return &OrdersHandler{
-		orderService:   orderService,
-		contextTimeout: time.Duration(contextTimeoutSec) * time.Second,
+		orderService:        orderService,
+		deviceMarkerService: deviceMarkerService,
+		contextTimeout:      time.Duration(contextTimeoutSec) * time.Second,
+		pagination:          pagination,
+		retryLimiter:        newRetryLimiter(time.Duration(retryCooldownSec) * time.Second),
 	}
 }
 
+// newRetryLimiter remembers retried orders, keyed by user and normalized number, for the cooldown period.
+func newRetryLimiter(cooldown time.Duration) *cache.Cache {
+	return cache.New(cooldown, 2*cooldown)
+}
+
 // CreateOrder godoc
 // @Summary Loading order number
 // @Description The handler is only available to authenticated users and is used to upload a new order number.
-//
-//	The order number is a sequence of digits of arbitrary length and can be validated using the Luhn algorithm.
-//
+// @Description The order number is a sequence of digits of arbitrary length and can be validated using the Luhn algorithm.
+// @Description Send Accept: application/json to receive the created order in the 202 body, without it the body stays empty.
 // @Tags order
-// @Accept plain
+// @Accept plain,json
 // @Produce json
-// @Param order body string true "Order Number"
+// @Param order body string true "Order Number as plain text, or a JSON object with an order field"
 // @Success 200 "The order number has already been uploaded by this user"
-// @Success 202 "The new order number has been accepted for processing"
-// @Failure 400 {object} ErrorResponse "Bad Request - Unable to read body or incorrect request format"
+// @Success 202 {object} OrderDTO "The new order number has been accepted for processing, the body is only sent with Accept: application/json"
+// @Failure 400 {object} ErrorResponse "Bad Request - Unable to read body, incorrect request format or empty order number"
 // @Failure 401 {object} ErrorResponse "Unauthorized - The user is not authenticated"
 // @Failure 409 {object} ErrorResponse "Conflict - The order number has already been uploaded by another user"
 // @Failure 422 {object} ErrorResponse "Unprocessable Entity - Incorrect order number format"
@@ -59,78 +101,281 @@ func NewOrdersHandler(contextTimeoutSec int, orderService service.OrderService)
 // @Security ApiKeyAuth
 // @Router /api/user/orders [post]
 func (oh *OrdersHandler) CreateOrder(w http.ResponseWriter, r *http.Request) {
-	ctx, cancel := context.WithTimeout(context.Background(), oh.contextTimeout)
+	ctx, cancel := context.WithTimeout(r.Context(), oh.contextTimeout)
 	defer cancel()
 
-	orderID, err := io.ReadAll(r.Body)
+	stringOrderID, err := readOrderNumber(r)
 	if err != nil {
-		err = appErrors.NewWithCode(err, errMsgEnableReadBody, http.StatusBadRequest)
-		PrepareError(w, err)
+		PrepareError(w, r, err)
 		return
 	}
 	userUID := appContext.UserUID(r.Context())
 
-	stringOrderID := string(orderID)
 	err = goluhn.Validate(stringOrderID)
 	if err != nil {
 		err = appErrors.NewWithCode(err, "Invalid order ID", http.StatusUnprocessableEntity)
-		PrepareError(w, err)
+		PrepareError(w, r, err)
+		return
+	}
+	// check the deadline before writing anything: once the order is stored, report success
+	err = appContext.GetContextError(ctx)
+	if err != nil {
+		PrepareError(w, r, err)
 		return
 	}
-	_, err = oh.orderService.CreateOrder(ctx, stringOrderID, userUID)
+	order, err := oh.orderService.CreateOrder(ctx, stringOrderID, userUID)
 	appErr := &appErrors.ResponseCodeError{}
 	if err != nil && errors.As(err, appErr) && strings.Contains(appErr.Msg(), "repeated order") {
 		w.WriteHeader(http.StatusOK)
 		return
 	} else if err != nil {
-		PrepareError(w, err)
+		PrepareError(w, r, err)
+		return
+	}
+	if !acceptsJSON(r.Header.Get("Accept")) {
+		w.WriteHeader(http.StatusAccepted)
 		return
 	}
 
-	err = appContext.GetContextError(ctx)
+	rawBytes, err := mapOrderToOrderDto(order).MarshalJSON()
 	if err != nil {
-		PrepareError(w, err)
+		PrepareError(w, r, fmt.Errorf("unable to marshal response: %w", err))
 		return
 	}
+	w.Header().Add("Content-Type", "application/json")
 	w.WriteHeader(http.StatusAccepted)
+	w.Write(rawBytes)
+}
+
+// acceptsJSON reports whether the Accept header names application/json explicitly.
+// Wildcards don't count, so clients sending */* keep getting the empty body.
+func acceptsJSON(accept string) bool {
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil || mediaType != "application/json" {
+			continue
+		}
+		if q, err := strconv.ParseFloat(params["q"], 64); err == nil && q == 0 {
+			continue
+		}
+		return true
+	}
+	return false
+}
+
+// readOrderNumber extracts the order number from a plain text body or,
+// for application/json requests, from the order field of the JSON body.
+// A missing or blank number is a bad request rather than an invalid number.
+func readOrderNumber(r *http.Request) (string, error) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return "", appErrors.NewWithCode(err, errMsgEnableReadBody, http.StatusBadRequest)
+	}
+	orderNumber := string(body)
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType == "application/json" {
+		request := CreateOrderRequestDTO{}
+		if err := request.UnmarshalJSON(body); err != nil {
+			return "", appErrors.NewWithCode(err, "Unable to parse body", http.StatusBadRequest)
+		}
+		orderNumber = request.Order
+	}
+	orderNumber = strings.TrimSpace(orderNumber)
+	if orderNumber == "" {
+		msg := "Empty order number"
+		return "", appErrors.NewWithCode(errors.New(msg), msg, http.StatusBadRequest)
+	}
+	return orderNumber, nil
 }
 
 // GetOrders godoc
 // @Summary Getting a list of downloaded order numbers
 // @Description The handler returns a list of order numbers sorted by loading time from oldest to newest for an authorized user.
 // @Description The response includes the order number, status, accrual (if available), and the upload timestamp.
+// @Description With envelope=true the list is wrapped together with the page and the total number of orders,
+// @Description and an empty list is returned with 200 instead of 204.
+// @Description With the cursor param the orders are paged by an opaque cursor instead of an offset, newest first,
+// @Description so orders uploaded meanwhile don't shift the pages. An empty cursor starts from the newest order.
+// @Description The response is always wrapped in the envelope, whose next_cursor is set while more orders follow.
+// @Description With new_only=true only the orders uploaded after the marker of the device are listed,
+// @Description all orders while the device has no marker. See PUT /api/user/devices/{device}/marker.
 // @Tags orders
 // @Produce json
+// @Param limit query int false "Page size, clamped to the configured maximum, all orders are listed without it unless paging by cursor"
+// @Param offset query int false "Number of orders to skip, not allowed together with cursor"
+// @Param envelope query bool false "Wrap the list in an object with pagination metadata"
+// @Param cursor query string false "next_cursor of the previous page, empty for the first page"
+// @Param new_only query bool false "List only the orders uploaded after the marker of the device, not allowed together with cursor"
+// @Param device query string false "Device identifier, required with new_only"
 // @Success 200 {array} OrderDTO "List of orders with details"
 // @Success 204 "No orders to display"
+// @Failure 400 {object} ErrorResponse "Bad Request - Invalid pagination, envelope, cursor or device parameters, or an unknown parameter"
 // @Failure 401 {object} ErrorResponse "Unauthorized - The user is not authorized"
 // @Failure 500 {object} ErrorResponse "Internal Server Error"
 // @Security ApiKeyAuth
 // @Router /api/user/orders [get]
 func (oh *OrdersHandler) GetOrders(w http.ResponseWriter, r *http.Request) {
-	ctx, cancel := context.WithTimeout(context.Background(), oh.contextTimeout)
+	ctx, cancel := context.WithTimeout(r.Context(), oh.contextTimeout)
 	defer cancel()
 
+	if err := checkQueryParams(r, "limit", "offset", "envelope", "cursor", "new_only", "device"); err != nil {
+		PrepareError(w, r, err)
+		return
+	}
 	userUID := appContext.UserUID(r.Context())
+	envelope, err := parseEnvelope(r)
+	if err != nil {
+		PrepareError(w, r, err)
+		return
+	}
+	cursor, cursorMode, err := parseCursor(r)
+	if err != nil {
+		PrepareError(w, r, err)
+		return
+	}
+	// cursor pages always have a size, the other listings return all orders unless the client asks for a page
+	var page Page
+	if cursorMode {
+		page, err = oh.pagination.ParsePage(r)
+	} else {
+		page, err = oh.pagination.ParseOptionalPage(r)
+	}
+	if err != nil {
+		PrepareError(w, r, err)
+		return
+	}
+	deviceID, newOnly, err := parseNewOnly(r)
+	if err != nil {
+		PrepareError(w, r, err)
+		return
+	}
+	if newOnly && cursorMode {
+		msg := "new_only and cursor are mutually exclusive"
+		PrepareError(w, r, appErrors.NewWithCode(errors.New(msg), msg, http.StatusBadRequest))
+		return
+	}
 
-	orders, err := oh.orderService.GetOrders(ctx, userUID)
+	var (
+		orders     *[]repository.Order
+		nextCursor string
+	)
+	if cursorMode {
+		orders, nextCursor, err = oh.getOrdersAfterCursor(ctx, userUID, cursor, page.Limit)
+	} else if newOnly {
+		orders, err = oh.getNewOrders(ctx, userUID, deviceID, page)
+	} else {
+		orders, err = oh.orderService.GetOrders(ctx, userUID, page.RowLimit(), page.Offset)
+	}
 	if err != nil {
-		PrepareError(w, err)
+		PrepareError(w, r, err)
 		return
 	}
-	if len(*orders) == 0 {
-		w.WriteHeader(http.StatusNoContent)
+	var rawBytes []byte
+	if envelope || cursorMode {
+		var total int
+		total, err = oh.orderService.CountOrders(ctx, userUID)
+		if err != nil {
+			PrepareError(w, r, err)
+			return
+		}
+		response := OrdersEnvelopeDTO{
+			Data:       oh.mapOrdersToOrderDtoSlice(orders),
+			Page:       PageDTO{Limit: page.Limit, Offset: page.Offset, Cursor: r.URL.Query().Get("cursor")},
+			Total:      total,
+			NextCursor: nextCursor,
+		}
+		rawBytes, err = response.MarshalJSON()
+	} else {
+		if len(*orders) == 0 {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		response := oh.mapOrdersToOrderDtoSlice(orders)
+		rawBytes, err = response.MarshalJSON()
+	}
+	if err != nil {
+		PrepareError(w, r, fmt.Errorf("marshal response: %w", err))
+		return
+	}
+	err = appContext.GetContextError(ctx)
+	if err != nil {
+		PrepareError(w, r, err)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(rawBytes)
+}
+
+// getNewOrders lists the orders uploaded after the device's marker, all orders when it has none.
+func (oh *OrdersHandler) getNewOrders(ctx context.Context, userUID *uuid.UUID, deviceID string, page Page) (*[]repository.Order, error) {
+	var since time.Time
+	marker, err := oh.deviceMarkerService.GetMarker(ctx, userUID, deviceID)
+	switch {
+	case err == nil:
+		since = marker.LastSeenAt
+	case !errors.Is(err, repository.ErrDeviceMarkerNotFound):
+		return nil, err
+	}
+	return oh.orderService.GetOrdersSince(ctx, userUID, since, page.RowLimit(), page.Offset)
+}
+
+// getOrdersAfterCursor fetches one order more than limit to tell whether a next page exists,
+// and returns the cursor of that page if it does.
+func (oh *OrdersHandler) getOrdersAfterCursor(ctx context.Context, userUID *uuid.UUID,
+	cursor *repository.OrderCursor, limit int) (*[]repository.Order, string, error) {
+	orders, err := oh.orderService.GetOrdersAfterCursor(ctx, userUID, cursor, limit+1)
+	if err != nil {
+		return nil, "", err
+	}
+	if len(*orders) <= limit {
+		return orders, "", nil
+	}
+	page := (*orders)[:limit]
+	return &page, repository.NewOrderCursor(page[limit-1]).Encode(), nil
+}
+
+// GetOrderHistory godoc
+// @Summary Getting the status history of an order
+// @Description The handler returns the status transitions of the user's order sorted from oldest to newest.
+// @Tags orders
+// @Produce json
+// @Param number path string true "Order Number"
+// @Success 200 {array} OrderStatusChangeDTO "Ordered status transitions"
+// @Failure 401 {object} ErrorResponse "Unauthorized - The user is not authorized"
+// @Failure 404 {object} ErrorResponse "Not Found - The order does not exist or belongs to another user"
+// @Failure 500 {object} ErrorResponse "Internal Server Error"
+// @Security ApiKeyAuth
+// @Router /api/user/orders/{number}/history [get]
+func (oh *OrdersHandler) GetOrderHistory(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), oh.contextTimeout)
+	defer cancel()
+
+	userUID := appContext.UserUID(r.Context())
+	orderID := chi.URLParam(r, "number")
+
+	history, err := oh.orderService.GetOrderHistory(ctx, orderID, userUID)
+	if err != nil {
+		PrepareError(w, r, err)
 		return
 	}
-	response := oh.mapOrdersToOrderDtoSlice(orders)
+	response := make(OrderStatusChangeDTOSlice, 0, len(*history))
+	for _, change := range *history {
+		response = append(response, OrderStatusChangeDTO{
+			Status:    change.Status.String(),
+			Accrual:   change.Accrual,
+			ChangedAt: change.ChangedAt,
+		})
+	}
 	rawBytes, err := response.MarshalJSON()
 	if err != nil {
-		PrepareError(w, fmt.Errorf("marshal response: %w", err))
+		PrepareError(w, r, fmt.Errorf("marshal response: %w", err))
 		return
 	}
 	err = appContext.GetContextError(ctx)
 	if err != nil {
-		PrepareError(w, err)
+		PrepareError(w, r, err)
 		return
 	}
 
@@ -139,16 +384,128 @@ func (oh *OrdersHandler) GetOrders(w http.ResponseWriter, r *http.Request) {
 	w.Write(rawBytes)
 }
 
+// RetryOrder godoc
+// @Summary Re-triggering accrual lookup for an order
+// @Description The handler resets the user's INVALID or stuck PROCESSING order to NEW and sends it to the accrual
+// @Description system again. Retries of the same order are limited to one per cooldown period.
+// @Tags orders
+// @Produce json
+// @Param number path string true "Order Number"
+// @Success 202 "The order has been sent to processing again"
+// @Failure 401 {object} ErrorResponse "Unauthorized - The user is not authorized"
+// @Failure 404 {object} ErrorResponse "Not Found - The order does not exist or belongs to another user"
+// @Failure 409 {object} ErrorResponse "Conflict - The order is not in a retryable state"
+// @Failure 429 {object} ErrorResponse "Too Many Requests - The order was retried recently"
+// @Failure 500 {object} ErrorResponse "Internal Server Error"
+// @Security ApiKeyAuth
+// @Router /api/user/orders/{number}/retry [post]
+func (oh *OrdersHandler) RetryOrder(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), oh.contextTimeout)
+	defer cancel()
+
+	userUID := appContext.UserUID(r.Context())
+	orderID := chi.URLParam(r, "number")
+	// zero padded forms of the number name the same order and share its cooldown
+	cooldownKey := userUID.String() + "/" + util.NormalizeOrderNumber(orderID)
+
+	if err := oh.retryLimiter.Add(cooldownKey, struct{}{}, cache.DefaultExpiration); err != nil {
+		if _, expiresAt, found := oh.retryLimiter.GetWithExpiration(cooldownKey); found {
+			retryAfter := int(time.Until(expiresAt).Seconds()) + 1
+			w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+		}
+		WriteErrorResponse(w, r, "Order was retried recently", http.StatusTooManyRequests)
+		return
+	}
+
+	_, err := oh.orderService.RetryOrder(ctx, orderID, userUID)
+	if err != nil {
+		oh.retryLimiter.Delete(cooldownKey)
+		PrepareError(w, r, err)
+		return
+	}
+
+	err = appContext.GetContextError(ctx)
+	if err != nil {
+		PrepareError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
 func (oh *OrdersHandler) mapOrdersToOrderDtoSlice(slice *[]repository.Order) OrderDTOSlice {
-	var responseSlice []OrderDTO
+	responseSlice := make(OrderDTOSlice, 0, len(*slice))
 	for _, item := range *slice {
-		responseItem := OrderDTO{
-			OrderID:    item.ID,
-			Status:     item.Status.String(),
-			Accrual:    item.Accrual,
-			UploadedAt: item.CreatedAt,
-		}
-		responseSlice = append(responseSlice, responseItem)
+		responseSlice = append(responseSlice, mapOrderToOrderDto(&item))
 	}
 	return responseSlice
 }
+
+// mapOrderToOrderDto maps an order the way it is listed, also used for the created order in the 202 body.
+func mapOrderToOrderDto(order *repository.Order) OrderDTO {
+	return OrderDTO{
+		OrderID:    order.ID,
+		Status:     order.Status.String(),
+		Accrual:    order.Accrual,
+		UploadedAt: order.CreatedAt,
+	}
+}
+
+// parseCursor reports whether the request asks for cursor paging and decodes its cursor,
+// which is nil for the first page. Cursors and offsets can't be mixed.
+func parseCursor(r *http.Request) (*repository.OrderCursor, bool, error) {
+	query := r.URL.Query()
+	if !query.Has("cursor") {
+		return nil, false, nil
+	}
+	if query.Get("offset") != "" {
+		msg := "offset and cursor are mutually exclusive"
+		return nil, false, appErrors.NewWithCode(errors.New(msg), errMsgInvalidPagination, http.StatusBadRequest)
+	}
+	token := query.Get("cursor")
+	if token == "" {
+		return nil, true, nil
+	}
+	cursor, err := repository.DecodeOrderCursor(token)
+	if err != nil {
+		return nil, false, appErrors.NewWithCode(err, "Invalid cursor", http.StatusBadRequest)
+	}
+	return &cursor, true, nil
+}
+
+// parseNewOnly reads the new_only query param together with the device whose marker it uses.
+func parseNewOnly(r *http.Request) (string, bool, error) {
+	query := r.URL.Query()
+	raw := query.Get("new_only")
+	if raw == "" {
+		return "", false, nil
+	}
+	newOnly, err := strconv.ParseBool(raw)
+	if err != nil {
+		return "", false, appErrors.NewWithCode(err, "Invalid new_only parameter", http.StatusBadRequest)
+	}
+	if !newOnly {
+		return "", false, nil
+	}
+	if !query.Has("device") {
+		msg := "device is required with new_only"
+		return "", false, appErrors.NewWithCode(errors.New(msg), msg, http.StatusBadRequest)
+	}
+	deviceID, err := validateDeviceID(query.Get("device"))
+	if err != nil {
+		return "", false, err
+	}
+	return deviceID, true, nil
+}
+
+// parseEnvelope reads the optional envelope query param; the bare array stays the default.
+func parseEnvelope(r *http.Request) (bool, error) {
+	raw := r.URL.Query().Get("envelope")
+	if raw == "" {
+		return false, nil
+	}
+	envelope, err := strconv.ParseBool(raw)
+	if err != nil {
+		return false, appErrors.NewWithCode(err, "Invalid envelope parameter", http.StatusBadRequest)
+	}
+	return envelope, nil
+}
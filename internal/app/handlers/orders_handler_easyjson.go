@@ -17,7 +17,150 @@ var (
 	_ easyjson.Marshaler
 )
 
-func easyjsonB00e796eDecodeGithubComUjweghGophermartInternalAppHandlers(in *jlexer.Lexer, out *OrderDTOSlice) {
+func easyjsonB00e796eDecodeGithubComUjweghGophermartInternalAppHandlers(in *jlexer.Lexer, out *OrdersEnvelopeDTO) {
+	isTopLevel := in.IsStart()
+	if in.IsNull() {
+		if isTopLevel {
+			in.Consumed()
+		}
+		in.Skip()
+		return
+	}
+	in.Delim('{')
+	for !in.IsDelim('}') {
+		key := in.UnsafeFieldName(false)
+		in.WantColon()
+		if in.IsNull() {
+			in.Skip()
+			in.WantComma()
+			continue
+		}
+		switch key {
+		case "data":
+			(out.Data).UnmarshalEasyJSON(in)
+		case "page":
+			easyjsonB00e796eDecodeGithubComUjweghGophermartInternalAppHandlers1(in, &out.Page)
+		case "total":
+			out.Total = int(in.Int())
+		case "next_cursor":
+			out.NextCursor = string(in.String())
+		default:
+			in.SkipRecursive()
+		}
+		in.WantComma()
+	}
+	in.Delim('}')
+	if isTopLevel {
+		in.Consumed()
+	}
+}
+func easyjsonB00e796eEncodeGithubComUjweghGophermartInternalAppHandlers(out *jwriter.Writer, in OrdersEnvelopeDTO) {
+	out.RawByte('{')
+	first := true
+	_ = first
+	{
+		const prefix string = ",\"data\":"
+		out.RawString(prefix[1:])
+		(in.Data).MarshalEasyJSON(out)
+	}
+	{
+		const prefix string = ",\"page\":"
+		out.RawString(prefix)
+		easyjsonB00e796eEncodeGithubComUjweghGophermartInternalAppHandlers1(out, in.Page)
+	}
+	{
+		const prefix string = ",\"total\":"
+		out.RawString(prefix)
+		out.Int(int(in.Total))
+	}
+	if in.NextCursor != "" {
+		const prefix string = ",\"next_cursor\":"
+		out.RawString(prefix)
+		out.String(string(in.NextCursor))
+	}
+	out.RawByte('}')
+}
+
+// MarshalJSON supports json.Marshaler interface
+func (v OrdersEnvelopeDTO) MarshalJSON() ([]byte, error) {
+	w := jwriter.Writer{}
+	easyjsonB00e796eEncodeGithubComUjweghGophermartInternalAppHandlers(&w, v)
+	return w.Buffer.BuildBytes(), w.Error
+}
+
+// MarshalEasyJSON supports easyjson.Marshaler interface
+func (v OrdersEnvelopeDTO) MarshalEasyJSON(w *jwriter.Writer) {
+	easyjsonB00e796eEncodeGithubComUjweghGophermartInternalAppHandlers(w, v)
+}
+
+// UnmarshalJSON supports json.Unmarshaler interface
+func (v *OrdersEnvelopeDTO) UnmarshalJSON(data []byte) error {
+	r := jlexer.Lexer{Data: data}
+	easyjsonB00e796eDecodeGithubComUjweghGophermartInternalAppHandlers(&r, v)
+	return r.Error()
+}
+
+// UnmarshalEasyJSON supports easyjson.Unmarshaler interface
+func (v *OrdersEnvelopeDTO) UnmarshalEasyJSON(l *jlexer.Lexer) {
+	easyjsonB00e796eDecodeGithubComUjweghGophermartInternalAppHandlers(l, v)
+}
+func easyjsonB00e796eDecodeGithubComUjweghGophermartInternalAppHandlers1(in *jlexer.Lexer, out *PageDTO) {
+	isTopLevel := in.IsStart()
+	if in.IsNull() {
+		if isTopLevel {
+			in.Consumed()
+		}
+		in.Skip()
+		return
+	}
+	in.Delim('{')
+	for !in.IsDelim('}') {
+		key := in.UnsafeFieldName(false)
+		in.WantColon()
+		if in.IsNull() {
+			in.Skip()
+			in.WantComma()
+			continue
+		}
+		switch key {
+		case "limit":
+			out.Limit = int(in.Int())
+		case "offset":
+			out.Offset = int(in.Int())
+		case "cursor":
+			out.Cursor = string(in.String())
+		default:
+			in.SkipRecursive()
+		}
+		in.WantComma()
+	}
+	in.Delim('}')
+	if isTopLevel {
+		in.Consumed()
+	}
+}
+func easyjsonB00e796eEncodeGithubComUjweghGophermartInternalAppHandlers1(out *jwriter.Writer, in PageDTO) {
+	out.RawByte('{')
+	first := true
+	_ = first
+	{
+		const prefix string = ",\"limit\":"
+		out.RawString(prefix[1:])
+		out.Int(int(in.Limit))
+	}
+	{
+		const prefix string = ",\"offset\":"
+		out.RawString(prefix)
+		out.Int(int(in.Offset))
+	}
+	if in.Cursor != "" {
+		const prefix string = ",\"cursor\":"
+		out.RawString(prefix)
+		out.String(string(in.Cursor))
+	}
+	out.RawByte('}')
+}
+func easyjsonB00e796eDecodeGithubComUjweghGophermartInternalAppHandlers2(in *jlexer.Lexer, out *OrderStatusChangeDTOSlice) {
 	isTopLevel := in.IsStart()
 	if in.IsNull() {
 		in.Skip()
@@ -26,15 +169,15 @@ func easyjsonB00e796eDecodeGithubComUjweghGophermartInternalAppHandlers(in *jlex
 		in.Delim('[')
 		if *out == nil {
 			if !in.IsDelim(']') {
-				*out = make(OrderDTOSlice, 0, 1)
+				*out = make(OrderStatusChangeDTOSlice, 0, 1)
 			} else {
-				*out = OrderDTOSlice{}
+				*out = OrderStatusChangeDTOSlice{}
 			}
 		} else {
 			*out = (*out)[:0]
 		}
 		for !in.IsDelim(']') {
-			var v1 OrderDTO
+			var v1 OrderStatusChangeDTO
 			(v1).UnmarshalEasyJSON(in)
 			*out = append(*out, v1)
 			in.WantComma()
@@ -45,7 +188,7 @@ func easyjsonB00e796eDecodeGithubComUjweghGophermartInternalAppHandlers(in *jlex
 		in.Consumed()
 	}
 }
-func easyjsonB00e796eEncodeGithubComUjweghGophermartInternalAppHandlers(out *jwriter.Writer, in OrderDTOSlice) {
+func easyjsonB00e796eEncodeGithubComUjweghGophermartInternalAppHandlers2(out *jwriter.Writer, in OrderStatusChangeDTOSlice) {
 	if in == nil && (out.Flags&jwriter.NilSliceAsEmpty) == 0 {
 		out.RawString("null")
 	} else {
@@ -60,30 +203,186 @@ func easyjsonB00e796eEncodeGithubComUjweghGophermartInternalAppHandlers(out *jwr
 	}
 }
 
+// MarshalJSON supports json.Marshaler interface
+func (v OrderStatusChangeDTOSlice) MarshalJSON() ([]byte, error) {
+	w := jwriter.Writer{}
+	easyjsonB00e796eEncodeGithubComUjweghGophermartInternalAppHandlers2(&w, v)
+	return w.Buffer.BuildBytes(), w.Error
+}
+
+// MarshalEasyJSON supports easyjson.Marshaler interface
+func (v OrderStatusChangeDTOSlice) MarshalEasyJSON(w *jwriter.Writer) {
+	easyjsonB00e796eEncodeGithubComUjweghGophermartInternalAppHandlers2(w, v)
+}
+
+// UnmarshalJSON supports json.Unmarshaler interface
+func (v *OrderStatusChangeDTOSlice) UnmarshalJSON(data []byte) error {
+	r := jlexer.Lexer{Data: data}
+	easyjsonB00e796eDecodeGithubComUjweghGophermartInternalAppHandlers2(&r, v)
+	return r.Error()
+}
+
+// UnmarshalEasyJSON supports easyjson.Unmarshaler interface
+func (v *OrderStatusChangeDTOSlice) UnmarshalEasyJSON(l *jlexer.Lexer) {
+	easyjsonB00e796eDecodeGithubComUjweghGophermartInternalAppHandlers2(l, v)
+}
+func easyjsonB00e796eDecodeGithubComUjweghGophermartInternalAppHandlers3(in *jlexer.Lexer, out *OrderStatusChangeDTO) {
+	isTopLevel := in.IsStart()
+	if in.IsNull() {
+		if isTopLevel {
+			in.Consumed()
+		}
+		in.Skip()
+		return
+	}
+	in.Delim('{')
+	for !in.IsDelim('}') {
+		key := in.UnsafeFieldName(false)
+		in.WantColon()
+		if in.IsNull() {
+			in.Skip()
+			in.WantComma()
+			continue
+		}
+		switch key {
+		case "status":
+			out.Status = string(in.String())
+		case "accrual":
+			if in.IsNull() {
+				in.Skip()
+				out.Accrual = nil
+			} else {
+				if out.Accrual == nil {
+					out.Accrual = new(float64)
+				}
+				*out.Accrual = float64(in.Float64())
+			}
+		case "changed_at":
+			if data := in.Raw(); in.Ok() {
+				in.AddError((out.ChangedAt).UnmarshalJSON(data))
+			}
+		default:
+			in.SkipRecursive()
+		}
+		in.WantComma()
+	}
+	in.Delim('}')
+	if isTopLevel {
+		in.Consumed()
+	}
+}
+func easyjsonB00e796eEncodeGithubComUjweghGophermartInternalAppHandlers3(out *jwriter.Writer, in OrderStatusChangeDTO) {
+	out.RawByte('{')
+	first := true
+	_ = first
+	{
+		const prefix string = ",\"status\":"
+		out.RawString(prefix[1:])
+		out.String(string(in.Status))
+	}
+	if in.Accrual != nil {
+		const prefix string = ",\"accrual\":"
+		out.RawString(prefix)
+		out.Float64(float64(*in.Accrual))
+	}
+	{
+		const prefix string = ",\"changed_at\":"
+		out.RawString(prefix)
+		out.Raw((in.ChangedAt).MarshalJSON())
+	}
+	out.RawByte('}')
+}
+
+// MarshalJSON supports json.Marshaler interface
+func (v OrderStatusChangeDTO) MarshalJSON() ([]byte, error) {
+	w := jwriter.Writer{}
+	easyjsonB00e796eEncodeGithubComUjweghGophermartInternalAppHandlers3(&w, v)
+	return w.Buffer.BuildBytes(), w.Error
+}
+
+// MarshalEasyJSON supports easyjson.Marshaler interface
+func (v OrderStatusChangeDTO) MarshalEasyJSON(w *jwriter.Writer) {
+	easyjsonB00e796eEncodeGithubComUjweghGophermartInternalAppHandlers3(w, v)
+}
+
+// UnmarshalJSON supports json.Unmarshaler interface
+func (v *OrderStatusChangeDTO) UnmarshalJSON(data []byte) error {
+	r := jlexer.Lexer{Data: data}
+	easyjsonB00e796eDecodeGithubComUjweghGophermartInternalAppHandlers3(&r, v)
+	return r.Error()
+}
+
+// UnmarshalEasyJSON supports easyjson.Unmarshaler interface
+func (v *OrderStatusChangeDTO) UnmarshalEasyJSON(l *jlexer.Lexer) {
+	easyjsonB00e796eDecodeGithubComUjweghGophermartInternalAppHandlers3(l, v)
+}
+func easyjsonB00e796eDecodeGithubComUjweghGophermartInternalAppHandlers4(in *jlexer.Lexer, out *OrderDTOSlice) {
+	isTopLevel := in.IsStart()
+	if in.IsNull() {
+		in.Skip()
+		*out = nil
+	} else {
+		in.Delim('[')
+		if *out == nil {
+			if !in.IsDelim(']') {
+				*out = make(OrderDTOSlice, 0, 1)
+			} else {
+				*out = OrderDTOSlice{}
+			}
+		} else {
+			*out = (*out)[:0]
+		}
+		for !in.IsDelim(']') {
+			var v4 OrderDTO
+			(v4).UnmarshalEasyJSON(in)
+			*out = append(*out, v4)
+			in.WantComma()
+		}
+		in.Delim(']')
+	}
+	if isTopLevel {
+		in.Consumed()
+	}
+}
+func easyjsonB00e796eEncodeGithubComUjweghGophermartInternalAppHandlers4(out *jwriter.Writer, in OrderDTOSlice) {
+	if in == nil && (out.Flags&jwriter.NilSliceAsEmpty) == 0 {
+		out.RawString("null")
+	} else {
+		out.RawByte('[')
+		for v5, v6 := range in {
+			if v5 > 0 {
+				out.RawByte(',')
+			}
+			(v6).MarshalEasyJSON(out)
+		}
+		out.RawByte(']')
+	}
+}
+
 // MarshalJSON supports json.Marshaler interface
 func (v OrderDTOSlice) MarshalJSON() ([]byte, error) {
 	w := jwriter.Writer{}
-	easyjsonB00e796eEncodeGithubComUjweghGophermartInternalAppHandlers(&w, v)
+	easyjsonB00e796eEncodeGithubComUjweghGophermartInternalAppHandlers4(&w, v)
 	return w.Buffer.BuildBytes(), w.Error
 }
 
 // MarshalEasyJSON supports easyjson.Marshaler interface
 func (v OrderDTOSlice) MarshalEasyJSON(w *jwriter.Writer) {
-	easyjsonB00e796eEncodeGithubComUjweghGophermartInternalAppHandlers(w, v)
+	easyjsonB00e796eEncodeGithubComUjweghGophermartInternalAppHandlers4(w, v)
 }
 
 // UnmarshalJSON supports json.Unmarshaler interface
 func (v *OrderDTOSlice) UnmarshalJSON(data []byte) error {
 	r := jlexer.Lexer{Data: data}
-	easyjsonB00e796eDecodeGithubComUjweghGophermartInternalAppHandlers(&r, v)
+	easyjsonB00e796eDecodeGithubComUjweghGophermartInternalAppHandlers4(&r, v)
 	return r.Error()
 }
 
 // UnmarshalEasyJSON supports easyjson.Unmarshaler interface
 func (v *OrderDTOSlice) UnmarshalEasyJSON(l *jlexer.Lexer) {
-	easyjsonB00e796eDecodeGithubComUjweghGophermartInternalAppHandlers(l, v)
+	easyjsonB00e796eDecodeGithubComUjweghGophermartInternalAppHandlers4(l, v)
 }
-func easyjsonB00e796eDecodeGithubComUjweghGophermartInternalAppHandlers1(in *jlexer.Lexer, out *OrderDTO) {
+func easyjsonB00e796eDecodeGithubComUjweghGophermartInternalAppHandlers5(in *jlexer.Lexer, out *OrderDTO) {
 	isTopLevel := in.IsStart()
 	if in.IsNull() {
 		if isTopLevel {
@@ -130,7 +429,7 @@ func easyjsonB00e796eDecodeGithubComUjweghGophermartInternalAppHandlers1(in *jle
 		in.Consumed()
 	}
 }
-func easyjsonB00e796eEncodeGithubComUjweghGophermartInternalAppHandlers1(out *jwriter.Writer, in OrderDTO) {
+func easyjsonB00e796eEncodeGithubComUjweghGophermartInternalAppHandlers5(out *jwriter.Writer, in OrderDTO) {
 	out.RawByte('{')
 	first := true
 	_ = first
@@ -160,23 +459,89 @@ func easyjsonB00e796eEncodeGithubComUjweghGophermartInternalAppHandlers1(out *jw
 // MarshalJSON supports json.Marshaler interface
 func (v OrderDTO) MarshalJSON() ([]byte, error) {
 	w := jwriter.Writer{}
-	easyjsonB00e796eEncodeGithubComUjweghGophermartInternalAppHandlers1(&w, v)
+	easyjsonB00e796eEncodeGithubComUjweghGophermartInternalAppHandlers5(&w, v)
 	return w.Buffer.BuildBytes(), w.Error
 }
 
 // MarshalEasyJSON supports easyjson.Marshaler interface
 func (v OrderDTO) MarshalEasyJSON(w *jwriter.Writer) {
-	easyjsonB00e796eEncodeGithubComUjweghGophermartInternalAppHandlers1(w, v)
+	easyjsonB00e796eEncodeGithubComUjweghGophermartInternalAppHandlers5(w, v)
 }
 
 // UnmarshalJSON supports json.Unmarshaler interface
 func (v *OrderDTO) UnmarshalJSON(data []byte) error {
 	r := jlexer.Lexer{Data: data}
-	easyjsonB00e796eDecodeGithubComUjweghGophermartInternalAppHandlers1(&r, v)
+	easyjsonB00e796eDecodeGithubComUjweghGophermartInternalAppHandlers5(&r, v)
 	return r.Error()
 }
 
 // UnmarshalEasyJSON supports easyjson.Unmarshaler interface
 func (v *OrderDTO) UnmarshalEasyJSON(l *jlexer.Lexer) {
-	easyjsonB00e796eDecodeGithubComUjweghGophermartInternalAppHandlers1(l, v)
+	easyjsonB00e796eDecodeGithubComUjweghGophermartInternalAppHandlers5(l, v)
+}
+func easyjsonB00e796eDecodeGithubComUjweghGophermartInternalAppHandlers6(in *jlexer.Lexer, out *CreateOrderRequestDTO) {
+	isTopLevel := in.IsStart()
+	if in.IsNull() {
+		if isTopLevel {
+			in.Consumed()
+		}
+		in.Skip()
+		return
+	}
+	in.Delim('{')
+	for !in.IsDelim('}') {
+		key := in.UnsafeFieldName(false)
+		in.WantColon()
+		if in.IsNull() {
+			in.Skip()
+			in.WantComma()
+			continue
+		}
+		switch key {
+		case "order":
+			out.Order = string(in.String())
+		default:
+			in.SkipRecursive()
+		}
+		in.WantComma()
+	}
+	in.Delim('}')
+	if isTopLevel {
+		in.Consumed()
+	}
+}
+func easyjsonB00e796eEncodeGithubComUjweghGophermartInternalAppHandlers6(out *jwriter.Writer, in CreateOrderRequestDTO) {
+	out.RawByte('{')
+	first := true
+	_ = first
+	{
+		const prefix string = ",\"order\":"
+		out.RawString(prefix[1:])
+		out.String(string(in.Order))
+	}
+	out.RawByte('}')
+}
+
+// MarshalJSON supports json.Marshaler interface
+func (v CreateOrderRequestDTO) MarshalJSON() ([]byte, error) {
+	w := jwriter.Writer{}
+	easyjsonB00e796eEncodeGithubComUjweghGophermartInternalAppHandlers6(&w, v)
+	return w.Buffer.BuildBytes(), w.Error
+}
+
+// MarshalEasyJSON supports easyjson.Marshaler interface
+func (v CreateOrderRequestDTO) MarshalEasyJSON(w *jwriter.Writer) {
+	easyjsonB00e796eEncodeGithubComUjweghGophermartInternalAppHandlers6(w, v)
+}
+
+// UnmarshalJSON supports json.Unmarshaler interface
+func (v *CreateOrderRequestDTO) UnmarshalJSON(data []byte) error {
+	r := jlexer.Lexer{Data: data}
+	easyjsonB00e796eDecodeGithubComUjweghGophermartInternalAppHandlers6(&r, v)
+	return r.Error()
+}
+
+// UnmarshalEasyJSON supports easyjson.Unmarshaler interface
+func (v *CreateOrderRequestDTO) UnmarshalEasyJSON(l *jlexer.Lexer) {
+	easyjsonB00e796eDecodeGithubComUjweghGophermartInternalAppHandlers6(l, v)
 }
@@ -3,15 +3,22 @@ package handlers
 import (
 	"context"
 	"errors"
+	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	_ "github.com/mattn/go-sqlite3"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 	appContext "github.com/ujwegh/gophermart/internal/app/context"
 	appErrors "github.com/ujwegh/gophermart/internal/app/errors"
 	"github.com/ujwegh/gophermart/internal/app/repository"
+	"github.com/ujwegh/gophermart/internal/app/service"
+	"math"
 	"net/http"
 	"net/http/httptest"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 )
@@ -30,11 +37,76 @@ func (m *MockOrderService) GetOrderByID(ctx context.Context, orderID string) (*r
 	return args.Get(0).(*repository.Order), args.Error(1)
 }
 
-func (m *MockOrderService) GetOrders(ctx context.Context, uid *uuid.UUID) (*[]repository.Order, error) {
+func (m *MockOrderService) GetUserOrderByID(ctx context.Context, orderID string, userUID *uuid.UUID) (*repository.Order, error) {
+	args := m.Called(ctx, orderID, userUID)
+	return args.Get(0).(*repository.Order), args.Error(1)
+}
+
+func (m *MockOrderService) GetOrders(ctx context.Context, uid *uuid.UUID, limit int, offset int) (*[]repository.Order, error) {
+	args := m.Called(ctx, uid, limit, offset)
+	return args.Get(0).(*[]repository.Order), args.Error(1)
+}
+
+func (m *MockOrderService) GetOrdersAfterCursor(ctx context.Context, uid *uuid.UUID, cursor *repository.OrderCursor, limit int) (*[]repository.Order, error) {
+	args := m.Called(ctx, uid, cursor, limit)
+	return args.Get(0).(*[]repository.Order), args.Error(1)
+}
+
+func (m *MockOrderService) GetOrdersSince(ctx context.Context, uid *uuid.UUID, since time.Time, limit int, offset int) (*[]repository.Order, error) {
+	args := m.Called(ctx, uid, since, limit, offset)
+	return args.Get(0).(*[]repository.Order), args.Error(1)
+}
+
+func (m *MockOrderService) CountOrders(ctx context.Context, uid *uuid.UUID) (int, error) {
 	args := m.Called(ctx, uid)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockOrderService) CorrectAccrual(ctx context.Context, orderID string, accrual repository.Cents) (*repository.AccrualCorrection, error) {
+	args := m.Called(ctx, orderID, accrual)
+	return args.Get(0).(*repository.AccrualCorrection), args.Error(1)
+}
+
+func (m *MockOrderService) GetPendingAccruals(ctx context.Context, uid *uuid.UUID) (float64, error) {
+	args := m.Called(ctx, uid)
+	return args.Get(0).(float64), args.Error(1)
+}
+
+func (m *MockOrderService) GetProcessedOrders(ctx context.Context, uid *uuid.UUID, limit int, offset int) (*[]repository.Order, error) {
+	args := m.Called(ctx, uid, limit, offset)
 	return args.Get(0).(*[]repository.Order), args.Error(1)
 }
 
+func (m *MockOrderService) GetOrdersInRange(ctx context.Context, from time.Time, to time.Time, limit int, offset int) (*[]repository.UserOrder, error) {
+	args := m.Called(ctx, from, to, limit, offset)
+	return args.Get(0).(*[]repository.UserOrder), args.Error(1)
+}
+
+func (m *MockOrderService) GetOrderHistory(ctx context.Context, orderID string, userUID *uuid.UUID) (*[]repository.OrderStatusChange, error) {
+	args := m.Called(ctx, orderID, userUID)
+	return args.Get(0).(*[]repository.OrderStatusChange), args.Error(1)
+}
+
+func (m *MockOrderService) RetryOrder(ctx context.Context, orderID string, userUID *uuid.UUID) (*repository.Order, error) {
+	args := m.Called(ctx, orderID, userUID)
+	return args.Get(0).(*repository.Order), args.Error(1)
+}
+
+func (m *MockOrderService) RetryOrders(ctx context.Context, orderIDs []string) (*service.BatchRetryResult, error) {
+	args := m.Called(ctx, orderIDs)
+	return args.Get(0).(*service.BatchRetryResult), args.Error(1)
+}
+
+func (m *MockOrderService) GetDeadLetterOrders(ctx context.Context, limit int, offset int) (*[]repository.UserOrder, error) {
+	args := m.Called(ctx, limit, offset)
+	return args.Get(0).(*[]repository.UserOrder), args.Error(1)
+}
+
+func (m *MockOrderService) RequeueDeadLetterOrders(ctx context.Context, orderIDs []string) (*service.BatchRetryResult, error) {
+	args := m.Called(ctx, orderIDs)
+	return args.Get(0).(*service.BatchRetryResult), args.Error(1)
+}
+
 func TestOrdersHandler_CreateOrder(t *testing.T) {
 	tests := []struct {
 		name             string
@@ -141,6 +213,192 @@ func TestOrdersHandler_CreateOrder(t *testing.T) {
 	}
 }
 
+func TestOrdersHandler_CreateOrder_AcceptJSON(t *testing.T) {
+	userUID := uuid.New()
+	order := &repository.Order{
+		ID:        "354188083613",
+		UserUUID:  userUID,
+		Status:    repository.NEW,
+		CreatedAt: time.Date(2021, 1, 2, 0, 0, 0, 0, time.UTC),
+	}
+	tests := []struct {
+		name             string
+		accept           string
+		wantResponseBody string
+	}{
+		{name: "JSON", accept: "application/json", wantResponseBody: `{"number":"354188083613","status":"NEW","uploaded_at":"2021-01-02T00:00:00Z"}`},
+		{name: "JSON Among Others", accept: "text/html, application/json;q=0.9", wantResponseBody: `{"number":"354188083613","status":"NEW","uploaded_at":"2021-01-02T00:00:00Z"}`},
+		{name: "No Accept Header", accept: ""},
+		{name: "Wildcard", accept: "*/*"},
+		{name: "JSON Refused", accept: "application/json;q=0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &MockOrderService{}
+			m.On("CreateOrder", mock.Anything, "354188083613", mock.Anything).Return(order, nil)
+			req := httptest.NewRequest("POST", "/api/user/orders", strings.NewReader("354188083613"))
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+			req = req.WithContext(appContext.WithUserUID(req.Context(), &userUID))
+			w := httptest.NewRecorder()
+
+			oh := &OrdersHandler{orderService: m, contextTimeout: 5 * time.Second}
+			oh.CreateOrder(w, req)
+
+			assert.Equal(t, http.StatusAccepted, w.Code)
+			if tt.wantResponseBody == "" {
+				assert.Empty(t, w.Body.String())
+				return
+			}
+			assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+			assert.JSONEq(t, tt.wantResponseBody, w.Body.String())
+		})
+	}
+}
+
+func TestOrdersHandler_CreateOrder_ContentTypes(t *testing.T) {
+	tests := []struct {
+		name             string
+		contentType      string
+		requestBody      string
+		wantCreate       bool
+		wantStatusCode   int
+		wantResponseBody string
+	}{
+		{name: "Plain Text", contentType: "text/plain", requestBody: "354188083613", wantCreate: true, wantStatusCode: http.StatusAccepted},
+		{name: "No Content Type", contentType: "", requestBody: "354188083613", wantCreate: true, wantStatusCode: http.StatusAccepted},
+		{name: "JSON", contentType: "application/json", requestBody: `{"order":"354188083613"}`, wantCreate: true, wantStatusCode: http.StatusAccepted},
+		{name: "JSON With Charset", contentType: "application/json; charset=utf-8", requestBody: `{"order": "354188083613"}`, wantCreate: true, wantStatusCode: http.StatusAccepted},
+		{
+			name:             "Malformed JSON",
+			contentType:      "application/json",
+			requestBody:      `{"order":`,
+			wantStatusCode:   http.StatusBadRequest,
+			wantResponseBody: `{"code":400,"message":"Unable to parse body"}`,
+		},
+		{
+			name:             "JSON With Invalid Number",
+			contentType:      "application/json",
+			requestBody:      `{"order":"123"}`,
+			wantStatusCode:   http.StatusUnprocessableEntity,
+			wantResponseBody: `{"code":422,"message":"Invalid order ID"}`,
+		},
+		{
+			name:             "Empty Body",
+			contentType:      "text/plain",
+			requestBody:      "",
+			wantStatusCode:   http.StatusBadRequest,
+			wantResponseBody: `{"code":400,"message":"Empty order number"}`,
+		},
+		{
+			name:             "Whitespace Only Body",
+			contentType:      "",
+			requestBody:      " \n\t",
+			wantStatusCode:   http.StatusBadRequest,
+			wantResponseBody: `{"code":400,"message":"Empty order number"}`,
+		},
+		{
+			name:             "JSON Without Number",
+			contentType:      "application/json",
+			requestBody:      `{"order":" "}`,
+			wantStatusCode:   http.StatusBadRequest,
+			wantResponseBody: `{"code":400,"message":"Empty order number"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &MockOrderService{}
+			m.On("CreateOrder", mock.Anything, "354188083613", mock.Anything).Return(&repository.Order{}, nil)
+			req := httptest.NewRequest("POST", "/api/user/orders", strings.NewReader(tt.requestBody))
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+			w := httptest.NewRecorder()
+
+			oh := &OrdersHandler{orderService: m, contextTimeout: 5 * time.Second}
+			oh.CreateOrder(w, req)
+
+			assert.Equal(t, tt.wantStatusCode, w.Code)
+			if tt.wantCreate {
+				m.AssertCalled(t, "CreateOrder", mock.Anything, "354188083613", mock.Anything)
+			} else {
+				m.AssertNotCalled(t, "CreateOrder", mock.Anything, mock.Anything, mock.Anything)
+				assert.JSONEq(t, tt.wantResponseBody, w.Body.String())
+			}
+		})
+	}
+}
+
+// racingOrderRepository reports the order as missing on the first lookup and then
+// fails the insert, as if a concurrent request created the same order in between.
+type racingOrderRepository struct {
+	repository.OrderRepository
+	db       *sqlx.DB
+	mu       sync.Mutex
+	existing repository.Order
+	lookups  int
+}
+
+func (r *racingOrderRepository) GetOrderByID(ctx context.Context, orderID string) (*repository.Order, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.lookups++
+	if r.lookups == 1 {
+		return nil, appErrors.NewWithCode(errors.New("no rows"), "Order not found", http.StatusNotFound)
+	}
+	order := r.existing
+	return &order, nil
+}
+
+func (r *racingOrderRepository) CreateOrderTx(ctx context.Context, tx *sqlx.Tx, order *repository.Order) error {
+	return repository.ErrOrderExists
+}
+
+// GetDB only serves to open the transaction CreateOrderTx is called in.
+func (r *racingOrderRepository) GetDB() *sqlx.DB {
+	return r.db
+}
+
+func TestOrdersHandler_CreateOrder_ConcurrentDuplicate(t *testing.T) {
+	ownerUID := uuid.New()
+	tests := []struct {
+		name           string
+		userUID        uuid.UUID
+		wantStatusCode int
+	}{
+		{name: "Same User", userUID: ownerUID, wantStatusCode: http.StatusOK},
+		{name: "Another User", userUID: uuid.New(), wantStatusCode: http.StatusConflict},
+	}
+
+	db, err := sqlx.Open("sqlite3", "file:racing_orders?mode=memory&cache=shared")
+	require.NoError(t, err)
+	defer db.Close()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &racingOrderRepository{db: db, existing: repository.Order{ID: "354188083613", UserUUID: ownerUID, Status: repository.NEW}}
+			walletService := &MockWalletService{}
+			walletService.On("EnsureWallet", mock.Anything, mock.Anything, &tt.userUID).Return(nil)
+			orderChan := make(chan repository.Order, 1)
+			oh := &OrdersHandler{
+				orderService:   service.NewOrderService(repo, nil, walletService, orderChan),
+				contextTimeout: 5 * time.Second,
+			}
+
+			req := httptest.NewRequest("POST", "/api/user/orders", strings.NewReader("354188083613"))
+			req = req.WithContext(appContext.WithUserUID(req.Context(), &tt.userUID))
+			w := httptest.NewRecorder()
+			oh.CreateOrder(w, req)
+
+			assert.Equal(t, tt.wantStatusCode, w.Code)
+			assert.Empty(t, orderChan)
+		})
+	}
+}
+
 // Define the mock methods for OrderService as needed
 
 func TestOrdersHandler_GetOrders(t *testing.T) {
@@ -163,7 +421,7 @@ func TestOrdersHandler_GetOrders(t *testing.T) {
 					{ID: "order1", Status: repository.NEW, Accrual: nil, CreatedAt: time.Now()},
 					{ID: "order2", Status: repository.PROCESSED, Accrual: &accrual, CreatedAt: time.Now()},
 				}
-				m.On("GetOrders", mock.Anything, mock.Anything).Return(orders, nil)
+				m.On("GetOrders", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(orders, nil)
 				return m
 			},
 			contextTimeout:   5 * time.Second,
@@ -176,7 +434,7 @@ func TestOrdersHandler_GetOrders(t *testing.T) {
 			name: "No Orders Found",
 			mockOrderService: func() *MockOrderService {
 				m := &MockOrderService{}
-				m.On("GetOrders", mock.Anything, mock.Anything).Return(&[]repository.Order{}, nil)
+				m.On("GetOrders", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(&[]repository.Order{}, nil)
 				return m
 			},
 			contextTimeout:   5 * time.Second,
@@ -190,7 +448,7 @@ func TestOrdersHandler_GetOrders(t *testing.T) {
 			mockOrderService: func() *MockOrderService {
 				m := &MockOrderService{}
 				err := errors.New("internal server error")
-				m.On("GetOrders", mock.Anything, mock.Anything).Return((*[]repository.Order)(nil), err)
+				m.On("GetOrders", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return((*[]repository.Order)(nil), err)
 				return m
 			},
 			contextTimeout:   5 * time.Second,
@@ -206,7 +464,7 @@ func TestOrdersHandler_GetOrders(t *testing.T) {
 				orders := &[]repository.Order{
 					{ID: "order1", Status: repository.NEW, Accrual: nil, CreatedAt: time.Now()},
 				}
-				m.On("GetOrders", mock.Anything, mock.Anything).Return(orders, nil)
+				m.On("GetOrders", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(orders, nil)
 				return m
 			},
 			contextTimeout:   0,
@@ -219,7 +477,7 @@ func TestOrdersHandler_GetOrders(t *testing.T) {
 			name: "Empty Orders",
 			mockOrderService: func() *MockOrderService {
 				m := &MockOrderService{}
-				m.On("GetOrders", mock.Anything, mock.Anything).Return(&[]repository.Order{}, nil)
+				m.On("GetOrders", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(&[]repository.Order{}, nil)
 				return m
 			},
 			contextTimeout:   5,
@@ -259,3 +517,375 @@ func TestOrdersHandler_GetOrders(t *testing.T) {
 		})
 	}
 }
+
+func TestOrdersHandler_GetOrderHistory(t *testing.T) {
+	userUID := uuid.New()
+	accrual := 500.0
+	createdAt := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name             string
+		mockOrderService func() *MockOrderService
+		wantStatusCode   int
+		wantResponseBody string
+	}{
+		{
+			name: "Order Timeline",
+			mockOrderService: func() *MockOrderService {
+				m := &MockOrderService{}
+				history := &[]repository.OrderStatusChange{
+					{OrderID: "354188083613", Status: repository.PROCESSING, ChangedAt: createdAt},
+					{OrderID: "354188083613", Status: repository.PROCESSED, Accrual: &accrual, ChangedAt: createdAt.Add(time.Minute)},
+				}
+				m.On("GetOrderHistory", mock.Anything, "354188083613", &userUID).Return(history, nil)
+				return m
+			},
+			wantStatusCode: http.StatusOK,
+			wantResponseBody: `[{"status":"PROCESSING","changed_at":"2021-01-01T00:00:00Z"},
+				{"status":"PROCESSED","accrual":500,"changed_at":"2021-01-01T00:01:00Z"}]`,
+		},
+		{
+			name: "Order Of Another User",
+			mockOrderService: func() *MockOrderService {
+				m := &MockOrderService{}
+				err := appErrors.NewWithCode(errors.New("Order not found"), "Order not found", http.StatusNotFound)
+				m.On("GetOrderHistory", mock.Anything, "354188083613", &userUID).Return((*[]repository.OrderStatusChange)(nil), err)
+				return m
+			},
+			wantStatusCode:   http.StatusNotFound,
+			wantResponseBody: `{"code":404,"message":"Order not found"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest("GET", "/api/user/orders/354188083613/history", nil)
+			assert.NoError(t, err)
+
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("number", "354188083613")
+			ctx := context.WithValue(appContext.WithUserUID(req.Context(), &userUID), chi.RouteCtxKey, rctx)
+			req = req.WithContext(ctx)
+			w := httptest.NewRecorder()
+
+			oh := &OrdersHandler{
+				orderService:   tt.mockOrderService(),
+				contextTimeout: 5 * time.Second,
+			}
+			oh.GetOrderHistory(w, req)
+
+			assert.Equal(t, tt.wantStatusCode, w.Code)
+			assert.JSONEq(t, tt.wantResponseBody, w.Body.String())
+		})
+	}
+}
+
+func TestOrdersHandler_GetOrders_Pagination(t *testing.T) {
+	userUID := uuid.New()
+	tests := []struct {
+		name           string
+		query          string
+		wantLimit      int
+		wantOffset     int
+		wantStatusCode int
+	}{
+		{name: "All Orders Without Limit", query: "", wantLimit: math.MaxInt32, wantOffset: 0, wantStatusCode: http.StatusNoContent},
+		{name: "Offset Without Limit", query: "?offset=5", wantLimit: math.MaxInt32, wantOffset: 5, wantStatusCode: http.StatusNoContent},
+		{name: "Limit Within Bounds", query: "?limit=30&offset=10", wantLimit: 30, wantOffset: 10, wantStatusCode: http.StatusNoContent},
+		{name: "Limit At Maximum", query: "?limit=50", wantLimit: 50, wantOffset: 0, wantStatusCode: http.StatusNoContent},
+		{name: "Limit Above Maximum", query: "?limit=51", wantLimit: 50, wantOffset: 0, wantStatusCode: http.StatusNoContent},
+		{name: "Limit Of One", query: "?limit=1", wantLimit: 1, wantOffset: 0, wantStatusCode: http.StatusNoContent},
+		{name: "Zero Limit", query: "?limit=0", wantLimit: 1, wantOffset: 0, wantStatusCode: http.StatusNoContent},
+		{name: "Negative Limit", query: "?limit=-5", wantLimit: 1, wantOffset: 0, wantStatusCode: http.StatusNoContent},
+		{name: "Non Numeric Limit", query: "?limit=ten", wantStatusCode: http.StatusBadRequest},
+		{name: "Negative Offset", query: "?offset=-1", wantStatusCode: http.StatusBadRequest},
+		{name: "Misspelled Limit", query: "?limt=10", wantStatusCode: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest("GET", "/api/user/orders"+tt.query, nil)
+			assert.NoError(t, err)
+			req = req.WithContext(appContext.WithUserUID(req.Context(), &userUID))
+			w := httptest.NewRecorder()
+
+			m := &MockOrderService{}
+			m.On("GetOrders", mock.Anything, &userUID, tt.wantLimit, tt.wantOffset).Return(&[]repository.Order{}, nil)
+			oh := &OrdersHandler{
+				orderService:   m,
+				contextTimeout: 5 * time.Second,
+				pagination:     NewPagination(20, 50),
+			}
+			oh.GetOrders(w, req)
+
+			assert.Equal(t, tt.wantStatusCode, w.Code)
+			if tt.wantStatusCode == http.StatusBadRequest {
+				m.AssertNotCalled(t, "GetOrders", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+			} else {
+				m.AssertExpectations(t)
+			}
+		})
+	}
+}
+
+func TestOrdersHandler_GetOrders_Envelope(t *testing.T) {
+	userUID := uuid.New()
+	accrual := 500.0
+	orders := &[]repository.Order{
+		{ID: "354188083613", Status: repository.PROCESSED, Accrual: &accrual, CreatedAt: time.Date(2021, 1, 2, 0, 0, 0, 0, time.UTC)},
+		{ID: "12345678903", Status: repository.NEW, CreatedAt: time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)},
+	}
+	tests := []struct {
+		name             string
+		query            string
+		orders           *[]repository.Order
+		total            int
+		wantStatusCode   int
+		wantResponseBody string
+	}{
+		{
+			name:           "Bare Array By Default",
+			query:          "?limit=2",
+			orders:         orders,
+			wantStatusCode: http.StatusOK,
+			wantResponseBody: `[{"number":"354188083613","status":"PROCESSED","accrual":500,"uploaded_at":"2021-01-02T00:00:00Z"},
+				{"number":"12345678903","status":"NEW","uploaded_at":"2021-01-01T00:00:00Z"}]`,
+		},
+		{
+			name:           "Envelope With Metadata",
+			query:          "?limit=2&envelope=true",
+			orders:         orders,
+			total:          5,
+			wantStatusCode: http.StatusOK,
+			wantResponseBody: `{"data":[{"number":"354188083613","status":"PROCESSED","accrual":500,"uploaded_at":"2021-01-02T00:00:00Z"},
+				{"number":"12345678903","status":"NEW","uploaded_at":"2021-01-01T00:00:00Z"}],
+				"page":{"limit":2,"offset":0},"total":5}`,
+		},
+		{
+			name:             "Empty Envelope",
+			query:            "?limit=2&envelope=true",
+			orders:           &[]repository.Order{},
+			wantStatusCode:   http.StatusOK,
+			wantResponseBody: `{"data":[],"page":{"limit":2,"offset":0},"total":0}`,
+		},
+		{
+			name:             "Invalid Envelope",
+			query:            "?limit=2&envelope=maybe",
+			wantStatusCode:   http.StatusBadRequest,
+			wantResponseBody: `{"code":400,"message":"Invalid envelope parameter"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/api/user/orders"+tt.query, nil)
+			req = req.WithContext(appContext.WithUserUID(req.Context(), &userUID))
+			w := httptest.NewRecorder()
+
+			m := &MockOrderService{}
+			m.On("GetOrders", mock.Anything, &userUID, 2, 0).Return(tt.orders, nil)
+			m.On("CountOrders", mock.Anything, &userUID).Return(tt.total, nil)
+			oh := &OrdersHandler{
+				orderService:   m,
+				contextTimeout: 5 * time.Second,
+				pagination:     NewPagination(20, 50),
+			}
+			oh.GetOrders(w, req)
+
+			assert.Equal(t, tt.wantStatusCode, w.Code)
+			assert.JSONEq(t, tt.wantResponseBody, w.Body.String())
+		})
+	}
+}
+
+func TestOrdersHandler_GetOrders_NewOnly(t *testing.T) {
+	userUID := uuid.New()
+	seen := time.Date(2021, 1, 2, 0, 0, 0, 0, time.UTC)
+	newOrders := []repository.Order{
+		{ID: "354188083613", Status: repository.NEW, CreatedAt: seen.Add(time.Hour)},
+	}
+	get := func(oh *OrdersHandler, query string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/api/user/orders"+query, nil)
+		req = req.WithContext(appContext.WithUserUID(req.Context(), &userUID))
+		w := httptest.NewRecorder()
+		oh.GetOrders(w, req)
+		return w
+	}
+	newHandler := func(orders *MockOrderService, markers *MockDeviceMarkerService) *OrdersHandler {
+		return &OrdersHandler{
+			orderService:        orders,
+			deviceMarkerService: markers,
+			contextTimeout:      5 * time.Second,
+			pagination:          NewPagination(20, 50),
+		}
+	}
+
+	t.Run("Orders After The Marker", func(t *testing.T) {
+		markers := &MockDeviceMarkerService{}
+		markers.On("GetMarker", mock.Anything, &userUID, "phone").
+			Return(&repository.DeviceMarker{UserUUID: userUID, DeviceID: "phone", LastSeenAt: seen}, nil)
+		orders := &MockOrderService{}
+		orders.On("GetOrdersSince", mock.Anything, &userUID, seen, math.MaxInt32, 0).Return(&newOrders, nil)
+
+		w := get(newHandler(orders, markers), "?new_only=true&device=phone")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `[{"number":"354188083613","status":"NEW","uploaded_at":"2021-01-02T01:00:00Z"}]`, w.Body.String())
+		orders.AssertExpectations(t)
+	})
+
+	t.Run("All Orders Without A Marker", func(t *testing.T) {
+		markers := &MockDeviceMarkerService{}
+		err := appErrors.NewWithCode(repository.ErrDeviceMarkerNotFound, "Device marker not found", http.StatusNotFound)
+		markers.On("GetMarker", mock.Anything, &userUID, "phone").Return((*repository.DeviceMarker)(nil), err)
+		orders := &MockOrderService{}
+		orders.On("GetOrdersSince", mock.Anything, &userUID, time.Time{}, math.MaxInt32, 0).Return(&newOrders, nil)
+
+		w := get(newHandler(orders, markers), "?new_only=true&device=phone")
+		assert.Equal(t, http.StatusOK, w.Code)
+		orders.AssertExpectations(t)
+	})
+
+	t.Run("Device Is Required", func(t *testing.T) {
+		orders := &MockOrderService{}
+		w := get(newHandler(orders, &MockDeviceMarkerService{}), "?new_only=true")
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.JSONEq(t, `{"code":400,"message":"device is required with new_only"}`, w.Body.String())
+		orders.AssertNotCalled(t, "GetOrdersSince", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Not Together With Cursor", func(t *testing.T) {
+		w := get(newHandler(&MockOrderService{}, &MockDeviceMarkerService{}), "?new_only=true&device=phone&cursor=")
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
+
+func TestOrdersHandler_GetOrders_Cursor(t *testing.T) {
+	userUID := uuid.New()
+	orders := []repository.Order{
+		{ID: "354188083613", Status: repository.PROCESSED, CreatedAt: time.Date(2021, 1, 3, 0, 0, 0, 0, time.UTC)},
+		{ID: "12345678903", Status: repository.NEW, CreatedAt: time.Date(2021, 1, 2, 0, 0, 0, 0, time.UTC)},
+		{ID: "79927398713", Status: repository.NEW, CreatedAt: time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)},
+	}
+	newHandler := func(m *MockOrderService) *OrdersHandler {
+		return &OrdersHandler{
+			orderService:   m,
+			contextTimeout: 5 * time.Second,
+			pagination:     NewPagination(20, 50),
+		}
+	}
+	get := func(oh *OrdersHandler, query string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/api/user/orders"+query, nil)
+		req = req.WithContext(appContext.WithUserUID(req.Context(), &userUID))
+		w := httptest.NewRecorder()
+		oh.GetOrders(w, req)
+		return w
+	}
+
+	t.Run("Pages Follow Next Cursor", func(t *testing.T) {
+		next := repository.NewOrderCursor(orders[1])
+		m := &MockOrderService{}
+		first := orders[:3]
+		second := orders[2:]
+		m.On("GetOrdersAfterCursor", mock.Anything, &userUID, (*repository.OrderCursor)(nil), 3).Return(&first, nil)
+		m.On("GetOrdersAfterCursor", mock.Anything, &userUID, &next, 3).Return(&second, nil)
+		m.On("CountOrders", mock.Anything, &userUID).Return(3, nil)
+		oh := newHandler(m)
+
+		w := get(oh, "?limit=2&cursor=")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"data":[{"number":"354188083613","status":"PROCESSED","uploaded_at":"2021-01-03T00:00:00Z"},
+			{"number":"12345678903","status":"NEW","uploaded_at":"2021-01-02T00:00:00Z"}],
+			"page":{"limit":2,"offset":0},"total":3,"next_cursor":"`+next.Encode()+`"}`, w.Body.String())
+
+		w = get(oh, "?limit=2&cursor="+next.Encode())
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"data":[{"number":"79927398713","status":"NEW","uploaded_at":"2021-01-01T00:00:00Z"}],
+			"page":{"limit":2,"offset":0,"cursor":"`+next.Encode()+`"},"total":3}`, w.Body.String())
+		m.AssertExpectations(t)
+	})
+
+	t.Run("Offset With Cursor", func(t *testing.T) {
+		w := get(newHandler(&MockOrderService{}), "?limit=2&offset=2&cursor=")
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.JSONEq(t, `{"code":400,"message":"Invalid pagination parameters"}`, w.Body.String())
+	})
+
+	t.Run("Invalid Cursor", func(t *testing.T) {
+		w := get(newHandler(&MockOrderService{}), "?limit=2&cursor=not-a-cursor")
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.JSONEq(t, `{"code":400,"message":"Invalid cursor"}`, w.Body.String())
+	})
+}
+
+func TestOrdersHandler_RetryOrder(t *testing.T) {
+	userUID := uuid.New()
+	newRequest := func(orderID string) *http.Request {
+		req, err := http.NewRequest("POST", "/api/user/orders/"+orderID+"/retry", nil)
+		assert.NoError(t, err)
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("number", orderID)
+		ctx := context.WithValue(appContext.WithUserUID(req.Context(), &userUID), chi.RouteCtxKey, rctx)
+		return req.WithContext(ctx)
+	}
+
+	t.Run("Re-enqueue Then Rate Limit", func(t *testing.T) {
+		m := &MockOrderService{}
+		retried := &repository.Order{ID: "354188083613", UserUUID: userUID, Status: repository.NEW}
+		m.On("RetryOrder", mock.Anything, "354188083613", &userUID).Return(retried, nil).Once()
+		oh := &OrdersHandler{
+			orderService:   m,
+			contextTimeout: 5 * time.Second,
+			retryLimiter:   newRetryLimiter(time.Minute),
+		}
+
+		w := httptest.NewRecorder()
+		oh.RetryOrder(w, newRequest("354188083613"))
+		assert.Equal(t, http.StatusAccepted, w.Code)
+
+		w = httptest.NewRecorder()
+		oh.RetryOrder(w, newRequest("354188083613"))
+		assert.Equal(t, http.StatusTooManyRequests, w.Code)
+		assert.NotEmpty(t, w.Header().Get("Retry-After"))
+		assert.JSONEq(t, `{"code":429,"message":"Order was retried recently"}`, w.Body.String())
+
+		m.AssertNumberOfCalls(t, "RetryOrder", 1)
+	})
+
+	t.Run("Padded Number Shares Cooldown", func(t *testing.T) {
+		m := &MockOrderService{}
+		retried := &repository.Order{ID: "354188083613", UserUUID: userUID, Status: repository.NEW}
+		m.On("RetryOrder", mock.Anything, "00354188083613", &userUID).Return(retried, nil).Once()
+		oh := &OrdersHandler{
+			orderService:   m,
+			contextTimeout: 5 * time.Second,
+			retryLimiter:   newRetryLimiter(time.Minute),
+		}
+
+		w := httptest.NewRecorder()
+		oh.RetryOrder(w, newRequest("00354188083613"))
+		assert.Equal(t, http.StatusAccepted, w.Code)
+
+		w = httptest.NewRecorder()
+		oh.RetryOrder(w, newRequest("354188083613"))
+		assert.Equal(t, http.StatusTooManyRequests, w.Code)
+
+		m.AssertNumberOfCalls(t, "RetryOrder", 1)
+	})
+
+	t.Run("Failed Retry Does Not Consume Cooldown", func(t *testing.T) {
+		m := &MockOrderService{}
+		err := appErrors.NewWithCode(errors.New("Order cannot be retried"), "Order cannot be retried", http.StatusConflict)
+		m.On("RetryOrder", mock.Anything, "12345678903", &userUID).Return((*repository.Order)(nil), err)
+		oh := &OrdersHandler{
+			orderService:   m,
+			contextTimeout: 5 * time.Second,
+			retryLimiter:   newRetryLimiter(time.Minute),
+		}
+
+		for i := 0; i < 2; i++ {
+			w := httptest.NewRecorder()
+			oh.RetryOrder(w, newRequest("12345678903"))
+			assert.Equal(t, http.StatusConflict, w.Code)
+		}
+		m.AssertNumberOfCalls(t, "RetryOrder", 2)
+	})
+}
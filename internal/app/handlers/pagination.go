@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"errors"
+	"github.com/ujwegh/gophermart/internal/app/config"
+	appErrors "github.com/ujwegh/gophermart/internal/app/errors"
+	"math"
+	"net/http"
+	"strconv"
+)
+
+const errMsgInvalidPagination = "Invalid pagination parameters"
+
+type (
+	Pagination struct {
+		DefaultPageSize int
+		MaxPageSize     int
+	}
+	Page struct {
+		// Limit is 0 for a page without a limit, see RowLimit
+		Limit  int
+		Offset int
+	}
+)
+
+func NewPagination(defaultPageSize, maxPageSize int) Pagination {
+	return Pagination{
+		DefaultPageSize: defaultPageSize,
+		MaxPageSize:     maxPageSize,
+	}
+}
+
+// ParsePage reads the limit and offset query params, clamping the limit to [1, MaxPageSize]
+// and falling back to DefaultPageSize when it is absent.
+func (p Pagination) ParsePage(r *http.Request) (Page, error) {
+	defaultSize, maxSize := p.DefaultPageSize, p.MaxPageSize
+	if maxSize <= 0 {
+		maxSize = config.MaxPageSize
+	}
+	if defaultSize <= 0 {
+		defaultSize = config.DefaultPageSize
+	}
+
+	page := Page{Limit: defaultSize}
+	query := r.URL.Query()
+	if rawLimit := query.Get("limit"); rawLimit != "" {
+		limit, err := strconv.Atoi(rawLimit)
+		if err != nil {
+			return Page{}, appErrors.NewWithCode(err, errMsgInvalidPagination, http.StatusBadRequest)
+		}
+		page.Limit = limit
+	}
+	if rawOffset := query.Get("offset"); rawOffset != "" {
+		offset, err := strconv.Atoi(rawOffset)
+		if err != nil {
+			return Page{}, appErrors.NewWithCode(err, errMsgInvalidPagination, http.StatusBadRequest)
+		}
+		if offset < 0 {
+			return Page{}, appErrors.NewWithCode(errors.New("negative offset"), errMsgInvalidPagination, http.StatusBadRequest)
+		}
+		page.Offset = offset
+	}
+
+	if page.Limit < 1 {
+		page.Limit = 1
+	}
+	if page.Limit > maxSize {
+		page.Limit = maxSize
+	}
+	return page, nil
+}
+
+// ParseOptionalPage is ParsePage for the listings that return all rows unless the client asks for a page:
+// without the limit param the page has no limit. The offset applies either way.
+func (p Pagination) ParseOptionalPage(r *http.Request) (Page, error) {
+	page, err := p.ParsePage(r)
+	if err != nil {
+		return Page{}, err
+	}
+	if r.URL.Query().Get("limit") == "" {
+		page.Limit = 0
+	}
+	return page, nil
+}
+
+// RowLimit returns how many rows to read for the page.
+func (page Page) RowLimit() int {
+	if page.Limit == 0 {
+		return math.MaxInt32
+	}
+	return page.Limit
+}
@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"fmt"
+	appErrors "github.com/ujwegh/gophermart/internal/app/errors"
+	"net/http"
+	"sort"
+)
+
+// UnknownQueryParamError is returned, wrapped in a 400, for a query parameter the endpoint doesn't know,
+// so a misspelled limt=10 isn't silently ignored.
+type UnknownQueryParamError struct {
+	Param string
+}
+
+func (e *UnknownQueryParamError) Error() string {
+	return fmt.Sprintf("unknown query parameter %q", e.Param)
+}
+
+// checkQueryParams rejects query parameters that are not in allowed, naming the first one in alphabetical order.
+func checkQueryParams(r *http.Request, allowed ...string) error {
+	var unknown []string
+	for param := range r.URL.Query() {
+		if !containsString(allowed, param) {
+			unknown = append(unknown, param)
+		}
+	}
+	if len(unknown) == 0 {
+		return nil
+	}
+	sort.Strings(unknown)
+	msg := fmt.Sprintf("Unknown query parameter %q", unknown[0])
+	return appErrors.NewWithCode(&UnknownQueryParamError{Param: unknown[0]}, msg, http.StatusBadRequest)
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,22 @@
+package handlers
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"testing"
+)
+
+func TestCheckQueryParams(t *testing.T) {
+	req, err := http.NewRequest("GET", "/api/user/orders?limit=10&offset=0", nil)
+	require.NoError(t, err)
+	assert.NoError(t, checkQueryParams(req, "limit", "offset"))
+
+	req, err = http.NewRequest("GET", "/api/user/orders?limt=10&ofset=5&offset=0", nil)
+	require.NoError(t, err)
+	err = checkQueryParams(req, "limit", "offset")
+	var paramErr *UnknownQueryParamError
+	require.True(t, errors.As(err, &paramErr))
+	assert.Equal(t, "limt", paramErr.Param)
+}
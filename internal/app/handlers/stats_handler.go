@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	appContext "github.com/ujwegh/gophermart/internal/app/context"
+	"github.com/ujwegh/gophermart/internal/app/service"
+	"net/http"
+	"time"
+)
+
+type (
+	StatsHandler struct {
+		statsService   service.StatsService
+		contextTimeout time.Duration
+	}
+
+	//easyjson:json
+	UserStatsDTO struct {
+		TotalOrders    int            `json:"total_orders"`
+		OrdersByStatus map[string]int `json:"orders_by_status"`
+		Accrued        float64        `json:"accrued"`
+		Withdrawn      float64        `json:"withdrawn"`
+		Balance        float64        `json:"balance"`
+	}
+)
+
+func NewStatsHandler(contextTimeoutSec int, statsService service.StatsService) *StatsHandler {
+	return &StatsHandler{
+		statsService:   statsService,
+		contextTimeout: time.Duration(contextTimeoutSec) * time.Second,
+	}
+}
+
+// GetStats godoc
+// @Summary Receiving aggregate statistics of the user
+// @Description The handler returns the number of orders of an authorized user, in total and by status,
+// @Description the accruals of processed orders, the withdrawn points and the current balance in one response.
+// @Tags balance
+// @Produce json
+// @Success 200 {object} UserStatsDTO "Aggregate statistics"
+// @Failure 401 {object} ErrorResponse "Unauthorized - The user is not authorized"
+// @Failure 500 {object} ErrorResponse "Internal Server Error"
+// @Security ApiKeyAuth
+// @Router /api/user/stats [get]
+func (sh *StatsHandler) GetStats(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), sh.contextTimeout)
+	defer cancel()
+	userUID := appContext.UserUID(r.Context())
+
+	stats, err := sh.statsService.GetUserStats(ctx, userUID)
+	if err != nil {
+		PrepareError(w, r, err)
+		return
+	}
+	response := UserStatsDTO{
+		TotalOrders:    stats.TotalOrders,
+		OrdersByStatus: make(map[string]int, len(stats.OrdersByStatus)),
+		Accrued:        stats.Accrued,
+		Withdrawn:      stats.Withdrawn,
+		Balance:        stats.Balance,
+	}
+	for status, count := range stats.OrdersByStatus {
+		response.OrdersByStatus[status.String()] = count
+	}
+	rawBytes, err := response.MarshalJSON()
+	if err != nil {
+		PrepareError(w, r, fmt.Errorf("unable to marshal response: %w", err))
+		return
+	}
+
+	err = appContext.GetContextError(ctx)
+	if err != nil {
+		PrepareError(w, r, err)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(rawBytes)
+}
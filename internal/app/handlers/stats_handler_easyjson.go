@@ -0,0 +1,143 @@
+// Code generated by easyjson for marshaling/unmarshaling. DO NOT EDIT.
+
+package handlers
+
+import (
+	json "encoding/json"
+	easyjson "github.com/mailru/easyjson"
+	jlexer "github.com/mailru/easyjson/jlexer"
+	jwriter "github.com/mailru/easyjson/jwriter"
+)
+
+// suppress unused package warning
+var (
+	_ *json.RawMessage
+	_ *jlexer.Lexer
+	_ *jwriter.Writer
+	_ easyjson.Marshaler
+)
+
+func easyjsonBaab8c82DecodeGithubComUjweghGophermartInternalAppHandlers(in *jlexer.Lexer, out *UserStatsDTO) {
+	isTopLevel := in.IsStart()
+	if in.IsNull() {
+		if isTopLevel {
+			in.Consumed()
+		}
+		in.Skip()
+		return
+	}
+	in.Delim('{')
+	for !in.IsDelim('}') {
+		key := in.UnsafeFieldName(false)
+		in.WantColon()
+		if in.IsNull() {
+			in.Skip()
+			in.WantComma()
+			continue
+		}
+		switch key {
+		case "total_orders":
+			out.TotalOrders = int(in.Int())
+		case "orders_by_status":
+			if in.IsNull() {
+				in.Skip()
+			} else {
+				in.Delim('{')
+				out.OrdersByStatus = make(map[string]int)
+				for !in.IsDelim('}') {
+					key := string(in.String())
+					in.WantColon()
+					var v1 int
+					v1 = int(in.Int())
+					(out.OrdersByStatus)[key] = v1
+					in.WantComma()
+				}
+				in.Delim('}')
+			}
+		case "accrued":
+			out.Accrued = float64(in.Float64())
+		case "withdrawn":
+			out.Withdrawn = float64(in.Float64())
+		case "balance":
+			out.Balance = float64(in.Float64())
+		default:
+			in.SkipRecursive()
+		}
+		in.WantComma()
+	}
+	in.Delim('}')
+	if isTopLevel {
+		in.Consumed()
+	}
+}
+func easyjsonBaab8c82EncodeGithubComUjweghGophermartInternalAppHandlers(out *jwriter.Writer, in UserStatsDTO) {
+	out.RawByte('{')
+	first := true
+	_ = first
+	{
+		const prefix string = ",\"total_orders\":"
+		out.RawString(prefix[1:])
+		out.Int(int(in.TotalOrders))
+	}
+	{
+		const prefix string = ",\"orders_by_status\":"
+		out.RawString(prefix)
+		if in.OrdersByStatus == nil && (out.Flags&jwriter.NilMapAsEmpty) == 0 {
+			out.RawString(`null`)
+		} else {
+			out.RawByte('{')
+			v2First := true
+			for v2Name, v2Value := range in.OrdersByStatus {
+				if v2First {
+					v2First = false
+				} else {
+					out.RawByte(',')
+				}
+				out.String(string(v2Name))
+				out.RawByte(':')
+				out.Int(int(v2Value))
+			}
+			out.RawByte('}')
+		}
+	}
+	{
+		const prefix string = ",\"accrued\":"
+		out.RawString(prefix)
+		out.Float64(float64(in.Accrued))
+	}
+	{
+		const prefix string = ",\"withdrawn\":"
+		out.RawString(prefix)
+		out.Float64(float64(in.Withdrawn))
+	}
+	{
+		const prefix string = ",\"balance\":"
+		out.RawString(prefix)
+		out.Float64(float64(in.Balance))
+	}
+	out.RawByte('}')
+}
+
+// MarshalJSON supports json.Marshaler interface
+func (v UserStatsDTO) MarshalJSON() ([]byte, error) {
+	w := jwriter.Writer{}
+	easyjsonBaab8c82EncodeGithubComUjweghGophermartInternalAppHandlers(&w, v)
+	return w.Buffer.BuildBytes(), w.Error
+}
+
+// MarshalEasyJSON supports easyjson.Marshaler interface
+func (v UserStatsDTO) MarshalEasyJSON(w *jwriter.Writer) {
+	easyjsonBaab8c82EncodeGithubComUjweghGophermartInternalAppHandlers(w, v)
+}
+
+// UnmarshalJSON supports json.Unmarshaler interface
+func (v *UserStatsDTO) UnmarshalJSON(data []byte) error {
+	r := jlexer.Lexer{Data: data}
+	easyjsonBaab8c82DecodeGithubComUjweghGophermartInternalAppHandlers(&r, v)
+	return r.Error()
+}
+
+// UnmarshalEasyJSON supports easyjson.Unmarshaler interface
+func (v *UserStatsDTO) UnmarshalEasyJSON(l *jlexer.Lexer) {
+	easyjsonBaab8c82DecodeGithubComUjweghGophermartInternalAppHandlers(l, v)
+}
@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	appContext "github.com/ujwegh/gophermart/internal/app/context"
+	"github.com/ujwegh/gophermart/internal/app/repository"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type MockStatsService struct {
+	mock.Mock
+}
+
+func (m *MockStatsService) GetUserStats(ctx context.Context, userUID *uuid.UUID) (*repository.UserStats, error) {
+	args := m.Called(ctx, userUID)
+	return args.Get(0).(*repository.UserStats), args.Error(1)
+}
+
+func TestStatsHandler_GetStats(t *testing.T) {
+	userUID := uuid.New()
+	tests := []struct {
+		name             string
+		stats            *repository.UserStats
+		err              error
+		wantStatusCode   int
+		wantResponseBody string
+	}{
+		{
+			name: "Successful Stats Retrieval",
+			stats: &repository.UserStats{
+				TotalOrders: 6,
+				OrdersByStatus: map[repository.Status]int{
+					repository.NEW: 1, repository.PROCESSING: 2, repository.INVALID: 1, repository.PROCESSED: 2,
+				},
+				Accrued:   300.5,
+				Withdrawn: 50,
+				Balance:   250.5,
+			},
+			wantStatusCode: http.StatusOK,
+			wantResponseBody: `{
+				"total_orders": 6,
+				"orders_by_status": {"NEW": 1, "PROCESSING": 2, "INVALID": 1, "PROCESSED": 2},
+				"accrued": 300.5,
+				"withdrawn": 50,
+				"balance": 250.5
+			}`,
+		},
+		{
+			name:             "Error In Fetching Stats",
+			stats:            (*repository.UserStats)(nil),
+			err:              errors.New("db down"),
+			wantStatusCode:   http.StatusInternalServerError,
+			wantResponseBody: `{"code":500,"message":"Internal Server Error"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ss := &MockStatsService{}
+			ss.On("GetUserStats", mock.Anything, &userUID).Return(tt.stats, tt.err)
+			sh := &StatsHandler{statsService: ss, contextTimeout: 5 * time.Second}
+
+			req := httptest.NewRequest("GET", "/api/user/stats", nil)
+			req = req.WithContext(appContext.WithUserUID(req.Context(), &userUID))
+			w := httptest.NewRecorder()
+
+			sh.GetStats(w, req)
+
+			assert.Equal(t, tt.wantStatusCode, w.Code)
+			assert.JSONEq(t, tt.wantResponseBody, w.Body.String())
+			if tt.wantStatusCode == http.StatusOK {
+				assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+			}
+		})
+	}
+}
@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	appErrors "github.com/ujwegh/gophermart/internal/app/errors"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// Method-less copies of the request DTOs for decodeStrict: encoding/json would otherwise call
+// their easyjson UnmarshalJSON, which skips unknown fields.
+type (
+	strictUserRegisterDto        UserRegisterDto
+	strictUserLoginDto           UserLoginDto
+	strictUserProfileDto         UserProfileDto
+	strictWithdrawRequestDTO     WithdrawRequestDTO
+	strictDeviceMarkerRequestDTO DeviceMarkerRequestDTO
+)
+
+// requireJSON answers 415 Unsupported Media Type for requests whose Content-Type is missing or not JSON.
+func requireJSON(r *http.Request) error {
+	contentType := r.Header.Get("Content-Type")
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err == nil && (mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")) {
+		return nil
+	}
+	msg := "Content-Type must be application/json"
+	return appErrors.NewWithCode(fmt.Errorf("unsupported content type %q", contentType), msg, http.StatusUnsupportedMediaType)
+}
+
+// decodeStrict decodes a JSON body into dto, which must not implement json.Unmarshaler, rejecting unknown fields,
+// values of the wrong type and trailing data. The returned error is a 400 describing the first problem.
+func decodeStrict(body []byte, dto interface{}) error {
+	if !bytes.HasPrefix(bytes.TrimSpace(body), []byte("{")) {
+		msg := "Body must be a JSON object"
+		return appErrors.NewWithCode(errors.New(msg), msg, http.StatusBadRequest)
+	}
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.DisallowUnknownFields()
+	err := dec.Decode(dto)
+	if err == nil {
+		if _, tokenErr := dec.Token(); tokenErr == io.EOF {
+			return nil
+		}
+		err = errors.New("unexpected data after the JSON object")
+	}
+
+	msg := "Unable to parse body"
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &typeErr) && typeErr.Field != "":
+		msg = fmt.Sprintf("Invalid type of field %q: got %s", typeErr.Field, typeErr.Value)
+	case errors.As(err, &typeErr):
+		// encoding/json leaves Field empty for some types, json.Number among them
+		msg = fmt.Sprintf("Invalid type of a field: got %s", typeErr.Value)
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		msg = fmt.Sprintf("Unknown field %s", strings.TrimPrefix(err.Error(), "json: unknown field "))
+	}
+	return appErrors.NewWithCode(err, msg, http.StatusBadRequest)
+}
@@ -0,0 +1,22 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	appErrors "github.com/ujwegh/gophermart/internal/app/errors"
+	"net/http"
+)
+
+// maxTenantIDLength matches the tenant_id columns.
+const maxTenantIDLength = 64
+
+// requestTenant reads the tenant of a registration or login from the X-Tenant-ID header,
+// "" when absent for the default tenant. Authenticated requests take the tenant from the token instead.
+func requestTenant(r *http.Request) (string, error) {
+	tenantID := r.Header.Get("X-Tenant-ID")
+	if len(tenantID) > maxTenantIDLength {
+		msg := fmt.Sprintf("Tenant identifier must be at most %d characters", maxTenantIDLength)
+		return "", appErrors.NewWithCode(errors.New(msg), msg, http.StatusBadRequest)
+	}
+	return tenantID, nil
+}
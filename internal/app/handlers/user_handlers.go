@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	appContext "github.com/ujwegh/gophermart/internal/app/context"
 	appErrors "github.com/ujwegh/gophermart/internal/app/errors"
@@ -9,6 +10,8 @@ import (
 	"github.com/ujwegh/gophermart/internal/app/service"
 	"io"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -19,6 +22,11 @@ type (
 		userService    service.UserService
 		tokenService   service.TokenService
 		contextTimeout time.Duration
+		// tokenCookie is the template of the cookie issued tokens are also set in, nil when disabled
+		tokenCookie *http.Cookie
+		// tokenLifetime is how long issued tokens stay valid, zero when unknown
+		tokenLifetime time.Duration
+		now           func() time.Time
 	}
 	//easyjson:json
 	UserLoginDto struct {
@@ -30,6 +38,16 @@ type (
 		Login    string `json:"login"`
 		Password string `json:"password"`
 	}
+	//easyjson:json
+	UserProfileDto struct {
+		Login string `json:"login"`
+	}
+	//easyjson:json
+	TokenDto struct {
+		Token string `json:"token"`
+		// ExpiresAt is left out when the token lifetime isn't configured
+		ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	}
 )
 
 func NewUserHandler(userService service.UserService, tokenService service.TokenService, contextTimeoutSec int) *UserHandler {
@@ -37,132 +55,391 @@ func NewUserHandler(userService service.UserService, tokenService service.TokenS
 		userService:    userService,
 		tokenService:   tokenService,
 		contextTimeout: time.Duration(contextTimeoutSec) * time.Second,
+		now:            time.Now,
 	}
 }
 
+// WithTokenLifetime sets how long issued tokens stay valid, reported as expires_at in JSON login responses.
+func (uh *UserHandler) WithTokenLifetime(lifetime time.Duration) *UserHandler {
+	uh.tokenLifetime = lifetime
+	return uh
+}
+
+// WithTokenCookie also sets issued tokens in an HttpOnly cookie with the given name, kept for maxAge.
+// secure limits the cookie to HTTPS. An empty name leaves the cookie off.
+func (uh *UserHandler) WithTokenCookie(name string, maxAge time.Duration, secure bool) *UserHandler {
+	if name == "" {
+		uh.tokenCookie = nil
+		return uh
+	}
+	uh.tokenCookie = &http.Cookie{
+		Name:     name,
+		Path:     "/",
+		MaxAge:   int(maxAge.Seconds()),
+		HttpOnly: true,
+		Secure:   secure,
+		SameSite: http.SameSiteLaxMode,
+	}
+	return uh
+}
+
 // Register godoc
 // @Summary User registration
 // @Description Registration is carried out using a login/password pair. Each login must be unique.
-// After successful registration, automatic user authentication should occur.
+// @Description After successful registration, automatic user authentication should occur.
+// @Description A registration retried with the same Idempotency-Key, login and password within 10 minutes
+// @Description gets a token of the registered user instead of a conflict.
+// @Description With AUTH_COOKIE_NAME set the token is also set in an HttpOnly cookie of that name.
 // @Tags user
 // @Accept json
 // @Produce json
 // @Param user body UserRegisterDto true "User Registration Information"
+// @Param Idempotency-Key header string false "Client chosen key of at most 128 characters that makes retries of the registration safe"
+// @Param X-Tenant-ID header string false "Tenant to register in, at most 64 characters, the default tenant when absent"
 // @Success 200 {string} string "Bearer <token>"
-// @Failure 400 {object} ErrorResponse "Bad Request"
+// @Failure 400 {object} ErrorResponse "Bad Request - Invalid tenant identifier or Idempotency-Key, unable to parse body or login and password are required"
+// @Failure 415 {object} ErrorResponse "Unsupported Media Type - The body is not JSON"
 // @Failure 500 {object} ErrorResponse "Internal Server Error"
 // @Router /api/user/register [post]
 func (uh *UserHandler) Register(w http.ResponseWriter, r *http.Request) {
-	ctx, cancel := context.WithTimeout(context.Background(), uh.contextTimeout)
+	ctx, cancel := context.WithTimeout(r.Context(), uh.contextTimeout)
 	defer cancel()
 
+	if err := requireJSON(r); err != nil {
+		PrepareError(w, r, err)
+		return
+	}
+	tenantID, err := requestTenant(r)
+	if err != nil {
+		PrepareError(w, r, err)
+		return
+	}
+	ctx = appContext.WithTenantID(ctx, tenantID)
+
 	body, err := io.ReadAll(r.Body)
 	if err != nil {
 		err = appErrors.NewWithCode(err, errMsgEnableReadBody, http.StatusBadRequest)
-		PrepareError(w, err)
+		PrepareError(w, r, err)
 		return
 	}
 	registerDto := UserRegisterDto{}
-	err = registerDto.UnmarshalJSON(body)
+	err = decodeStrict(body, (*strictUserRegisterDto)(&registerDto))
 	if err != nil {
-		err = appErrors.NewWithCode(err, "Unable to parse body", http.StatusBadRequest)
-		PrepareError(w, err)
+		PrepareError(w, r, err)
 		return
 	}
 
 	if registerDto.Login == "" || registerDto.Password == "" {
 		err = appErrors.NewWithCode(err, "Login and password are required", http.StatusBadRequest)
-		PrepareError(w, err)
+		PrepareError(w, r, err)
 		return
 	}
 
-	user, err := uh.userService.Create(ctx, registerDto.Login, registerDto.Password)
+	idempotencyKey, err := validateIdempotencyKey(r.Header.Get("Idempotency-Key"))
 	if err != nil {
-		PrepareError(w, err)
+		PrepareError(w, r, err)
+		return
+	}
+	var user *repository.User
+	if idempotencyKey != "" {
+		user, err = uh.userService.CreateIdempotent(ctx, registerDto.Login, registerDto.Password, idempotencyKey)
+	} else {
+		user, err = uh.userService.Create(ctx, registerDto.Login, registerDto.Password)
+	}
+	if err != nil {
+		PrepareError(w, r, err)
 		return
 	}
 
 	token, err := uh.generateToken(user)
 	if err != nil {
-		PrepareError(w, err)
+		PrepareError(w, r, err)
 		return
 	}
 
 	err = appContext.GetContextError(ctx)
 	if err != nil {
-		PrepareError(w, err)
+		PrepareError(w, r, err)
 		return
 	}
-	bearerToken := fmt.Sprintf("Bearer %s", token)
-	w.Header().Add("Authorization", bearerToken)
-	w.WriteHeader(http.StatusOK)
-	fmt.Fprintf(w, "%s", bearerToken)
+	uh.writeBearerToken(w, fmt.Sprintf("Bearer %s", token))
 }
 
 // Login godoc
 // @Summary User login
 // @Description Authenticates a user using a login/password pair and returns a bearer token if successful.
+// @Description With AUTH_COOKIE_NAME set the token is also set in an HttpOnly cookie of that name.
+// @Description The body is the plain "Bearer <token>" unless the request sends Accept: application/json,
+// @Description then it is a JSON object with the token and its expiry. The Authorization header is set either way.
 // @Tags users
 // @Accept json
-// @Produce json
+// @Produce plain,json
 // @Param user body UserLoginDto true "User Login Credentials"
-// @Success 200 {string} string "Bearer <token>"
-// @Failure 400 {object} ErrorResponse "Bad Request - Unable to read body or parse body or login and password are required"
+// @Param X-Tenant-ID header string false "Tenant of the user, at most 64 characters, the default tenant when absent"
+// @Success 200 {object} TokenDto "Bearer <token> as plain text, or the token and its expiry with Accept: application/json"
+// @Failure 400 {object} ErrorResponse "Bad Request - Invalid tenant identifier, unable to read body or parse body or login and password are required"
 // @Failure 401 {object} ErrorResponse "Unauthorized - Invalid login credentials"
+// @Failure 415 {object} ErrorResponse "Unsupported Media Type - The body is not JSON"
+// @Failure 429 {object} ErrorResponse "Too Many Requests - The login is locked after repeated failures, see Retry-After"
 // @Failure 500 {object} ErrorResponse "Internal Server Error - Unable to generate token"
 // @Router /api/user/login [post]
 func (uh *UserHandler) Login(w http.ResponseWriter, r *http.Request) {
-	ctx, cancel := context.WithTimeout(context.Background(), uh.contextTimeout)
+	ctx, cancel := context.WithTimeout(r.Context(), uh.contextTimeout)
 	defer cancel()
 
+	if err := requireJSON(r); err != nil {
+		PrepareError(w, r, err)
+		return
+	}
+	tenantID, err := requestTenant(r)
+	if err != nil {
+		PrepareError(w, r, err)
+		return
+	}
+	ctx = appContext.WithTenantID(ctx, tenantID)
+
 	body, err := io.ReadAll(r.Body)
 	if err != nil {
 		err = appErrors.NewWithCode(err, errMsgEnableReadBody, http.StatusBadRequest)
-		PrepareError(w, err)
+		PrepareError(w, r, err)
 		return
 	}
 
 	loginDto := UserLoginDto{}
-	err = loginDto.UnmarshalJSON(body)
+	err = decodeStrict(body, (*strictUserLoginDto)(&loginDto))
 	if err != nil {
-		err = appErrors.NewWithCode(err, "Unable to parse body", http.StatusBadRequest)
-		PrepareError(w, err)
+		PrepareError(w, r, err)
 		return
 	}
 
 	if loginDto.Login == "" || loginDto.Password == "" {
 		err = appErrors.NewWithCode(err, "Login and password are required", http.StatusBadRequest)
-		PrepareError(w, err)
+		PrepareError(w, r, err)
 		return
 	}
 
 	user, err := uh.userService.Authenticate(ctx, loginDto.Login, loginDto.Password)
 	if err != nil {
-		PrepareError(w, err)
+		var lockedErr *service.LoginLockedError
+		if errors.As(err, &lockedErr) {
+			w.Header().Set("Retry-After", strconv.Itoa(int(lockedErr.RetryAfter.Seconds())+1))
+		}
+		PrepareError(w, r, err)
 		return
 	}
 
 	token, err := uh.generateToken(user)
 	if err != nil {
-		PrepareError(w, err)
+		PrepareError(w, r, err)
 		return
 	}
 
 	err = appContext.GetContextError(ctx)
 	if err != nil {
-		PrepareError(w, err)
+		PrepareError(w, r, err)
 		return
 	}
-	bearerToken := fmt.Sprintf("Bearer %s", token)
-	w.Header().Add("Authorization", bearerToken)
-	w.WriteHeader(http.StatusOK)
-	fmt.Fprintf(w, "%s", bearerToken)
+	if acceptsJSON(r.Header.Get("Accept")) {
+		uh.writeTokenJSON(w, r, token)
+		return
+	}
+	uh.writeBearerToken(w, fmt.Sprintf("Bearer %s", token))
+}
+
+// UpdateProfile godoc
+// @Summary Update user profile
+// @Description Changes the login of the authenticated user. The new login must be unique.
+// @Description A token for the new login is returned, the presented token of the old login is revoked.
+// @Tags user
+// @Accept json
+// @Produce json
+// @Param profile body UserProfileDto true "New profile data"
+// @Success 200 {string} string "Bearer <token>"
+// @Failure 400 {object} ErrorResponse "Bad Request - Unable to read body or parse body or login is required"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 409 {object} ErrorResponse "Conflict - Login already taken"
+// @Failure 500 {object} ErrorResponse "Internal Server Error - Unable to update login, generate or revoke token"
+// @Router /api/user/profile [patch]
+func (uh *UserHandler) UpdateProfile(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), uh.contextTimeout)
+	defer cancel()
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		err = appErrors.NewWithCode(err, errMsgEnableReadBody, http.StatusBadRequest)
+		PrepareError(w, r, err)
+		return
+	}
+	profileDto := UserProfileDto{}
+	err = decodeStrict(body, (*strictUserProfileDto)(&profileDto))
+	if err != nil {
+		PrepareError(w, r, err)
+		return
+	}
+	if profileDto.Login == "" {
+		err = appErrors.NewWithCode(errors.New("empty login"), "Login is required", http.StatusBadRequest)
+		PrepareError(w, r, err)
+		return
+	}
+
+	err = appContext.GetContextError(ctx)
+	if err != nil {
+		PrepareError(w, r, err)
+		return
+	}
+	// the login from the token saves looking the user up again, an unchanged login only gets a new token
+	renamed := profileDto.Login != appContext.UserLogin(r.Context())
+	if renamed {
+		err = uh.userService.UpdateLogin(ctx, appContext.UserUID(r.Context()), profileDto.Login)
+		if err != nil {
+			PrepareError(w, r, err)
+			return
+		}
+	}
+
+	tenantID, _ := appContext.TenantID(r.Context())
+	token, err := uh.generateToken(&repository.User{Login: profileDto.Login, TenantID: tenantID})
+	if err != nil {
+		PrepareError(w, r, err)
+		return
+	}
+	// the old login is free again, a user registering it must not be reachable with the presented token
+	if oldToken := uh.presentedToken(r); renamed && oldToken != "" {
+		if err = uh.tokenService.RevokeToken(oldToken); err != nil {
+			PrepareError(w, r, appErrors.NewWithCode(err, "Unable to revoke token", http.StatusInternalServerError))
+			return
+		}
+	}
+	uh.writeBearerToken(w, fmt.Sprintf("Bearer %s", token))
+}
+
+// presentedToken returns the token the request was authenticated with, read like the auth middleware does:
+// from the Authorization header, or from the token cookie when the header is absent.
+func (uh *UserHandler) presentedToken(r *http.Request) string {
+	authHeader := r.Header.Get("Authorization")
+	if authHeader == "" && uh.tokenCookie != nil {
+		if cookie, err := r.Cookie(uh.tokenCookie.Name); err == nil {
+			return cookie.Value
+		}
+	}
+	token, _ := BearerToken(authHeader)
+	return token
 }
 
 func (uh *UserHandler) generateToken(user *repository.User) (string, error) {
-	token, err := uh.tokenService.GenerateToken(user.Login)
+	token, err := uh.tokenService.GenerateTenantToken(user.TenantID, user.Login)
 	if err != nil {
 		return "", appErrors.NewWithCode(err, "Unable to generate token", http.StatusInternalServerError)
 	}
 	return token, nil
 }
+
+// RenewToken godoc
+// @Summary Renew the auth token
+// @Description Issues a fresh token for a still valid one, e.g. shortly before it expires, without asking for the password.
+// @Description With revoke_old=true the presented token stops working. Expired tokens can't be renewed, log in again instead.
+// @Tags user
+// @Produce plain
+// @Param Authorization header string true "Bearer <token>"
+// @Param revoke_old query bool false "Revoke the presented token"
+// @Success 200 {string} string "Bearer <token>"
+// @Failure 400 {object} ErrorResponse "Bad Request - Invalid revoke_old parameter"
+// @Failure 401 {object} ErrorResponse "Unauthorized - Missing, invalid, expired or revoked token"
+// @Failure 500 {object} ErrorResponse "Internal Server Error - Unable to generate or revoke token"
+// @Router /api/user/token/renew [post]
+func (uh *UserHandler) RenewToken(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), uh.contextTimeout)
+	defer cancel()
+
+	token, ok := BearerToken(r.Header.Get("Authorization"))
+	if !ok || token == "" {
+		msg := "Unauthorized: Missing bearer token"
+		PrepareError(w, r, appErrors.NewWithCode(errors.New(msg), msg, http.StatusUnauthorized))
+		return
+	}
+	revokeOld := false
+	if raw := r.URL.Query().Get("revoke_old"); raw != "" {
+		var err error
+		if revokeOld, err = strconv.ParseBool(raw); err != nil {
+			PrepareError(w, r, appErrors.NewWithCode(err, "Invalid revoke_old parameter", http.StatusBadRequest))
+			return
+		}
+	}
+
+	tenantID, login, err := uh.tokenService.GetTenantLogin(token)
+	if err != nil {
+		PrepareError(w, r, appErrors.NewWithCode(err, "Unauthorized: Invalid token", http.StatusUnauthorized))
+		return
+	}
+	ctx = appContext.WithTenantID(ctx, tenantID)
+	// a renamed or deleted user must log in again
+	user, err := uh.userService.GetByUserLogin(ctx, login)
+	if err != nil {
+		PrepareError(w, r, appErrors.NewWithCode(err, "Unauthorized: User not found", http.StatusUnauthorized))
+		return
+	}
+
+	newToken, err := uh.generateToken(user)
+	if err != nil {
+		PrepareError(w, r, err)
+		return
+	}
+	if revokeOld {
+		if err = uh.tokenService.RevokeToken(token); err != nil {
+			PrepareError(w, r, appErrors.NewWithCode(err, "Unable to revoke token", http.StatusInternalServerError))
+			return
+		}
+	}
+
+	err = appContext.GetContextError(ctx)
+	if err != nil {
+		PrepareError(w, r, err)
+		return
+	}
+	uh.writeBearerToken(w, fmt.Sprintf("Bearer %s", newToken))
+}
+
+// BearerToken extracts the token from a "Bearer <token>" header, matching the scheme case-insensitively.
+// It reports false when the header uses another scheme.
+func BearerToken(authHeader string) (string, bool) {
+	scheme, token, _ := strings.Cut(strings.TrimSpace(authHeader), " ")
+	if !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	return strings.TrimSpace(token), true
+}
+
+func (uh *UserHandler) writeBearerToken(w http.ResponseWriter, bearerToken string) {
+	uh.setBearerToken(w, bearerToken)
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "%s", bearerToken)
+}
+
+// writeTokenJSON answers with the token and its expiry as JSON, keeping the Authorization header and cookie.
+func (uh *UserHandler) writeTokenJSON(w http.ResponseWriter, r *http.Request, token string) {
+	response := TokenDto{Token: token}
+	if uh.tokenLifetime > 0 {
+		expiresAt := uh.now().Add(uh.tokenLifetime).UTC().Truncate(time.Second)
+		response.ExpiresAt = &expiresAt
+	}
+	rawBytes, err := response.MarshalJSON()
+	if err != nil {
+		PrepareError(w, r, fmt.Errorf("unable to marshal response: %w", err))
+		return
+	}
+
+	uh.setBearerToken(w, fmt.Sprintf("Bearer %s", token))
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(rawBytes)
+}
+
+// setBearerToken sets the token in the Authorization header and, when enabled, the token cookie.
+func (uh *UserHandler) setBearerToken(w http.ResponseWriter, bearerToken string) {
+	w.Header().Add("Authorization", bearerToken)
+	if uh.tokenCookie != nil {
+		cookie := *uh.tokenCookie
+		cookie.Value, _ = BearerToken(bearerToken)
+		http.SetCookie(w, &cookie)
+	}
+}
@@ -7,6 +7,7 @@ import (
 	easyjson "github.com/mailru/easyjson"
 	jlexer "github.com/mailru/easyjson/jlexer"
 	jwriter "github.com/mailru/easyjson/jwriter"
+	time "time"
 )
 
 // suppress unused package warning
@@ -90,7 +91,73 @@ func (v *UserRegisterDto) UnmarshalJSON(data []byte) error {
 func (v *UserRegisterDto) UnmarshalEasyJSON(l *jlexer.Lexer) {
 	easyjson2b7a6f05DecodeGithubComUjweghGophermartInternalAppHandlers(l, v)
 }
-func easyjson2b7a6f05DecodeGithubComUjweghGophermartInternalAppHandlers1(in *jlexer.Lexer, out *UserLoginDto) {
+func easyjson2b7a6f05DecodeGithubComUjweghGophermartInternalAppHandlers1(in *jlexer.Lexer, out *UserProfileDto) {
+	isTopLevel := in.IsStart()
+	if in.IsNull() {
+		if isTopLevel {
+			in.Consumed()
+		}
+		in.Skip()
+		return
+	}
+	in.Delim('{')
+	for !in.IsDelim('}') {
+		key := in.UnsafeFieldName(false)
+		in.WantColon()
+		if in.IsNull() {
+			in.Skip()
+			in.WantComma()
+			continue
+		}
+		switch key {
+		case "login":
+			out.Login = string(in.String())
+		default:
+			in.SkipRecursive()
+		}
+		in.WantComma()
+	}
+	in.Delim('}')
+	if isTopLevel {
+		in.Consumed()
+	}
+}
+func easyjson2b7a6f05EncodeGithubComUjweghGophermartInternalAppHandlers1(out *jwriter.Writer, in UserProfileDto) {
+	out.RawByte('{')
+	first := true
+	_ = first
+	{
+		const prefix string = ",\"login\":"
+		out.RawString(prefix[1:])
+		out.String(string(in.Login))
+	}
+	out.RawByte('}')
+}
+
+// MarshalJSON supports json.Marshaler interface
+func (v UserProfileDto) MarshalJSON() ([]byte, error) {
+	w := jwriter.Writer{}
+	easyjson2b7a6f05EncodeGithubComUjweghGophermartInternalAppHandlers1(&w, v)
+	return w.Buffer.BuildBytes(), w.Error
+}
+
+// MarshalEasyJSON supports easyjson.Marshaler interface
+func (v UserProfileDto) MarshalEasyJSON(w *jwriter.Writer) {
+	easyjson2b7a6f05EncodeGithubComUjweghGophermartInternalAppHandlers1(w, v)
+}
+
+// UnmarshalJSON supports json.Unmarshaler interface
+func (v *UserProfileDto) UnmarshalJSON(data []byte) error {
+	r := jlexer.Lexer{Data: data}
+	easyjson2b7a6f05DecodeGithubComUjweghGophermartInternalAppHandlers1(&r, v)
+	return r.Error()
+}
+
+// UnmarshalEasyJSON supports easyjson.Unmarshaler interface
+func (v *UserProfileDto) UnmarshalEasyJSON(l *jlexer.Lexer) {
+	easyjson2b7a6f05DecodeGithubComUjweghGophermartInternalAppHandlers1(l, v)
+}
+func easyjson2b7a6f05DecodeGithubComUjweghGophermartInternalAppHandlers2(in *jlexer.Lexer, out *UserLoginDto) {
 	isTopLevel := in.IsStart()
 	if in.IsNull() {
 		if isTopLevel {
@@ -123,7 +190,7 @@ func easyjson2b7a6f05DecodeGithubComUjweghGophermartInternalAppHandlers1(in *jle
 		in.Consumed()
 	}
 }
-func easyjson2b7a6f05EncodeGithubComUjweghGophermartInternalAppHandlers1(out *jwriter.Writer, in UserLoginDto) {
+func easyjson2b7a6f05EncodeGithubComUjweghGophermartInternalAppHandlers2(out *jwriter.Writer, in UserLoginDto) {
 	out.RawByte('{')
 	first := true
 	_ = first
@@ -143,23 +210,106 @@ func easyjson2b7a6f05EncodeGithubComUjweghGophermartInternalAppHandlers1(out *jw
 // MarshalJSON supports json.Marshaler interface
 func (v UserLoginDto) MarshalJSON() ([]byte, error) {
 	w := jwriter.Writer{}
-	easyjson2b7a6f05EncodeGithubComUjweghGophermartInternalAppHandlers1(&w, v)
+	easyjson2b7a6f05EncodeGithubComUjweghGophermartInternalAppHandlers2(&w, v)
 	return w.Buffer.BuildBytes(), w.Error
 }
 
 // MarshalEasyJSON supports easyjson.Marshaler interface
 func (v UserLoginDto) MarshalEasyJSON(w *jwriter.Writer) {
-	easyjson2b7a6f05EncodeGithubComUjweghGophermartInternalAppHandlers1(w, v)
+	easyjson2b7a6f05EncodeGithubComUjweghGophermartInternalAppHandlers2(w, v)
 }
 
 // UnmarshalJSON supports json.Unmarshaler interface
 func (v *UserLoginDto) UnmarshalJSON(data []byte) error {
 	r := jlexer.Lexer{Data: data}
-	easyjson2b7a6f05DecodeGithubComUjweghGophermartInternalAppHandlers1(&r, v)
+	easyjson2b7a6f05DecodeGithubComUjweghGophermartInternalAppHandlers2(&r, v)
 	return r.Error()
 }
 
 // UnmarshalEasyJSON supports easyjson.Unmarshaler interface
 func (v *UserLoginDto) UnmarshalEasyJSON(l *jlexer.Lexer) {
-	easyjson2b7a6f05DecodeGithubComUjweghGophermartInternalAppHandlers1(l, v)
+	easyjson2b7a6f05DecodeGithubComUjweghGophermartInternalAppHandlers2(l, v)
+}
+func easyjson2b7a6f05DecodeGithubComUjweghGophermartInternalAppHandlers3(in *jlexer.Lexer, out *TokenDto) {
+	isTopLevel := in.IsStart()
+	if in.IsNull() {
+		if isTopLevel {
+			in.Consumed()
+		}
+		in.Skip()
+		return
+	}
+	in.Delim('{')
+	for !in.IsDelim('}') {
+		key := in.UnsafeFieldName(false)
+		in.WantColon()
+		if in.IsNull() {
+			in.Skip()
+			in.WantComma()
+			continue
+		}
+		switch key {
+		case "token":
+			out.Token = string(in.String())
+		case "expires_at":
+			if in.IsNull() {
+				in.Skip()
+				out.ExpiresAt = nil
+			} else {
+				if out.ExpiresAt == nil {
+					out.ExpiresAt = new(time.Time)
+				}
+				if data := in.Raw(); in.Ok() {
+					in.AddError((*out.ExpiresAt).UnmarshalJSON(data))
+				}
+			}
+		default:
+			in.SkipRecursive()
+		}
+		in.WantComma()
+	}
+	in.Delim('}')
+	if isTopLevel {
+		in.Consumed()
+	}
+}
+func easyjson2b7a6f05EncodeGithubComUjweghGophermartInternalAppHandlers3(out *jwriter.Writer, in TokenDto) {
+	out.RawByte('{')
+	first := true
+	_ = first
+	{
+		const prefix string = ",\"token\":"
+		out.RawString(prefix[1:])
+		out.String(string(in.Token))
+	}
+	if in.ExpiresAt != nil {
+		const prefix string = ",\"expires_at\":"
+		out.RawString(prefix)
+		out.Raw((*in.ExpiresAt).MarshalJSON())
+	}
+	out.RawByte('}')
+}
+
+// MarshalJSON supports json.Marshaler interface
+func (v TokenDto) MarshalJSON() ([]byte, error) {
+	w := jwriter.Writer{}
+	easyjson2b7a6f05EncodeGithubComUjweghGophermartInternalAppHandlers3(&w, v)
+	return w.Buffer.BuildBytes(), w.Error
+}
+
+// MarshalEasyJSON supports easyjson.Marshaler interface
+func (v TokenDto) MarshalEasyJSON(w *jwriter.Writer) {
+	easyjson2b7a6f05EncodeGithubComUjweghGophermartInternalAppHandlers3(w, v)
+}
+
+// UnmarshalJSON supports json.Unmarshaler interface
+func (v *TokenDto) UnmarshalJSON(data []byte) error {
+	r := jlexer.Lexer{Data: data}
+	easyjson2b7a6f05DecodeGithubComUjweghGophermartInternalAppHandlers3(&r, v)
+	return r.Error()
+}
+
+// UnmarshalEasyJSON supports easyjson.Unmarshaler interface
+func (v *TokenDto) UnmarshalEasyJSON(l *jlexer.Lexer) {
+	easyjson2b7a6f05DecodeGithubComUjweghGophermartInternalAppHandlers3(l, v)
 }
@@ -3,11 +3,16 @@ package handlers
 import (
 	"context"
 	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt/v4"
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	appContext "github.com/ujwegh/gophermart/internal/app/context"
 	appErrors "github.com/ujwegh/gophermart/internal/app/errors"
 	"github.com/ujwegh/gophermart/internal/app/repository"
+	"github.com/ujwegh/gophermart/internal/app/service"
 	"net/http"
 	"net/http/httptest"
 	"strings"
@@ -27,6 +32,11 @@ func (m *MockUserService) Create(ctx context.Context, login, password string) (*
 	return args.Get(0).(*repository.User), args.Error(1)
 }
 
+func (m *MockUserService) CreateIdempotent(ctx context.Context, login, password, idempotencyKey string) (*repository.User, error) {
+	args := m.Called(ctx, login, password, idempotencyKey)
+	return args.Get(0).(*repository.User), args.Error(1)
+}
+
 func (m *MockUserService) GetByUserLogin(ctx context.Context, login string) (*repository.User, error) {
 	args := m.Called(ctx, login)
 	return args.Get(0).(*repository.User), args.Error(1)
@@ -37,26 +47,49 @@ func (m *MockUserService) Authenticate(ctx context.Context, login, password stri
 	return args.Get(0).(*repository.User), args.Error(1)
 }
 
+func (m *MockUserService) UpdateLogin(ctx context.Context, userUID *uuid.UUID, newLogin string) error {
+	args := m.Called(ctx, userUID, newLogin)
+	return args.Error(0)
+}
+
 func (m *MockTokenService) GetUserLogin(tokenString string) (string, error) {
 	args := m.Called(tokenString)
 	return args.String(0), args.Error(1)
 }
 
+func (m *MockTokenService) GetTenantLogin(tokenString string) (string, string, error) {
+	args := m.Called(tokenString)
+	return args.String(0), args.String(1), args.Error(2)
+}
+
 func (m *MockTokenService) GenerateToken(login string) (string, error) {
 	args := m.Called(login)
 	return args.String(0), args.Error(1)
 }
 
+func (m *MockTokenService) GenerateTenantToken(tenantID, login string) (string, error) {
+	args := m.Called(tenantID, login)
+	return args.String(0), args.Error(1)
+}
+
+func (m *MockTokenService) RevokeToken(tokenString string) error {
+	args := m.Called(tokenString)
+	return args.Error(0)
+}
+
 func TestUserHandler_Login(t *testing.T) {
 	tests := []struct {
 		name             string
 		request          string
+		contentType      string
+		noContentType    bool
 		mockUserService  func() *MockUserService
 		mockTokenService func() *MockTokenService
 		contextTimeout   time.Duration
 		wantErr          bool
 		wantResponse     string
 		wantStatusCode   int
+		wantRetryAfter   string
 	}{
 		{
 			name:    "Successful Login",
@@ -74,7 +107,7 @@ func TestUserHandler_Login(t *testing.T) {
 			},
 			mockTokenService: func() *MockTokenService {
 				m := &MockTokenService{}
-				m.On("GenerateToken", "testuser").Return("secret-token", nil)
+				m.On("GenerateTenantToken", "", "testuser").Return("secret-token", nil)
 				return m
 			},
 			contextTimeout: 5 * time.Second,
@@ -100,6 +133,24 @@ func TestUserHandler_Login(t *testing.T) {
 			wantResponse:   "{\"code\":401,\"message\":\"Invalid password\"}\n",
 			wantStatusCode: http.StatusUnauthorized,
 		},
+		{
+			name:    "Login Locked",
+			request: `{"login":"testuser","password":"password"}`,
+			mockUserService: func() *MockUserService {
+				m := &MockUserService{}
+				err := &service.LoginLockedError{RetryAfter: 90 * time.Second}
+				m.On("Authenticate", mock.Anything, "testuser", "password").Return((*repository.User)(nil), err)
+				return m
+			},
+			mockTokenService: func() *MockTokenService {
+				return &MockTokenService{}
+			},
+			contextTimeout: 5 * time.Second,
+			wantErr:        true,
+			wantResponse:   "{\"code\":429,\"message\":\"Too many failed login attempts\"}\n",
+			wantStatusCode: http.StatusTooManyRequests,
+			wantRetryAfter: "91",
+		},
 		{
 			name:    "Invalid Login Format",
 			request: `{"login":"","password":"password"}`,
@@ -131,7 +182,7 @@ func TestUserHandler_Login(t *testing.T) {
 			},
 			mockTokenService: func() *MockTokenService {
 				m := &MockTokenService{}
-				m.On("GenerateToken", "testuser").Return("", errors.New("token generation error"))
+				m.On("GenerateTenantToken", "", "testuser").Return("", errors.New("token generation error"))
 				return m
 			},
 			contextTimeout: 5 * time.Second,
@@ -155,7 +206,7 @@ func TestUserHandler_Login(t *testing.T) {
 			},
 			mockTokenService: func() *MockTokenService {
 				m := &MockTokenService{}
-				m.On("GenerateToken", "testuser").Return("secret-token", nil)
+				m.On("GenerateTenantToken", "", "testuser").Return("secret-token", nil)
 				return m
 			},
 			contextTimeout: 0 * time.Second,
@@ -177,6 +228,48 @@ func TestUserHandler_Login(t *testing.T) {
 			wantResponse:   "{\"code\":400,\"message\":\"Unable to parse body\"}\n",
 			wantStatusCode: http.StatusBadRequest,
 		},
+		{
+			name:             "Unknown Field",
+			request:          `{"login":"testuser","password":"password","remember":true}`,
+			mockUserService:  func() *MockUserService { return &MockUserService{} },
+			mockTokenService: func() *MockTokenService { return &MockTokenService{} },
+			contextTimeout:   5 * time.Second,
+			wantErr:          true,
+			wantResponse:     `{"code":400,"message":"Unknown field \"remember\""}`,
+			wantStatusCode:   http.StatusBadRequest,
+		},
+		{
+			name:             "Password Of The Wrong Type",
+			request:          `{"login":"testuser","password":12345}`,
+			mockUserService:  func() *MockUserService { return &MockUserService{} },
+			mockTokenService: func() *MockTokenService { return &MockTokenService{} },
+			contextTimeout:   5 * time.Second,
+			wantErr:          true,
+			wantResponse:     `{"code":400,"message":"Invalid type of field \"password\": got number"}`,
+			wantStatusCode:   http.StatusBadRequest,
+		},
+		{
+			name:             "Not A JSON Content Type",
+			request:          `{"login":"testuser","password":"password"}`,
+			contentType:      "text/plain",
+			mockUserService:  func() *MockUserService { return &MockUserService{} },
+			mockTokenService: func() *MockTokenService { return &MockTokenService{} },
+			contextTimeout:   5 * time.Second,
+			wantErr:          true,
+			wantResponse:     `{"code":415,"message":"Content-Type must be application/json"}`,
+			wantStatusCode:   http.StatusUnsupportedMediaType,
+		},
+		{
+			name:             "Missing Content Type",
+			request:          `{"login":"testuser","password":"password"}`,
+			noContentType:    true,
+			mockUserService:  func() *MockUserService { return &MockUserService{} },
+			mockTokenService: func() *MockTokenService { return &MockTokenService{} },
+			contextTimeout:   5 * time.Second,
+			wantErr:          true,
+			wantResponse:     `{"code":415,"message":"Content-Type must be application/json"}`,
+			wantStatusCode:   http.StatusUnsupportedMediaType,
+		},
 	}
 
 	for _, tt := range tests {
@@ -185,6 +278,12 @@ func TestUserHandler_Login(t *testing.T) {
 			body := strings.NewReader(tt.request)
 			req, err := http.NewRequest("POST", "/api/user/login", body)
 			assert.NoError(t, err)
+			if !tt.noContentType {
+				req.Header.Set("Content-Type", "application/json")
+			}
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
 			w := httptest.NewRecorder()
 
 			// Create UserHandler with mocked services
@@ -198,6 +297,7 @@ func TestUserHandler_Login(t *testing.T) {
 			uh.Login(w, req)
 
 			assert.Equal(t, tt.wantStatusCode, w.Code)
+			assert.Equal(t, tt.wantRetryAfter, w.Header().Get("Retry-After"))
 
 			if tt.wantErr {
 				assert.JSONEq(t, tt.wantResponse, w.Body.String())
@@ -213,6 +313,8 @@ func TestUserHandler_Register(t *testing.T) {
 	tests := []struct {
 		name             string
 		request          string
+		contentType      string
+		noContentType    bool
 		mockUserService  func() *MockUserService
 		mockTokenService func() *MockTokenService
 		contextTimeout   time.Duration
@@ -235,7 +337,7 @@ func TestUserHandler_Register(t *testing.T) {
 			},
 			mockTokenService: func() *MockTokenService {
 				m := &MockTokenService{}
-				m.On("GenerateToken", "newuser").Return("secret-token", nil)
+				m.On("GenerateTenantToken", "", "newuser").Return("secret-token", nil)
 				return m
 			},
 			contextTimeout: 5 * time.Second,
@@ -254,7 +356,7 @@ func TestUserHandler_Register(t *testing.T) {
 			},
 			mockTokenService: func() *MockTokenService {
 				m := &MockTokenService{}
-				m.On("GenerateToken", "newuser").Return("secret-token", nil)
+				m.On("GenerateTenantToken", "", "newuser").Return("secret-token", nil)
 				return m
 			},
 			contextTimeout: 5 * time.Second,
@@ -262,6 +364,36 @@ func TestUserHandler_Register(t *testing.T) {
 			wantResponse:   "{\"code\":400,\"message\":\"Login and password are required\"}\n",
 			wantStatusCode: http.StatusBadRequest,
 		},
+		{
+			name:             "Unknown Field",
+			request:          `{"login":"newuser","password":"newpassword","email":"new@example.com"}`,
+			mockUserService:  func() *MockUserService { return &MockUserService{} },
+			mockTokenService: func() *MockTokenService { return &MockTokenService{} },
+			contextTimeout:   5 * time.Second,
+			wantErr:          true,
+			wantResponse:     `{"code":400,"message":"Unknown field \"email\""}`,
+			wantStatusCode:   http.StatusBadRequest,
+		},
+		{
+			name:             "Login Of The Wrong Type",
+			request:          `{"login":["newuser"],"password":"newpassword"}`,
+			mockUserService:  func() *MockUserService { return &MockUserService{} },
+			mockTokenService: func() *MockTokenService { return &MockTokenService{} },
+			contextTimeout:   5 * time.Second,
+			wantErr:          true,
+			wantResponse:     `{"code":400,"message":"Invalid type of field \"login\": got array"}`,
+			wantStatusCode:   http.StatusBadRequest,
+		},
+		{
+			name:             "Body Is Not An Object",
+			request:          `"newuser"`,
+			mockUserService:  func() *MockUserService { return &MockUserService{} },
+			mockTokenService: func() *MockTokenService { return &MockTokenService{} },
+			contextTimeout:   5 * time.Second,
+			wantErr:          true,
+			wantResponse:     `{"code":400,"message":"Body must be a JSON object"}`,
+			wantStatusCode:   http.StatusBadRequest,
+		},
 		{
 			name:    "Error in User Creation",
 			request: `{"login":"newuser","password":"newpassword"}`,
@@ -290,7 +422,7 @@ func TestUserHandler_Register(t *testing.T) {
 			},
 			mockTokenService: func() *MockTokenService {
 				m := &MockTokenService{}
-				m.On("GenerateToken", "newuser").Return("", errors.New("token generation error"))
+				m.On("GenerateTenantToken", "", "newuser").Return("", errors.New("token generation error"))
 				return m
 			},
 			contextTimeout: 5 * time.Second,
@@ -309,7 +441,7 @@ func TestUserHandler_Register(t *testing.T) {
 			},
 			mockTokenService: func() *MockTokenService {
 				m := &MockTokenService{}
-				m.On("GenerateToken", "newuser").Return("secret-token", nil)
+				m.On("GenerateTenantToken", "", "newuser").Return("secret-token", nil)
 				return m
 			},
 			contextTimeout: 0 * time.Second,
@@ -332,6 +464,28 @@ func TestUserHandler_Register(t *testing.T) {
 			wantStatusCode: http.StatusBadRequest,
 		},
 		// Add more test cases as needed
+		{
+			name:             "Not A JSON Content Type",
+			request:          `{"login":"newuser","password":"password"}`,
+			contentType:      "text/plain",
+			mockUserService:  func() *MockUserService { return &MockUserService{} },
+			mockTokenService: func() *MockTokenService { return &MockTokenService{} },
+			contextTimeout:   5 * time.Second,
+			wantErr:          true,
+			wantResponse:     `{"code":415,"message":"Content-Type must be application/json"}`,
+			wantStatusCode:   http.StatusUnsupportedMediaType,
+		},
+		{
+			name:             "Missing Content Type",
+			request:          `{"login":"newuser","password":"newpassword"}`,
+			noContentType:    true,
+			mockUserService:  func() *MockUserService { return &MockUserService{} },
+			mockTokenService: func() *MockTokenService { return &MockTokenService{} },
+			contextTimeout:   5 * time.Second,
+			wantErr:          true,
+			wantResponse:     `{"code":415,"message":"Content-Type must be application/json"}`,
+			wantStatusCode:   http.StatusUnsupportedMediaType,
+		},
 	}
 
 	for _, tt := range tests {
@@ -340,6 +494,12 @@ func TestUserHandler_Register(t *testing.T) {
 			body := strings.NewReader(tt.request)
 			req, err := http.NewRequest("POST", "/api/user/register", body)
 			assert.NoError(t, err)
+			if !tt.noContentType {
+				req.Header.Set("Content-Type", "application/json")
+			}
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
 			w := httptest.NewRecorder()
 
 			// Create UserHandler with mocked services
@@ -362,3 +522,409 @@ func TestUserHandler_Register(t *testing.T) {
 		})
 	}
 }
+
+func TestUserHandler_Register_Idempotent(t *testing.T) {
+	user := &repository.User{UUID: uuid.New(), Login: "newuser", PasswordHash: "passwordhash", CreatedAt: time.Now()}
+	conflict := appErrors.NewWithCode(errors.New("duplicate"), "User already exists", http.StatusConflict)
+	us := &MockUserService{}
+	// the service tells a retry of the registration from another one by the stored key and password
+	us.On("CreateIdempotent", mock.Anything, "newuser", "newpassword", "key-1").Return(user, nil)
+	us.On("CreateIdempotent", mock.Anything, "newuser", "newpassword", "key-2").Return((*repository.User)(nil), conflict)
+	us.On("Create", mock.Anything, "newuser", "newpassword").Return((*repository.User)(nil), conflict)
+	ts := &MockTokenService{}
+	ts.On("GenerateTenantToken", "", "newuser").Return("secret-token", nil)
+	uh := &UserHandler{
+		userService:    us,
+		tokenService:   ts,
+		contextTimeout: 5 * time.Second,
+	}
+	register := func(idempotencyKey string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/api/user/register", strings.NewReader(`{"login":"newuser","password":"newpassword"}`))
+		req.Header.Set("Content-Type", "application/json")
+		if idempotencyKey != "" {
+			req.Header.Set("Idempotency-Key", idempotencyKey)
+		}
+		w := httptest.NewRecorder()
+		uh.Register(w, req)
+		return w
+	}
+
+	w := register("key-1")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "Bearer secret-token", w.Body.String())
+
+	w = register("key-1")
+	assert.Equal(t, http.StatusOK, w.Code, "Retry with the same key should succeed again")
+	assert.Equal(t, "Bearer secret-token", w.Body.String())
+	assert.Equal(t, "Bearer secret-token", w.Header().Get("Authorization"))
+
+	w = register("key-2")
+	assert.Equal(t, http.StatusConflict, w.Code, "Another key must not replay the registration")
+
+	w = register("")
+	assert.Equal(t, http.StatusConflict, w.Code, "Requests without a key must not replay the registration")
+	us.AssertNumberOfCalls(t, "CreateIdempotent", 3)
+	us.AssertNumberOfCalls(t, "Create", 1)
+}
+
+func TestUserHandler_UpdateProfile(t *testing.T) {
+	userUID := uuid.New()
+	tests := []struct {
+		name            string
+		request         string
+		mockUserService func() *MockUserService
+		wantResponse    string
+		wantStatusCode  int
+		wantRevoked     bool
+	}{
+		{
+			name:    "Successful Update",
+			request: `{"login":"renamed"}`,
+			mockUserService: func() *MockUserService {
+				m := &MockUserService{}
+				m.On("UpdateLogin", mock.Anything, &userUID, "renamed").Return(nil)
+				return m
+			},
+			wantResponse:   "Bearer renamed-token",
+			wantStatusCode: http.StatusOK,
+			wantRevoked:    true,
+		},
+		{
+			name:            "Unchanged Login",
+			request:         `{"login":"current"}`,
+			mockUserService: func() *MockUserService { return &MockUserService{} },
+			wantResponse:    "Bearer current-token",
+			wantStatusCode:  http.StatusOK,
+		},
+		{
+			name:    "Login Taken",
+			request: `{"login":"taken"}`,
+			mockUserService: func() *MockUserService {
+				m := &MockUserService{}
+				conflict := appErrors.NewWithCode(errors.New("taken"), "Login already taken", http.StatusConflict)
+				m.On("UpdateLogin", mock.Anything, &userUID, "taken").Return(conflict)
+				return m
+			},
+			wantResponse:   `{"code":409,"message":"Login already taken"}`,
+			wantStatusCode: http.StatusConflict,
+		},
+		{
+			name:            "Empty Login",
+			request:         `{"login":""}`,
+			mockUserService: func() *MockUserService { return &MockUserService{} },
+			wantResponse:    `{"code":400,"message":"Login is required"}`,
+			wantStatusCode:  http.StatusBadRequest,
+		},
+		{
+			name:            "Unknown Field",
+			request:         `{"login":"renamed","password":"secret"}`,
+			mockUserService: func() *MockUserService { return &MockUserService{} },
+			wantResponse:    `{"code":400,"message":"Unknown field \"password\""}`,
+			wantStatusCode:  http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			us := tt.mockUserService()
+			ts := &MockTokenService{}
+			ts.On("GenerateTenantToken", "", "renamed").Return("renamed-token", nil)
+			ts.On("GenerateTenantToken", "", "current").Return("current-token", nil)
+			ts.On("RevokeToken", "old-token").Return(nil)
+			uh := &UserHandler{
+				userService:    us,
+				tokenService:   ts,
+				contextTimeout: 5 * time.Second,
+			}
+			req := httptest.NewRequest("PATCH", "/api/user/profile", strings.NewReader(tt.request))
+			req.Header.Set("Authorization", "Bearer old-token")
+			req = req.WithContext(appContext.WithUserLogin(appContext.WithUserUID(req.Context(), &userUID), "current"))
+			w := httptest.NewRecorder()
+
+			uh.UpdateProfile(w, req)
+
+			assert.Equal(t, tt.wantStatusCode, w.Code)
+			if tt.wantStatusCode == http.StatusOK {
+				assert.Equal(t, tt.wantResponse, w.Body.String())
+				assert.Equal(t, tt.wantResponse, w.Header().Get("Authorization"))
+			} else {
+				assert.JSONEq(t, tt.wantResponse, w.Body.String())
+			}
+			us.AssertExpectations(t)
+			if tt.wantRevoked {
+				ts.AssertCalled(t, "RevokeToken", "old-token")
+			} else {
+				ts.AssertNotCalled(t, "RevokeToken", mock.Anything)
+			}
+		})
+	}
+}
+
+func TestUserHandler_RenewToken(t *testing.T) {
+	user := &repository.User{UUID: uuid.New(), Login: "testuser"}
+	expiredErr := fmt.Errorf("token error: failed to parse token: %w by 1m0s", jwt.ErrTokenExpired)
+	tests := []struct {
+		name             string
+		authHeader       string
+		query            string
+		mockUserService  func() *MockUserService
+		mockTokenService func() *MockTokenService
+		wantStatusCode   int
+		wantResponse     string
+	}{
+		{
+			name:       "Valid Token",
+			authHeader: "Bearer old-token",
+			mockUserService: func() *MockUserService {
+				m := &MockUserService{}
+				m.On("GetByUserLogin", mock.Anything, "testuser").Return(user, nil)
+				return m
+			},
+			mockTokenService: func() *MockTokenService {
+				m := &MockTokenService{}
+				m.On("GetTenantLogin", "old-token").Return("", "testuser", nil)
+				m.On("GenerateTenantToken", "", "testuser").Return("new-token", nil)
+				return m
+			},
+			wantStatusCode: http.StatusOK,
+			wantResponse:   "Bearer new-token",
+		},
+		{
+			name:       "Valid Token Revoked",
+			authHeader: "Bearer old-token",
+			query:      "?revoke_old=true",
+			mockUserService: func() *MockUserService {
+				m := &MockUserService{}
+				m.On("GetByUserLogin", mock.Anything, "testuser").Return(user, nil)
+				return m
+			},
+			mockTokenService: func() *MockTokenService {
+				m := &MockTokenService{}
+				m.On("GetTenantLogin", "old-token").Return("", "testuser", nil)
+				m.On("GenerateTenantToken", "", "testuser").Return("new-token", nil)
+				m.On("RevokeToken", "old-token").Return(nil)
+				return m
+			},
+			wantStatusCode: http.StatusOK,
+			wantResponse:   "Bearer new-token",
+		},
+		{
+			name:            "Expired Token",
+			authHeader:      "Bearer old-token",
+			mockUserService: func() *MockUserService { return &MockUserService{} },
+			mockTokenService: func() *MockTokenService {
+				m := &MockTokenService{}
+				m.On("GetTenantLogin", "old-token").Return("", "", expiredErr)
+				return m
+			},
+			wantStatusCode: http.StatusUnauthorized,
+			wantResponse:   `{"code":401,"message":"Unauthorized: Invalid token"}`,
+		},
+		{
+			name:             "Missing Token",
+			mockUserService:  func() *MockUserService { return &MockUserService{} },
+			mockTokenService: func() *MockTokenService { return &MockTokenService{} },
+			wantStatusCode:   http.StatusUnauthorized,
+			wantResponse:     `{"code":401,"message":"Unauthorized: Missing bearer token"}`,
+		},
+		{
+			name:       "Renamed User",
+			authHeader: "Bearer old-token",
+			mockUserService: func() *MockUserService {
+				m := &MockUserService{}
+				m.On("GetByUserLogin", mock.Anything, "testuser").
+					Return((*repository.User)(nil), appErrors.New(errors.New("no rows"), "User not found"))
+				return m
+			},
+			mockTokenService: func() *MockTokenService {
+				m := &MockTokenService{}
+				m.On("GetTenantLogin", "old-token").Return("", "testuser", nil)
+				return m
+			},
+			wantStatusCode: http.StatusUnauthorized,
+			wantResponse:   `{"code":401,"message":"Unauthorized: User not found"}`,
+		},
+		{
+			name:             "Invalid Revoke Parameter",
+			authHeader:       "Bearer old-token",
+			query:            "?revoke_old=maybe",
+			mockUserService:  func() *MockUserService { return &MockUserService{} },
+			mockTokenService: func() *MockTokenService { return &MockTokenService{} },
+			wantStatusCode:   http.StatusBadRequest,
+			wantResponse:     `{"code":400,"message":"Invalid revoke_old parameter"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/api/user/token/renew"+tt.query, nil)
+			if tt.authHeader != "" {
+				req.Header.Set("Authorization", tt.authHeader)
+			}
+			w := httptest.NewRecorder()
+			tokenService := tt.mockTokenService()
+			uh := &UserHandler{
+				userService:    tt.mockUserService(),
+				tokenService:   tokenService,
+				contextTimeout: 5 * time.Second,
+			}
+
+			uh.RenewToken(w, req)
+
+			assert.Equal(t, tt.wantStatusCode, w.Code)
+			if tt.wantStatusCode == http.StatusOK {
+				assert.Equal(t, tt.wantResponse, w.Body.String())
+				assert.Equal(t, tt.wantResponse, w.Header().Get("Authorization"))
+			} else {
+				assert.JSONEq(t, tt.wantResponse, w.Body.String())
+			}
+			tokenService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestUserHandler_Login_TokenCookie(t *testing.T) {
+	user := &repository.User{UUID: uuid.New(), Login: "testuser"}
+	tests := []struct {
+		name       string
+		cookieName string
+		secure     bool
+		wantCookie bool
+	}{
+		{name: "Cookie Set", cookieName: "auth", secure: true, wantCookie: true},
+		{name: "Cookie Disabled", cookieName: "", wantCookie: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			us := &MockUserService{}
+			us.On("Authenticate", mock.Anything, "testuser", "password").Return(user, nil)
+			ts := &MockTokenService{}
+			ts.On("GenerateTenantToken", "", "testuser").Return("secret-token", nil)
+			uh := NewUserHandler(us, ts, 5).WithTokenCookie(tt.cookieName, time.Hour, tt.secure)
+
+			req := httptest.NewRequest("POST", "/api/user/login", strings.NewReader(`{"login":"testuser","password":"password"}`))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			uh.Login(w, req)
+
+			require.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, "Bearer secret-token", w.Header().Get("Authorization"), "the header is set either way")
+			cookies := w.Result().Cookies()
+			if !tt.wantCookie {
+				assert.Empty(t, cookies)
+				return
+			}
+			require.Len(t, cookies, 1)
+			assert.Equal(t, "auth", cookies[0].Name)
+			assert.Equal(t, "secret-token", cookies[0].Value)
+			assert.True(t, cookies[0].HttpOnly)
+			assert.True(t, cookies[0].Secure)
+			assert.Equal(t, 3600, cookies[0].MaxAge)
+			assert.Equal(t, "/", cookies[0].Path)
+		})
+	}
+}
+
+func TestUserHandler_Login_Tenant(t *testing.T) {
+	user := &repository.User{UUID: uuid.New(), Login: "testuser", TenantID: "acme"}
+	tests := []struct {
+		name           string
+		tenantID       string
+		wantStatusCode int
+	}{
+		{name: "Tenant Header", tenantID: "acme", wantStatusCode: http.StatusOK},
+		{name: "Tenant Too Long", tenantID: strings.Repeat("a", maxTenantIDLength+1), wantStatusCode: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			us := &MockUserService{}
+			inTenant := mock.MatchedBy(func(ctx context.Context) bool {
+				tenantID, ok := appContext.TenantID(ctx)
+				return ok && tenantID == "acme"
+			})
+			us.On("Authenticate", inTenant, "testuser", "password").Return(user, nil)
+			ts := &MockTokenService{}
+			ts.On("GenerateTenantToken", "acme", "testuser").Return("secret-token", nil)
+			uh := NewUserHandler(us, ts, 5)
+
+			req := httptest.NewRequest("POST", "/api/user/login", strings.NewReader(`{"login":"testuser","password":"password"}`))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("X-Tenant-ID", tt.tenantID)
+			w := httptest.NewRecorder()
+			uh.Login(w, req)
+
+			assert.Equal(t, tt.wantStatusCode, w.Code)
+			if tt.wantStatusCode == http.StatusOK {
+				assert.Equal(t, "Bearer secret-token", w.Header().Get("Authorization"))
+				us.AssertExpectations(t)
+			}
+		})
+	}
+}
+
+func TestUserHandler_Login_JSON(t *testing.T) {
+	user := &repository.User{UUID: uuid.New(), Login: "testuser"}
+	now := time.Date(2021, 1, 2, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name          string
+		accept        string
+		tokenLifetime time.Duration
+		wantJSON      string
+	}{
+		{name: "JSON", accept: "application/json", tokenLifetime: time.Hour,
+			wantJSON: `{"token":"secret-token","expires_at":"2021-01-02T01:00:00Z"}`},
+		{name: "JSON Without Lifetime", accept: "application/json", wantJSON: `{"token":"secret-token"}`},
+		{name: "Plain Text By Default", accept: "", tokenLifetime: time.Hour},
+		{name: "Wildcard", accept: "*/*", tokenLifetime: time.Hour},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			us := &MockUserService{}
+			us.On("Authenticate", mock.Anything, "testuser", "password").Return(user, nil)
+			ts := &MockTokenService{}
+			ts.On("GenerateTenantToken", "", "testuser").Return("secret-token", nil)
+			uh := NewUserHandler(us, ts, 5).WithTokenLifetime(tt.tokenLifetime)
+			uh.now = func() time.Time { return now }
+
+			req := httptest.NewRequest("POST", "/api/user/login", strings.NewReader(`{"login":"testuser","password":"password"}`))
+			req.Header.Set("Content-Type", "application/json")
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+			w := httptest.NewRecorder()
+			uh.Login(w, req)
+
+			require.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, "Bearer secret-token", w.Header().Get("Authorization"), "the header is set either way")
+			if tt.wantJSON == "" {
+				assert.Equal(t, "Bearer secret-token", w.Body.String())
+				return
+			}
+			assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+			assert.JSONEq(t, tt.wantJSON, w.Body.String())
+		})
+	}
+}
+
+func TestUserHandler_Register_TokenCookie(t *testing.T) {
+	user := &repository.User{UUID: uuid.New(), Login: "newuser"}
+	us := &MockUserService{}
+	us.On("Create", mock.Anything, "newuser", "newpassword").Return(user, nil)
+	ts := &MockTokenService{}
+	ts.On("GenerateTenantToken", "", "newuser").Return("secret-token", nil)
+	uh := NewUserHandler(us, ts, 5).WithTokenCookie("auth", time.Hour, false)
+
+	req := httptest.NewRequest("POST", "/api/user/register", strings.NewReader(`{"login":"newuser","password":"newpassword"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	uh.Register(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	cookies := w.Result().Cookies()
+	require.Len(t, cookies, 1)
+	assert.Equal(t, "secret-token", cookies[0].Value)
+	assert.False(t, cookies[0].Secure)
+}
@@ -5,10 +5,10 @@ import (
 	appContext "github.com/ujwegh/gophermart/internal/app/context"
 	"github.com/ujwegh/gophermart/internal/app/handlers"
 	"github.com/ujwegh/gophermart/internal/app/logger"
+	"github.com/ujwegh/gophermart/internal/app/repository"
 	"github.com/ujwegh/gophermart/internal/app/service"
 	"go.uber.org/zap"
 	"net/http"
-	"strings"
 	"time"
 )
 
@@ -16,50 +16,107 @@ type AuthMiddleware struct {
 	tokenService   service.TokenService
 	userService    service.UserService
 	contextTimeout time.Duration
+	adminLogins    map[string]struct{}
+	// cookieName names the cookie the token is read from when the Authorization header is absent, empty disables it
+	cookieName string
 }
 
-func NewAuthMiddleware(tokenService service.TokenService, userService service.UserService, contextTimeoutSec int) AuthMiddleware {
+func NewAuthMiddleware(tokenService service.TokenService, userService service.UserService, contextTimeoutSec int, adminLogins []string) AuthMiddleware {
+	admins := make(map[string]struct{}, len(adminLogins))
+	for _, login := range adminLogins {
+		admins[login] = struct{}{}
+	}
 	return AuthMiddleware{
 		tokenService:   tokenService,
 		userService:    userService,
 		contextTimeout: time.Duration(contextTimeoutSec) * time.Second,
+		adminLogins:    admins,
 	}
 }
 
+// WithTokenCookie also accepts the token from the named cookie, for browser clients keeping it in an HttpOnly cookie.
+// The Authorization header takes precedence when both are sent.
+func (am AuthMiddleware) WithTokenCookie(name string) AuthMiddleware {
+	am.cookieName = name
+	return am
+}
+
 func (am *AuthMiddleware) Authenticate(next http.Handler) http.Handler {
+	return am.authenticate(next, false)
+}
+
+// AuthenticateAdmin works like Authenticate but only lets through users listed in the admin logins.
+func (am *AuthMiddleware) AuthenticateAdmin(next http.Handler) http.Handler {
+	return am.authenticate(next, true)
+}
+
+func (am *AuthMiddleware) authenticate(next http.Handler, adminOnly bool) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		ctx, cancel := context.WithTimeout(context.Background(), am.contextTimeout)
 		defer cancel()
 
 		authHeader := r.Header.Get("Authorization")
+		if authHeader == "" && am.cookieName != "" {
+			if cookie, err := r.Cookie(am.cookieName); err == nil && cookie.Value != "" {
+				authHeader = "Bearer " + cookie.Value
+			}
+		}
 		if authHeader == "" {
 			logger.Log.Error("auth header is empty")
-			handlers.WriteJSONErrorResponse(w, "Unauthorized: Empty auth header", http.StatusUnauthorized)
+			handlers.WriteErrorResponse(w, r, "Unauthorized: Empty auth header", http.StatusUnauthorized)
+			return
+		}
+		token, ok := handlers.BearerToken(authHeader)
+		if !ok {
+			logger.Log.Error("unsupported authorization scheme")
+			handlers.WriteErrorResponse(w, r, "Unauthorized: unsupported authorization scheme", http.StatusUnauthorized)
+			return
+		}
+		if token == "" {
+			logger.Log.Error("bearer token is empty")
+			handlers.WriteErrorResponse(w, r, "Unauthorized: Empty token", http.StatusUnauthorized)
 			return
 		}
-		token := strings.Split(authHeader, "Bearer ")[1]
 
-		userEmail, err := am.tokenService.GetUserLogin(token)
+		tenantID, userEmail, err := am.tokenService.GetTenantLogin(token)
 		if err != nil {
 			logger.Log.Error("failed to get user login", zap.Error(err))
-			handlers.WriteJSONErrorResponse(w, "Unauthorized: Invalid token", http.StatusUnauthorized)
+			handlers.WriteErrorResponse(w, r, "Unauthorized: Invalid token", http.StatusUnauthorized)
 			return
 		}
 
+		// tokens issued before tenants existed carry no claim and belong to the default tenant
+		ctx = appContext.WithTenantID(ctx, tenantID)
 		user, err := am.userService.GetByUserLogin(ctx, userEmail)
 		if err != nil {
 			logger.Log.Error("failed to get user", zap.Error(err))
-			handlers.WriteJSONErrorResponse(w, "Unauthorized: User not found", http.StatusUnauthorized)
+			handlers.WriteErrorResponse(w, r, "Unauthorized: User not found", http.StatusUnauthorized)
+			return
+		}
+
+		if adminOnly && !am.isAdmin(user) {
+			logger.Log.Error("user is not an admin", zap.String("login", user.Login))
+			handlers.WriteErrorResponse(w, r, "Forbidden: Admin access required", http.StatusForbidden)
 			return
 		}
 
 		err = appContext.GetContextError(ctx)
 		if err != nil {
-			handlers.PrepareError(w, err)
+			handlers.PrepareError(w, r, err)
 			return
 		}
 
-		r = r.WithContext(appContext.WithUserUID(r.Context(), &user.UUID))
+		reqCtx := appContext.WithTenantID(r.Context(), user.TenantID)
+		r = r.WithContext(appContext.WithUserLogin(appContext.WithUserUID(reqCtx, &user.UUID), user.Login))
 		next.ServeHTTP(w, r)
 	})
 }
+
+// isAdmin only considers users of the default tenant, the admin logins are not tenant qualified.
+func (am *AuthMiddleware) isAdmin(user *repository.User) bool {
+	if user.TenantID != "" {
+		return false
+	}
+	_, ok := am.adminLogins[user.Login]
+	return ok
+}
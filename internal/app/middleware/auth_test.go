@@ -0,0 +1,314 @@
+package middlware
+
+import (
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/ujwegh/gophermart/internal/app/config"
+	appContext "github.com/ujwegh/gophermart/internal/app/context"
+	"github.com/ujwegh/gophermart/internal/app/handlers"
+	"github.com/ujwegh/gophermart/internal/app/repository"
+	"github.com/ujwegh/gophermart/internal/app/service"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// stubTokenService accepts a single known token.
+type stubTokenService struct {
+	token    string
+	login    string
+	tenantID string
+}
+
+func (s *stubTokenService) GetUserLogin(tokenString string) (string, error) {
+	if tokenString != s.token {
+		return "", errors.New("invalid token")
+	}
+	return s.login, nil
+}
+
+func (s *stubTokenService) GetTenantLogin(tokenString string) (string, string, error) {
+	login, err := s.GetUserLogin(tokenString)
+	return s.tenantID, login, err
+}
+
+func (s *stubTokenService) GenerateToken(login string) (string, error) {
+	return s.token, nil
+}
+
+func (s *stubTokenService) GenerateTenantToken(tenantID, login string) (string, error) {
+	return s.token, nil
+}
+
+func (s *stubTokenService) RevokeToken(tokenString string) error {
+	return nil
+}
+
+type stubUserService struct {
+	user *repository.User
+}
+
+func (s *stubUserService) Create(ctx context.Context, login, password string) (*repository.User, error) {
+	return s.user, nil
+}
+
+func (s *stubUserService) CreateIdempotent(ctx context.Context, login, password, idempotencyKey string) (*repository.User, error) {
+	return s.user, nil
+}
+
+func (s *stubUserService) Authenticate(ctx context.Context, login, password string) (*repository.User, error) {
+	return s.user, nil
+}
+
+func (s *stubUserService) GetByUserLogin(ctx context.Context, login string) (*repository.User, error) {
+	if login != s.user.Login {
+		return nil, errors.New("user not found")
+	}
+	return s.user, nil
+}
+
+func (s *stubUserService) UpdateLogin(ctx context.Context, userUID *uuid.UUID, newLogin string) error {
+	return nil
+}
+
+func TestAuthMiddleware_Authenticate_Scheme(t *testing.T) {
+	user := &repository.User{UUID: uuid.New(), Login: "user"}
+	am := NewAuthMiddleware(&stubTokenService{token: "token", login: user.Login}, &stubUserService{user: user}, 5, nil)
+	handler := am.Authenticate(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, &user.UUID, appContext.UserUID(r.Context()))
+		assert.Equal(t, user.Login, appContext.UserLogin(r.Context()))
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	tests := []struct {
+		name             string
+		authHeader       string
+		wantStatusCode   int
+		wantResponseBody string
+	}{
+		{
+			name:           "Bearer Token",
+			authHeader:     "Bearer token",
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			name:           "Lowercase Scheme",
+			authHeader:     "bearer token",
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			name:           "Double Space Before Token",
+			authHeader:     "Bearer  token",
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			name:             "Basic Scheme",
+			authHeader:       "Basic abc",
+			wantStatusCode:   http.StatusUnauthorized,
+			wantResponseBody: `{"code":401,"message":"Unauthorized: unsupported authorization scheme"}`,
+		},
+		{
+			name:             "Token Without Scheme",
+			authHeader:       "token",
+			wantStatusCode:   http.StatusUnauthorized,
+			wantResponseBody: `{"code":401,"message":"Unauthorized: unsupported authorization scheme"}`,
+		},
+		{
+			name:             "Scheme Without Token",
+			authHeader:       "Bearer ",
+			wantStatusCode:   http.StatusUnauthorized,
+			wantResponseBody: `{"code":401,"message":"Unauthorized: Empty token"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/api/user/orders", nil)
+			req.Header.Set("Authorization", tt.authHeader)
+			w := httptest.NewRecorder()
+
+			handler.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantStatusCode, w.Code)
+			if tt.wantResponseBody != "" {
+				assert.JSONEq(t, tt.wantResponseBody, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestAuthMiddleware_Authenticate_Cookie(t *testing.T) {
+	user := &repository.User{UUID: uuid.New(), Login: "user"}
+	am := NewAuthMiddleware(&stubTokenService{token: "token", login: user.Login}, &stubUserService{user: user}, 5, nil).
+		WithTokenCookie("auth")
+	handler := am.Authenticate(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, &user.UUID, appContext.UserUID(r.Context()))
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	tests := []struct {
+		name             string
+		authHeader       string
+		cookie           *http.Cookie
+		wantStatusCode   int
+		wantResponseBody string
+	}{
+		{
+			name:           "Cookie Token",
+			cookie:         &http.Cookie{Name: "auth", Value: "token"},
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			name:             "Header Takes Precedence Over Valid Cookie",
+			authHeader:       "Bearer stale",
+			cookie:           &http.Cookie{Name: "auth", Value: "token"},
+			wantStatusCode:   http.StatusUnauthorized,
+			wantResponseBody: `{"code":401,"message":"Unauthorized: Invalid token"}`,
+		},
+		{
+			name:           "Header Takes Precedence Over Invalid Cookie",
+			authHeader:     "Bearer token",
+			cookie:         &http.Cookie{Name: "auth", Value: "stale"},
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			name:             "Other Cookie",
+			cookie:           &http.Cookie{Name: "session", Value: "token"},
+			wantStatusCode:   http.StatusUnauthorized,
+			wantResponseBody: `{"code":401,"message":"Unauthorized: Empty auth header"}`,
+		},
+		{
+			name:             "Invalid Cookie Token",
+			cookie:           &http.Cookie{Name: "auth", Value: "stale"},
+			wantStatusCode:   http.StatusUnauthorized,
+			wantResponseBody: `{"code":401,"message":"Unauthorized: Invalid token"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/api/user/orders", nil)
+			if tt.authHeader != "" {
+				req.Header.Set("Authorization", tt.authHeader)
+			}
+			req.AddCookie(tt.cookie)
+			w := httptest.NewRecorder()
+
+			handler.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantStatusCode, w.Code)
+			if tt.wantResponseBody != "" {
+				assert.JSONEq(t, tt.wantResponseBody, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestAuthMiddleware_Authenticate_CookieDisabled(t *testing.T) {
+	user := &repository.User{UUID: uuid.New(), Login: "user"}
+	am := NewAuthMiddleware(&stubTokenService{token: "token", login: user.Login}, &stubUserService{user: user}, 5, nil)
+	handler := am.Authenticate(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	req := httptest.NewRequest("GET", "/api/user/orders", nil)
+	req.AddCookie(&http.Cookie{Name: "auth", Value: "token"})
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func TestAuthMiddleware_Authenticate_Tenant(t *testing.T) {
+	user := &repository.User{UUID: uuid.New(), Login: "admin", TenantID: "acme"}
+	am := NewAuthMiddleware(&stubTokenService{token: "token", login: user.Login, tenantID: user.TenantID},
+		&stubUserService{user: user}, 5, []string{"admin"})
+
+	var tenantID string
+	var scoped bool
+	handler := am.Authenticate(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tenantID, scoped = appContext.TenantID(r.Context())
+		w.WriteHeader(http.StatusOK)
+	}))
+	req := httptest.NewRequest("GET", "/api/user/orders", nil)
+	req.Header.Set("Authorization", "Bearer token")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.True(t, scoped)
+	assert.Equal(t, "acme", tenantID)
+
+	// admin logins only count in the default tenant
+	adminHandler := am.AuthenticateAdmin(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	w = httptest.NewRecorder()
+	adminHandler.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
+
+// renamingUserService keeps the users by login, so a renamed user's old login can be taken by another one.
+type renamingUserService struct {
+	stubUserService
+	users map[string]*repository.User
+}
+
+func (s *renamingUserService) GetByUserLogin(ctx context.Context, login string) (*repository.User, error) {
+	user, ok := s.users[login]
+	if !ok {
+		return nil, errors.New("user not found")
+	}
+	return user, nil
+}
+
+func (s *renamingUserService) UpdateLogin(ctx context.Context, userUID *uuid.UUID, newLogin string) error {
+	for login, user := range s.users {
+		if user.UUID == *userUID {
+			delete(s.users, login)
+			user.Login = newLogin
+			s.users[newLogin] = user
+		}
+	}
+	return nil
+}
+
+func TestAuthMiddleware_Authenticate_RenamedUser(t *testing.T) {
+	user := &repository.User{UUID: uuid.New(), Login: "user"}
+	us := &renamingUserService{users: map[string]*repository.User{user.Login: user}}
+	ts := service.NewTokenService(config.AppConfig{TokenSecretKey: "super-duper-secret", TokenLifetimeSec: 3600})
+	am := NewAuthMiddleware(ts, us, 5, nil)
+	uh := handlers.NewUserHandler(us, ts, 5)
+	profile := am.Authenticate(http.HandlerFunc(uh.UpdateProfile))
+	orders := am.Authenticate(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	oldToken, err := ts.GenerateToken(user.Login)
+	require.NoError(t, err)
+	req := httptest.NewRequest("PATCH", "/api/user/profile", strings.NewReader(`{"login":"renamed"}`))
+	req.Header.Set("Authorization", "Bearer "+oldToken)
+	w := httptest.NewRecorder()
+	profile.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	newToken := w.Header().Get("Authorization")
+
+	// another user registers the freed login
+	us.users["user"] = &repository.User{UUID: uuid.New(), Login: "user"}
+
+	req = httptest.NewRequest("GET", "/api/user/orders", nil)
+	req.Header.Set("Authorization", "Bearer "+oldToken)
+	w = httptest.NewRecorder()
+	orders.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.JSONEq(t, `{"code":401,"message":"Unauthorized: Invalid token"}`, w.Body.String())
+
+	req = httptest.NewRequest("GET", "/api/user/orders", nil)
+	req.Header.Set("Authorization", newToken)
+	w = httptest.NewRecorder()
+	orders.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+}
@@ -0,0 +1,78 @@
+package middlware
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// ClientIPResolver finds the address a request comes from. X-Forwarded-For can be sent by anyone, so it is only
+// believed as far as trusted proxies appended to it: the list is read from the right, skipping trusted hops,
+// and the first address that isn't one is the client.
+type ClientIPResolver struct {
+	trusted []*net.IPNet
+}
+
+// NewClientIPResolver parses the trusted proxies, each an IP address or a CIDR range.
+// Without any, X-Forwarded-For is ignored and the peer address is the client.
+func NewClientIPResolver(trustedProxies []string) (*ClientIPResolver, error) {
+	cr := &ClientIPResolver{}
+	for _, proxy := range trustedProxies {
+		if !strings.Contains(proxy, "/") {
+			ip := net.ParseIP(proxy)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q", proxy)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			cr.trusted = append(cr.trusted, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, network, err := net.ParseCIDR(proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %w", proxy, err)
+		}
+		cr.trusted = append(cr.trusted, network)
+	}
+	return cr, nil
+}
+
+// ClientIP returns the address of the client r comes from, without port. A nil resolver trusts no proxy.
+func (cr *ClientIPResolver) ClientIP(r *http.Request) string {
+	client := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		client = host
+	}
+	if !cr.isTrusted(net.ParseIP(client)) {
+		return client
+	}
+
+	hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		ip := net.ParseIP(strings.TrimSpace(hops[i]))
+		if ip == nil {
+			// garbage in the header, the last trusted hop is as far as it can be followed
+			break
+		}
+		client = ip.String()
+		if !cr.isTrusted(ip) {
+			break
+		}
+	}
+	return client
+}
+
+func (cr *ClientIPResolver) isTrusted(ip net.IP) bool {
+	if cr == nil || ip == nil {
+		return false
+	}
+	for _, network := range cr.trusted {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,108 @@
+package middlware
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientIPResolver_ClientIP(t *testing.T) {
+	cr, err := NewClientIPResolver([]string{"10.0.0.0/8", "192.168.1.1", "fd00::/8"})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name         string
+		remoteAddr   string
+		forwardedFor []string
+		wantClientIP string
+	}{
+		{
+			name:         "Direct Client",
+			remoteAddr:   "203.0.113.7:51234",
+			wantClientIP: "203.0.113.7",
+		},
+		{
+			name:         "Spoofed Header From Untrusted Peer",
+			remoteAddr:   "203.0.113.7:51234",
+			forwardedFor: []string{"198.51.100.1"},
+			wantClientIP: "203.0.113.7",
+		},
+		{
+			name:         "Trusted Proxy",
+			remoteAddr:   "10.1.2.3:443",
+			forwardedFor: []string{"198.51.100.1"},
+			wantClientIP: "198.51.100.1",
+		},
+		{
+			name:         "Chain Of Trusted Proxies",
+			remoteAddr:   "192.168.1.1:443",
+			forwardedFor: []string{"198.51.100.1, 10.0.0.5"},
+			wantClientIP: "198.51.100.1",
+		},
+		{
+			name:         "Spoofed Entry Before The Client",
+			remoteAddr:   "10.1.2.3:443",
+			forwardedFor: []string{"1.2.3.4, 198.51.100.1"},
+			wantClientIP: "198.51.100.1",
+		},
+		{
+			name:         "Repeated Headers",
+			remoteAddr:   "10.1.2.3:443",
+			forwardedFor: []string{"1.2.3.4", "198.51.100.1"},
+			wantClientIP: "198.51.100.1",
+		},
+		{
+			name:         "Only Trusted Hops",
+			remoteAddr:   "10.1.2.3:443",
+			forwardedFor: []string{"10.0.0.9, 10.0.0.5"},
+			wantClientIP: "10.0.0.9",
+		},
+		{
+			name:         "Garbage Stops At Last Trusted Hop",
+			remoteAddr:   "10.1.2.3:443",
+			forwardedFor: []string{"198.51.100.1, unknown, 10.0.0.5"},
+			wantClientIP: "10.0.0.5",
+		},
+		{
+			name:         "Trusted Proxy Without Header",
+			remoteAddr:   "10.1.2.3:443",
+			wantClientIP: "10.1.2.3",
+		},
+		{
+			name:         "IPv6",
+			remoteAddr:   "[fd00::1]:443",
+			forwardedFor: []string{"2001:db8::7"},
+			wantClientIP: "2001:db8::7",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/api/user/orders", nil)
+			req.RemoteAddr = tt.remoteAddr
+			for _, value := range tt.forwardedFor {
+				req.Header.Add("X-Forwarded-For", value)
+			}
+			assert.Equal(t, tt.wantClientIP, cr.ClientIP(req))
+		})
+	}
+}
+
+func TestClientIPResolver_NoTrustedProxies(t *testing.T) {
+	req := httptest.NewRequest("GET", "/api/user/orders", nil)
+	req.RemoteAddr = "10.1.2.3:443"
+	req.Header.Set("X-Forwarded-For", "198.51.100.1")
+
+	cr, err := NewClientIPResolver(nil)
+	require.NoError(t, err)
+	assert.Equal(t, "10.1.2.3", cr.ClientIP(req))
+	assert.Equal(t, "10.1.2.3", (*ClientIPResolver)(nil).ClientIP(req))
+}
+
+func TestNewClientIPResolver_Invalid(t *testing.T) {
+	for _, proxy := range []string{"proxy.local", "10.0.0.0/33", ""} {
+		_, err := NewClientIPResolver([]string{proxy})
+		assert.Error(t, err, proxy)
+	}
+}
@@ -0,0 +1,144 @@
+package middlware
+
+import (
+	"compress/gzip"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// gzipResponseWriter holds the response back until it reaches minSize bytes, then switches to gzip.
+// Smaller responses are written as is once the handler returns.
+type gzipResponseWriter struct {
+	http.ResponseWriter
+	minSize     int
+	status      int
+	buf         []byte
+	gz          *gzip.Writer
+	wroteHeader bool
+}
+
+func (gw *gzipResponseWriter) WriteHeader(statusCode int) {
+	if gw.status == 0 {
+		gw.status = statusCode
+	}
+}
+
+func (gw *gzipResponseWriter) Write(b []byte) (int, error) {
+	if gw.gz != nil {
+		return gw.gz.Write(b)
+	}
+	if gw.wroteHeader {
+		return gw.ResponseWriter.Write(b)
+	}
+	gw.buf = append(gw.buf, b...)
+	if len(gw.buf) >= gw.minSize {
+		if err := gw.startGzip(); err != nil {
+			return 0, err
+		}
+	}
+	return len(b), nil
+}
+
+func (gw *gzipResponseWriter) startGzip() error {
+	h := gw.Header()
+	if h.Get("Content-Encoding") != "" {
+		// the handler encoded the body itself
+		return gw.flushPlain()
+	}
+	if h.Get("Content-Type") == "" {
+		h.Set("Content-Type", http.DetectContentType(gw.buf))
+	}
+	h.Set("Content-Encoding", "gzip")
+	h.Del("Content-Length")
+	gw.writeHeader()
+	gw.gz = gzip.NewWriter(gw.ResponseWriter)
+	_, err := gw.gz.Write(gw.buf)
+	gw.buf = nil
+	return err
+}
+
+func (gw *gzipResponseWriter) flushPlain() error {
+	gw.writeHeader()
+	if len(gw.buf) == 0 {
+		return nil
+	}
+	_, err := gw.ResponseWriter.Write(gw.buf)
+	gw.buf = nil
+	return err
+}
+
+func (gw *gzipResponseWriter) writeHeader() {
+	if gw.status == 0 {
+		gw.status = http.StatusOK
+	}
+	gw.wroteHeader = true
+	gw.ResponseWriter.WriteHeader(gw.status)
+}
+
+// Flush implements http.Flusher. A handler flushing a response still held back wants it streamed,
+// so compression starts right away and whatever was written so far is sent to the client.
+func (gw *gzipResponseWriter) Flush() {
+	if gw.gz == nil && !gw.wroteHeader {
+		if err := gw.startGzip(); err != nil {
+			return
+		}
+	}
+	if gw.gz != nil {
+		if err := gw.gz.Flush(); err != nil {
+			return
+		}
+	}
+	if f, ok := gw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// close completes the response: it ends the gzip stream or writes out a response below the threshold.
+func (gw *gzipResponseWriter) close() error {
+	if gw.gz != nil {
+		return gw.gz.Close()
+	}
+	if gw.wroteHeader {
+		return nil
+	}
+	return gw.flushPlain()
+}
+
+// Gzip compresses responses of at least minSize bytes for clients sending Accept-Encoding: gzip.
+// A negative minSize turns compression off.
+func Gzip(minSize int) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if minSize < 0 {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("Vary", "Accept-Encoding")
+			if !acceptsGzip(r.Header.Get("Accept-Encoding")) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			gw := &gzipResponseWriter{ResponseWriter: w, minSize: minSize}
+			// not deferred: after a panic the buffered response is dropped so Recoverer can write its own
+			next.ServeHTTP(gw, r)
+			gw.close()
+		})
+	}
+}
+
+// acceptsGzip reports whether the Accept-Encoding header lists gzip without q=0.
+func acceptsGzip(acceptEncoding string) bool {
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		coding, params, _ := strings.Cut(part, ";")
+		if !strings.EqualFold(strings.TrimSpace(coding), "gzip") {
+			continue
+		}
+		params = strings.TrimSpace(params)
+		if params == "" {
+			return true
+		}
+		q, err := strconv.ParseFloat(strings.TrimPrefix(params, "q="), 64)
+		return err == nil && q > 0
+	}
+	return false
+}
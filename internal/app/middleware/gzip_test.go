@@ -0,0 +1,116 @@
+package middlware
+
+import (
+	"bytes"
+	"compress/gzip"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestGzip(t *testing.T) {
+	const minSize = 1024
+	largeBody := `[` + strings.Repeat(`{"number":"354188083613","status":"PROCESSED"},`, 50) + `{}]`
+	smallBody := `{"current":500.5,"withdrawn":42}`
+
+	tests := []struct {
+		name           string
+		body           string
+		status         int
+		acceptEncoding string
+		wantGzip       bool
+	}{
+		{name: "Large Response Is Compressed", body: largeBody, status: http.StatusOK, acceptEncoding: "gzip, deflate", wantGzip: true},
+		{name: "Small Response Is Not Compressed", body: smallBody, status: http.StatusOK, acceptEncoding: "gzip"},
+		{name: "Client Without Gzip", body: largeBody, status: http.StatusOK, acceptEncoding: "deflate"},
+		{name: "Gzip Refused With Zero Quality", body: largeBody, status: http.StatusOK, acceptEncoding: "gzip;q=0"},
+		{name: "Empty Response Keeps Status", status: http.StatusNoContent, acceptEncoding: "gzip"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := Gzip(minSize)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Add("Content-Type", "application/json")
+				w.WriteHeader(tt.status)
+				// write in chunks so the threshold is crossed midway
+				for body := tt.body; body != ""; {
+					n := len(body)
+					if n > 100 {
+						n = 100
+					}
+					w.Write([]byte(body[:n]))
+					body = body[n:]
+				}
+			}))
+			req := httptest.NewRequest("GET", "/api/user/orders", nil)
+			req.Header.Set("Accept-Encoding", tt.acceptEncoding)
+			rr := httptest.NewRecorder()
+
+			handler.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.status, rr.Code)
+			assert.Equal(t, "application/json", rr.Header().Get("Content-Type"))
+			assert.Equal(t, "Accept-Encoding", rr.Header().Get("Vary"))
+			if !tt.wantGzip {
+				assert.Empty(t, rr.Header().Get("Content-Encoding"))
+				assert.Equal(t, tt.body, rr.Body.String())
+				return
+			}
+			assert.Equal(t, "gzip", rr.Header().Get("Content-Encoding"))
+			assert.Less(t, rr.Body.Len(), len(tt.body))
+			zr, err := gzip.NewReader(rr.Body)
+			require.NoError(t, err)
+			got, err := io.ReadAll(zr)
+			require.NoError(t, err)
+			assert.Equal(t, tt.body, string(got))
+		})
+	}
+}
+
+func TestGzip_Flush(t *testing.T) {
+	const event = "data: {\"number\":\"354188083613\",\"status\":\"PROCESSED\"}\n\n"
+	rr := httptest.NewRecorder()
+	flushed := make(chan []byte, 1)
+	handler := Gzip(1024)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write([]byte(event))
+		f, ok := w.(http.Flusher)
+		require.True(t, ok, "the gzip writer must be a Flusher")
+		f.Flush()
+		flushed <- append([]byte(nil), rr.Body.Bytes()...)
+	}))
+	req := httptest.NewRequest("GET", "/api/user/orders", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+
+	handler.ServeHTTP(rr, req)
+
+	assert.True(t, rr.Flushed)
+	assert.Equal(t, "gzip", rr.Header().Get("Content-Encoding"))
+	assert.Equal(t, "text/event-stream", rr.Header().Get("Content-Type"))
+	// the event is readable from what reached the client at the flush, before the stream was closed
+	zr, err := gzip.NewReader(bytes.NewReader(<-flushed))
+	require.NoError(t, err)
+	got := make([]byte, len(event))
+	_, err = io.ReadFull(zr, got)
+	require.NoError(t, err)
+	assert.Equal(t, event, string(got))
+}
+
+func TestGzip_Disabled(t *testing.T) {
+	body := strings.Repeat("a", 4096)
+	handler := Gzip(-1)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(body))
+	}))
+	req := httptest.NewRequest("GET", "/api/user/withdrawals", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rr := httptest.NewRecorder()
+
+	handler.ServeHTTP(rr, req)
+
+	assert.Empty(t, rr.Header().Get("Content-Encoding"))
+	assert.Equal(t, body, rr.Body.String())
+}
@@ -2,10 +2,26 @@ package middlware
 
 import (
 	"bytes"
+	"fmt"
 	"github.com/ujwegh/gophermart/internal/app/logger"
 	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 	"io"
 	"net/http"
+	"regexp"
+)
+
+// AccessLogOff turns request and response logging off when used as the access log level.
+const AccessLogOff = "off"
+
+var (
+	// passwordField matches the value of a JSON "password" field, including escaped quotes inside it.
+	passwordField = regexp.MustCompile(`(?i)("password"\s*:\s*)"(?:[^"\\]|\\.)*"`)
+	// credentialPaths receive passwords; their bodies are hidden completely when no password field can be masked
+	credentialPaths = map[string]bool{
+		"/api/user/register": true,
+		"/api/user/login":    true,
+	}
 )
 
 type responseRecorder struct {
@@ -13,6 +29,7 @@ type responseRecorder struct {
 	status        int
 	contentLength int
 	body          bytes.Buffer
+	keepBody      bool
 }
 
 func (rr *responseRecorder) WriteHeader(statusCode int) {
@@ -24,40 +41,87 @@ func (rr *responseRecorder) Write(b []byte) (int, error) {
 	n, err := rr.ResponseWriter.Write(b)
 	if err == nil {
 		rr.contentLength += n
-		rr.body.Write(b)
+		if rr.keepBody {
+			rr.body.Write(b)
+		}
 	}
 	return n, err
 }
 
-func RequestLogger(next http.Handler) http.Handler {
+// AccessLogger logs requests and responses at a configurable level, optionally without bodies.
+type AccessLogger struct {
+	level     zapcore.Level
+	disabled  bool
+	logBodies bool
+	clientIPs *ClientIPResolver
+}
+
+// NewAccessLogger parses level as a zap level, or AccessLogOff to skip access logging.
+func NewAccessLogger(level string, logBodies bool) (*AccessLogger, error) {
+	if level == AccessLogOff {
+		return &AccessLogger{disabled: true}, nil
+	}
+	lvl, err := zapcore.ParseLevel(level)
+	if err != nil {
+		return nil, fmt.Errorf("parse access log level: %w", err)
+	}
+	return &AccessLogger{level: lvl, logBodies: logBodies}, nil
+}
+
+// WithClientIPResolver logs the client address as resolved by cr instead of the peer address.
+func (al *AccessLogger) WithClientIPResolver(cr *ClientIPResolver) *AccessLogger {
+	al.clientIPs = cr
+	return al
+}
+
+func (al *AccessLogger) enabled() bool {
+	return !al.disabled && logger.Log.Core().Enabled(al.level)
+}
+
+func (al *AccessLogger) RequestLogger(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		bodyMsg, err := getRequestBodyForLogging(r)
-		if err != nil {
-			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		if !al.enabled() {
+			next.ServeHTTP(w, r)
 			return
 		}
-		logger.Log.Info("REQUEST:",
+		fields := []zap.Field{
 			zap.String("Method", r.Method),
 			zap.String("Path", r.URL.Path),
-			zap.String("Body", bodyMsg),
-		)
+			zap.String("ClientIP", al.clientIPs.ClientIP(r)),
+		}
+		if al.logBodies {
+			bodyMsg, err := getRequestBodyForLogging(r)
+			if err != nil {
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+			fields = append(fields, zap.String("Body", bodyMsg))
+		}
+		logger.Log.Log(al.level, "REQUEST:", fields...)
 		next.ServeHTTP(w, r)
 	})
 }
 
-func ResponseLogger(next http.Handler) http.Handler {
+func (al *AccessLogger) ResponseLogger(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		rr := &responseRecorder{ResponseWriter: w}
-		next.ServeHTTP(rr, r)
-		body := rr.body.String()
-		if len(body) == 0 {
-			body = "empty body"
+		if !al.enabled() {
+			next.ServeHTTP(w, r)
+			return
 		}
-		logger.Log.Info("RESPONSE:",
+		rr := &responseRecorder{ResponseWriter: w, keepBody: al.logBodies}
+		next.ServeHTTP(rr, r)
+		fields := []zap.Field{
 			zap.Int("Status", rr.status),
 			zap.Int("Content-Length", rr.contentLength),
-			zap.String("Body", body),
-		)
+		}
+		if al.logBodies {
+			body := rr.body.String()
+			if len(body) == 0 {
+				body = "empty body"
+			}
+			fields = append(fields, zap.String("Body", body))
+		}
+		logger.Log.Log(al.level, "RESPONSE:", fields...)
 	})
 }
 
@@ -71,5 +135,18 @@ func getRequestBodyForLogging(r *http.Request) (string, error) {
 		return "empty body", nil
 	}
 	r.Body = io.NopCloser(bytes.NewBuffer(body))
-	return string(body), nil
+	return redactBody(r.URL.Path, body), nil
+}
+
+// redactBody masks the values of JSON password fields, leaving the rest of the body as it was sent.
+// A body sent to a login or registration endpoint without a maskable password field, e.g. malformed JSON,
+// is replaced as a whole since the password could be anywhere in it.
+func redactBody(path string, body []byte) string {
+	if !passwordField.Match(body) {
+		if credentialPaths[path] {
+			return "***"
+		}
+		return string(body)
+	}
+	return string(passwordField.ReplaceAll(body, []byte(`$1"***"`)))
 }
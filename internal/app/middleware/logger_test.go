@@ -0,0 +1,162 @@
+package middlware
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/ujwegh/gophermart/internal/app/logger"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// observeLogs routes logger.Log to an in-memory observer for the duration of the test.
+func observeLogs(t *testing.T, level zapcore.Level) *observer.ObservedLogs {
+	core, logs := observer.New(level)
+	previous := logger.Log
+	logger.Log = zap.New(core)
+	t.Cleanup(func() { logger.Log = previous })
+	return logs
+}
+
+// serveLogged sends body through the access log middleware and returns the body the handler received.
+func serveLogged(t *testing.T, al *AccessLogger, path string, body string) string {
+	var received string
+	handler := al.RequestLogger(al.ResponseLogger(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		raw, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		received = string(raw)
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("ok"))
+	})))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", path, strings.NewReader(body)))
+	return received
+}
+
+func TestAccessLogger_RedactsPassword(t *testing.T) {
+	logs := observeLogs(t, zapcore.DebugLevel)
+	al, err := NewAccessLogger("info", true)
+	require.NoError(t, err)
+
+	body := `{"login":"alice","password":"s3cr\"et"}`
+	received := serveLogged(t, al, "/api/user/login", body)
+
+	assert.Equal(t, body, received, "the handler must still get the original body")
+	requests := logs.FilterMessage("REQUEST:").All()
+	require.Len(t, requests, 1)
+	assert.Equal(t, zapcore.InfoLevel, requests[0].Level)
+	assert.Equal(t, `{"login":"alice","password":"***"}`, requests[0].ContextMap()["Body"])
+}
+
+func TestAccessLogger_RedactsRegistration(t *testing.T) {
+	tests := []struct {
+		name     string
+		path     string
+		body     string
+		wantBody string
+	}{
+		{
+			name:     "Registration Body",
+			path:     "/api/user/register",
+			body:     "{\n  \"login\": \"bob\",\n  \"Password\" : \"hunter2\"\n}",
+			wantBody: "{\n  \"login\": \"bob\",\n  \"Password\" : \"***\"\n}",
+		},
+		{
+			name:     "Malformed Registration Body",
+			path:     "/api/user/register",
+			body:     `{"login":"bob","password":hunter2}`,
+			wantBody: "***",
+		},
+		{
+			name:     "Form Encoded Login Body",
+			path:     "/api/user/login",
+			body:     `login=bob&password=hunter2`,
+			wantBody: "***",
+		},
+		{
+			name:     "Other Endpoint Logged As Is",
+			path:     "/api/user/balance/withdraw",
+			body:     `{"order":"2377225624","sum":751}`,
+			wantBody: `{"order":"2377225624","sum":751}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logs := observeLogs(t, zapcore.InfoLevel)
+			al, err := NewAccessLogger("info", true)
+			require.NoError(t, err)
+
+			received := serveLogged(t, al, tt.path, tt.body)
+
+			assert.Equal(t, tt.body, received)
+			requests := logs.FilterMessage("REQUEST:").All()
+			require.Len(t, requests, 1)
+			assert.Equal(t, tt.wantBody, requests[0].ContextMap()["Body"])
+			assert.NotContains(t, requests[0].ContextMap()["Body"], "hunter2")
+		})
+	}
+}
+
+func TestAccessLogger_Level(t *testing.T) {
+	tests := []struct {
+		name      string
+		level     string
+		logBodies bool
+		wantLogs  int
+		wantBody  bool
+	}{
+		{name: "Debug Level Below Logger Level", level: "debug", logBodies: true, wantLogs: 0},
+		{name: "Warn Level", level: "warn", logBodies: true, wantLogs: 2, wantBody: true},
+		{name: "Without Bodies", level: "info", logBodies: false, wantLogs: 2},
+		{name: "Off", level: AccessLogOff, logBodies: true, wantLogs: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logs := observeLogs(t, zapcore.InfoLevel)
+			al, err := NewAccessLogger(tt.level, tt.logBodies)
+			require.NoError(t, err)
+
+			received := serveLogged(t, al, "/api/user/orders", "354188083613")
+
+			assert.Equal(t, "354188083613", received)
+			require.Equal(t, tt.wantLogs, logs.Len())
+			for _, entry := range logs.All() {
+				_, hasBody := entry.ContextMap()["Body"]
+				assert.Equal(t, tt.wantBody, hasBody, entry.Message)
+			}
+		})
+	}
+}
+
+func TestAccessLogger_ClientIP(t *testing.T) {
+	logs := observeLogs(t, zapcore.InfoLevel)
+	cr, err := NewClientIPResolver([]string{"10.0.0.0/8"})
+	require.NoError(t, err)
+	al, err := NewAccessLogger("info", false)
+	require.NoError(t, err)
+	al.WithClientIPResolver(cr)
+
+	handler := al.RequestLogger(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	for _, remoteAddr := range []string{"10.1.2.3:443", "203.0.113.7:51234"} {
+		req := httptest.NewRequest("GET", "/api/user/orders", nil)
+		req.RemoteAddr = remoteAddr
+		req.Header.Set("X-Forwarded-For", "198.51.100.1")
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	requests := logs.FilterMessage("REQUEST:").All()
+	require.Len(t, requests, 2)
+	assert.Equal(t, "198.51.100.1", requests[0].ContextMap()["ClientIP"], "forwarded by a trusted proxy")
+	assert.Equal(t, "203.0.113.7", requests[1].ContextMap()["ClientIP"], "the header of an untrusted peer is ignored")
+}
+
+func TestNewAccessLogger_InvalidLevel(t *testing.T) {
+	_, err := NewAccessLogger("loud", true)
+	assert.Error(t, err)
+}
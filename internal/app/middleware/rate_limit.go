@@ -0,0 +1,78 @@
+package middlware
+
+import (
+	"github.com/patrickmn/go-cache"
+	"github.com/ujwegh/gophermart/internal/app/handlers"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// idleClientExpiration is how long the bucket of a client that stopped sending requests is kept.
+// A client that comes back later starts with a full bucket, as it would have refilled by then anyway.
+const idleClientExpiration = time.Minute
+
+// IPRateLimiter answers with 429 once a client address sends more than its limit of requests per second.
+// Each address has a token bucket holding a second's worth of requests, so short bursts up to the limit pass.
+// Addresses are resolved by the ClientIPResolver, so clients behind a trusted proxy are limited one by one,
+// while a forged X-Forwarded-For from anyone else can't dodge the limit.
+type IPRateLimiter struct {
+	perSecond int
+	clientIPs *ClientIPResolver
+	now       func() time.Time
+
+	mu      sync.Mutex
+	buckets *cache.Cache
+}
+
+type tokenBucket struct {
+	tokens    float64
+	updatedAt time.Time
+}
+
+// NewIPRateLimiter limits every client address to perSecond requests, 0 or less turns the limit off.
+func NewIPRateLimiter(perSecond int, clientIPs *ClientIPResolver) *IPRateLimiter {
+	return &IPRateLimiter{
+		perSecond: perSecond,
+		clientIPs: clientIPs,
+		now:       time.Now,
+		buckets:   cache.New(idleClientExpiration, 2*idleClientExpiration),
+	}
+}
+
+func (rl *IPRateLimiter) Handler(next http.Handler) http.Handler {
+	if rl.perSecond <= 0 {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !rl.allow(rl.clientIPs.ClientIP(r)) {
+			w.Header().Set("Retry-After", "1")
+			handlers.WriteErrorResponse(w, r, "Too many requests", http.StatusTooManyRequests)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// allow takes a token from the bucket of the client address, refilled for the time since it was last used.
+func (rl *IPRateLimiter) allow(clientIP string) bool {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	now := rl.now()
+	bucket := tokenBucket{tokens: float64(rl.perSecond), updatedAt: now}
+	if cached, found := rl.buckets.Get(clientIP); found {
+		bucket = cached.(tokenBucket)
+		bucket.tokens += now.Sub(bucket.updatedAt).Seconds() * float64(rl.perSecond)
+		if bucket.tokens > float64(rl.perSecond) {
+			bucket.tokens = float64(rl.perSecond)
+		}
+		bucket.updatedAt = now
+	}
+	allowed := bucket.tokens >= 1
+	if allowed {
+		bucket.tokens--
+	}
+	rl.buckets.Set(clientIP, bucket, cache.DefaultExpiration)
+	return allowed
+}
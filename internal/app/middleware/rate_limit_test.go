@@ -0,0 +1,64 @@
+package middlware
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestIPRateLimiter_Handler(t *testing.T) {
+	cr, err := NewClientIPResolver([]string{"10.0.0.0/8"})
+	require.NoError(t, err)
+	now := time.Date(2021, 1, 2, 0, 0, 0, 0, time.UTC)
+	rl := NewIPRateLimiter(2, cr)
+	rl.now = func() time.Time { return now }
+	handler := rl.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	send := func(remoteAddr string, forwardedFor string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/user/orders", nil)
+		req.RemoteAddr = remoteAddr
+		if forwardedFor != "" {
+			req.Header.Set("X-Forwarded-For", forwardedFor)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	assert.Equal(t, http.StatusOK, send("203.0.113.7:51234", "").Code)
+	assert.Equal(t, http.StatusOK, send("203.0.113.7:51234", "").Code)
+	w := send("203.0.113.7:51235", "")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code, "the limit applies to the address, not the connection")
+	assert.Equal(t, "1", w.Header().Get("Retry-After"))
+
+	// a forged header from an untrusted peer still counts against the peer address
+	assert.Equal(t, http.StatusTooManyRequests, send("203.0.113.7:51234", "198.51.100.1").Code)
+
+	// clients behind a trusted proxy have their own limits, the proxy has none
+	assert.Equal(t, http.StatusOK, send("10.1.2.3:443", "198.51.100.1").Code)
+	assert.Equal(t, http.StatusOK, send("10.1.2.3:443", "198.51.100.1").Code)
+	assert.Equal(t, http.StatusTooManyRequests, send("10.1.2.3:443", "198.51.100.1").Code)
+	assert.Equal(t, http.StatusOK, send("10.1.2.3:443", "198.51.100.2").Code)
+
+	// half a second later one request is allowed again
+	now = now.Add(500 * time.Millisecond)
+	assert.Equal(t, http.StatusOK, send("203.0.113.7:51234", "").Code)
+	assert.Equal(t, http.StatusTooManyRequests, send("203.0.113.7:51234", "").Code)
+}
+
+func TestIPRateLimiter_Off(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	handler := NewIPRateLimiter(0, nil).Handler(next)
+
+	for i := 0; i < 10; i++ {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/user/orders", nil))
+		assert.Equal(t, http.StatusOK, w.Code)
+	}
+}
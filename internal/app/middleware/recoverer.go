@@ -0,0 +1,33 @@
+package middlware
+
+import (
+	"github.com/ujwegh/gophermart/internal/app/handlers"
+	"github.com/ujwegh/gophermart/internal/app/logger"
+	"go.uber.org/zap"
+	"net/http"
+	"runtime/debug"
+)
+
+// Recoverer turns a panic in a downstream handler into a 500 response instead of crashing the server.
+func Recoverer(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			rvr := recover()
+			if rvr == nil {
+				return
+			}
+			if rvr == http.ErrAbortHandler {
+				// let net/http abort the connection as intended
+				panic(rvr)
+			}
+			logger.Log.Error("recovered from panic",
+				zap.Any("panic", rvr),
+				zap.String("Method", r.Method),
+				zap.String("Path", r.URL.Path),
+				zap.ByteString("stack", debug.Stack()),
+			)
+			handlers.WriteErrorResponse(w, r, "Internal Server Error", http.StatusInternalServerError)
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,34 @@
+package middlware
+
+import (
+	"github.com/ujwegh/gophermart/internal/app/handlers"
+	"net/http"
+	"sync/atomic"
+)
+
+// ShutdownGuard answers new requests with 503 once the server starts shutting down,
+// while the requests already being handled run to completion.
+type ShutdownGuard struct {
+	rejecting atomic.Bool
+}
+
+func NewShutdownGuard() *ShutdownGuard {
+	return &ShutdownGuard{}
+}
+
+// Reject makes the guard turn away every request that arrives from now on.
+func (sg *ShutdownGuard) Reject() {
+	sg.rejecting.Store(true)
+}
+
+func (sg *ShutdownGuard) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if sg.rejecting.Load() {
+			// ask keep-alive clients to reconnect, hopefully to an instance that is not going away
+			w.Header().Set("Connection", "close")
+			handlers.WriteErrorResponse(w, r, "Server is shutting down", http.StatusServiceUnavailable)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
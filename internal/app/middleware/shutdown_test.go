@@ -0,0 +1,46 @@
+package middlware
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestShutdownGuard_Handler(t *testing.T) {
+	sg := NewShutdownGuard()
+	release := make(chan struct{})
+	started := make(chan struct{})
+	h := sg.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			close(started)
+			<-release
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	inFlight := httptest.NewRecorder()
+	done := make(chan struct{})
+	go func() {
+		h.ServeHTTP(inFlight, httptest.NewRequest(http.MethodGet, "/slow", nil))
+		close(done)
+	}()
+	<-started
+
+	sg.Reject()
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, "close", w.Header().Get("Connection"))
+	assert.JSONEq(t, `{"code":503,"message":"Server is shutting down"}`, w.Body.String())
+
+	// the request that started before the shutdown still completes
+	close(release)
+	<-done
+	assert.Equal(t, http.StatusOK, inFlight.Code)
+}
@@ -0,0 +1,60 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"time"
+)
+
+type (
+	// DeviceMarker is the upload time of the newest order a user has seen on one of their devices.
+	DeviceMarker struct {
+		UserUUID   uuid.UUID `db:"user_uuid"`
+		DeviceID   string    `db:"device_id"`
+		LastSeenAt time.Time `db:"last_seen_at"`
+		UpdatedAt  time.Time `db:"updated_at"`
+	}
+	DeviceMarkerRepository interface {
+		GetMarker(ctx context.Context, userUID *uuid.UUID, deviceID string) (*DeviceMarker, error)
+		SetMarker(ctx context.Context, marker *DeviceMarker) error
+	}
+	DeviceMarkerRepositoryImpl struct {
+		db *sqlx.DB
+	}
+)
+
+// ErrDeviceMarkerNotFound is returned by GetMarker when the device has no marker yet.
+var ErrDeviceMarkerNotFound = errors.New("device marker not found")
+
+func NewDeviceMarkerRepository(db *sqlx.DB) *DeviceMarkerRepositoryImpl {
+	return &DeviceMarkerRepositoryImpl{db: db}
+}
+
+func (dr *DeviceMarkerRepositoryImpl) GetMarker(ctx context.Context, userUID *uuid.UUID, deviceID string) (*DeviceMarker, error) {
+	query := `SELECT * FROM device_markers WHERE user_uuid = $1 AND device_id = $2;`
+	marker := DeviceMarker{}
+	err := dr.db.GetContext(ctx, &marker, query, userUID, deviceID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrDeviceMarkerNotFound
+		}
+		return nil, fmt.Errorf("get device marker: %w", err)
+	}
+	return &marker, nil
+}
+
+// SetMarker creates the device's marker or moves it to marker.LastSeenAt, also backwards.
+func (dr *DeviceMarkerRepositoryImpl) SetMarker(ctx context.Context, marker *DeviceMarker) error {
+	query := `INSERT INTO device_markers (user_uuid, device_id, last_seen_at, updated_at) VALUES ($1, $2, $3, $4)
+			  ON CONFLICT (user_uuid, device_id) DO UPDATE
+			  SET last_seen_at = excluded.last_seen_at, updated_at = excluded.updated_at;`
+	_, err := dr.db.ExecContext(ctx, query, marker.UserUUID, marker.DeviceID, marker.LastSeenAt, marker.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("set device marker: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package repository
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+const initDeviceMarkerDB = `
+CREATE TABLE IF NOT EXISTS device_markers
+(
+    user_uuid    TEXT      NOT NULL,
+    device_id    TEXT      NOT NULL,
+    last_seen_at TIMESTAMP NOT NULL,
+    updated_at   TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_uuid, device_id)
+);
+`
+
+func TestDeviceMarkerRepositoryImpl_SetAndGetMarker(t *testing.T) {
+	db, err := sqlx.Open("sqlite3", "file:device_markers?mode=memory&cache=shared")
+	require.NoError(t, err)
+	defer db.Close()
+	_, err = db.Exec(initDeviceMarkerDB)
+	require.NoError(t, err)
+
+	repo := NewDeviceMarkerRepository(db)
+	userUUID, otherUUID := uuid.New(), uuid.New()
+	ctx := context.Background()
+
+	_, err = repo.GetMarker(ctx, &userUUID, "phone")
+	assert.ErrorIs(t, err, ErrDeviceMarkerNotFound)
+
+	seen := time.Date(2021, 1, 1, 12, 0, 0, 0, time.UTC)
+	require.NoError(t, repo.SetMarker(ctx, &DeviceMarker{UserUUID: userUUID, DeviceID: "phone", LastSeenAt: seen, UpdatedAt: seen}))
+	require.NoError(t, repo.SetMarker(ctx, &DeviceMarker{UserUUID: userUUID, DeviceID: "tablet", LastSeenAt: seen.Add(-time.Hour), UpdatedAt: seen}))
+
+	// setting it again moves the marker instead of adding a second one
+	later := seen.Add(time.Hour)
+	require.NoError(t, repo.SetMarker(ctx, &DeviceMarker{UserUUID: userUUID, DeviceID: "phone", LastSeenAt: later, UpdatedAt: later}))
+
+	marker, err := repo.GetMarker(ctx, &userUUID, "phone")
+	require.NoError(t, err)
+	assert.True(t, later.Equal(marker.LastSeenAt))
+	marker, err = repo.GetMarker(ctx, &userUUID, "tablet")
+	require.NoError(t, err)
+	assert.True(t, seen.Add(-time.Hour).Equal(marker.LastSeenAt))
+
+	// markers belong to the user, not just the device
+	_, err = repo.GetMarker(ctx, &otherUUID, "phone")
+	assert.ErrorIs(t, err, ErrDeviceMarkerNotFound)
+}
@@ -0,0 +1,52 @@
+package repository
+
+import (
+	"errors"
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v5/pgconn"
+	"strings"
+)
+
+// dialect covers the spots where the SQL differs between Postgres, which the service runs against,
+// and SQLite, which the tests use. Both accept $N placeholders, RETURNING and ON CONFLICT ... DO,
+// so queries are written once in Postgres syntax and only the differences below go through dialect.
+type dialect int
+
+const (
+	dialectPostgres dialect = iota
+	dialectSQLite
+)
+
+// sqliteUniqueViolation prefixes the message of a unique constraint failure in SQLite.
+const sqliteUniqueViolation = "UNIQUE constraint failed: "
+
+// dialectOf picks the dialect by the database/sql driver name of a DB or Tx.
+func dialectOf(driverName string) dialect {
+	if strings.HasPrefix(driverName, "sqlite") {
+		return dialectSQLite
+	}
+	return dialectPostgres
+}
+
+// forUpdate returns the clause locking the selected rows until the transaction ends.
+// SQLite has no row locks, it serializes writers instead, so the clause is empty there.
+func (d dialect) forUpdate() string {
+	if d == dialectSQLite {
+		return ""
+	}
+	return " FOR UPDATE"
+}
+
+// uniqueViolation reports whether err is a unique constraint violation and what was violated:
+// the constraint name on Postgres, the comma separated table.column list on SQLite.
+func uniqueViolation(err error) (string, bool) {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return pgErr.ConstraintName, pgErr.Code == pgerrcode.UniqueViolation
+	}
+	// matched by message, importing the cgo driver for its error type isn't worth it outside the tests
+	if err != nil && strings.HasPrefix(err.Error(), sqliteUniqueViolation) {
+		return strings.TrimPrefix(err.Error(), sqliteUniqueViolation), true
+	}
+	return "", false
+}
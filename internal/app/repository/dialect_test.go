@@ -0,0 +1,180 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appContext "github.com/ujwegh/gophermart/internal/app/context"
+	"testing"
+	"time"
+)
+
+func TestDialectOf(t *testing.T) {
+	assert.Equal(t, dialectSQLite, dialectOf("sqlite3"))
+	assert.Equal(t, dialectPostgres, dialectOf("pgx"))
+	assert.Equal(t, "", dialectSQLite.forUpdate())
+	assert.Equal(t, " FOR UPDATE", dialectPostgres.forUpdate())
+}
+
+func TestUniqueViolation(t *testing.T) {
+	tests := []struct {
+		name         string
+		err          error
+		wantViolated string
+		wantOK       bool
+	}{
+		{
+			name:         "postgres",
+			err:          &pgconn.PgError{Code: pgerrcode.UniqueViolation, ConstraintName: "withdrawals_order_id_idx"},
+			wantViolated: "withdrawals_order_id_idx",
+			wantOK:       true,
+		},
+		{
+			name:   "postgres other violation",
+			err:    &pgconn.PgError{Code: pgerrcode.CheckViolation, ConstraintName: "withdrawals_amount_check"},
+			wantOK: false,
+		},
+		{
+			name:         "sqlite",
+			err:          errors.New("UNIQUE constraint failed: withdrawals.user_uuid, withdrawals.idempotency_key"),
+			wantViolated: "withdrawals.user_uuid, withdrawals.idempotency_key",
+			wantOK:       true,
+		},
+		{
+			name:   "other error",
+			err:    errors.New("connection refused"),
+			wantOK: false,
+		},
+		{
+			name:   "nil",
+			wantOK: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			violated, ok := uniqueViolation(tt.err)
+			assert.Equal(t, tt.wantOK, ok)
+			if tt.wantOK {
+				assert.Equal(t, tt.wantViolated, violated)
+			}
+		})
+	}
+}
+
+func TestDialect_SQLite(t *testing.T) {
+	db, err := sqlx.Open("sqlite3", "file:dialect?mode=memory&cache=shared")
+	require.NoError(t, err)
+	defer db.Close()
+	for _, schema := range []string{initUserDB, initOrderDB, initWalletDB, initWithdrawalDB, initLeaseDB} {
+		_, err = db.Exec(schema)
+		require.NoError(t, err)
+	}
+
+	runDialectQueries(t, db)
+}
+
+func TestDialect_Postgres(t *testing.T) {
+	db := setupPostgresDB(t)
+	defer db.Close()
+
+	runDialectQueries(t, db)
+}
+
+// runDialectQueries runs the repository queries relying on dialect specific SQL, row locks,
+// ON CONFLICT, conditional upserts and unique violations, and expects the same results on both databases.
+func runDialectQueries(t *testing.T, db *sqlx.DB) {
+	ctx := appContext.WithAllTenants(context.Background())
+	now := time.Now().UTC().Truncate(time.Second)
+	user := &User{UUID: uuid.New(), Login: "dialect-" + uuid.NewString(), PasswordHash: "hash", CreatedAt: now}
+	inTx := func(fn func(tx *sqlx.Tx) error) error {
+		return WithTransaction(ctx, db, fn)
+	}
+	require.NoError(t, inTx(func(tx *sqlx.Tx) error {
+		return NewUserRepository(db).Create(ctx, tx, user)
+	}))
+	defer db.ExecContext(ctx, `DELETE FROM users WHERE uuid = $1`, user.UUID)
+
+	t.Run("duplicate user", func(t *testing.T) {
+		err := inTx(func(tx *sqlx.Tx) error {
+			return NewUserRepository(db).Create(ctx, tx, &User{UUID: uuid.New(), Login: user.Login, PasswordHash: "hash", CreatedAt: now})
+		})
+		assert.Error(t, err)
+	})
+
+	t.Run("wallet for update", func(t *testing.T) {
+		walletRepo := NewWalletRepository(db)
+		wallet := &Wallet{UserUUID: user.UUID, Credits: 100, CreatedAt: now, UpdatedAt: now}
+		var created, again bool
+		require.NoError(t, inTx(func(tx *sqlx.Tx) (err error) {
+			created, err = walletRepo.CreateWalletIfMissing(ctx, tx, wallet)
+			if err != nil {
+				return err
+			}
+			again, err = walletRepo.CreateWalletIfMissing(ctx, tx, wallet)
+			return err
+		}))
+		assert.True(t, created)
+		assert.False(t, again, "ON CONFLICT DO NOTHING should skip the existing wallet")
+
+		var locked *Wallet
+		require.NoError(t, inTx(func(tx *sqlx.Tx) (err error) {
+			locked, err = walletRepo.GetWalletForUpdate(ctx, tx, &user.UUID)
+			return err
+		}))
+		assert.Equal(t, 100.0, locked.Credits)
+	})
+
+	t.Run("order upsert and duplicate", func(t *testing.T) {
+		orderRepo := NewOrderRepository(db)
+		order := &Order{ID: "dialect-" + uuid.NewString(), UserUUID: user.UUID, Status: NEW, CreatedAt: now, UpdatedAt: now}
+		require.NoError(t, orderRepo.CreateOrder(ctx, order))
+		assert.ErrorIs(t, orderRepo.CreateOrder(ctx, order), ErrOrderExists)
+
+		accrual := 42.0
+		order.Status, order.Accrual = PROCESSED, &accrual
+		require.NoError(t, inTx(func(tx *sqlx.Tx) error {
+			return orderRepo.UpsertOrder(ctx, tx, order)
+		}))
+		stored, err := orderRepo.GetOrderByID(ctx, order.ID)
+		require.NoError(t, err)
+		assert.Equal(t, PROCESSED, stored.Status)
+		require.NotNil(t, stored.Accrual)
+		assert.Equal(t, accrual, *stored.Accrual)
+	})
+
+	t.Run("withdrawal unique violations", func(t *testing.T) {
+		withdrawalRepo := NewWithdrawalsRepository(db)
+		create := func(orderID, key string) error {
+			return inTx(func(tx *sqlx.Tx) error {
+				return withdrawalRepo.CreateWithdrawal(ctx, tx, &Withdrawal{
+					UserUUID: user.UUID, OrderID: orderID, Amount: 10_00, CreatedAt: now, IdempotencyKey: &key,
+				})
+			})
+		}
+		orderID := "dialect-" + uuid.NewString()
+		require.NoError(t, create(orderID, "first"))
+		assert.ErrorIs(t, create(orderID, "second"), ErrWithdrawalExists)
+		assert.ErrorIs(t, create("dialect-"+uuid.NewString(), "first"), ErrIdempotencyKeyUsed)
+	})
+
+	t.Run("conditional lease upsert", func(t *testing.T) {
+		leaseRepo := NewLeaseRepository(db)
+		name := "dialect-" + uuid.NewString()
+		defer db.ExecContext(ctx, `DELETE FROM leases WHERE name = $1`, name)
+
+		acquired, err := leaseRepo.TryAcquire(ctx, name, "a", now, time.Minute)
+		require.NoError(t, err)
+		assert.True(t, acquired)
+		acquired, err = leaseRepo.TryAcquire(ctx, name, "b", now.Add(time.Second), time.Minute)
+		require.NoError(t, err)
+		assert.False(t, acquired)
+		acquired, err = leaseRepo.TryAcquire(ctx, name, "b", now.Add(2*time.Minute), time.Minute)
+		require.NoError(t, err)
+		assert.True(t, acquired)
+	})
+}
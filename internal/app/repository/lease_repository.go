@@ -0,0 +1,56 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"time"
+)
+
+type (
+	// LeaseRepository coordinates work only one of several service instances should do, like a lock
+	// that expires by itself, so a crashed holder can't keep it forever.
+	LeaseRepository interface {
+		TryAcquire(ctx context.Context, name string, holder string, now time.Time, ttl time.Duration) (bool, error)
+		Release(ctx context.Context, name string, holder string) error
+	}
+	LeaseRepositoryImpl struct {
+		db *sqlx.DB
+	}
+)
+
+func NewLeaseRepository(db *sqlx.DB) *LeaseRepositoryImpl {
+	return &LeaseRepositoryImpl{db: db}
+}
+
+// TryAcquire claims the named lease for holder until now+ttl. It reports false while another holder's claim
+// hasn't expired yet, a holder may renew its own claim.
+func (lr *LeaseRepositoryImpl) TryAcquire(ctx context.Context, name string, holder string, now time.Time, ttl time.Duration) (bool, error) {
+	// the conditional upsert decides in one statement, two instances racing for the lease can't both win
+	query := `INSERT INTO leases (name, holder, expires_at) VALUES ($1, $2, $3)
+			  ON CONFLICT (name) DO UPDATE SET holder = excluded.holder, expires_at = excluded.expires_at
+			  WHERE leases.expires_at <= $4 OR leases.holder = excluded.holder
+			  RETURNING holder;`
+	now = now.UTC()
+	var acquiredBy string
+	err := lr.db.GetContext(ctx, &acquiredBy, query, name, holder, now.Add(ttl), now)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, nil
+		}
+		return false, fmt.Errorf("acquire lease %s: %w", name, err)
+	}
+	return true, nil
+}
+
+// Release gives up holder's claim on the named lease, so the next instance doesn't wait for it to expire.
+// Claims of other holders are left alone.
+func (lr *LeaseRepositoryImpl) Release(ctx context.Context, name string, holder string) error {
+	_, err := lr.db.ExecContext(ctx, `DELETE FROM leases WHERE name = $1 AND holder = $2;`, name, holder)
+	if err != nil {
+		return fmt.Errorf("release lease %s: %w", name, err)
+	}
+	return nil
+}
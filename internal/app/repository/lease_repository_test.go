@@ -0,0 +1,86 @@
+package repository
+
+import (
+	"context"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+const initLeaseDB = `
+CREATE TABLE IF NOT EXISTS leases
+(
+    name       TEXT PRIMARY KEY,
+    holder     TEXT      NOT NULL,
+    expires_at TIMESTAMP NOT NULL
+);
+`
+
+func TestLeaseRepositoryImpl_TryAcquire(t *testing.T) {
+	db, err := sqlx.Open("sqlite3", "file:leases?mode=memory&cache=shared")
+	require.NoError(t, err)
+	defer db.Close()
+	_, err = db.Exec(initLeaseDB)
+	require.NoError(t, err)
+
+	repo := NewLeaseRepository(db)
+	ctx := context.Background()
+	now := time.Date(2021, 1, 1, 12, 0, 0, 0, time.UTC)
+	ttl := time.Minute
+
+	acquired, err := repo.TryAcquire(ctx, "startup", "instance-a", now, ttl)
+	require.NoError(t, err)
+	assert.True(t, acquired, "a free lease is acquired")
+
+	acquired, err = repo.TryAcquire(ctx, "startup", "instance-b", now.Add(30*time.Second), ttl)
+	require.NoError(t, err)
+	assert.False(t, acquired, "another holder's claim is still valid")
+
+	acquired, err = repo.TryAcquire(ctx, "other", "instance-b", now.Add(30*time.Second), ttl)
+	require.NoError(t, err)
+	assert.True(t, acquired, "leases are independent by name")
+
+	acquired, err = repo.TryAcquire(ctx, "startup", "instance-a", now.Add(45*time.Second), ttl)
+	require.NoError(t, err)
+	assert.True(t, acquired, "the holder renews its own claim")
+
+	acquired, err = repo.TryAcquire(ctx, "startup", "instance-b", now.Add(90*time.Second), ttl)
+	require.NoError(t, err)
+	assert.False(t, acquired, "the renewed claim runs until 12:01:45")
+
+	acquired, err = repo.TryAcquire(ctx, "startup", "instance-b", now.Add(2*time.Minute), ttl)
+	require.NoError(t, err)
+	assert.True(t, acquired, "an expired claim is taken over")
+
+	var holder string
+	require.NoError(t, db.Get(&holder, `SELECT holder FROM leases WHERE name = 'startup'`))
+	assert.Equal(t, "instance-b", holder)
+}
+
+func TestLeaseRepositoryImpl_Release(t *testing.T) {
+	db, err := sqlx.Open("sqlite3", "file:leases_release?mode=memory&cache=shared")
+	require.NoError(t, err)
+	defer db.Close()
+	_, err = db.Exec(initLeaseDB)
+	require.NoError(t, err)
+
+	repo := NewLeaseRepository(db)
+	ctx := context.Background()
+	now := time.Date(2021, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	acquired, err := repo.TryAcquire(ctx, "startup", "instance-a", now, time.Minute)
+	require.NoError(t, err)
+	require.True(t, acquired)
+
+	require.NoError(t, repo.Release(ctx, "startup", "instance-b"))
+	acquired, err = repo.TryAcquire(ctx, "startup", "instance-b", now.Add(time.Second), time.Minute)
+	require.NoError(t, err)
+	assert.False(t, acquired, "another holder can't release the claim")
+
+	require.NoError(t, repo.Release(ctx, "startup", "instance-a"))
+	acquired, err = repo.TryAcquire(ctx, "startup", "instance-b", now.Add(time.Second), time.Minute)
+	require.NoError(t, err)
+	assert.True(t, acquired, "a released lease is free before it expires")
+}
@@ -0,0 +1,70 @@
+package repository
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"math"
+	"math/big"
+)
+
+// Cents is an amount of money in hundredths. It is written to the NUMERIC columns as an exact decimal,
+// so amounts parsed from requests reach the database without a detour through float64.
+type Cents int64
+
+// CentsOf rounds an amount read as float64, e.g. an accrual reported by the accrual system, to cents.
+func CentsOf(amount float64) Cents {
+	return Cents(math.Round(amount * 100))
+}
+
+// Float64 returns the amount in whole units, for the responses.
+func (c Cents) Float64() float64 {
+	return float64(c) / 100
+}
+
+// String formats the amount as a decimal with two places, e.g. "100.10".
+func (c Cents) String() string {
+	sign, abs := "", int64(c)
+	if abs < 0 {
+		sign, abs = "-", -abs
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, abs/100, abs%100)
+}
+
+// Value implements driver.Valuer, the decimal text is taken by NUMERIC columns without rounding.
+func (c Cents) Value() (driver.Value, error) {
+	return c.String(), nil
+}
+
+// Scan implements sql.Scanner. Postgres returns NUMERIC as text, which is converted exactly;
+// drivers returning numbers, like sqlite in the tests, are rounded to cents.
+func (c *Cents) Scan(src any) error {
+	switch value := src.(type) {
+	case int64:
+		*c = Cents(value * 100)
+	case float64:
+		*c = CentsOf(value)
+	case []byte:
+		return c.scanDecimal(string(value))
+	case string:
+		return c.scanDecimal(value)
+	default:
+		return fmt.Errorf("scan cents from %T", src)
+	}
+	return nil
+}
+
+func (c *Cents) scanDecimal(value string) error {
+	amount, ok := new(big.Rat).SetString(value)
+	if !ok {
+		return fmt.Errorf("scan cents from %q", value)
+	}
+	amount.Mul(amount, big.NewRat(100, 1))
+	if amount.IsInt() && amount.Num().IsInt64() {
+		*c = Cents(amount.Num().Int64())
+		return nil
+	}
+	// more than two decimal places, e.g. an average computed by the database
+	f, _ := amount.Float64()
+	*c = Cents(math.Round(f))
+	return nil
+}
@@ -0,0 +1,57 @@
+package repository
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestCents_Value(t *testing.T) {
+	tests := []struct {
+		cents Cents
+		want  string
+	}{
+		{cents: 100_10, want: "100.10"},
+		{cents: 1, want: "0.01"},
+		{cents: 0, want: "0.00"},
+		{cents: -40_05, want: "-40.05"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			got, err := tt.cents.Value()
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+			assert.InDelta(t, float64(tt.cents)/100, tt.cents.Float64(), 1e-9)
+		})
+	}
+}
+
+func TestCents_Scan(t *testing.T) {
+	tests := []struct {
+		name    string
+		src     any
+		want    Cents
+		wantErr bool
+	}{
+		{name: "numeric text", src: []byte("100.10"), want: 100_10},
+		{name: "numeric text with scale", src: "120.5000000000000000", want: 120_50},
+		{name: "rounded text", src: "0.005", want: 1},
+		{name: "integer", src: int64(7), want: 7_00},
+		{name: "float", src: 100.1, want: 100_10},
+		{name: "negative float", src: -0.29, want: -29},
+		{name: "not a number", src: "abc", wantErr: true},
+		{name: "null", src: nil, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got Cents
+			err := got.Scan(tt.src)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
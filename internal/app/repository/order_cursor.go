@@ -0,0 +1,44 @@
+package repository
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// OrderCursor points at the last order of a page in the created_at desc, id desc order of the user's orders.
+// Clients get it as an opaque token, so the format can change without breaking them.
+type OrderCursor struct {
+	CreatedAt time.Time
+	ID        string
+}
+
+// NewOrderCursor returns the cursor of the page ending with order.
+func NewOrderCursor(order Order) OrderCursor {
+	return OrderCursor{CreatedAt: order.CreatedAt, ID: order.ID}
+}
+
+// Encode returns the cursor as a URL safe token.
+func (c OrderCursor) Encode() string {
+	raw := c.CreatedAt.Format(time.RFC3339Nano) + "|" + c.ID
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// DecodeOrderCursor parses a token produced by OrderCursor.Encode.
+func DecodeOrderCursor(token string) (OrderCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return OrderCursor{}, fmt.Errorf("decode cursor: %w", err)
+	}
+	createdAt, id, found := strings.Cut(string(raw), "|")
+	if !found || id == "" {
+		return OrderCursor{}, errors.New("decode cursor: malformed token")
+	}
+	t, err := time.Parse(time.RFC3339Nano, createdAt)
+	if err != nil {
+		return OrderCursor{}, fmt.Errorf("decode cursor: %w", err)
+	}
+	return OrderCursor{CreatedAt: t, ID: id}, nil
+}
@@ -0,0 +1,59 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"time"
+)
+
+type (
+	OrderStatusChange struct {
+		ID        int64     `db:"id"`
+		OrderID   string    `db:"order_id"`
+		Status    Status    `db:"status"`
+		Accrual   *float64  `db:"accrual"`
+		ChangedAt time.Time `db:"changed_at"`
+	}
+	OrderHistoryRepository interface {
+		AddStatusChange(ctx context.Context, tx *sqlx.Tx, change *OrderStatusChange) error
+		GetStatusHistory(ctx context.Context, orderID string) (*[]OrderStatusChange, error)
+	}
+	OrderHistoryRepositoryImpl struct {
+		db *sqlx.DB
+	}
+)
+
+func NewOrderHistoryRepository(db *sqlx.DB) *OrderHistoryRepositoryImpl {
+	return &OrderHistoryRepositoryImpl{db: db}
+}
+
+func (hr *OrderHistoryRepositoryImpl) AddStatusChange(ctx context.Context, tx *sqlx.Tx, change *OrderStatusChange) error {
+	query := `INSERT INTO order_status_history (order_id, status, accrual, changed_at) VALUES ($1, $2, $3, $4);`
+	stmt, err := tx.PrepareContext(ctx, query)
+	if err != nil {
+		return fmt.Errorf("prepare statement: %w", err)
+	}
+	defer stmt.Close()
+
+	_, err = stmt.ExecContext(ctx, change.OrderID, change.Status.String(), change.Accrual, change.ChangedAt)
+	if err != nil {
+		return fmt.Errorf("exec statement: %w", err)
+	}
+	return nil
+}
+
+func (hr *OrderHistoryRepositoryImpl) GetStatusHistory(ctx context.Context, orderID string) (*[]OrderStatusChange, error) {
+	query := `SELECT * FROM order_status_history WHERE order_id = $1 order by changed_at, id;`
+	history := make([]OrderStatusChange, 0)
+	err := hr.db.SelectContext(ctx, &history, query, orderID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return &history, nil
+		}
+		return nil, fmt.Errorf("read order status history: %w", err)
+	}
+	return &history, nil
+}
@@ -0,0 +1,90 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+const initOrderHistoryDB = `
+CREATE TABLE IF NOT EXISTS order_status_history
+(
+    id INTEGER PRIMARY KEY,
+    order_id VARCHAR NOT NULL,
+    status TEXT NOT NULL,
+    accrual NUMERIC,
+    changed_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+`
+
+func setupInMemoryOrderHistoryDB(t *testing.T) *sqlx.DB {
+	// a database of its own per test, so no other test sees the history rows
+	db, err := sqlx.Open("sqlite3", fmt.Sprintf("file:order_history_%s?mode=memory&cache=shared", t.Name()))
+	if err != nil {
+		t.Fatalf("could not create in-memory db: %v", err)
+	}
+	_, err = db.Exec(initOrderHistoryDB)
+	if err != nil {
+		t.Fatalf("could not create order history table: %v", err)
+	}
+	return db
+}
+
+func TestOrderHistoryRepositoryImpl_AddStatusChange(t *testing.T) {
+	db := setupInMemoryOrderHistoryDB(t)
+	defer db.Close()
+
+	repo := NewOrderHistoryRepository(db)
+	accrual := 500.0
+	base := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	changes := []*OrderStatusChange{
+		{OrderID: "order1", Status: PROCESSED, Accrual: &accrual, ChangedAt: base.Add(2 * time.Minute)},
+		{OrderID: "order1", Status: PROCESSING, ChangedAt: base.Add(time.Minute)},
+		{OrderID: "order2", Status: INVALID, ChangedAt: base},
+	}
+	for _, change := range changes {
+		tx, err := db.Beginx()
+		require.NoError(t, err)
+		assert.NoError(t, repo.AddStatusChange(context.Background(), tx, change), "AddStatusChange should not fail")
+		require.NoError(t, tx.Commit())
+	}
+
+	tests := []struct {
+		name         string
+		orderID      string
+		wantStatuses []Status
+	}{
+		{
+			name:         "Ordered Transitions",
+			orderID:      "order1",
+			wantStatuses: []Status{PROCESSING, PROCESSED},
+		},
+		{
+			name:         "Single Transition",
+			orderID:      "order2",
+			wantStatuses: []Status{INVALID},
+		},
+		{
+			name:         "No Transitions",
+			orderID:      "order3",
+			wantStatuses: []Status{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := repo.GetStatusHistory(context.Background(), tt.orderID)
+			require.NoError(t, err, "GetStatusHistory should not fail")
+
+			statuses := make([]Status, 0, len(*got))
+			for _, change := range *got {
+				statuses = append(statuses, change.Status)
+			}
+			assert.Equal(t, tt.wantStatuses, statuses, "Unexpected status timeline")
+		})
+	}
+}
@@ -18,28 +18,78 @@ type (
 		UserUUID  uuid.UUID `db:"user_uuid"`
 		Status    Status    `db:"status"`
 		Accrual   *float64  `db:"accrual"`
+		Attempts  int       `db:"attempts"`
 		CreatedAt time.Time `db:"created_at"`
 		UpdatedAt time.Time `db:"updated_at"`
+		// LookupFailures counts the accrual lookups that failed without an answer, e.g. on network or parse errors
+		LookupFailures int `db:"lookup_failures"`
+		// FailureReason tells why the order was marked INVALID, nil for orders in other statuses
+		FailureReason *string `db:"failure_reason"`
+		// TenantID is the tenant of the user who uploaded the order
+		TenantID string `db:"tenant_id"`
+		// ArchivedAt is when the retention job archived the PROCESSED order, nil while the user's order list shows it
+		ArchivedAt *time.Time `db:"archived_at"`
+	}
+	// UserOrder is an order together with the login of the user who uploaded it.
+	UserOrder struct {
+		Order
+		Login string `db:"login"`
+	}
+	// AccrualCorrection records an admin correcting the accrual of a PROCESSED order.
+	AccrualCorrection struct {
+		ID              int64     `db:"id"`
+		OrderID         string    `db:"order_id"`
+		UserUUID        uuid.UUID `db:"user_uuid"`
+		PreviousAccrual Cents     `db:"previous_accrual"`
+		Accrual         Cents     `db:"accrual"`
+		Delta           Cents     `db:"delta"`
+		CreatedAt       time.Time `db:"created_at"`
 	}
 	Status          string
 	OrderRepository interface {
 		CreateOrder(ctx context.Context, order *Order) error
+		CreateOrderTx(ctx context.Context, tx *sqlx.Tx, order *Order) error
 		GetOrderByID(ctx context.Context, orderID string) (*Order, error)
-		GetOrdersByUserUID(ctx context.Context, userUID *uuid.UUID) (*[]Order, error)
+		GetOrderByIDTx(ctx context.Context, tx *sqlx.Tx, orderID string) (*Order, error)
+		GetOrdersByIDs(ctx context.Context, orderIDs []string) (*[]Order, error)
+		GetOrdersByUserUID(ctx context.Context, userUID *uuid.UUID, limit int, offset int) (*[]Order, error)
+		GetOrdersAfterCursor(ctx context.Context, userUID *uuid.UUID, cursor *OrderCursor, limit int) (*[]Order, error)
+		GetOrdersSince(ctx context.Context, userUID *uuid.UUID, since time.Time, limit int, offset int) (*[]Order, error)
+		CountOrdersByUserUID(ctx context.Context, userUID *uuid.UUID) (int, error)
+		GetProcessedOrdersByUserUID(ctx context.Context, userUID *uuid.UUID, limit int, offset int) (*[]Order, error)
+		GetOrdersInRange(ctx context.Context, from time.Time, to time.Time, limit int, offset int) (*[]UserOrder, error)
+		GetDeadLetterOrders(ctx context.Context, limit int, offset int) (*[]UserOrder, error)
 		UpdateOrder(ctx context.Context, tx *sqlx.Tx, order *Order) error
+		UpsertOrder(ctx context.Context, tx *sqlx.Tx, order *Order) error
+		CreateAccrualCorrection(ctx context.Context, tx *sqlx.Tx, correction *AccrualCorrection) error
+		IncrementAttempts(ctx context.Context, tx *sqlx.Tx, orderID string) (int, error)
+		IncrementLookupFailures(ctx context.Context, tx *sqlx.Tx, orderID string) (int, error)
+		ResetOrder(ctx context.Context, tx *sqlx.Tx, orderID string, updatedAt time.Time) error
 		CountUnprocessedOrders() (int, error)
 		GetUnprocessedOrders(limit int, offset int) (*[]Order, error)
+		SumProcessedAccruals(ctx context.Context, userUID *uuid.UUID) (float64, error)
+		SumPendingAccruals(ctx context.Context, userUID *uuid.UUID) (float64, error)
+		ArchiveProcessedOrdersBefore(ctx context.Context, t time.Time, archivedAt time.Time) (int64, error)
 		GetDB() *sqlx.DB
 	}
 	OrderRepositoryImpl struct {
-		db *sqlx.DB
+		db     *sqlx.DB
+		readDB *sqlx.DB
 	}
 )
 
+// ErrOrderExists is returned by CreateOrder when an order with the same number was inserted concurrently.
+var ErrOrderExists = errors.New("order already exists")
+
 func (s Status) String() string {
 	return string(s)
 }
 
+// IsFinal reports whether the accrual system will not change the status anymore.
+func (s Status) IsFinal() bool {
+	return s == INVALID || s == PROCESSED
+}
+
 const (
 	NEW        Status = "NEW"
 	PROCESSING Status = "PROCESSING"
@@ -47,46 +97,119 @@ const (
 	PROCESSED  Status = "PROCESSED"
 )
 
+// Description tells what an order in the status is waiting for, empty for an unknown status.
+func (s Status) Description() string {
+	switch s {
+	case NEW:
+		return "The order is uploaded but not processed yet"
+	case PROCESSING:
+		return "The accrual system is calculating the reward for the order"
+	case INVALID:
+		return "The accrual system declined the order, no reward will be credited"
+	case PROCESSED:
+		return "The reward for the order is calculated and credited"
+	}
+	return ""
+}
+
+// Statuses lists every order status in the order an order goes through them.
+func Statuses() []Status {
+	return []Status{NEW, PROCESSING, INVALID, PROCESSED}
+}
+
 func NewOrderRepository(db *sqlx.DB) *OrderRepositoryImpl {
-	return &OrderRepositoryImpl{db: db}
+	return &OrderRepositoryImpl{db: db, readDB: db}
+}
+
+// WithReadDB sends the read-heavy listing queries to readDB, e.g. a replica; writes keep using the primary.
+func (or *OrderRepositoryImpl) WithReadDB(readDB *sqlx.DB) *OrderRepositoryImpl {
+	or.readDB = readDB
+	return or
 }
 
 func (or *OrderRepositoryImpl) CreateOrder(ctx context.Context, order *Order) error {
-	tx, err := or.db.BeginTx(ctx, nil)
-	if err != nil {
-		return fmt.Errorf("begin transaction: %w", err)
-	}
-	query := `INSERT INTO orders (id, user_uuid, status, created_at, updated_at) VALUES ($1, $2, $3, $4, $5);`
-	stmt, err := tx.PrepareContext(ctx, query)
-	if err != nil {
-		return err
-	}
-	defer stmt.Close()
+	return WithTransaction(ctx, or.db, func(tx *sqlx.Tx) error {
+		return or.CreateOrderTx(ctx, tx, order)
+	})
+}
 
-	_, err = stmt.ExecContext(ctx, order.ID, order.UserUUID, order.Status.String(), order.CreatedAt, order.UpdatedAt)
+// CreateOrderTx inserts the order within tx. ErrOrderExists leaves tx unusable on Postgres, it has to be rolled back.
+func (or *OrderRepositoryImpl) CreateOrderTx(ctx context.Context, tx *sqlx.Tx, order *Order) error {
+	query := `INSERT INTO orders (id, user_uuid, status, created_at, updated_at, tenant_id) VALUES ($1, $2, $3, $4, $5, $6);`
+	_, err := tx.ExecContext(ctx, query, order.ID, order.UserUUID, order.Status.String(), order.CreatedAt, order.UpdatedAt, order.TenantID)
 	if err != nil {
-		if err := tx.Rollback(); err != nil {
-			return fmt.Errorf("rollback transaction: %w", err)
+		if _, ok := uniqueViolation(err); ok {
+			return ErrOrderExists
 		}
-		return err
+		return fmt.Errorf("insert order: %w", err)
 	}
-	return tx.Commit()
+	return nil
 }
 
+// GetOrderByID reads the order if it belongs to the tenant of ctx. Order numbers are unique across tenants,
+// the accrual system knows a single one, so orders of other tenants are merely hidden.
 func (or *OrderRepositoryImpl) GetOrderByID(ctx context.Context, orderID string) (*Order, error) {
-	query := `SELECT * FROM orders WHERE id = $1;`
+	scope, scopeArgs, err := tenantCondition(ctx, "tenant_id", 2)
+	if err != nil {
+		return nil, fmt.Errorf("read order: %w", err)
+	}
+	query := `SELECT * FROM orders WHERE id = $1` + scope + `;`
 	order := &Order{}
-	err := or.db.GetContext(ctx, order, query, orderID)
+	err = or.db.GetContext(ctx, order, query, append([]interface{}{orderID}, scopeArgs...)...)
 	if err != nil {
 		return nil, appErrors.NewWithCode(err, "Order not found", http.StatusNotFound)
 	}
 	return order, nil
 }
 
-func (or *OrderRepositoryImpl) GetOrdersByUserUID(ctx context.Context, userUID *uuid.UUID) (*[]Order, error) {
-	query := `SELECT * FROM orders WHERE user_uuid = $1 order by created_at desc;`
+// GetOrderByIDTx reads the order within tx, so it sees the writes tx has made so far.
+func (or *OrderRepositoryImpl) GetOrderByIDTx(ctx context.Context, tx *sqlx.Tx, orderID string) (*Order, error) {
+	scope, scopeArgs, err := tenantCondition(ctx, "tenant_id", 2)
+	if err != nil {
+		return nil, fmt.Errorf("read order: %w", err)
+	}
+	query := `SELECT * FROM orders WHERE id = $1` + scope + `;`
+	order := &Order{}
+	err = tx.GetContext(ctx, order, query, append([]interface{}{orderID}, scopeArgs...)...)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, appErrors.NewWithCode(err, "Order not found", http.StatusNotFound)
+		}
+		return nil, fmt.Errorf("read order: %w", err)
+	}
+	return order, nil
+}
+
+// GetOrdersByIDs returns the orders with the given numbers in a single query. Unknown numbers are skipped,
+// so the result may be shorter than orderIDs; no order is guaranteed.
+func (or *OrderRepositoryImpl) GetOrdersByIDs(ctx context.Context, orderIDs []string) (*[]Order, error) {
+	orders := make([]Order, 0, len(orderIDs))
+	if len(orderIDs) == 0 {
+		return &orders, nil
+	}
+	tenantID, scoped, err := tenantScope(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("read orders: %w", err)
+	}
+	query, args, err := sqlx.In(`SELECT * FROM orders WHERE id IN (?)`, orderIDs)
+	if err != nil {
+		return nil, fmt.Errorf("build orders query: %w", err)
+	}
+	if scoped {
+		query += ` AND tenant_id = ?`
+		args = append(args, tenantID)
+	}
+	err = or.db.SelectContext(ctx, &orders, or.db.Rebind(query), args...)
+	if err != nil {
+		return nil, fmt.Errorf("read orders: %w", err)
+	}
+	return &orders, nil
+}
+
+func (or *OrderRepositoryImpl) GetOrdersByUserUID(ctx context.Context, userUID *uuid.UUID, limit int, offset int) (*[]Order, error) {
+	query := `SELECT * FROM orders WHERE user_uuid = $1 AND archived_at IS NULL order by created_at desc, id desc limit $2 offset $3;`
 	orders := make([]Order, 0)
-	err := or.db.SelectContext(ctx, &orders, query, userUID)
+	err := or.readDB.SelectContext(ctx, &orders, query, userUID, limit, offset)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return &orders, nil
@@ -96,6 +219,60 @@ func (or *OrderRepositoryImpl) GetOrdersByUserUID(ctx context.Context, userUID *
 	return &orders, nil
 }
 
+// GetOrdersAfterCursor returns up to limit of the user's orders that come after cursor, newest first.
+// A nil cursor starts from the newest order. Unlike offsets, a cursor is not shifted by orders added meanwhile.
+func (or *OrderRepositoryImpl) GetOrdersAfterCursor(ctx context.Context, userUID *uuid.UUID, cursor *OrderCursor, limit int) (*[]Order, error) {
+	orders := make([]Order, 0)
+	var err error
+	if cursor == nil {
+		query := `SELECT * FROM orders WHERE user_uuid = $1 AND archived_at IS NULL order by created_at desc, id desc limit $2;`
+		err = or.readDB.SelectContext(ctx, &orders, query, userUID, limit)
+	} else {
+		query := `SELECT * FROM orders WHERE user_uuid = $1 AND archived_at IS NULL AND (created_at < $2 OR (created_at = $2 AND id < $3))
+			order by created_at desc, id desc limit $4;`
+		err = or.readDB.SelectContext(ctx, &orders, query, userUID, cursor.CreatedAt, cursor.ID, limit)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read user orders after cursor: %w", err)
+	}
+	return &orders, nil
+}
+
+// GetOrdersSince returns the user's orders uploaded after since, newest first.
+func (or *OrderRepositoryImpl) GetOrdersSince(ctx context.Context, userUID *uuid.UUID, since time.Time, limit int, offset int) (*[]Order, error) {
+	query := `SELECT * FROM orders WHERE user_uuid = $1 AND archived_at IS NULL AND created_at > $2 order by created_at desc, id desc limit $3 offset $4;`
+	orders := make([]Order, 0)
+	err := or.readDB.SelectContext(ctx, &orders, query, userUID, since, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("read user orders since %s: %w", since, err)
+	}
+	return &orders, nil
+}
+
+func (or *OrderRepositoryImpl) CountOrdersByUserUID(ctx context.Context, userUID *uuid.UUID) (int, error) {
+	query := `SELECT count(*) FROM orders WHERE user_uuid = $1 AND archived_at IS NULL;`
+	var count int
+	err := or.readDB.GetContext(ctx, &count, query, userUID)
+	if err != nil {
+		return 0, fmt.Errorf("count user orders: %w", err)
+	}
+	return count, nil
+}
+
+// GetProcessedOrdersByUserUID returns the user's PROCESSED orders, oldest processed first.
+func (or *OrderRepositoryImpl) GetProcessedOrdersByUserUID(ctx context.Context, userUID *uuid.UUID, limit int, offset int) (*[]Order, error) {
+	query := `SELECT * FROM orders WHERE user_uuid = $1 AND status = 'PROCESSED' order by updated_at limit $2 offset $3;`
+	orders := make([]Order, 0)
+	err := or.readDB.SelectContext(ctx, &orders, query, userUID, limit, offset)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return &orders, nil
+		}
+		return nil, fmt.Errorf("read processed user orders: %w", err)
+	}
+	return &orders, nil
+}
+
 func (or *OrderRepositoryImpl) UpdateOrder(ctx context.Context, tx *sqlx.Tx, order *Order) error {
 	query := `UPDATE orders SET status = $1, accrual = $2, updated_at = $3 WHERE id = $4`
 	stmt, err := tx.PrepareContext(ctx, query)
@@ -111,8 +288,102 @@ func (or *OrderRepositoryImpl) UpdateOrder(ctx context.Context, tx *sqlx.Tx, ord
 	return nil
 }
 
+// UpsertOrder stores the status, accrual, failure reason and update time of the order in one statement, inserting the
+// whole order when its row doesn't exist yet, so a write racing the order creation isn't lost.
+func (or *OrderRepositoryImpl) UpsertOrder(ctx context.Context, tx *sqlx.Tx, order *Order) error {
+	query := `INSERT INTO orders (id, user_uuid, status, accrual, attempts, created_at, updated_at, tenant_id, failure_reason)
+			  VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+			  ON CONFLICT (id) DO UPDATE SET status = excluded.status, accrual = excluded.accrual,
+			  updated_at = excluded.updated_at, failure_reason = excluded.failure_reason;`
+	_, err := tx.ExecContext(ctx, query, order.ID, order.UserUUID, order.Status.String(), order.Accrual,
+		order.Attempts, order.CreatedAt, order.UpdatedAt, order.TenantID, order.FailureReason)
+	if err != nil {
+		return fmt.Errorf("upsert order: %w", err)
+	}
+	return nil
+}
+
+func (or *OrderRepositoryImpl) CreateAccrualCorrection(ctx context.Context, tx *sqlx.Tx, correction *AccrualCorrection) error {
+	query := `INSERT INTO accrual_corrections (order_id, user_uuid, previous_accrual, accrual, delta, created_at)
+			  VALUES ($1, $2, $3, $4, $5, $6) returning id;`
+	err := tx.GetContext(ctx, &correction.ID, query, correction.OrderID, correction.UserUUID,
+		correction.PreviousAccrual, correction.Accrual, correction.Delta, correction.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("create accrual correction: %w", err)
+	}
+	return nil
+}
+
+// IncrementAttempts bumps the number of accrual lookups made for the order and returns the new count.
+func (or *OrderRepositoryImpl) IncrementAttempts(ctx context.Context, tx *sqlx.Tx, orderID string) (int, error) {
+	query := `UPDATE orders SET attempts = attempts + 1 WHERE id = $1 RETURNING attempts;`
+	var attempts int
+	err := tx.GetContext(ctx, &attempts, query, orderID)
+	if err != nil {
+		return 0, fmt.Errorf("increment attempts: %w", err)
+	}
+	return attempts, nil
+}
+
+// IncrementLookupFailures bumps the number of failed accrual lookups of the order and returns the new count.
+func (or *OrderRepositoryImpl) IncrementLookupFailures(ctx context.Context, tx *sqlx.Tx, orderID string) (int, error) {
+	query := `UPDATE orders SET lookup_failures = lookup_failures + 1 WHERE id = $1 RETURNING lookup_failures;`
+	var failures int
+	err := tx.GetContext(ctx, &failures, query, orderID)
+	if err != nil {
+		return 0, fmt.Errorf("increment lookup failures: %w", err)
+	}
+	return failures, nil
+}
+
+func (or *OrderRepositoryImpl) ResetOrder(ctx context.Context, tx *sqlx.Tx, orderID string, updatedAt time.Time) error {
+	query := `UPDATE orders SET status = 'NEW', accrual = NULL, attempts = 0, lookup_failures = 0, failure_reason = NULL, updated_at = $1 WHERE id = $2`
+	_, err := tx.ExecContext(ctx, query, updatedAt, orderID)
+	if err != nil {
+		return fmt.Errorf("reset order: %w", err)
+	}
+	return nil
+}
+
+// GetOrdersInRange returns orders of all users of the tenant of ctx created in [from, to), oldest first.
+func (or *OrderRepositoryImpl) GetOrdersInRange(ctx context.Context, from time.Time, to time.Time, limit int, offset int) (*[]UserOrder, error) {
+	scope, scopeArgs, err := tenantCondition(ctx, "o.tenant_id", 3)
+	if err != nil {
+		return nil, fmt.Errorf("read orders in range: %w", err)
+	}
+	args := append([]interface{}{from, to}, scopeArgs...)
+	query := fmt.Sprintf(`SELECT o.*, u.login FROM orders o JOIN users u ON u.uuid = o.user_uuid
+		WHERE o.created_at >= $1 AND o.created_at < $2%s order by o.created_at limit $%d offset $%d;`, scope, len(args)+1, len(args)+2)
+	orders := make([]UserOrder, 0)
+	err = or.readDB.SelectContext(ctx, &orders, query, append(args, limit, offset)...)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return &orders, nil
+		}
+		return nil, fmt.Errorf("read orders in range: %w", err)
+	}
+	return &orders, nil
+}
+
+// GetDeadLetterOrders returns the INVALID orders of all users of the tenant of ctx together with why they failed,
+// the longest failed first, so they can be inspected and requeued.
+func (or *OrderRepositoryImpl) GetDeadLetterOrders(ctx context.Context, limit int, offset int) (*[]UserOrder, error) {
+	scope, scopeArgs, err := tenantCondition(ctx, "o.tenant_id", 1)
+	if err != nil {
+		return nil, fmt.Errorf("read dead letter orders: %w", err)
+	}
+	query := fmt.Sprintf(`SELECT o.*, u.login FROM orders o JOIN users u ON u.uuid = o.user_uuid
+		WHERE o.status = 'INVALID'%s order by o.updated_at, o.id limit $%d offset $%d;`, scope, len(scopeArgs)+1, len(scopeArgs)+2)
+	orders := make([]UserOrder, 0)
+	err = or.readDB.SelectContext(ctx, &orders, query, append(scopeArgs, limit, offset)...)
+	if err != nil {
+		return nil, fmt.Errorf("read dead letter orders: %w", err)
+	}
+	return &orders, nil
+}
+
 func (or *OrderRepositoryImpl) CountUnprocessedOrders() (int, error) {
-	query := `SELECT count(*) FROM orders WHERE status = 'NEW' or status = 'PROCESSING'`
+	query := `SELECT count(*) FROM orders WHERE status IN ('NEW', 'PROCESSING')`
 	var count int
 	err := or.db.Get(&count, query)
 	if err != nil {
@@ -122,7 +393,8 @@ func (or *OrderRepositoryImpl) CountUnprocessedOrders() (int, error) {
 }
 
 func (or *OrderRepositoryImpl) GetUnprocessedOrders(limit int, offset int) (*[]Order, error) {
-	query := `SELECT * FROM orders WHERE status = 'NEW' or status = 'PROCESSING' limit $1 offset $2`
+	// the predicate matches the orders_unprocessed_idx partial index
+	query := `SELECT * FROM orders WHERE status IN ('NEW', 'PROCESSING') limit $1 offset $2`
 	orders := make([]Order, 0)
 	err := or.db.Select(&orders, query, limit, offset)
 	if err != nil {
@@ -134,6 +406,40 @@ func (or *OrderRepositoryImpl) GetUnprocessedOrders(limit int, offset int) (*[]O
 	return &orders, nil
 }
 
+func (or *OrderRepositoryImpl) SumProcessedAccruals(ctx context.Context, userUID *uuid.UUID) (float64, error) {
+	query := `SELECT COALESCE(SUM(accrual), 0) FROM orders WHERE user_uuid = $1 AND status = 'PROCESSED';`
+	var sum float64
+	err := or.db.GetContext(ctx, &sum, query, userUID)
+	if err != nil {
+		return 0, fmt.Errorf("sum processed accruals: %w", err)
+	}
+	return sum, nil
+}
+
+// SumPendingAccruals sums the accruals already reported for the user's PROCESSING orders.
+// They are not credited to the wallet until the orders are PROCESSED.
+func (or *OrderRepositoryImpl) SumPendingAccruals(ctx context.Context, userUID *uuid.UUID) (float64, error) {
+	query := `SELECT COALESCE(SUM(accrual), 0) FROM orders WHERE user_uuid = $1 AND status = 'PROCESSING';`
+	var sum float64
+	err := or.readDB.GetContext(ctx, &sum, query, userUID)
+	if err != nil {
+		return 0, fmt.Errorf("sum pending accruals: %w", err)
+	}
+	return sum, nil
+}
+
+// ArchiveProcessedOrdersBefore archives PROCESSED orders last updated before t and returns how many were archived.
+// Archived orders are left out of the user's order list only: they stay stored, so their numbers can't be uploaded
+// again, their status history is kept and the accruals already credited still add up in reconciliations.
+func (or *OrderRepositoryImpl) ArchiveProcessedOrdersBefore(ctx context.Context, t time.Time, archivedAt time.Time) (int64, error) {
+	query := `UPDATE orders SET archived_at = $1 WHERE status = 'PROCESSED' AND updated_at < $2 AND archived_at IS NULL;`
+	result, err := or.db.ExecContext(ctx, query, archivedAt, t)
+	if err != nil {
+		return 0, fmt.Errorf("archive processed orders: %w", err)
+	}
+	return result.RowsAffected()
+}
+
 func (or *OrderRepositoryImpl) GetDB() *sqlx.DB {
 	return or.db
 }
@@ -0,0 +1,45 @@
+package repository
+
+import (
+	"context"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/ujwegh/gophermart/migrations"
+	"os"
+	"strings"
+	"testing"
+)
+
+// setupPostgresDB connects to the database from TEST_DATABASE_URI and applies all migrations.
+// Tests using it are skipped when the variable is not set.
+func setupPostgresDB(t *testing.T) *sqlx.DB {
+	dsn := os.Getenv("TEST_DATABASE_URI")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URI is not set")
+	}
+	db, err := sqlx.Open("pgx", dsn)
+	require.NoError(t, err)
+	require.NoError(t, MigrateFS(db, migrations.FS, "."))
+	return db
+}
+
+func TestOrderRepositoryImpl_GetUnprocessedOrders_UsesPartialIndex(t *testing.T) {
+	db := setupPostgresDB(t)
+	defer db.Close()
+
+	ctx := context.Background()
+	conn, err := db.Connx(ctx)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	// an empty table is always cheapest to scan sequentially, so rule that out to see whether the index applies
+	_, err = conn.ExecContext(ctx, `SET enable_seqscan = off`)
+	require.NoError(t, err)
+	defer conn.ExecContext(ctx, `RESET enable_seqscan`)
+
+	var plan []string
+	err = conn.SelectContext(ctx, &plan, `EXPLAIN SELECT * FROM orders WHERE status IN ('NEW', 'PROCESSING') limit 10 offset 0`)
+	require.NoError(t, err)
+	assert.Contains(t, strings.Join(plan, "\n"), "orders_unprocessed_idx")
+}
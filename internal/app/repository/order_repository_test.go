@@ -2,11 +2,13 @@ package repository
 
 import (
 	"context"
+	"fmt"
 	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
 	_ "github.com/mattn/go-sqlite3"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	appContext "github.com/ujwegh/gophermart/internal/app/context"
 	"testing"
 	"time"
 )
@@ -18,8 +20,13 @@ CREATE TABLE IF NOT EXISTS orders
     user_uuid VARCHAR NOT NULL,
     status TEXT NOT NULL DEFAULT 'NEW',
     accrual NUMERIC,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    lookup_failures INTEGER NOT NULL DEFAULT 0,
+    failure_reason TEXT,
+    archived_at TIMESTAMP,
     created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
     updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    tenant_id TEXT NOT NULL DEFAULT '',
     CHECK (accrual > 0)
 );
 `
@@ -36,6 +43,20 @@ func setupInMemoryOrderDB(t *testing.T) *sqlx.DB {
 	return db
 }
 
+// setupPrimaryAndReplica opens two separate in-memory databases with the same schema,
+// so a test can tell which one a query was sent to.
+func setupPrimaryAndReplica(t *testing.T, name string, schema string) (*sqlx.DB, *sqlx.DB) {
+	var dbs []*sqlx.DB
+	for _, role := range []string{"primary", "replica"} {
+		db, err := sqlx.Open("sqlite3", fmt.Sprintf("file:%s_%s?mode=memory&cache=shared", name, role))
+		require.NoError(t, err)
+		_, err = db.Exec(schema)
+		require.NoError(t, err)
+		dbs = append(dbs, db)
+	}
+	return dbs[0], dbs[1]
+}
+
 func TestOrderRepositoryImpl_CountUnprocessedOrders(t *testing.T) {
 	db := setupInMemoryOrderDB(t)
 	defer db.Close()
@@ -161,7 +182,7 @@ func TestOrderRepositoryImpl_GetOrderByID(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := repo.GetOrderByID(context.Background(), tt.orderID)
+			got, err := repo.GetOrderByID(appContext.WithAllTenants(context.Background()), tt.orderID)
 
 			if tt.wantErr {
 				assert.Error(t, err, "GetOrderByID should fail for non-existent ID")
@@ -174,6 +195,42 @@ func TestOrderRepositoryImpl_GetOrderByID(t *testing.T) {
 	}
 }
 
+func TestOrderRepositoryImpl_GetOrdersByIDs(t *testing.T) {
+	db := setupInMemoryOrderDB(t)
+	defer db.Close()
+
+	userUUID := uuid.New()
+	for _, id := range []string{"bulk1", "bulk2", "bulk3"} {
+		_, err := db.Exec(`INSERT INTO orders (id, user_uuid, status) VALUES (?, ?, 'INVALID')`, id, userUUID.String())
+		require.NoError(t, err)
+	}
+	repo := NewOrderRepository(db)
+
+	tests := []struct {
+		name    string
+		ids     []string
+		wantIDs []string
+	}{
+		{name: "All Existing", ids: []string{"bulk1", "bulk3"}, wantIDs: []string{"bulk1", "bulk3"}},
+		{name: "Existing And Missing", ids: []string{"bulk2", "missing1", "bulk3", "missing2"}, wantIDs: []string{"bulk2", "bulk3"}},
+		{name: "Only Missing", ids: []string{"missing1"}, wantIDs: []string{}},
+		{name: "No IDs", ids: []string{}, wantIDs: []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			orders, err := repo.GetOrdersByIDs(appContext.WithAllTenants(context.Background()), tt.ids)
+			require.NoError(t, err)
+			gotIDs := make([]string, 0, len(*orders))
+			for _, order := range *orders {
+				gotIDs = append(gotIDs, order.ID)
+				assert.Equal(t, userUUID, order.UserUUID)
+				assert.Equal(t, INVALID, order.Status)
+			}
+			assert.ElementsMatch(t, tt.wantIDs, gotIDs)
+		})
+	}
+}
+
 func TestOrderRepositoryImpl_GetOrdersByUserUID(t *testing.T) {
 	db := setupInMemoryOrderDB(t)
 	defer db.Close()
@@ -222,7 +279,7 @@ func TestOrderRepositoryImpl_GetOrdersByUserUID(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := repo.GetOrdersByUserUID(context.Background(), tt.userUUID)
+			got, err := repo.GetOrdersByUserUID(context.Background(), tt.userUUID, 10, 0)
 
 			if tt.wantErr {
 				assert.Error(t, err, "GetOrdersByUserUID should fail")
@@ -234,6 +291,113 @@ func TestOrderRepositoryImpl_GetOrdersByUserUID(t *testing.T) {
 	}
 }
 
+func TestOrderRepositoryImpl_GetOrdersAfterCursor(t *testing.T) {
+	db := setupInMemoryOrderDB(t)
+	defer db.Close()
+
+	userUUID := uuid.New()
+	otherUUID := uuid.New()
+	base := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	insert := func(id string, owner uuid.UUID, createdAt time.Time) {
+		_, err := db.NamedExec(`INSERT INTO orders (id, user_uuid, status, created_at, updated_at)
+								VALUES (:id, :user_uuid, :status, :created_at, :updated_at)`,
+			Order{ID: id, UserUUID: owner, Status: NEW, CreatedAt: createdAt, UpdatedAt: createdAt})
+		require.NoError(t, err)
+	}
+	insert("cursor1", userUUID, base)
+	insert("cursor2", userUUID, base.Add(time.Hour))
+	// cursor3 and cursor4 share created_at, the id breaks the tie
+	insert("cursor3", userUUID, base.Add(2*time.Hour))
+	insert("cursor4", userUUID, base.Add(2*time.Hour))
+	insert("cursor5", userUUID, base.Add(3*time.Hour))
+	insert("foreign", otherUUID, base.Add(time.Hour))
+
+	repo := NewOrderRepository(db)
+	ids := func(orders *[]Order) []string {
+		result := make([]string, 0, len(*orders))
+		for _, order := range *orders {
+			result = append(result, order.ID)
+		}
+		return result
+	}
+
+	first, err := repo.GetOrdersAfterCursor(context.Background(), &userUUID, nil, 2)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"cursor5", "cursor4"}, ids(first))
+
+	// new orders arriving between pages must not shift the following pages
+	insert("cursor6", userUUID, base.Add(4*time.Hour))
+
+	cursor, err := DecodeOrderCursor(NewOrderCursor((*first)[1]).Encode())
+	require.NoError(t, err)
+	second, err := repo.GetOrdersAfterCursor(context.Background(), &userUUID, &cursor, 2)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"cursor3", "cursor2"}, ids(second))
+
+	cursor = NewOrderCursor((*second)[1])
+	last, err := repo.GetOrdersAfterCursor(context.Background(), &userUUID, &cursor, 2)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"cursor1"}, ids(last))
+
+	cursor = NewOrderCursor((*last)[0])
+	empty, err := repo.GetOrdersAfterCursor(context.Background(), &userUUID, &cursor, 2)
+	require.NoError(t, err)
+	assert.Empty(t, *empty)
+}
+
+func TestOrderRepositoryImpl_GetOrdersSince(t *testing.T) {
+	db := setupInMemoryOrderDB(t)
+	defer db.Close()
+
+	userUUID := uuid.New()
+	otherUUID := uuid.New()
+	base := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	insert := func(id string, owner uuid.UUID, createdAt time.Time) {
+		_, err := db.NamedExec(`INSERT INTO orders (id, user_uuid, status, created_at, updated_at)
+								VALUES (:id, :user_uuid, :status, :created_at, :updated_at)`,
+			Order{ID: id, UserUUID: owner, Status: NEW, CreatedAt: createdAt, UpdatedAt: createdAt})
+		require.NoError(t, err)
+	}
+	insert("since1", userUUID, base)
+	insert("since2", userUUID, base.Add(time.Hour))
+	insert("since3", userUUID, base.Add(2*time.Hour))
+	insert("foreign", otherUUID, base.Add(3*time.Hour))
+
+	repo := NewOrderRepository(db)
+	ids := func(orders *[]Order) []string {
+		result := make([]string, 0, len(*orders))
+		for _, order := range *orders {
+			result = append(result, order.ID)
+		}
+		return result
+	}
+
+	orders, err := repo.GetOrdersSince(context.Background(), &userUUID, base, 10, 0)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"since3", "since2"}, ids(orders), "the order seen last is not new")
+
+	orders, err = repo.GetOrdersSince(context.Background(), &userUUID, time.Time{}, 1, 1)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"since2"}, ids(orders))
+
+	orders, err = repo.GetOrdersSince(context.Background(), &userUUID, base.Add(2*time.Hour), 10, 0)
+	require.NoError(t, err)
+	assert.Empty(t, *orders)
+}
+
+func TestDecodeOrderCursor(t *testing.T) {
+	cursor := OrderCursor{CreatedAt: time.Date(2021, 1, 1, 12, 30, 0, 123456000, time.UTC), ID: "354188083613"}
+	decoded, err := DecodeOrderCursor(cursor.Encode())
+	require.NoError(t, err)
+	assert.True(t, cursor.CreatedAt.Equal(decoded.CreatedAt))
+	assert.Equal(t, cursor.ID, decoded.ID)
+
+	for _, token := range []string{"", "not base64!", "bm8tc2VwYXJhdG9y", "eWVzdGVyZGF5fDEyMw"} {
+		_, err := DecodeOrderCursor(token)
+		assert.Error(t, err, token)
+	}
+}
+
 func TestOrderRepositoryImpl_GetUnprocessedOrders(t *testing.T) {
 	db := setupInMemoryOrderDB(t)
 	defer db.Close()
@@ -353,3 +517,389 @@ func TestOrderRepositoryImpl_UpdateOrder(t *testing.T) {
 		})
 	}
 }
+
+func TestOrderRepositoryImpl_SumProcessedAccruals(t *testing.T) {
+	db := setupInMemoryOrderDB(t)
+	defer db.Close()
+
+	userUUID := uuid.New()
+	otherUserUUID := uuid.New()
+	newUserUUID := uuid.New()
+	var processedAcc, processingAcc, otherAcc = 100.5, 40.0, 70.0
+	testOrders := []Order{
+		{ID: "order1", UserUUID: userUUID, Status: PROCESSED, Accrual: &processedAcc},
+		{ID: "order2", UserUUID: userUUID, Status: PROCESSED, Accrual: &processedAcc},
+		{ID: "order3", UserUUID: userUUID, Status: PROCESSING, Accrual: &processingAcc},
+		{ID: "order4", UserUUID: userUUID, Status: NEW},
+		{ID: "order5", UserUUID: otherUserUUID, Status: PROCESSED, Accrual: &otherAcc},
+	}
+	for _, order := range testOrders {
+		_, err := db.NamedExec(`INSERT INTO orders (id, user_uuid, status, accrual, created_at, updated_at) 
+								VALUES (:id, :user_uuid, :status, :accrual, :created_at, :updated_at)`, order)
+		require.NoError(t, err)
+	}
+
+	repo := NewOrderRepository(db)
+
+	tests := []struct {
+		name     string
+		userUUID *uuid.UUID
+		want     float64
+	}{
+		{
+			name:     "Sum Of Processed Orders Only",
+			userUUID: &userUUID,
+			want:     201,
+		},
+		{
+			name:     "User Without Orders",
+			userUUID: &newUserUUID,
+			want:     0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := repo.SumProcessedAccruals(context.Background(), tt.userUUID)
+			assert.NoError(t, err, "SumProcessedAccruals should not fail")
+			assert.Equal(t, tt.want, got, "Unexpected accrual sum")
+		})
+	}
+}
+
+func TestOrderRepositoryImpl_SumPendingAccruals(t *testing.T) {
+	db := setupInMemoryOrderDB(t)
+	defer db.Close()
+
+	userUUID := uuid.New()
+	otherUserUUID := uuid.New()
+	var processedAcc, processingAcc, otherAcc = 100.5, 40.0, 70.0
+	testOrders := []Order{
+		{ID: "order1", UserUUID: userUUID, Status: PROCESSED, Accrual: &processedAcc},
+		{ID: "order2", UserUUID: userUUID, Status: PROCESSING, Accrual: &processingAcc},
+		{ID: "order3", UserUUID: userUUID, Status: PROCESSING, Accrual: &processingAcc},
+		{ID: "order4", UserUUID: userUUID, Status: PROCESSING},
+		{ID: "order5", UserUUID: userUUID, Status: NEW},
+		{ID: "order6", UserUUID: otherUserUUID, Status: PROCESSING, Accrual: &otherAcc},
+	}
+	for _, order := range testOrders {
+		_, err := db.NamedExec(`INSERT INTO orders (id, user_uuid, status, accrual, created_at, updated_at) 
+								VALUES (:id, :user_uuid, :status, :accrual, :created_at, :updated_at)`, order)
+		require.NoError(t, err)
+	}
+
+	repo := NewOrderRepository(db)
+
+	got, err := repo.SumPendingAccruals(context.Background(), &userUUID)
+	assert.NoError(t, err)
+	assert.Equal(t, 80.0, got, "Only known accruals of PROCESSING orders should be summed")
+
+	newUserUUID := uuid.New()
+	got, err = repo.SumPendingAccruals(context.Background(), &newUserUUID)
+	assert.NoError(t, err)
+	assert.Equal(t, 0.0, got)
+}
+
+func TestOrderRepositoryImpl_GetOrdersInRange(t *testing.T) {
+	db := setupInMemoryOrderDB(t)
+	defer db.Close()
+	_, err := db.Exec(initUserDB)
+	require.NoError(t, err)
+
+	alice, bob := uuid.New(), uuid.New()
+	_, err = db.Exec(`INSERT INTO users (uuid, login, password_hash) VALUES (?, 'alice', 'hash'), (?, 'bob', 'hash')`, alice, bob)
+	require.NoError(t, err)
+
+	day := func(d int) time.Time { return time.Date(2021, 1, d, 0, 0, 0, 0, time.UTC) }
+	testOrders := []Order{
+		{ID: "order1", UserUUID: alice, Status: NEW, CreatedAt: day(1), UpdatedAt: day(1)},
+		{ID: "order2", UserUUID: bob, Status: NEW, CreatedAt: day(2), UpdatedAt: day(2)},
+		{ID: "order3", UserUUID: alice, Status: NEW, CreatedAt: day(3), UpdatedAt: day(3)},
+		{ID: "order4", UserUUID: bob, Status: NEW, CreatedAt: day(4), UpdatedAt: day(4)},
+	}
+	for _, order := range testOrders {
+		_, err := db.NamedExec(`INSERT INTO orders (id, user_uuid, status, accrual, created_at, updated_at) 
+								VALUES (:id, :user_uuid, :status, :accrual, :created_at, :updated_at)`, order)
+		require.NoError(t, err)
+	}
+
+	repo := NewOrderRepository(db)
+
+	tests := []struct {
+		name   string
+		from   time.Time
+		to     time.Time
+		limit  int
+		offset int
+		want   []UserOrder
+	}{
+		{
+			name:  "Orders Of All Users In Range",
+			from:  day(2),
+			to:    day(4),
+			limit: 10,
+			want: []UserOrder{
+				{Order: testOrders[1], Login: "bob"},
+				{Order: testOrders[2], Login: "alice"},
+			},
+		},
+		{
+			name:   "Paginated",
+			from:   day(1),
+			to:     day(5),
+			limit:  1,
+			offset: 3,
+			want:   []UserOrder{{Order: testOrders[3], Login: "bob"}},
+		},
+		{
+			name:  "Empty Range",
+			from:  day(10),
+			to:    day(11),
+			limit: 10,
+			want:  []UserOrder{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := repo.GetOrdersInRange(appContext.WithAllTenants(context.Background()), tt.from, tt.to, tt.limit, tt.offset)
+			require.NoError(t, err)
+			assert.Equal(t, &tt.want, got)
+		})
+	}
+}
+
+func TestOrderRepositoryImpl_GetDeadLetterOrders(t *testing.T) {
+	db := setupInMemoryOrderDB(t)
+	defer db.Close()
+	_, err := db.Exec(initUserDB)
+	require.NoError(t, err)
+
+	alice, bob := uuid.New(), uuid.New()
+	_, err = db.Exec(`INSERT INTO users (uuid, login, password_hash) VALUES (?, 'dl_alice', 'hash'), (?, 'dl_bob', 'hash')`, alice, bob)
+	require.NoError(t, err)
+
+	day := func(d int) time.Time { return time.Date(2022, 1, d, 0, 0, 0, 0, time.UTC) }
+	declined, exhausted := "declined", "exhausted"
+	testOrders := []Order{
+		{ID: "dl_order1", UserUUID: alice, Status: INVALID, FailureReason: &exhausted, CreatedAt: day(1), UpdatedAt: day(5)},
+		{ID: "dl_order2", UserUUID: bob, Status: INVALID, FailureReason: &declined, CreatedAt: day(2), UpdatedAt: day(3)},
+		{ID: "dl_order3", UserUUID: alice, Status: PROCESSED, CreatedAt: day(3), UpdatedAt: day(4)},
+		{ID: "dl_order4", UserUUID: bob, Status: INVALID, CreatedAt: day(4), UpdatedAt: day(6)},
+	}
+	for _, order := range testOrders {
+		_, err := db.NamedExec(`INSERT INTO orders (id, user_uuid, status, failure_reason, created_at, updated_at) 
+								VALUES (:id, :user_uuid, :status, :failure_reason, :created_at, :updated_at)`, order)
+		require.NoError(t, err)
+	}
+	defer db.Exec(`DELETE FROM orders WHERE id LIKE 'dl_%'`)
+
+	repo := NewOrderRepository(db)
+
+	tests := []struct {
+		name   string
+		limit  int
+		offset int
+		want   []UserOrder
+	}{
+		{
+			name:  "Invalid Orders Longest Failed First",
+			limit: 10,
+			want: []UserOrder{
+				{Order: testOrders[1], Login: "dl_bob"},
+				{Order: testOrders[0], Login: "dl_alice"},
+				{Order: testOrders[3], Login: "dl_bob"},
+			},
+		},
+		{
+			name:   "Paginated",
+			limit:  1,
+			offset: 1,
+			want:   []UserOrder{{Order: testOrders[0], Login: "dl_alice"}},
+		},
+		{
+			name:   "Past The Last Order",
+			limit:  10,
+			offset: 3,
+			want:   []UserOrder{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := repo.GetDeadLetterOrders(appContext.WithAllTenants(context.Background()), tt.limit, tt.offset)
+			require.NoError(t, err)
+			assert.Equal(t, &tt.want, got)
+		})
+	}
+}
+
+func TestOrderRepositoryImpl_ArchiveProcessedOrdersBefore(t *testing.T) {
+	db := setupInMemoryOrderDB(t)
+	defer db.Close()
+
+	userUUID := uuid.New()
+	acc := 10.0
+	cutoff := time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC)
+	old := cutoff.Add(-24 * time.Hour)
+	recent := cutoff.Add(24 * time.Hour)
+	testOrders := []Order{
+		{ID: "old_processed", UserUUID: userUUID, Status: PROCESSED, Accrual: &acc, CreatedAt: old, UpdatedAt: old},
+		{ID: "old_invalid", UserUUID: userUUID, Status: INVALID, CreatedAt: old, UpdatedAt: old},
+		{ID: "old_new", UserUUID: userUUID, Status: NEW, CreatedAt: old, UpdatedAt: old},
+		{ID: "recent_processed", UserUUID: userUUID, Status: PROCESSED, Accrual: &acc, CreatedAt: old, UpdatedAt: recent},
+	}
+	for _, order := range testOrders {
+		_, err := db.NamedExec(`INSERT INTO orders (id, user_uuid, status, accrual, created_at, updated_at) 
+								VALUES (:id, :user_uuid, :status, :accrual, :created_at, :updated_at)`, order)
+		require.NoError(t, err)
+	}
+
+	repo := NewOrderRepository(db)
+	ctx := appContext.WithAllTenants(context.Background())
+	archived, err := repo.ArchiveProcessedOrdersBefore(ctx, cutoff, recent)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), archived)
+
+	// archiving again doesn't touch the order a second time
+	archived, err = repo.ArchiveProcessedOrdersBefore(ctx, cutoff, recent)
+	require.NoError(t, err)
+	assert.Zero(t, archived)
+
+	listed, err := repo.GetOrdersByUserUID(ctx, &userUUID, 10, 0)
+	require.NoError(t, err)
+	var listedIDs []string
+	for _, order := range *listed {
+		listedIDs = append(listedIDs, order.ID)
+	}
+	assert.ElementsMatch(t, []string{"old_invalid", "old_new", "recent_processed"}, listedIDs)
+	count, err := repo.CountOrdersByUserUID(ctx, &userUUID)
+	require.NoError(t, err)
+	assert.Equal(t, 3, count)
+
+	// the archived order is still stored, so its number stays taken and its accrual still counts
+	order, err := repo.GetOrderByID(ctx, "old_processed")
+	require.NoError(t, err)
+	require.NotNil(t, order.ArchivedAt)
+	assert.True(t, recent.Equal(*order.ArchivedAt))
+	sum, err := repo.SumProcessedAccruals(ctx, &userUUID)
+	require.NoError(t, err)
+	assert.Equal(t, 20.0, sum)
+}
+
+func TestOrderRepositoryImpl_UpsertOrder(t *testing.T) {
+	db := setupInMemoryOrderDB(t)
+	defer db.Close()
+	repo := NewOrderRepository(db)
+	allTenants := appContext.WithAllTenants(context.Background())
+
+	userUUID := uuid.New()
+	accrual := 42.5
+	order := &Order{
+		ID:        "upsert-order",
+		UserUUID:  userUUID,
+		Status:    PROCESSING,
+		Attempts:  1,
+		CreatedAt: time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC),
+		UpdatedAt: time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+	upsert := func(order *Order) {
+		tx, err := db.Beginx()
+		require.NoError(t, err)
+		require.NoError(t, repo.UpsertOrder(context.Background(), tx, order))
+		require.NoError(t, tx.Commit())
+	}
+
+	// the row doesn't exist yet, the whole order is inserted
+	upsert(order)
+	stored, err := repo.GetOrderByID(allTenants, "upsert-order")
+	require.NoError(t, err)
+	assert.Equal(t, userUUID, stored.UserUUID)
+	assert.Equal(t, PROCESSING, stored.Status)
+	assert.Nil(t, stored.Accrual)
+	assert.Equal(t, 1, stored.Attempts)
+
+	// the row exists now, only status, accrual and update time change
+	upsert(&Order{
+		ID:        "upsert-order",
+		UserUUID:  uuid.New(),
+		Status:    PROCESSED,
+		Accrual:   &accrual,
+		Attempts:  5,
+		CreatedAt: time.Date(2021, 1, 3, 0, 0, 0, 0, time.UTC),
+		UpdatedAt: time.Date(2021, 1, 2, 0, 0, 0, 0, time.UTC),
+	})
+	stored, err = repo.GetOrderByID(allTenants, "upsert-order")
+	require.NoError(t, err)
+	assert.Equal(t, userUUID, stored.UserUUID, "the owner should not change")
+	assert.Equal(t, PROCESSED, stored.Status)
+	require.NotNil(t, stored.Accrual)
+	assert.Equal(t, accrual, *stored.Accrual)
+	assert.Equal(t, 1, stored.Attempts, "attempts are counted by IncrementAttempts only")
+	assert.True(t, stored.CreatedAt.Equal(order.CreatedAt), "created_at should not change")
+	assert.True(t, stored.UpdatedAt.Equal(time.Date(2021, 1, 2, 0, 0, 0, 0, time.UTC)))
+
+	var count int
+	require.NoError(t, db.Get(&count, `SELECT count(*) FROM orders WHERE id = 'upsert-order'`))
+	assert.Equal(t, 1, count)
+}
+
+func TestOrderRepositoryImpl_IncrementAttempts(t *testing.T) {
+	db := setupInMemoryOrderDB(t)
+	defer db.Close()
+
+	_, err := db.Exec(`INSERT INTO orders (id, user_uuid, status) VALUES (?, ?, 'NEW')`, "attempts-order", uuid.New().String())
+	require.NoError(t, err)
+
+	repo := NewOrderRepository(db)
+
+	for want := 1; want <= 3; want++ {
+		tx, err := db.Beginx()
+		require.NoError(t, err)
+		got, err := repo.IncrementAttempts(context.Background(), tx, "attempts-order")
+		require.NoError(t, err)
+		require.NoError(t, tx.Commit())
+		assert.Equal(t, want, got, "each call should count one more attempt")
+	}
+
+	order, err := repo.GetOrderByID(appContext.WithAllTenants(context.Background()), "attempts-order")
+	require.NoError(t, err)
+	assert.Equal(t, 3, order.Attempts)
+
+	tx, err := db.Beginx()
+	require.NoError(t, err)
+	_, err = repo.IncrementAttempts(context.Background(), tx, "missing-order")
+	assert.Error(t, err, "IncrementAttempts should fail for an unknown order")
+	assert.NoError(t, tx.Rollback())
+}
+
+func TestOrderRepositoryImpl_WithReadDB(t *testing.T) {
+	primary, replica := setupPrimaryAndReplica(t, "orders", initOrderDB)
+	defer primary.Close()
+	defer replica.Close()
+
+	userUUID := uuid.New()
+	_, err := replica.Exec(`INSERT INTO orders (id, user_uuid, status, accrual) VALUES ('replica-order', ?, 'PROCESSED', 10)`, userUUID.String())
+	require.NoError(t, err)
+
+	repo := NewOrderRepository(primary).WithReadDB(replica)
+	ctx := context.Background()
+
+	orders, err := repo.GetOrdersByUserUID(ctx, &userUUID, 10, 0)
+	require.NoError(t, err)
+	require.Len(t, *orders, 1, "user order listing should read from the replica")
+	assert.Equal(t, "replica-order", (*orders)[0].ID)
+
+	processed, err := repo.GetProcessedOrdersByUserUID(ctx, &userUUID, 10, 0)
+	require.NoError(t, err)
+	assert.Len(t, *processed, 1)
+
+	_, err = repo.GetOrderByID(ctx, "replica-order")
+	assert.Error(t, err, "lookups before writes should stay on the primary")
+
+	require.NoError(t, repo.CreateOrder(ctx, &Order{ID: "primary-order", UserUUID: userUUID, Status: NEW,
+		CreatedAt: time.Now(), UpdatedAt: time.Now()}))
+	var onReplica int
+	require.NoError(t, replica.Get(&onReplica, `SELECT count(*) FROM orders WHERE id = 'primary-order'`))
+	assert.Zero(t, onReplica, "writes should go to the primary")
+}
@@ -0,0 +1,81 @@
+package repository
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/ujwegh/gophermart/internal/app/config"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestWalletRepositoryImpl_GetWalletForUpdate_Locks(t *testing.T) {
+	db := setupPostgresDB(t)
+	defer db.Close()
+
+	ctx := context.Background()
+	userUUID := uuid.New()
+	_, err := db.ExecContext(ctx, `INSERT INTO users (uuid, login, password_hash) VALUES ($1, $2, 'hash')`,
+		userUUID, "lock-test-"+userUUID.String())
+	require.NoError(t, err)
+	defer db.ExecContext(ctx, `DELETE FROM users WHERE uuid = $1`, userUUID)
+	_, err = db.ExecContext(ctx, `INSERT INTO wallets (user_uuid, credits) VALUES ($1, 100)`, userUUID)
+	require.NoError(t, err)
+
+	repo := NewWalletRepository(db)
+	first, err := db.BeginTxx(ctx, nil)
+	require.NoError(t, err)
+	defer first.Rollback()
+	_, err = repo.GetWalletForUpdate(ctx, first, &userUUID)
+	require.NoError(t, err)
+
+	second, err := db.BeginTxx(ctx, nil)
+	require.NoError(t, err)
+	defer second.Rollback()
+	locked := make(chan *Wallet, 1)
+	go func() {
+		wallet, err := repo.GetWalletForUpdate(ctx, second, &userUUID)
+		assert.NoError(t, err)
+		locked <- wallet
+	}()
+
+	select {
+	case <-locked:
+		t.Fatal("second transaction read the wallet while the first one held the lock")
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	_, err = repo.Debit(ctx, first, &userUUID, 40_00)
+	require.NoError(t, err)
+	require.NoError(t, first.Commit())
+
+	select {
+	case wallet := <-locked:
+		assert.Equal(t, 40.0, wallet.Debits, "second transaction should see the committed debit")
+	case <-time.After(5 * time.Second):
+		t.Fatal("second transaction did not get the lock after the first committed")
+	}
+}
+
+func TestDBStorage_MigrationVersion(t *testing.T) {
+	db := setupPostgresDB(t)
+	defer db.Close()
+
+	storage := &DBStorage{DBConn: db, ReadDBConn: db}
+	version, err := storage.MigrationVersion()
+	require.NoError(t, err)
+	assert.NotZero(t, version, "Applied migrations should be reported")
+}
+
+func TestRunMigrations(t *testing.T) {
+	db := setupPostgresDB(t)
+	defer db.Close()
+
+	err := RunMigrations(config.AppConfig{DatabaseURI: os.Getenv("TEST_DATABASE_URI")})
+	require.NoError(t, err, "Migrating an up to date database should be a no-op")
+	version, err := (&DBStorage{DBConn: db}).MigrationVersion()
+	require.NoError(t, err)
+	assert.NotZero(t, version)
+}
@@ -0,0 +1,68 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+type (
+	// UserStats aggregates a user's orders, accruals, withdrawals and balance.
+	UserStats struct {
+		TotalOrders    int
+		OrdersByStatus map[Status]int
+		Accrued        float64 `db:"accrued"`
+		Withdrawn      float64 `db:"withdrawn"`
+		Balance        float64 `db:"balance"`
+	}
+	StatsRepository interface {
+		GetUserStats(ctx context.Context, userUID *uuid.UUID) (*UserStats, error)
+	}
+	StatsRepositoryImpl struct {
+		readDB *sqlx.DB
+	}
+	statusCount struct {
+		Status Status `db:"status"`
+		Count  int    `db:"count"`
+	}
+)
+
+func NewStatsRepository(db *sqlx.DB) *StatsRepositoryImpl {
+	return &StatsRepositoryImpl{readDB: db}
+}
+
+// WithReadDB sends the aggregate queries to readDB, e.g. a replica.
+func (sr *StatsRepositoryImpl) WithReadDB(readDB *sqlx.DB) *StatsRepositoryImpl {
+	sr.readDB = readDB
+	return sr
+}
+
+// GetUserStats counts the user's orders by status and sums the accruals of PROCESSED orders, the withdrawals
+// that were not reversed and the wallet balance. Every status is present in OrdersByStatus, unused ones with 0.
+func (sr *StatsRepositoryImpl) GetUserStats(ctx context.Context, userUID *uuid.UUID) (*UserStats, error) {
+	stats := UserStats{
+		OrdersByStatus: map[Status]int{NEW: 0, PROCESSING: 0, INVALID: 0, PROCESSED: 0},
+	}
+	counts := make([]statusCount, 0)
+	query := `SELECT status, COUNT(*) AS count FROM orders WHERE user_uuid = $1 GROUP BY status;`
+	if err := sr.readDB.SelectContext(ctx, &counts, query, userUID); err != nil {
+		return nil, fmt.Errorf("count orders by status: %w", err)
+	}
+	for _, c := range counts {
+		stats.OrdersByStatus[c.Status] = c.Count
+		stats.TotalOrders += c.Count
+	}
+
+	query = `SELECT
+				(SELECT COALESCE(SUM(accrual), 0) FROM orders
+				 WHERE user_uuid = $1 AND status = 'PROCESSED') AS accrued,
+				(SELECT COALESCE(SUM(w.amount), 0) FROM withdrawals w
+				 WHERE w.user_uuid = $1
+				   AND NOT EXISTS (SELECT 1 FROM withdrawal_reversals r WHERE r.withdrawal_id = w.id)) AS withdrawn,
+				COALESCE((SELECT credits - debits FROM wallets WHERE user_uuid = $1), 0) AS balance;`
+	if err := sr.readDB.GetContext(ctx, &stats, query, userUID); err != nil {
+		return nil, fmt.Errorf("sum user totals: %w", err)
+	}
+	return &stats, nil
+}
@@ -0,0 +1,65 @@
+package repository
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestStatsRepositoryImpl_GetUserStats(t *testing.T) {
+	db, err := sqlx.Open("sqlite3", "file:stats?mode=memory&cache=shared")
+	require.NoError(t, err)
+	defer db.Close()
+	for _, schema := range []string{initOrderDB, initWithdrawalDB, initWalletDB} {
+		_, err = db.Exec(schema)
+		require.NoError(t, err)
+	}
+
+	userUUID, otherUUID := uuid.New(), uuid.New()
+	orders := []struct {
+		id      string
+		user    uuid.UUID
+		status  Status
+		accrual interface{}
+	}{
+		{"1", userUUID, NEW, nil},
+		{"2", userUUID, PROCESSING, nil},
+		{"3", userUUID, PROCESSING, nil},
+		{"4", userUUID, INVALID, nil},
+		{"5", userUUID, PROCESSED, 100.5},
+		{"6", userUUID, PROCESSED, 200.0},
+		{"7", otherUUID, PROCESSED, 999.0},
+	}
+	for _, o := range orders {
+		_, err = db.Exec(`INSERT INTO orders (id, user_uuid, status, accrual) VALUES (?, ?, ?, ?)`,
+			o.id, o.user.String(), o.status, o.accrual)
+		require.NoError(t, err)
+	}
+	insertTestWithdrawal(db, userUUID, "w1", 50)
+	insertTestWithdrawal(db, userUUID, "w2", 25)
+	insertTestWithdrawal(db, otherUUID, "w3", 10)
+	_, err = db.Exec(`INSERT INTO withdrawal_reversals (withdrawal_id, user_uuid, order_id, amount)
+					  SELECT id, user_uuid, order_id, amount FROM withdrawals WHERE order_id = 'w2'`)
+	require.NoError(t, err)
+	_, err = db.Exec(`INSERT INTO wallets (user_uuid, credits, debits) VALUES (?, ?, ?)`, userUUID.String(), 300.5, 50)
+	require.NoError(t, err)
+
+	repo := NewStatsRepository(db)
+	stats, err := repo.GetUserStats(context.Background(), &userUUID)
+	require.NoError(t, err)
+	assert.Equal(t, 6, stats.TotalOrders)
+	assert.Equal(t, map[Status]int{NEW: 1, PROCESSING: 2, INVALID: 1, PROCESSED: 2}, stats.OrdersByStatus)
+	assert.Equal(t, 300.5, stats.Accrued)
+	assert.Equal(t, 50.0, stats.Withdrawn, "reversed withdrawals should not count")
+	assert.Equal(t, 250.5, stats.Balance)
+
+	newcomer := uuid.New()
+	stats, err = repo.GetUserStats(context.Background(), &newcomer)
+	require.NoError(t, err)
+	assert.Equal(t, &UserStats{
+		OrdersByStatus: map[Status]int{NEW: 0, PROCESSING: 0, INVALID: 0, PROCESSED: 0},
+	}, stats)
+}
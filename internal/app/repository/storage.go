@@ -11,16 +11,26 @@ import (
 	"io/fs"
 )
 
-type DBStorage struct {
-	DBConn *sqlx.DB
-}
+type (
+	DBStorage struct {
+		DBConn *sqlx.DB
+		// ReadDBConn points to the read replica, or to the primary when no replica is configured.
+		ReadDBConn *sqlx.DB
+	}
+	MigrationVersionProvider interface {
+		MigrationVersion() (int64, error)
+	}
+)
+
+// readMaxOpenConns limits the read replica pool, DB_MAX_OPEN_CONNS only sizes the primary the commits write to.
+const readMaxOpenConns = 10
 
-func open(dataSourceName string) *sqlx.DB {
+func open(dataSourceName string, maxOpenConns int) *sqlx.DB {
 	db, err := sqlx.Open("pgx", dataSourceName)
-	db.SetMaxOpenConns(10)
 	if err != nil {
 		panic(err)
 	}
+	db.SetMaxOpenConns(maxOpenConns)
 	return db
 }
 
@@ -47,13 +57,59 @@ func MigrateFS(db *sqlx.DB, migrationsFS fs.FS, dir string) error {
 	return Migrate(db, dir)
 }
 
+// RunMigrations applies the embedded migrations to the primary database, so that they can be run
+// as a separate step before servers started with SkipMigrations.
+func RunMigrations(cfg config.AppConfig) error {
+	db, err := sqlx.Open("pgx", cfg.DatabaseURI)
+	if err != nil {
+		return fmt.Errorf("migrate: %w", err)
+	}
+	defer db.Close()
+	return MigrateFS(db, migrations.FS, ".")
+}
+
 func NewDBStorage(cfg config.AppConfig) *DBStorage {
-	db := open(cfg.DatabaseURI)
-	// Migrate the database
-	err := MigrateFS(db, migrations.FS, ".")
+	db := open(cfg.DatabaseURI, cfg.DBMaxOpenConns)
+	if !cfg.SkipMigrations {
+		err := MigrateFS(db, migrations.FS, ".")
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	readDB := db
+	if cfg.DatabaseReadURI != "" {
+		readDB = open(cfg.DatabaseReadURI, readMaxOpenConns)
+	}
+	return &DBStorage{DBConn: db, ReadDBConn: readDB}
+}
+
+// MigrationVersion returns the version of the latest migration applied to the primary database, 0 if none is.
+func (s *DBStorage) MigrationVersion() (int64, error) {
+	query := `SELECT COALESCE(MAX(version_id), 0) FROM goose_db_version WHERE is_applied;`
+	var version int64
+	err := s.DBConn.Get(&version, query)
 	if err != nil {
-		panic(err)
+		return 0, fmt.Errorf("read migration version: %w", err)
 	}
+	return version, nil
+}
 
-	return &DBStorage{DBConn: db}
+// LatestMigrationVersion returns the version of the newest migration in migrationsFS, 0 if there is none.
+func LatestMigrationVersion(migrationsFS fs.FS) (int64, error) {
+	names, err := fs.Glob(migrationsFS, "*.sql")
+	if err != nil {
+		return 0, fmt.Errorf("list migrations: %w", err)
+	}
+	var latest int64
+	for _, name := range names {
+		version, err := goose.NumericComponent(name)
+		if err != nil {
+			return 0, fmt.Errorf("migration %s: %w", name, err)
+		}
+		if version > latest {
+			latest = version
+		}
+	}
+	return latest, nil
 }
@@ -0,0 +1,35 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	appContext "github.com/ujwegh/gophermart/internal/app/context"
+)
+
+// ErrNoTenant is returned by tenant scoped queries for contexts with neither a tenant nor the all tenants mark.
+var ErrNoTenant = errors.New("tenant scoped query without a tenant")
+
+// tenantCondition returns the condition limiting a query to the tenant of ctx, with the tenant as the
+// numbered argument argN, or nothing for contexts marked by appContext.WithAllTenants. It fails closed
+// with ErrNoTenant for any other context. Queries by user need no condition, a user belongs to a single tenant.
+// argN has to follow the placeholders before the condition in the query text: SQLite numbers
+// $N parameters by their first appearance rather than by N.
+func tenantCondition(ctx context.Context, column string, argN int) (string, []interface{}, error) {
+	tenantID, scoped, err := tenantScope(ctx)
+	if err != nil || !scoped {
+		return "", nil, err
+	}
+	return fmt.Sprintf(" AND %s = $%d", column, argN), []interface{}{tenantID}, nil
+}
+
+// tenantScope returns the tenant of ctx, scoped is false for contexts working across tenants.
+func tenantScope(ctx context.Context) (tenantID string, scoped bool, err error) {
+	if tenantID, ok := appContext.TenantID(ctx); ok {
+		return tenantID, true, nil
+	}
+	if appContext.AllTenants(ctx) {
+		return "", false, nil
+	}
+	return "", false, ErrNoTenant
+}
@@ -0,0 +1,98 @@
+package repository
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appContext "github.com/ujwegh/gophermart/internal/app/context"
+	"testing"
+	"time"
+)
+
+func TestTenantIsolation(t *testing.T) {
+	db, err := sqlx.Open("sqlite3", "file:tenants?mode=memory&cache=shared")
+	require.NoError(t, err)
+	defer db.Close()
+	for _, schema := range []string{initUserDB, initOrderDB} {
+		_, err = db.Exec(schema)
+		require.NoError(t, err)
+	}
+
+	background := context.Background()
+	acme := appContext.WithTenantID(background, "acme")
+	globex := appContext.WithTenantID(background, "globex")
+	now := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	userRepo := NewUserRepository(db)
+	createUser := func(tenantID string) (*User, error) {
+		user := &User{UUID: uuid.New(), Login: "alice", PasswordHash: "hash", CreatedAt: now, TenantID: tenantID}
+		return user, WithTransaction(background, db, func(tx *sqlx.Tx) error {
+			return userRepo.Create(background, tx, user)
+		})
+	}
+	acmeAlice, err := createUser("acme")
+	require.NoError(t, err)
+	globexAlice, err := createUser("globex")
+	require.NoError(t, err, "logins are unique per tenant")
+	_, err = createUser("acme")
+	assert.Error(t, err)
+
+	t.Run("users", func(t *testing.T) {
+		found, err := userRepo.FindByLogin(acme, "alice")
+		require.NoError(t, err)
+		assert.Equal(t, acmeAlice.UUID, found.UUID)
+		found, err = userRepo.FindByLogin(globex, "alice")
+		require.NoError(t, err)
+		assert.Equal(t, globexAlice.UUID, found.UUID)
+		_, err = userRepo.FindByLogin(appContext.WithTenantID(background, ""), "alice")
+		assert.Error(t, err, "the default tenant has no alice")
+	})
+
+	orderRepo := NewOrderRepository(db)
+	acmeOrder := &Order{ID: "1", UserUUID: acmeAlice.UUID, Status: NEW, CreatedAt: now, UpdatedAt: now, TenantID: "acme"}
+	globexOrder := &Order{ID: "2", UserUUID: globexAlice.UUID, Status: NEW, CreatedAt: now, UpdatedAt: now, TenantID: "globex"}
+	require.NoError(t, orderRepo.CreateOrder(acme, acmeOrder))
+	require.NoError(t, orderRepo.CreateOrder(globex, globexOrder))
+
+	t.Run("orders", func(t *testing.T) {
+		_, err := orderRepo.GetOrderByID(acme, globexOrder.ID)
+		assert.Error(t, err)
+		order, err := orderRepo.GetOrderByID(acme, acmeOrder.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "acme", order.TenantID)
+
+		orders, err := orderRepo.GetOrdersByIDs(acme, []string{acmeOrder.ID, globexOrder.ID})
+		require.NoError(t, err)
+		require.Len(t, *orders, 1)
+		assert.Equal(t, acmeOrder.ID, (*orders)[0].ID)
+
+		inRange, err := orderRepo.GetOrdersInRange(globex, now, now.Add(time.Hour), 10, 0)
+		require.NoError(t, err)
+		require.Len(t, *inRange, 1)
+		assert.Equal(t, globexOrder.ID, (*inRange)[0].ID)
+	})
+
+	t.Run("order numbers are unique across tenants", func(t *testing.T) {
+		duplicate := &Order{ID: acmeOrder.ID, UserUUID: globexAlice.UUID, Status: NEW, CreatedAt: now, UpdatedAt: now, TenantID: "globex"}
+		assert.ErrorIs(t, orderRepo.CreateOrder(globex, duplicate), ErrOrderExists)
+	})
+
+	t.Run("background jobs see all tenants", func(t *testing.T) {
+		orders, err := orderRepo.GetOrdersByIDs(appContext.WithAllTenants(background), []string{acmeOrder.ID, globexOrder.ID})
+		require.NoError(t, err)
+		assert.Len(t, *orders, 2)
+	})
+
+	t.Run("contexts without a tenant are refused", func(t *testing.T) {
+		_, err := orderRepo.GetOrdersByIDs(background, []string{acmeOrder.ID, globexOrder.ID})
+		assert.ErrorIs(t, err, ErrNoTenant)
+		_, err = orderRepo.GetOrderByID(background, acmeOrder.ID)
+		assert.ErrorIs(t, err, ErrNoTenant)
+		_, err = orderRepo.GetOrdersInRange(background, now, now.Add(time.Hour), 10, 0)
+		assert.ErrorIs(t, err, ErrNoTenant)
+		_, err = userRepo.FindByLogin(background, "alice")
+		assert.ErrorIs(t, err, ErrNoTenant)
+	})
+}
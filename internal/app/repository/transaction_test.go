@@ -0,0 +1,89 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func setupInMemoryTransactionDB(t *testing.T) *sqlx.DB {
+	db, err := sqlx.Open("sqlite3", "file:transaction?mode=memory&cache=shared")
+	if err != nil {
+		t.Fatalf("could not create in-memory db: %v", err)
+	}
+	_, err = db.Exec(`CREATE TABLE IF NOT EXISTS items (name TEXT NOT NULL)`)
+	if err != nil {
+		t.Fatalf("could not create items table: %v", err)
+	}
+	return db
+}
+
+func countItems(t *testing.T, db *sqlx.DB) int {
+	var count int
+	require.NoError(t, db.Get(&count, `SELECT count(*) FROM items`))
+	return count
+}
+
+func TestWithTransaction(t *testing.T) {
+	errFailed := errors.New("failed")
+	tests := []struct {
+		name      string
+		fn        func(tx *sqlx.Tx) error
+		wantErr   error
+		wantPanic bool
+		wantCount int
+	}{
+		{
+			name: "Commit On Success",
+			fn: func(tx *sqlx.Tx) error {
+				_, err := tx.Exec(`INSERT INTO items (name) VALUES ('a')`)
+				return err
+			},
+			wantCount: 1,
+		},
+		{
+			name: "Rollback On Error",
+			fn: func(tx *sqlx.Tx) error {
+				if _, err := tx.Exec(`INSERT INTO items (name) VALUES ('a')`); err != nil {
+					return err
+				}
+				return errFailed
+			},
+			wantErr:   errFailed,
+			wantCount: 0,
+		},
+		{
+			name: "Rollback On Panic",
+			fn: func(tx *sqlx.Tx) error {
+				if _, err := tx.Exec(`INSERT INTO items (name) VALUES ('a')`); err != nil {
+					return err
+				}
+				panic("boom")
+			},
+			wantPanic: true,
+			wantCount: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := setupInMemoryTransactionDB(t)
+			defer db.Close()
+			_, err := db.Exec(`DELETE FROM items`)
+			require.NoError(t, err)
+
+			run := func() error { return WithTransaction(context.Background(), db, tt.fn) }
+			if tt.wantPanic {
+				assert.PanicsWithValue(t, "boom", func() { _ = run() })
+			} else if tt.wantErr != nil {
+				assert.ErrorIs(t, run(), tt.wantErr)
+			} else {
+				assert.NoError(t, run())
+			}
+			assert.Equal(t, tt.wantCount, countItems(t, db))
+		})
+	}
+}
@@ -6,8 +6,6 @@ import (
 	"errors"
 	"fmt"
 	"github.com/google/uuid"
-	"github.com/jackc/pgerrcode"
-	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jmoiron/sqlx"
 	appErrors "github.com/ujwegh/gophermart/internal/app/errors"
 	"time"
@@ -19,10 +17,15 @@ type (
 		Login        string    `db:"login"`
 		PasswordHash string    `db:"password_hash"`
 		CreatedAt    time.Time `db:"created_at"`
+		// TenantID is the tenant the user registered in, "" for the default tenant
+		TenantID string `db:"tenant_id"`
+		// RegistrationKey is the Idempotency-Key the user registered with, nil without one
+		RegistrationKey *string `db:"registration_key"`
 	}
 	UserRepository interface {
 		Create(ctx context.Context, tx *sqlx.Tx, user *User) error
 		FindByLogin(ctx context.Context, login string) (*User, error)
+		UpdateLogin(ctx context.Context, tx *sqlx.Tx, userUID *uuid.UUID, newLogin string) error
 		GetDB() *sqlx.DB
 	}
 	UserRepositoryImpl struct {
@@ -30,14 +33,26 @@ type (
 	}
 )
 
+var (
+	// ErrUserNotFound is returned by UpdateLogin when there is no user with the uuid.
+	ErrUserNotFound = errors.New("user not found")
+	// ErrLoginTaken is returned by UpdateLogin when another user already has the login.
+	ErrLoginTaken = errors.New("login already taken")
+)
+
 func NewUserRepository(db *sqlx.DB) *UserRepositoryImpl {
 	return &UserRepositoryImpl{db: db}
 }
 
+// FindByLogin looks the login up within the tenant of ctx, logins are only unique per tenant.
 func (ur *UserRepositoryImpl) FindByLogin(ctx context.Context, login string) (*User, error) {
-	query := `SELECT * FROM users WHERE login = $1;`
+	scope, scopeArgs, err := tenantCondition(ctx, "tenant_id", 2)
+	if err != nil {
+		return nil, fmt.Errorf("get user: %w", err)
+	}
+	query := `SELECT * FROM users WHERE login = $1` + scope + `;`
 	user := User{}
-	err := ur.db.GetContext(ctx, &user, query, login)
+	err = ur.db.GetContext(ctx, &user, query, append([]interface{}{login}, scopeArgs...)...)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, appErrors.New(err, "User not found")
@@ -48,17 +63,16 @@ func (ur *UserRepositoryImpl) FindByLogin(ctx context.Context, login string) (*U
 }
 
 func (ur *UserRepositoryImpl) Create(ctx context.Context, tx *sqlx.Tx, user *User) error {
-	query := `INSERT INTO users (uuid, login, password_hash, created_at) VALUES ($1, $2, $3, $4);`
+	query := `INSERT INTO users (uuid, login, password_hash, created_at, tenant_id, registration_key) VALUES ($1, $2, $3, $4, $5, $6);`
 	stmt, err := tx.PrepareContext(ctx, query)
 	if err != nil {
 		return fmt.Errorf("prepare statement: %w", err)
 	}
 	defer stmt.Close()
 
-	_, err = stmt.ExecContext(ctx, user.UUID, user.Login, user.PasswordHash, user.CreatedAt)
+	_, err = stmt.ExecContext(ctx, user.UUID, user.Login, user.PasswordHash, user.CreatedAt, user.TenantID, user.RegistrationKey)
 	if err != nil {
-		var pgErr *pgconn.PgError
-		if errors.As(err, &pgErr) && pgErr.Code == pgerrcode.UniqueViolation {
+		if _, ok := uniqueViolation(err); ok {
 			return appErrors.New(err, "User already exists")
 		}
 		return fmt.Errorf("exec statement: %w", err)
@@ -66,6 +80,25 @@ func (ur *UserRepositoryImpl) Create(ctx context.Context, tx *sqlx.Tx, user *Use
 	return nil
 }
 
+func (ur *UserRepositoryImpl) UpdateLogin(ctx context.Context, tx *sqlx.Tx, userUID *uuid.UUID, newLogin string) error {
+	query := `UPDATE users SET login = $1 WHERE uuid = $2;`
+	result, err := tx.ExecContext(ctx, query, newLogin, userUID)
+	if err != nil {
+		if _, ok := uniqueViolation(err); ok {
+			return ErrLoginTaken
+		}
+		return fmt.Errorf("update login: %w", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("update login: %w", err)
+	}
+	if rows == 0 {
+		return ErrUserNotFound
+	}
+	return nil
+}
+
 func (ur *UserRepositoryImpl) GetDB() *sqlx.DB {
 	return ur.db
 }
@@ -6,6 +6,7 @@ import (
 	"github.com/jmoiron/sqlx"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	appContext "github.com/ujwegh/gophermart/internal/app/context"
 	"testing"
 	"time"
 )
@@ -14,9 +15,12 @@ const initUserDB = `
 CREATE TABLE IF NOT EXISTS users
 (
     uuid          TEXT PRIMARY KEY DEFAULT (hex(randomblob(16))),
-    login         TEXT UNIQUE NOT NULL,
+    login         TEXT NOT NULL,
     password_hash TEXT NOT NULL,
-    created_at    TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+    created_at    TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    tenant_id     TEXT NOT NULL DEFAULT '',
+    registration_key TEXT,
+    UNIQUE (tenant_id, login)
 );
 `
 
@@ -121,7 +125,7 @@ func TestUserRepositoryImpl_FindByLogin(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := repo.FindByLogin(context.Background(), tt.login)
+			got, err := repo.FindByLogin(appContext.WithAllTenants(context.Background()), tt.login)
 
 			if tt.wantErr {
 				assert.Error(t, err, "FindByLogin should fail")
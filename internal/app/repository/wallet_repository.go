@@ -2,9 +2,12 @@ package repository
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"fmt"
 	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
+	"strings"
 	"time"
 )
 
@@ -14,20 +17,69 @@ type (
 		UserUUID  uuid.UUID `db:"user_uuid"`
 		Credits   float64   `db:"credits"`
 		Debits    float64   `db:"debits"`
+		Version   int64     `db:"version"`
 		CreatedAt time.Time `db:"created_at"`
 		UpdatedAt time.Time `db:"updated_at"`
 	}
+	// WalletDrift is a wallet whose stored totals differ from the sums of its transaction log.
+	WalletDrift struct {
+		UserUUID        uuid.UUID `db:"user_uuid"`
+		Version         int64     `db:"version"`
+		Credits         float64   `db:"credits"`
+		Debits          float64   `db:"debits"`
+		ExpectedCredits float64   `db:"expected_credits"`
+		ExpectedDebits  float64   `db:"expected_debits"`
+	}
+	// UnmatchedDebit is a wallet whose debits exceed its unreversed withdrawals,
+	// i.e. points were taken from the user without a withdrawal record to show for them.
+	UnmatchedDebit struct {
+		UserUUID  uuid.UUID `db:"user_uuid"`
+		Login     string    `db:"login"`
+		Debits    float64   `db:"debits"`
+		Withdrawn float64   `db:"withdrawn"`
+	}
 	WalletRepository interface {
 		CreateWallet(ctx context.Context, tx *sqlx.Tx, wallet *Wallet) error
+		CreateWalletIfMissing(ctx context.Context, tx *sqlx.Tx, wallet *Wallet) (bool, error)
 		GetWallet(ctx context.Context, userUID *uuid.UUID) (*Wallet, error)
-		Credit(ctx context.Context, tx *sqlx.Tx, userUID *uuid.UUID, amount float64) (*Wallet, error)
-		Debit(ctx context.Context, tx *sqlx.Tx, userUID *uuid.UUID, amount float64) (*Wallet, error)
+		GetWalletForUpdate(ctx context.Context, tx *sqlx.Tx, userUID *uuid.UUID) (*Wallet, error)
+		Credit(ctx context.Context, tx *sqlx.Tx, userUID *uuid.UUID, amount Cents) (*Wallet, error)
+		CreditOnce(ctx context.Context, tx *sqlx.Tx, userUID *uuid.UUID, amount Cents, key string) (*Wallet, bool, error)
+		Debit(ctx context.Context, tx *sqlx.Tx, userUID *uuid.UUID, amount Cents) (*Wallet, error)
+		Refund(ctx context.Context, tx *sqlx.Tx, userUID *uuid.UUID, amount Cents) (*Wallet, error)
+		FindDrifts(ctx context.Context) ([]WalletDrift, error)
+		FixDrift(ctx context.Context, drift *WalletDrift) (bool, error)
+		FindUnmatchedDebits(ctx context.Context) ([]UnmatchedDebit, error)
 	}
 	WalletRepositoryImpl struct {
 		db *sqlx.DB
+		// beforeVersionedUpdate lets tests change the wallet between reading its version and updating it
+		beforeVersionedUpdate func(tx *sqlx.Tx)
 	}
 )
 
+// WalletTransactionKind tells the balance changes logged in wallet_transactions apart.
+type WalletTransactionKind string
+
+const (
+	CreditTransaction WalletTransactionKind = "CREDIT"
+	DebitTransaction  WalletTransactionKind = "DEBIT"
+	RefundTransaction WalletTransactionKind = "REFUND"
+)
+
+// driftTolerance absorbs float rounding when comparing stored totals with the transaction log.
+const driftTolerance = 0.005
+
+// maxWalletUpdateRetries is how often a balance change is retried after the wallet changed under it.
+const maxWalletUpdateRetries = 5
+
+var (
+	// ErrWalletNotFound is returned when the user has no wallet to credit or debit.
+	ErrWalletNotFound = errors.New("wallet not found")
+	// ErrWalletVersionConflict is returned when the wallet kept changing through all retries of a balance change.
+	ErrWalletVersionConflict = errors.New("wallet changed concurrently")
+)
+
 func NewWalletRepository(db *sqlx.DB) *WalletRepositoryImpl {
 	return &WalletRepositoryImpl{db: db}
 }
@@ -45,6 +97,47 @@ func (wr *WalletRepositoryImpl) CreateWallet(ctx context.Context, tx *sqlx.Tx, w
 	if err != nil {
 		return fmt.Errorf("exec statement: %w", err)
 	}
+	return wr.logOpeningBalance(ctx, tx, wallet)
+}
+
+// CreateWalletIfMissing inserts the wallet unless the user already has one and reports whether it did.
+// Concurrent calls for the same user create a single wallet.
+func (wr *WalletRepositoryImpl) CreateWalletIfMissing(ctx context.Context, tx *sqlx.Tx, wallet *Wallet) (bool, error) {
+	query := `INSERT INTO wallets (user_uuid, credits, debits, created_at, updated_at)
+			  VALUES ($1, $2, $3, $4, $5) ON CONFLICT (user_uuid) DO NOTHING;`
+	result, err := tx.ExecContext(ctx, query, wallet.UserUUID, wallet.Credits, wallet.Debits, wallet.CreatedAt, wallet.UpdatedAt)
+	if err != nil {
+		return false, fmt.Errorf("create missing wallet: %w", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("create missing wallet: %w", err)
+	}
+	if rows == 0 {
+		return false, nil
+	}
+	return true, wr.logOpeningBalance(ctx, tx, wallet)
+}
+
+// logOpeningBalance logs the credits and debits a new wallet starts with, like migration 009 did for the wallets
+// created before the log, so FindDrifts doesn't take them for a drift.
+func (wr *WalletRepositoryImpl) logOpeningBalance(ctx context.Context, tx *sqlx.Tx, wallet *Wallet) error {
+	insert := `INSERT INTO wallet_transactions (user_uuid, kind, amount, created_at) VALUES ($1, $2, $3, $4);`
+	opening := []struct {
+		kind   WalletTransactionKind
+		amount Cents
+	}{
+		{kind: CreditTransaction, amount: CentsOf(wallet.Credits)},
+		{kind: DebitTransaction, amount: CentsOf(wallet.Debits)},
+	}
+	for _, entry := range opening {
+		if entry.amount == 0 {
+			continue
+		}
+		if _, err := tx.ExecContext(ctx, insert, wallet.UserUUID, entry.kind, entry.amount, wallet.CreatedAt); err != nil {
+			return fmt.Errorf("log opening balance: %w", err)
+		}
+	}
 	return nil
 }
 
@@ -58,22 +151,175 @@ func (wr *WalletRepositoryImpl) GetWallet(ctx context.Context, userUID *uuid.UUI
 	return &wallet, nil
 }
 
-func (wr *WalletRepositoryImpl) Credit(ctx context.Context, tx *sqlx.Tx, userUID *uuid.UUID, amount float64) (*Wallet, error) {
-	query := `UPDATE wallets SET credits = credits + $1 WHERE user_uuid = $2 returning *;`
+// GetWalletForUpdate reads the wallet and locks its row until tx ends, so a balance check
+// and the following debit can't interleave with another transaction.
+func (wr *WalletRepositoryImpl) GetWalletForUpdate(ctx context.Context, tx *sqlx.Tx, userUID *uuid.UUID) (*Wallet, error) {
+	query := `SELECT * FROM wallets WHERE user_uuid = $1` + dialectOf(tx.DriverName()).forUpdate() + `;`
 	wallet := Wallet{}
-	err := tx.GetContext(ctx, &wallet, query, amount, userUID)
+	err := tx.GetContext(ctx, &wallet, query, userUID)
 	if err != nil {
-		return nil, fmt.Errorf("credit: %w", err)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("get wallet for update: %w", ErrWalletNotFound)
+		}
+		return nil, fmt.Errorf("get wallet for update: %w", err)
 	}
 	return &wallet, nil
 }
 
-func (wr *WalletRepositoryImpl) Debit(ctx context.Context, tx *sqlx.Tx, userUID *uuid.UUID, amount float64) (*Wallet, error) {
-	query := `UPDATE wallets SET debits = debits + $1 WHERE user_uuid = $2 returning *;`
-	wallet := Wallet{}
-	err := tx.GetContext(ctx, &wallet, query, amount, userUID)
+const creditQuery = `UPDATE wallets SET credits = credits + $1, version = version + 1
+			  WHERE user_uuid = $2 AND version = $3 returning *;`
+
+func (wr *WalletRepositoryImpl) Credit(ctx context.Context, tx *sqlx.Tx, userUID *uuid.UUID, amount Cents) (*Wallet, error) {
+	return wr.updateVersioned(ctx, tx, CreditTransaction, creditQuery, userUID, amount)
+}
+
+// CreditOnce credits the wallet like Credit unless a credit with the same key has been logged for the user before,
+// e.g. by an earlier attempt at saving the same accrual. Keys are unique per user, other users may use the same one. The key is logged first, so of two transactions
+// racing with the same key the second waits for the first and skips. It reports whether the wallet was credited
+// and returns the wallet either way.
+func (wr *WalletRepositoryImpl) CreditOnce(ctx context.Context, tx *sqlx.Tx, userUID *uuid.UUID, amount Cents, key string) (*Wallet, bool, error) {
+	insert := `INSERT INTO wallet_transactions (user_uuid, kind, amount, created_at, idempotency_key) VALUES ($1, $2, $3, $4, $5)
+			   ON CONFLICT (user_uuid, idempotency_key) DO NOTHING;`
+	result, err := tx.ExecContext(ctx, insert, userUID, CreditTransaction, amount, time.Now(), key)
 	if err != nil {
-		return nil, fmt.Errorf("debit: %w", err)
+		return nil, false, fmt.Errorf("credit: log transaction: %w", err)
 	}
-	return &wallet, nil
+	logged, err := result.RowsAffected()
+	if err != nil {
+		return nil, false, fmt.Errorf("credit: log transaction: %w", err)
+	}
+	if logged == 0 {
+		wallet := Wallet{}
+		err = tx.GetContext(ctx, &wallet, `SELECT * FROM wallets WHERE user_uuid = $1;`, userUID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, false, fmt.Errorf("credit: %w", ErrWalletNotFound)
+		}
+		if err != nil {
+			return nil, false, fmt.Errorf("credit: %w", err)
+		}
+		return &wallet, false, nil
+	}
+	wallet, err := wr.applyVersioned(ctx, tx, "credit", creditQuery, userUID, amount)
+	if err != nil {
+		return nil, false, err
+	}
+	return wallet, true, nil
+}
+
+func (wr *WalletRepositoryImpl) Debit(ctx context.Context, tx *sqlx.Tx, userUID *uuid.UUID, amount Cents) (*Wallet, error) {
+	query := `UPDATE wallets SET debits = debits + $1, version = version + 1
+			  WHERE user_uuid = $2 AND version = $3 returning *;`
+	return wr.updateVersioned(ctx, tx, DebitTransaction, query, userUID, amount)
+}
+
+// Refund takes back a previous debit, so the amount no longer counts as withdrawn.
+func (wr *WalletRepositoryImpl) Refund(ctx context.Context, tx *sqlx.Tx, userUID *uuid.UUID, amount Cents) (*Wallet, error) {
+	query := `UPDATE wallets SET debits = debits - $1, version = version + 1
+			  WHERE user_uuid = $2 AND version = $3 returning *;`
+	return wr.updateVersioned(ctx, tx, RefundTransaction, query, userUID, amount)
+}
+
+// updateVersioned runs query, which takes the amount, the user and the expected version, against the wallet
+// version it just read. When another transaction bumped the version in between, nothing is updated and the
+// change is retried on the fresh version, so concurrent balance changes never overwrite each other.
+// The applied change is logged in wallet_transactions within tx.
+func (wr *WalletRepositoryImpl) updateVersioned(ctx context.Context, tx *sqlx.Tx, kind WalletTransactionKind, query string, userUID *uuid.UUID, amount Cents) (*Wallet, error) {
+	op := strings.ToLower(string(kind))
+	wallet, err := wr.applyVersioned(ctx, tx, op, query, userUID, amount)
+	if err != nil {
+		return nil, err
+	}
+	insert := `INSERT INTO wallet_transactions (user_uuid, kind, amount, created_at) VALUES ($1, $2, $3, $4);`
+	if _, err = tx.ExecContext(ctx, insert, userUID, kind, amount, time.Now()); err != nil {
+		return nil, fmt.Errorf("%s: log transaction: %w", op, err)
+	}
+	return wallet, nil
+}
+
+// applyVersioned runs the versioned update of updateVersioned without logging it.
+func (wr *WalletRepositoryImpl) applyVersioned(ctx context.Context, tx *sqlx.Tx, op string, query string, userUID *uuid.UUID, amount Cents) (*Wallet, error) {
+	for attempt := 0; attempt <= maxWalletUpdateRetries; attempt++ {
+		var version int64
+		err := tx.GetContext(ctx, &version, `SELECT version FROM wallets WHERE user_uuid = $1;`, userUID)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return nil, fmt.Errorf("%s: %w", op, ErrWalletNotFound)
+			}
+			return nil, fmt.Errorf("%s: read version: %w", op, err)
+		}
+		if wr.beforeVersionedUpdate != nil {
+			wr.beforeVersionedUpdate(tx)
+		}
+
+		wallet := Wallet{}
+		err = tx.GetContext(ctx, &wallet, query, amount, userUID, version)
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		return &wallet, nil
+	}
+	return nil, fmt.Errorf("%s: %w", op, ErrWalletVersionConflict)
+}
+
+// FindDrifts returns the wallets whose credits or debits don't match their transaction log.
+// Refunds count against the debits.
+func (wr *WalletRepositoryImpl) FindDrifts(ctx context.Context) ([]WalletDrift, error) {
+	query := `SELECT * FROM (
+				SELECT w.user_uuid, w.version, w.credits, w.debits,
+					   COALESCE(SUM(CASE WHEN t.kind = 'CREDIT' THEN t.amount ELSE 0 END), 0) AS expected_credits,
+					   COALESCE(SUM(CASE WHEN t.kind = 'DEBIT' THEN t.amount
+										 WHEN t.kind = 'REFUND' THEN -t.amount ELSE 0 END), 0) AS expected_debits
+				FROM wallets w LEFT JOIN wallet_transactions t ON t.user_uuid = w.user_uuid
+				GROUP BY w.user_uuid, w.version, w.credits, w.debits
+			  ) totals
+			  WHERE ABS(credits - expected_credits) >= $1 OR ABS(debits - expected_debits) >= $1;`
+	drifts := make([]WalletDrift, 0)
+	err := wr.db.SelectContext(ctx, &drifts, query, driftTolerance)
+	if err != nil {
+		return nil, fmt.Errorf("find wallet drifts: %w", err)
+	}
+	return drifts, nil
+}
+
+// FixDrift sets the wallet totals to the expected ones unless the wallet changed since the drift was found,
+// in which case it reports false and leaves the wallet to the next check.
+func (wr *WalletRepositoryImpl) FixDrift(ctx context.Context, drift *WalletDrift) (bool, error) {
+	query := `UPDATE wallets SET credits = $1, debits = $2, version = version + 1
+			  WHERE user_uuid = $3 AND version = $4;`
+	result, err := wr.db.ExecContext(ctx, query, drift.ExpectedCredits, drift.ExpectedDebits, drift.UserUUID, drift.Version)
+	if err != nil {
+		return false, fmt.Errorf("fix wallet drift: %w", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("fix wallet drift: %w", err)
+	}
+	return rows == 1, nil
+}
+
+// Unmatched is the amount debited without a withdrawal.
+func (d *UnmatchedDebit) Unmatched() float64 {
+	return d.Debits - d.Withdrawn
+}
+
+// FindUnmatchedDebits returns the wallets debited by more than the sum of their unreversed withdrawals,
+// ordered by login. Reversed withdrawals were refunded, so they no longer count against the debits.
+func (wr *WalletRepositoryImpl) FindUnmatchedDebits(ctx context.Context) ([]UnmatchedDebit, error) {
+	query := `SELECT w.user_uuid, u.login, w.debits, COALESCE(SUM(wd.amount), 0) AS withdrawn
+			  FROM wallets w
+			  JOIN users u ON u.uuid = w.user_uuid
+			  LEFT JOIN withdrawals wd ON wd.user_uuid = w.user_uuid
+				  AND NOT EXISTS (SELECT 1 FROM withdrawal_reversals r WHERE r.withdrawal_id = wd.id)
+			  GROUP BY w.user_uuid, u.login, w.debits
+			  HAVING w.debits - COALESCE(SUM(wd.amount), 0) >= $1
+			  ORDER BY u.login;`
+	debits := make([]UnmatchedDebit, 0)
+	err := wr.db.SelectContext(ctx, &debits, query, driftTolerance)
+	if err != nil {
+		return nil, fmt.Errorf("find unmatched debits: %w", err)
+	}
+	return debits, nil
 }
@@ -18,11 +18,22 @@ CREATE TABLE IF NOT EXISTS wallets
     user_uuid TEXT UNIQUE NOT NULL,
     credits NUMERIC NOT NULL DEFAULT 0,
     debits NUMERIC NOT NULL DEFAULT 0,
+    version INTEGER NOT NULL DEFAULT 0,
     created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
     updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
     CHECK (credits >= 0),
     CHECK (debits >= 0)
 );
+CREATE TABLE IF NOT EXISTS wallet_transactions
+(
+    id INTEGER PRIMARY KEY,
+    user_uuid TEXT NOT NULL,
+    kind TEXT NOT NULL,
+    amount NUMERIC NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    idempotency_key TEXT
+);
+CREATE UNIQUE INDEX IF NOT EXISTS wallet_transactions_idempotency_key_idx ON wallet_transactions (user_uuid, idempotency_key);
 `
 
 func setupInMemoryWalletDB(t *testing.T) *sqlx.DB {
@@ -59,6 +70,17 @@ func TestWalletRepositoryImpl_CreateWallet(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		{
+			name: "Opening Balance Is Logged",
+			wallet: &Wallet{
+				UserUUID:  uuid.New(),
+				Credits:   100.5,
+				Debits:    20,
+				CreatedAt: time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC),
+				UpdatedAt: time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC),
+			},
+			wantErr: false,
+		},
 	}
 
 	for _, tt := range tests {
@@ -79,11 +101,42 @@ func TestWalletRepositoryImpl_CreateWallet(t *testing.T) {
 				require.NoError(t, err)
 				assert.Equal(t, tt.wallet.Credits, retrievedWallet.Credits, "Credits should match")
 				assert.Equal(t, tt.wallet.Debits, retrievedWallet.Debits, "Debits should match")
+				assertOpeningBalanceLogged(t, db, tt.wallet)
 			}
 		})
 	}
 }
 
+func TestWalletRepositoryImpl_CreateWalletIfMissing(t *testing.T) {
+	db := setupInMemoryWalletDB(t)
+	defer db.Close()
+
+	repo := NewWalletRepository(db)
+	wallet := &Wallet{UserUUID: uuid.New(), Credits: 42, CreatedAt: time.Now(), UpdatedAt: time.Now()}
+
+	for _, wantCreated := range []bool{true, false} {
+		tx, err := db.Beginx()
+		require.NoError(t, err)
+		created, err := repo.CreateWalletIfMissing(context.Background(), tx, wallet)
+		require.NoError(t, err)
+		require.NoError(t, tx.Commit())
+		assert.Equal(t, wantCreated, created)
+	}
+	// the skipped second call logs nothing
+	assertOpeningBalanceLogged(t, db, wallet)
+}
+
+// assertOpeningBalanceLogged checks that the transaction log of the new wallet adds up to its totals.
+func assertOpeningBalanceLogged(t *testing.T, db *sqlx.DB, wallet *Wallet) {
+	var credits, debits float64
+	err := db.QueryRow(`SELECT COALESCE(SUM(CASE WHEN kind = 'CREDIT' THEN amount ELSE 0 END), 0),
+							   COALESCE(SUM(CASE WHEN kind = 'DEBIT' THEN amount ELSE 0 END), 0)
+						FROM wallet_transactions WHERE user_uuid = ?`, wallet.UserUUID).Scan(&credits, &debits)
+	require.NoError(t, err)
+	assert.Equal(t, wallet.Credits, credits, "logged credits should match")
+	assert.Equal(t, wallet.Debits, debits, "logged debits should match")
+}
+
 func TestWalletRepositoryImpl_Credit(t *testing.T) {
 	db := setupInMemoryWalletDB(t)
 	defer db.Close()
@@ -92,7 +145,7 @@ func TestWalletRepositoryImpl_Credit(t *testing.T) {
 	newUserUID := uuid.New()
 
 	initialCredits := 100.0
-	creditAmount := 50.0
+	creditAmount := Cents(50_00)
 
 	// Insert a test wallet into the database for existing user
 	_, err := db.Exec(`INSERT INTO wallets (user_uuid, credits, debits) 
@@ -106,7 +159,7 @@ func TestWalletRepositoryImpl_Credit(t *testing.T) {
 	tests := []struct {
 		name              string
 		userUUID          *uuid.UUID
-		amount            float64
+		amount            Cents
 		wantErr           bool
 		wantCredits       float64
 		shouldCheckWallet bool
@@ -116,7 +169,7 @@ func TestWalletRepositoryImpl_Credit(t *testing.T) {
 			userUUID:          &userUUID,
 			amount:            creditAmount,
 			wantErr:           false,
-			wantCredits:       initialCredits + creditAmount,
+			wantCredits:       initialCredits + creditAmount.Float64(),
 			shouldCheckWallet: false,
 		},
 		{
@@ -130,7 +183,7 @@ func TestWalletRepositoryImpl_Credit(t *testing.T) {
 		{
 			name:              "Invalid Credit Amount (Negative)",
 			userUUID:          &userUUID,
-			amount:            -1000.0,
+			amount:            -1000_00,
 			wantErr:           true,
 			wantCredits:       initialCredits, // No change expected
 			shouldCheckWallet: true,
@@ -151,7 +204,7 @@ func TestWalletRepositoryImpl_Credit(t *testing.T) {
 					var wallet Wallet
 					err := db.Get(&wallet, "SELECT * FROM wallets WHERE user_uuid = ?", tt.userUUID.String())
 					require.NoError(t, err)
-					assert.Equal(t, initialCredits+creditAmount, wallet.Credits, "Credits should remain unchanged after rollback")
+					assert.Equal(t, initialCredits+creditAmount.Float64(), wallet.Credits, "Credits should remain unchanged after rollback")
 				}
 			} else {
 				assert.NoError(t, err, "Credit should not fail")
@@ -162,6 +215,130 @@ func TestWalletRepositoryImpl_Credit(t *testing.T) {
 	}
 }
 
+func TestWalletRepositoryImpl_ConcurrentUpdate(t *testing.T) {
+	db := setupInMemoryWalletDB(t)
+	defer db.Close()
+
+	userUUID := uuid.New()
+	_, err := db.Exec(`INSERT INTO wallets (user_uuid, credits, debits) VALUES (?, ?, ?)`, userUUID.String(), 100.0, 0.0)
+	require.NoError(t, err)
+
+	repo := NewWalletRepository(db)
+	// another transaction credits the wallet right after each version read
+	concurrentWrites := 0
+	repo.beforeVersionedUpdate = func(tx *sqlx.Tx) {
+		if concurrentWrites == 2 {
+			return
+		}
+		concurrentWrites++
+		_, err := tx.Exec(`UPDATE wallets SET credits = credits + 5, version = version + 1 WHERE user_uuid = ?`, userUUID.String())
+		require.NoError(t, err)
+	}
+
+	tx, err := db.Beginx()
+	require.NoError(t, err)
+	wallet, err := repo.Debit(context.Background(), tx, &userUUID, 30_00)
+	require.NoError(t, err)
+	require.NoError(t, tx.Commit())
+
+	assert.Equal(t, 110.0, wallet.Credits, "the concurrent credits must not be lost")
+	assert.Equal(t, 30.0, wallet.Debits)
+	assert.Equal(t, int64(3), wallet.Version)
+
+	// a wallet that never stops changing gives up
+	repo.beforeVersionedUpdate = func(tx *sqlx.Tx) {
+		_, err := tx.Exec(`UPDATE wallets SET version = version + 1 WHERE user_uuid = ?`, userUUID.String())
+		require.NoError(t, err)
+	}
+	tx, err = db.Beginx()
+	require.NoError(t, err)
+	_, err = repo.Credit(context.Background(), tx, &userUUID, 10_00)
+	assert.ErrorIs(t, err, ErrWalletVersionConflict)
+	require.NoError(t, tx.Rollback())
+}
+
+func TestWalletRepositoryImpl_CreditOnce(t *testing.T) {
+	db := setupInMemoryWalletDB(t)
+	defer db.Close()
+	ctx := context.Background()
+	walletRepo := NewWalletRepository(db)
+
+	userUUID := uuid.New()
+	_, err := db.Exec(`INSERT INTO wallets (user_uuid, credits) VALUES (?, 5)`, userUUID.String())
+	require.NoError(t, err)
+
+	creditOnce := func(key string, amount Cents) (wallet *Wallet, credited bool) {
+		require.NoError(t, WithTransaction(ctx, db, func(tx *sqlx.Tx) (err error) {
+			wallet, credited, err = walletRepo.CreditOnce(ctx, tx, &userUUID, amount, key)
+			return err
+		}))
+		return wallet, credited
+	}
+	wallet, credited := creditOnce("order/1", 10_00)
+	assert.True(t, credited)
+	assert.Equal(t, 15.0, wallet.Credits)
+
+	// a retry with the same key leaves the wallet and the log alone
+	wallet, credited = creditOnce("order/1", 10_00)
+	assert.False(t, credited)
+	assert.Equal(t, 15.0, wallet.Credits)
+
+	wallet, credited = creditOnce("order/2", 3_00)
+	assert.True(t, credited)
+	assert.Equal(t, 18.0, wallet.Credits)
+
+	var logged int
+	require.NoError(t, db.Get(&logged, `SELECT count(*) FROM wallet_transactions WHERE user_uuid = ? AND idempotency_key = 'order/1'`,
+		userUUID.String()))
+	assert.Equal(t, 1, logged)
+
+	// the same order number of another tenant's user is credited to that user
+	otherUUID := uuid.New()
+	_, err = db.Exec(`INSERT INTO wallets (user_uuid) VALUES (?)`, otherUUID.String())
+	require.NoError(t, err)
+	require.NoError(t, WithTransaction(ctx, db, func(tx *sqlx.Tx) error {
+		other, credited, err := walletRepo.CreditOnce(ctx, tx, &otherUUID, 10_00, "order/1")
+		require.NoError(t, err)
+		assert.True(t, credited)
+		assert.Equal(t, 10.0, other.Credits)
+		return nil
+	}))
+
+	missing := uuid.New()
+	err = WithTransaction(ctx, db, func(tx *sqlx.Tx) error {
+		_, _, err := walletRepo.CreditOnce(ctx, tx, &missing, 1_00, "order/3")
+		return err
+	})
+	assert.ErrorIs(t, err, ErrWalletNotFound)
+}
+
+func TestWalletRepositoryImpl_FixDrift_StaleVersion(t *testing.T) {
+	db := setupInMemoryWalletDB(t)
+	defer db.Close()
+	ctx := context.Background()
+	walletRepo := NewWalletRepository(db)
+
+	userUUID := uuid.New()
+	_, err := db.Exec(`INSERT INTO wallets (user_uuid, credits) VALUES (?, 5)`, userUUID.String())
+	require.NoError(t, err)
+	drifts, err := walletRepo.FindDrifts(ctx)
+	require.NoError(t, err)
+	require.Len(t, drifts, 1)
+
+	// the wallet changes between finding and fixing the drift
+	require.NoError(t, WithTransaction(ctx, db, func(tx *sqlx.Tx) error {
+		_, err := walletRepo.Credit(ctx, tx, &userUUID, 1_00)
+		return err
+	}))
+	fixed, err := walletRepo.FixDrift(ctx, &drifts[0])
+	require.NoError(t, err)
+	assert.False(t, fixed)
+
+	wallet, err := walletRepo.GetWallet(ctx, &userUUID)
+	require.NoError(t, err)
+	assert.Equal(t, 6.0, wallet.Credits, "a stale fix must not overwrite the newer credit")
+}
+
 func TestWalletRepositoryImpl_Debit(t *testing.T) {
 	db := setupInMemoryWalletDB(t)
 	defer db.Close()
@@ -171,7 +348,7 @@ func TestWalletRepositoryImpl_Debit(t *testing.T) {
 
 	initialCredits := 100.0
 	initialDebits := 20.0
-	debitAmount := 30.0
+	debitAmount := Cents(30_00)
 
 	// Insert a test wallet into the database for existing user
 	_, err := db.Exec(`INSERT INTO wallets (user_uuid, credits, debits) 
@@ -184,7 +361,7 @@ func TestWalletRepositoryImpl_Debit(t *testing.T) {
 	tests := []struct {
 		name              string
 		userUUID          *uuid.UUID
-		amount            float64
+		amount            Cents
 		wantErr           bool
 		wantDebits        float64
 		shouldCheckWallet bool
@@ -194,7 +371,7 @@ func TestWalletRepositoryImpl_Debit(t *testing.T) {
 			userUUID:          &userUUID,
 			amount:            debitAmount,
 			wantErr:           false,
-			wantDebits:        initialDebits + debitAmount,
+			wantDebits:        initialDebits + debitAmount.Float64(),
 			shouldCheckWallet: false,
 		},
 		{
@@ -208,7 +385,7 @@ func TestWalletRepositoryImpl_Debit(t *testing.T) {
 		{
 			name:              "Invalid Debit Amount (Negative)",
 			userUUID:          &userUUID,
-			amount:            -1000.0,
+			amount:            -1000_00,
 			wantErr:           true,
 			wantDebits:        initialDebits, // No change expected
 			shouldCheckWallet: true,
@@ -230,7 +407,7 @@ func TestWalletRepositoryImpl_Debit(t *testing.T) {
 					var wallet Wallet
 					err := db.Get(&wallet, "SELECT * FROM wallets WHERE user_uuid = ?", tt.userUUID.String())
 					require.NoError(t, err)
-					assert.Equal(t, initialDebits+debitAmount, wallet.Debits, "Debits should remain unchanged after rollback")
+					assert.Equal(t, initialDebits+debitAmount.Float64(), wallet.Debits, "Debits should remain unchanged after rollback")
 				}
 			} else {
 				assert.NoError(t, err, "Debit should not fail")
@@ -294,3 +471,39 @@ func TestWalletRepositoryImpl_GetWallet(t *testing.T) {
 		})
 	}
 }
+
+func TestWalletRepositoryImpl_FindUnmatchedDebits(t *testing.T) {
+	db, err := sqlx.Open("sqlite3", "file:unmatched_debits?mode=memory&cache=shared")
+	require.NoError(t, err)
+	defer db.Close()
+	for _, schema := range []string{initUserDB, initWalletDB, initWithdrawalDB} {
+		_, err = db.Exec(schema)
+		require.NoError(t, err)
+	}
+
+	alice, bob, carol := uuid.New(), uuid.New(), uuid.New()
+	_, err = db.Exec(`INSERT INTO users (uuid, login, password_hash) VALUES (?, 'alice', 'h'), (?, 'bob', 'h'), (?, 'carol', 'h')`,
+		alice.String(), bob.String(), carol.String())
+	require.NoError(t, err)
+	// alice was debited 100 but has a single unreversed withdrawal of 60, bob's debits match,
+	// carol's reversed withdrawal was refunded and no longer counts
+	_, err = db.Exec(`INSERT INTO wallets (user_uuid, credits, debits) VALUES (?, 500, 100), (?, 500, 50), (?, 500, 0)`,
+		alice.String(), bob.String(), carol.String())
+	require.NoError(t, err)
+	_, err = db.Exec(`INSERT INTO withdrawals (id, user_uuid, order_id, amount) VALUES
+		(1, ?, 'alice-1', 60), (2, ?, 'bob-1', 30), (3, ?, 'bob-2', 20), (4, ?, 'carol-1', 40)`,
+		alice.String(), bob.String(), bob.String(), carol.String())
+	require.NoError(t, err)
+	_, err = db.Exec(`INSERT INTO withdrawal_reversals (withdrawal_id, user_uuid, order_id, amount) VALUES (4, ?, 'carol-1', 40)`,
+		carol.String())
+	require.NoError(t, err)
+
+	debits, err := NewWalletRepository(db).FindUnmatchedDebits(context.Background())
+	require.NoError(t, err)
+	require.Len(t, debits, 1)
+	assert.Equal(t, alice, debits[0].UserUUID)
+	assert.Equal(t, "alice", debits[0].Login)
+	assert.Equal(t, 100.0, debits[0].Debits)
+	assert.Equal(t, 60.0, debits[0].Withdrawn)
+	assert.Equal(t, 40.0, debits[0].Unmatched())
+}
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
+	"strings"
 	"time"
 )
 
@@ -15,42 +16,176 @@ type (
 		ID        int64     `db:"id"`
 		UserUUID  uuid.UUID `db:"user_uuid"`
 		OrderID   string    `db:"order_id"`
-		Amount    float64   `db:"amount"`
+		Amount    Cents     `db:"amount"`
 		CreatedAt time.Time `db:"created_at"`
+		// IdempotencyKey is the client's Idempotency-Key of the request that made the withdrawal, if it sent one
+		IdempotencyKey *string `db:"idempotency_key"`
+	}
+	// WithdrawalOutcome is a withdrawal together with the time it was reversed, nil while it stands.
+	WithdrawalOutcome struct {
+		Withdrawal
+		ReversedAt *time.Time `db:"reversed_at"`
+	}
+	// WithdrawalReversal records that a withdrawal was refunded to the user's wallet.
+	WithdrawalReversal struct {
+		ID           int64     `db:"id"`
+		WithdrawalID int64     `db:"withdrawal_id"`
+		UserUUID     uuid.UUID `db:"user_uuid"`
+		OrderID      string    `db:"order_id"`
+		Amount       Cents     `db:"amount"`
+		CreatedAt    time.Time `db:"created_at"`
 	}
 	WithdrawalsRepository interface {
 		CreateWithdrawal(ctx context.Context, tx *sqlx.Tx, withdrawal *Withdrawal) error
-		GetWithdrawals(ctx context.Context, userUID *uuid.UUID) (*[]Withdrawal, error)
+		GetWithdrawalByOrder(ctx context.Context, tx *sqlx.Tx, userUID *uuid.UUID, orderID string) (*Withdrawal, error)
+		GetWithdrawalByKey(ctx context.Context, tx *sqlx.Tx, userUID *uuid.UUID, key string) (*Withdrawal, error)
+		GetWithdrawalOutcome(ctx context.Context, userUID *uuid.UUID, key string) (*WithdrawalOutcome, error)
+		CreateReversal(ctx context.Context, tx *sqlx.Tx, reversal *WithdrawalReversal) (bool, error)
+		GetWithdrawals(ctx context.Context, userUID *uuid.UUID, limit int, offset int) (*[]Withdrawal, error)
+		GetWithdrawalsSorted(ctx context.Context, userUID *uuid.UUID, limit int, offset int, direction SortDirection) (*[]Withdrawal, error)
+		CountWithdrawals(ctx context.Context, userUID *uuid.UUID) (int, error)
+		SumWithdrawals(ctx context.Context, userUID *uuid.UUID) (float64, error)
+		SumWithdrawalsTx(ctx context.Context, tx *sqlx.Tx, userUID *uuid.UUID) (float64, error)
 		GetDB() *sqlx.DB
 	}
 	WithdrawalsRepositoryImpl struct {
-		db *sqlx.DB
+		db     *sqlx.DB
+		readDB *sqlx.DB
 	}
 )
 
+// SortDirection orders listings by creation time.
+type SortDirection string
+
+const (
+	SortAsc  SortDirection = "asc"
+	SortDesc SortDirection = "desc"
+)
+
+var (
+	// ErrWithdrawalNotFound is returned when the user has no withdrawal for the order.
+	ErrWithdrawalNotFound = errors.New("withdrawal not found")
+	// ErrWithdrawalExists is returned by CreateWithdrawal when the order already has a withdrawal.
+	ErrWithdrawalExists = errors.New("withdrawal already exists")
+	// ErrIdempotencyKeyUsed is returned by CreateWithdrawal when the user already made a withdrawal with the key.
+	ErrIdempotencyKeyUsed = errors.New("idempotency key already used")
+)
+
+// withdrawalIdempotencyKeyIndex tells a reused idempotency key apart from a second withdrawal for the order.
+const withdrawalIdempotencyKeyIndex = "withdrawals_idempotency_key_idx"
+
 func NewWithdrawalsRepository(db *sqlx.DB) *WithdrawalsRepositoryImpl {
-	return &WithdrawalsRepositoryImpl{db: db}
+	return &WithdrawalsRepositoryImpl{db: db, readDB: db}
+}
+
+// WithReadDB sends the read-heavy listing queries to readDB, e.g. a replica; writes keep using the primary.
+func (wr *WithdrawalsRepositoryImpl) WithReadDB(readDB *sqlx.DB) *WithdrawalsRepositoryImpl {
+	wr.readDB = readDB
+	return wr
 }
 
+// CreateWithdrawal inserts the withdrawal within tx and sets its ID.
 func (wr *WithdrawalsRepositoryImpl) CreateWithdrawal(ctx context.Context, tx *sqlx.Tx, withdrawal *Withdrawal) error {
-	query := `INSERT INTO withdrawals (user_uuid, order_id, amount, created_at) VALUES ($1, $2, $3, $4);`
+	query := `INSERT INTO withdrawals (user_uuid, order_id, amount, created_at, idempotency_key)
+			  VALUES ($1, $2, $3, $4, $5) returning id;`
 	stmt, err := tx.PrepareContext(ctx, query)
 	if err != nil {
 		return fmt.Errorf("prepare statement: %w", err)
 	}
 	defer stmt.Close()
 
-	_, err = stmt.ExecContext(ctx, withdrawal.UserUUID, withdrawal.OrderID, withdrawal.Amount, withdrawal.CreatedAt)
+	err = stmt.QueryRowContext(ctx, withdrawal.UserUUID, withdrawal.OrderID, withdrawal.Amount, withdrawal.CreatedAt,
+		withdrawal.IdempotencyKey).Scan(&withdrawal.ID)
 	if err != nil {
+		if violated, ok := uniqueViolation(err); ok {
+			if violated == withdrawalIdempotencyKeyIndex || strings.HasSuffix(violated, ".idempotency_key") {
+				return ErrIdempotencyKeyUsed
+			}
+			return ErrWithdrawalExists
+		}
 		return fmt.Errorf("exec statement: %w", err)
 	}
 	return nil
 }
 
-func (wr *WithdrawalsRepositoryImpl) GetWithdrawals(ctx context.Context, userUID *uuid.UUID) (*[]Withdrawal, error) {
-	query := `SELECT * FROM withdrawals WHERE user_uuid = $1 order by created_at;`
+func (wr *WithdrawalsRepositoryImpl) GetWithdrawalByOrder(ctx context.Context, tx *sqlx.Tx, userUID *uuid.UUID, orderID string) (*Withdrawal, error) {
+	query := `SELECT * FROM withdrawals WHERE user_uuid = $1 AND order_id = $2 order by id limit 1;`
+	withdrawal := Withdrawal{}
+	err := tx.GetContext(ctx, &withdrawal, query, userUID, orderID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("get withdrawal: %w", ErrWithdrawalNotFound)
+		}
+		return nil, fmt.Errorf("get withdrawal: %w", err)
+	}
+	return &withdrawal, nil
+}
+
+// GetWithdrawalByKey reads the user's withdrawal made with the idempotency key within tx.
+func (wr *WithdrawalsRepositoryImpl) GetWithdrawalByKey(ctx context.Context, tx *sqlx.Tx, userUID *uuid.UUID, key string) (*Withdrawal, error) {
+	query := `SELECT * FROM withdrawals WHERE user_uuid = $1 AND idempotency_key = $2;`
+	withdrawal := Withdrawal{}
+	err := tx.GetContext(ctx, &withdrawal, query, userUID, key)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("get withdrawal by key: %w", ErrWithdrawalNotFound)
+		}
+		return nil, fmt.Errorf("get withdrawal by key: %w", err)
+	}
+	return &withdrawal, nil
+}
+
+// GetWithdrawalOutcome returns the user's withdrawal made with the idempotency key and whether it was reversed since.
+// It reads from the primary, a client asking right after the withdrawal must not miss it on a lagging replica.
+func (wr *WithdrawalsRepositoryImpl) GetWithdrawalOutcome(ctx context.Context, userUID *uuid.UUID, key string) (*WithdrawalOutcome, error) {
+	query := `SELECT w.*, r.created_at AS reversed_at FROM withdrawals w
+			  LEFT JOIN withdrawal_reversals r ON r.withdrawal_id = w.id
+			  WHERE w.user_uuid = $1 AND w.idempotency_key = $2;`
+	outcome := WithdrawalOutcome{}
+	err := wr.db.GetContext(ctx, &outcome, query, userUID, key)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("get withdrawal outcome: %w", ErrWithdrawalNotFound)
+		}
+		return nil, fmt.Errorf("get withdrawal outcome: %w", err)
+	}
+	return &outcome, nil
+}
+
+// CreateReversal records the reversal unless the withdrawal has already been reversed.
+// It reports whether a new reversal was written, so concurrent callers refund only once.
+func (wr *WithdrawalsRepositoryImpl) CreateReversal(ctx context.Context, tx *sqlx.Tx, reversal *WithdrawalReversal) (bool, error) {
+	query := `INSERT INTO withdrawal_reversals (withdrawal_id, user_uuid, order_id, amount, created_at)
+			  VALUES ($1, $2, $3, $4, $5) ON CONFLICT (withdrawal_id) DO NOTHING returning id;`
+	err := tx.GetContext(ctx, &reversal.ID, query,
+		reversal.WithdrawalID, reversal.UserUUID, reversal.OrderID, reversal.Amount, reversal.CreatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, nil
+		}
+		return false, fmt.Errorf("create reversal: %w", err)
+	}
+	return true, nil
+}
+
+func (wr *WithdrawalsRepositoryImpl) GetWithdrawals(ctx context.Context, userUID *uuid.UUID, limit int, offset int) (*[]Withdrawal, error) {
+	return wr.GetWithdrawalsSorted(ctx, userUID, limit, offset, SortAsc)
+}
+
+// notReversed is the condition leaving out the reversed withdrawals w.
+const notReversed = `NOT EXISTS (SELECT 1 FROM withdrawal_reversals r WHERE r.withdrawal_id = w.id)`
+
+// GetWithdrawalsSorted lists the user's withdrawals oldest first for SortAsc and newest first for SortDesc.
+// Reversed withdrawals are left out, like SumWithdrawals leaves them out of the withdrawn balance.
+func (wr *WithdrawalsRepositoryImpl) GetWithdrawalsSorted(ctx context.Context, userUID *uuid.UUID, limit int, offset int, direction SortDirection) (*[]Withdrawal, error) {
+	order := "ASC"
+	if direction == SortDesc {
+		order = "DESC"
+	}
+	query := fmt.Sprintf(`SELECT w.* FROM withdrawals w WHERE w.user_uuid = $1 AND %s
+			  order by w.created_at %s, w.id %s limit $2 offset $3;`, notReversed, order, order)
 	withdrawals := make([]Withdrawal, 0)
-	err := wr.db.SelectContext(ctx, &withdrawals, query, userUID)
+	err := wr.readDB.SelectContext(ctx, &withdrawals, query, userUID, limit, offset)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return &withdrawals, nil
@@ -60,6 +195,40 @@ func (wr *WithdrawalsRepositoryImpl) GetWithdrawals(ctx context.Context, userUID
 	return &withdrawals, nil
 }
 
+// CountWithdrawals counts the user's withdrawals that weren't reversed, as the listing shows them.
+func (wr *WithdrawalsRepositoryImpl) CountWithdrawals(ctx context.Context, userUID *uuid.UUID) (int, error) {
+	query := `SELECT count(*) FROM withdrawals w WHERE w.user_uuid = $1 AND ` + notReversed + `;`
+	var count int
+	err := wr.readDB.GetContext(ctx, &count, query, userUID)
+	if err != nil {
+		return 0, fmt.Errorf("count withdrawals: %w", err)
+	}
+	return count, nil
+}
+
+// sumWithdrawalsQuery sums the user's withdrawals that were not reversed.
+const sumWithdrawalsQuery = `SELECT COALESCE(SUM(w.amount), 0) FROM withdrawals w WHERE w.user_uuid = $1
+			  AND ` + notReversed + `;`
+
+func (wr *WithdrawalsRepositoryImpl) SumWithdrawals(ctx context.Context, userUID *uuid.UUID) (float64, error) {
+	var sum float64
+	err := wr.db.GetContext(ctx, &sum, sumWithdrawalsQuery, userUID)
+	if err != nil {
+		return 0, fmt.Errorf("sum withdrawals: %w", err)
+	}
+	return sum, nil
+}
+
+// SumWithdrawalsTx is SumWithdrawals within tx.
+func (wr *WithdrawalsRepositoryImpl) SumWithdrawalsTx(ctx context.Context, tx *sqlx.Tx, userUID *uuid.UUID) (float64, error) {
+	var sum float64
+	err := tx.GetContext(ctx, &sum, sumWithdrawalsQuery, userUID)
+	if err != nil {
+		return 0, fmt.Errorf("sum withdrawals: %w", err)
+	}
+	return sum, nil
+}
+
 func (wr *WithdrawalsRepositoryImpl) GetDB() *sqlx.DB {
 	return wr.db
 }
@@ -2,9 +2,15 @@ package repository
 
 import (
 	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
 	"fmt"
 	"github.com/google/uuid"
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jmoiron/sqlx"
+	"github.com/mattn/go-sqlite3"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"testing"
@@ -19,8 +25,20 @@ CREATE TABLE IF NOT EXISTS withdrawals
     order_id TEXT NOT NULL,
     amount NUMERIC NOT NULL DEFAULT 0,
     created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    idempotency_key TEXT,
     CHECK (amount > 0)
 );
+CREATE UNIQUE INDEX IF NOT EXISTS withdrawals_order_id_idx ON withdrawals (order_id);
+CREATE UNIQUE INDEX IF NOT EXISTS withdrawals_idempotency_key_idx ON withdrawals (user_uuid, idempotency_key);
+CREATE TABLE IF NOT EXISTS withdrawal_reversals
+(
+    id INTEGER PRIMARY KEY,
+    withdrawal_id INTEGER UNIQUE NOT NULL,
+    user_uuid TEXT NOT NULL,
+    order_id TEXT NOT NULL,
+    amount NUMERIC NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
 `
 
 func setupInMemoryWithdrawalDB(t *testing.T) *sqlx.DB {
@@ -51,7 +69,7 @@ func TestWithdrawalsRepositoryImpl_CreateWithdrawal(t *testing.T) {
 			withdrawal: &Withdrawal{
 				UserUUID:  uuid.New(),
 				OrderID:   "order123",
-				Amount:    100.0,
+				Amount:    100_00,
 				CreatedAt: time.Now(),
 			},
 			wantErr: false,
@@ -90,6 +108,78 @@ func TestWithdrawalsRepositoryImpl_CreateWithdrawal(t *testing.T) {
 	}
 }
 
+// pgErrorsConnector connects to sqlite3 and reports unique constraint failures the way pgx does,
+// so the Postgres specific error handling can be exercised without a Postgres server.
+// It is passed to sql.OpenDB, so no driver has to be registered globally.
+type pgErrorsConnector struct{ dsn string }
+
+type pgErrorsConn struct{ driver.Conn }
+
+type pgErrorsStmt struct{ driver.Stmt }
+
+func (c pgErrorsConnector) Connect(context.Context) (driver.Conn, error) {
+	conn, err := c.Driver().Open(c.dsn)
+	if err != nil {
+		return nil, err
+	}
+	return pgErrorsConn{conn}, nil
+}
+
+func (pgErrorsConnector) Driver() driver.Driver {
+	return &sqlite3.SQLiteDriver{}
+}
+
+func (c pgErrorsConn) Prepare(query string) (driver.Stmt, error) {
+	stmt, err := c.Conn.Prepare(query)
+	if err != nil {
+		return nil, err
+	}
+	return pgErrorsStmt{stmt}, nil
+}
+
+func (s pgErrorsStmt) Exec(args []driver.Value) (driver.Result, error) {
+	result, err := s.Stmt.Exec(args)
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique {
+		return nil, &pgconn.PgError{Code: pgerrcode.UniqueViolation, Message: sqliteErr.Error()}
+	}
+	return result, err
+}
+
+func TestWithdrawalsRepositoryImpl_CreateWithdrawal_UniqueViolation(t *testing.T) {
+	const dsn = "file:withdrawal_unique_violation?mode=memory&cache=shared"
+	// the schema is created through the plain driver, the wrapper can only run single statements
+	schemaDB, err := sqlx.Open("sqlite3", dsn)
+	require.NoError(t, err)
+	defer schemaDB.Close()
+	_, err = schemaDB.Exec(initWithdrawalDB)
+	require.NoError(t, err)
+	db := sqlx.NewDb(sql.OpenDB(pgErrorsConnector{dsn: dsn}), "sqlite3")
+	defer db.Close()
+
+	repo := NewWithdrawalsRepository(db)
+	create := func(userUUID uuid.UUID) error {
+		tx, err := db.Beginx()
+		require.NoError(t, err)
+		err = repo.CreateWithdrawal(context.Background(), tx, &Withdrawal{
+			UserUUID: userUUID, OrderID: "unique-order", Amount: 10_00, CreatedAt: time.Now(),
+		})
+		if err != nil {
+			require.NoError(t, tx.Rollback())
+			return err
+		}
+		return tx.Commit()
+	}
+
+	require.NoError(t, create(uuid.New()))
+	err = create(uuid.New())
+	assert.ErrorIs(t, err, ErrWithdrawalExists)
+
+	var count int
+	require.NoError(t, db.Get(&count, `SELECT COUNT(*) FROM withdrawals WHERE order_id = 'unique-order'`))
+	assert.Equal(t, 1, count)
+}
+
 func TestWithdrawalsRepositoryImpl_GetWithdrawals(t *testing.T) {
 	db := setupInMemoryWithdrawalDB(t)
 	defer db.Close()
@@ -125,7 +215,7 @@ func TestWithdrawalsRepositoryImpl_GetWithdrawals(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := repo.GetWithdrawals(context.Background(), tt.userUUID)
+			got, err := repo.GetWithdrawals(context.Background(), tt.userUUID, 10, 0)
 
 			if tt.wantErr {
 				assert.Error(t, err, "GetWithdrawals should fail")
@@ -144,3 +234,172 @@ func insertTestWithdrawal(db *sqlx.DB, userUUID uuid.UUID, orderID string, amoun
 		panic(fmt.Sprintf("Failed to insert test withdrawal: %v", err))
 	}
 }
+
+func TestWithdrawalsRepositoryImpl_GetWithdrawalsSorted(t *testing.T) {
+	db := setupInMemoryWithdrawalDB(t)
+	defer db.Close()
+
+	userUUID := uuid.New()
+	// inserted within the same second, the id keeps the order stable
+	for _, orderID := range []string{"sorted-1", "sorted-2", "sorted-3"} {
+		insertTestWithdrawal(db, userUUID, orderID, 10.0)
+	}
+	repo := NewWithdrawalsRepository(db)
+
+	orderIDs := func(direction SortDirection) []string {
+		withdrawals, err := repo.GetWithdrawalsSorted(context.Background(), &userUUID, 10, 0, direction)
+		require.NoError(t, err)
+		ids := make([]string, 0, len(*withdrawals))
+		for _, w := range *withdrawals {
+			ids = append(ids, w.OrderID)
+		}
+		return ids
+	}
+	assert.Equal(t, []string{"sorted-1", "sorted-2", "sorted-3"}, orderIDs(SortAsc))
+	assert.Equal(t, []string{"sorted-3", "sorted-2", "sorted-1"}, orderIDs(SortDesc))
+}
+
+func TestWithdrawalsRepositoryImpl_CountWithdrawals(t *testing.T) {
+	db := setupInMemoryWithdrawalDB(t)
+	defer db.Close()
+
+	userUUID, otherUUID := uuid.New(), uuid.New()
+	for _, orderID := range []string{"count-1", "count-2", "count-3"} {
+		insertTestWithdrawal(db, userUUID, orderID, 10.0)
+	}
+	insertTestWithdrawal(db, otherUUID, "count-other", 10.0)
+	repo := NewWithdrawalsRepository(db)
+
+	count, err := repo.CountWithdrawals(context.Background(), &userUUID)
+	require.NoError(t, err)
+	assert.Equal(t, 3, count)
+
+	noWithdrawals := uuid.New()
+	count, err = repo.CountWithdrawals(context.Background(), &noWithdrawals)
+	require.NoError(t, err)
+	assert.Zero(t, count)
+}
+
+func TestWithdrawalsRepositoryImpl_SumWithdrawals(t *testing.T) {
+	db := setupInMemoryWithdrawalDB(t)
+	defer db.Close()
+
+	userUUID := uuid.New()
+	newUserUID := uuid.New()
+
+	repo := NewWithdrawalsRepository(db)
+
+	insertTestWithdrawal(db, userUUID, "order1", 100.0)
+	insertTestWithdrawal(db, userUUID, "order2", 50.5)
+	insertTestWithdrawal(db, newUserUID, "order3", 10.0)
+
+	got, err := repo.SumWithdrawals(context.Background(), &userUUID)
+	assert.NoError(t, err, "SumWithdrawals should not fail")
+	assert.Equal(t, 150.5, got, "Unexpected withdrawals sum")
+}
+
+func TestWithdrawalsRepositoryImpl_CreateReversal(t *testing.T) {
+	db := setupInMemoryWithdrawalDB(t)
+	defer db.Close()
+
+	userUUID := uuid.New()
+	insertTestWithdrawal(db, userUUID, "reversed-order", 100.0)
+	insertTestWithdrawal(db, userUUID, "kept-order", 20.0)
+
+	repo := NewWithdrawalsRepository(db)
+	ctx := context.Background()
+
+	tx, err := db.Beginx()
+	require.NoError(t, err)
+	withdrawal, err := repo.GetWithdrawalByOrder(ctx, tx, &userUUID, "reversed-order")
+	require.NoError(t, err)
+	reversal := &WithdrawalReversal{
+		WithdrawalID: withdrawal.ID,
+		UserUUID:     userUUID,
+		OrderID:      withdrawal.OrderID,
+		Amount:       withdrawal.Amount,
+		CreatedAt:    time.Now(),
+	}
+	created, err := repo.CreateReversal(ctx, tx, reversal)
+	require.NoError(t, err)
+	assert.True(t, created, "first reversal should be written")
+	assert.NotZero(t, reversal.ID)
+
+	created, err = repo.CreateReversal(ctx, tx, &WithdrawalReversal{WithdrawalID: withdrawal.ID, UserUUID: userUUID,
+		OrderID: withdrawal.OrderID, Amount: withdrawal.Amount, CreatedAt: time.Now()})
+	require.NoError(t, err)
+	assert.False(t, created, "second reversal of the same withdrawal should be skipped")
+
+	_, err = repo.GetWithdrawalByOrder(ctx, tx, &userUUID, "missing-order")
+	assert.ErrorIs(t, err, ErrWithdrawalNotFound)
+	require.NoError(t, tx.Commit())
+
+	sum, err := repo.SumWithdrawals(ctx, &userUUID)
+	require.NoError(t, err)
+	assert.Equal(t, 20.0, sum, "reversed withdrawals should not count")
+
+	withdrawals, err := repo.GetWithdrawalsSorted(ctx, &userUUID, 10, 0, SortAsc)
+	require.NoError(t, err)
+	require.Len(t, *withdrawals, 1, "reversed withdrawals should not be listed")
+	assert.Equal(t, "kept-order", (*withdrawals)[0].OrderID)
+	count, err := repo.CountWithdrawals(ctx, &userUUID)
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+}
+
+func TestWithdrawalsRepositoryImpl_GetWithdrawalOutcome(t *testing.T) {
+	db := setupInMemoryWithdrawalDB(t)
+	defer db.Close()
+
+	userUUID := uuid.New()
+	repo := NewWithdrawalsRepository(db)
+	ctx := context.Background()
+
+	tx, err := db.Beginx()
+	require.NoError(t, err)
+	for _, w := range []struct{ orderID, key string }{{"keyed-order", "key-1"}, {"reversed-keyed-order", "key-2"}} {
+		key := w.key
+		require.NoError(t, repo.CreateWithdrawal(ctx, tx, &Withdrawal{UserUUID: userUUID, OrderID: w.orderID,
+			Amount: 10_00, CreatedAt: time.Now(), IdempotencyKey: &key}))
+	}
+	withdrawal, err := repo.GetWithdrawalByKey(ctx, tx, &userUUID, "key-2")
+	require.NoError(t, err)
+	assert.Equal(t, "reversed-keyed-order", withdrawal.OrderID)
+	_, err = repo.CreateReversal(ctx, tx, &WithdrawalReversal{WithdrawalID: withdrawal.ID, UserUUID: userUUID,
+		OrderID: withdrawal.OrderID, Amount: withdrawal.Amount, CreatedAt: time.Now()})
+	require.NoError(t, err)
+	require.NoError(t, tx.Commit())
+
+	outcome, err := repo.GetWithdrawalOutcome(ctx, &userUUID, "key-1")
+	require.NoError(t, err)
+	assert.Equal(t, "keyed-order", outcome.OrderID)
+	require.NotNil(t, outcome.IdempotencyKey)
+	assert.Equal(t, "key-1", *outcome.IdempotencyKey)
+	assert.Nil(t, outcome.ReversedAt)
+
+	outcome, err = repo.GetWithdrawalOutcome(ctx, &userUUID, "key-2")
+	require.NoError(t, err)
+	assert.NotNil(t, outcome.ReversedAt)
+
+	// keys are per user
+	otherUUID := uuid.New()
+	_, err = repo.GetWithdrawalOutcome(ctx, &otherUUID, "key-1")
+	assert.ErrorIs(t, err, ErrWithdrawalNotFound)
+	_, err = repo.GetWithdrawalOutcome(ctx, &userUUID, "unknown-key")
+	assert.ErrorIs(t, err, ErrWithdrawalNotFound)
+}
+
+func TestWithdrawalsRepositoryImpl_WithReadDB(t *testing.T) {
+	primary, replica := setupPrimaryAndReplica(t, "withdrawals", initWithdrawalDB)
+	defer primary.Close()
+	defer replica.Close()
+
+	userUUID := uuid.New()
+	insertTestWithdrawal(replica, userUUID, "replica-order", 30.0)
+
+	repo := NewWithdrawalsRepository(primary).WithReadDB(replica)
+	withdrawals, err := repo.GetWithdrawals(context.Background(), &userUUID, 10, 0)
+	require.NoError(t, err)
+	require.Len(t, *withdrawals, 1, "withdrawal listing should read from the replica")
+	assert.Equal(t, "replica-order", (*withdrawals)[0].OrderID)
+}
@@ -4,35 +4,86 @@ import (
 	"github.com/go-chi/chi/v5"
 	httpSwagger "github.com/swaggo/http-swagger"
 	_ "github.com/ujwegh/gophermart/docs"
+	"github.com/ujwegh/gophermart/internal/app/config"
 	"github.com/ujwegh/gophermart/internal/app/handlers"
 	middlware "github.com/ujwegh/gophermart/internal/app/middleware"
 )
 
+// NewAppRouter mounts the routes of optional features only when featureEnabled reports them on.
 func NewAppRouter(serverAddress string,
+	gzipMinSize int,
+	featureEnabled func(name string) bool,
 	uh *handlers.UserHandler,
 	oh *handlers.OrdersHandler,
 	bh *handlers.BalanceHandler,
-	am middlware.AuthMiddleware) *chi.Mux {
+	lh *handlers.LedgerHandler,
+	sh *handlers.StatsHandler,
+	dvh *handlers.DeviceHandler,
+	ah *handlers.AdminHandler,
+	mh *handlers.MetricsHandler,
+	mgh *handlers.MigrationsHandler,
+	mth *handlers.MetaHandler,
+	hh *handlers.HealthHandler,
+	dh *handlers.DevHandler,
+	am middlware.AuthMiddleware,
+	al *middlware.AccessLogger,
+	rl *middlware.IPRateLimiter,
+	sg *middlware.ShutdownGuard) *chi.Mux {
 	r := chi.NewRouter()
 
+	r.Use(middlware.Recoverer)
+	r.Use(sg.Handler)
 	r.Use(middlware.SetupCORS())
+	r.Use(middlware.Gzip(gzipMinSize))
 	r.Get("/swagger/*", httpSwagger.Handler(
 		httpSwagger.URL("http://"+serverAddress+"/swagger/doc.json"),
 	))
+	r.Get("/readyz", mgh.Ready)
+	r.Get("/healthz/processor", hh.ProcessorHealth)
 
 	r.Group(func(r chi.Router) {
-		r.Use(middlware.RequestLogger)
-		r.Use(middlware.ResponseLogger)
+		r.Use(al.RequestLogger)
+		r.Use(al.ResponseLogger)
+		r.Use(rl.Handler)
 		r.Post("/api/user/register", uh.Register)
 		r.Post("/api/user/login", uh.Login)
+		r.Post("/api/user/token/renew", uh.RenewToken)
+		r.Get("/api/meta/statuses", mth.GetStatuses)
+		if featureEnabled(config.FeatureDevEndpoints) {
+			r.Get("/api/dev/order-number", dh.GenerateOrderNumber)
+		}
 
 		r.Group(func(r chi.Router) {
 			r.Use(am.Authenticate)
+			r.Patch("/api/user/profile", uh.UpdateProfile)
 			r.Post("/api/user/orders", oh.CreateOrder)
 			r.Get("/api/user/orders", oh.GetOrders)
+			r.Get("/api/user/orders/{number}/history", oh.GetOrderHistory)
+			r.Post("/api/user/orders/{number}/retry", oh.RetryOrder)
 			r.Get("/api/user/balance", bh.GetBalance)
+			r.Get("/api/user/wallet", bh.GetWallet)
 			r.Post("/api/user/balance/withdraw", bh.Withdraw)
 			r.Get("/api/user/withdrawals", bh.GetWithdrawals)
+			r.Get("/api/user/withdrawals/by-key/{key}", bh.GetWithdrawalByKey)
+			r.Get("/api/user/ledger", lh.GetLedger)
+			r.Get("/api/user/stats", sh.GetStats)
+			r.Get("/api/user/devices/{device}/marker", dvh.GetMarker)
+			r.Put("/api/user/devices/{device}/marker", dvh.SetMarker)
+		})
+
+		r.Group(func(r chi.Router) {
+			r.Use(am.AuthenticateAdmin)
+			r.Get("/admin/reconcile/{login}", ah.Reconcile)
+			r.Get("/admin/orders", ah.ListOrders)
+			r.Post("/admin/orders/retry", ah.RetryOrders)
+			r.Get("/admin/orders/dead-letter", ah.ListDeadLetterOrders)
+			r.Post("/admin/orders/dead-letter/requeue", ah.RequeueDeadLetterOrders)
+			r.Post("/admin/orders/{number}/accrual", ah.CorrectAccrual)
+			r.Post("/admin/withdrawals/reconcile", ah.ReconcileDebits)
+			r.Post("/admin/withdrawals/{login}/{order}/reverse", ah.ReverseWithdrawal)
+			r.Get("/admin/metrics", mh.GetMetrics)
+			r.Get("/admin/queue", mh.GetQueue)
+			r.Get("/admin/migrations", mgh.GetMigrationStatus)
 		})
 	})
 
@@ -0,0 +1,51 @@
+package router
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/ujwegh/gophermart/internal/app/config"
+	"github.com/ujwegh/gophermart/internal/app/handlers"
+	middlware "github.com/ujwegh/gophermart/internal/app/middleware"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNewAppRouter_DevEndpoints(t *testing.T) {
+	tests := []struct {
+		name       string
+		c          config.AppConfig
+		wantStatus int
+	}{
+		{
+			name:       "Mounted In Dev",
+			c:          config.AppConfig{Environment: config.EnvDev, Features: map[string]bool{config.FeatureDevEndpoints: true}},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "Not Found In Prod",
+			c:          config.AppConfig{Environment: config.EnvProd, Features: map[string]bool{config.FeatureDevEndpoints: true}},
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "Not Found With The Feature Off",
+			c:          config.AppConfig{Environment: config.EnvDev, Features: map[string]bool{config.FeatureCookieAuth: true}},
+			wantStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			al, err := middlware.NewAccessLogger(middlware.AccessLogOff, false)
+			require.NoError(t, err)
+			r := NewAppRouter("localhost:8080", -1, tt.c.FeatureEnabled, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil,
+				nil, handlers.NewDevHandler(), middlware.NewAuthMiddleware(nil, nil, 1, nil), al,
+				middlware.NewIPRateLimiter(0, nil), middlware.NewShutdownGuard())
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/dev/order-number", nil))
+
+			assert.Equal(t, tt.wantStatus, w.Code)
+		})
+	}
+}
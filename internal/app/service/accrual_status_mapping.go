@@ -0,0 +1,43 @@
+package service
+
+import (
+	"fmt"
+	"github.com/ujwegh/gophermart/internal/app/repository"
+	"github.com/ujwegh/gophermart/internal/app/service/clients"
+)
+
+// AccrualStatusMapping translates the statuses reported by the accrual system into order statuses.
+type AccrualStatusMapping map[clients.AccrualStatus]repository.Status
+
+// DefaultAccrualStatusMapping returns the mapping for the statuses of the reference accrual system.
+func DefaultAccrualStatusMapping() AccrualStatusMapping {
+	return AccrualStatusMapping{
+		clients.REGISTERED: repository.NEW,
+		clients.PROCESSING: repository.PROCESSING,
+		clients.INVALID:    repository.INVALID,
+		clients.PROCESSED:  repository.PROCESSED,
+	}
+}
+
+// NewAccrualStatusMapping returns the default mapping with overrides, accrual status to order status, applied on top.
+func NewAccrualStatusMapping(overrides map[string]string) (AccrualStatusMapping, error) {
+	mapping := DefaultAccrualStatusMapping()
+	for accrualStatus, orderStatus := range overrides {
+		status := repository.Status(orderStatus)
+		switch status {
+		case repository.NEW, repository.PROCESSING, repository.INVALID, repository.PROCESSED:
+		default:
+			return nil, fmt.Errorf("accrual status %q: unknown order status %q", accrualStatus, orderStatus)
+		}
+		mapping[clients.AccrualStatus(accrualStatus)] = status
+	}
+	return mapping, nil
+}
+
+// Map returns the order status for an accrual status; statuses the mapping doesn't know make the order INVALID.
+func (m AccrualStatusMapping) Map(status clients.AccrualStatus) repository.Status {
+	if orderStatus, ok := m[status]; ok {
+		return orderStatus
+	}
+	return repository.INVALID
+}
@@ -0,0 +1,24 @@
+package service
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/ujwegh/gophermart/internal/app/repository"
+	"github.com/ujwegh/gophermart/internal/app/service/clients"
+	"testing"
+)
+
+func TestNewAccrualStatusMapping(t *testing.T) {
+	mapping, err := NewAccrualStatusMapping(map[string]string{"DONE": "PROCESSED", "REGISTERED": "PROCESSING"})
+	require.NoError(t, err)
+
+	assert.Equal(t, repository.PROCESSED, mapping.Map("DONE"))
+	assert.Equal(t, repository.PROCESSING, mapping.Map(clients.REGISTERED), "overrides replace the default")
+	assert.Equal(t, repository.PROCESSED, mapping.Map(clients.PROCESSED), "defaults stay in place")
+	assert.Equal(t, repository.INVALID, mapping.Map("UNKNOWN"))
+
+	_, err = NewAccrualStatusMapping(map[string]string{"DONE": "FINISHED"})
+	assert.ErrorContains(t, err, `unknown order status "FINISHED"`)
+	_, err = NewAccrualStatusMapping(map[string]string{"DONE": ""})
+	assert.Error(t, err)
+}
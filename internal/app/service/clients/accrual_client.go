@@ -2,25 +2,35 @@ package clients
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"github.com/sethgrid/pester"
 	"github.com/ujwegh/gophermart/internal/app/config"
 	"github.com/ujwegh/gophermart/internal/app/logger"
-	"go.uber.org/ratelimit"
 	"go.uber.org/zap"
 	"io"
 	"net/http"
+	"net/url"
 	"time"
 )
 
 type (
 	AccrualClient interface {
 		GetOrderInfo(orderID string) (*AccrualResponseDto, error)
+		Version(ctx context.Context) (string, error)
 	}
 	AccrualClientImpl struct {
-		ServiceURL   string
-		pesterClient *pester.Client
-		rateLimiter  ratelimit.Limiter
+		ServiceURL    string
+		versionPath   string
+		userAgent     string
+		basicAuthUser string
+		basicAuthPass string
+		pesterClient  *pester.Client
+		rateLimiter   *adaptiveLimiter
+		totalDeadline time.Duration
+		breaker       *circuitBreaker
+		unit          config.AccrualUnit
 	}
 	//easyjson:json
 	AccrualResponseDto struct {
@@ -28,8 +38,13 @@ type (
 		AccrualStatus AccrualStatus `json:"status"`
 		Accrual       float64       `json:"accrual"`
 	}
+	//easyjson:json
+	AccrualVersionDto struct {
+		Version string `json:"version"`
+	}
 	LoggingRoundTripper struct {
-		Proxied http.RoundTripper
+		Proxied   http.RoundTripper
+		LogBodies bool
 	}
 	responseRecorder struct {
 		http.ResponseWriter
@@ -48,34 +63,99 @@ const (
 	PROCESSED  AccrualStatus = "PROCESSED"
 )
 
+var (
+	// ErrOrderMismatch is returned when the accrual service answers with data for another order.
+	ErrOrderMismatch = errors.New("accrual response order mismatch")
+	// ErrOrderNotRegistered is returned when the accrual service does not know the order yet
+	// (204 No Content, or 200 with an empty body from some implementations).
+	ErrOrderNotRegistered = errors.New("order not registered in accrual system")
+)
+
 func NewAccrualClient(c config.AppConfig) *AccrualClientImpl {
-	ratePerSecond := c.AccrualMaxRequestsPerMinute / 1
+	// a zero rate would make the limiter divide by zero, a zero deadline would fail every request.
+	// The setting has always been applied per second despite its name.
+	requestsPerSecond := atLeastOne("accrual max requests per minute", c.AccrualMaxRequestsPerMinute)
+	requestTimeoutSec := atLeastOne("accrual request timeout", c.AccrualSystemRequestTimeoutSec)
+	totalDeadlineSec := atLeastOne("accrual total deadline", c.AccrualTotalDeadlineSec)
 
-	rateLimiter := ratelimit.New(ratePerSecond)
+	rateLimiter := newAdaptiveLimiter(requestsPerSecond)
 	pesterClient := pester.New()
 
 	pesterClient.Concurrency = 1 // Since we are rate-limiting, concurrency should be 1
 	pesterClient.MaxRetries = 0
 	pesterClient.KeepLog = true
-	pesterClient.Timeout = time.Duration(c.AccrualSystemRequestTimeoutSec) * time.Second
+	pesterClient.Timeout = time.Duration(requestTimeoutSec) * time.Second
 	pesterClient.RetryOnHTTP429 = false
-	pesterClient.Transport = &LoggingRoundTripper{Proxied: http.DefaultTransport}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = accrualProxy(c.AccrualProxyURL, http.ProxyFromEnvironment)
+	pesterClient.Transport = &LoggingRoundTripper{Proxied: transport, LogBodies: c.AccrualLogBodies}
 
 	return &AccrualClientImpl{
-		ServiceURL:   c.AccrualSystemAddress,
-		pesterClient: pesterClient,
-		rateLimiter:  rateLimiter,
+		ServiceURL:    c.AccrualSystemAddress,
+		versionPath:   c.AccrualVersionPath,
+		userAgent:     c.AccrualUserAgent,
+		basicAuthUser: c.AccrualBasicAuthUser,
+		basicAuthPass: c.AccrualBasicAuthPassword,
+		pesterClient:  pesterClient,
+		rateLimiter:   rateLimiter,
+		totalDeadline: time.Duration(totalDeadlineSec) * time.Second,
+		breaker:       newCircuitBreaker(c.AccrualBreakerFailures, time.Duration(c.AccrualBreakerCooldownSec)*time.Second),
+		unit:          c.AccrualUnit,
+	}
+}
+
+// atLeastOne returns value, or 1 with a warning when value is not positive.
+func atLeastOne(name string, value int) int {
+	if value >= 1 {
+		return value
 	}
+	logger.Log.Warn("invalid accrual client setting, falling back to 1", zap.String("setting", name), zap.Int("value", value))
+	return 1
 }
 
+// accrualProxy routes requests through the proxy at rawURL. Without one, or with an invalid one,
+// fromEnv applies, which is http.ProxyFromEnvironment outside the tests, as for any other client.
+func accrualProxy(rawURL string, fromEnv func(*http.Request) (*url.URL, error)) func(*http.Request) (*url.URL, error) {
+	if rawURL == "" {
+		return fromEnv
+	}
+	proxyURL, err := url.Parse(rawURL)
+	if err != nil || proxyURL.Host == "" {
+		// the URL may carry proxy credentials, so it isn't logged
+		logger.Log.Warn("invalid accrual proxy URL, falling back to the proxy environment variables")
+		return fromEnv
+	}
+	return http.ProxyURL(proxyURL)
+}
+
+// GetOrderInfo fails fast with a CircuitOpenError while the accrual service keeps failing.
 func (ac *AccrualClientImpl) GetOrderInfo(orderID string) (*AccrualResponseDto, error) {
+	if err := ac.breaker.allow(); err != nil {
+		return nil, err
+	}
+	dto, err := ac.getOrderInfo(orderID)
+	// unknown or mismatched orders still mean the service answered
+	ac.breaker.record(err == nil || errors.Is(err, ErrOrderNotRegistered) || errors.Is(err, ErrOrderMismatch))
+	return dto, err
+}
+
+func (ac *AccrualClientImpl) getOrderInfo(orderID string) (*AccrualResponseDto, error) {
 	// Wait for the next available opportunity to send a request
 	ac.rateLimiter.Take()
 
-	resp, err := ac.pesterClient.Get(ac.ServiceURL + "/api/orders/" + orderID)
+	// Bound the whole lookup, retries and backoff included
+	ctx, cancel := context.WithTimeout(context.Background(), ac.totalDeadline)
+	defer cancel()
+
+	req, err := ac.newRequest(ctx, "/api/orders/"+orderID)
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %w", err)
+	}
+	resp, err := ac.pesterClient.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("error making request: %w", err)
 	}
+	ac.rateLimiter.record(resp.StatusCode)
 	body, err := io.ReadAll(resp.Body)
 	defer resp.Body.Close()
 
@@ -86,7 +166,10 @@ func (ac *AccrualClientImpl) GetOrderInfo(orderID string) (*AccrualResponseDto,
 	if resp.StatusCode != 200 && resp.StatusCode != 204 {
 		return nil, fmt.Errorf("error making request to get order info by orderID: %s", orderID)
 	} else if resp.StatusCode == 204 {
-		return nil, fmt.Errorf("order with orderID: " + orderID + " not registered yet")
+		return nil, fmt.Errorf("%w: %s", ErrOrderNotRegistered, orderID)
+	}
+	if len(bytes.TrimSpace(body)) == 0 {
+		return nil, fmt.Errorf("%w: %s (empty response body)", ErrOrderNotRegistered, orderID)
 	}
 
 	dto := &AccrualResponseDto{}
@@ -94,51 +177,115 @@ func (ac *AccrualClientImpl) GetOrderInfo(orderID string) (*AccrualResponseDto,
 	if err != nil {
 		return nil, fmt.Errorf("error unmarshalling response to DTO: %w", err)
 	}
+	if dto.OrderID != orderID {
+		return nil, fmt.Errorf("%w: requested %s, got %s", ErrOrderMismatch, orderID, dto.OrderID)
+	}
+	// callers and storage always deal in points
+	if ac.unit == config.AccrualUnitCents {
+		dto.Accrual /= 100
+	}
 
 	return dto, nil
 }
 
+// Version asks the accrual service which API version it runs, so response shape changes are noticed early.
+func (ac *AccrualClientImpl) Version(ctx context.Context) (string, error) {
+	ac.rateLimiter.Take()
+
+	req, err := ac.newRequest(ctx, ac.versionPath)
+	if err != nil {
+		return "", fmt.Errorf("error creating request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := ac.pesterClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("error making request: %w", err)
+	}
+	ac.rateLimiter.record(resp.StatusCode)
+	body, err := io.ReadAll(resp.Body)
+	defer resp.Body.Close()
+
+	if err != nil {
+		return "", fmt.Errorf("error reading response body: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("accrual version endpoint returned status %d", resp.StatusCode)
+	}
+
+	dto := &AccrualVersionDto{}
+	err = dto.UnmarshalJSON(body)
+	if err != nil {
+		return "", fmt.Errorf("error unmarshalling version response: %w", err)
+	}
+	if dto.Version == "" {
+		return "", errors.New("accrual version response has no version")
+	}
+	return dto.Version, nil
+}
+
+// newRequest builds a GET request to the accrual service, identifying this client by its User-Agent
+// and authenticating with basic auth when a user is configured.
+func (ac *AccrualClientImpl) newRequest(ctx context.Context, path string) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, ac.ServiceURL+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	if ac.userAgent != "" {
+		req.Header.Set("User-Agent", ac.userAgent)
+	}
+	if ac.basicAuthUser != "" {
+		req.SetBasicAuth(ac.basicAuthUser, ac.basicAuthPass)
+	}
+	return req, nil
+}
+
 func (ac *LoggingRoundTripper) RoundTrip(r *http.Request) (*http.Response, error) {
-	logRequest(r)
+	logRequest(r, ac.LogBodies)
 	response, err := ac.Proxied.RoundTrip(r)
 	if err != nil {
 		logger.Log.Error("accrual request error", zap.Error(err))
 		return nil, err
 	}
-	logResponse(response)
+	logResponse(response, ac.LogBodies)
 	return response, nil
 }
 
-func logResponse(response *http.Response) {
-	bodyBytes, err := io.ReadAll(response.Body)
-	if err != nil {
-		logger.Log.Error("accrual response error", zap.Error(err))
-		return
+func logResponse(response *http.Response, logBodies bool) {
+	fields := []zap.Field{
+		zap.Int("Status", response.StatusCode),
+		zap.Int64("Content-Length", response.ContentLength),
 	}
-	response.Body = io.NopCloser(bytes.NewBuffer(bodyBytes))
-	body := string(bodyBytes)
-	if len(body) == 0 {
-		body = "empty body"
+	if logBodies {
+		bodyBytes, err := io.ReadAll(response.Body)
+		if err != nil {
+			logger.Log.Error("accrual response error", zap.Error(err))
+			return
+		}
+		response.Body = io.NopCloser(bytes.NewBuffer(bodyBytes))
+		body := string(bodyBytes)
+		if len(body) == 0 {
+			body = "empty body"
+		}
+		fields = append(fields, zap.String("Body", body))
 	}
 
-	logger.Log.Info("ACCRUAL RESPONSE:",
-		zap.Int("Status", response.StatusCode),
-		zap.Int64("Content-Length", response.ContentLength),
-		zap.String("Body", body),
-	)
+	logger.Log.Info("ACCRUAL RESPONSE:", fields...)
 }
 
-func logRequest(r *http.Request) {
-	bodyMsg, err := getRequestBodyForLogging(r)
-	if err != nil {
-		logger.Log.Error("accrual log request error", zap.Error(err))
-		return
-	}
-	logger.Log.Info("ACCRUAL REQUEST:",
+func logRequest(r *http.Request, logBodies bool) {
+	fields := []zap.Field{
 		zap.String("Method", r.Method),
 		zap.String("Path", r.URL.String()),
-		zap.String("Body", bodyMsg),
-	)
+	}
+	if logBodies {
+		bodyMsg, err := getRequestBodyForLogging(r)
+		if err != nil {
+			logger.Log.Error("accrual log request error", zap.Error(err))
+			return
+		}
+		fields = append(fields, zap.String("Body", bodyMsg))
+	}
+	logger.Log.Info("ACCRUAL REQUEST:", fields...)
 }
 
 func getRequestBodyForLogging(r *http.Request) (string, error) {
@@ -17,7 +17,73 @@ var (
 	_ easyjson.Marshaler
 )
 
-func easyjson72d98f8bDecodeGithubComUjweghGophermartInternalAppServiceClients(in *jlexer.Lexer, out *AccrualResponseDto) {
+func easyjson72d98f8bDecodeGithubComUjweghGophermartInternalAppServiceClients(in *jlexer.Lexer, out *AccrualVersionDto) {
+	isTopLevel := in.IsStart()
+	if in.IsNull() {
+		if isTopLevel {
+			in.Consumed()
+		}
+		in.Skip()
+		return
+	}
+	in.Delim('{')
+	for !in.IsDelim('}') {
+		key := in.UnsafeFieldName(false)
+		in.WantColon()
+		if in.IsNull() {
+			in.Skip()
+			in.WantComma()
+			continue
+		}
+		switch key {
+		case "version":
+			out.Version = string(in.String())
+		default:
+			in.SkipRecursive()
+		}
+		in.WantComma()
+	}
+	in.Delim('}')
+	if isTopLevel {
+		in.Consumed()
+	}
+}
+func easyjson72d98f8bEncodeGithubComUjweghGophermartInternalAppServiceClients(out *jwriter.Writer, in AccrualVersionDto) {
+	out.RawByte('{')
+	first := true
+	_ = first
+	{
+		const prefix string = ",\"version\":"
+		out.RawString(prefix[1:])
+		out.String(string(in.Version))
+	}
+	out.RawByte('}')
+}
+
+// MarshalJSON supports json.Marshaler interface
+func (v AccrualVersionDto) MarshalJSON() ([]byte, error) {
+	w := jwriter.Writer{}
+	easyjson72d98f8bEncodeGithubComUjweghGophermartInternalAppServiceClients(&w, v)
+	return w.Buffer.BuildBytes(), w.Error
+}
+
+// MarshalEasyJSON supports easyjson.Marshaler interface
+func (v AccrualVersionDto) MarshalEasyJSON(w *jwriter.Writer) {
+	easyjson72d98f8bEncodeGithubComUjweghGophermartInternalAppServiceClients(w, v)
+}
+
+// UnmarshalJSON supports json.Unmarshaler interface
+func (v *AccrualVersionDto) UnmarshalJSON(data []byte) error {
+	r := jlexer.Lexer{Data: data}
+	easyjson72d98f8bDecodeGithubComUjweghGophermartInternalAppServiceClients(&r, v)
+	return r.Error()
+}
+
+// UnmarshalEasyJSON supports easyjson.Unmarshaler interface
+func (v *AccrualVersionDto) UnmarshalEasyJSON(l *jlexer.Lexer) {
+	easyjson72d98f8bDecodeGithubComUjweghGophermartInternalAppServiceClients(l, v)
+}
+func easyjson72d98f8bDecodeGithubComUjweghGophermartInternalAppServiceClients1(in *jlexer.Lexer, out *AccrualResponseDto) {
 	isTopLevel := in.IsStart()
 	if in.IsNull() {
 		if isTopLevel {
@@ -52,7 +118,7 @@ func easyjson72d98f8bDecodeGithubComUjweghGophermartInternalAppServiceClients(in
 		in.Consumed()
 	}
 }
-func easyjson72d98f8bEncodeGithubComUjweghGophermartInternalAppServiceClients(out *jwriter.Writer, in AccrualResponseDto) {
+func easyjson72d98f8bEncodeGithubComUjweghGophermartInternalAppServiceClients1(out *jwriter.Writer, in AccrualResponseDto) {
 	out.RawByte('{')
 	first := true
 	_ = first
@@ -77,23 +143,23 @@ func easyjson72d98f8bEncodeGithubComUjweghGophermartInternalAppServiceClients(ou
 // MarshalJSON supports json.Marshaler interface
 func (v AccrualResponseDto) MarshalJSON() ([]byte, error) {
 	w := jwriter.Writer{}
-	easyjson72d98f8bEncodeGithubComUjweghGophermartInternalAppServiceClients(&w, v)
+	easyjson72d98f8bEncodeGithubComUjweghGophermartInternalAppServiceClients1(&w, v)
 	return w.Buffer.BuildBytes(), w.Error
 }
 
 // MarshalEasyJSON supports easyjson.Marshaler interface
 func (v AccrualResponseDto) MarshalEasyJSON(w *jwriter.Writer) {
-	easyjson72d98f8bEncodeGithubComUjweghGophermartInternalAppServiceClients(w, v)
+	easyjson72d98f8bEncodeGithubComUjweghGophermartInternalAppServiceClients1(w, v)
 }
 
 // UnmarshalJSON supports json.Unmarshaler interface
 func (v *AccrualResponseDto) UnmarshalJSON(data []byte) error {
 	r := jlexer.Lexer{Data: data}
-	easyjson72d98f8bDecodeGithubComUjweghGophermartInternalAppServiceClients(&r, v)
+	easyjson72d98f8bDecodeGithubComUjweghGophermartInternalAppServiceClients1(&r, v)
 	return r.Error()
 }
 
 // UnmarshalEasyJSON supports easyjson.Unmarshaler interface
 func (v *AccrualResponseDto) UnmarshalEasyJSON(l *jlexer.Lexer) {
-	easyjson72d98f8bDecodeGithubComUjweghGophermartInternalAppServiceClients(l, v)
+	easyjson72d98f8bDecodeGithubComUjweghGophermartInternalAppServiceClients1(l, v)
 }
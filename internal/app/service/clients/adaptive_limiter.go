@@ -0,0 +1,104 @@
+package clients
+
+import (
+	"github.com/ujwegh/gophermart/internal/app/logger"
+	"go.uber.org/ratelimit"
+	"go.uber.org/zap"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// consecutive 429 responses after which the rate is halved
+	throttleThreshold = 3
+	// time without throttling after which the rate steps back up
+	throttleRecoveryInterval = time.Minute
+)
+
+// adaptiveLimiter wraps a per-second ratelimit.Limiter and slows down when the accrual service
+// keeps answering 429 despite the local limit: every throttleThreshold consecutive 429s halve the
+// rate, and every throttleRecoveryInterval without one gives back a tenth of the configured rate.
+type adaptiveLimiter struct {
+	baseRate   int
+	newLimiter func(requestsPerSecond int) ratelimit.Limiter
+	now        func() time.Time
+
+	mu        sync.Mutex
+	rate      int
+	limiter   ratelimit.Limiter
+	throttled int
+	changedAt time.Time
+}
+
+func newAdaptiveLimiter(requestsPerSecond int) *adaptiveLimiter {
+	newLimiter := func(rate int) ratelimit.Limiter {
+		return ratelimit.New(rate)
+	}
+	return &adaptiveLimiter{
+		baseRate:   requestsPerSecond,
+		newLimiter: newLimiter,
+		now:        time.Now,
+		rate:       requestsPerSecond,
+		limiter:    newLimiter(requestsPerSecond),
+	}
+}
+
+// Take blocks until the current rate allows the next request.
+func (l *adaptiveLimiter) Take() time.Time {
+	l.mu.Lock()
+	limiter := l.limiter
+	l.mu.Unlock()
+	return limiter.Take()
+}
+
+// Rate returns the effective requests per second.
+func (l *adaptiveLimiter) Rate() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.rate
+}
+
+// record adjusts the rate to the status code of an accrual response.
+func (l *adaptiveLimiter) record(statusCode int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if statusCode == http.StatusTooManyRequests {
+		l.throttled++
+		if l.throttled < throttleThreshold {
+			return
+		}
+		l.throttled = 0
+		rate := l.rate / 2
+		if rate < 1 {
+			rate = 1
+		}
+		l.setRate(rate)
+		logger.Log.Warn("accrual service keeps throttling, lowering request rate",
+			zap.Int("requestsPerSecond", l.rate), zap.Int("configured", l.baseRate))
+		return
+	}
+
+	l.throttled = 0
+	if l.rate >= l.baseRate || l.now().Sub(l.changedAt) < throttleRecoveryInterval {
+		return
+	}
+	step := l.baseRate / 10
+	if step < 1 {
+		step = 1
+	}
+	rate := l.rate + step
+	if rate > l.baseRate {
+		rate = l.baseRate
+	}
+	l.setRate(rate)
+	logger.Log.Info("accrual service stopped throttling, raising request rate",
+		zap.Int("requestsPerSecond", l.rate), zap.Int("configured", l.baseRate))
+}
+
+func (l *adaptiveLimiter) setRate(rate int) {
+	l.rate = rate
+	l.limiter = l.newLimiter(rate)
+	l.changedAt = l.now()
+}
@@ -0,0 +1,106 @@
+package clients
+
+import (
+	"errors"
+	"fmt"
+	"github.com/ujwegh/gophermart/internal/app/logger"
+	"go.uber.org/zap"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is matched by CircuitOpenError, for callers that don't need the retry delay.
+var ErrCircuitOpen = errors.New("accrual circuit breaker is open")
+
+// CircuitOpenError is returned by GetOrderInfo without contacting the accrual service
+// while the circuit breaker is open.
+type CircuitOpenError struct {
+	RetryAfter time.Duration
+}
+
+func (e *CircuitOpenError) Error() string {
+	return fmt.Sprintf("%s, retry in %s", ErrCircuitOpen, e.RetryAfter)
+}
+
+func (e *CircuitOpenError) Unwrap() error {
+	return ErrCircuitOpen
+}
+
+type circuitState int
+
+const (
+	circuitClosed circuitState = iota
+	circuitOpen
+	circuitHalfOpen
+)
+
+// circuitBreaker opens after maxFailures consecutive failures and fails fast for cooldown.
+// After the cooldown a single probe is let through: its success closes the circuit, its failure
+// opens it for another cooldown. A nil breaker lets everything through.
+type circuitBreaker struct {
+	maxFailures int
+	cooldown    time.Duration
+	now         func() time.Time
+
+	mu       sync.Mutex
+	state    circuitState
+	failures int
+	openedAt time.Time
+}
+
+func newCircuitBreaker(maxFailures int, cooldown time.Duration) *circuitBreaker {
+	if maxFailures <= 0 || cooldown <= 0 {
+		return nil
+	}
+	return &circuitBreaker{maxFailures: maxFailures, cooldown: cooldown, now: time.Now}
+}
+
+// allow returns a CircuitOpenError when the call must not be made.
+func (cb *circuitBreaker) allow() error {
+	if cb == nil {
+		return nil
+	}
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	switch cb.state {
+	case circuitOpen:
+		if wait := cb.openedAt.Add(cb.cooldown).Sub(cb.now()); wait > 0 {
+			return &CircuitOpenError{RetryAfter: wait}
+		}
+		cb.state = circuitHalfOpen
+		logger.Log.Info("accrual circuit breaker half-open, probing")
+		return nil
+	case circuitHalfOpen:
+		// the probe is still running
+		return &CircuitOpenError{RetryAfter: cb.cooldown}
+	}
+	return nil
+}
+
+// record counts the outcome of a call that allow let through.
+func (cb *circuitBreaker) record(success bool) {
+	if cb == nil {
+		return
+	}
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	if success {
+		if cb.state != circuitClosed {
+			logger.Log.Info("accrual circuit breaker closed")
+		}
+		cb.state = circuitClosed
+		cb.failures = 0
+		return
+	}
+	cb.failures++
+	if cb.state == circuitHalfOpen || cb.failures >= cb.maxFailures {
+		if cb.state != circuitOpen {
+			logger.Log.Warn("accrual circuit breaker opened",
+				zap.Int("failures", cb.failures), zap.Duration("cooldown", cb.cooldown))
+		}
+		cb.state = circuitOpen
+		cb.openedAt = cb.now()
+	}
+}
@@ -0,0 +1,50 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	appErrors "github.com/ujwegh/gophermart/internal/app/errors"
+	"github.com/ujwegh/gophermart/internal/app/repository"
+	"net/http"
+	"time"
+)
+
+type (
+	DeviceMarkerService interface {
+		GetMarker(ctx context.Context, userUID *uuid.UUID, deviceID string) (*repository.DeviceMarker, error)
+		SetMarker(ctx context.Context, userUID *uuid.UUID, deviceID string, lastSeenAt time.Time) (*repository.DeviceMarker, error)
+	}
+	DeviceMarkerServiceImpl struct {
+		markerRepo repository.DeviceMarkerRepository
+	}
+)
+
+func NewDeviceMarkerService(markerRepo repository.DeviceMarkerRepository) *DeviceMarkerServiceImpl {
+	return &DeviceMarkerServiceImpl{markerRepo: markerRepo}
+}
+
+// GetMarker returns a 404 wrapping repository.ErrDeviceMarkerNotFound when the device has no marker yet.
+func (ds *DeviceMarkerServiceImpl) GetMarker(ctx context.Context, userUID *uuid.UUID, deviceID string) (*repository.DeviceMarker, error) {
+	marker, err := ds.markerRepo.GetMarker(ctx, userUID, deviceID)
+	if errors.Is(err, repository.ErrDeviceMarkerNotFound) {
+		return nil, appErrors.NewWithCode(err, "Device marker not found", http.StatusNotFound)
+	}
+	if err != nil {
+		return nil, appErrors.New(err, "get device marker")
+	}
+	return marker, nil
+}
+
+func (ds *DeviceMarkerServiceImpl) SetMarker(ctx context.Context, userUID *uuid.UUID, deviceID string, lastSeenAt time.Time) (*repository.DeviceMarker, error) {
+	marker := &repository.DeviceMarker{
+		UserUUID:   *userUID,
+		DeviceID:   deviceID,
+		LastSeenAt: lastSeenAt.UTC(),
+		UpdatedAt:  time.Now().UTC(),
+	}
+	if err := ds.markerRepo.SetMarker(ctx, marker); err != nil {
+		return nil, appErrors.New(err, "set device marker")
+	}
+	return marker, nil
+}
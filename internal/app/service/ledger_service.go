@@ -0,0 +1,79 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"github.com/google/uuid"
+	"sort"
+	"time"
+)
+
+const (
+	AccrualEntry    LedgerEntryType = "accrual"
+	WithdrawalEntry LedgerEntryType = "withdrawal"
+)
+
+type (
+	LedgerEntryType string
+	// LedgerEntry is a single balance movement: an accrual for a processed order or a withdrawal.
+	LedgerEntry struct {
+		Type      LedgerEntryType
+		OrderID   string
+		Amount    float64
+		CreatedAt time.Time
+	}
+	LedgerService interface {
+		GetLedger(ctx context.Context, userUID *uuid.UUID, limit int, offset int) (*[]LedgerEntry, error)
+	}
+	LedgerServiceImpl struct {
+		orderService      OrderService
+		withdrawalService WithdrawalService
+	}
+)
+
+func NewLedgerService(orderService OrderService, withdrawalService WithdrawalService) *LedgerServiceImpl {
+	return &LedgerServiceImpl{
+		orderService:      orderService,
+		withdrawalService: withdrawalService,
+	}
+}
+
+// GetLedger returns the user's accruals and withdrawals merged oldest first.
+// Both sources are read up to offset+limit rows, since either of them may fill the requested page.
+func (ls *LedgerServiceImpl) GetLedger(ctx context.Context, userUID *uuid.UUID, limit int, offset int) (*[]LedgerEntry, error) {
+	window := offset + limit
+	orders, err := ls.orderService.GetProcessedOrders(ctx, userUID, window, 0)
+	if err != nil {
+		return nil, fmt.Errorf("ledger: %w", err)
+	}
+	withdrawals, err := ls.withdrawalService.GetWithdrawals(ctx, userUID, window, 0)
+	if err != nil {
+		return nil, fmt.Errorf("ledger: %w", err)
+	}
+
+	merged := make([]LedgerEntry, 0, len(*orders)+len(*withdrawals))
+	for _, order := range *orders {
+		var amount float64
+		if order.Accrual != nil {
+			amount = *order.Accrual
+		}
+		merged = append(merged, LedgerEntry{Type: AccrualEntry, OrderID: order.ID, Amount: amount, CreatedAt: order.UpdatedAt})
+	}
+	for _, withdrawal := range *withdrawals {
+		merged = append(merged, LedgerEntry{Type: WithdrawalEntry, OrderID: withdrawal.OrderID, Amount: withdrawal.Amount.Float64(), CreatedAt: withdrawal.CreatedAt})
+	}
+	// accruals come first, so on equal timestamps a stable sort keeps them before withdrawals
+	sort.SliceStable(merged, func(i, j int) bool {
+		return merged[i].CreatedAt.Before(merged[j].CreatedAt)
+	})
+
+	entries := make([]LedgerEntry, 0, limit)
+	if offset < len(merged) {
+		end := offset + limit
+		if end > len(merged) {
+			end = len(merged)
+		}
+		entries = append(entries, merged[offset:end]...)
+	}
+	return &entries, nil
+}
@@ -0,0 +1,287 @@
+package service
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/mock"
+	appContext "github.com/ujwegh/gophermart/internal/app/context"
+	"github.com/ujwegh/gophermart/internal/app/repository"
+	"time"
+)
+
+// defaultTenantContext returns the context of a request in the default tenant, as the handlers pass it.
+func defaultTenantContext() context.Context {
+	return appContext.WithTenantID(context.Background(), "")
+}
+
+type MockUserService struct {
+	mock.Mock
+}
+
+func (m *MockUserService) Create(ctx context.Context, login, password string) (*repository.User, error) {
+	args := m.Called(ctx, login, password)
+	return args.Get(0).(*repository.User), args.Error(1)
+}
+
+func (m *MockUserService) CreateIdempotent(ctx context.Context, login, password, idempotencyKey string) (*repository.User, error) {
+	args := m.Called(ctx, login, password, idempotencyKey)
+	return args.Get(0).(*repository.User), args.Error(1)
+}
+
+func (m *MockUserService) Authenticate(ctx context.Context, login, password string) (*repository.User, error) {
+	args := m.Called(ctx, login, password)
+	return args.Get(0).(*repository.User), args.Error(1)
+}
+
+func (m *MockUserService) GetByUserLogin(ctx context.Context, login string) (*repository.User, error) {
+	args := m.Called(ctx, login)
+	return args.Get(0).(*repository.User), args.Error(1)
+}
+
+func (m *MockUserService) UpdateLogin(ctx context.Context, userUID *uuid.UUID, newLogin string) error {
+	args := m.Called(ctx, userUID, newLogin)
+	return args.Error(0)
+}
+
+type MockWalletRepository struct {
+	mock.Mock
+}
+
+func (m *MockWalletRepository) CreateWallet(ctx context.Context, tx *sqlx.Tx, wallet *repository.Wallet) error {
+	args := m.Called(ctx, tx, wallet)
+	return args.Error(0)
+}
+
+func (m *MockWalletRepository) CreateWalletIfMissing(ctx context.Context, tx *sqlx.Tx, wallet *repository.Wallet) (bool, error) {
+	args := m.Called(ctx, tx, wallet)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockWalletRepository) GetWallet(ctx context.Context, userUID *uuid.UUID) (*repository.Wallet, error) {
+	args := m.Called(ctx, userUID)
+	return args.Get(0).(*repository.Wallet), args.Error(1)
+}
+
+func (m *MockWalletRepository) GetWalletForUpdate(ctx context.Context, tx *sqlx.Tx, userUID *uuid.UUID) (*repository.Wallet, error) {
+	args := m.Called(ctx, tx, userUID)
+	return args.Get(0).(*repository.Wallet), args.Error(1)
+}
+
+func (m *MockWalletRepository) FindDrifts(ctx context.Context) ([]repository.WalletDrift, error) {
+	args := m.Called(ctx)
+	return args.Get(0).([]repository.WalletDrift), args.Error(1)
+}
+
+func (m *MockWalletRepository) FixDrift(ctx context.Context, drift *repository.WalletDrift) (bool, error) {
+	args := m.Called(ctx, drift)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockWalletRepository) FindUnmatchedDebits(ctx context.Context) ([]repository.UnmatchedDebit, error) {
+	args := m.Called(ctx)
+	return args.Get(0).([]repository.UnmatchedDebit), args.Error(1)
+}
+
+func (m *MockWalletRepository) Credit(ctx context.Context, tx *sqlx.Tx, userUID *uuid.UUID, amount repository.Cents) (*repository.Wallet, error) {
+	args := m.Called(ctx, tx, userUID, amount)
+	return args.Get(0).(*repository.Wallet), args.Error(1)
+}
+
+func (m *MockWalletRepository) CreditOnce(ctx context.Context, tx *sqlx.Tx, userUID *uuid.UUID, amount repository.Cents, key string) (*repository.Wallet, bool, error) {
+	args := m.Called(ctx, tx, userUID, amount, key)
+	return args.Get(0).(*repository.Wallet), args.Bool(1), args.Error(2)
+}
+
+func (m *MockWalletRepository) Debit(ctx context.Context, tx *sqlx.Tx, userUID *uuid.UUID, amount repository.Cents) (*repository.Wallet, error) {
+	args := m.Called(ctx, tx, userUID, amount)
+	return args.Get(0).(*repository.Wallet), args.Error(1)
+}
+
+func (m *MockWalletRepository) Refund(ctx context.Context, tx *sqlx.Tx, userUID *uuid.UUID, amount repository.Cents) (*repository.Wallet, error) {
+	args := m.Called(ctx, tx, userUID, amount)
+	return args.Get(0).(*repository.Wallet), args.Error(1)
+}
+
+type MockOrderRepository struct {
+	mock.Mock
+}
+
+func (m *MockOrderRepository) CreateOrder(ctx context.Context, order *repository.Order) error {
+	args := m.Called(ctx, order)
+	return args.Error(0)
+}
+
+func (m *MockOrderRepository) CreateOrderTx(ctx context.Context, tx *sqlx.Tx, order *repository.Order) error {
+	args := m.Called(ctx, tx, order)
+	return args.Error(0)
+}
+
+func (m *MockOrderRepository) GetOrderByIDTx(ctx context.Context, tx *sqlx.Tx, orderID string) (*repository.Order, error) {
+	args := m.Called(ctx, tx, orderID)
+	return args.Get(0).(*repository.Order), args.Error(1)
+}
+
+func (m *MockOrderRepository) CreateAccrualCorrection(ctx context.Context, tx *sqlx.Tx, correction *repository.AccrualCorrection) error {
+	args := m.Called(ctx, tx, correction)
+	return args.Error(0)
+}
+
+func (m *MockOrderRepository) GetOrderByID(ctx context.Context, orderID string) (*repository.Order, error) {
+	args := m.Called(ctx, orderID)
+	return args.Get(0).(*repository.Order), args.Error(1)
+}
+
+func (m *MockOrderRepository) GetOrdersByIDs(ctx context.Context, orderIDs []string) (*[]repository.Order, error) {
+	args := m.Called(ctx, orderIDs)
+	return args.Get(0).(*[]repository.Order), args.Error(1)
+}
+
+func (m *MockOrderRepository) GetOrdersByUserUID(ctx context.Context, userUID *uuid.UUID, limit int, offset int) (*[]repository.Order, error) {
+	args := m.Called(ctx, userUID, limit, offset)
+	return args.Get(0).(*[]repository.Order), args.Error(1)
+}
+
+func (m *MockOrderRepository) GetOrdersAfterCursor(ctx context.Context, userUID *uuid.UUID, cursor *repository.OrderCursor, limit int) (*[]repository.Order, error) {
+	args := m.Called(ctx, userUID, cursor, limit)
+	return args.Get(0).(*[]repository.Order), args.Error(1)
+}
+
+func (m *MockOrderRepository) GetOrdersSince(ctx context.Context, userUID *uuid.UUID, since time.Time, limit int, offset int) (*[]repository.Order, error) {
+	args := m.Called(ctx, userUID, since, limit, offset)
+	return args.Get(0).(*[]repository.Order), args.Error(1)
+}
+
+func (m *MockOrderRepository) CountOrdersByUserUID(ctx context.Context, userUID *uuid.UUID) (int, error) {
+	args := m.Called(ctx, userUID)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockOrderRepository) GetProcessedOrdersByUserUID(ctx context.Context, userUID *uuid.UUID, limit int, offset int) (*[]repository.Order, error) {
+	args := m.Called(ctx, userUID, limit, offset)
+	return args.Get(0).(*[]repository.Order), args.Error(1)
+}
+
+func (m *MockOrderRepository) GetOrdersInRange(ctx context.Context, from time.Time, to time.Time, limit int, offset int) (*[]repository.UserOrder, error) {
+	args := m.Called(ctx, from, to, limit, offset)
+	return args.Get(0).(*[]repository.UserOrder), args.Error(1)
+}
+
+func (m *MockOrderRepository) GetDeadLetterOrders(ctx context.Context, limit int, offset int) (*[]repository.UserOrder, error) {
+	args := m.Called(ctx, limit, offset)
+	return args.Get(0).(*[]repository.UserOrder), args.Error(1)
+}
+
+func (m *MockOrderRepository) UpdateOrder(ctx context.Context, tx *sqlx.Tx, order *repository.Order) error {
+	args := m.Called(ctx, tx, order)
+	return args.Error(0)
+}
+
+func (m *MockOrderRepository) UpsertOrder(ctx context.Context, tx *sqlx.Tx, order *repository.Order) error {
+	args := m.Called(ctx, tx, order)
+	return args.Error(0)
+}
+
+func (m *MockOrderRepository) IncrementAttempts(ctx context.Context, tx *sqlx.Tx, orderID string) (int, error) {
+	args := m.Called(ctx, tx, orderID)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockOrderRepository) IncrementLookupFailures(ctx context.Context, tx *sqlx.Tx, orderID string) (int, error) {
+	args := m.Called(ctx, tx, orderID)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockOrderRepository) ResetOrder(ctx context.Context, tx *sqlx.Tx, orderID string, updatedAt time.Time) error {
+	args := m.Called(ctx, tx, orderID, updatedAt)
+	return args.Error(0)
+}
+
+func (m *MockOrderRepository) CountUnprocessedOrders() (int, error) {
+	args := m.Called()
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockOrderRepository) GetUnprocessedOrders(limit int, offset int) (*[]repository.Order, error) {
+	args := m.Called(limit, offset)
+	return args.Get(0).(*[]repository.Order), args.Error(1)
+}
+
+func (m *MockOrderRepository) SumProcessedAccruals(ctx context.Context, userUID *uuid.UUID) (float64, error) {
+	args := m.Called(ctx, userUID)
+	return args.Get(0).(float64), args.Error(1)
+}
+
+func (m *MockOrderRepository) SumPendingAccruals(ctx context.Context, userUID *uuid.UUID) (float64, error) {
+	args := m.Called(ctx, userUID)
+	return args.Get(0).(float64), args.Error(1)
+}
+
+func (m *MockOrderRepository) ArchiveProcessedOrdersBefore(ctx context.Context, t time.Time, archivedAt time.Time) (int64, error) {
+	args := m.Called(ctx, t, archivedAt)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockOrderRepository) GetDB() *sqlx.DB {
+	args := m.Called()
+	return args.Get(0).(*sqlx.DB)
+}
+
+type MockWithdrawalsRepository struct {
+	mock.Mock
+}
+
+func (m *MockWithdrawalsRepository) CreateWithdrawal(ctx context.Context, tx *sqlx.Tx, withdrawal *repository.Withdrawal) error {
+	args := m.Called(ctx, tx, withdrawal)
+	return args.Error(0)
+}
+
+func (m *MockWithdrawalsRepository) GetWithdrawalByOrder(ctx context.Context, tx *sqlx.Tx, userUID *uuid.UUID, orderID string) (*repository.Withdrawal, error) {
+	args := m.Called(ctx, tx, userUID, orderID)
+	return args.Get(0).(*repository.Withdrawal), args.Error(1)
+}
+
+func (m *MockWithdrawalsRepository) GetWithdrawalByKey(ctx context.Context, tx *sqlx.Tx, userUID *uuid.UUID, key string) (*repository.Withdrawal, error) {
+	args := m.Called(ctx, tx, userUID, key)
+	return args.Get(0).(*repository.Withdrawal), args.Error(1)
+}
+
+func (m *MockWithdrawalsRepository) GetWithdrawalOutcome(ctx context.Context, userUID *uuid.UUID, key string) (*repository.WithdrawalOutcome, error) {
+	args := m.Called(ctx, userUID, key)
+	return args.Get(0).(*repository.WithdrawalOutcome), args.Error(1)
+}
+
+func (m *MockWithdrawalsRepository) CreateReversal(ctx context.Context, tx *sqlx.Tx, reversal *repository.WithdrawalReversal) (bool, error) {
+	args := m.Called(ctx, tx, reversal)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockWithdrawalsRepository) GetWithdrawalsSorted(ctx context.Context, userUID *uuid.UUID, limit int, offset int, direction repository.SortDirection) (*[]repository.Withdrawal, error) {
+	args := m.Called(ctx, userUID, limit, offset, direction)
+	return args.Get(0).(*[]repository.Withdrawal), args.Error(1)
+}
+
+func (m *MockWithdrawalsRepository) CountWithdrawals(ctx context.Context, userUID *uuid.UUID) (int, error) {
+	args := m.Called(ctx, userUID)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockWithdrawalsRepository) GetWithdrawals(ctx context.Context, userUID *uuid.UUID, limit int, offset int) (*[]repository.Withdrawal, error) {
+	args := m.Called(ctx, userUID, limit, offset)
+	return args.Get(0).(*[]repository.Withdrawal), args.Error(1)
+}
+
+func (m *MockWithdrawalsRepository) SumWithdrawals(ctx context.Context, userUID *uuid.UUID) (float64, error) {
+	args := m.Called(ctx, userUID)
+	return args.Get(0).(float64), args.Error(1)
+}
+
+func (m *MockWithdrawalsRepository) SumWithdrawalsTx(ctx context.Context, tx *sqlx.Tx, userUID *uuid.UUID) (float64, error) {
+	args := m.Called(ctx, tx, userUID)
+	return args.Get(0).(float64), args.Error(1)
+}
+
+func (m *MockWithdrawalsRepository) GetDB() *sqlx.DB {
+	args := m.Called()
+	return args.Get(0).(*sqlx.DB)
+}
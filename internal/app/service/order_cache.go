@@ -1,40 +1,248 @@
 package service
 
 import (
+	"container/heap"
 	"github.com/patrickmn/go-cache"
 	"github.com/ujwegh/gophermart/internal/app/logger"
 	"github.com/ujwegh/gophermart/internal/app/repository"
 	"go.uber.org/zap"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
 type OrderCache interface {
 	AddOrder(order *repository.Order)
+	AddOrderWithDelay(order *repository.Order, delay time.Duration)
+	ItemCount() int
 }
 
 type OrderCacheImpl struct {
 	*cache.Cache
 	orderChan chan repository.Order
+	// mu is held for reading while an evicted order is sent, Close takes it for writing
+	// to wait for in-flight sends before the channel may be closed
+	mu        sync.RWMutex
+	done      chan struct{}
+	closeOnce sync.Once
+	// maxSize caps the cached orders, 0 for no cap
+	maxSize      int
+	capEvictions atomic.Int64
+	// capMu serializes the adds of a capped cache, so concurrent adds can't all see room for one more order
+	capMu sync.Mutex
+	// due orders the cached orders by expiration while the cache is capped, guarded by capMu
+	due dueHeap
 }
 
+// cachedOrder is the cache value. mu and sent make sure the order is sent back once, either early
+// because the cache is full or when it expires.
+type cachedOrder struct {
+	mu    sync.Mutex
+	order repository.Order
+	sent  bool
+}
+
+// dueOrder is a due heap entry. Entries of orders that expired or were added again since are stale
+// and dropped when they come up.
+type dueOrder struct {
+	id         string
+	expiration int64
+}
+
+type dueHeap []dueOrder
+
+func (h dueHeap) Len() int            { return len(h) }
+func (h dueHeap) Less(i, j int) bool  { return h[i].expiration < h[j].expiration }
+func (h dueHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *dueHeap) Push(x interface{}) { *h = append(*h, x.(dueOrder)) }
+func (h *dueHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	item := old[n-1]
+	*h = old[:n-1]
+	return item
+}
+
+// NewOrderCache returns a cache that sends expired orders back to orderChan. The cache runs its own
+// janitor instead of the go-cache one, so Close can stop it before orderChan is closed.
 func NewOrderCache(defaultExpiration, cleanupInterval time.Duration, orderChan chan repository.Order) *OrderCacheImpl {
-	c := cache.New(defaultExpiration, cleanupInterval)
-	c.OnEvicted(func(key string, value interface{}) {
-		order, ok := value.(repository.Order)
-		if !ok {
+	c := &OrderCacheImpl{
+		Cache:     cache.New(defaultExpiration, 0),
+		orderChan: orderChan,
+		done:      make(chan struct{}),
+	}
+	c.OnEvicted(c.onEvicted)
+	if cleanupInterval > 0 {
+		go c.runJanitor(cleanupInterval)
+	}
+	return c
+}
+
+// WithMaxSize caps the cache at maxSize orders. Adding an order to a full cache sends the order that is due
+// soonest back for processing right away instead of waiting for it to expire. 0 disables the cap.
+// It has to be called before the first order is added.
+func (c *OrderCacheImpl) WithMaxSize(maxSize int) *OrderCacheImpl {
+	c.maxSize = maxSize
+	return c
+}
+
+func (c *OrderCacheImpl) MaxSize() int {
+	return c.maxSize
+}
+
+// CapEvictions returns how many orders were sent back early because the cache was full.
+func (c *OrderCacheImpl) CapEvictions() int64 {
+	return c.capEvictions.Load()
+}
+
+func (c *OrderCacheImpl) runJanitor(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			c.DeleteExpired()
+		case <-c.done:
 			return
 		}
-		orderChan <- order
-	})
-	return &OrderCacheImpl{
-		Cache:     c,
-		orderChan: orderChan,
 	}
 }
 
+func (c *OrderCacheImpl) onEvicted(_ string, value interface{}) {
+	entry, ok := value.(*cachedOrder)
+	if !ok {
+		return
+	}
+	entry.mu.Lock()
+	defer entry.mu.Unlock()
+	if entry.sent {
+		return
+	}
+	entry.sent = true
+	order := entry.order
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	select {
+	case <-c.done:
+		logger.Log.Debug("Dropping evicted order after shutdown", zap.String("order_id", order.ID))
+		return
+	default:
+	}
+	select {
+	case c.orderChan <- order:
+	case <-c.done:
+		logger.Log.Debug("Dropping evicted order after shutdown", zap.String("order_id", order.ID))
+	}
+}
+
+// Close stops the janitor and makes later evictions drop their orders. Once it returns no eviction
+// sends to orderChan anymore, so the channel can be closed safely.
+func (c *OrderCacheImpl) Close() {
+	c.closeOnce.Do(func() {
+		close(c.done)
+	})
+	c.mu.Lock()
+	defer c.mu.Unlock()
+}
+
 func (c *OrderCacheImpl) AddOrder(order *repository.Order) {
-	err := c.Add(order.ID, *order, cache.DefaultExpiration)
+	c.AddOrderWithDelay(order, cache.DefaultExpiration)
+}
+
+// AddOrderWithDelay schedules the order to be sent back for processing once delay has passed.
+func (c *OrderCacheImpl) AddOrderWithDelay(order *repository.Order, delay time.Duration) {
+	if c.maxSize <= 0 {
+		c.add(order, delay)
+		return
+	}
+	c.capMu.Lock()
+	defer c.capMu.Unlock()
+	c.dropStale()
+	if c.ItemCount() >= c.maxSize {
+		c.evictSoonest()
+	}
+	if !c.add(order, delay) {
+		return
+	}
+	if _, expiration, found := c.GetWithExpiration(order.ID); found {
+		heap.Push(&c.due, dueOrder{id: order.ID, expiration: expiration.UnixNano()})
+	}
+}
+
+func (c *OrderCacheImpl) add(order *repository.Order, delay time.Duration) bool {
+	err := c.Add(order.ID, &cachedOrder{order: *order}, delay)
 	if err != nil {
 		logger.Log.Debug("Order already exists in cache", zap.String("order_id", order.ID))
+		return false
+	}
+	return true
+}
+
+// dueEntry returns the cached order of a due heap entry, false when the entry is stale.
+func (c *OrderCacheImpl) dueEntry(due dueOrder) (*cachedOrder, bool) {
+	value, expiration, found := c.GetWithExpiration(due.id)
+	if !found || expiration.UnixNano() != due.expiration {
+		return nil, false
+	}
+	return value.(*cachedOrder), true
+}
+
+// dropStale pops the stale entries off the top of the due heap, the expired orders come up first,
+// so the heap doesn't outgrow the cache while it stays below the cap.
+func (c *OrderCacheImpl) dropStale() {
+	for c.due.Len() > 0 {
+		if _, ok := c.dueEntry(c.due[0]); ok {
+			return
+		}
+		heap.Pop(&c.due)
+	}
+}
+
+// evictSoonest sends the order closest to its expiration back for processing and removes it from the cache.
+// The send doesn't wait for room in the channel: the callers are the lookup workers that drain it themselves.
+// While the channel is full the order stays cached, so the cap is only exceeded while processing is saturated.
+func (c *OrderCacheImpl) evictSoonest() {
+	for c.due.Len() > 0 {
+		entry, ok := c.dueEntry(c.due[0])
+		if !ok {
+			heap.Pop(&c.due)
+			continue
+		}
+		if !c.sendEarly(entry) {
+			logger.Log.Debug("Order cache is full and so is the processing channel, keeping order",
+				zap.String("order_id", entry.order.ID))
+			return
+		}
+		soonest := heap.Pop(&c.due).(dueOrder)
+		c.capEvictions.Add(1)
+		logger.Log.Debug("Order cache is full, sent order back early", zap.String("order_id", soonest.id))
+		c.Delete(soonest.id)
+		return
+	}
+}
+
+// sendEarly sends the order back unless the channel is full or the cache closed. It leaves an order
+// that is being sent on expiration right now to that send.
+func (c *OrderCacheImpl) sendEarly(entry *cachedOrder) bool {
+	if !entry.mu.TryLock() {
+		return false
+	}
+	defer entry.mu.Unlock()
+	if entry.sent {
+		return true
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	select {
+	case <-c.done:
+		return false
+	default:
+	}
+	select {
+	case c.orderChan <- entry.order:
+		entry.sent = true
+		return true
+	default:
+		return false
 	}
 }
@@ -0,0 +1,127 @@
+package service
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/ujwegh/gophermart/internal/app/repository"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestOrderCacheImpl_EvictionSendsOrder(t *testing.T) {
+	orderChan := make(chan repository.Order, 1)
+	oc := NewOrderCache(time.Minute, 0, orderChan)
+	defer oc.Close()
+
+	oc.AddOrderWithDelay(&repository.Order{ID: "354188083613"}, time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+	oc.DeleteExpired()
+
+	require.Len(t, orderChan, 1)
+	assert.Equal(t, "354188083613", (<-orderChan).ID)
+}
+
+func TestOrderCacheImpl_EvictionAfterClose(t *testing.T) {
+	orderChan := make(chan repository.Order, 1)
+	oc := NewOrderCache(time.Minute, time.Millisecond, orderChan)
+	oc.AddOrderWithDelay(&repository.Order{ID: "354188083613"}, time.Millisecond)
+
+	oc.Close()
+	close(orderChan)
+	time.Sleep(5 * time.Millisecond)
+
+	assert.NotPanics(t, func() {
+		oc.DeleteExpired()
+		oc.Delete("354188083613")
+	})
+	_, open := <-orderChan
+	assert.False(t, open)
+}
+
+func TestOrderCacheImpl_CloseUnblocksPendingEviction(t *testing.T) {
+	orderChan := make(chan repository.Order) // nobody reads, the eviction blocks
+	oc := NewOrderCache(time.Minute, 0, orderChan)
+	oc.AddOrder(&repository.Order{ID: "354188083613"})
+
+	evicted := make(chan struct{})
+	go func() {
+		oc.Delete("354188083613")
+		close(evicted)
+	}()
+	time.Sleep(5 * time.Millisecond)
+
+	oc.Close()
+	close(orderChan)
+	select {
+	case <-evicted:
+	case <-time.After(time.Second):
+		t.Fatal("eviction is still blocked after Close")
+	}
+}
+
+func TestOrderCacheImpl_MaxSizeEvictsSoonest(t *testing.T) {
+	orderChan := make(chan repository.Order, 1)
+	oc := NewOrderCache(time.Minute, 0, orderChan).WithMaxSize(2)
+	defer oc.Close()
+	assert.Equal(t, 2, oc.MaxSize())
+
+	oc.AddOrderWithDelay(&repository.Order{ID: "354188083613"}, time.Hour)
+	oc.AddOrderWithDelay(&repository.Order{ID: "12345678903"}, time.Minute)
+	assert.Empty(t, orderChan, "Nothing is evicted below the cap")
+
+	oc.AddOrderWithDelay(&repository.Order{ID: "79927398713"}, time.Hour)
+
+	select {
+	case order := <-orderChan:
+		assert.Equal(t, "12345678903", order.ID, "The order due soonest should be sent back")
+	case <-time.After(time.Second):
+		t.Fatal("no order was sent back after exceeding the cap")
+	}
+	assert.Equal(t, int64(1), oc.CapEvictions())
+	assert.Equal(t, 2, oc.ItemCount(), "the eviction is done when the add returns")
+	_, found := oc.Get("79927398713")
+	assert.True(t, found)
+	_, found = oc.Get("12345678903")
+	assert.False(t, found)
+}
+
+func TestOrderCacheImpl_MaxSizeConcurrentAdds(t *testing.T) {
+	orderChan := make(chan repository.Order, 100)
+	oc := NewOrderCache(time.Minute, 0, orderChan).WithMaxSize(10)
+	defer oc.Close()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			oc.AddOrderWithDelay(&repository.Order{ID: fmt.Sprintf("order%d", i)}, time.Duration(i+1)*time.Minute)
+		}(i)
+	}
+	wg.Wait()
+
+	assert.Equal(t, 10, oc.ItemCount(), "concurrent adds don't overshoot the cap")
+	assert.Len(t, orderChan, 90)
+	assert.Equal(t, int64(90), oc.CapEvictions())
+}
+
+func TestOrderCacheImpl_MaxSizeChannelFull(t *testing.T) {
+	orderChan := make(chan repository.Order, 1)
+	orderChan <- repository.Order{ID: "queued"}
+	oc := NewOrderCache(time.Minute, 0, orderChan).WithMaxSize(1)
+	defer oc.Close()
+
+	oc.AddOrderWithDelay(&repository.Order{ID: "354188083613"}, time.Minute)
+	oc.AddOrderWithDelay(&repository.Order{ID: "12345678903"}, time.Hour)
+
+	assert.Equal(t, 2, oc.ItemCount(), "the soonest order stays cached while the channel is full")
+	assert.Zero(t, oc.CapEvictions())
+
+	<-orderChan
+	oc.AddOrderWithDelay(&repository.Order{ID: "79927398713"}, time.Hour)
+	order := <-orderChan
+	assert.Equal(t, "354188083613", order.ID)
+	assert.Equal(t, 2, oc.ItemCount())
+}
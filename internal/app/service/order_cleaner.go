@@ -0,0 +1,49 @@
+package service
+
+import (
+	"context"
+	"github.com/ujwegh/gophermart/internal/app/logger"
+	"github.com/ujwegh/gophermart/internal/app/repository"
+	"go.uber.org/zap"
+	"time"
+)
+
+// OrderCleaner periodically archives PROCESSED orders that are older than the retention period.
+type OrderCleaner struct {
+	orderRepo repository.OrderRepository
+	retention time.Duration
+	interval  time.Duration
+}
+
+func NewOrderCleaner(orderRepo repository.OrderRepository, retention time.Duration, interval time.Duration) *OrderCleaner {
+	return &OrderCleaner{
+		orderRepo: orderRepo,
+		retention: retention,
+		interval:  interval,
+	}
+}
+
+// Run archives old orders every interval until ctx is done.
+func (oc *OrderCleaner) Run(ctx context.Context) {
+	ticker := time.NewTicker(oc.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			oc.Cleanup(ctx)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (oc *OrderCleaner) Cleanup(ctx context.Context) {
+	now := time.Now()
+	before := now.Add(-oc.retention)
+	archived, err := oc.orderRepo.ArchiveProcessedOrdersBefore(ctx, before, now)
+	if err != nil {
+		logger.Log.Error("failed to archive processed orders", zap.Error(err))
+		return
+	}
+	logger.Log.Info("archived processed orders", zap.Int64("archived", archived), zap.Time("before", before))
+}
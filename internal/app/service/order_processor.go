@@ -2,43 +2,205 @@ package service
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"fmt"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
 	"github.com/ujwegh/gophermart/internal/app/logger"
 	"github.com/ujwegh/gophermart/internal/app/repository"
 	"github.com/ujwegh/gophermart/internal/app/service/clients"
 	"go.uber.org/zap"
+	"hash/fnv"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
+// unfinishedOrdersLease names the lease held by the instance republishing the unfinished orders on start.
+const unfinishedOrdersLease = "unfinished_orders"
+
+// defaultHeartbeatInterval is how often idle lookup workers beat, so a quiet processor doesn't look stalled.
+const defaultHeartbeatInterval = 5 * time.Second
+
 type OrderProcessor interface {
 	ProcessOrder(order *repository.Order) error
 }
 
+// ProcessorMetrics is a snapshot of how the processor spends its retry budget.
+type ProcessorMetrics struct {
+	// Recached counts orders sent back to the cache to be polled again
+	Recached int64
+	// Exhausted counts orders marked INVALID after reaching the max attempts or the max lookup failures
+	Exhausted int64
+	// CacheSize is the number of orders currently waiting in the cache
+	CacheSize int
+}
+
+type ProcessorMetricsProvider interface {
+	Metrics() ProcessorMetrics
+}
+
+// QueueDepth is a snapshot of the orders waiting for an accrual lookup.
+type QueueDepth struct {
+	// Queued is the number of orders buffered in the processing channel
+	Queued int
+	// Capacity is the size of the processing channel buffer
+	Capacity int
+	// Cached is the number of orders waiting in the cache to be polled again
+	Cached int
+}
+
+type QueueDepthProvider interface {
+	QueueDepth() QueueDepth
+}
+
+// HeartbeatProvider reports when the order processor loop last ran, the zero time if it never did.
+type HeartbeatProvider interface {
+	LastHeartbeat() time.Time
+}
+
 type OrderProcessorImpl struct {
-	orderRepo        repository.OrderRepository
-	orderCache       OrderCache
-	walletService    WalletService
-	accrualClient    clients.AccrualClient
-	processOrderChan chan repository.Order
+	orderRepo         repository.OrderRepository
+	orderHistoryRepo  repository.OrderHistoryRepository
+	orderCache        OrderCache
+	walletService     WalletService
+	accrualClient     clients.AccrualClient
+	processOrderChan  chan repository.Order
+	lookupConcurrency int
+	commitConcurrency int
+	maxAttempts       int
+	// maxLookupFailures is how many failed accrual lookups mark an order INVALID, 0 for no limit
+	maxLookupFailures int
+	// notRegisteredDelay is how long to wait before asking again about an order unknown to the accrual system
+	notRegisteredDelay time.Duration
+	statusMapping      AccrualStatusMapping
+	// pollFloor is the least time between two accrual lookups of the same order, 0 for no floor
+	pollFloor time.Duration
+	// writeTimeout bounds the transaction saving a looked up order, 0 for no bound
+	writeTimeout time.Duration
+	pollsMu      sync.Mutex
+	// lastPolls holds the time of the last accrual lookup by order ID, until the order is final or the poll is
+	// older than pollFloor; pollsSweptAt is when the old polls were last dropped
+	lastPolls    map[string]time.Time
+	pollsSweptAt time.Time
+	// startupLease keeps other instances from republishing the unfinished orders for startupLeaseTTL, nil for no lease
+	startupLease    repository.LeaseRepository
+	startupLeaseTTL time.Duration
+	instanceID      string
+	recached        atomic.Int64
+	exhausted       atomic.Int64
+	// heartbeat is the UnixNano time the lookup loop last ran, beaten at least every heartbeatInterval while it's alive
+	heartbeat         atomic.Int64
+	heartbeatInterval time.Duration
+}
+
+// Failure reasons stored with the orders the processor marks INVALID, listed by /admin/orders/dead-letter.
+const (
+	failureDeclined          = "declined by the accrual system"
+	failureMaxAttempts       = "no final status from the accrual system"
+	failureMaxLookupFailures = "accrual lookups kept failing"
+	failureWalletNotFound    = "wallet of the owner not found"
+)
+
+// lookupResult carries an order whose accrual info has been fetched, or whose lookup failed, to the committer.
+type lookupResult struct {
+	order          repository.Order
+	previousStatus repository.Status
+	// lookupErr is the error of a failed lookup, order is then unchanged
+	lookupErr error
 }
 
 func NewOrderProcessor(orderRepo repository.OrderRepository,
+	orderHistoryRepo repository.OrderHistoryRepository,
 	orderCache OrderCache,
 	walletService WalletService,
 	accrualClient clients.AccrualClient,
-	processOrderChan chan repository.Order) *OrderProcessorImpl {
+	processOrderChan chan repository.Order,
+	lookupConcurrency int,
+	maxAttempts int,
+	notRegisteredDelay time.Duration) *OrderProcessorImpl {
+	if lookupConcurrency < 1 {
+		lookupConcurrency = 1
+	}
 	o := &OrderProcessorImpl{
-		orderRepo:        orderRepo,
-		orderCache:       orderCache,
-		walletService:    walletService,
-		accrualClient:    accrualClient,
-		processOrderChan: processOrderChan,
+		orderRepo:          orderRepo,
+		orderHistoryRepo:   orderHistoryRepo,
+		orderCache:         orderCache,
+		walletService:      walletService,
+		accrualClient:      accrualClient,
+		processOrderChan:   processOrderChan,
+		lookupConcurrency:  lookupConcurrency,
+		commitConcurrency:  1,
+		maxAttempts:        maxAttempts,
+		notRegisteredDelay: notRegisteredDelay,
+		statusMapping:      DefaultAccrualStatusMapping(),
+		lastPolls:          make(map[string]time.Time),
+		instanceID:         uuid.NewString(),
+		heartbeatInterval:  defaultHeartbeatInterval,
 	}
-	o.ProcessUnfinishedOrders()
 	return o
 }
 
+// WithStatusMapping replaces the default translation of accrual statuses into order statuses.
+func (op *OrderProcessorImpl) WithStatusMapping(mapping AccrualStatusMapping) *OrderProcessorImpl {
+	op.statusMapping = mapping
+	return op
+}
+
+// WithCommitConcurrency lets up to n order updates of different users be written at the same time instead of one by one.
+// Each commit holds a DB connection, so n should stay below the pool size to leave room for requests.
+func (op *OrderProcessorImpl) WithCommitConcurrency(n int) *OrderProcessorImpl {
+	if n < 1 {
+		n = 1
+	}
+	op.commitConcurrency = n
+	return op
+}
+
+// WithMaxLookupFailures marks an order INVALID once n of its accrual lookups failed without an answer,
+// e.g. on network or parse errors, so an order the accrual system can't answer for stops taking up the workers.
+// Lookups skipped by the open circuit breaker and answers that the order isn't registered don't count. The failures
+// are counted apart from the attempts capped by maxAttempts, which also count lookups answered PROCESSING.
+// 0 disables the limit, as an outage of the accrual system would otherwise invalidate every order it hits.
+func (op *OrderProcessorImpl) WithMaxLookupFailures(n int) *OrderProcessorImpl {
+	op.maxLookupFailures = n
+	return op
+}
+
+// WithPollFloor keeps at least floor between two accrual lookups of the same order, however soon it comes back,
+// e.g. re-queued right after a PROCESSING answer or sent again by a retry. An order that comes back too early
+// waits in the cache for the rest of the floor. 0 disables the floor.
+func (op *OrderProcessorImpl) WithPollFloor(floor time.Duration) *OrderProcessorImpl {
+	op.pollFloor = floor
+	return op
+}
+
+// WithWriteTimeout bounds the transaction saving a looked up order by timeout, so a hung database
+// doesn't block a committer forever: on the deadline the transaction is rolled back and the order re-cached.
+// 0 disables the bound.
+func (op *OrderProcessorImpl) WithWriteTimeout(timeout time.Duration) *OrderProcessorImpl {
+	op.writeTimeout = timeout
+	return op
+}
+
+// WithStartupLease lets only one of several instances started together republish the unfinished orders:
+// the one claiming the lease first, the others skip it until ttl has passed or the holder released it
+// on shutdown. An instance crashing within ttl leaves its orders to the next one started after that.
+// A zero ttl makes every instance republish.
+func (op *OrderProcessorImpl) WithStartupLease(leaseRepo repository.LeaseRepository, ttl time.Duration) *OrderProcessorImpl {
+	op.startupLease = leaseRepo
+	op.startupLeaseTTL = ttl
+	return op
+}
+
+// ProcessUnfinishedOrders sends the orders still waiting for their accrual to be processed, called once on start.
 func (op *OrderProcessorImpl) ProcessUnfinishedOrders() {
+	if !op.acquireStartupLease() {
+		logger.Log.Info("another instance is republishing unfinished orders, skipping")
+		return
+	}
 	logger.Log.Info("start processing unfinished orders")
 	totalOrders, err := op.orderRepo.CountUnprocessedOrders()
 	if err != nil {
@@ -64,24 +226,110 @@ func (op *OrderProcessorImpl) ProcessUnfinishedOrders() {
 	logger.Log.Info("published unprocessed orders", zap.Int("total_orders", totalOrders))
 }
 
+// acquireStartupLease reports whether this instance should republish the unfinished orders.
+// Enqueuing orders twice only costs lookups, so the orders are republished when the lease can't be checked.
+func (op *OrderProcessorImpl) acquireStartupLease() bool {
+	if op.startupLease == nil || op.startupLeaseTTL <= 0 {
+		return true
+	}
+	acquired, err := op.startupLease.TryAcquire(context.Background(), unfinishedOrdersLease, op.instanceID, time.Now(), op.startupLeaseTTL)
+	if err != nil {
+		logger.Log.Error("failed to acquire the unfinished orders lease, republishing anyway", zap.Error(err))
+		return true
+	}
+	return acquired
+}
+
+// ReleaseStartupLease gives up the startup lease on shutdown, so an instance started right after
+// republishes the unfinished orders instead of skipping them for the rest of the ttl.
+func (op *OrderProcessorImpl) ReleaseStartupLease(ctx context.Context) {
+	if op.startupLease == nil || op.startupLeaseTTL <= 0 {
+		return
+	}
+	if err := op.startupLease.Release(ctx, unfinishedOrdersLease, op.instanceID); err != nil {
+		logger.Log.Error("failed to release the unfinished orders lease", zap.Error(err))
+	}
+}
+
+// ProcessOrders fetches accrual info with a pool of lookup workers and writes the results with commitConcurrency
+// committers, so the lookups can't exhaust the DB pool. The results are sharded by user: a user's orders are
+// committed one after another by the same committer instead of waiting on each other's wallet lock.
 func (op *OrderProcessorImpl) ProcessOrders(ctx context.Context) {
+	results := make(chan lookupResult)
+	var wg sync.WaitGroup
+	for i := 0; i < op.lookupConcurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			op.lookupOrders(ctx, results)
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	shards := make([]chan lookupResult, op.commitConcurrency)
+	var commits sync.WaitGroup
+	for i := range shards {
+		shards[i] = make(chan lookupResult)
+		commits.Add(1)
+		go func(shard <-chan lookupResult) {
+			defer commits.Done()
+			for result := range shard {
+				op.commitOrder(result)
+			}
+		}(shards[i])
+	}
+	for result := range results {
+		shards[commitShard(result.order.UserUUID, len(shards))] <- result
+	}
+	for _, shard := range shards {
+		close(shard)
+	}
+	commits.Wait()
+}
+
+// commitShard returns the committer of the user's orders out of n.
+func commitShard(userUUID uuid.UUID, n int) int {
+	h := fnv.New32a()
+	h.Write(userUUID[:])
+	return int(h.Sum32() % uint32(n))
+}
+
+func (op *OrderProcessorImpl) commitOrder(result lookupResult) {
+	// a retry must compare against the stored status again, not the one that failed to be saved
+	retry := result.order
+	retry.Status = result.previousStatus
+	defer op.recoverOrder(&retry, "commit")
+
+	order := result.order
+	var err error
+	if result.lookupErr != nil {
+		err = op.recordLookupFailure(&order, result.lookupErr)
+	} else {
+		err = op.updateOrder(&order, result.previousStatus)
+	}
+	if err != nil {
+		logger.Log.Error("failed to update order", zap.Error(err))
+	}
+}
+
+// lookupOrders beats the heartbeat on every iteration, and on a ticker while no orders come in, so the heartbeat
+// only goes stale when the workers are gone or stuck.
+func (op *OrderProcessorImpl) lookupOrders(ctx context.Context, results chan<- lookupResult) {
+	ticker := time.NewTicker(op.heartbeatInterval)
+	defer ticker.Stop()
 	for {
+		op.heartbeat.Store(time.Now().UnixNano())
 		select {
-		case order := <-op.processOrderChan:
-			logger.Log.Debug("processing order", zap.String("order_id", order.ID))
-			orderInfo, err := op.accrualClient.GetOrderInfo(order.ID)
-			if err != nil {
-				logger.Log.Debug("error getting order info", zap.Error(err))
-				op.orderCache.AddOrder(&order)
-				continue
+		case <-ticker.C:
+		case order, ok := <-op.processOrderChan:
+			if !ok {
+				return
 			}
-			order.Accrual = &orderInfo.Accrual
-			order.Status = mapAccrualResponseStatus(orderInfo)
-			order.UpdatedAt = time.Now()
-
-			err = op.updateOrder(&order)
-			if err != nil {
-				logger.Log.Error("failed to update order", zap.Error(err))
+			if op.lookupOrder(ctx, order, results) {
+				return
 			}
 		case <-ctx.Done():
 			return
@@ -89,48 +337,320 @@ func (op *OrderProcessorImpl) ProcessOrders(ctx context.Context) {
 	}
 }
 
-func (op *OrderProcessorImpl) updateOrder(order *repository.Order) error {
-	ctx := context.Background()
+// lookupOrder fetches the accrual info of the order and hands it to the committer.
+// It reports whether ctx was done before the committer took the result; a recovered panic reports false.
+func (op *OrderProcessorImpl) lookupOrder(ctx context.Context, order repository.Order, results chan<- lookupResult) (done bool) {
+	retry := order
+	defer op.recoverOrder(&retry, "lookup")
 
-	db := op.orderRepo.GetDB()
-	tx, err := db.BeginTxx(ctx, nil)
+	logger.Log.Debug("processing order", zap.String("order_id", order.ID))
+	if wait := op.pollWait(order.ID); wait > 0 {
+		logger.Log.Debug("order polled too recently, deferring lookup",
+			zap.String("order_id", order.ID), zap.Duration("wait", wait))
+		op.recacheWithDelay(&order, wait)
+		return false
+	}
+	orderInfo, err := op.accrualClient.GetOrderInfo(order.ID)
+	if errors.Is(err, clients.ErrOrderNotRegistered) {
+		logger.Log.Debug("order not registered in accrual system yet", zap.String("order_id", order.ID))
+		op.recacheWithDelay(&order, op.notRegisteredDelay)
+		return false
+	}
+	var circuitErr *clients.CircuitOpenError
+	if errors.As(err, &circuitErr) {
+		op.recacheWithDelay(&order, circuitErr.RetryAfter)
+		return false
+	}
 	if err != nil {
-		op.orderCache.AddOrder(order)
-		return fmt.Errorf("failed to begin transaction: %w", err)
+		logger.Log.Debug("error getting order info", zap.Error(err))
+		if op.maxLookupFailures <= 0 {
+			op.recache(&order)
+			return false
+		}
+		select {
+		case results <- lookupResult{order: order, previousStatus: order.Status, lookupErr: err}:
+			return false
+		case <-ctx.Done():
+			return true
+		}
 	}
-	if err := op.orderRepo.UpdateOrder(ctx, tx, order); err != nil {
-		op.orderCache.AddOrder(order)
-		if err := tx.Rollback(); err != nil {
-			return fmt.Errorf("failed to rollback transaction: %w", err)
+	previousStatus := order.Status
+	order.Accrual = nil
+	if orderInfo.Accrual > 0 {
+		order.Accrual = &orderInfo.Accrual
+	}
+	order.Status = op.statusMapping.Map(orderInfo.AccrualStatus)
+	order.FailureReason = nil
+	if order.Status == repository.INVALID {
+		order.FailureReason = failureReason(failureDeclined)
+	}
+	order.UpdatedAt = time.Now()
+
+	select {
+	case results <- lookupResult{order: order, previousStatus: previousStatus}:
+		return false
+	case <-ctx.Done():
+		return true
+	}
+}
+
+// recoverOrder is deferred around the processing of a single order: a panic is logged and
+// the order re-cached, so one bad order doesn't stop the workers or the committer.
+func (op *OrderProcessorImpl) recoverOrder(order *repository.Order, stage string) {
+	rvr := recover()
+	if rvr == nil {
+		return
+	}
+	logger.Log.Error("recovered from panic while processing order",
+		zap.String("order_id", order.ID),
+		zap.String("stage", stage),
+		zap.Any("panic", rvr),
+		zap.ByteString("stack", debug.Stack()),
+	)
+	op.recache(order)
+}
+
+// writeContext returns the context of a transaction saving an order, bounded by the write timeout.
+func (op *OrderProcessorImpl) writeContext() (context.Context, context.CancelFunc) {
+	if op.writeTimeout <= 0 {
+		return context.WithCancel(context.Background())
+	}
+	return context.WithTimeout(context.Background(), op.writeTimeout)
+}
+
+func (op *OrderProcessorImpl) updateOrder(order *repository.Order, previousStatus repository.Status) error {
+	ctx, cancel := op.writeContext()
+	defer cancel()
+
+	exhausted := false
+	err := repository.WithTransaction(ctx, op.orderRepo.GetDB(), func(tx *sqlx.Tx) error {
+		attempts, err := op.orderRepo.IncrementAttempts(ctx, tx, order.ID)
+		if errors.Is(err, sql.ErrNoRows) {
+			// the row isn't visible yet, saveOrder inserts it with this attempt counted
+			attempts, err = order.Attempts+1, nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to count attempt: %w", err)
+		}
+		order.Attempts = attempts
+		if op.maxAttempts > 0 && attempts >= op.maxAttempts && !order.Status.IsFinal() {
+			logger.Log.Warn("order reached max accrual attempts, marking order invalid",
+				zap.String("order_id", order.ID), zap.Int("attempts", attempts))
+			order.Status = repository.INVALID
+			order.Accrual = nil
+			order.FailureReason = failureReason(fmt.Sprintf("%s after %d attempts", failureMaxAttempts, attempts))
+			exhausted = true
+		}
+		if err := op.saveOrder(ctx, tx, order, previousStatus); err != nil {
+			return err
+		}
+		// accruals reported while PROCESSING are pending, the wallet is credited once the order is PROCESSED
+		if order.Status != repository.PROCESSED || order.Accrual == nil {
+			return nil
 		}
-		return fmt.Errorf("failed to update order: %w", err)
+		_, credited, err := op.walletService.CreditOnce(ctx, tx, &order.UserUUID, repository.CentsOf(*order.Accrual), accrualCreditKey(order))
+		if err != nil {
+			return fmt.Errorf("failed to credit: %w", err)
+		}
+		if !credited {
+			logger.Log.Info("accrual already credited, skipping",
+				zap.String("order_id", order.ID), zap.String("status", order.Status.String()))
+		}
+		return nil
+	})
+	if errors.Is(err, repository.ErrWalletNotFound) {
+		// retrying cannot help an order whose owner has no wallet anymore
+		logger.Log.Error("orphaned order: wallet not found, marking order invalid",
+			zap.String("order_id", order.ID), zap.String("user_uuid", order.UserUUID.String()))
+		return op.invalidateOrder(order, previousStatus, failureWalletNotFound)
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		logger.Log.Warn("saving order timed out, rolled back",
+			zap.String("order_id", order.ID), zap.Duration("timeout", op.writeTimeout))
 	}
-	_, err = op.walletService.Credit(ctx, tx, &order.UserUUID, *order.Accrual)
 	if err != nil {
-		if err := tx.Rollback(); err != nil {
-			return fmt.Errorf("failed to rollback transaction: %w", err)
+		op.recache(order)
+		return err
+	}
+	if exhausted {
+		op.exhausted.Add(1)
+	}
+	if !order.Status.IsFinal() {
+		// the accrual system is still working on it, poll again later
+		op.recache(order)
+		return nil
+	}
+	op.forgetPolls(order.ID)
+	return nil
+}
+
+// recordLookupFailure counts a failed accrual lookup of the order and marks the order INVALID once the failures
+// reach the limit, with lookupErr as the failure reason, re-caching it for another lookup otherwise.
+func (op *OrderProcessorImpl) recordLookupFailure(order *repository.Order, lookupErr error) error {
+	ctx, cancel := op.writeContext()
+	defer cancel()
+
+	previousStatus := order.Status
+	exhausted := false
+	err := repository.WithTransaction(ctx, op.orderRepo.GetDB(), func(tx *sqlx.Tx) error {
+		failures, err := op.orderRepo.IncrementLookupFailures(ctx, tx, order.ID)
+		if errors.Is(err, sql.ErrNoRows) {
+			// the row isn't visible yet, nothing to count against
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to count lookup failure: %w", err)
 		}
-		op.orderCache.AddOrder(order)
-		return fmt.Errorf("failed to credit: %w", err)
+		order.LookupFailures = failures
+		if failures < op.maxLookupFailures || order.Status.IsFinal() {
+			return nil
+		}
+		logger.Log.Warn("order reached max accrual lookup failures, marking order invalid",
+			zap.String("order_id", order.ID), zap.Int("failures", failures))
+		order.Status = repository.INVALID
+		order.Accrual = nil
+		order.FailureReason = failureReason(fmt.Sprintf("%s after %d failures: %v", failureMaxLookupFailures, failures, lookupErr))
+		order.UpdatedAt = time.Now()
+		exhausted = true
+		return op.saveOrder(ctx, tx, order, previousStatus)
+	})
+	if err != nil {
+		order.Status = previousStatus
+		order.FailureReason = nil
+		op.recache(order)
+		return err
 	}
+	if !exhausted {
+		op.recache(order)
+		return nil
+	}
+	op.exhausted.Add(1)
+	op.forgetPolls(order.ID)
+	return nil
+}
 
-	if err := tx.Commit(); err != nil {
-		op.orderCache.AddOrder(order)
-		return fmt.Errorf("failed to commit transaction: %w", err)
+func failureReason(reason string) *string {
+	return &reason
+}
+
+// accrualCreditKey identifies the credit of an order's accrual in the owner's wallet_transactions, so saving the
+// PROCESSED order again after a retry doesn't credit the accrual twice.
+func accrualCreditKey(order *repository.Order) string {
+	return "order/" + order.ID
+}
+
+func (op *OrderProcessorImpl) invalidateOrder(order *repository.Order, previousStatus repository.Status, reason string) error {
+	ctx, cancel := op.writeContext()
+	defer cancel()
+	order.Status = repository.INVALID
+	order.Accrual = nil
+	order.FailureReason = failureReason(reason)
+	err := repository.WithTransaction(ctx, op.orderRepo.GetDB(), func(tx *sqlx.Tx) error {
+		return op.saveOrder(ctx, tx, order, previousStatus)
+	})
+	if err != nil {
+		op.recache(order)
+		return err
 	}
+	op.forgetPolls(order.ID)
 	return nil
 }
 
-func mapAccrualResponseStatus(accrualResponse *clients.AccrualResponseDto) repository.Status {
-	switch accrualResponse.AccrualStatus {
-	case clients.PROCESSING:
-		return repository.PROCESSING
-	case clients.REGISTERED:
-		return repository.NEW
-	case clients.INVALID:
-		return repository.INVALID
-	case clients.PROCESSED:
-		return repository.PROCESSED
+// pollWait returns how long the order has to wait until the poll floor allows another lookup.
+// When the lookup is allowed right away, it is recorded as the last poll of the order.
+func (op *OrderProcessorImpl) pollWait(orderID string) time.Duration {
+	if op.pollFloor <= 0 {
+		return 0
+	}
+	op.pollsMu.Lock()
+	defer op.pollsMu.Unlock()
+	now := time.Now()
+	op.sweepPolls(now)
+	if last, ok := op.lastPolls[orderID]; ok {
+		if wait := last.Add(op.pollFloor).Sub(now); wait > 0 {
+			return wait
+		}
+	}
+	op.lastPolls[orderID] = now
+	return 0
+}
+
+// sweepPolls drops the polls older than the floor, at most once per floor. They no longer delay a lookup, and orders
+// that never reach a final status, e.g. deleted or stuck ones, would otherwise stay in lastPolls for good.
+// The caller holds pollsMu.
+func (op *OrderProcessorImpl) sweepPolls(now time.Time) {
+	if now.Sub(op.pollsSweptAt) < op.pollFloor {
+		return
+	}
+	op.pollsSweptAt = now
+	for orderID, last := range op.lastPolls {
+		if now.Sub(last) >= op.pollFloor {
+			delete(op.lastPolls, orderID)
+		}
+	}
+}
+
+// forgetPolls drops the last poll of an order that won't be looked up again.
+func (op *OrderProcessorImpl) forgetPolls(orderID string) {
+	op.pollsMu.Lock()
+	defer op.pollsMu.Unlock()
+	delete(op.lastPolls, orderID)
+}
+
+func (op *OrderProcessorImpl) recache(order *repository.Order) {
+	op.recached.Add(1)
+	op.orderCache.AddOrder(order)
+}
+
+func (op *OrderProcessorImpl) recacheWithDelay(order *repository.Order, delay time.Duration) {
+	op.recached.Add(1)
+	op.orderCache.AddOrderWithDelay(order, delay)
+}
+
+// Metrics returns the retry counters accumulated since start and the current cache size.
+func (op *OrderProcessorImpl) Metrics() ProcessorMetrics {
+	return ProcessorMetrics{
+		Recached:  op.recached.Load(),
+		Exhausted: op.exhausted.Load(),
+		CacheSize: op.orderCache.ItemCount(),
 	}
-	return repository.INVALID
+}
+
+// LastHeartbeat returns when a lookup worker last ran its loop, the zero time before ProcessOrders started.
+func (op *OrderProcessorImpl) LastHeartbeat() time.Time {
+	beat := op.heartbeat.Load()
+	if beat == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, beat)
+}
+
+// QueueDepth returns how many orders wait in the processing channel and in the cache. len and cap
+// of a channel are safe to read while other goroutines send and receive.
+func (op *OrderProcessorImpl) QueueDepth() QueueDepth {
+	return QueueDepth{
+		Queued:   len(op.processOrderChan),
+		Capacity: cap(op.processOrderChan),
+		Cached:   op.orderCache.ItemCount(),
+	}
+}
+
+// saveOrder upserts the order and, when its status changed, writes a history entry.
+func (op *OrderProcessorImpl) saveOrder(ctx context.Context, tx *sqlx.Tx, order *repository.Order, previousStatus repository.Status) error {
+	if err := op.orderRepo.UpsertOrder(ctx, tx, order); err != nil {
+		return fmt.Errorf("failed to upsert order: %w", err)
+	}
+	if order.Status == previousStatus {
+		return nil
+	}
+	change := &repository.OrderStatusChange{
+		OrderID:   order.ID,
+		Status:    order.Status,
+		Accrual:   order.Accrual,
+		ChangedAt: order.UpdatedAt,
+	}
+	if err := op.orderHistoryRepo.AddStatusChange(ctx, tx, change); err != nil {
+		return fmt.Errorf("failed to record status change: %w", err)
+	}
+	return nil
 }
@@ -0,0 +1,945 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/ujwegh/gophermart/internal/app/repository"
+	"github.com/ujwegh/gophermart/internal/app/service/clients"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const initProcessorDB = `
+CREATE TABLE IF NOT EXISTS orders
+(
+    id VARCHAR PRIMARY KEY,
+    user_uuid VARCHAR NOT NULL,
+    status TEXT NOT NULL DEFAULT 'NEW',
+    accrual NUMERIC,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    lookup_failures INTEGER NOT NULL DEFAULT 0,
+    failure_reason TEXT,
+    archived_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    tenant_id TEXT NOT NULL DEFAULT '',
+    CHECK (accrual > 0)
+);
+CREATE TABLE IF NOT EXISTS wallets
+(
+    id INTEGER PRIMARY KEY,
+    user_uuid TEXT UNIQUE NOT NULL,
+    credits NUMERIC NOT NULL DEFAULT 0,
+    debits NUMERIC NOT NULL DEFAULT 0,
+    version INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE TABLE IF NOT EXISTS wallet_transactions
+(
+    id INTEGER PRIMARY KEY,
+    user_uuid TEXT NOT NULL,
+    kind TEXT NOT NULL,
+    amount NUMERIC NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    idempotency_key TEXT
+);
+CREATE UNIQUE INDEX IF NOT EXISTS wallet_transactions_idempotency_key_idx ON wallet_transactions (user_uuid, idempotency_key);
+CREATE TABLE IF NOT EXISTS order_status_history
+(
+    id INTEGER PRIMARY KEY,
+    order_id VARCHAR NOT NULL,
+    status TEXT NOT NULL,
+    accrual NUMERIC,
+    changed_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE TABLE IF NOT EXISTS leases
+(
+    name TEXT PRIMARY KEY,
+    holder TEXT NOT NULL,
+    expires_at TIMESTAMP NOT NULL
+);
+`
+
+func setupInMemoryProcessorDB(t testing.TB, name string) *sqlx.DB {
+	db, err := sqlx.Open("sqlite3", fmt.Sprintf("file:%s?mode=memory&cache=shared", name))
+	if err != nil {
+		t.Fatalf("could not create in-memory db: %v", err)
+	}
+	_, err = db.Exec(initProcessorDB)
+	if err != nil {
+		t.Fatalf("could not create processor tables: %v", err)
+	}
+	return db
+}
+
+// seedProcessorOrders inserts a user wallet and count NEW orders for that user.
+func seedProcessorOrders(t testing.TB, db *sqlx.DB, count int) uuid.UUID {
+	userUUID := uuid.New()
+	_, err := db.Exec(`INSERT INTO wallets (user_uuid) VALUES (?)`, userUUID.String())
+	require.NoError(t, err)
+	for i := 0; i < count; i++ {
+		_, err := db.Exec(`INSERT INTO orders (id, user_uuid, status) VALUES (?, ?, 'NEW')`,
+			fmt.Sprintf("order%d", i), userUUID.String())
+		require.NoError(t, err)
+	}
+	return userUUID
+}
+
+type slowAccrualClient struct {
+	delay   time.Duration
+	accrual float64
+	status  clients.AccrualStatus
+}
+
+func (c *slowAccrualClient) GetOrderInfo(orderID string) (*clients.AccrualResponseDto, error) {
+	time.Sleep(c.delay)
+	status := c.status
+	if status == "" {
+		status = clients.PROCESSED
+	}
+	return &clients.AccrualResponseDto{OrderID: orderID, AccrualStatus: status, Accrual: c.accrual}, nil
+}
+
+func (c *slowAccrualClient) Version(ctx context.Context) (string, error) {
+	return "test", nil
+}
+
+// notRegisteredAccrualClient answers every lookup like the accrual system does for an unknown order.
+type notRegisteredAccrualClient struct{}
+
+func (c *notRegisteredAccrualClient) GetOrderInfo(orderID string) (*clients.AccrualResponseDto, error) {
+	return nil, fmt.Errorf("%w: %s", clients.ErrOrderNotRegistered, orderID)
+}
+
+func (c *notRegisteredAccrualClient) Version(ctx context.Context) (string, error) {
+	return "test", nil
+}
+
+type recordingOrderCache struct {
+	mu     sync.Mutex
+	orders []repository.Order
+	delays []time.Duration
+}
+
+func (c *recordingOrderCache) AddOrder(order *repository.Order) {
+	c.AddOrderWithDelay(order, 0)
+}
+
+func (c *recordingOrderCache) AddOrderWithDelay(order *repository.Order, delay time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.orders = append(c.orders, *order)
+	c.delays = append(c.delays, delay)
+}
+
+func (c *recordingOrderCache) ItemCount() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.orders)
+}
+
+// runProcessor processes all unfinished orders in db and returns the elapsed time.
+func runProcessor(t testing.TB, db *sqlx.DB, accrualClient clients.AccrualClient, total, concurrency int) time.Duration {
+	orderRepo := repository.NewOrderRepository(db)
+	walletService := NewWalletService(repository.NewWalletRepository(db))
+	processOrderChan := make(chan repository.Order, total)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	start := time.Now()
+	op := NewOrderProcessor(orderRepo, repository.NewOrderHistoryRepository(db), &recordingOrderCache{},
+		walletService, accrualClient, processOrderChan, concurrency, 0, 0)
+	op.ProcessUnfinishedOrders()
+	go op.ProcessOrders(ctx)
+
+	require.Eventually(t, func() bool {
+		var processed int
+		err := db.Get(&processed, `SELECT count(*) FROM orders WHERE status = 'PROCESSED'`)
+		return err == nil && processed == total
+	}, 10*time.Second, 5*time.Millisecond)
+	return time.Since(start)
+}
+
+// gatedAccrualClient holds every lookup until waitFor lookups are in flight at once, and records the most seen.
+type gatedAccrualClient struct {
+	accrual     float64
+	waitFor     int32
+	inFlight    atomic.Int32
+	maxInFlight atomic.Int32
+	releaseOnce sync.Once
+	released    chan struct{}
+}
+
+func (c *gatedAccrualClient) GetOrderInfo(orderID string) (*clients.AccrualResponseDto, error) {
+	current := c.inFlight.Add(1)
+	defer c.inFlight.Add(-1)
+	for seen := c.maxInFlight.Load(); current > seen && !c.maxInFlight.CompareAndSwap(seen, current); {
+		seen = c.maxInFlight.Load()
+	}
+	if current >= c.waitFor {
+		c.releaseOnce.Do(func() { close(c.released) })
+	}
+	select {
+	case <-c.released:
+	case <-time.After(5 * time.Second):
+		return nil, fmt.Errorf("only %d lookups in flight, want %d", c.inFlight.Load(), c.waitFor)
+	}
+	return &clients.AccrualResponseDto{OrderID: orderID, AccrualStatus: clients.PROCESSED, Accrual: c.accrual}, nil
+}
+
+func (c *gatedAccrualClient) Version(ctx context.Context) (string, error) {
+	return "test", nil
+}
+
+func TestOrderProcessorImpl_ProcessOrders_ConcurrentLookups(t *testing.T) {
+	const total = 8
+	for _, concurrency := range []int{1, total} {
+		t.Run(fmt.Sprintf("lookups=%d", concurrency), func(t *testing.T) {
+			db := setupInMemoryProcessorDB(t, fmt.Sprintf("processor_lookups_%d", concurrency))
+			defer db.Close()
+			userUUID := seedProcessorOrders(t, db, total)
+			// the lookups only get through once as many run at the same time as there are workers
+			accrualClient := &gatedAccrualClient{accrual: 10, waitFor: int32(concurrency), released: make(chan struct{})}
+
+			runProcessor(t, db, accrualClient, total, concurrency)
+
+			assert.Equal(t, int32(concurrency), accrualClient.maxInFlight.Load(), "every worker should run a lookup at once")
+			// the single committer must still credit every order exactly once
+			var credits float64
+			require.NoError(t, db.Get(&credits, `SELECT credits FROM wallets WHERE user_uuid = ?`, userUUID.String()))
+			assert.Equal(t, float64(total*10), credits)
+		})
+	}
+}
+
+func BenchmarkOrderProcessorImpl_ProcessOrders(b *testing.B) {
+	const total = 16
+	accrualClient := &slowAccrualClient{delay: 5 * time.Millisecond, accrual: 10}
+	for _, concurrency := range []int{1, 4, 16} {
+		b.Run(fmt.Sprintf("lookups=%d", concurrency), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				db := setupInMemoryProcessorDB(b, fmt.Sprintf("processor_bench_%d_%d", concurrency, i))
+				seedProcessorOrders(b, db, total)
+				b.StartTimer()
+				runProcessor(b, db, accrualClient, total, concurrency)
+				db.Close()
+			}
+		})
+	}
+}
+
+func TestOrderProcessorImpl_ProcessOrders_MissingWallet(t *testing.T) {
+	db := setupInMemoryProcessorDB(t, "processor_missing_wallet")
+	defer db.Close()
+	userUUID := uuid.New()
+	_, err := db.Exec(`INSERT INTO orders (id, user_uuid, status) VALUES ('orphan', ?, 'NEW')`, userUUID.String())
+	require.NoError(t, err)
+
+	orderRepo := repository.NewOrderRepository(db)
+	orderCache := &recordingOrderCache{}
+	processOrderChan := make(chan repository.Order, 1)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	op := NewOrderProcessor(orderRepo, repository.NewOrderHistoryRepository(db), orderCache,
+		NewWalletService(repository.NewWalletRepository(db)), &slowAccrualClient{accrual: 10}, processOrderChan, 1, 0, 0)
+	op.ProcessUnfinishedOrders()
+	go op.ProcessOrders(ctx)
+
+	require.Eventually(t, func() bool {
+		var status string
+		err := db.Get(&status, `SELECT status FROM orders WHERE id = 'orphan'`)
+		return err == nil && status == string(repository.INVALID)
+	}, 5*time.Second, 5*time.Millisecond)
+
+	var accrual *float64
+	require.NoError(t, db.Get(&accrual, `SELECT accrual FROM orders WHERE id = 'orphan'`))
+	assert.Nil(t, accrual)
+	var history []string
+	require.NoError(t, db.Select(&history, `SELECT status FROM order_status_history WHERE order_id = 'orphan'`))
+	assert.Equal(t, []string{string(repository.INVALID)}, history)
+	orderCache.mu.Lock()
+	defer orderCache.mu.Unlock()
+	assert.Empty(t, orderCache.orders)
+}
+
+func TestOrderProcessorImpl_ProcessOrders_MaxAttempts(t *testing.T) {
+	const maxAttempts = 3
+	db := setupInMemoryProcessorDB(t, "processor_max_attempts")
+	defer db.Close()
+	userUUID := seedProcessorOrders(t, db, 2)
+	_, err := db.Exec(`UPDATE orders SET attempts = ? WHERE id = 'order0'`, maxAttempts-1)
+	require.NoError(t, err)
+
+	orderCache := &recordingOrderCache{}
+	processOrderChan := make(chan repository.Order, 2)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	accrualClient := &slowAccrualClient{status: clients.PROCESSING}
+	op := NewOrderProcessor(repository.NewOrderRepository(db), repository.NewOrderHistoryRepository(db), orderCache,
+		NewWalletService(repository.NewWalletRepository(db)), accrualClient, processOrderChan, 1, maxAttempts, 0)
+	op.ProcessUnfinishedOrders()
+	go op.ProcessOrders(ctx)
+
+	require.Eventually(t, func() bool {
+		var attempts int
+		err := db.Get(&attempts, `SELECT attempts FROM orders WHERE id = 'order1'`)
+		return err == nil && attempts == 1
+	}, 5*time.Second, 5*time.Millisecond)
+
+	var capped repository.Order
+	require.NoError(t, db.Get(&capped, `SELECT * FROM orders WHERE id = 'order0'`))
+	assert.Equal(t, repository.INVALID, capped.Status, "order over the cap must be given up on")
+	assert.Equal(t, maxAttempts, capped.Attempts)
+	assert.Nil(t, capped.Accrual)
+
+	var pending repository.Order
+	require.NoError(t, db.Get(&pending, `SELECT * FROM orders WHERE id = 'order1'`))
+	assert.Equal(t, repository.PROCESSING, pending.Status)
+
+	var credits float64
+	require.NoError(t, db.Get(&credits, `SELECT credits FROM wallets WHERE user_uuid = ?`, userUUID.String()))
+	assert.Zero(t, credits)
+
+	// only the order below the cap goes back to the cache for another poll
+	orderCache.mu.Lock()
+	defer orderCache.mu.Unlock()
+	require.Len(t, orderCache.orders, 1)
+	assert.Equal(t, "order1", orderCache.orders[0].ID)
+}
+
+func TestOrderProcessorImpl_ProcessOrders_NotRegistered(t *testing.T) {
+	const delay = 30 * time.Second
+	db := setupInMemoryProcessorDB(t, "processor_not_registered")
+	defer db.Close()
+	seedProcessorOrders(t, db, 1)
+
+	orderCache := &recordingOrderCache{}
+	processOrderChan := make(chan repository.Order, 1)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	op := NewOrderProcessor(repository.NewOrderRepository(db), repository.NewOrderHistoryRepository(db), orderCache,
+		NewWalletService(repository.NewWalletRepository(db)), &notRegisteredAccrualClient{}, processOrderChan, 1, 0, delay)
+	op.ProcessUnfinishedOrders()
+	go op.ProcessOrders(ctx)
+
+	require.Eventually(t, func() bool {
+		orderCache.mu.Lock()
+		defer orderCache.mu.Unlock()
+		return len(orderCache.orders) == 1
+	}, 5*time.Second, 5*time.Millisecond)
+
+	orderCache.mu.Lock()
+	defer orderCache.mu.Unlock()
+	assert.Equal(t, "order0", orderCache.orders[0].ID)
+	assert.Equal(t, delay, orderCache.delays[0], "unregistered orders should wait for the dedicated delay")
+
+	var order repository.Order
+	require.NoError(t, db.Get(&order, `SELECT * FROM orders WHERE id = 'order0'`))
+	assert.Equal(t, repository.NEW, order.Status)
+	assert.Zero(t, order.Attempts, "the accrual system has not started on the order, so no attempt is counted")
+}
+
+func TestOrderProcessorImpl_ProcessOrders_StatusMapping(t *testing.T) {
+	db := setupInMemoryProcessorDB(t, "processor_status_mapping")
+	defer db.Close()
+	userUUID := seedProcessorOrders(t, db, 1)
+
+	mapping, err := NewAccrualStatusMapping(map[string]string{"DONE": "PROCESSED"})
+	require.NoError(t, err)
+	processOrderChan := make(chan repository.Order, 1)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	accrualClient := &slowAccrualClient{accrual: 10, status: "DONE"}
+	op := NewOrderProcessor(repository.NewOrderRepository(db), repository.NewOrderHistoryRepository(db), &recordingOrderCache{},
+		NewWalletService(repository.NewWalletRepository(db)), accrualClient, processOrderChan, 1, 0, 0).
+		WithStatusMapping(mapping)
+	op.ProcessUnfinishedOrders()
+	go op.ProcessOrders(ctx)
+
+	require.Eventually(t, func() bool {
+		var status string
+		err := db.Get(&status, `SELECT status FROM orders WHERE id = 'order0'`)
+		return err == nil && status == string(repository.PROCESSED)
+	}, 5*time.Second, 5*time.Millisecond)
+	var credits float64
+	require.NoError(t, db.Get(&credits, `SELECT credits FROM wallets WHERE user_uuid = ?`, userUUID.String()))
+	assert.Equal(t, 10.0, credits)
+}
+
+// failingAccrualClient simulates an accrual system that cannot be reached.
+type failingAccrualClient struct{}
+
+func (c *failingAccrualClient) GetOrderInfo(orderID string) (*clients.AccrualResponseDto, error) {
+	return nil, fmt.Errorf("accrual system unavailable")
+}
+
+func (c *failingAccrualClient) Version(ctx context.Context) (string, error) {
+	return "", fmt.Errorf("accrual system unavailable")
+}
+
+func TestOrderProcessorImpl_QueueDepth(t *testing.T) {
+	db := setupInMemoryProcessorDB(t, "processor_queue_depth")
+	defer db.Close()
+	seedProcessorOrders(t, db, 3)
+
+	processOrderChan := make(chan repository.Order, 10)
+	orderCache := NewOrderCache(time.Minute, 0, processOrderChan)
+	defer orderCache.Close()
+
+	// the unfinished orders are enqueued, nothing consumes them yet
+	op := NewOrderProcessor(repository.NewOrderRepository(db), repository.NewOrderHistoryRepository(db), orderCache,
+		NewWalletService(repository.NewWalletRepository(db)), &failingAccrualClient{}, processOrderChan, 1, 0, 0)
+	op.ProcessUnfinishedOrders()
+	assert.Equal(t, QueueDepth{Queued: 3, Capacity: 10}, op.QueueDepth())
+
+	orderCache.AddOrder(&repository.Order{ID: "cached1"})
+	orderCache.AddOrder(&repository.Order{ID: "cached2"})
+	processOrderChan <- repository.Order{ID: "queued"}
+	assert.Equal(t, QueueDepth{Queued: 4, Capacity: 10, Cached: 2}, op.QueueDepth())
+
+	<-processOrderChan
+	assert.Equal(t, QueueDepth{Queued: 3, Capacity: 10, Cached: 2}, op.QueueDepth())
+}
+
+func TestOrderProcessorImpl_ProcessUnfinishedOrders_StartupLease(t *testing.T) {
+	db := setupInMemoryProcessorDB(t, "processor_startup_lease")
+	defer db.Close()
+	seedProcessorOrders(t, db, 3)
+
+	// two instances sharing the database start together, each with its own channel
+	newInstance := func(ttl time.Duration) (*OrderProcessorImpl, chan repository.Order) {
+		processOrderChan := make(chan repository.Order, 10)
+		op := NewOrderProcessor(repository.NewOrderRepository(db), repository.NewOrderHistoryRepository(db), &recordingOrderCache{},
+			NewWalletService(repository.NewWalletRepository(db)), &failingAccrualClient{}, processOrderChan, 1, 0, 0).
+			WithStartupLease(repository.NewLeaseRepository(db), ttl)
+		return op, processOrderChan
+	}
+	first, firstChan := newInstance(time.Minute)
+	second, secondChan := newInstance(time.Minute)
+
+	var wg sync.WaitGroup
+	for _, op := range []*OrderProcessorImpl{first, second} {
+		wg.Add(1)
+		go func(op *OrderProcessorImpl) {
+			defer wg.Done()
+			op.ProcessUnfinishedOrders()
+		}(op)
+	}
+	wg.Wait()
+
+	queued := []int{len(firstChan), len(secondChan)}
+	assert.ElementsMatch(t, []int{3, 0}, queued, "only one instance should republish the unfinished orders")
+
+	// an instance started after the holder shut down doesn't wait for the lease to expire
+	holder := first
+	if len(secondChan) > 0 {
+		holder = second
+	}
+	holder.ReleaseStartupLease(context.Background())
+	next, nextChan := newInstance(time.Minute)
+	next.ProcessUnfinishedOrders()
+	assert.Len(t, nextChan, 3)
+
+	// without a lease every instance republishes, as before
+	third, thirdChan := newInstance(0)
+	third.ProcessUnfinishedOrders()
+	assert.Len(t, thirdChan, 3)
+}
+
+func TestOrderProcessorImpl_Metrics(t *testing.T) {
+	t.Run("Failed Lookup Is Recached", func(t *testing.T) {
+		db := setupInMemoryProcessorDB(t, "processor_metrics_failure")
+		defer db.Close()
+		seedProcessorOrders(t, db, 1)
+
+		orderCache := &recordingOrderCache{}
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		op := NewOrderProcessor(repository.NewOrderRepository(db), repository.NewOrderHistoryRepository(db), orderCache,
+			NewWalletService(repository.NewWalletRepository(db)), &failingAccrualClient{}, make(chan repository.Order, 1), 1, 0, 0)
+		op.ProcessUnfinishedOrders()
+		assert.Equal(t, ProcessorMetrics{}, op.Metrics())
+		go op.ProcessOrders(ctx)
+
+		require.Eventually(t, func() bool {
+			return op.Metrics().Recached == 1
+		}, 5*time.Second, 5*time.Millisecond)
+		assert.Equal(t, ProcessorMetrics{Recached: 1, CacheSize: 1}, op.Metrics())
+	})
+
+	t.Run("Exhausted Order Is Counted", func(t *testing.T) {
+		db := setupInMemoryProcessorDB(t, "processor_metrics_exhausted")
+		defer db.Close()
+		seedProcessorOrders(t, db, 1)
+		_, err := db.Exec(`UPDATE orders SET attempts = 1 WHERE id = 'order0'`)
+		require.NoError(t, err)
+
+		orderCache := &recordingOrderCache{}
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		accrualClient := &slowAccrualClient{status: clients.PROCESSING}
+		op := NewOrderProcessor(repository.NewOrderRepository(db), repository.NewOrderHistoryRepository(db), orderCache,
+			NewWalletService(repository.NewWalletRepository(db)), accrualClient, make(chan repository.Order, 1), 1, 2, 0)
+		op.ProcessUnfinishedOrders()
+		go op.ProcessOrders(ctx)
+
+		require.Eventually(t, func() bool {
+			return op.Metrics().Exhausted == 1
+		}, 5*time.Second, 5*time.Millisecond)
+		assert.Equal(t, ProcessorMetrics{Exhausted: 1}, op.Metrics(), "an exhausted order is not polled again")
+	})
+}
+
+// nilInfoAccrualClient answers the lookup of panicOrderID with neither info nor error,
+// which the processor dereferences and panics on.
+type nilInfoAccrualClient struct {
+	slowAccrualClient
+	panicOrderID string
+}
+
+func (c *nilInfoAccrualClient) GetOrderInfo(orderID string) (*clients.AccrualResponseDto, error) {
+	if orderID == c.panicOrderID {
+		return nil, nil
+	}
+	return c.slowAccrualClient.GetOrderInfo(orderID)
+}
+
+func TestOrderProcessorImpl_ProcessOrders_RecoversPanic(t *testing.T) {
+	const total = 3
+	db := setupInMemoryProcessorDB(t, "processor_panic")
+	defer db.Close()
+	userUUID := seedProcessorOrders(t, db, total)
+
+	orderCache := &recordingOrderCache{}
+	processOrderChan := make(chan repository.Order, total)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// a single worker, so the orders after the panicking one are looked up by the same goroutine
+	accrualClient := &nilInfoAccrualClient{slowAccrualClient: slowAccrualClient{accrual: 10}, panicOrderID: "order0"}
+	op := NewOrderProcessor(repository.NewOrderRepository(db), repository.NewOrderHistoryRepository(db), orderCache,
+		NewWalletService(repository.NewWalletRepository(db)), accrualClient, processOrderChan, 1, 0, 0)
+	op.ProcessUnfinishedOrders()
+	go op.ProcessOrders(ctx)
+
+	require.Eventually(t, func() bool {
+		var processed int
+		err := db.Get(&processed, `SELECT count(*) FROM orders WHERE status = 'PROCESSED'`)
+		return err == nil && processed == total-1
+	}, 5*time.Second, 5*time.Millisecond)
+
+	var status string
+	require.NoError(t, db.Get(&status, `SELECT status FROM orders WHERE id = 'order0'`))
+	assert.Equal(t, string(repository.NEW), status)
+	var credits float64
+	require.NoError(t, db.Get(&credits, `SELECT credits FROM wallets WHERE user_uuid = ?`, userUUID.String()))
+	assert.Equal(t, float64((total-1)*10), credits)
+
+	orderCache.mu.Lock()
+	defer orderCache.mu.Unlock()
+	require.Len(t, orderCache.orders, 1, "the panicking order should be re-cached")
+	assert.Equal(t, "order0", orderCache.orders[0].ID)
+	assert.Equal(t, repository.NEW, orderCache.orders[0].Status)
+}
+
+func TestOrderProcessorImpl_ProcessOrders_CommitConcurrency(t *testing.T) {
+	const (
+		total             = 12
+		commitConcurrency = 3
+	)
+	db := setupInMemoryProcessorDB(t, "processor_commit_concurrency")
+	defer db.Close()
+
+	var inFlight, maxInFlight, committed atomic.Int32
+	or := &MockOrderRepository{}
+	or.On("GetDB").Return(db)
+	or.On("IncrementAttempts", mock.Anything, mock.Anything, mock.Anything).Return(1, nil)
+	or.On("UpsertOrder", mock.Anything, mock.Anything, mock.Anything).Run(func(mock.Arguments) {
+		current := inFlight.Add(1)
+		for {
+			seen := maxInFlight.Load()
+			if current <= seen || maxInFlight.CompareAndSwap(seen, current) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		inFlight.Add(-1)
+		committed.Add(1)
+	}).Return(nil)
+
+	processOrderChan := make(chan repository.Order, total)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// the status stays NEW, so the commits touch nothing but the mocked repository
+	accrualClient := &slowAccrualClient{status: clients.REGISTERED}
+	op := NewOrderProcessor(or, repository.NewOrderHistoryRepository(db), &recordingOrderCache{},
+		NewWalletService(repository.NewWalletRepository(db)), accrualClient, processOrderChan, total, 0, 0).
+		WithCommitConcurrency(commitConcurrency)
+	go op.ProcessOrders(ctx)
+	users := shardUsers(commitConcurrency)
+	for i := 0; i < total; i++ {
+		processOrderChan <- repository.Order{ID: fmt.Sprintf("order%d", i), UserUUID: users[i%len(users)], Status: repository.NEW}
+	}
+
+	require.Eventually(t, func() bool {
+		return committed.Load() == total
+	}, 5*time.Second, 5*time.Millisecond)
+	assert.LessOrEqual(t, maxInFlight.Load(), int32(commitConcurrency), "commits should never exceed the configured concurrency")
+	assert.Greater(t, maxInFlight.Load(), int32(1), "commits should run concurrently")
+}
+
+// shardUsers returns a user for each of n commit shards.
+func shardUsers(n int) []uuid.UUID {
+	users := make([]uuid.UUID, n)
+	for found := 0; found < n; {
+		user := uuid.New()
+		if shard := commitShard(user, n); users[shard] == uuid.Nil {
+			users[shard] = user
+			found++
+		}
+	}
+	return users
+}
+
+func TestOrderProcessorImpl_ProcessOrders_CommitsOfUserInOrder(t *testing.T) {
+	const total = 12
+	db := setupInMemoryProcessorDB(t, "processor_commits_of_user")
+	defer db.Close()
+
+	var inFlight, maxInFlight atomic.Int32
+	var mu sync.Mutex
+	var committed []string
+	or := &MockOrderRepository{}
+	or.On("GetDB").Return(db)
+	or.On("IncrementAttempts", mock.Anything, mock.Anything, mock.Anything).Return(1, nil)
+	or.On("UpsertOrder", mock.Anything, mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		if current := inFlight.Add(1); current > maxInFlight.Load() {
+			maxInFlight.Store(current)
+		}
+		time.Sleep(time.Millisecond)
+		inFlight.Add(-1)
+		mu.Lock()
+		defer mu.Unlock()
+		committed = append(committed, args.Get(2).(*repository.Order).ID)
+	}).Return(nil)
+
+	processOrderChan := make(chan repository.Order, total)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	accrualClient := &slowAccrualClient{status: clients.REGISTERED}
+	// a single lookup worker hands the results over in the order the orders came in
+	op := NewOrderProcessor(or, repository.NewOrderHistoryRepository(db), &recordingOrderCache{},
+		NewWalletService(repository.NewWalletRepository(db)), accrualClient, processOrderChan, 1, 0, 0).
+		WithCommitConcurrency(4)
+	go op.ProcessOrders(ctx)
+	userUUID := uuid.New()
+	want := make([]string, 0, total)
+	for i := 0; i < total; i++ {
+		want = append(want, fmt.Sprintf("order%d", i))
+		processOrderChan <- repository.Order{ID: want[i], UserUUID: userUUID, Status: repository.NEW}
+	}
+
+	require.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(committed) == total
+	}, 5*time.Second, 5*time.Millisecond)
+	assert.Equal(t, int32(1), maxInFlight.Load(), "a user's commits don't run concurrently")
+	assert.Equal(t, want, committed)
+}
+
+// countingAccrualClient answers like slowAccrualClient and counts the lookups.
+type countingAccrualClient struct {
+	slowAccrualClient
+	calls atomic.Int32
+}
+
+func (c *countingAccrualClient) GetOrderInfo(orderID string) (*clients.AccrualResponseDto, error) {
+	c.calls.Add(1)
+	return c.slowAccrualClient.GetOrderInfo(orderID)
+}
+
+func TestOrderProcessorImpl_ProcessOrders_PollFloor(t *testing.T) {
+	const floor = 500 * time.Millisecond
+	db := setupInMemoryProcessorDB(t, "processor_poll_floor")
+	defer db.Close()
+	seedProcessorOrders(t, db, 1)
+
+	orderCache := &recordingOrderCache{}
+	processOrderChan := make(chan repository.Order, 1)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	accrualClient := &countingAccrualClient{slowAccrualClient: slowAccrualClient{status: clients.PROCESSING}}
+	op := NewOrderProcessor(repository.NewOrderRepository(db), repository.NewOrderHistoryRepository(db), orderCache,
+		NewWalletService(repository.NewWalletRepository(db)), accrualClient, processOrderChan, 1, 0, 0).
+		WithPollFloor(floor)
+	op.ProcessUnfinishedOrders()
+	go op.ProcessOrders(ctx)
+
+	recached := func(n int) func() bool {
+		return func() bool {
+			orderCache.mu.Lock()
+			defer orderCache.mu.Unlock()
+			return len(orderCache.orders) == n
+		}
+	}
+	requeue := func(i int) {
+		orderCache.mu.Lock()
+		order := orderCache.orders[i]
+		orderCache.mu.Unlock()
+		processOrderChan <- order
+	}
+	// seeded orders were sent by NewOrderProcessor; PROCESSING re-queues the order right away
+	require.Eventually(t, recached(1), 5*time.Second, 5*time.Millisecond)
+	polledAt := time.Now()
+	requeue(0)
+
+	require.Eventually(t, recached(2), 5*time.Second, 5*time.Millisecond)
+	assert.Equal(t, int32(1), accrualClient.calls.Load(), "the order must not be polled again before the floor elapses")
+	orderCache.mu.Lock()
+	delay := orderCache.delays[1]
+	orderCache.mu.Unlock()
+	assert.Greater(t, delay, time.Duration(0))
+	assert.LessOrEqual(t, delay, floor, "the order should wait for the rest of the floor only")
+
+	time.Sleep(floor - time.Since(polledAt))
+	requeue(1)
+	require.Eventually(t, func() bool {
+		return accrualClient.calls.Load() == 2
+	}, 5*time.Second, 5*time.Millisecond, "the order should be polled again once the floor elapsed")
+}
+
+func TestOrderProcessorImpl_PollWait_EvictsOldPolls(t *testing.T) {
+	const floor = 20 * time.Millisecond
+	op := &OrderProcessorImpl{lastPolls: make(map[string]time.Time)}
+	op.WithPollFloor(floor)
+
+	assert.Zero(t, op.pollWait("354188083613"))
+	assert.Zero(t, op.pollWait("12345678903"))
+	assert.Greater(t, op.pollWait("354188083613"), time.Duration(0), "a poll within the floor must wait")
+
+	// neither order comes back, e.g. both were deleted
+	time.Sleep(floor)
+	assert.Zero(t, op.pollWait("79927398713"))
+
+	op.pollsMu.Lock()
+	defer op.pollsMu.Unlock()
+	assert.Len(t, op.lastPolls, 1, "polls older than the floor should be dropped")
+	assert.Contains(t, op.lastPolls, "79927398713")
+}
+
+// hangingOrderRepository stands in for a database that stops answering: saving an order blocks until the context ends.
+type hangingOrderRepository struct {
+	repository.OrderRepository
+}
+
+func (r *hangingOrderRepository) UpsertOrder(ctx context.Context, tx *sqlx.Tx, order *repository.Order) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestOrderProcessorImpl_ProcessOrders_WriteTimeout(t *testing.T) {
+	const writeTimeout = 50 * time.Millisecond
+	db := setupInMemoryProcessorDB(t, "processor_write_timeout")
+	defer db.Close()
+	// database/sql drops the connection of the timed out transaction, keep another one open so the in-memory database survives
+	conn, err := db.Conn(context.Background())
+	require.NoError(t, err)
+	defer conn.Close()
+	userUUID := seedProcessorOrders(t, db, 1)
+
+	orderCache := &recordingOrderCache{}
+	processOrderChan := make(chan repository.Order, 1)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	orderRepo := &hangingOrderRepository{OrderRepository: repository.NewOrderRepository(db)}
+	op := NewOrderProcessor(orderRepo, repository.NewOrderHistoryRepository(db), orderCache,
+		NewWalletService(repository.NewWalletRepository(db)), &slowAccrualClient{accrual: 10}, processOrderChan, 1, 0, 0).
+		WithWriteTimeout(writeTimeout)
+	op.ProcessUnfinishedOrders()
+	start := time.Now()
+	go op.ProcessOrders(ctx)
+
+	require.Eventually(t, func() bool {
+		return orderCache.ItemCount() == 1
+	}, 5*time.Second, 5*time.Millisecond, "the timed out order should go back to the cache")
+	assert.GreaterOrEqual(t, time.Since(start), writeTimeout)
+
+	// the attempt counted before the hang is rolled back with the rest of the transaction
+	var stored repository.Order
+	require.NoError(t, db.Get(&stored, `SELECT * FROM orders WHERE id = 'order0'`))
+	assert.Equal(t, repository.NEW, stored.Status)
+	assert.Zero(t, stored.Attempts)
+	assert.Nil(t, stored.Accrual)
+	var credits float64
+	require.NoError(t, db.Get(&credits, `SELECT credits FROM wallets WHERE user_uuid = ?`, userUUID.String()))
+	assert.Zero(t, credits)
+
+	orderCache.mu.Lock()
+	defer orderCache.mu.Unlock()
+	assert.Equal(t, "order0", orderCache.orders[0].ID)
+}
+
+func TestOrderProcessorImpl_UpdateOrder_CreditsOnce(t *testing.T) {
+	db := setupInMemoryProcessorDB(t, "processor_credits_once")
+	defer db.Close()
+	userUUID := seedProcessorOrders(t, db, 1)
+
+	orderCache := &recordingOrderCache{}
+	op := NewOrderProcessor(repository.NewOrderRepository(db), repository.NewOrderHistoryRepository(db), orderCache,
+		NewWalletService(repository.NewWalletRepository(db)), &slowAccrualClient{}, make(chan repository.Order), 1, 0, 0)
+
+	// the same accrual saved again, e.g. after a retry the first save's outcome was lost
+	accrual := 25.0
+	for i := 0; i < 2; i++ {
+		order := &repository.Order{ID: "order0", UserUUID: userUUID, Status: repository.PROCESSED, Accrual: &accrual}
+		require.NoError(t, op.updateOrder(order, repository.NEW))
+	}
+
+	var credits float64
+	require.NoError(t, db.Get(&credits, `SELECT credits FROM wallets WHERE user_uuid = ?`, userUUID.String()))
+	assert.Equal(t, accrual, credits)
+	var logged int
+	require.NoError(t, db.Get(&logged, `SELECT count(*) FROM wallet_transactions WHERE user_uuid = ?`, userUUID.String()))
+	assert.Equal(t, 1, logged)
+}
+
+func TestOrderProcessorImpl_UpdateOrder_CreditsOnceProcessed(t *testing.T) {
+	db := setupInMemoryProcessorDB(t, "processor_credits_once_processed")
+	defer db.Close()
+	userUUID := seedProcessorOrders(t, db, 1)
+
+	op := NewOrderProcessor(repository.NewOrderRepository(db), repository.NewOrderHistoryRepository(db), &recordingOrderCache{},
+		NewWalletService(repository.NewWalletRepository(db)), &slowAccrualClient{}, make(chan repository.Order), 1, 0, 0)
+
+	// the accrual system reports the accrual while still PROCESSING, then the order is PROCESSED
+	accrual := 25.0
+	processing := &repository.Order{ID: "order0", UserUUID: userUUID, Status: repository.PROCESSING, Accrual: &accrual}
+	require.NoError(t, op.updateOrder(processing, repository.NEW))
+	var credits float64
+	require.NoError(t, db.Get(&credits, `SELECT credits FROM wallets WHERE user_uuid = ?`, userUUID.String()))
+	assert.Zero(t, credits, "a PROCESSING accrual is pending, not credited")
+
+	processed := &repository.Order{ID: "order0", UserUUID: userUUID, Status: repository.PROCESSED, Accrual: &accrual}
+	require.NoError(t, op.updateOrder(processed, repository.PROCESSING))
+
+	require.NoError(t, db.Get(&credits, `SELECT credits FROM wallets WHERE user_uuid = ?`, userUUID.String()))
+	assert.Equal(t, accrual, credits)
+	var keys []string
+	require.NoError(t, db.Select(&keys, `SELECT idempotency_key FROM wallet_transactions WHERE user_uuid = ?`, userUUID.String()))
+	assert.Equal(t, []string{"order/order0"}, keys)
+}
+
+func TestOrderProcessorImpl_ProcessOrders_MaxLookupFailures(t *testing.T) {
+	const maxFailures = 3
+	db := setupInMemoryProcessorDB(t, "processor_max_lookup_failures")
+	defer db.Close()
+	seedProcessorOrders(t, db, 2)
+	_, err := db.Exec(`UPDATE orders SET lookup_failures = ? WHERE id = 'order0'`, maxFailures-1)
+	require.NoError(t, err)
+
+	orderCache := &recordingOrderCache{}
+	processOrderChan := make(chan repository.Order, 2)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	op := NewOrderProcessor(repository.NewOrderRepository(db), repository.NewOrderHistoryRepository(db), orderCache,
+		NewWalletService(repository.NewWalletRepository(db)), &failingAccrualClient{}, processOrderChan, 1, 0, 0).
+		WithMaxLookupFailures(maxFailures)
+	op.ProcessUnfinishedOrders()
+	go op.ProcessOrders(ctx)
+
+	require.Eventually(t, func() bool {
+		return orderCache.ItemCount() == 1 && op.Metrics().Exhausted == 1
+	}, 5*time.Second, 5*time.Millisecond)
+
+	var failed repository.Order
+	require.NoError(t, db.Get(&failed, `SELECT * FROM orders WHERE id = 'order0'`))
+	assert.Equal(t, repository.INVALID, failed.Status, "order reaching the failure threshold must be given up on")
+	assert.Equal(t, maxFailures, failed.LookupFailures)
+	assert.Zero(t, failed.Attempts, "failed lookups don't count as attempts")
+	require.NotNil(t, failed.FailureReason)
+	assert.Contains(t, *failed.FailureReason, failureMaxLookupFailures)
+	var history []string
+	require.NoError(t, db.Select(&history, `SELECT status FROM order_status_history WHERE order_id = 'order0'`))
+	assert.Equal(t, []string{string(repository.INVALID)}, history)
+
+	var pending repository.Order
+	require.NoError(t, db.Get(&pending, `SELECT * FROM orders WHERE id = 'order1'`))
+	assert.Equal(t, repository.NEW, pending.Status)
+	assert.Equal(t, 1, pending.LookupFailures)
+
+	// only the order below the threshold goes back to the cache for another lookup
+	orderCache.mu.Lock()
+	defer orderCache.mu.Unlock()
+	assert.Equal(t, "order1", orderCache.orders[0].ID)
+}
+
+// stalledAccrualClient blocks every lookup until release is closed, like a hung accrual system.
+type stalledAccrualClient struct {
+	release chan struct{}
+}
+
+func (c *stalledAccrualClient) GetOrderInfo(orderID string) (*clients.AccrualResponseDto, error) {
+	<-c.release
+	return nil, fmt.Errorf("accrual system unavailable")
+}
+
+func (c *stalledAccrualClient) Version(ctx context.Context) (string, error) {
+	return "test", nil
+}
+
+func TestOrderProcessorImpl_LastHeartbeat(t *testing.T) {
+	db := setupInMemoryProcessorDB(t, "processor_heartbeat")
+	defer db.Close()
+	accrualClient := &stalledAccrualClient{release: make(chan struct{})}
+	defer close(accrualClient.release)
+	processOrderChan := make(chan repository.Order, 1)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	op := NewOrderProcessor(repository.NewOrderRepository(db), repository.NewOrderHistoryRepository(db), &recordingOrderCache{},
+		NewWalletService(repository.NewWalletRepository(db)), accrualClient, processOrderChan, 1, 0, 0)
+	op.heartbeatInterval = 10 * time.Millisecond
+	assert.True(t, op.LastHeartbeat().IsZero(), "no heartbeat before the processor starts")
+	go op.ProcessOrders(ctx)
+
+	// an idle processor keeps beating
+	require.Eventually(t, func() bool {
+		return !op.LastHeartbeat().IsZero()
+	}, time.Second, 5*time.Millisecond)
+	started := op.LastHeartbeat()
+	require.Eventually(t, func() bool {
+		return op.LastHeartbeat().After(started)
+	}, time.Second, 5*time.Millisecond)
+
+	// the only worker hangs on the lookup, so the heartbeat goes stale
+	processOrderChan <- repository.Order{ID: "order0", Status: repository.NEW}
+	require.Eventually(t, func() bool {
+		return time.Since(op.LastHeartbeat()) > 200*time.Millisecond
+	}, 2*time.Second, 10*time.Millisecond)
+	stalled := op.LastHeartbeat()
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, stalled, op.LastHeartbeat(), "a stalled processor must not beat")
+}
@@ -2,11 +2,15 @@ package service
 
 import (
 	"context"
+	"database/sql"
 	"errors"
 	"fmt"
 	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	appContext "github.com/ujwegh/gophermart/internal/app/context"
 	appErrors "github.com/ujwegh/gophermart/internal/app/errors"
 	"github.com/ujwegh/gophermart/internal/app/repository"
+	"github.com/ujwegh/gophermart/internal/app/util"
 	"net/http"
 	"time"
 )
@@ -14,63 +18,364 @@ import (
 type OrderService interface {
 	CreateOrder(ctx context.Context, orderID string, userUID *uuid.UUID) (*repository.Order, error)
 	GetOrderByID(ctx context.Context, orderID string) (*repository.Order, error)
-	GetOrders(ctx context.Context, uid *uuid.UUID) (*[]repository.Order, error)
+	GetUserOrderByID(ctx context.Context, orderID string, userUID *uuid.UUID) (*repository.Order, error)
+	GetOrders(ctx context.Context, uid *uuid.UUID, limit int, offset int) (*[]repository.Order, error)
+	GetOrdersAfterCursor(ctx context.Context, uid *uuid.UUID, cursor *repository.OrderCursor, limit int) (*[]repository.Order, error)
+	GetOrdersSince(ctx context.Context, uid *uuid.UUID, since time.Time, limit int, offset int) (*[]repository.Order, error)
+	CountOrders(ctx context.Context, uid *uuid.UUID) (int, error)
+	GetPendingAccruals(ctx context.Context, uid *uuid.UUID) (float64, error)
+	GetProcessedOrders(ctx context.Context, uid *uuid.UUID, limit int, offset int) (*[]repository.Order, error)
+	GetOrdersInRange(ctx context.Context, from time.Time, to time.Time, limit int, offset int) (*[]repository.UserOrder, error)
+	GetOrderHistory(ctx context.Context, orderID string, userUID *uuid.UUID) (*[]repository.OrderStatusChange, error)
+	RetryOrder(ctx context.Context, orderID string, userUID *uuid.UUID) (*repository.Order, error)
+	RetryOrders(ctx context.Context, orderIDs []string) (*BatchRetryResult, error)
+	GetDeadLetterOrders(ctx context.Context, limit int, offset int) (*[]repository.UserOrder, error)
+	RequeueDeadLetterOrders(ctx context.Context, orderIDs []string) (*BatchRetryResult, error)
+	CorrectAccrual(ctx context.Context, orderID string, accrual repository.Cents) (*repository.AccrualCorrection, error)
+}
+
+// BatchRetryResult sorts the order numbers of a batch retry by outcome, keeping the request order.
+type BatchRetryResult struct {
+	Retried []string
+	// Skipped orders are not in a status the batch retries
+	Skipped []string
+	Missing []string
 }
 
 type OrderServiceImpl struct {
-	orderRepo     repository.OrderRepository
-	walletService WalletService
-	orderChan     chan repository.Order
+	orderRepo        repository.OrderRepository
+	orderHistoryRepo repository.OrderHistoryRepository
+	walletService    WalletService
+	orderChan        chan repository.Order
+	// stuckAfter is how long a PROCESSING order has to stay unchanged before a retry may pick it up
+	stuckAfter time.Duration
 }
 
-func NewOrderService(orderRepo repository.OrderRepository, walletService WalletService, processOrderChan chan repository.Order) *OrderServiceImpl {
+// defaultStuckAfter matches the default ORDER_RETRY_COOLDOWN_SEC.
+const defaultStuckAfter = time.Minute
+
+func NewOrderService(orderRepo repository.OrderRepository,
+	orderHistoryRepo repository.OrderHistoryRepository,
+	walletService WalletService,
+	processOrderChan chan repository.Order) *OrderServiceImpl {
 	return &OrderServiceImpl{
-		orderRepo:     orderRepo,
-		walletService: walletService,
-		orderChan:     processOrderChan,
+		orderRepo:        orderRepo,
+		orderHistoryRepo: orderHistoryRepo,
+		walletService:    walletService,
+		orderChan:        processOrderChan,
+		stuckAfter:       defaultStuckAfter,
 	}
 }
 
+// WithStuckAfter sets how long a PROCESSING order has to stay unchanged before it counts as stuck and may be
+// retried, so an order the processor is still working on isn't sent to processing twice.
+func (os *OrderServiceImpl) WithStuckAfter(stuckAfter time.Duration) *OrderServiceImpl {
+	os.stuckAfter = stuckAfter
+	return os
+}
+
 func (os *OrderServiceImpl) CreateOrder(ctx context.Context, orderID string, userUID *uuid.UUID) (*repository.Order, error) {
+	orderID = util.NormalizeOrderNumber(orderID)
 	order, err := os.GetOrderByID(ctx, orderID)
 	appErr := &appErrors.ResponseCodeError{}
 	if err != nil && !errors.As(err, appErr) {
 		return nil, err
 	}
 
-	if order != nil && userUID.String() != order.UserUUID.String() {
-		msg := "order already created by another user"
-		return nil, appErrors.NewWithCode(errors.New(msg), msg, http.StatusConflict)
-	}
-	if order != nil && userUID.String() == order.UserUUID.String() {
-		msg := "repeated order"
-		return nil, appErrors.New(errors.New(msg), msg)
+	if order != nil {
+		return nil, existingOrderError(order, userUID)
 	}
 
 	now := time.Now()
+	tenantID, _ := appContext.TenantID(ctx)
 	newOrder := &repository.Order{
 		ID:        orderID,
 		UserUUID:  *userUID,
 		Status:    repository.NEW,
 		CreatedAt: now,
 		UpdatedAt: now,
+		TenantID:  tenantID,
 	}
 
-	if err = os.orderRepo.CreateOrder(ctx, newOrder); err != nil {
+	// users registered before wallets existed get theirs with the first order
+	err = repository.WithTransaction(ctx, os.orderRepo.GetDB(), func(tx *sqlx.Tx) error {
+		if err := os.walletService.EnsureWallet(ctx, tx, userUID); err != nil {
+			return err
+		}
+		return os.orderRepo.CreateOrderTx(ctx, tx, newOrder)
+	})
+	if err != nil {
+		if errors.Is(err, repository.ErrOrderExists) {
+			// a concurrent request inserted the same number after our lookup
+			order, err := os.GetOrderByID(ctx, orderID)
+			if errors.Is(err, sql.ErrNoRows) {
+				// the lookups only see the tenant of ctx, order numbers are unique across tenants
+				msg := "order already created by another user"
+				return nil, appErrors.NewWithCode(err, msg, http.StatusConflict)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("create order: %w", err)
+			}
+			return nil, existingOrderError(order, userUID)
+		}
 		return nil, fmt.Errorf("create order: %w", err)
 	}
 	os.orderChan <- *newOrder // send order to process channel
 	return newOrder, nil
 }
 
+func existingOrderError(order *repository.Order, userUID *uuid.UUID) error {
+	if userUID.String() != order.UserUUID.String() {
+		msg := "order already created by another user"
+		return appErrors.NewWithCode(errors.New(msg), msg, http.StatusConflict)
+	}
+	msg := "repeated order"
+	return appErrors.New(errors.New(msg), msg)
+}
+
 func (os *OrderServiceImpl) GetOrderByID(ctx context.Context, orderID string) (*repository.Order, error) {
 	return os.orderRepo.GetOrderByID(ctx, orderID)
 }
 
-func (os *OrderServiceImpl) GetOrders(ctx context.Context, uid *uuid.UUID) (*[]repository.Order, error) {
-	orders, err := os.orderRepo.GetOrdersByUserUID(ctx, uid)
+func (os *OrderServiceImpl) GetOrders(ctx context.Context, uid *uuid.UUID, limit int, offset int) (*[]repository.Order, error) {
+	orders, err := os.orderRepo.GetOrdersByUserUID(ctx, uid, limit, offset)
 	if err != nil {
 		return nil, err
 	}
 	return orders, nil
 }
+
+func (os *OrderServiceImpl) GetOrdersAfterCursor(ctx context.Context, uid *uuid.UUID, cursor *repository.OrderCursor, limit int) (*[]repository.Order, error) {
+	return os.orderRepo.GetOrdersAfterCursor(ctx, uid, cursor, limit)
+}
+
+// GetOrdersSince returns the user's orders uploaded after since, newest first.
+func (os *OrderServiceImpl) GetOrdersSince(ctx context.Context, uid *uuid.UUID, since time.Time, limit int, offset int) (*[]repository.Order, error) {
+	return os.orderRepo.GetOrdersSince(ctx, uid, since, limit, offset)
+}
+
+func (os *OrderServiceImpl) CountOrders(ctx context.Context, uid *uuid.UUID) (int, error) {
+	return os.orderRepo.CountOrdersByUserUID(ctx, uid)
+}
+
+// GetPendingAccruals returns the accruals of the user's orders that are still being processed.
+func (os *OrderServiceImpl) GetPendingAccruals(ctx context.Context, uid *uuid.UUID) (float64, error) {
+	pending, err := os.orderRepo.SumPendingAccruals(ctx, uid)
+	if err != nil {
+		return 0, appErrors.New(err, "get pending accruals")
+	}
+	return pending, nil
+}
+
+func (os *OrderServiceImpl) GetProcessedOrders(ctx context.Context, uid *uuid.UUID, limit int, offset int) (*[]repository.Order, error) {
+	return os.orderRepo.GetProcessedOrdersByUserUID(ctx, uid, limit, offset)
+}
+
+func (os *OrderServiceImpl) GetOrdersInRange(ctx context.Context, from time.Time, to time.Time, limit int, offset int) (*[]repository.UserOrder, error) {
+	return os.orderRepo.GetOrdersInRange(ctx, from, to, limit, offset)
+}
+
+func (os *OrderServiceImpl) GetOrderHistory(ctx context.Context, orderID string, userUID *uuid.UUID) (*[]repository.OrderStatusChange, error) {
+	orderID = util.NormalizeOrderNumber(orderID)
+	if _, err := os.GetUserOrderByID(ctx, orderID, userUID); err != nil {
+		return nil, err
+	}
+	return os.orderHistoryRepo.GetStatusHistory(ctx, orderID)
+}
+
+// RetryOrder resets an INVALID or stuck PROCESSING order back to NEW with a fresh attempts budget
+// and sends it to processing again.
+func (os *OrderServiceImpl) RetryOrder(ctx context.Context, orderID string, userUID *uuid.UUID) (*repository.Order, error) {
+	order, err := os.GetUserOrderByID(ctx, orderID, userUID)
+	if err != nil {
+		return nil, err
+	}
+	if !os.isRetryable(order) {
+		msg := "Order cannot be retried"
+		return nil, appErrors.NewWithCode(errors.New(msg), msg, http.StatusConflict)
+	}
+	if err := os.resetOrder(ctx, order); err != nil {
+		return nil, err
+	}
+	return order, nil
+}
+
+// RetryOrders retries every INVALID or stuck PROCESSING order of the batch regardless of its owner.
+// The orders are loaded with a single query; numbers are normalized and duplicates retried once.
+func (os *OrderServiceImpl) RetryOrders(ctx context.Context, orderIDs []string) (*BatchRetryResult, error) {
+	return os.retryBatch(ctx, orderIDs, os.isRetryable)
+}
+
+// GetDeadLetterOrders returns the INVALID orders of all users with why they failed, the longest failed first.
+func (os *OrderServiceImpl) GetDeadLetterOrders(ctx context.Context, limit int, offset int) (*[]repository.UserOrder, error) {
+	return os.orderRepo.GetDeadLetterOrders(ctx, limit, offset)
+}
+
+// RequeueDeadLetterOrders sends the INVALID orders of the batch to processing again with a fresh attempts budget,
+// regardless of their owner. Orders in other statuses are skipped.
+func (os *OrderServiceImpl) RequeueDeadLetterOrders(ctx context.Context, orderIDs []string) (*BatchRetryResult, error) {
+	return os.retryBatch(ctx, orderIDs, func(order *repository.Order) bool {
+		return order.Status == repository.INVALID
+	})
+}
+
+// retryBatch resets the orders of the batch accepted by retryable. The orders are loaded with a single query;
+// numbers are normalized and duplicates retried once.
+func (os *OrderServiceImpl) retryBatch(ctx context.Context, orderIDs []string, retryable func(*repository.Order) bool) (*BatchRetryResult, error) {
+	ids := make([]string, 0, len(orderIDs))
+	seen := make(map[string]bool, len(orderIDs))
+	for _, orderID := range orderIDs {
+		id := util.NormalizeOrderNumber(orderID)
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	orders, err := os.orderRepo.GetOrdersByIDs(ctx, ids)
+	if err != nil {
+		return nil, fmt.Errorf("retry orders: %w", err)
+	}
+	byID := make(map[string]*repository.Order, len(*orders))
+	for i := range *orders {
+		byID[(*orders)[i].ID] = &(*orders)[i]
+	}
+
+	result := &BatchRetryResult{Retried: []string{}, Skipped: []string{}, Missing: []string{}}
+	for _, id := range ids {
+		order, ok := byID[id]
+		switch {
+		case !ok:
+			result.Missing = append(result.Missing, id)
+		case !retryable(order):
+			result.Skipped = append(result.Skipped, id)
+		default:
+			if err := os.resetOrder(ctx, order); err != nil {
+				return nil, err
+			}
+			result.Retried = append(result.Retried, id)
+		}
+	}
+	return result, nil
+}
+
+// isRetryable accepts INVALID orders and PROCESSING orders that haven't changed for stuckAfter.
+func (os *OrderServiceImpl) isRetryable(order *repository.Order) bool {
+	switch order.Status {
+	case repository.INVALID:
+		return true
+	case repository.PROCESSING:
+		return time.Since(order.UpdatedAt) >= os.stuckAfter
+	default:
+		return false
+	}
+}
+
+// resetOrder gives the order a fresh attempts budget, records it going back to NEW and sends it to processing again.
+// Sending gives up once ctx is done instead of blocking on a full channel; the order stays NEW and is loaded
+// with the other unprocessed orders when the processor starts.
+func (os *OrderServiceImpl) resetOrder(ctx context.Context, order *repository.Order) error {
+	order.Status = repository.NEW
+	order.Accrual = nil
+	order.Attempts = 0
+	order.LookupFailures = 0
+	order.FailureReason = nil
+	order.UpdatedAt = time.Now()
+	err := repository.WithTransaction(ctx, os.orderRepo.GetDB(), func(tx *sqlx.Tx) error {
+		if err := os.orderRepo.ResetOrder(ctx, tx, order.ID, order.UpdatedAt); err != nil {
+			return err
+		}
+		change := &repository.OrderStatusChange{OrderID: order.ID, Status: order.Status, ChangedAt: order.UpdatedAt}
+		return os.orderHistoryRepo.AddStatusChange(ctx, tx, change)
+	})
+	if err != nil {
+		return fmt.Errorf("retry order: %w", err)
+	}
+	select {
+	case os.orderChan <- *order: // send order to process channel
+		return nil
+	case <-ctx.Done():
+		return appContext.GetContextError(ctx)
+	}
+}
+
+// GetUserOrderByID returns the order only if it belongs to the user and reports it as not found otherwise,
+// so user-facing endpoints don't reveal other users' orders. GetOrderByID stays unscoped for internal use.
+func (os *OrderServiceImpl) GetUserOrderByID(ctx context.Context, orderID string, userUID *uuid.UUID) (*repository.Order, error) {
+	order, err := os.GetOrderByID(ctx, util.NormalizeOrderNumber(orderID))
+	if err != nil {
+		return nil, err
+	}
+	if order.UserUUID != *userUID {
+		msg := "Order not found"
+		return nil, appErrors.NewWithCode(errors.New(msg), msg, http.StatusNotFound)
+	}
+	return order, nil
+}
+
+// CorrectAccrual sets the accrual of a PROCESSED order and credits the owner's wallet with the difference,
+// which is negative when the accrual goes down. The wallet is locked before the order is read, so corrections
+// of the same user's orders apply one after another. A correction that would leave a negative balance is refused.
+// Correcting the accrual to zero clears it.
+func (os *OrderServiceImpl) CorrectAccrual(ctx context.Context, orderID string, accrual repository.Cents) (*repository.AccrualCorrection, error) {
+	orderID = util.NormalizeOrderNumber(orderID)
+	owner, err := os.orderRepo.GetOrderByID(ctx, orderID)
+	if err != nil {
+		return nil, err
+	}
+
+	var correction *repository.AccrualCorrection
+	err = repository.WithTransaction(ctx, os.orderRepo.GetDB(), func(tx *sqlx.Tx) error {
+		wallet, err := os.walletService.GetWalletForUpdate(ctx, tx, &owner.UserUUID)
+		if err != nil {
+			return err
+		}
+		order, err := os.orderRepo.GetOrderByIDTx(ctx, tx, orderID)
+		if err != nil {
+			return err
+		}
+		if order.Status != repository.PROCESSED {
+			msg := "Only PROCESSED orders can be corrected"
+			return appErrors.NewWithCode(errors.New(msg), msg, http.StatusConflict)
+		}
+		var previous repository.Cents
+		if order.Accrual != nil {
+			previous = repository.CentsOf(*order.Accrual)
+		}
+		delta := accrual - previous
+		if repository.CentsOf(wallet.Credits-wallet.Debits)+delta < 0 {
+			msg := "Correction exceeds the current balance"
+			return appErrors.NewWithCode(errors.New(msg), msg, http.StatusConflict)
+		}
+
+		now := time.Now()
+		// orders without accrual have none rather than a zero one, the accrual_positive check refuses zero
+		order.Accrual = nil
+		if accrual > 0 {
+			corrected := accrual.Float64()
+			order.Accrual = &corrected
+		}
+		order.UpdatedAt = now
+		if err := os.orderRepo.UpdateOrder(ctx, tx, order); err != nil {
+			return err
+		}
+		if _, err := os.walletService.Credit(ctx, tx, &order.UserUUID, delta); err != nil {
+			return err
+		}
+		correction = &repository.AccrualCorrection{
+			OrderID:         order.ID,
+			UserUUID:        order.UserUUID,
+			PreviousAccrual: previous,
+			Accrual:         accrual,
+			Delta:           delta,
+			CreatedAt:       now,
+		}
+		if err := os.orderRepo.CreateAccrualCorrection(ctx, tx, correction); err != nil {
+			return err
+		}
+		return appContext.GetContextError(ctx)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return correction, nil
+}
@@ -0,0 +1,396 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	appErrors "github.com/ujwegh/gophermart/internal/app/errors"
+	"github.com/ujwegh/gophermart/internal/app/repository"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestOrderServiceImpl_RetryOrder(t *testing.T) {
+	ownerUID := uuid.New()
+	otherUID := uuid.New()
+	tests := []struct {
+		name         string
+		status       repository.Status
+		updatedAt    time.Time
+		userUID      *uuid.UUID
+		wantCode     int
+		wantEnqueued bool
+	}{
+		{name: "Invalid Order Is Re-enqueued", status: repository.INVALID, userUID: &ownerUID, wantEnqueued: true},
+		{
+			name:         "Stuck Processing Order Is Re-enqueued",
+			status:       repository.PROCESSING,
+			updatedAt:    time.Now().Add(-2 * time.Minute),
+			userUID:      &ownerUID,
+			wantEnqueued: true,
+		},
+		{
+			name:      "Processing Order In Flight Cannot Be Retried",
+			status:    repository.PROCESSING,
+			updatedAt: time.Now(),
+			userUID:   &ownerUID,
+			wantCode:  http.StatusConflict,
+		},
+		{name: "Processed Order Cannot Be Retried", status: repository.PROCESSED, userUID: &ownerUID, wantCode: http.StatusConflict},
+		{name: "Order Of Another User", status: repository.INVALID, userUID: &otherUID, wantCode: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			accrual := 0.0
+			or := &MockOrderRepository{}
+			or.On("GetOrderByID", mock.Anything, "354188083613").
+				Return(&repository.Order{ID: "354188083613", UserUUID: ownerUID, Status: tt.status, Accrual: &accrual, UpdatedAt: tt.updatedAt}, nil)
+			or.On("ResetOrder", mock.Anything, mock.Anything, "354188083613", mock.Anything).Return(nil)
+			db := setupInMemoryProcessorDB(t, "retry_order_"+strings.ReplaceAll(tt.name, " ", "_"))
+			defer db.Close()
+			or.On("GetDB").Return(db)
+			orderChan := make(chan repository.Order, 1)
+
+			os := NewOrderService(or, repository.NewOrderHistoryRepository(db), nil, orderChan)
+			got, err := os.RetryOrder(context.Background(), "354188083613", tt.userUID)
+
+			if !tt.wantEnqueued {
+				appErr := appErrors.ResponseCodeError{}
+				require.True(t, errors.As(err, &appErr))
+				assert.Equal(t, tt.wantCode, appErr.Code())
+				assert.Empty(t, orderChan)
+				or.AssertNotCalled(t, "ResetOrder", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, repository.NEW, got.Status)
+			assert.Nil(t, got.Accrual)
+			require.Len(t, orderChan, 1)
+			enqueued := <-orderChan
+			assert.Equal(t, "354188083613", enqueued.ID)
+			assert.Equal(t, repository.NEW, enqueued.Status)
+
+			var history []string
+			require.NoError(t, db.Select(&history, `SELECT status FROM order_status_history WHERE order_id = '354188083613'`))
+			assert.Equal(t, []string{string(repository.NEW)}, history, "the reset shows up in the order history")
+		})
+	}
+}
+
+func TestOrderServiceImpl_RetryOrder_QueueFull(t *testing.T) {
+	userUID := uuid.New()
+	or := &MockOrderRepository{}
+	or.On("GetOrderByID", mock.Anything, "354188083613").
+		Return(&repository.Order{ID: "354188083613", UserUUID: userUID, Status: repository.INVALID}, nil)
+	or.On("ResetOrder", mock.Anything, mock.Anything, "354188083613", mock.Anything).Return(nil)
+	db := setupInMemoryProcessorDB(t, "retry_order_queue_full")
+	defer db.Close()
+	or.On("GetDB").Return(db)
+	orderChan := make(chan repository.Order, 1)
+	orderChan <- repository.Order{ID: "12345678903"}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	os := NewOrderService(or, repository.NewOrderHistoryRepository(db), nil, orderChan)
+	_, err := os.RetryOrder(ctx, "354188083613", &userUID)
+
+	appErr := appErrors.ResponseCodeError{}
+	require.True(t, errors.As(err, &appErr), "the retry gives up with the request instead of blocking")
+	assert.Equal(t, http.StatusInternalServerError, appErr.Code())
+	assert.Len(t, orderChan, 1)
+}
+
+func TestOrderServiceImpl_GetUserOrderByID(t *testing.T) {
+	ownerUID := uuid.New()
+	otherUID := uuid.New()
+	tests := []struct {
+		name     string
+		userUID  *uuid.UUID
+		repoErr  error
+		wantCode int
+	}{
+		{name: "Owner Gets Order", userUID: &ownerUID},
+		{name: "Other User Gets Not Found", userUID: &otherUID, wantCode: http.StatusNotFound},
+		{
+			name:     "Missing Order",
+			userUID:  &ownerUID,
+			repoErr:  appErrors.NewWithCode(errors.New("no rows"), "Order not found", http.StatusNotFound),
+			wantCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			order := &repository.Order{ID: "354188083613", UserUUID: ownerUID, Status: repository.NEW}
+			or := &MockOrderRepository{}
+			if tt.repoErr != nil {
+				or.On("GetOrderByID", mock.Anything, "354188083613").Return((*repository.Order)(nil), tt.repoErr)
+			} else {
+				or.On("GetOrderByID", mock.Anything, "354188083613").Return(order, nil)
+			}
+
+			os := NewOrderService(or, nil, nil, nil)
+			got, err := os.GetUserOrderByID(context.Background(), "354188083613", tt.userUID)
+
+			if tt.wantCode != 0 {
+				assert.Nil(t, got)
+				appErr := appErrors.ResponseCodeError{}
+				require.True(t, errors.As(err, &appErr))
+				assert.Equal(t, tt.wantCode, appErr.Code())
+				assert.Equal(t, "Order not found", appErr.Msg())
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, order, got)
+		})
+	}
+}
+
+func TestOrderServiceImpl_CreateOrderNormalizesNumber(t *testing.T) {
+	ownerUID := uuid.New()
+	otherUID := uuid.New()
+	tests := []struct {
+		name     string
+		orderID  string
+		existing *repository.Order
+		userUID  *uuid.UUID
+		wantCode int
+	}{
+		{name: "New Order Stored Without Leading Zeros", orderID: "00354188083613", userUID: &ownerUID},
+		{name: "Plain Number Stored As Is", orderID: "354188083613", userUID: &ownerUID},
+		{
+			name:     "Leading Zeros Repeat Own Order",
+			orderID:  "00354188083613",
+			existing: &repository.Order{ID: "354188083613", UserUUID: ownerUID},
+			userUID:  &ownerUID,
+			wantCode: http.StatusInternalServerError,
+		},
+		{
+			name:     "Leading Zeros Match Another User's Order",
+			orderID:  "0354188083613",
+			existing: &repository.Order{ID: "354188083613", UserUUID: ownerUID},
+			userUID:  &otherUID,
+			wantCode: http.StatusConflict,
+		},
+	}
+
+	db := setupInMemoryProcessorDB(t, "create_order_normalize")
+	defer db.Close()
+	walletService := NewWalletService(repository.NewWalletRepository(db))
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			or := &MockOrderRepository{}
+			or.On("GetDB").Return(db)
+			if tt.existing != nil {
+				or.On("GetOrderByID", mock.Anything, "354188083613").Return(tt.existing, nil)
+			} else {
+				or.On("GetOrderByID", mock.Anything, "354188083613").
+					Return((*repository.Order)(nil), appErrors.NewWithCode(errors.New("no rows"), "Order not found", http.StatusNotFound))
+				or.On("CreateOrderTx", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+			}
+			orderChan := make(chan repository.Order, 1)
+
+			os := NewOrderService(or, nil, walletService, orderChan)
+			got, err := os.CreateOrder(context.Background(), tt.orderID, tt.userUID)
+
+			if tt.wantCode != 0 {
+				assert.Nil(t, got)
+				appErr := appErrors.ResponseCodeError{}
+				require.True(t, errors.As(err, &appErr))
+				assert.Equal(t, tt.wantCode, appErr.Code())
+				or.AssertNotCalled(t, "CreateOrderTx", mock.Anything, mock.Anything, mock.Anything)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, "354188083613", got.ID)
+			or.AssertCalled(t, "CreateOrderTx", mock.Anything, mock.Anything, mock.MatchedBy(func(o *repository.Order) bool {
+				return o.ID == "354188083613"
+			}))
+		})
+	}
+}
+
+func TestOrderServiceImpl_CreateOrder_MissingWallet(t *testing.T) {
+	db := setupInMemoryProcessorDB(t, "create_order_missing_wallet")
+	defer db.Close()
+	walletRepo := repository.NewWalletRepository(db)
+	os := NewOrderService(repository.NewOrderRepository(db), nil, NewWalletService(walletRepo), make(chan repository.Order, 2))
+
+	// registered before wallets were introduced
+	userUID := uuid.New()
+	order, err := os.CreateOrder(defaultTenantContext(), "354188083613", &userUID)
+	require.NoError(t, err)
+	assert.Equal(t, repository.NEW, order.Status)
+
+	wallet, err := walletRepo.GetWallet(defaultTenantContext(), &userUID)
+	require.NoError(t, err)
+	assert.Equal(t, 0.0, wallet.Credits)
+	assert.Equal(t, 0.0, wallet.Debits)
+
+	// later orders keep the wallet
+	_, err = os.CreateOrder(defaultTenantContext(), "12345678903", &userUID)
+	require.NoError(t, err)
+	var wallets int
+	require.NoError(t, db.Get(&wallets, `SELECT count(*) FROM wallets WHERE user_uuid = ?`, userUID.String()))
+	assert.Equal(t, 1, wallets)
+}
+
+func TestOrderServiceImpl_RetryOrders(t *testing.T) {
+	userUID := uuid.New()
+	or := &MockOrderRepository{}
+	or.On("GetOrdersByIDs", mock.Anything, []string{"354188083613", "12345678903", "79927398713", "4561261212345467"}).
+		Return(&[]repository.Order{
+			{ID: "12345678903", UserUUID: userUID, Status: repository.PROCESSED},
+			{ID: "4561261212345467", UserUUID: userUID, Status: repository.PROCESSING, UpdatedAt: time.Now().Add(-2 * time.Minute)},
+			{ID: "79927398713", UserUUID: userUID, Status: repository.PROCESSING, UpdatedAt: time.Now()},
+			{ID: "354188083613", UserUUID: userUID, Status: repository.INVALID},
+		}, nil)
+	or.On("ResetOrder", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	db := setupInMemoryProcessorDB(t, "retry_orders")
+	defer db.Close()
+	or.On("GetDB").Return(db)
+	orderChan := make(chan repository.Order, 4)
+
+	os := NewOrderService(or, repository.NewOrderHistoryRepository(db), nil, orderChan)
+	got, err := os.RetryOrders(context.Background(),
+		[]string{"354188083613", "12345678903", "0354188083613", "79927398713", "4561261212345467"})
+
+	require.NoError(t, err)
+	assert.Equal(t, &BatchRetryResult{
+		Retried: []string{"354188083613", "4561261212345467"},
+		Skipped: []string{"12345678903", "79927398713"},
+		Missing: []string{},
+	}, got)
+	or.AssertNumberOfCalls(t, "GetOrdersByIDs", 1)
+	or.AssertNumberOfCalls(t, "ResetOrder", 2)
+	require.Len(t, orderChan, 2)
+	for _, id := range got.Retried {
+		enqueued := <-orderChan
+		assert.Equal(t, id, enqueued.ID)
+		assert.Equal(t, repository.NEW, enqueued.Status)
+	}
+}
+
+func TestOrderServiceImpl_RequeueDeadLetterOrders(t *testing.T) {
+	userUID := uuid.New()
+	reason := "declined by the accrual system"
+	or := &MockOrderRepository{}
+	or.On("GetOrdersByIDs", mock.Anything, []string{"354188083613", "4561261212345467"}).
+		Return(&[]repository.Order{
+			{ID: "4561261212345467", UserUUID: userUID, Status: repository.PROCESSING},
+			{ID: "354188083613", UserUUID: userUID, Status: repository.INVALID, LookupFailures: 20, FailureReason: &reason},
+		}, nil)
+	or.On("ResetOrder", mock.Anything, mock.Anything, "354188083613", mock.Anything).Return(nil)
+	db := setupInMemoryProcessorDB(t, "requeue_dead_letter_orders")
+	defer db.Close()
+	or.On("GetDB").Return(db)
+	orderChan := make(chan repository.Order, 2)
+
+	os := NewOrderService(or, repository.NewOrderHistoryRepository(db), nil, orderChan)
+	got, err := os.RequeueDeadLetterOrders(context.Background(), []string{"354188083613", "4561261212345467"})
+
+	require.NoError(t, err)
+	assert.Equal(t, &BatchRetryResult{
+		Retried: []string{"354188083613"},
+		Skipped: []string{"4561261212345467"},
+		Missing: []string{},
+	}, got, "only INVALID orders are requeued")
+	require.Len(t, orderChan, 1)
+	enqueued := <-orderChan
+	assert.Equal(t, repository.NEW, enqueued.Status)
+	assert.Zero(t, enqueued.LookupFailures)
+	assert.Nil(t, enqueued.FailureReason)
+}
+
+const initAccrualCorrectionDB = `
+CREATE TABLE IF NOT EXISTS accrual_corrections
+(
+    id INTEGER PRIMARY KEY,
+    order_id VARCHAR NOT NULL,
+    user_uuid VARCHAR NOT NULL,
+    previous_accrual NUMERIC NOT NULL,
+    accrual NUMERIC NOT NULL,
+    delta NUMERIC NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+`
+
+func TestOrderServiceImpl_CorrectAccrual(t *testing.T) {
+	db := setupInMemoryProcessorDB(t, "accrual_correction")
+	defer db.Close()
+	_, err := db.Exec(initAccrualCorrectionDB)
+	require.NoError(t, err)
+
+	userUUID := uuid.New()
+	_, err = db.Exec(`INSERT INTO wallets (user_uuid, credits, debits) VALUES (?, 150, 30)`, userUUID.String())
+	require.NoError(t, err)
+	_, err = db.Exec(`INSERT INTO orders (id, user_uuid, status, accrual) VALUES
+		('354188083613', ?, 'PROCESSED', 100), ('12345678903', ?, 'PROCESSED', 50), ('79927398713', ?, 'PROCESSING', NULL)`,
+		userUUID.String(), userUUID.String(), userUUID.String())
+	require.NoError(t, err)
+
+	os := NewOrderService(repository.NewOrderRepository(db), nil,
+		NewWalletService(repository.NewWalletRepository(db)), nil)
+	assertConsistent := func(wantCredits float64) {
+		var credits, accruals float64
+		require.NoError(t, db.Get(&credits, `SELECT credits FROM wallets WHERE user_uuid = ?`, userUUID.String()))
+		require.NoError(t, db.Get(&accruals, `SELECT SUM(accrual) FROM orders WHERE status = 'PROCESSED'`))
+		assert.Equal(t, wantCredits, credits)
+		assert.Equal(t, accruals, credits, "Wallet credits must match the processed accruals")
+	}
+
+	t.Run("Positive Delta Credits The Wallet", func(t *testing.T) {
+		correction, err := os.CorrectAccrual(defaultTenantContext(), "354188083613", 120_50)
+		require.NoError(t, err)
+		assert.Equal(t, repository.Cents(100_00), correction.PreviousAccrual)
+		assert.Equal(t, repository.Cents(20_50), correction.Delta)
+		assertConsistent(170.5)
+	})
+
+	t.Run("Negative Delta Takes Points Back", func(t *testing.T) {
+		correction, err := os.CorrectAccrual(defaultTenantContext(), "012345678903", 10_00)
+		require.NoError(t, err)
+		assert.Equal(t, "12345678903", correction.OrderID)
+		assert.Equal(t, repository.Cents(-40_00), correction.Delta)
+		assertConsistent(130.5)
+	})
+
+	t.Run("Negative Balance Is Refused", func(t *testing.T) {
+		_, err := os.CorrectAccrual(defaultTenantContext(), "354188083613", 0)
+		appErr := appErrors.ResponseCodeError{}
+		require.True(t, errors.As(err, &appErr))
+		assert.Equal(t, http.StatusConflict, appErr.Code())
+		assertConsistent(130.5)
+	})
+
+	t.Run("Zero Accrual Clears The Accrual", func(t *testing.T) {
+		correction, err := os.CorrectAccrual(defaultTenantContext(), "12345678903", 0)
+		require.NoError(t, err)
+		assert.Equal(t, repository.Cents(-10_00), correction.Delta)
+		var accrual *float64
+		require.NoError(t, db.Get(&accrual, `SELECT accrual FROM orders WHERE id = '12345678903'`))
+		assert.Nil(t, accrual)
+		assertConsistent(120.5)
+	})
+
+	t.Run("Unprocessed Order Is Refused", func(t *testing.T) {
+		_, err := os.CorrectAccrual(defaultTenantContext(), "79927398713", 10_00)
+		appErr := appErrors.ResponseCodeError{}
+		require.True(t, errors.As(err, &appErr))
+		assert.Equal(t, http.StatusConflict, appErr.Code())
+	})
+
+	var corrections []repository.AccrualCorrection
+	require.NoError(t, db.Select(&corrections, `SELECT * FROM accrual_corrections ORDER BY id`))
+	require.Len(t, corrections, 3, "Only applied corrections should be recorded")
+	assert.Equal(t, repository.Cents(120_50), corrections[0].Accrual)
+	assert.Equal(t, repository.Cents(-40_00), corrections[1].Delta)
+	assert.Equal(t, repository.Cents(0), corrections[2].Accrual)
+}
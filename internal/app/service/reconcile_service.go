@@ -0,0 +1,147 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/ujwegh/gophermart/internal/app/logger"
+	"github.com/ujwegh/gophermart/internal/app/repository"
+	"go.uber.org/zap"
+	"math"
+)
+
+// balanceTolerance absorbs float rounding when comparing expected and stored amounts.
+const balanceTolerance = 0.005
+
+type (
+	Reconciliation struct {
+		UserUUID        uuid.UUID
+		Login           string
+		ExpectedCredits float64
+		ActualCredits   float64
+		ExpectedDebits  float64
+		ActualDebits    float64
+		ExpectedBalance float64
+		ActualBalance   float64
+		Discrepancy     float64
+		Consistent      bool
+	}
+	// DebitReconciliation is a debit without a matching withdrawal and the amount refunded for it.
+	DebitReconciliation struct {
+		repository.UnmatchedDebit
+		Refunded float64
+	}
+	ReconcileService interface {
+		Reconcile(ctx context.Context, login string) (*Reconciliation, error)
+		ReconcileDebits(ctx context.Context, refund bool) ([]DebitReconciliation, error)
+	}
+	ReconcileServiceImpl struct {
+		userService    UserService
+		walletRepo     repository.WalletRepository
+		orderRepo      repository.OrderRepository
+		withdrawalRepo repository.WithdrawalsRepository
+	}
+)
+
+func NewReconcileService(userService UserService,
+	walletRepo repository.WalletRepository,
+	orderRepo repository.OrderRepository,
+	withdrawalRepo repository.WithdrawalsRepository) *ReconcileServiceImpl {
+	return &ReconcileServiceImpl{
+		userService:    userService,
+		walletRepo:     walletRepo,
+		orderRepo:      orderRepo,
+		withdrawalRepo: withdrawalRepo,
+	}
+}
+
+// Reconcile recomputes the user's balance from processed orders and withdrawals
+// and compares it with the totals stored in the wallet.
+func (rs *ReconcileServiceImpl) Reconcile(ctx context.Context, login string) (*Reconciliation, error) {
+	user, err := rs.userService.GetByUserLogin(ctx, login)
+	if err != nil {
+		return nil, err
+	}
+	wallet, err := rs.walletRepo.GetWallet(ctx, &user.UUID)
+	if err != nil {
+		return nil, fmt.Errorf("reconcile: %w", err)
+	}
+	accrued, err := rs.orderRepo.SumProcessedAccruals(ctx, &user.UUID)
+	if err != nil {
+		return nil, fmt.Errorf("reconcile: %w", err)
+	}
+	withdrawn, err := rs.withdrawalRepo.SumWithdrawals(ctx, &user.UUID)
+	if err != nil {
+		return nil, fmt.Errorf("reconcile: %w", err)
+	}
+
+	r := &Reconciliation{
+		UserUUID:        user.UUID,
+		Login:           user.Login,
+		ExpectedCredits: accrued,
+		ActualCredits:   wallet.Credits,
+		ExpectedDebits:  withdrawn,
+		ActualDebits:    wallet.Debits,
+		ExpectedBalance: accrued - withdrawn,
+		ActualBalance:   wallet.Credits - wallet.Debits,
+	}
+	r.Discrepancy = r.ActualBalance - r.ExpectedBalance
+	r.Consistent = math.Abs(r.ExpectedCredits-r.ActualCredits) < balanceTolerance &&
+		math.Abs(r.ExpectedDebits-r.ActualDebits) < balanceTolerance
+	return r, nil
+}
+
+// ReconcileDebits finds the wallets debited by more than their unreversed withdrawals add up to, which happens
+// when a debit is committed but the withdrawal record is lost. With refund set, the excess is refunded to each
+// wallet; a user whose refund fails is reported with nothing refunded and left for the next run.
+func (rs *ReconcileServiceImpl) ReconcileDebits(ctx context.Context, refund bool) ([]DebitReconciliation, error) {
+	unmatched, err := rs.walletRepo.FindUnmatchedDebits(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("reconcile debits: %w", err)
+	}
+	reconciliations := make([]DebitReconciliation, 0, len(unmatched))
+	for _, debit := range unmatched {
+		logger.Log.Warn("wallet debited without a withdrawal",
+			zap.String("user_uuid", debit.UserUUID.String()),
+			zap.Float64("debits", debit.Debits), zap.Float64("withdrawn", debit.Withdrawn))
+		reconciliation := DebitReconciliation{UnmatchedDebit: debit}
+		if refund {
+			reconciliation.Refunded, err = rs.refundUnmatched(ctx, &debit.UserUUID)
+			if err != nil {
+				logger.Log.Error("failed to refund unmatched debit", zap.String("user_uuid", debit.UserUUID.String()), zap.Error(err))
+			}
+		}
+		reconciliations = append(reconciliations, reconciliation)
+	}
+	return reconciliations, nil
+}
+
+// refundUnmatched refunds what the user's debits exceed their withdrawals by. The excess is recomputed under
+// the wallet lock, so a withdrawal made since the wallet was found can't be refunded by mistake.
+func (rs *ReconcileServiceImpl) refundUnmatched(ctx context.Context, userUID *uuid.UUID) (float64, error) {
+	var refunded float64
+	err := repository.WithTransaction(ctx, rs.withdrawalRepo.GetDB(), func(tx *sqlx.Tx) error {
+		wallet, err := rs.walletRepo.GetWalletForUpdate(ctx, tx, userUID)
+		if err != nil {
+			return err
+		}
+		withdrawn, err := rs.withdrawalRepo.SumWithdrawalsTx(ctx, tx, userUID)
+		if err != nil {
+			return err
+		}
+		excess := repository.CentsOf(wallet.Debits - withdrawn)
+		if excess <= 0 {
+			return nil
+		}
+		if _, err = rs.walletRepo.Refund(ctx, tx, userUID, excess); err != nil {
+			return err
+		}
+		refunded = excess.Float64()
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("refund unmatched debit: %w", err)
+	}
+	return refunded, nil
+}
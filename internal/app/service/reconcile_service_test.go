@@ -0,0 +1,128 @@
+package service
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/ujwegh/gophermart/internal/app/repository"
+	"testing"
+)
+
+func TestReconcileServiceImpl_Reconcile(t *testing.T) {
+	user := &repository.User{UUID: uuid.New(), Login: "user"}
+	tests := []struct {
+		name            string
+		wallet          *repository.Wallet
+		accrued         float64
+		withdrawn       float64
+		wantConsistent  bool
+		wantDiscrepancy float64
+	}{
+		{
+			name:            "Consistent Wallet",
+			wallet:          &repository.Wallet{Credits: 500, Debits: 120.5},
+			accrued:         500,
+			withdrawn:       120.5,
+			wantConsistent:  true,
+			wantDiscrepancy: 0,
+		},
+		{
+			name:            "Wallet Credited Twice",
+			wallet:          &repository.Wallet{Credits: 1000, Debits: 100},
+			accrued:         500,
+			withdrawn:       100,
+			wantConsistent:  false,
+			wantDiscrepancy: 500,
+		},
+		{
+			name:            "Missing Debit",
+			wallet:          &repository.Wallet{Credits: 500, Debits: 0},
+			accrued:         500,
+			withdrawn:       50,
+			wantConsistent:  false,
+			wantDiscrepancy: 50,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			us := &MockUserService{}
+			us.On("GetByUserLogin", mock.Anything, user.Login).Return(user, nil)
+			wr := &MockWalletRepository{}
+			wr.On("GetWallet", mock.Anything, &user.UUID).Return(tt.wallet, nil)
+			or := &MockOrderRepository{}
+			or.On("SumProcessedAccruals", mock.Anything, &user.UUID).Return(tt.accrued, nil)
+			wlr := &MockWithdrawalsRepository{}
+			wlr.On("SumWithdrawals", mock.Anything, &user.UUID).Return(tt.withdrawn, nil)
+
+			rs := NewReconcileService(us, wr, or, wlr)
+			got, err := rs.Reconcile(context.Background(), user.Login)
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantConsistent, got.Consistent)
+			assert.InDelta(t, tt.wantDiscrepancy, got.Discrepancy, balanceTolerance)
+			assert.Equal(t, tt.accrued-tt.withdrawn, got.ExpectedBalance)
+			assert.Equal(t, tt.wallet.Credits-tt.wallet.Debits, got.ActualBalance)
+		})
+	}
+}
+
+func TestReconcileServiceImpl_ReconcileDebits(t *testing.T) {
+	db, err := sqlx.Open("sqlite3", "file:reconcile_debits?mode=memory&cache=shared")
+	require.NoError(t, err)
+	defer db.Close()
+	for _, schema := range []string{initUpdateLoginDB, initWithdrawalDB} {
+		_, err = db.Exec(schema)
+		require.NoError(t, err)
+	}
+
+	userUUID := uuid.New()
+	_, err = db.Exec(`INSERT INTO users (uuid, login, password_hash) VALUES (?, 'alice', 'h')`, userUUID.String())
+	require.NoError(t, err)
+	_, err = db.Exec(`INSERT INTO wallets (user_uuid, credits) VALUES (?, 500)`, userUUID.String())
+	require.NoError(t, err)
+
+	walletRepo := repository.NewWalletRepository(db)
+	withdrawalRepo := repository.NewWithdrawalsRepository(db)
+	ws := NewWithdrawalService(withdrawalRepo, repository.NewOrderRepository(db), NewWalletService(walletRepo))
+	_, err = ws.CreateWithdrawal(defaultTenantContext(), &userUUID, "354188083613", 100_00)
+	require.NoError(t, err)
+	_, err = ws.CreateWithdrawal(defaultTenantContext(), &userUUID, "12345678903", 25_50)
+	require.NoError(t, err)
+	// the debit of the second withdrawal stays, its record is lost
+	_, err = db.Exec(`DELETE FROM withdrawals WHERE order_id = '12345678903'`)
+	require.NoError(t, err)
+
+	rs := NewReconcileService(nil, walletRepo, nil, withdrawalRepo)
+	debits := func() float64 {
+		var debits float64
+		require.NoError(t, db.Get(&debits, `SELECT debits FROM wallets WHERE user_uuid = ?`, userUUID.String()))
+		return debits
+	}
+
+	got, err := rs.ReconcileDebits(defaultTenantContext(), false)
+	require.NoError(t, err)
+	require.Len(t, got, 1)
+	assert.Equal(t, "alice", got[0].Login)
+	assert.Equal(t, 125.5, got[0].Debits)
+	assert.Equal(t, 100.0, got[0].Withdrawn)
+	assert.InDelta(t, 25.5, got[0].Unmatched(), balanceTolerance)
+	assert.Zero(t, got[0].Refunded, "a dry run must not refund")
+	assert.Equal(t, 125.5, debits())
+
+	got, err = rs.ReconcileDebits(defaultTenantContext(), true)
+	require.NoError(t, err)
+	require.Len(t, got, 1)
+	assert.Equal(t, 25.5, got[0].Refunded)
+	assert.Equal(t, 100.0, debits())
+	var refunds int
+	require.NoError(t, db.Get(&refunds, `SELECT COUNT(*) FROM wallet_transactions WHERE kind = 'REFUND' AND amount = 25.5`))
+	assert.Equal(t, 1, refunds, "the refund should be logged")
+
+	got, err = rs.ReconcileDebits(defaultTenantContext(), true)
+	require.NoError(t, err)
+	assert.Empty(t, got)
+}
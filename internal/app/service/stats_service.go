@@ -0,0 +1,24 @@
+package service
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"github.com/ujwegh/gophermart/internal/app/repository"
+)
+
+type (
+	StatsService interface {
+		GetUserStats(ctx context.Context, userUID *uuid.UUID) (*repository.UserStats, error)
+	}
+	StatsServiceImpl struct {
+		statsRepo repository.StatsRepository
+	}
+)
+
+func NewStatsService(statsRepo repository.StatsRepository) *StatsServiceImpl {
+	return &StatsServiceImpl{statsRepo: statsRepo}
+}
+
+func (ss *StatsServiceImpl) GetUserStats(ctx context.Context, userUID *uuid.UUID) (*repository.UserStats, error) {
+	return ss.statsRepo.GetUserStats(ctx, userUID)
+}
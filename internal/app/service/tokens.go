@@ -4,33 +4,94 @@ import (
 	"errors"
 	"fmt"
 	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
+	"github.com/patrickmn/go-cache"
 	"github.com/ujwegh/gophermart/internal/app/config"
 	"time"
 )
 
 type TokenService interface {
 	GetUserLogin(tokenString string) (string, error)
+	GetTenantLogin(tokenString string) (tenantID string, login string, err error)
 	GenerateToken(userEmail string) (string, error)
+	GenerateTenantToken(tenantID string, login string) (string, error)
+	RevokeToken(tokenString string) error
 }
 
+// ErrTokenRevoked is returned by GetUserLogin for a token passed to RevokeToken before.
+var ErrTokenRevoked = errors.New("token has been revoked")
+
 type Claims struct {
 	jwt.RegisteredClaims
 	UserLogin string
+	// TenantID is left out of tokens of the default tenant
+	TenantID string `json:"tenant_id,omitempty"`
 }
 
 type TokenServiceImpl struct {
 	secretKey     string
 	tokenLifetime time.Duration
+	leeway        time.Duration
+	// revoked holds the revoked tokens of this instance until they would have expired anyway
+	revoked *cache.Cache
 }
 
 func NewTokenService(cfg config.AppConfig) *TokenServiceImpl {
 	return &TokenServiceImpl{
 		secretKey:     cfg.TokenSecretKey,
 		tokenLifetime: time.Duration(cfg.TokenLifetimeSec) * time.Second,
+		leeway:        time.Duration(cfg.TokenLeewaySec) * time.Second,
+		revoked:       cache.New(cache.NoExpiration, 10*time.Minute),
 	}
 }
 
 func (ts TokenServiceImpl) GetUserLogin(tokenString string) (string, error) {
+	_, login, err := ts.GetTenantLogin(tokenString)
+	return login, err
+}
+
+// GetTenantLogin returns the tenant and the login of a valid token, "" for the tenant of tokens without one.
+func (ts TokenServiceImpl) GetTenantLogin(tokenString string) (string, string, error) {
+	claims, err := ts.parseClaims(tokenString)
+	if err != nil {
+		return "", "", err
+	}
+	if ts.revoked != nil {
+		if _, revoked := ts.revoked.Get(revocationKey(tokenString, claims)); revoked {
+			return "", "", fmt.Errorf("token error: %w", ErrTokenRevoked)
+		}
+	}
+	return claims.TenantID, claims.UserLogin, nil
+}
+
+// RevokeToken makes GetUserLogin reject the token from now on. Only valid tokens can be revoked.
+// Revocations live in memory: they are lost on restart and not shared between instances.
+func (ts TokenServiceImpl) RevokeToken(tokenString string) error {
+	claims, err := ts.parseClaims(tokenString)
+	if err != nil {
+		return err
+	}
+	if ts.revoked == nil {
+		return errors.New("token revocation is not enabled")
+	}
+	ttl := ts.tokenLifetime
+	if claims.ExpiresAt != nil {
+		ttl = time.Until(claims.ExpiresAt.Time)
+	}
+	ts.revoked.Set(revocationKey(tokenString, claims), struct{}{}, ttl+ts.leeway)
+	return nil
+}
+
+// revocationKey identifies a token by its ID, or by the whole token for tokens issued without one.
+func revocationKey(tokenString string, claims *Claims) string {
+	if claims.ID != "" {
+		return claims.ID
+	}
+	return tokenString
+}
+
+// parseClaims verifies the signature and the time-based claims of the token.
+func (ts TokenServiceImpl) parseClaims(tokenString string) (*Claims, error) {
 	claims := &Claims{}
 	token, err := jwt.ParseWithClaims(tokenString, claims,
 		func(t *jwt.Token) (interface{}, error) {
@@ -38,32 +99,57 @@ func (ts TokenServiceImpl) GetUserLogin(tokenString string) (string, error) {
 				return nil, fmt.Errorf("unexpected signing method: %v", t.Header["alg"])
 			}
 			return []byte(ts.secretKey), nil
-		})
+		}, jwt.WithoutClaimsValidation())
 	if err != nil {
-		return "", fmt.Errorf("token error: failed to parse token: %w", err)
+		return nil, fmt.Errorf("token error: failed to parse token: %w", err)
+	}
+	// jwt/v4 has no leeway option, so the time-based claims are checked here
+	if err := ts.validateClaims(claims, time.Now()); err != nil {
+		return nil, fmt.Errorf("token error: failed to parse token: %w", err)
 	}
 
 	if !token.Valid {
-		return "", fmt.Errorf("token error: %w", errors.New("token is not valid"))
+		return nil, fmt.Errorf("token error: %w", errors.New("token is not valid"))
 	}
 
 	if claims.UserLogin == "" {
-		return "", fmt.Errorf("token error: %w", errors.New("empty login in token"))
+		return nil, fmt.Errorf("token error: %w", errors.New("empty login in token"))
 	}
 
-	return claims.UserLogin, nil
+	return claims, nil
+}
+
+// validateClaims checks expiry, issued-at and not-before, tolerating clock skew up to the configured leeway.
+func (ts TokenServiceImpl) validateClaims(claims *Claims, now time.Time) error {
+	if !claims.VerifyExpiresAt(now.Add(-ts.leeway), false) {
+		return fmt.Errorf("%w by %s", jwt.ErrTokenExpired, now.Sub(claims.ExpiresAt.Time))
+	}
+	if !claims.VerifyIssuedAt(now.Add(ts.leeway), false) {
+		return jwt.ErrTokenUsedBeforeIssued
+	}
+	if !claims.VerifyNotBefore(now.Add(ts.leeway), false) {
+		return jwt.ErrTokenNotValidYet
+	}
+	return nil
 }
 
 func (ts TokenServiceImpl) GenerateToken(userEmail string) (string, error) {
+	return ts.GenerateTenantToken("", userEmail)
+}
+
+// GenerateTenantToken issues a token for the login within the tenant, "" for the default tenant.
+func (ts TokenServiceImpl) GenerateTenantToken(tenantID string, login string) (string, error) {
 	now := time.Now()
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, Claims{
 		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.NewString(),
 			Issuer:    "gophermart",
 			Subject:   "auth token",
 			ExpiresAt: jwt.NewNumericDate(now.Add(ts.tokenLifetime)),
 			IssuedAt:  jwt.NewNumericDate(now),
 		},
-		UserLogin: userEmail,
+		UserLogin: login,
+		TenantID:  tenantID,
 	})
 
 	tokenString, err := token.SignedString([]byte(ts.secretKey))
@@ -5,6 +5,9 @@ import (
 	"errors"
 	"fmt"
 	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/patrickmn/go-cache"
+	appContext "github.com/ujwegh/gophermart/internal/app/context"
 	appErrors "github.com/ujwegh/gophermart/internal/app/errors"
 	"github.com/ujwegh/gophermart/internal/app/repository"
 	"golang.org/x/crypto/bcrypt"
@@ -14,13 +17,37 @@ import (
 
 type UserService interface {
 	Create(ctx context.Context, login, password string) (*repository.User, error)
+	CreateIdempotent(ctx context.Context, login, password, idempotencyKey string) (*repository.User, error)
 	Authenticate(ctx context.Context, login, password string) (*repository.User, error)
 	GetByUserLogin(ctx context.Context, login string) (*repository.User, error)
+	UpdateLogin(ctx context.Context, userUID *uuid.UUID, newLogin string) error
 }
 
+// registrationReplayWindow is how long a registration can be retried with the same Idempotency-Key.
+const registrationReplayWindow = 10 * time.Minute
+
 type UserServiceImpl struct {
 	userRepo      repository.UserRepository
 	walletService WalletService
+	// maxLoginFailures failed logins within lockout lock the login for lockout, 0 disables the lockout
+	maxLoginFailures int
+	lockout          time.Duration
+	failedLogins     *cache.Cache
+	lockedLogins     *cache.Cache
+}
+
+// LoginLockedError is returned by Authenticate while the login is locked after too many failed attempts.
+type LoginLockedError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LoginLockedError) Error() string {
+	return fmt.Sprintf("login locked for %s", e.RetryAfter)
+}
+
+func (e *LoginLockedError) Unwrap() error {
+	msg := "Too many failed login attempts"
+	return appErrors.NewWithCode(errors.New(msg), msg, http.StatusTooManyRequests)
 }
 
 func NewUserService(userRepo repository.UserRepository, walletService WalletService) *UserServiceImpl {
@@ -30,18 +57,70 @@ func NewUserService(userRepo repository.UserRepository, walletService WalletServ
 	}
 }
 
+// WithLoginLockout locks a login for lockout once it has maxFailures failed logins within lockout.
+// A successful login resets the count.
+func (us *UserServiceImpl) WithLoginLockout(maxFailures int, lockout time.Duration) *UserServiceImpl {
+	if maxFailures <= 0 || lockout <= 0 {
+		return us
+	}
+	us.maxLoginFailures = maxFailures
+	us.lockout = lockout
+	us.failedLogins = cache.New(lockout, 2*lockout)
+	us.lockedLogins = cache.New(lockout, 2*lockout)
+	return us
+}
+
 func (us *UserServiceImpl) Authenticate(ctx context.Context, login, password string) (*repository.User, error) {
+	tenantID, _ := appContext.TenantID(ctx)
+	lockoutKey := tenantID + "/" + login
+	if err := us.checkLoginLocked(lockoutKey); err != nil {
+		return nil, err
+	}
 	user, err := us.GetByUserLogin(ctx, login)
 	if err != nil {
 		return nil, err
 	}
 	err = bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password))
 	if err != nil {
+		us.recordLoginFailure(lockoutKey)
 		return nil, appErrors.NewWithCode(err, "Invalid password", http.StatusUnauthorized)
 	}
+	if us.failedLogins != nil {
+		us.failedLogins.Delete(lockoutKey)
+	}
 	return user, nil
 }
 
+// checkLoginLocked and recordLoginFailure take the login qualified by its tenant,
+// the same login in another tenant belongs to someone else.
+func (us *UserServiceImpl) checkLoginLocked(login string) error {
+	if us.lockedLogins == nil {
+		return nil
+	}
+	if _, expiresAt, found := us.lockedLogins.GetWithExpiration(login); found {
+		return &LoginLockedError{RetryAfter: time.Until(expiresAt)}
+	}
+	return nil
+}
+
+func (us *UserServiceImpl) recordLoginFailure(login string) {
+	if us.failedLogins == nil {
+		return
+	}
+	failures := 1
+	if err := us.failedLogins.Add(login, failures, cache.DefaultExpiration); err != nil {
+		if failures, err = us.failedLogins.IncrementInt(login, 1); err != nil {
+			// the count expired in between, start over
+			failures = 1
+			us.failedLogins.Set(login, failures, cache.DefaultExpiration)
+		}
+	}
+	if failures >= us.maxLoginFailures {
+		us.failedLogins.Delete(login)
+		us.lockedLogins.Set(login, struct{}{}, cache.DefaultExpiration)
+	}
+}
+
 func (us *UserServiceImpl) GetByUserLogin(ctx context.Context, login string) (*repository.User, error) {
 	user, err := us.userRepo.FindByLogin(ctx, login)
 	if err != nil {
@@ -55,33 +134,87 @@ func (us *UserServiceImpl) GetByUserLogin(ctx context.Context, login string) (*r
 }
 
 func (us *UserServiceImpl) Create(ctx context.Context, login, password string) (*repository.User, error) {
+	user, _, err := us.create(ctx, login, password, nil)
+	return user, err
+}
+
+// CreateIdempotent creates the user like Create and stores idempotencyKey with it. When the login is taken by a user
+// registered with the same key and password within registrationReplayWindow, the request is a retry of that
+// registration and the existing user is returned instead of a conflict.
+func (us *UserServiceImpl) CreateIdempotent(ctx context.Context, login, password, idempotencyKey string) (*repository.User, error) {
+	user, conflict, err := us.create(ctx, login, password, &idempotencyKey)
+	if !conflict {
+		return user, err
+	}
+	existing, findErr := us.userRepo.FindByLogin(ctx, login)
+	if findErr != nil || !isRegistrationRetry(existing, password, idempotencyKey) {
+		return nil, err
+	}
+	return existing, nil
+}
+
+// isRegistrationRetry reports whether user was registered recently with idempotencyKey and password.
+func isRegistrationRetry(user *repository.User, password, idempotencyKey string) bool {
+	if user.RegistrationKey == nil || *user.RegistrationKey != idempotencyKey {
+		return false
+	}
+	if time.Since(user.CreatedAt) > registrationReplayWindow {
+		return false
+	}
+	return bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)) == nil
+}
+
+// create stores the user and its wallet, conflict reports whether the login was already taken.
+func (us *UserServiceImpl) create(ctx context.Context, login, password string, registrationKey *string) (*repository.User, bool, error) {
 	passwordHash := generatePasswordHash(password)
+	tenantID, _ := appContext.TenantID(ctx)
 	user := &repository.User{
-		UUID:         uuid.New(),
-		Login:        login,
-		PasswordHash: passwordHash,
-		CreatedAt:    time.Now(),
+		UUID:            uuid.New(),
+		Login:           login,
+		PasswordHash:    passwordHash,
+		CreatedAt:       time.Now(),
+		TenantID:        tenantID,
+		RegistrationKey: registrationKey,
 	}
-	tx, err := us.userRepo.GetDB().BeginTxx(ctx, nil)
+	conflict := false
+	err := repository.WithTransaction(ctx, us.userRepo.GetDB(), func(tx *sqlx.Tx) error {
+		if err := us.userRepo.Create(ctx, tx, user); err != nil {
+			appErr := &appErrors.ResponseCodeError{}
+			if errors.As(err, appErr) {
+				conflict = true
+				return appErrors.NewWithCode(err, appErr.Msg(), http.StatusConflict)
+			}
+			return fmt.Errorf("create user: %w", err)
+		}
+		return us.walletService.CreateWallet(ctx, tx, &user.UUID)
+	})
 	if err != nil {
-		return nil, fmt.Errorf("begin transaction: %w", err)
+		return nil, conflict, err
 	}
-	defer tx.Rollback()
+	return user, false, nil
+}
 
-	if err := us.userRepo.Create(ctx, tx, user); err != nil {
-		appErr := &appErrors.ResponseCodeError{}
-		if errors.As(err, appErr) {
-			return nil, appErrors.NewWithCode(err, appErr.Msg(), http.StatusConflict)
-		}
-		return nil, fmt.Errorf("create user: %w", err)
+// UpdateLogin changes the login of the user, the new login must not belong to another user.
+// Like the repository method it only returns an error: the caller knows the new login and needs no user lookup.
+func (us *UserServiceImpl) UpdateLogin(ctx context.Context, userUID *uuid.UUID, newLogin string) error {
+	existing, err := us.userRepo.FindByLogin(ctx, newLogin)
+	if err == nil && existing.UUID != *userUID {
+		msg := "Login already taken"
+		return appErrors.NewWithCode(errors.New(msg), msg, http.StatusConflict)
 	}
 
-	err = us.walletService.CreateWallet(ctx, tx, &user.UUID)
-	if err != nil {
-		return nil, err
+	err = repository.WithTransaction(ctx, us.userRepo.GetDB(), func(tx *sqlx.Tx) error {
+		return us.userRepo.UpdateLogin(ctx, tx, userUID, newLogin)
+	})
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, repository.ErrLoginTaken):
+		return appErrors.NewWithCode(err, "Login already taken", http.StatusConflict)
+	case errors.Is(err, repository.ErrUserNotFound):
+		return appErrors.NewWithCode(err, "User not found", http.StatusNotFound)
 	}
-
-	return user, tx.Commit()
+	return fmt.Errorf("update login: %w", err)
 }
 
 func generatePasswordHash(password string) string {
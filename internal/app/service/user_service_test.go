@@ -0,0 +1,220 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	appErrors "github.com/ujwegh/gophermart/internal/app/errors"
+	"github.com/ujwegh/gophermart/internal/app/repository"
+	"golang.org/x/crypto/bcrypt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// stubUserRepository finds a single user and counts the lookups.
+type stubUserRepository struct {
+	user    *repository.User
+	lookups int
+}
+
+func (r *stubUserRepository) Create(ctx context.Context, tx *sqlx.Tx, user *repository.User) error {
+	return errors.New("not implemented")
+}
+
+func (r *stubUserRepository) FindByLogin(ctx context.Context, login string) (*repository.User, error) {
+	r.lookups++
+	return r.user, nil
+}
+
+func (r *stubUserRepository) UpdateLogin(ctx context.Context, tx *sqlx.Tx, userUID *uuid.UUID, newLogin string) error {
+	return errors.New("not implemented")
+}
+
+func (r *stubUserRepository) GetDB() *sqlx.DB {
+	return nil
+}
+
+func newLockoutTestService(t *testing.T, maxFailures int, lockout time.Duration) (*UserServiceImpl, *stubUserRepository) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	require.NoError(t, err)
+	repo := &stubUserRepository{user: &repository.User{UUID: uuid.New(), Login: "alice", PasswordHash: string(hash)}}
+	return NewUserService(repo, nil).WithLoginLockout(maxFailures, lockout), repo
+}
+
+func assertResponseCode(t *testing.T, err error, code int) {
+	t.Helper()
+	appErr := appErrors.ResponseCodeError{}
+	require.True(t, errors.As(err, &appErr), "unexpected error: %v", err)
+	assert.Equal(t, code, appErr.Code())
+}
+
+func TestUserServiceImpl_Authenticate_Lockout(t *testing.T) {
+	us, repo := newLockoutTestService(t, 3, time.Minute)
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		_, err := us.Authenticate(ctx, "alice", "wrong")
+		assertResponseCode(t, err, http.StatusUnauthorized)
+	}
+
+	// even the right password is refused while the login is locked, without touching the database
+	_, err := us.Authenticate(ctx, "alice", "secret")
+	var lockedErr *LoginLockedError
+	require.True(t, errors.As(err, &lockedErr))
+	assert.InDelta(t, time.Minute.Seconds(), lockedErr.RetryAfter.Seconds(), 1)
+	assertResponseCode(t, err, http.StatusTooManyRequests)
+	assert.Equal(t, 3, repo.lookups)
+
+	// other logins are not affected
+	_, err = us.Authenticate(ctx, "bob", "secret")
+	assert.NoError(t, err)
+}
+
+func TestUserServiceImpl_Authenticate_LockoutExpires(t *testing.T) {
+	us, _ := newLockoutTestService(t, 1, 50*time.Millisecond)
+	ctx := context.Background()
+
+	_, err := us.Authenticate(ctx, "alice", "wrong")
+	assertResponseCode(t, err, http.StatusUnauthorized)
+	_, err = us.Authenticate(ctx, "alice", "secret")
+	assertResponseCode(t, err, http.StatusTooManyRequests)
+
+	time.Sleep(60 * time.Millisecond)
+	user, err := us.Authenticate(ctx, "alice", "secret")
+	require.NoError(t, err)
+	assert.Equal(t, "alice", user.Login)
+}
+
+func TestUserServiceImpl_Authenticate_SuccessResetsFailures(t *testing.T) {
+	us, _ := newLockoutTestService(t, 3, time.Minute)
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		_, err := us.Authenticate(ctx, "alice", "wrong")
+		assertResponseCode(t, err, http.StatusUnauthorized)
+	}
+	_, err := us.Authenticate(ctx, "alice", "secret")
+	require.NoError(t, err)
+
+	// the count starts over, so two more failures still don't lock the login
+	for i := 0; i < 2; i++ {
+		_, err := us.Authenticate(ctx, "alice", "wrong")
+		assertResponseCode(t, err, http.StatusUnauthorized)
+	}
+	_, err = us.Authenticate(ctx, "alice", "secret")
+	assert.NoError(t, err)
+}
+
+func TestUserServiceImpl_Authenticate_LockoutDisabled(t *testing.T) {
+	us, _ := newLockoutTestService(t, 0, time.Minute)
+	ctx := context.Background()
+
+	for i := 0; i < 10; i++ {
+		_, err := us.Authenticate(ctx, "alice", "wrong")
+		assertResponseCode(t, err, http.StatusUnauthorized)
+	}
+	_, err := us.Authenticate(ctx, "alice", "secret")
+	assert.NoError(t, err)
+}
+
+const initUpdateLoginDB = `
+CREATE TABLE IF NOT EXISTS users
+(
+    uuid          TEXT PRIMARY KEY,
+    login         TEXT NOT NULL,
+    password_hash TEXT NOT NULL,
+    created_at    TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    tenant_id     TEXT NOT NULL DEFAULT '',
+    registration_key TEXT,
+    UNIQUE (tenant_id, login)
+);
+`
+
+func newUpdateLoginTestService(t *testing.T) (*UserServiceImpl, *repository.User, *repository.User) {
+	db, err := sqlx.Open("sqlite3", "file:update_login?mode=memory&cache=shared")
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	_, err = db.Exec(initUpdateLoginDB)
+	require.NoError(t, err)
+
+	repo := repository.NewUserRepository(db)
+	alice := &repository.User{UUID: uuid.New(), Login: "alice", PasswordHash: "hash", CreatedAt: time.Now()}
+	bob := &repository.User{UUID: uuid.New(), Login: "bob", PasswordHash: "hash", CreatedAt: time.Now()}
+	for _, user := range []*repository.User{alice, bob} {
+		require.NoError(t, repository.WithTransaction(context.Background(), db, func(tx *sqlx.Tx) error {
+			return repo.Create(context.Background(), tx, user)
+		}))
+	}
+	t.Cleanup(func() { _, _ = db.Exec(`DROP TABLE users;`) })
+	return NewUserService(repo, nil), alice, bob
+}
+
+func TestUserServiceImpl_UpdateLogin(t *testing.T) {
+	us, alice, _ := newUpdateLoginTestService(t)
+	ctx := defaultTenantContext()
+
+	err := us.UpdateLogin(ctx, &alice.UUID, "alice2")
+	require.NoError(t, err)
+	user, err := us.GetByUserLogin(ctx, "alice2")
+	require.NoError(t, err)
+	assert.Equal(t, alice.UUID, user.UUID)
+
+	_, err = us.GetByUserLogin(ctx, "alice")
+	assert.Error(t, err, "the old login must be gone")
+
+	unknown := uuid.New()
+	err = us.UpdateLogin(ctx, &unknown, "carol")
+	assertResponseCode(t, err, http.StatusNotFound)
+}
+
+func TestUserServiceImpl_UpdateLogin_Conflict(t *testing.T) {
+	us, alice, bob := newUpdateLoginTestService(t)
+	ctx := defaultTenantContext()
+
+	err := us.UpdateLogin(ctx, &alice.UUID, bob.Login)
+	assertResponseCode(t, err, http.StatusConflict)
+
+	user, err := us.GetByUserLogin(ctx, "alice")
+	require.NoError(t, err)
+	assert.Equal(t, alice.UUID, user.UUID)
+}
+
+func TestUserServiceImpl_CreateIdempotent(t *testing.T) {
+	db, err := sqlx.Open("sqlite3", "file:create_idempotent?mode=memory&cache=shared")
+	require.NoError(t, err)
+	defer db.Close()
+	_, err = db.Exec(initUpdateLoginDB)
+	require.NoError(t, err)
+
+	walletRepo := &MockWalletRepository{}
+	walletRepo.On("CreateWallet", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	us := NewUserService(repository.NewUserRepository(db), NewWalletService(walletRepo))
+	ctx := defaultTenantContext()
+
+	created, err := us.CreateIdempotent(ctx, "alice", "secret", "key-1")
+	require.NoError(t, err)
+
+	// the retry finds the stored key in the database, no matter which instance handled the first request
+	retried, err := us.CreateIdempotent(ctx, "alice", "secret", "key-1")
+	require.NoError(t, err)
+	assert.Equal(t, created.UUID, retried.UUID)
+
+	_, err = us.CreateIdempotent(ctx, "alice", "guessed", "key-1")
+	assertResponseCode(t, err, http.StatusConflict)
+	_, err = us.CreateIdempotent(ctx, "alice", "secret", "key-2")
+	assertResponseCode(t, err, http.StatusConflict)
+	_, err = us.Create(ctx, "alice", "secret")
+	assertResponseCode(t, err, http.StatusConflict)
+
+	// a registration older than the replay window is not retried anymore
+	_, err = db.Exec(`UPDATE users SET created_at = $1 WHERE login = 'alice'`, time.Now().Add(-registrationReplayWindow-time.Minute))
+	require.NoError(t, err)
+	_, err = us.CreateIdempotent(ctx, "alice", "secret", "key-1")
+	assertResponseCode(t, err, http.StatusConflict)
+	walletRepo.AssertNumberOfCalls(t, "CreateWallet", 1)
+}
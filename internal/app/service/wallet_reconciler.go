@@ -0,0 +1,66 @@
+package service
+
+import (
+	"context"
+	"github.com/ujwegh/gophermart/internal/app/logger"
+	"github.com/ujwegh/gophermart/internal/app/repository"
+	"go.uber.org/zap"
+	"time"
+)
+
+// WalletReconciler periodically corrects wallet credits and debits that drifted from the wallet transaction log.
+type WalletReconciler struct {
+	walletRepo repository.WalletRepository
+	interval   time.Duration
+}
+
+func NewWalletReconciler(walletRepo repository.WalletRepository, interval time.Duration) *WalletReconciler {
+	return &WalletReconciler{
+		walletRepo: walletRepo,
+		interval:   interval,
+	}
+}
+
+// Run reconciles the wallets every interval until ctx is done.
+func (wr *WalletReconciler) Run(ctx context.Context) {
+	ticker := time.NewTicker(wr.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			wr.Reconcile(ctx)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Reconcile sets the totals of every drifted wallet to the sums of its transactions and returns how many it fixed.
+// A wallet that changes while being fixed is left for the next run.
+func (wr *WalletReconciler) Reconcile(ctx context.Context) int {
+	drifts, err := wr.walletRepo.FindDrifts(ctx)
+	if err != nil {
+		logger.Log.Error("failed to find wallet drifts", zap.Error(err))
+		return 0
+	}
+	fixed := 0
+	for i := range drifts {
+		drift := &drifts[i]
+		logger.Log.Warn("wallet totals drifted from transactions",
+			zap.String("user_uuid", drift.UserUUID.String()),
+			zap.Float64("credits", drift.Credits), zap.Float64("expected_credits", drift.ExpectedCredits),
+			zap.Float64("debits", drift.Debits), zap.Float64("expected_debits", drift.ExpectedDebits))
+		ok, err := wr.walletRepo.FixDrift(ctx, drift)
+		if err != nil {
+			logger.Log.Error("failed to fix wallet drift", zap.String("user_uuid", drift.UserUUID.String()), zap.Error(err))
+			continue
+		}
+		if ok {
+			fixed++
+		}
+	}
+	if len(drifts) > 0 {
+		logger.Log.Info("reconciled wallets", zap.Int("drifted", len(drifts)), zap.Int("fixed", fixed))
+	}
+	return fixed
+}
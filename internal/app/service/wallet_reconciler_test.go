@@ -0,0 +1,68 @@
+package service
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/ujwegh/gophermart/internal/app/logger"
+	"github.com/ujwegh/gophermart/internal/app/repository"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+	"testing"
+	"time"
+)
+
+func TestWalletReconciler_Reconcile(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+	previous := logger.Log
+	logger.Log = zap.New(core)
+	defer func() { logger.Log = previous }()
+
+	db := setupInMemoryProcessorDB(t, "wallet_reconciler")
+	defer db.Close()
+	ctx := context.Background()
+	walletRepo := repository.NewWalletRepository(db)
+
+	drifted, intact := uuid.New(), uuid.New()
+	for _, userUUID := range []uuid.UUID{drifted, intact} {
+		_, err := db.Exec(`INSERT INTO wallets (user_uuid) VALUES (?)`, userUUID.String())
+		require.NoError(t, err)
+	}
+	require.NoError(t, repository.WithTransaction(ctx, db, func(tx *sqlx.Tx) error {
+		if _, err := walletRepo.Credit(ctx, tx, &drifted, 100_00); err != nil {
+			return err
+		}
+		if _, err := walletRepo.Debit(ctx, tx, &drifted, 30_00); err != nil {
+			return err
+		}
+		if _, err := walletRepo.Refund(ctx, tx, &drifted, 10_00); err != nil {
+			return err
+		}
+		_, err := walletRepo.Credit(ctx, tx, &intact, 50_00)
+		return err
+	}))
+
+	// a manual edit that bypassed the transaction log
+	_, err := db.Exec(`UPDATE wallets SET credits = credits + 7, debits = 0 WHERE user_uuid = ?`, drifted.String())
+	require.NoError(t, err)
+
+	reconciler := NewWalletReconciler(walletRepo, time.Hour)
+	assert.Equal(t, 1, reconciler.Reconcile(ctx))
+
+	wallet, err := walletRepo.GetWallet(ctx, &drifted)
+	require.NoError(t, err)
+	assert.Equal(t, 100.0, wallet.Credits)
+	assert.Equal(t, 20.0, wallet.Debits)
+	wallet, err = walletRepo.GetWallet(ctx, &intact)
+	require.NoError(t, err)
+	assert.Equal(t, 50.0, wallet.Credits)
+
+	warnings := logs.FilterMessage("wallet totals drifted from transactions").All()
+	require.Len(t, warnings, 1)
+	assert.Equal(t, drifted.String(), warnings[0].ContextMap()["user_uuid"])
+	assert.Equal(t, 107.0, warnings[0].ContextMap()["credits"])
+
+	assert.Equal(t, 0, reconciler.Reconcile(ctx), "reconciled wallets should not drift again")
+}
@@ -5,7 +5,9 @@ import (
 	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
 	appErrors "github.com/ujwegh/gophermart/internal/app/errors"
+	"github.com/ujwegh/gophermart/internal/app/logger"
 	"github.com/ujwegh/gophermart/internal/app/repository"
+	"go.uber.org/zap"
 	"time"
 )
 
@@ -16,9 +18,13 @@ type (
 	}
 	WalletService interface {
 		CreateWallet(ctx context.Context, tx *sqlx.Tx, userUID *uuid.UUID) error
+		EnsureWallet(ctx context.Context, tx *sqlx.Tx, userUID *uuid.UUID) error
 		GetWallet(ctx context.Context, userUID *uuid.UUID) (*repository.Wallet, error)
-		Credit(ctx context.Context, tx *sqlx.Tx, userUID *uuid.UUID, amount float64) (*repository.Wallet, error)
-		Debit(ctx context.Context, tx *sqlx.Tx, userUID *uuid.UUID, amount float64) (*repository.Wallet, error)
+		GetWalletForUpdate(ctx context.Context, tx *sqlx.Tx, userUID *uuid.UUID) (*repository.Wallet, error)
+		Credit(ctx context.Context, tx *sqlx.Tx, userUID *uuid.UUID, amount repository.Cents) (*repository.Wallet, error)
+		CreditOnce(ctx context.Context, tx *sqlx.Tx, userUID *uuid.UUID, amount repository.Cents, key string) (*repository.Wallet, bool, error)
+		Debit(ctx context.Context, tx *sqlx.Tx, userUID *uuid.UUID, amount repository.Cents) (*repository.Wallet, error)
+		Refund(ctx context.Context, tx *sqlx.Tx, userUID *uuid.UUID, amount repository.Cents) (*repository.Wallet, error)
 		GetBalance(ctx context.Context, uid *uuid.UUID) (*UserBalance, error)
 	}
 	WalletServiceImpl struct {
@@ -46,6 +52,24 @@ func (ws *WalletServiceImpl) CreateWallet(ctx context.Context, tx *sqlx.Tx, user
 	return nil
 }
 
+// EnsureWallet creates an empty wallet within tx for users registered before wallets existed.
+func (ws *WalletServiceImpl) EnsureWallet(ctx context.Context, tx *sqlx.Tx, userUID *uuid.UUID) error {
+	now := time.Now()
+	wallet := repository.Wallet{
+		UserUUID:  *userUID,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	created, err := ws.walletRepo.CreateWalletIfMissing(ctx, tx, &wallet)
+	if err != nil {
+		return appErrors.New(err, "create wallet")
+	}
+	if created {
+		logger.Log.Info("created missing wallet", zap.String("user_uuid", userUID.String()))
+	}
+	return nil
+}
+
 func (ws *WalletServiceImpl) GetWallet(ctx context.Context, userUID *uuid.UUID) (*repository.Wallet, error) {
 	wallet, err := ws.walletRepo.GetWallet(ctx, userUID)
 	if err != nil {
@@ -54,14 +78,27 @@ func (ws *WalletServiceImpl) GetWallet(ctx context.Context, userUID *uuid.UUID)
 	return wallet, nil
 }
 
-func (ws *WalletServiceImpl) Credit(ctx context.Context, tx *sqlx.Tx, userUID *uuid.UUID, amount float64) (*repository.Wallet, error) {
+func (ws *WalletServiceImpl) GetWalletForUpdate(ctx context.Context, tx *sqlx.Tx, userUID *uuid.UUID) (*repository.Wallet, error) {
+	return ws.walletRepo.GetWalletForUpdate(ctx, tx, userUID)
+}
+
+func (ws *WalletServiceImpl) Credit(ctx context.Context, tx *sqlx.Tx, userUID *uuid.UUID, amount repository.Cents) (*repository.Wallet, error) {
 	return ws.walletRepo.Credit(ctx, tx, userUID, amount)
 }
 
-func (ws *WalletServiceImpl) Debit(ctx context.Context, tx *sqlx.Tx, userUID *uuid.UUID, amount float64) (*repository.Wallet, error) {
+// CreditOnce credits the wallet unless a credit with the same key was made before, reporting whether it did.
+func (ws *WalletServiceImpl) CreditOnce(ctx context.Context, tx *sqlx.Tx, userUID *uuid.UUID, amount repository.Cents, key string) (*repository.Wallet, bool, error) {
+	return ws.walletRepo.CreditOnce(ctx, tx, userUID, amount, key)
+}
+
+func (ws *WalletServiceImpl) Debit(ctx context.Context, tx *sqlx.Tx, userUID *uuid.UUID, amount repository.Cents) (*repository.Wallet, error) {
 	return ws.walletRepo.Debit(ctx, tx, userUID, amount)
 }
 
+func (ws *WalletServiceImpl) Refund(ctx context.Context, tx *sqlx.Tx, userUID *uuid.UUID, amount repository.Cents) (*repository.Wallet, error) {
+	return ws.walletRepo.Refund(ctx, tx, userUID, amount)
+}
+
 func (ws *WalletServiceImpl) GetBalance(ctx context.Context, uid *uuid.UUID) (*UserBalance, error) {
 	wallet, err := ws.GetWallet(ctx, uid)
 	if err != nil {
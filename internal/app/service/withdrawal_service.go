@@ -2,61 +2,203 @@ package service
 
 import (
 	"context"
+	"database/sql"
 	"errors"
-	"fmt"
 	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	appContext "github.com/ujwegh/gophermart/internal/app/context"
 	appErrors "github.com/ujwegh/gophermart/internal/app/errors"
+	"github.com/ujwegh/gophermart/internal/app/logger"
 	"github.com/ujwegh/gophermart/internal/app/repository"
+	"github.com/ujwegh/gophermart/internal/app/util"
+	"go.uber.org/zap"
 	"net/http"
 	"time"
 )
 
+// WithdrawalReceipt confirms a withdrawal together with the balance left after it.
+type WithdrawalReceipt struct {
+	Withdrawal repository.Withdrawal
+	Current    float64
+}
+
 type WithdrawalService interface {
-	CreateWithdrawal(ctx context.Context, userUID *uuid.UUID, orderID string, amount float64) error
-	GetWithdrawals(ctx context.Context, userUID *uuid.UUID) (*[]repository.Withdrawal, error)
+	CreateWithdrawal(ctx context.Context, userUID *uuid.UUID, orderID string, sum repository.Cents) (*WithdrawalReceipt, error)
+	CreateWithdrawalWithKey(ctx context.Context, userUID *uuid.UUID, key string, orderID string, sum repository.Cents) (*WithdrawalReceipt, error)
+	GetWithdrawalOutcome(ctx context.Context, userUID *uuid.UUID, key string) (*repository.WithdrawalOutcome, error)
+	GetWithdrawals(ctx context.Context, userUID *uuid.UUID, limit int, offset int) (*[]repository.Withdrawal, error)
+	GetWithdrawalsSorted(ctx context.Context, userUID *uuid.UUID, limit int, offset int, direction repository.SortDirection) (*[]repository.Withdrawal, error)
+	CountWithdrawals(ctx context.Context, userUID *uuid.UUID) (int, error)
+	ReverseWithdrawal(ctx context.Context, userUID *uuid.UUID, orderID string) error
 }
 
 type WithdrawalServiceImpl struct {
 	withdrawalRepo repository.WithdrawalsRepository
+	orderRepo      repository.OrderRepository
 	walletService  WalletService
 }
 
-func NewWithdrawalService(withdrawalRepo repository.WithdrawalsRepository, walletService WalletService) *WithdrawalServiceImpl {
+func NewWithdrawalService(withdrawalRepo repository.WithdrawalsRepository, orderRepo repository.OrderRepository,
+	walletService WalletService) *WithdrawalServiceImpl {
 	return &WithdrawalServiceImpl{
 		withdrawalRepo: withdrawalRepo,
+		orderRepo:      orderRepo,
 		walletService:  walletService,
 	}
 }
 
-func (bs *WithdrawalServiceImpl) CreateWithdrawal(ctx context.Context, userUID *uuid.UUID, orderID string, amount float64) error {
+// CreateWithdrawal debits the user's wallet for the order. An order number uploaded by another user is rejected
+// with 409; a number that isn't an uploaded order at all is accepted, the withdrawal pays for a new order.
+// Concurrent withdrawals of the same user are serialized by the wallet row lock, so each one checks the balance
+// left by the previous one and together they can't overdraw the wallet.
+func (bs *WithdrawalServiceImpl) CreateWithdrawal(ctx context.Context, userUID *uuid.UUID, orderID string, sum repository.Cents) (*WithdrawalReceipt, error) {
+	return bs.createWithdrawal(ctx, userUID, nil, orderID, sum)
+}
+
+// CreateWithdrawalWithKey is CreateWithdrawal made safe to retry: repeating the withdrawal with the same key,
+// order and amount succeeds without debiting again and returns the original withdrawal with the current balance,
+// reusing the key for a different withdrawal is rejected with 422.
+func (bs *WithdrawalServiceImpl) CreateWithdrawalWithKey(ctx context.Context, userUID *uuid.UUID, key string, orderID string, sum repository.Cents) (*WithdrawalReceipt, error) {
+	return bs.createWithdrawal(ctx, userUID, &key, orderID, sum)
+}
+
+func (bs *WithdrawalServiceImpl) createWithdrawal(ctx context.Context, userUID *uuid.UUID, key *string, orderID string, sum repository.Cents) (*WithdrawalReceipt, error) {
 	withdrawal := repository.Withdrawal{
-		UserUUID:  *userUID,
-		OrderID:   orderID,
-		Amount:    amount,
-		CreatedAt: time.Now(),
+		UserUUID:       *userUID,
+		OrderID:        util.NormalizeOrderNumber(orderID),
+		Amount:         sum,
+		CreatedAt:      time.Now(),
+		IdempotencyKey: key,
 	}
 
-	tx, err := bs.withdrawalRepo.GetDB().BeginTxx(ctx, nil)
+	var receipt *WithdrawalReceipt
+	err := repository.WithTransaction(ctx, bs.withdrawalRepo.GetDB(), func(tx *sqlx.Tx) error {
+		if key != nil {
+			previous, err := bs.withdrawalRepo.GetWithdrawalByKey(ctx, tx, userUID, *key)
+			if err == nil {
+				if err = checkReplay(previous, &withdrawal); err != nil {
+					return err
+				}
+				wallet, err := bs.walletService.GetWalletForUpdate(ctx, tx, userUID)
+				if err != nil {
+					return err
+				}
+				receipt = &WithdrawalReceipt{Withdrawal: *previous, Current: wallet.Credits - wallet.Debits}
+				return nil
+			}
+			if !errors.Is(err, repository.ErrWithdrawalNotFound) {
+				return err
+			}
+		}
+
+		order, err := bs.orderRepo.GetOrderByIDTx(ctx, tx, withdrawal.OrderID)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+		if err == nil && order.UserUUID != *userUID {
+			msg := "Order number belongs to another user"
+			return appErrors.NewWithCode(errors.New(msg), msg, http.StatusConflict)
+		}
+
+		// lock the wallet first so concurrent withdrawals see each other's debits
+		wallet, err := bs.walletService.GetWalletForUpdate(ctx, tx, userUID)
+		if err != nil {
+			return err
+		}
+		if repository.CentsOf(wallet.Credits-wallet.Debits) < sum {
+			msg := "insufficient funds"
+			return appErrors.NewWithCode(errors.New(msg), msg, http.StatusPaymentRequired)
+		}
+		debited, err := bs.walletService.Debit(ctx, tx, userUID, sum)
+		if err != nil {
+			return err
+		}
+		err = bs.withdrawalRepo.CreateWithdrawal(ctx, tx, &withdrawal)
+		if errors.Is(err, repository.ErrWithdrawalExists) {
+			return appErrors.NewWithCode(err, "Withdrawal for the order already exists", http.StatusConflict)
+		}
+		if errors.Is(err, repository.ErrIdempotencyKeyUsed) {
+			// a concurrent retry with the same key got there first
+			return appErrors.NewWithCode(err, "Withdrawal with the Idempotency-Key already exists", http.StatusConflict)
+		}
+		if err != nil {
+			return appErrors.NewWithCode(err, "create withdrawal", http.StatusInternalServerError)
+		}
+		receipt = &WithdrawalReceipt{Withdrawal: withdrawal, Current: debited.Credits - debited.Debits}
+
+		// a request that ran out of time must not leave the debit behind, returning an error rolls it back
+		return appContext.GetContextError(ctx)
+	})
 	if err != nil {
-		return fmt.Errorf("begin transaction: %w", err)
+		return nil, err
 	}
-	defer tx.Rollback()
-	wallet, err := bs.walletService.Debit(ctx, tx, userUID, amount)
-	if err != nil {
-		return err
+	return receipt, nil
+}
+
+// checkReplay accepts a retried withdrawal that matches the one made with its key and rejects any other.
+func checkReplay(previous *repository.Withdrawal, retry *repository.Withdrawal) error {
+	if previous.OrderID != retry.OrderID || previous.Amount != retry.Amount {
+		msg := "Idempotency-Key was already used for another withdrawal"
+		return appErrors.NewWithCode(repository.ErrIdempotencyKeyUsed, msg, http.StatusUnprocessableEntity)
 	}
-	if (wallet.Credits - wallet.Debits) < 0 {
-		msg := "insufficient funds"
-		return appErrors.NewWithCode(errors.New(msg), msg, http.StatusPaymentRequired)
+	logger.Log.Info("withdrawal replayed", zap.String("order_id", previous.OrderID))
+	return nil
+}
+
+// GetWithdrawalOutcome returns the user's withdrawal made with the idempotency key, 404 when there is none.
+func (bs *WithdrawalServiceImpl) GetWithdrawalOutcome(ctx context.Context, userUID *uuid.UUID, key string) (*repository.WithdrawalOutcome, error) {
+	outcome, err := bs.withdrawalRepo.GetWithdrawalOutcome(ctx, userUID, key)
+	if errors.Is(err, repository.ErrWithdrawalNotFound) {
+		return nil, appErrors.NewWithCode(err, "Withdrawal not found", http.StatusNotFound)
 	}
-	err = bs.withdrawalRepo.CreateWithdrawal(ctx, tx, &withdrawal)
 	if err != nil {
-		return appErrors.NewWithCode(err, "create withdrawal", http.StatusInternalServerError)
+		return nil, err
 	}
+	return outcome, nil
+}
+
+func (bs *WithdrawalServiceImpl) GetWithdrawals(ctx context.Context, userUID *uuid.UUID, limit int, offset int) (*[]repository.Withdrawal, error) {
+	return bs.withdrawalRepo.GetWithdrawals(ctx, userUID, limit, offset)
+}
+
+func (bs *WithdrawalServiceImpl) GetWithdrawalsSorted(ctx context.Context, userUID *uuid.UUID, limit int, offset int, direction repository.SortDirection) (*[]repository.Withdrawal, error) {
+	return bs.withdrawalRepo.GetWithdrawalsSorted(ctx, userUID, limit, offset, direction)
+}
 
-	return tx.Commit()
+func (bs *WithdrawalServiceImpl) CountWithdrawals(ctx context.Context, userUID *uuid.UUID) (int, error) {
+	return bs.withdrawalRepo.CountWithdrawals(ctx, userUID)
 }
 
-func (bs *WithdrawalServiceImpl) GetWithdrawals(ctx context.Context, userUID *uuid.UUID) (*[]repository.Withdrawal, error) {
-	return bs.withdrawalRepo.GetWithdrawals(ctx, userUID)
+// ReverseWithdrawal refunds the user's withdrawal for the order and records the reversal.
+// Reversing the same withdrawal again is a no-op, so the wallet is refunded at most once.
+func (bs *WithdrawalServiceImpl) ReverseWithdrawal(ctx context.Context, userUID *uuid.UUID, orderID string) error {
+	orderID = util.NormalizeOrderNumber(orderID)
+	return repository.WithTransaction(ctx, bs.withdrawalRepo.GetDB(), func(tx *sqlx.Tx) error {
+		withdrawal, err := bs.withdrawalRepo.GetWithdrawalByOrder(ctx, tx, userUID, orderID)
+		if errors.Is(err, repository.ErrWithdrawalNotFound) {
+			return appErrors.NewWithCode(err, "Withdrawal not found", http.StatusNotFound)
+		}
+		if err != nil {
+			return err
+		}
+		reversal := &repository.WithdrawalReversal{
+			WithdrawalID: withdrawal.ID,
+			UserUUID:     withdrawal.UserUUID,
+			OrderID:      withdrawal.OrderID,
+			Amount:       withdrawal.Amount,
+			CreatedAt:    time.Now(),
+		}
+		created, err := bs.withdrawalRepo.CreateReversal(ctx, tx, reversal)
+		if err != nil {
+			return err
+		}
+		if !created {
+			logger.Log.Info("withdrawal already reversed", zap.String("order_id", orderID))
+			return nil
+		}
+		if _, err = bs.walletService.Refund(ctx, tx, userUID, withdrawal.Amount); err != nil {
+			return err
+		}
+		return appContext.GetContextError(ctx)
+	})
 }
@@ -0,0 +1,91 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appErrors "github.com/ujwegh/gophermart/internal/app/errors"
+	"github.com/ujwegh/gophermart/internal/app/repository"
+	"github.com/ujwegh/gophermart/migrations"
+	"net/http"
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+// setupPostgresDB connects to the database from TEST_DATABASE_URI and applies all migrations.
+// Tests using it are skipped when the variable is not set.
+func setupPostgresDB(t *testing.T) *sqlx.DB {
+	dsn := os.Getenv("TEST_DATABASE_URI")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URI is not set")
+	}
+	db, err := sqlx.Open("pgx", dsn)
+	require.NoError(t, err)
+	require.NoError(t, repository.MigrateFS(db, migrations.FS, "."))
+	return db
+}
+
+// slowDebitWalletService holds the wallet a while before debiting it, so without the row lock
+// both withdrawals would read the balance before either debit is written.
+type slowDebitWalletService struct {
+	WalletService
+}
+
+func (ws slowDebitWalletService) Debit(ctx context.Context, tx *sqlx.Tx, userUID *uuid.UUID, amount repository.Cents) (*repository.Wallet, error) {
+	time.Sleep(100 * time.Millisecond)
+	return ws.WalletService.Debit(ctx, tx, userUID, amount)
+}
+
+func TestWithdrawalServiceImpl_CreateWithdrawal_Concurrent(t *testing.T) {
+	db := setupPostgresDB(t)
+	defer db.Close()
+
+	ctx := context.Background()
+	userUUID := uuid.New()
+	_, err := db.ExecContext(ctx, `INSERT INTO users (uuid, login, password_hash) VALUES ($1, $2, 'hash')`,
+		userUUID, "withdraw-test-"+userUUID.String())
+	require.NoError(t, err)
+	defer db.ExecContext(ctx, `DELETE FROM users WHERE uuid = $1`, userUUID)
+	_, err = db.ExecContext(ctx, `INSERT INTO wallets (user_uuid, credits) VALUES ($1, 100)`, userUUID)
+	require.NoError(t, err)
+
+	ws := NewWithdrawalService(repository.NewWithdrawalsRepository(db), repository.NewOrderRepository(db),
+		slowDebitWalletService{NewWalletService(repository.NewWalletRepository(db))})
+
+	// 60 + 60 is more than the balance of 100, only one of the two may go through
+	orderIDs := []string{"12345678903", "4561261212345467"}
+	errs := make([]error, len(orderIDs))
+	var wg sync.WaitGroup
+	for i, orderID := range orderIDs {
+		wg.Add(1)
+		go func(i int, orderID string) {
+			defer wg.Done()
+			_, errs[i] = ws.CreateWithdrawal(ctx, &userUUID, orderID, 60_00)
+		}(i, orderID)
+	}
+	wg.Wait()
+
+	succeeded := 0
+	for _, err := range errs {
+		if err == nil {
+			succeeded++
+			continue
+		}
+		appErr := appErrors.ResponseCodeError{}
+		require.True(t, errors.As(err, &appErr), "unexpected error: %v", err)
+		assert.Equal(t, http.StatusPaymentRequired, appErr.Code())
+	}
+	assert.Equal(t, 1, succeeded, "exactly one withdrawal should succeed")
+
+	var debits float64
+	require.NoError(t, db.GetContext(ctx, &debits, `SELECT debits FROM wallets WHERE user_uuid = $1`, userUUID))
+	assert.Equal(t, 60.0, debits)
+	var withdrawals int
+	require.NoError(t, db.GetContext(ctx, &withdrawals, `SELECT count(*) FROM withdrawals WHERE user_uuid = $1`, userUUID))
+	assert.Equal(t, 1, withdrawals)
+}
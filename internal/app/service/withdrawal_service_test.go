@@ -0,0 +1,232 @@
+package service
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appErrors "github.com/ujwegh/gophermart/internal/app/errors"
+	"github.com/ujwegh/gophermart/internal/app/repository"
+	"net/http"
+	"testing"
+)
+
+const initWithdrawalDB = `
+CREATE TABLE IF NOT EXISTS wallets
+(
+    id INTEGER PRIMARY KEY,
+    user_uuid TEXT UNIQUE NOT NULL,
+    credits NUMERIC NOT NULL DEFAULT 0,
+    debits NUMERIC NOT NULL DEFAULT 0,
+    version INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE TABLE IF NOT EXISTS wallet_transactions
+(
+    id INTEGER PRIMARY KEY,
+    user_uuid TEXT NOT NULL,
+    kind TEXT NOT NULL,
+    amount NUMERIC NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    idempotency_key TEXT
+);
+CREATE UNIQUE INDEX IF NOT EXISTS wallet_transactions_idempotency_key_idx ON wallet_transactions (user_uuid, idempotency_key);
+CREATE TABLE IF NOT EXISTS withdrawals
+(
+    id INTEGER PRIMARY KEY,
+    user_uuid TEXT NOT NULL,
+    order_id TEXT NOT NULL,
+    amount NUMERIC NOT NULL DEFAULT 0,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    idempotency_key TEXT
+);
+CREATE TABLE IF NOT EXISTS orders
+(
+    id TEXT PRIMARY KEY,
+    user_uuid TEXT NOT NULL,
+    status TEXT NOT NULL DEFAULT 'NEW',
+    accrual NUMERIC,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    lookup_failures INTEGER NOT NULL DEFAULT 0,
+    failure_reason TEXT,
+    archived_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    tenant_id TEXT NOT NULL DEFAULT ''
+);
+CREATE TABLE IF NOT EXISTS withdrawal_reversals
+(
+    id INTEGER PRIMARY KEY,
+    withdrawal_id INTEGER UNIQUE NOT NULL,
+    user_uuid TEXT NOT NULL,
+    order_id TEXT NOT NULL,
+    amount NUMERIC NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+`
+
+// expiringWalletService lets the request deadline pass right after the debit is written.
+type expiringWalletService struct {
+	WalletService
+	cancel context.CancelFunc
+}
+
+func (ws *expiringWalletService) Debit(ctx context.Context, tx *sqlx.Tx, userUID *uuid.UUID, amount repository.Cents) (*repository.Wallet, error) {
+	wallet, err := ws.WalletService.Debit(ctx, tx, userUID, amount)
+	ws.cancel()
+	return wallet, err
+}
+
+func TestWithdrawalServiceImpl_CreateWithdrawal_Timeout(t *testing.T) {
+	db, err := sqlx.Open("sqlite3", "file:withdrawal_timeout?mode=memory&cache=shared")
+	require.NoError(t, err)
+	defer db.Close()
+	_, err = db.Exec(initWithdrawalDB)
+	require.NoError(t, err)
+
+	userUUID := uuid.New()
+	_, err = db.Exec(`INSERT INTO wallets (user_uuid, credits) VALUES (?, 500)`, userUUID.String())
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	walletService := &expiringWalletService{
+		WalletService: NewWalletService(repository.NewWalletRepository(db)),
+		cancel:        cancel,
+	}
+	ws := NewWithdrawalService(repository.NewWithdrawalsRepository(db), repository.NewOrderRepository(db), walletService)
+
+	_, err = ws.CreateWithdrawal(ctx, &userUUID, "354188083613", 100_00)
+	assert.Error(t, err)
+
+	var debits float64
+	require.NoError(t, db.Get(&debits, `SELECT debits FROM wallets WHERE user_uuid = ?`, userUUID.String()))
+	assert.Equal(t, 0.0, debits)
+	var withdrawals int
+	require.NoError(t, db.Get(&withdrawals, `SELECT count(*) FROM withdrawals`))
+	assert.Equal(t, 0, withdrawals)
+}
+
+func TestWithdrawalServiceImpl_CreateWithdrawal_OrderOwner(t *testing.T) {
+	db, err := sqlx.Open("sqlite3", "file:withdrawal_order_owner?mode=memory&cache=shared")
+	require.NoError(t, err)
+	defer db.Close()
+	_, err = db.Exec(initWithdrawalDB)
+	require.NoError(t, err)
+
+	userUUID := uuid.New()
+	otherUUID := uuid.New()
+	_, err = db.Exec(`INSERT INTO wallets (user_uuid, credits) VALUES (?, 500)`, userUUID.String())
+	require.NoError(t, err)
+	_, err = db.Exec(`INSERT INTO orders (id, user_uuid) VALUES ('354188083613', ?), ('12345678903', ?)`,
+		otherUUID.String(), userUUID.String())
+	require.NoError(t, err)
+
+	ws := NewWithdrawalService(repository.NewWithdrawalsRepository(db), repository.NewOrderRepository(db),
+		NewWalletService(repository.NewWalletRepository(db)))
+
+	// the order was uploaded by another user, with or without leading zeros
+	for _, orderID := range []string{"354188083613", "00354188083613"} {
+		_, err = ws.CreateWithdrawal(defaultTenantContext(), &userUUID, orderID, 100_00)
+		appErr := &appErrors.ResponseCodeError{}
+		require.ErrorAs(t, err, appErr)
+		assert.Equal(t, http.StatusConflict, appErr.Code())
+		assert.Equal(t, "Order number belongs to another user", appErr.Msg())
+	}
+
+	// the user's own order and a number no one has uploaded are both fine
+	receipt, err := ws.CreateWithdrawal(defaultTenantContext(), &userUUID, "12345678903", 100_00)
+	require.NoError(t, err)
+	assert.Equal(t, "12345678903", receipt.Withdrawal.OrderID)
+	assert.Equal(t, 400.0, receipt.Current)
+	receipt, err = ws.CreateWithdrawal(defaultTenantContext(), &userUUID, "4561261212345467", 100_00)
+	require.NoError(t, err)
+	assert.Equal(t, 300.0, receipt.Current)
+
+	var debits float64
+	require.NoError(t, db.Get(&debits, `SELECT debits FROM wallets WHERE user_uuid = ?`, userUUID.String()))
+	assert.Equal(t, 200.0, debits)
+	var withdrawals int
+	require.NoError(t, db.Get(&withdrawals, `SELECT count(*) FROM withdrawals WHERE order_id = '354188083613'`))
+	assert.Equal(t, 0, withdrawals)
+}
+
+func TestWithdrawalServiceImpl_CreateWithdrawalWithKey(t *testing.T) {
+	db, err := sqlx.Open("sqlite3", "file:withdrawal_idempotency?mode=memory&cache=shared")
+	require.NoError(t, err)
+	defer db.Close()
+	_, err = db.Exec(initWithdrawalDB)
+	require.NoError(t, err)
+
+	userUUID := uuid.New()
+	_, err = db.Exec(`INSERT INTO wallets (user_uuid, credits) VALUES (?, 500)`, userUUID.String())
+	require.NoError(t, err)
+
+	ws := NewWithdrawalService(repository.NewWithdrawalsRepository(db), repository.NewOrderRepository(db),
+		NewWalletService(repository.NewWalletRepository(db)))
+	ctx := defaultTenantContext()
+
+	receipt, err := ws.CreateWithdrawalWithKey(ctx, &userUUID, "key-1", "354188083613", 100_00)
+	require.NoError(t, err)
+	assert.NotZero(t, receipt.Withdrawal.ID)
+	assert.Equal(t, 400.0, receipt.Current)
+	// a retry of the same request debits only once and gets the original withdrawal back
+	replayed, err := ws.CreateWithdrawalWithKey(ctx, &userUUID, "key-1", "00354188083613", 100_00)
+	require.NoError(t, err)
+	assert.Equal(t, receipt.Withdrawal.ID, replayed.Withdrawal.ID)
+	assert.Equal(t, "354188083613", replayed.Withdrawal.OrderID)
+	assert.Equal(t, 400.0, replayed.Current)
+
+	_, err = ws.CreateWithdrawalWithKey(ctx, &userUUID, "key-1", "354188083613", 50_00)
+	appErr := &appErrors.ResponseCodeError{}
+	require.ErrorAs(t, err, appErr)
+	assert.Equal(t, http.StatusUnprocessableEntity, appErr.Code())
+
+	var debits float64
+	require.NoError(t, db.Get(&debits, `SELECT debits FROM wallets WHERE user_uuid = ?`, userUUID.String()))
+	assert.Equal(t, 100.0, debits)
+
+	outcome, err := ws.GetWithdrawalOutcome(ctx, &userUUID, "key-1")
+	require.NoError(t, err)
+	assert.Equal(t, "354188083613", outcome.OrderID)
+	assert.Equal(t, repository.Cents(100_00), outcome.Amount)
+
+	_, err = ws.GetWithdrawalOutcome(ctx, &userUUID, "key-2")
+	require.ErrorAs(t, err, appErr)
+	assert.Equal(t, http.StatusNotFound, appErr.Code())
+}
+
+func TestWithdrawalServiceImpl_ReverseWithdrawal(t *testing.T) {
+	db, err := sqlx.Open("sqlite3", "file:withdrawal_reversal?mode=memory&cache=shared")
+	require.NoError(t, err)
+	defer db.Close()
+	_, err = db.Exec(initWithdrawalDB)
+	require.NoError(t, err)
+
+	userUUID := uuid.New()
+	_, err = db.Exec(`INSERT INTO wallets (user_uuid, credits, debits) VALUES (?, 500, 100)`, userUUID.String())
+	require.NoError(t, err)
+	_, err = db.Exec(`INSERT INTO withdrawals (user_uuid, order_id, amount) VALUES (?, '354188083613', 100)`, userUUID.String())
+	require.NoError(t, err)
+
+	ws := NewWithdrawalService(repository.NewWithdrawalsRepository(db), repository.NewOrderRepository(db),
+		NewWalletService(repository.NewWalletRepository(db)))
+
+	require.NoError(t, ws.ReverseWithdrawal(context.Background(), &userUUID, "354188083613"))
+	// a repeated reversal, e.g. a retried admin request, must not refund twice
+	require.NoError(t, ws.ReverseWithdrawal(context.Background(), &userUUID, "354188083613"))
+
+	var debits float64
+	require.NoError(t, db.Get(&debits, `SELECT debits FROM wallets WHERE user_uuid = ?`, userUUID.String()))
+	assert.Equal(t, 0.0, debits)
+	var reversals int
+	require.NoError(t, db.Get(&reversals, `SELECT count(*) FROM withdrawal_reversals`))
+	assert.Equal(t, 1, reversals)
+
+	err = ws.ReverseWithdrawal(context.Background(), &userUUID, "12345678903")
+	appErr := &appErrors.ResponseCodeError{}
+	require.ErrorAs(t, err, appErr)
+	assert.Equal(t, http.StatusNotFound, appErr.Code())
+}
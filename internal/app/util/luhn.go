@@ -0,0 +1,28 @@
+package util
+
+import (
+	"github.com/ShiraazMoollatjie/goluhn"
+	"math/rand"
+	"strings"
+)
+
+// GenerateLuhn returns a random number of the given length that starts with prefix
+// and ends with a valid Luhn check digit. A prefix that leaves no room for the check
+// digit is cut to length-1 digits; a non-positive length yields an empty string.
+func GenerateLuhn(prefix string, length int) string {
+	if length <= 0 {
+		return ""
+	}
+	if len(prefix) > length-1 {
+		prefix = prefix[:length-1]
+	}
+
+	var s strings.Builder
+	s.WriteString(prefix)
+	for s.Len() < length-1 {
+		s.WriteByte(byte('0' + rand.Intn(10)))
+	}
+
+	_, number, _ := goluhn.Calculate(s.String())
+	return number
+}
@@ -0,0 +1,37 @@
+package util
+
+import (
+	"github.com/ShiraazMoollatjie/goluhn"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestGenerateLuhn(t *testing.T) {
+	tests := []struct {
+		name       string
+		prefix     string
+		length     int
+		wantPrefix string
+		wantLength int
+	}{
+		{name: "No Prefix", prefix: "", length: 16, wantPrefix: "", wantLength: 16},
+		{name: "With Prefix", prefix: "4000", length: 12, wantPrefix: "4000", wantLength: 12},
+		{name: "Single Digit", prefix: "", length: 1, wantPrefix: "", wantLength: 1},
+		{name: "Prefix Fills Length", prefix: "12345", length: 5, wantPrefix: "1234", wantLength: 5},
+		{name: "Zero Length", prefix: "12", length: 0, wantPrefix: "", wantLength: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for i := 0; i < 100; i++ {
+				got := GenerateLuhn(tt.prefix, tt.length)
+				assert.Len(t, got, tt.wantLength)
+				assert.Truef(t, len(got) >= len(tt.wantPrefix) && got[:len(tt.wantPrefix)] == tt.wantPrefix,
+					"%s should start with %s", got, tt.wantPrefix)
+				if tt.wantLength > 0 {
+					assert.NoError(t, goluhn.Validate(got), got)
+				}
+			}
+		})
+	}
+}
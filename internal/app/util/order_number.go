@@ -0,0 +1,14 @@
+package util
+
+import "strings"
+
+// NormalizeOrderNumber returns the canonical form of an order number: leading zeros are
+// stripped, so "00123" and "123" refer to the same order. Leading zeros don't change the
+// Luhn checksum, so a valid number stays valid. A number made only of zeros becomes "0".
+func NormalizeOrderNumber(number string) string {
+	normalized := strings.TrimLeft(number, "0")
+	if normalized == "" && number != "" {
+		return "0"
+	}
+	return normalized
+}
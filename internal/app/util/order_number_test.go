@@ -0,0 +1,35 @@
+package util
+
+import (
+	"github.com/ShiraazMoollatjie/goluhn"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestNormalizeOrderNumber(t *testing.T) {
+	tests := []struct {
+		name   string
+		number string
+		want   string
+	}{
+		{name: "No Leading Zeros", number: "123", want: "123"},
+		{name: "Leading Zeros", number: "00123", want: "123"},
+		{name: "Inner Zeros Kept", number: "0010203", want: "10203"},
+		{name: "All Zeros", number: "0000", want: "0"},
+		{name: "Single Zero", number: "0", want: "0"},
+		{name: "Empty", number: "", want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, NormalizeOrderNumber(tt.number))
+		})
+	}
+}
+
+func TestNormalizeOrderNumberKeepsLuhn(t *testing.T) {
+	for _, number := range []string{"354188083613", "00354188083613", "0000000000000000012345678903"} {
+		assert.NoError(t, goluhn.Validate(number), number)
+		assert.NoError(t, goluhn.Validate(NormalizeOrderNumber(number)), number)
+	}
+}
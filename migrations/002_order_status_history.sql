@@ -0,0 +1,20 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE order_status_history
+(
+    id         BIGSERIAL PRIMARY KEY,
+    order_id   VARCHAR   NOT NULL REFERENCES orders (id) ON DELETE CASCADE,
+    status     status    NOT NULL,
+    accrual    NUMERIC,
+    changed_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX order_status_history_order_id_idx ON order_status_history (order_id, changed_at);
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE order_status_history;
+
+-- +goose StatementEnd
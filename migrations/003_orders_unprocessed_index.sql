@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE INDEX orders_unprocessed_idx ON orders (status) WHERE status IN ('NEW', 'PROCESSING');
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX orders_unprocessed_idx;
+
+-- +goose StatementEnd
@@ -0,0 +1,19 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE withdrawal_reversals
+(
+    id            BIGSERIAL PRIMARY KEY,
+    withdrawal_id BIGINT UNIQUE NOT NULL REFERENCES withdrawals (id) ON DELETE CASCADE,
+    user_uuid     UUID      NOT NULL REFERENCES users (uuid) ON DELETE CASCADE,
+    order_id      VARCHAR   NOT NULL,
+    amount        NUMERIC   NOT NULL,
+    created_at    TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE withdrawal_reversals;
+
+-- +goose StatementEnd
@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE UNIQUE INDEX withdrawals_order_id_idx ON withdrawals (order_id);
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX withdrawals_order_id_idx;
+
+-- +goose StatementEnd
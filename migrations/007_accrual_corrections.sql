@@ -0,0 +1,22 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE accrual_corrections
+(
+    id               BIGSERIAL PRIMARY KEY,
+    order_id         VARCHAR   NOT NULL REFERENCES orders (id) ON DELETE CASCADE,
+    user_uuid        UUID      NOT NULL REFERENCES users (uuid) ON DELETE CASCADE,
+    previous_accrual NUMERIC   NOT NULL,
+    accrual          NUMERIC   NOT NULL,
+    delta            NUMERIC   NOT NULL,
+    created_at       TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX accrual_corrections_order_id_idx ON accrual_corrections (order_id);
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE accrual_corrections;
+
+-- +goose StatementEnd
@@ -0,0 +1,27 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE wallet_transactions
+(
+    id         BIGSERIAL PRIMARY KEY,
+    user_uuid  UUID      NOT NULL REFERENCES users (uuid) ON DELETE CASCADE,
+    kind       VARCHAR   NOT NULL,
+    amount     NUMERIC   NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    constraint kind_known check (kind IN ('CREDIT', 'DEBIT', 'REFUND'))
+);
+
+CREATE INDEX wallet_transactions_user_uuid_idx ON wallet_transactions (user_uuid);
+
+-- opening entries, so the log adds up to the balances recorded before it existed
+INSERT INTO wallet_transactions (user_uuid, kind, amount)
+SELECT user_uuid, 'CREDIT', credits FROM wallets WHERE credits <> 0;
+INSERT INTO wallet_transactions (user_uuid, kind, amount)
+SELECT user_uuid, 'DEBIT', debits FROM wallets WHERE debits <> 0;
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE wallet_transactions;
+
+-- +goose StatementEnd
@@ -0,0 +1,18 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE device_markers
+(
+    user_uuid    UUID         NOT NULL REFERENCES users (uuid) ON DELETE CASCADE,
+    device_id    VARCHAR(128) NOT NULL,
+    last_seen_at TIMESTAMP    NOT NULL,
+    updated_at   TIMESTAMP    NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (user_uuid, device_id)
+);
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE device_markers;
+
+-- +goose StatementEnd
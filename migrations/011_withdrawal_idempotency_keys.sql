@@ -0,0 +1,13 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE withdrawals ADD COLUMN idempotency_key VARCHAR(128);
+CREATE UNIQUE INDEX withdrawals_idempotency_key_idx ON withdrawals (user_uuid, idempotency_key);
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX withdrawals_idempotency_key_idx;
+ALTER TABLE withdrawals DROP COLUMN idempotency_key;
+
+-- +goose StatementEnd
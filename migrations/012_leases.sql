@@ -0,0 +1,16 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE leases
+(
+    name       VARCHAR(64) PRIMARY KEY,
+    holder     VARCHAR(64) NOT NULL,
+    expires_at TIMESTAMP   NOT NULL
+);
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE leases;
+
+-- +goose StatementEnd
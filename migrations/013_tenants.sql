@@ -0,0 +1,17 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE users ADD COLUMN tenant_id VARCHAR(64) NOT NULL DEFAULT '';
+ALTER TABLE users DROP CONSTRAINT users_login_key;
+CREATE UNIQUE INDEX users_tenant_login_idx ON users (tenant_id, login);
+ALTER TABLE orders ADD COLUMN tenant_id VARCHAR(64) NOT NULL DEFAULT '';
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE orders DROP COLUMN tenant_id;
+DROP INDEX users_tenant_login_idx;
+ALTER TABLE users ADD CONSTRAINT users_login_key UNIQUE (login);
+ALTER TABLE users DROP COLUMN tenant_id;
+
+-- +goose StatementEnd
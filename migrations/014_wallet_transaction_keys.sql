@@ -0,0 +1,13 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE wallet_transactions ADD COLUMN idempotency_key VARCHAR(128);
+CREATE UNIQUE INDEX wallet_transactions_idempotency_key_idx ON wallet_transactions (idempotency_key);
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX wallet_transactions_idempotency_key_idx;
+ALTER TABLE wallet_transactions DROP COLUMN idempotency_key;
+
+-- +goose StatementEnd
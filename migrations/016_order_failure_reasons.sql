@@ -0,0 +1,15 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE orders ADD COLUMN failure_reason TEXT;
+
+CREATE INDEX orders_invalid_idx ON orders (updated_at) WHERE status = 'INVALID';
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX orders_invalid_idx;
+
+ALTER TABLE orders DROP COLUMN failure_reason;
+
+-- +goose StatementEnd
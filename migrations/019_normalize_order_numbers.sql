@@ -0,0 +1,66 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Order numbers stored before they were normalized keep their leading zeros and can't be found by the normalized
+-- form anymore. Rewrite them, and the rows referring to them, unless the normalized number is taken already.
+ALTER TABLE order_status_history DROP CONSTRAINT order_status_history_order_id_fkey;
+ALTER TABLE order_status_history ADD CONSTRAINT order_status_history_order_id_fkey
+    FOREIGN KEY (order_id) REFERENCES orders (id) ON DELETE CASCADE ON UPDATE CASCADE;
+ALTER TABLE accrual_corrections DROP CONSTRAINT accrual_corrections_order_id_fkey;
+ALTER TABLE accrual_corrections ADD CONSTRAINT accrual_corrections_order_id_fkey
+    FOREIGN KEY (order_id) REFERENCES orders (id) ON DELETE CASCADE ON UPDATE CASCADE;
+
+-- of several legacy forms of the same number only the smallest is rewritten
+CREATE TEMPORARY TABLE order_renames AS
+SELECT DISTINCT ON (normalized_id) legacy_id, normalized_id
+FROM (SELECT id AS legacy_id, COALESCE(NULLIF(ltrim(id, '0'), ''), '0') AS normalized_id
+      FROM orders
+      WHERE id LIKE '0_%') legacy
+WHERE NOT EXISTS (SELECT 1 FROM orders taken WHERE taken.id = legacy.normalized_id)
+ORDER BY normalized_id, legacy_id;
+
+-- the accrual credits are keyed by order number, see accrualCreditKey
+UPDATE wallet_transactions t
+SET idempotency_key = 'order/' || r.normalized_id || substr(t.idempotency_key, length('order/' || r.legacy_id) + 1)
+FROM order_renames r
+WHERE t.idempotency_key LIKE 'order/' || r.legacy_id || '/%';
+
+UPDATE orders o
+SET id = r.normalized_id
+FROM order_renames r
+WHERE o.id = r.legacy_id;
+
+DROP TABLE order_renames;
+
+CREATE TEMPORARY TABLE withdrawal_renames AS
+SELECT DISTINCT ON (normalized_id) legacy_id, normalized_id
+FROM (SELECT order_id AS legacy_id, COALESCE(NULLIF(ltrim(order_id, '0'), ''), '0') AS normalized_id
+      FROM withdrawals
+      WHERE order_id LIKE '0_%') legacy
+WHERE NOT EXISTS (SELECT 1 FROM withdrawals taken WHERE taken.order_id = legacy.normalized_id)
+ORDER BY normalized_id, legacy_id;
+
+UPDATE withdrawal_reversals wr
+SET order_id = r.normalized_id
+FROM withdrawal_renames r
+WHERE wr.order_id = r.legacy_id;
+
+UPDATE withdrawals w
+SET order_id = r.normalized_id
+FROM withdrawal_renames r
+WHERE w.order_id = r.legacy_id;
+
+DROP TABLE withdrawal_renames;
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+-- the leading zeros are gone for good, only the constraints are restored
+ALTER TABLE accrual_corrections DROP CONSTRAINT accrual_corrections_order_id_fkey;
+ALTER TABLE accrual_corrections ADD CONSTRAINT accrual_corrections_order_id_fkey
+    FOREIGN KEY (order_id) REFERENCES orders (id) ON DELETE CASCADE;
+ALTER TABLE order_status_history DROP CONSTRAINT order_status_history_order_id_fkey;
+ALTER TABLE order_status_history ADD CONSTRAINT order_status_history_order_id_fkey
+    FOREIGN KEY (order_id) REFERENCES orders (id) ON DELETE CASCADE;
+
+-- +goose StatementEnd
@@ -0,0 +1,16 @@
+-- +goose Up
+-- +goose StatementBegin
+-- The credit keys only have to be unique within a user's log, like the withdrawal idempotency keys.
+-- The keys stored so far stay valid, the user is part of the index instead of the key.
+DROP INDEX wallet_transactions_idempotency_key_idx;
+CREATE UNIQUE INDEX wallet_transactions_idempotency_key_idx ON wallet_transactions (user_uuid, idempotency_key);
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+-- fails if two users already hold the same key
+DROP INDEX wallet_transactions_idempotency_key_idx;
+CREATE UNIQUE INDEX wallet_transactions_idempotency_key_idx ON wallet_transactions (idempotency_key);
+
+-- +goose StatementEnd
@@ -0,0 +1,17 @@
+-- +goose Up
+-- +goose StatementBegin
+-- The accrual credits are keyed by order number alone now, see accrualCreditKey. The keys of PROCESSED orders are
+-- rewritten so saving such an order again doesn't credit it a second time.
+UPDATE wallet_transactions
+SET idempotency_key = left(idempotency_key, length(idempotency_key) - length('/PROCESSED'))
+WHERE idempotency_key LIKE 'order/%/PROCESSED';
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+UPDATE wallet_transactions
+SET idempotency_key = idempotency_key || '/PROCESSED'
+WHERE idempotency_key LIKE 'order/%' AND idempotency_key NOT LIKE 'order/%/%';
+
+-- +goose StatementEnd